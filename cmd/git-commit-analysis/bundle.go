@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/kerneldump/git-dual-context/pkg/bundle"
+	"github.com/kerneldump/git-dual-context/pkg/output"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// replayBundle re-renders a run from a reproducibility bundle to sink,
+// logging to logger.
+// Stored LLM responses are re-parsed, so neither the repository nor an
+// API key is needed.
+func replayBundle(path string, sink output.Sink, logger *slog.Logger) error {
+	m, err := bundle.Read(path)
+	if err != nil {
+		return err
+	}
+
+	msg := fmt.Sprintf("Replaying bundle recorded %s: %d commits of %s for error: %q",
+		m.CreatedAt.Format(time.RFC3339), len(m.Commits), m.Repo, m.ErrorMessage)
+	logger.Info(msg)
+
+	printer := newOrderedPrinter(sink, logger, len(m.Commits))
+	for _, c := range m.Commits {
+		res, err := c.Result()
+		printer.submit(&commitResult{
+			index:  c.Index,
+			result: res,
+			err:    err,
+			commit: &object.Commit{Hash: plumbing.NewHash(c.Hash), Message: c.Message},
+		})
+	}
+
+	// Report the original run's duration, not the replay's
+	duration, _ := time.ParseDuration(m.Summary.Duration)
+	return sink.WriteSummary(printer.summary(duration, m.Model))
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kerneldump/git-dual-context/pkg/history"
+)
+
+// checkpointVersion is the format version of checkpoint files
+const checkpointVersion = 1
+
+// checkpoint records the verdicts of an interrupted run, which -resume
+// reuses instead of analyzing those commits again
+type checkpoint struct {
+	Version      int              `json:"version"`
+	CreatedAt    time.Time        `json:"created_at"`
+	ErrorMessage string           `json:"error_message"`
+	Model        string           `json:"model"`
+	Branch       string           `json:"branch,omitempty"`
+	Repos        []checkpointRepo `json:"repos"`
+}
+
+// checkpointRepo holds one repository's part of a checkpoint
+type checkpointRepo struct {
+	Repo     string            `json:"repo"`
+	Verdicts []history.Verdict `json:"verdicts"`
+
+	// Remaining lists the commits left without a verdict
+	Remaining []string `json:"remaining,omitempty"`
+}
+
+// writeCheckpoint writes cp to path, replacing any previous checkpoint
+// only once the new one is complete
+func writeCheckpoint(path string, cp *checkpoint) error {
+	cp.Version = checkpointVersion
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".checkpoint-*")
+	if err != nil {
+		return fmt.Errorf("writing checkpoint: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing checkpoint: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("writing checkpoint: %w", err)
+	}
+	return nil
+}
+
+// readCheckpoint reads the checkpoint at path
+func readCheckpoint(path string) (*checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cp checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint %s: %w", path, err)
+	}
+	if cp.Version != checkpointVersion {
+		return nil, fmt.Errorf("checkpoint %s has unsupported version %d", path, cp.Version)
+	}
+	return &cp, nil
+}
+
+// verdict returns the checkpointed verdict for commit of repo
+func (cp *checkpoint) verdict(repo, commit string) (history.Verdict, bool) {
+	if cp == nil {
+		return history.Verdict{}, false
+	}
+	for _, r := range cp.Repos {
+		if r.Repo != repo {
+			continue
+		}
+		for _, v := range r.Verdicts {
+			if v.Commit == commit {
+				return v, true
+			}
+		}
+	}
+	return history.Verdict{}, false
+}
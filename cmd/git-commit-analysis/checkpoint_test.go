@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kerneldump/git-dual-context/pkg/history"
+)
+
+func TestCheckpointRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	cp := &checkpoint{
+		CreatedAt:    time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+		ErrorMessage: "session expired too early",
+		Model:        "gemini-test",
+		Repos: []checkpointRepo{{
+			Repo:      "/src/app",
+			Verdicts:  []history.Verdict{{Commit: "abc123", Probability: "HIGH", Reasoning: "Changes the TTL"}},
+			Remaining: []string{"def456"},
+		}},
+	}
+	if err := writeCheckpoint(path, cp); err != nil {
+		t.Fatalf("writeCheckpoint failed: %v", err)
+	}
+
+	got, err := readCheckpoint(path)
+	if err != nil {
+		t.Fatalf("readCheckpoint failed: %v", err)
+	}
+	if got.ErrorMessage != cp.ErrorMessage || got.Model != cp.Model || !got.CreatedAt.Equal(cp.CreatedAt) {
+		t.Errorf("Checkpoint changed in the round trip: %+v", got)
+	}
+	if v, ok := got.verdict("/src/app", "abc123"); !ok || v.Probability != "HIGH" {
+		t.Errorf("Expected the HIGH verdict of abc123, got %+v, %v", v, ok)
+	}
+	if _, ok := got.verdict("/src/app", "def456"); ok {
+		t.Error("Expected no verdict for a remaining commit")
+	}
+	if _, ok := got.verdict("/src/other", "abc123"); ok {
+		t.Error("Expected no verdict from another repository")
+	}
+	var none *checkpoint
+	if _, ok := none.verdict("/src/app", "abc123"); ok {
+		t.Error("Expected no verdict without a checkpoint")
+	}
+}
+
+func TestReadCheckpointVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	data, _ := json.Marshal(map[string]any{"version": checkpointVersion + 1})
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readCheckpoint(path); err == nil {
+		t.Error("Expected an error for an unsupported version")
+	}
+}
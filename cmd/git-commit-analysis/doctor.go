@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/kerneldump/git-dual-context/pkg/analyzer"
+	"github.com/kerneldump/git-dual-context/pkg/config"
+	"github.com/kerneldump/git-dual-context/pkg/validator"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+)
+
+// doctorTimeout bounds each network check
+const doctorTimeout = 30 * time.Second
+
+// errDoctorFailed is returned when at least one check fails
+var errDoctorFailed = errors.New("one or more checks failed")
+
+// checkResult is one line of doctor output
+type checkResult struct {
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Status string `json:"status"` // ok, fail, or skip
+	Detail string `json:"detail,omitempty"`
+}
+
+// doctor runs preflight checks and writes one NDJSON record per check
+type doctor struct {
+	encoder *json.Encoder
+	failed  bool
+}
+
+func (d *doctor) report(name string, err error, okDetail string) bool {
+	res := checkResult{Type: "check", Name: name, Status: "ok", Detail: okDetail}
+	if err != nil {
+		res.Status = "fail"
+		res.Detail = err.Error()
+		d.failed = true
+	}
+	_ = d.encoder.Encode(res)
+	return err == nil
+}
+
+func (d *doctor) skip(name, reason string) {
+	_ = d.encoder.Encode(checkResult{Type: "check", Name: name, Status: "skip", Detail: reason})
+}
+
+// runDoctor implements the "doctor" subcommand. It verifies the config,
+// repository, branch, API key, and model up front so that problems are
+// reported once and clearly instead of partway through a run.
+func runDoctor(ctx context.Context, cfg *config.Config, args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	repoPath := fs.String("repo", ".", "Path to the git repository or remote URL")
+	branch := fs.String("branch", "", "Branch, tag, remote-tracking ref, or commit to analyze (default: current HEAD)")
+	modelName := fs.String("model", cfg.LLM.Model, "Gemini model to use")
+	apiKey := fs.String("apikey", "", "Google Gemini API Key (prefer GEMINI_API_KEY env var)")
+	offline := fs.Bool("offline", false, "Skip checks that call the Gemini API")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	d := &doctor{encoder: json.NewEncoder(w)}
+
+	// 1. Config file, repository config, profile, and environment
+	// overrides load and validate
+	cfgPath := config.FindConfigFile()
+	fileCfg, err := config.LoadConfig(cfgPath)
+	repoConfig := ""
+	if !analyzer.IsRemoteURL(*repoPath) {
+		repoConfig = config.FindRepoConfigFile(*repoPath)
+	}
+	var ignoredSections []string
+	if err == nil && repoConfig != "" && !config.SameFile(cfgPath, repoConfig) {
+		ignoredSections, err = fileCfg.ApplyRepoConfig(repoConfig)
+	} else {
+		repoConfig = ""
+	}
+	profile := os.Getenv(config.ProfileEnv)
+	if err == nil && profile != "" {
+		err = fileCfg.ApplyProfile(profile)
+	}
+	var overrides []string
+	if err == nil {
+		overrides, err = fileCfg.ApplyEnv(os.LookupEnv)
+	}
+	if err == nil {
+		err = fileCfg.Validate()
+	}
+	detail := "no config file found, using defaults"
+	if cfgPath != "" {
+		detail = cfgPath
+	}
+	if repoConfig != "" {
+		detail += "; repository config " + repoConfig
+	}
+	if len(ignoredSections) > 0 {
+		detail += " (ignoring " + strings.Join(ignoredSections, ", ") + ")"
+	}
+	if profile != "" {
+		detail += "; profile " + profile
+	}
+	if len(overrides) > 0 {
+		detail += "; overridden by " + strings.Join(overrides, ", ")
+	}
+	d.report("config", err, detail)
+
+	// 2. Repository opens (or is reachable, for remote URLs)
+	branchErr := validator.ValidateRef(*branch)
+	if branchErr != nil {
+		*branch = ""
+	}
+	repoOK := d.report("repo", checkRepo(ctx, *repoPath, *branch, cfg.Clone), *repoPath)
+
+	// 3. Branch (or HEAD) resolves to a commit
+	switch {
+	case branchErr != nil:
+		d.report("branch", branchErr, "")
+	case !repoOK:
+		d.skip("branch", "repository unavailable")
+	case analyzer.IsRemoteURL(*repoPath):
+		d.skip("branch", "checked as part of the remote listing")
+	default:
+		hash, err := resolveHead(*repoPath, *branch)
+		d.report("branch", err, hash)
+	}
+
+	// 4. API keys are present and accepted, and the model exists; with
+	// llm.api_keys each key is checked as api_key:NAME
+	if *offline {
+		d.skip("api_key", "offline mode")
+		d.skip("model", "offline mode")
+	} else if keys, err := cfg.APIKeys(ctx, *apiKey); err != nil {
+		d.report("api_key", err, "")
+		d.skip("model", "no API key")
+	} else {
+		for _, k := range keys {
+			suffix := ""
+			if len(keys) > 1 {
+				suffix = ":" + k.Name
+			}
+			checkModel(ctx, d, k.Value, *modelName, suffix)
+		}
+	}
+
+	if d.failed {
+		return errDoctorFailed
+	}
+	return nil
+}
+
+// checkRepo opens a local repository, or lists the refs of a remote one
+// allowed by clone's host lists without cloning it
+func checkRepo(ctx context.Context, repoPath, branch string, clone config.CloneConfig) error {
+	if err := validator.ValidateRepoPath(repoPath); err != nil {
+		return err
+	}
+	if err := validator.ValidateRepoHost(repoPath, clone.AllowedHosts, clone.DeniedHosts); err != nil {
+		return err
+	}
+	if !analyzer.IsRemoteURL(repoPath) {
+		_, err := analyzer.OpenRepository(repoPath)
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, doctorTimeout)
+	defer cancel()
+	remote := git.NewRemote(memory.NewStorage(), &gitconfig.RemoteConfig{
+		Name: "origin",
+		URLs: []string{repoPath},
+	})
+	method, err := analyzer.RemoteAuthFromEnv(clone.Username, clone.SSHKey).Method(repoPath)
+	if err != nil {
+		return err
+	}
+	refs, err := remote.ListContext(ctx, &git.ListOptions{Auth: method})
+	if err != nil {
+		return fmt.Errorf("failed to list remote: %w", err)
+	}
+	if branch == "" {
+		return nil
+	}
+	// Match names as git rev-parse would: branches, tags, and full refs
+	for _, ref := range refs {
+		for _, rule := range plumbing.RefRevParseRules {
+			if ref.Name().String() == fmt.Sprintf(rule, branch) {
+				return nil
+			}
+		}
+	}
+	// Commits other than ref tips are not listed; cloning resolves them
+	if hexHashRe.MatchString(branch) {
+		return nil
+	}
+	return fmt.Errorf("branch %s not found on remote", branch)
+}
+
+// hexHashRe matches full and abbreviated commit hashes
+var hexHashRe = regexp.MustCompile(`^[0-9a-f]{4,40}$`)
+
+// resolveHead returns the commit hash the analysis would start from
+func resolveHead(repoPath, branch string) (string, error) {
+	r, err := analyzer.OpenRepository(repoPath)
+	if err != nil {
+		return "", err
+	}
+	commit, err := analyzer.ResolveCommit(r, branch)
+	if err != nil {
+		return "", err
+	}
+	return commit.Hash.String(), nil
+}
+
+// checkModel verifies the API key is accepted and the model exists,
+// reporting checks named with suffix
+func checkModel(ctx context.Context, d *doctor, key, modelName, suffix string) {
+	ctx, cancel := context.WithTimeout(ctx, doctorTimeout)
+	defer cancel()
+
+	client, err := genai.NewClient(ctx, option.WithAPIKey(key))
+	if err != nil {
+		d.report("api_key"+suffix, fmt.Errorf("failed to create Gemini client: %w", err), "")
+		d.skip("model"+suffix, "no client")
+		return
+	}
+	defer client.Close()
+
+	// Fetching model metadata proves the key is valid and the model exists;
+	// a 404 means the key works but the model name is wrong
+	model := client.GenerativeModel(modelName)
+	info, err := model.Info(ctx)
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+		d.report("api_key"+suffix, nil, "accepted")
+		d.report("model"+suffix, fmt.Errorf("model %s not found", modelName), "")
+		return
+	}
+	if err != nil {
+		d.report("api_key"+suffix, err, "")
+		d.skip("model"+suffix, "API key rejected")
+		return
+	}
+
+	// A 1-token ping proves the key may also generate content
+	model.SetMaxOutputTokens(1)
+	_, err = model.GenerateContent(ctx, genai.Text("ping"))
+	d.report("api_key"+suffix, err, "1-token ping succeeded")
+	d.report("model"+suffix, nil, fmt.Sprintf("%s (input limit %d tokens)", info.Name, info.InputTokenLimit))
+}
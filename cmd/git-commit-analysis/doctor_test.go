@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kerneldump/git-dual-context/pkg/config"
+)
+
+// runDoctorChecks runs the doctor offline and returns checks by name
+func runDoctorChecks(t *testing.T, args ...string) (map[string]checkResult, error) {
+	t.Helper()
+	var out bytes.Buffer
+	err := runDoctor(context.Background(), config.DefaultConfig(), append(args, "-offline"), &out)
+
+	checks := make(map[string]checkResult)
+	dec := json.NewDecoder(&out)
+	for dec.More() {
+		var c checkResult
+		if err := dec.Decode(&c); err != nil {
+			t.Fatalf("Invalid doctor output: %v", err)
+		}
+		checks[c.Name] = c
+	}
+	return checks, err
+}
+
+func TestDoctor_HealthyRepo(t *testing.T) {
+	repoPath := filepath.Join(t.TempDir(), "repo")
+	createTestRepo(t, repoPath)
+
+	checks, err := runDoctorChecks(t, "-repo", repoPath)
+	if err != nil {
+		t.Fatalf("Expected all checks to pass, got %v: %+v", err, checks)
+	}
+	for _, name := range []string{"repo", "branch"} {
+		if checks[name].Status != "ok" {
+			t.Errorf("Expected %s check ok, got %+v", name, checks[name])
+		}
+	}
+	if checks["api_key"].Status != "skip" || checks["model"].Status != "skip" {
+		t.Errorf("Expected API checks skipped offline, got %+v / %+v", checks["api_key"], checks["model"])
+	}
+}
+
+func TestDoctor_Failures(t *testing.T) {
+	repoPath := filepath.Join(t.TempDir(), "repo")
+	createTestRepo(t, repoPath)
+
+	tests := []struct {
+		name      string
+		args      []string
+		failCheck string
+	}{
+		{"missing branch", []string{"-repo", repoPath, "-branch", "nope"}, "branch"},
+		{"invalid branch", []string{"-repo", repoPath, "-branch", "bad..name"}, "branch"},
+		{"not a repository", []string{"-repo", t.TempDir()}, "repo"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checks, err := runDoctorChecks(t, tt.args...)
+			if !errors.Is(err, errDoctorFailed) {
+				t.Errorf("Expected errDoctorFailed, got %v", err)
+			}
+			if checks[tt.failCheck].Status != "fail" {
+				t.Errorf("Expected %s check to fail, got %+v", tt.failCheck, checks[tt.failCheck])
+			}
+		})
+	}
+}
+
+func TestDoctor_RepoConfig(t *testing.T) {
+	repoPath := filepath.Join(t.TempDir(), "repo")
+	createTestRepo(t, repoPath)
+	repoConfig := filepath.Join(repoPath, ".git-dual-context.yaml")
+	if err := os.WriteFile(repoConfig, []byte("analysis:\n  default_commits: 5\nllm:\n  model: x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	checks, err := runDoctorChecks(t, "-repo", repoPath)
+	if err != nil {
+		t.Fatalf("Expected all checks to pass, got %v: %+v", err, checks)
+	}
+	detail := checks["config"].Detail
+	if !strings.Contains(detail, "repository config "+repoConfig) || !strings.Contains(detail, "ignoring llm") {
+		t.Errorf("Expected the repository config and its ignored sections reported, got %q", detail)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kerneldump/git-dual-context/pkg/analyzer"
+	"github.com/kerneldump/git-dual-context/pkg/config"
+	"github.com/kerneldump/git-dual-context/pkg/history"
+)
+
+const historyUsage = `usage: git-commit-analysis history <command> [flags]
+
+Commands:
+  list              List recent runs
+  show <run-id>     Show a run and its verdicts
+  query [flags]     Search stored verdicts across runs`
+
+// runHistory implements the "history" subcommand. Records are written to
+// stdout as NDJSON, one run or verdict per line.
+func runHistory(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing command\n%s", historyUsage)
+	}
+
+	fs := flag.NewFlagSet("history "+args[0], flag.ContinueOnError)
+	dbPath := fs.String("db", cfg.History.Path, "Path to the history database")
+	limit := fs.Int("n", 20, "Maximum number of records to return")
+
+	var filter history.Filter
+	var errorMsg string
+	var since time.Duration
+	if args[0] == "query" {
+		fs.StringVar(&filter.Repo, "repo", "", "Repository path or URL")
+		fs.StringVar(&errorMsg, "error", "", "Error message (matched by fingerprint)")
+		fs.StringVar(&filter.Fingerprint, "fingerprint", "", "Error fingerprint")
+		fs.StringVar(&filter.Commit, "commit", "", "Commit hash or prefix")
+		fs.StringVar(&filter.Probability, "probability", "", "Verdict probability (HIGH, MEDIUM, LOW)")
+		fs.DurationVar(&since, "since", 0, "Only verdicts recorded within this duration (e.g. 168h)")
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	store, err := history.Open(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	encoder := json.NewEncoder(os.Stdout)
+
+	switch args[0] {
+	case "list":
+		runs, err := store.ListRuns(*limit)
+		if err != nil {
+			return err
+		}
+		for _, r := range runs {
+			if err := encoder.Encode(r); err != nil {
+				return err
+			}
+		}
+
+	case "show":
+		if fs.NArg() != 1 {
+			return fmt.Errorf("show requires exactly one run ID")
+		}
+		run, verdicts, err := store.GetRun(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		if err := encoder.Encode(run); err != nil {
+			return err
+		}
+		for _, v := range verdicts {
+			if err := encoder.Encode(v); err != nil {
+				return err
+			}
+		}
+
+	case "query":
+		if errorMsg != "" {
+			filter.Fingerprint = history.Fingerprint(errorMsg)
+		}
+		if filter.Repo != "" && !analyzer.IsRemoteURL(filter.Repo) {
+			if abs, err := filepath.Abs(filter.Repo); err == nil {
+				filter.Repo = abs
+			}
+		}
+		if since > 0 {
+			filter.Since = time.Now().UTC().Add(-since)
+		}
+		filter.Limit = *limit
+		verdicts, err := store.Query(filter)
+		if err != nil {
+			return err
+		}
+		for _, v := range verdicts {
+			if err := encoder.Encode(v); err != nil {
+				return err
+			}
+		}
+
+	default:
+		return fmt.Errorf("unknown command %q\n%s", args[0], historyUsage)
+	}
+
+	return nil
+}
@@ -271,6 +271,84 @@ func TestIntegration_OutputFile(t *testing.T) {
 	}
 }
 
+// TestIntegration_MultiRepo analyzes two repositories offline in one run
+func TestIntegration_MultiRepo(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	tmpDir := t.TempDir()
+	repoA := filepath.Join(tmpDir, "service-a")
+	repoB := filepath.Join(tmpDir, "service-b")
+	createTestRepo(t, repoA)
+	createTestRepo(t, repoB)
+	manifest := filepath.Join(tmpDir, "repos.txt")
+	if err := os.WriteFile(manifest, []byte("# fleet\n\n"+repoB+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write manifest: %v", err)
+	}
+
+	binaryPath := filepath.Join(tmpDir, "git-commit-analysis")
+	buildCmd := exec.Command("go", "build", "-o", binaryPath, ".")
+	if err := buildCmd.Run(); err != nil {
+		t.Fatalf("Failed to build CLI: %v", err)
+	}
+
+	var stdout bytes.Buffer
+	cmd := exec.Command(
+		binaryPath,
+		"-repo", repoA,
+		"-repos-file", manifest,
+		"-error", "panic in main: Println",
+		"-n", "2",
+		"-j", "2",
+		"-offline",
+		"-no-history",
+	)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	if err := runWithContext(ctx, cmd); err != nil {
+		t.Fatalf("CLI execution failed: %v\n%s", err, stdout.String())
+	}
+
+	repos := map[string]int{}
+	var summary analyzer.Summary
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		var generic struct {
+			Type string `json:"type"`
+		}
+		if json.Unmarshal([]byte(line), &generic) != nil {
+			continue
+		}
+		switch generic.Type {
+		case "result":
+			var result analyzer.JSONResult
+			if err := json.Unmarshal([]byte(line), &result); err != nil {
+				t.Fatalf("Failed to parse result: %v", err)
+			}
+			repos[result.Repo]++
+		case "summary":
+			if err := json.Unmarshal([]byte(line), &summary); err != nil {
+				t.Fatalf("Failed to parse summary: %v", err)
+			}
+		}
+	}
+
+	if repos[repoA] != 2 || repos[repoB] != 2 || len(repos) != 2 {
+		t.Errorf("Expected 2 results from each repository, got %v", repos)
+	}
+	if summary.Total != 4 {
+		t.Errorf("Expected a merged summary of 4 commits, got %d", summary.Total)
+	}
+	for _, entry := range summary.Ranking {
+		if !strings.HasPrefix(entry, repoA+"@") && !strings.HasPrefix(entry, repoB+"@") {
+			t.Errorf("Expected ranking entries qualified by repository, got %q", entry)
+		}
+	}
+}
+
 // Helper functions
 
 func createTestRepo(t *testing.T, path string) *git.Repository {
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"github.com/kerneldump/git-dual-context/pkg/analyzer"
+	"github.com/kerneldump/git-dual-context/pkg/config"
+)
+
+// geminiOptions returns the llm settings of cfg for a Gemini model named
+// modelName calling with keys, whose rate limited keys are reported to
+// logf
+func geminiOptions(cfg *config.Config, keys []config.ResolvedKey, modelName string, logf func(format string, args ...any)) analyzer.GeminiOptions {
+	opts := analyzer.GeminiOptions{
+		Model:           modelName,
+		KeyRotation:     cfg.LLM.KeyRotation,
+		Temperature:     cfg.LLM.Temperature,
+		Stream:          cfg.LLM.Stream,
+		ContextCache:    cfg.LLM.ContextCache,
+		ContextCacheTTL: cfg.LLM.ContextCacheTTL,
+		Logf:            logf,
+	}
+	for _, k := range keys {
+		opts.Keys = append(opts.Keys, analyzer.APIKey(k))
+	}
+	return opts
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/kerneldump/git-dual-context/pkg/analyzer"
+)
+
+// Log formats of -log-format
+const (
+	logFormatJSON = "json"
+	logFormatText = "text"
+)
+
+// newLogger returns the logger of a run, writing records at level and
+// above to w. The json format writes one "log" record per line, shaped
+// like analyzer.LogEntry; text writes slog's key=value lines.
+func newLogger(w io.Writer, format string, level slog.Level) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: level}
+	switch format {
+	case logFormatText:
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case logFormatJSON:
+		opts.ReplaceAttr = func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.String("timestamp", a.Value.Time().UTC().Format(time.RFC3339))
+			}
+			return a
+		}
+		return slog.New(slog.NewJSONHandler(w, opts)).With("type", analyzer.RecordLog, "schema_version", analyzer.SchemaVersion), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q (want json or text)", format)
+	}
+}
+
+// parseLogLevel parses a -log-level value: debug, info, warn, or error
+func parseLogLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("unknown log level %q (want debug, info, warn, or error)", s)
+	}
+	return level, nil
+}
+
+// logEntry logs a commit's log entry, with its commit, error kind, and
+// debug file as attributes
+func logEntry(logger *slog.Logger, entry analyzer.LogEntry) {
+	level, err := parseLogLevel(entry.Level)
+	if err != nil {
+		level = slog.LevelInfo
+	}
+	var attrs []any
+	if entry.Commit != "" {
+		attrs = append(attrs, "commit", entry.Commit)
+	}
+	if entry.ErrorKind != "" {
+		attrs = append(attrs, "error_kind", entry.ErrorKind)
+	}
+	if entry.Skip != nil {
+		attrs = append(attrs, "skip_reason", string(entry.Skip.Reason))
+		if len(entry.Skip.IgnoredFiles) > 0 {
+			attrs = append(attrs, "ignored_files", entry.Skip.IgnoredFiles)
+		}
+	}
+	if entry.DebugFile != "" {
+		attrs = append(attrs, "debug_file", entry.DebugFile)
+	}
+	logger.Log(context.Background(), level, entry.Msg, attrs...)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/kerneldump/git-dual-context/pkg/analyzer"
+)
+
+func TestNewLoggerJSONMatchesLogEntry(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := newLogger(&buf, logFormatJSON, slog.LevelInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logEntry(logger, analyzer.NewErrorEntry("Failed to analyze commit abc", "abc", errors.New("boom"), ""))
+
+	var entry analyzer.LogEntry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("invalid log record %q: %v", buf.String(), err)
+	}
+	if entry.Type != analyzer.RecordLog || entry.SchemaVersion != analyzer.SchemaVersion || entry.Level != "ERROR" {
+		t.Errorf("unexpected record: %+v", entry)
+	}
+	if entry.Msg != "Failed to analyze commit abc" || entry.Commit != "abc" || entry.ErrorKind != analyzer.ErrorKindOther {
+		t.Errorf("unexpected record: %+v", entry)
+	}
+	if entry.Timestamp == "" {
+		t.Error("expected a timestamp")
+	}
+}
+
+func TestNewLoggerLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := newLogger(&buf, logFormatText, slog.LevelWarn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("hidden")
+	logger.Warn("shown")
+	if out := buf.String(); strings.Contains(out, "hidden") || !strings.Contains(out, "msg=shown") {
+		t.Errorf("unexpected output: %q", out)
+	}
+}
+
+func TestNewLoggerRejectsUnknownFormat(t *testing.T) {
+	if _, err := newLogger(&bytes.Buffer{}, "xml", slog.LevelInfo); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+	if _, err := parseLogLevel("trace"); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+	if level, err := parseLogLevel("WARN"); err != nil || level != slog.LevelWarn {
+		t.Errorf("parseLogLevel(WARN) = %v, %v", level, err)
+	}
+}
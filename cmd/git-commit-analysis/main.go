@@ -1,44 +1,64 @@
 package main
 
 import (
+	"cmp"
 	"context"
-	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/kerneldump/git-dual-context/pkg/analyzer"
+	"github.com/kerneldump/git-dual-context/pkg/audit"
+	"github.com/kerneldump/git-dual-context/pkg/batch"
+	"github.com/kerneldump/git-dual-context/pkg/bundle"
+	"github.com/kerneldump/git-dual-context/pkg/ci"
 	"github.com/kerneldump/git-dual-context/pkg/config"
+	"github.com/kerneldump/git-dual-context/pkg/gitdiff"
+	"github.com/kerneldump/git-dual-context/pkg/history"
+	"github.com/kerneldump/git-dual-context/pkg/issues"
+	"github.com/kerneldump/git-dual-context/pkg/network"
+	"github.com/kerneldump/git-dual-context/pkg/output"
 	"github.com/kerneldump/git-dual-context/pkg/validator"
 
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
-	"github.com/google/generative-ai-go/genai"
-	"google.golang.org/api/option"
 )
 
+// errInterrupted is the error of commits an interrupt kept from starting
+var errInterrupted = errors.New("interrupted before analysis")
+
 // commitResult holds the analysis result for ordered streaming output
 type commitResult struct {
-	index  int
-	result *analyzer.AnalysisResult
-	err    error
-	commit *object.Commit
+	index   int
+	result  *analyzer.AnalysisResult
+	err     error
+	retries *analyzer.RetryStats
+	commit  *object.Commit
 }
 
 // orderedPrinter handles streaming results in commit order
 type orderedPrinter struct {
-	encoder     *json.Encoder
+	sink        output.Sink
+	logger      *slog.Logger
 	mu          sync.Mutex
 	results     map[int]*commitResult // buffered results waiting to print
 	nextToPrint int                   // next index we're waiting to print
 	total       int                   // total number of commits
+	repo        string                // repository labeling results (multi-repo runs)
+	debugDir    string                // where unparsable responses are saved (optional)
 
 	// Summary counters
 	high    int
@@ -47,13 +67,35 @@ type orderedPrinter struct {
 	skipped int
 	errors  int
 
+	// Errors by analyzer.ErrorKind
+	errorKinds map[string]int
+
+	// Commits skipped for their changes, by analyzer.SkipReason
+	skipReasons map[string]int
+
+	// Skipped commits the run's deadline left no time for
+	overBudget int
+
+	// Commits left without a verdict, for the checkpoint of an
+	// interrupted run, and how many of them an interrupt kept from
+	// starting
+	remaining   []string
+	interrupted int
+
 	// Error tracking
 	encodeErrors int
+
+	// Verdicts recorded for the history database
+	verdicts []history.Verdict
+
+	// Results ranked in the summary
+	ranked []analyzer.CommitAnalysisResult
 }
 
-func newOrderedPrinter(encoder *json.Encoder, total int) *orderedPrinter {
+func newOrderedPrinter(sink output.Sink, logger *slog.Logger, total int) *orderedPrinter {
 	return &orderedPrinter{
-		encoder:     encoder,
+		sink:        sink,
+		logger:      logger,
 		results:     make(map[int]*commitResult),
 		nextToPrint: 0,
 		total:       total,
@@ -83,24 +125,48 @@ func (p *orderedPrinter) submit(r *commitResult) {
 
 // printResult outputs a single result and updates counters
 func (p *orderedPrinter) printResult(r *commitResult) {
+	if errors.Is(r.err, analyzer.ErrBudgetExhausted) {
+		p.logger.Warn(fmt.Sprintf("Commit: %s%s | [Skipped - Run deadline reached]", p.repoPrefix(), r.commit.Hash.String()[:8]))
+		p.skipped++
+		p.overBudget++
+		skipped := &analyzer.AnalysisResult{Skipped: true, Skip: &analyzer.SkipInfo{Reason: analyzer.SkipDeadline}}
+		p.write(skipped.ToJSONResult(r.commit.Hash.String()[:8], r.commit.Message))
+		return
+	}
+	if errors.Is(r.err, errInterrupted) {
+		p.remaining = append(p.remaining, r.commit.Hash.String())
+		p.interrupted++
+		return
+	}
 	if r.err != nil {
-		if err := p.encoder.Encode(analyzer.NewLogEntry("ERROR", fmt.Sprintf("Failed to analyze commit %s: %v", r.commit.Hash.String(), r.err))); err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to encode error log: %v\n", err)
+		p.remaining = append(p.remaining, r.commit.Hash.String())
+		entry := analyzer.NewErrorEntry(fmt.Sprintf("Failed to analyze commit %s%s: %v", p.repoPrefix(), r.commit.Hash.String(), r.err), r.commit.Hash.String(), r.err, p.debugDir)
+		logEntry(p.logger, entry)
+		if err := p.sink.WriteLog(entry); err != nil {
+			p.logger.Error("Failed to write error log", "error", err)
 			p.encodeErrors++
 		}
-		p.errors++
+		p.countError(entry.ErrorKind)
+		jr := analyzer.NewErrorResult(r.commit.Hash.String()[:8], r.commit.Message, r.err)
+		jr.Retries = r.retries
+		p.write(jr)
 		return
 	}
 	if r.result == nil {
-		p.errors++
+		p.countError(analyzer.ErrorKindOther)
+		p.write(analyzer.NewErrorResult(r.commit.Hash.String()[:8], r.commit.Message, errors.New("no result")))
 		return
 	}
 	if r.result.Skipped {
-		if err := p.encoder.Encode(analyzer.NewLogEntry("INFO", fmt.Sprintf("Commit: %s | [Skipped - No relevant code changes]", r.commit.Hash.String()[:8]))); err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to encode skip log: %v\n", err)
-			p.encodeErrors++
-		}
+		logEntry(p.logger, analyzer.NewSkipEntry(p.repoPrefix()+r.commit.Hash.String()[:8], r.commit.Hash.String(), r.result.Skip))
 		p.skipped++
+		if r.result.Skip != nil {
+			if p.skipReasons == nil {
+				p.skipReasons = map[string]int{}
+			}
+			p.skipReasons[string(r.result.Skip.Reason)]++
+		}
+		p.write(r.result.ToJSONResult(r.commit.Hash.String()[:8], r.commit.Message))
 		return
 	}
 
@@ -114,10 +180,26 @@ func (p *orderedPrinter) printResult(r *commitResult) {
 		p.low++
 	}
 
+	p.verdicts = append(p.verdicts, history.Verdict{
+		Commit:       r.commit.Hash.String(),
+		Message:      analyzer.TruncateCommitMessage(r.commit.Message, analyzer.DefaultCommitMessageMaxLength),
+		Probability:  string(r.result.Probability),
+		Reasoning:    r.result.Reasoning,
+		PromptTokens: int(r.result.PromptTokens),
+		OutputTokens: int(r.result.OutputTokens),
+	})
+
+	p.ranked = append(p.ranked, analyzer.CommitAnalysisResult{Repo: p.repo, Hash: r.commit.Hash.String(), Result: r.result})
+
 	// Encode and print as JSON with commit message
-	jr := r.result.ToJSONResult(r.commit.Hash.String()[:8], r.commit.Message)
-	if err := p.encoder.Encode(jr); err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to encode result: %v\n", err)
+	p.write(r.result.ToJSONResult(r.commit.Hash.String()[:8], r.commit.Message))
+}
+
+// write sends the result record of a commit of p.repo to the sink
+func (p *orderedPrinter) write(jr analyzer.JSONResult) {
+	jr.Repo = p.repo
+	if err := p.sink.Write(jr); err != nil {
+		p.logger.Error("Failed to write result", "error", err)
 		p.encodeErrors++
 	}
 }
@@ -128,7 +210,7 @@ func (p *orderedPrinter) summary(duration time.Duration, modelName string) analy
 	defer p.mu.Unlock()
 
 	return analyzer.Summary{
-		Type:     "summary",
+		Type:     analyzer.RecordSummary,
 		Total:    p.total,
 		High:     p.high,
 		Medium:   p.medium,
@@ -137,268 +219,1061 @@ func (p *orderedPrinter) summary(duration time.Duration, modelName string) analy
 		Errors:   p.errors,
 		Duration: duration.String(),
 		Model:    modelName,
+		Ranking:  analyzer.Ranking(p.ranked),
+
+		OverBudget:    p.overBudget,
+		ErrorKinds:    maps.Clone(p.errorKinds),
+		SkipReasons:   maps.Clone(p.skipReasons),
+		SchemaVersion: analyzer.SchemaVersion,
+	}
+}
+
+// countError counts a failed commit with an error of kind
+func (p *orderedPrinter) countError(kind string) {
+	p.errors++
+	if p.errorKinds == nil {
+		p.errorKinds = map[string]int{}
 	}
+	p.errorKinds[kind]++
 }
 
-// Global temp directory for cleanup on fatal exit
-var tempDir string
+// checkpointRepo returns the verdicts and remaining commits of repo so
+// far
+func (p *orderedPrinter) checkpointRepo(repo string) checkpointRepo {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return checkpointRepo{Repo: repo, Verdicts: slices.Clone(p.verdicts), Remaining: slices.Clone(p.remaining)}
+}
+
+// repoPrefix returns "<repo>@" to qualify commit hashes in multi-repo runs
+func (p *orderedPrinter) repoPrefix() string {
+	if p.repo == "" {
+		return ""
+	}
+	return p.repo + "@"
+}
+
+// mergeSummaries combines the summaries of the repositories of a
+// multi-repo run, ranking suspects across all of them
+func mergeSummaries(printers []*orderedPrinter, duration time.Duration, modelName string) analyzer.Summary {
+	merged := analyzer.Summary{Type: analyzer.RecordSummary, SchemaVersion: analyzer.SchemaVersion, Duration: duration.String(), Model: modelName}
+	var ranked []analyzer.CommitAnalysisResult
+	for _, p := range printers {
+		s := p.summary(duration, modelName)
+		merged.Total += s.Total
+		merged.High += s.High
+		merged.Medium += s.Medium
+		merged.Low += s.Low
+		merged.Skipped += s.Skipped
+		merged.Errors += s.Errors
+		merged.OverBudget += s.OverBudget
+		for kind, n := range s.ErrorKinds {
+			if merged.ErrorKinds == nil {
+				merged.ErrorKinds = map[string]int{}
+			}
+			merged.ErrorKinds[kind] += n
+		}
+		for reason, n := range s.SkipReasons {
+			if merged.SkipReasons == nil {
+				merged.SkipReasons = map[string]int{}
+			}
+			merged.SkipReasons[reason] += n
+		}
+
+		p.mu.Lock()
+		ranked = append(ranked, p.ranked...)
+		p.mu.Unlock()
+	}
+	merged.Ranking = analyzer.Ranking(ranked)
+	return merged
+}
+
+// readRepoManifest reads a -repos-file: one repository path or URL per
+// line, with blank lines and lines starting with # ignored
+func readRepoManifest(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var repos []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		repos = append(repos, line)
+	}
+	return repos, nil
+}
+
+// repoTarget is one repository analyzed in a run
+type repoTarget struct {
+	path       string // -repo value
+	id         string // key in the history database
+	r          *git.Repository
+	start      *object.Commit // where history is walked from (-branch)
+	headCommit *object.Commit // compared against for the macro context (-head-ref)
+	diffOpts   gitdiff.Options
+	commits    []*object.Commit
+	printer    *orderedPrinter
+}
+
+// formatScoreWeights renders configured score weights as a -score-weights
+// value
+func formatScoreWeights(w config.ScoreWeights) string {
+	return fmt.Sprintf("llm=%g,heuristics=%g,recency=%g", w.LLM, w.Heuristics, w.Recency)
+}
+
+// listFlag is a repeatable flag collecting comma-separated values
+type listFlag []string
+
+func (l *listFlag) String() string { return strings.Join(*l, ",") }
+
+func (l *listFlag) Set(s string) error {
+	*l = append(*l, splitList(s)...)
+	return nil
+}
+
+// specFlag is a repeatable flag collecting whole values, for values such
+// as URLs that may contain commas
+type specFlag []string
+
+func (l *specFlag) String() string { return strings.Join(*l, " ") }
+
+func (l *specFlag) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// flagValues returns the values given for the named flag in args. The
+// config profile and repository must be known before the other flags are
+// defined, since the config supplies their defaults.
+func flagValues(args []string, flagName string) []string {
+	var values []string
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != flagName {
+			continue
+		}
+		if hasValue {
+			values = append(values, value)
+		} else if i+1 < len(args) {
+			values = append(values, args[i+1])
+		}
+	}
+	return values
+}
+
+// profileArg returns the value of the -config-profile flag in args
+func profileArg(args []string) string {
+	values := flagValues(args, "config-profile")
+	if len(values) == 0 {
+		return ""
+	}
+	return values[len(values)-1]
+}
+
+// repoConfigDir returns the local repository whose config file applies to
+// an analysis run with args: the single -repo given, or the current
+// directory without one. Several repositories, a -repos-file, a remote
+// URL, or a subcommand select none.
+func repoConfigDir(args []string) string {
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		return ""
+	}
+	if len(flagValues(args, "repos-file")) > 0 {
+		return ""
+	}
+	repos := flagValues(args, "repo")
+	switch {
+	case len(repos) == 0:
+		return "."
+	case len(repos) == 1 && !analyzer.IsRemoteURL(repos[0]):
+		return repos[0]
+	}
+	return ""
+}
+
+// Global temp directories for cleanup on fatal exit
+var tempDirs []string
 
 func main() {
+	// A run that could not analyze every repository exits 1, once the
+	// deferred cleanups have run
+	var failed atomic.Bool
+	defer func() {
+		if failed.Load() {
+			os.Exit(1)
+		}
+	}()
+
 	// Set up signal handling for graceful shutdown
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
-	// Load config file (uses defaults if not found)
-	cfg, _ := config.LoadConfig(config.FindConfigFile())
+	// Load config file (uses defaults if not found), the analyzed
+	// repository's config file, and the selected profile; GDC_*
+	// environment variables override them, and flags override all four
+	profile := cmp.Or(profileArg(os.Args[1:]), os.Getenv(config.ProfileEnv))
+	repoConfig := ""
+	if dir := repoConfigDir(os.Args[1:]); dir != "" {
+		repoConfig = config.FindRepoConfigFile(dir)
+	}
+	configPath := config.FindConfigFile()
+	cfg, ignoredSections, err := config.Load(configPath, repoConfig, profile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config: %v\n", err)
+		os.Exit(1)
+	}
 
-	// Env var overrides config (but flag overrides both)
-	if envModel := os.Getenv("GEMINI_MODEL"); envModel != "" {
-		cfg.LLM.Model = envModel
+	// Subcommands
+	if len(os.Args) > 1 {
+		netOpts := network.Options{Proxy: cfg.Network.Proxy, CABundle: cfg.Network.CABundle}
+		var run func() error
+		switch os.Args[1] {
+		case "serve":
+			run = func() error { return runServe(ctx, cfg, configPath, profile, os.Args[2:]) }
+		case "history":
+			run = func() error { return runHistory(cfg, os.Args[2:]) }
+		case "models":
+			run = func() error { return runModels(ctx, cfg, os.Args[2:], os.Stdout) }
+		case "doctor":
+			run = func() error { return runDoctor(ctx, cfg, os.Args[2:], os.Stdout) }
+		case "schema":
+			run = func() error { return runSchema(os.Args[2:], os.Stdout) }
+		}
+		if run != nil {
+			if err := network.Configure(netOpts); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[1], err)
+				os.Exit(1)
+			}
+			if err := run(); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[1], err)
+				os.Exit(1)
+			}
+			return
+		}
 	}
 
 	// Parse flags with defaults from config
-	repoPath := flag.String("repo", ".", "Path to the git repository or remote URL")
-	branch := flag.String("branch", "", "Branch to analyze (default: current HEAD)")
+	var repoPaths listFlag
+	flag.Var(&repoPaths, "repo", "Path to the git repository or remote URL (repeatable to analyze several; default: .)")
+	reposFile := flag.String("repos-file", "", "File listing repositories to analyze, one path or URL per line")
+	branch := flag.String("branch", "", "Branch, tag, remote-tracking ref, or commit whose history is analyzed (default: current HEAD)")
+	cloneDepth := flag.Int("clone-depth", cfg.Clone.Depth, "Commits of history to fetch when cloning a remote -repo (0: all)")
+	singleBranch := flag.Bool("single-branch", cfg.Clone.SingleBranch, "Fetch only -branch (or the remote's default branch) when cloning a remote -repo")
+	gitUser := flag.String("git-user", cfg.Clone.Username, "Username for HTTPS remotes (token or password from GIT_TOKEN or GIT_PASSWORD)")
+	cloneCache := flag.String("clone-cache", cfg.Clone.CacheDir, "Keep clones of remote -repo URLs in this directory between runs, fetching instead of re-cloning")
+	proxy := flag.String("proxy", cfg.Network.Proxy, "Proxy URL for git remotes and the LLM API (default: HTTPS_PROXY/HTTP_PROXY/NO_PROXY)")
+	caBundle := flag.String("ca-bundle", cfg.Network.CABundle, "PEM file of certificate authorities to trust in addition to the system roots")
+	sshKey := flag.String("ssh-key", cfg.Clone.SSHKey, "Private key file for SSH remotes (default: SSH agent; passphrase from GIT_SSH_KEY_PASSPHRASE)")
+	headRef := flag.String("head-ref", "", "Ref to compare commits against for the macro context (default: -branch)")
 	errorMsg := flag.String("error", "", "The error message or bug description to analyze")
 	numCommits := flag.Int("n", cfg.Analysis.DefaultCommits, "Number of commits to analyze")
 	numWorkers := flag.Int("j", cfg.Performance.Workers, "Number of concurrent workers")
+	objectCacheMB := flag.Int("object-cache-mb", cfg.Performance.ObjectCacheMB, "Megabytes of decompressed git objects cached for the workers extracting diffs")
 	modelName := flag.String("model", cfg.LLM.Model, "Gemini model to use")
 	timeout := flag.Duration("timeout", cfg.LLM.Timeout, "Timeout per commit analysis")
-	outputFile := flag.String("o", "", "Output file path (default: stdout)")
+	runTimeout := flag.Duration("run-timeout", cfg.Performance.RunTimeout, "Time the whole run may take; commits left without time are skipped (0: no limit)")
+	var outputs specFlag
+	flag.Var(&outputs, "o", "Write output to a file (.md: Markdown report, .sarif: SARIF, otherwise NDJSON), an http(s) webhook URL, or - for stdout; prefix with ndjson:, markdown:, sarif:, or webhook: to pick the format (repeatable; default: NDJSON on stdout)")
 	apiKey := flag.String("apikey", "", "Google Gemini API Key (prefer GEMINI_API_KEY env var)")
-	verbose := flag.Bool("v", cfg.Output.Verbose, "Verbose output (show additional debug info)")
+	verbose := flag.Bool("v", cfg.Output.Verbose, "Verbose output (show additional debug info; same as -log-level debug)")
+	logFormat := flag.String("log-format", cfg.Output.LogFormat, "Format of the logs written to stderr: json or text")
+	logLevel := flag.String("log-level", cfg.Output.LogLevel, "Least severe level of the logs written to stderr: debug, info, warn, or error")
+	noHistory := flag.Bool("no-history", !cfg.History.Enabled, "Do not record this run in the history database")
+	offline := flag.Bool("offline", cfg.LLM.Provider == config.ProviderHeuristic, "Rate commits with heuristics (stack trace paths, error keywords, churn, recency) instead of an LLM; no API key needed")
+	reuse := flag.Bool("reuse", false, "Reuse stored verdicts for commits already analyzed for the same error and model")
+	include := flag.String("include", "", "Comma-separated glob patterns; only matching files are analyzed (adds to analysis.include_files)")
+	exclude := flag.String("exclude", "", "Comma-separated glob patterns of files to skip (adds to analysis.file_filters)")
+	flag.String("config-profile", "", "Config file profile to apply, such as a quick incident or a thorough nightly scan (default: GDC_PROFILE)")
+	profiles := flag.String("profile", "", "Comma-separated filter profiles: go, node, python, jvm, monorepo, or auto to detect (adds to analysis.filter_profiles)")
+	var only listFlag
+	flag.Var(&only, "only", "Glob pattern restricting the files diffed and the commits considered, e.g. 'pkg/auth/**' (repeatable)")
+	includeTests := flag.Bool("include-tests", cfg.Analysis.IncludeTests, "Analyze test files too (for failing or flaky tests)")
+	contextLines := flag.Int("context-lines", cfg.Analysis.ContextLines, "Unchanged lines shown around each change (0: whole files)")
+	maxDiffTokens := flag.Int("max-diff-tokens", cfg.Analysis.MaxDiffTokens, "Token budget for each diff; large files are truncated proportionally")
+	dropIrrelevantHunks := flag.Bool("drop-irrelevant-hunks", cfg.Analysis.DropIrrelevantHunks, "Drop hunks sharing no identifiers with the error from diffs over the token budget")
+	maxChunks := flag.Int("max-chunks", cfg.Analysis.MaxChunks, "Split commits over the token budget into up to this many LLM calls (1: truncate instead)")
+	maxDiffSize := flag.Int("max-diff-size", cfg.Analysis.MaxDiffSize, "Truncate each standard diff (commit vs parent) to this many characters")
+	maxFullDiffSize := flag.Int("max-full-diff-size", cfg.Analysis.MaxFullDiffSize, "Truncate each full diff (commit vs HEAD) to this many characters")
+	maxPromptDiffSize := flag.Int("max-prompt-diff-size", cfg.Analysis.MaxPromptDiffSize, "Truncate both diffs of one LLM call to this many characters together, the full diff first (0: no combined limit)")
+	minChangedLines := flag.Int("min-changed-lines", cfg.Analysis.MinChangedLines, "Skip commits changing fewer lines of analyzed files, without an LLM call")
+	analyzeNonFunctional := flag.Bool("analyze-non-functional", cfg.Analysis.AnalyzeNonFunctional, "Send whitespace-, comment-, and import-order-only commits to the LLM instead of rating them LOW")
+	semanticDiff := flag.Bool("semantic-diff", cfg.Analysis.SemanticDiff, "List changed functions and types instead of changed lines for Go files (other languages keep line diffs)")
+	fullFileMaxBytes := flag.Int("full-file-max-bytes", cfg.Analysis.FullFileMaxBytes, "Send files up to this size whole despite -context-lines, -function-context, or -semantic-diff (0: never)")
+	diffBackend := flag.String("diff-backend", cfg.Analysis.DiffBackend, "Compute diffs with go-git, the system git binary (git), or git when available (auto)")
+	blameEvolution := flag.Bool("blame-evolution", cfg.Analysis.BlameEvolution, "Note on each changed line of the evolution diff the commit that last touched it (slow on long histories)")
+	deepen := flag.Bool("deepen", cfg.Analysis.DeepenShallow, "Fetch missing history from origin when a shallow clone is too short for -n commits")
+	stream := flag.Bool("stream", cfg.LLM.Stream, "Stream responses and stop generating once the verdict JSON is complete")
+	batchMode := flag.Bool("batch", cfg.LLM.Batch, "Submit all prompts through the Gemini Batch API at half the price; results may take up to a day (-timeout defaults to llm.batch_timeout)")
+	contextCache := flag.Bool("context-cache", cfg.LLM.ContextCache, "Cache the instructions, error, and HEAD-side code every commit's prompt shares in Gemini's context cache")
+	dedupe := flag.Bool("dedupe", cfg.Analysis.DedupePatches, "Analyze commits with identical patches (such as cherry-picks) once and reuse the verdict")
+	reasoning := flag.String("reasoning", cfg.Analysis.Reasoning, "Length of each verdict's reasoning: short (one or two sentences, cut to them), standard, or verbose (the whole chain of reasoning)")
+	scoreWeights := flag.String("score-weights", formatScoreWeights(cfg.Analysis.ScoreWeights), "Weights of the LLM verdict, heuristics, and recency in each result's suspicion score")
+	hotspotHistory := flag.Int("hotspot-history", cfg.Analysis.HotspotHistory, "Rank equally rated commits by the churn and bug-fix history of their files over this many commits (0: off)")
+	suggestOwners := flag.Bool("owners", cfg.Analysis.SuggestOwners, "Suggest who to ask about HIGH and MEDIUM commits from CODEOWNERS, or blame for files without owners")
+	affectedTests := flag.Bool("affected-tests", cfg.Analysis.AffectedTests, "List the test files to run to confirm HIGH and MEDIUM commits, by naming convention and Go imports")
+	relatedFiles := flag.Int("related-files", cfg.Analysis.RelatedFiles, "Add the evolution of up to this many files each commit did not change, but that change with or import its files, to the full diff (0: off)")
+	goCallers := flag.Bool("go-callers", cfg.Analysis.GoCallers, "List where the Go functions each commit changed are called at HEAD in its prompt (type-checks HEAD with the go command)")
+	verifyCmd := flag.String("verify-cmd", "", "Run this shell command, such as a test, in checkouts of the most suspicious commits and their parents, raising confirmed verdicts to HIGH and refuted ones to LOW")
+	verifyTop := flag.Int("verify-top", cfg.Analysis.VerifyTop, "Run -verify-cmd at this many of the most suspicious HIGH and MEDIUM commits")
+	verifyTimeout := flag.Duration("verify-timeout", cfg.Analysis.VerifyTimeout, "Time limit of each run of -verify-cmd")
+	techStack := flag.Bool("tech-stack", cfg.Analysis.TechStack, "Name the repository's dominant languages and frameworks in the prompt")
+	ciStatus := flag.Bool("ci-status", cfg.CI.Enabled, "Look up whether each commit passed its GitHub checks or GitLab pipeline (needs issues.github_token_ref or ci.gitlab_token_ref)")
+	functionContext := flag.Bool("function-context", cfg.Analysis.FunctionContext, "Expand each change to its enclosing function, like git diff -W")
+	exportBundle := flag.String("export-bundle", "", "Write diffs, prompts, raw LLM responses, and config for this run to a zip file")
+	checkpointPath := flag.String("checkpoint", ".git-dual-context-checkpoint.json", "File an interrupted run writes its verdicts so far to, for -resume")
+	resume := flag.Bool("resume", false, "Reuse the verdicts of the interrupted run recorded in -checkpoint and analyze only the remaining commits")
+	importBundle := flag.String("import-bundle", "", "Re-render the report stored in a bundle offline (no repository or API key needed)")
+	debugDir := flag.String("debug-dir", cfg.Output.DebugDir, "Save the prompt and raw response of LLM responses that cannot be parsed to this directory (empty: off)")
+	auditPath := flag.String("audit-log", "", "Append every LLM prompt and response hash to this JSONL file (default: audit.path when audit.enabled)")
 	flag.Parse()
 
-	// Set up output writer
-	var output io.Writer = os.Stdout
-	if *outputFile != "" {
-		f, err := os.Create(*outputFile)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, `{"type":"log","level":"ERROR","msg":"Failed to create output file: %s"}`, err.Error())
-			os.Exit(1)
+	// Batches may take up to a day, unlike interactive calls
+	if *batchMode {
+		explicit := false
+		flag.Visit(func(f *flag.Flag) { explicit = explicit || f.Name == "timeout" })
+		if !explicit {
+			*timeout = cfg.LLM.BatchTimeout
+		}
+	}
+
+	// Set up logging; logs go to stderr, leaving stdout to the results
+	if *verbose {
+		explicit := false
+		flag.Visit(func(f *flag.Flag) { explicit = explicit || f.Name == "log-level" })
+		if !explicit {
+			*logLevel = "debug"
 		}
-		defer f.Close()
-		output = f
+	}
+	level, err := parseLogLevel(*logLevel)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid -log-level: %v\n", err)
+		os.Exit(1)
+	}
+	logger, err := newLogger(os.Stderr, *logFormat, level)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid -log-format: %v\n", err)
+		os.Exit(1)
 	}
 
-	encoder := json.NewEncoder(output)
-	var logMutex sync.Mutex
+	if repoConfig != "" && len(ignoredSections) > 0 {
+		logger.Warn(fmt.Sprintf("Repository config %s may only set analysis settings; ignoring %s", repoConfig, strings.Join(ignoredSections, ", ")))
+	}
+	if profile != "" {
+		logger.Info("Using config profile " + profile)
+	}
 
-	logJSON := func(level, msg string) {
-		logMutex.Lock()
-		defer logMutex.Unlock()
-		if err := encoder.Encode(analyzer.NewLogEntry(level, msg)); err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to encode log entry: %v\n", err)
+	// Set up output sinks
+	if len(outputs) == 0 {
+		outputs = specFlag{"-"}
+		if cfg.Output.Format == "markdown" {
+			outputs = specFlag{output.FormatMarkdown + ":-"}
+		}
+	}
+	var sinks []output.Sink
+	for _, spec := range outputs {
+		s, err := output.Open(spec, os.Stdout)
+		if err != nil {
+			logger.Error(fmt.Sprintf("Failed to open output %q: %v", spec, err))
+			os.Exit(1)
+		}
+		sinks = append(sinks, s)
+	}
+	sink := output.Multi(sinks...)
+	closeSink := func() {
+		if err := sink.Close(); err != nil {
+			logger.Error("Failed to write output", "error", err)
 		}
 	}
+	defer closeSink()
 
-	fatalJSON := func(msg string) {
-		logJSON("ERROR", msg)
-		// Clean up temp directory on fatal exit
-		if tempDir != "" {
-			os.RemoveAll(tempDir)
+	fatal := func(msg string) {
+		logger.Error(msg)
+		closeSink()
+		// Clean up temp directories on fatal exit
+		for _, dir := range tempDirs {
+			os.RemoveAll(dir)
 		}
 		os.Exit(1)
 	}
 
+	// Replay a recorded run instead of analyzing
+	if *importBundle != "" {
+		if err := replayBundle(*importBundle, sink, logger); err != nil {
+			fatal(fmt.Sprintf("Failed to replay bundle: %v", err))
+		}
+		return
+	}
+
 	// Validate inputs
 	if err := validator.ValidateErrorMessage(*errorMsg); err != nil {
-		fatalJSON(fmt.Sprintf("Invalid error message: %v", err))
+		fatal(fmt.Sprintf("Invalid error message: %v", err))
 	}
 
 	if err := validator.ValidateNumCommits(*numCommits); err != nil {
-		fatalJSON(fmt.Sprintf("Invalid number of commits: %v", err))
+		fatal(fmt.Sprintf("Invalid number of commits: %v", err))
 	}
 
 	if err := validator.ValidateNumWorkers(*numWorkers); err != nil {
-		fatalJSON(fmt.Sprintf("Invalid number of workers: %v", err))
+		fatal(fmt.Sprintf("Invalid number of workers: %v", err))
 	}
+	if *objectCacheMB < 0 {
+		fatal(fmt.Sprintf("Invalid object cache size: %d MB", *objectCacheMB))
+	}
+	if *runTimeout < 0 {
+		fatal(fmt.Sprintf("Invalid run timeout: %v cannot be negative", *runTimeout))
+	}
+	// The deadline covers cloning and diffing as well as the LLM calls
+	budget := analyzer.NewBudget(*runTimeout)
 
-	if err := validator.ValidateBranchName(*branch); err != nil {
-		fatalJSON(fmt.Sprintf("Invalid branch name: %v", err))
+	if err := validator.ValidateRef(*branch); err != nil {
+		fatal(fmt.Sprintf("Invalid branch name: %v", err))
+	}
+	if err := validator.ValidateRef(*headRef); err != nil {
+		fatal(fmt.Sprintf("Invalid head ref: %v", err))
 	}
 
-	if err := validator.ValidateRepoPath(*repoPath); err != nil {
-		fatalJSON(fmt.Sprintf("Invalid repository path: %v", err))
+	if *reposFile != "" {
+		listed, err := readRepoManifest(*reposFile)
+		if err != nil {
+			fatal(fmt.Sprintf("Failed to read repos file: %v", err))
+		}
+		repoPaths = append(repoPaths, listed...)
+	}
+	if len(repoPaths) == 0 {
+		repoPaths = listFlag{"."}
 	}
+	for _, path := range repoPaths {
+		if err := validator.ValidateRepoPath(path); err != nil {
+			fatal(fmt.Sprintf("Invalid repository path: %v", err))
+		}
+		if err := validator.ValidateRepoHost(path, cfg.Clone.AllowedHosts, cfg.Clone.DeniedHosts); err != nil {
+			fatal(fmt.Sprintf("Invalid repository path: %v", err))
+		}
+	}
+	multiRepo := len(repoPaths) > 1
 
-	key := *apiKey
-	if key != "" {
-		logJSON("WARN", "API key passed via command line may be visible in process list. Consider using GEMINI_API_KEY environment variable instead.")
-	} else {
-		key = os.Getenv("GEMINI_API_KEY")
+	weights, err := analyzer.ParseScoreWeights(*scoreWeights)
+	if err != nil {
+		fatal(fmt.Sprintf("Invalid score weights: %v", err))
 	}
-	if key == "" {
-		fatalJSON("Error: No API key provided. Please use -apikey flag or set GEMINI_API_KEY environment variable.")
+	verbosity, err := analyzer.ParseVerbosity(*reasoning)
+	if err != nil {
+		fatal(fmt.Sprintf("Invalid reasoning verbosity: %v", err))
 	}
+	retry := analyzer.RetryConfig(cfg.Retry())
 
-	// Initialize Git
-	var r *git.Repository
-	var err error
+	fileFilter, err := gitdiff.NewFilter(
+		append(cfg.Analysis.IncludeFiles, splitList(*include)...),
+		append(cfg.Analysis.FileFilters, splitList(*exclude)...),
+	)
+	if err != nil {
+		fatal(fmt.Sprintf("Invalid file filter: %v", err))
+	}
+	fileFilter.IncludeTests = *includeTests
+	if err := fileFilter.SetOnly(only); err != nil {
+		fatal(fmt.Sprintf("Invalid file filter: %v", err))
+	}
 
-	// Check if it's a remote URL
-	if strings.HasPrefix(*repoPath, "http") || strings.HasPrefix(*repoPath, "git@") {
-		// Create temp dir
-		tempDir, err = os.MkdirTemp("", "git-analysis-*")
-		if err != nil {
-			fatalJSON(err.Error())
+	if *contextLines < 0 {
+		fatal(fmt.Sprintf("Invalid context lines: %d cannot be negative", *contextLines))
+	}
+	if *maxDiffTokens <= 0 {
+		fatal(fmt.Sprintf("Invalid max diff tokens: %d must be positive", *maxDiffTokens))
+	}
+	if *maxChunks <= 0 {
+		fatal(fmt.Sprintf("Invalid max chunks: %d must be positive", *maxChunks))
+	}
+	if *maxDiffSize <= 0 || *maxFullDiffSize <= 0 {
+		fatal(fmt.Sprintf("Invalid max diff sizes: %d and %d must be positive", *maxDiffSize, *maxFullDiffSize))
+	}
+	if *maxPromptDiffSize < 0 {
+		fatal(fmt.Sprintf("Invalid max prompt diff size: %d cannot be negative", *maxPromptDiffSize))
+	}
+	if *fullFileMaxBytes < 0 {
+		fatal(fmt.Sprintf("Invalid full file max bytes: %d cannot be negative", *fullFileMaxBytes))
+	}
+	if *minChangedLines < 0 {
+		fatal(fmt.Sprintf("Invalid min changed lines: %d cannot be negative", *minChangedLines))
+	}
+	if *verifyCmd != "" && (*verifyTop <= 0 || *verifyTimeout <= 0) {
+		fatal(fmt.Sprintf("Invalid verification: -verify-top %d and -verify-timeout %v must be positive", *verifyTop, *verifyTimeout))
+	}
+	// Filter profiles are resolved by the pipeline against each
+	// repository's HEAD, which "auto" inspects; unknown names fail here
+	filterProfiles := append(slices.Clone(cfg.Analysis.FilterProfiles), splitList(*profiles)...)
+	if _, err := (&gitdiff.Filter{}).AddProfiles(filterProfiles, nil); err != nil {
+		fatal(fmt.Sprintf("Invalid filter profile: %v", err))
+	}
+	diffOpts := gitdiff.Options{
+		Filter:          fileFilter,
+		ContextLines:    *contextLines,
+		FunctionContext: *functionContext,
+		MaxTokens:       analyzer.DiffTokenBudget(*modelName, *maxDiffTokens),
+		ErrorMessage:    *errorMsg,
+		MaxChunks:       *maxChunks,
+		MinChangedLines: *minChangedLines,
+
+		MaxDiffSize:          *maxDiffSize,
+		MaxFullDiffSize:      *maxFullDiffSize,
+		MaxPromptDiffSize:    *maxPromptDiffSize,
+		AnalyzeNonFunctional: *analyzeNonFunctional,
+		SemanticDiff:         *semanticDiff,
+		DropIrrelevantHunks:  *dropIrrelevantHunks,
+		FullFileMaxBytes:     *fullFileMaxBytes,
+		BlameEvolution:       *blameEvolution,
+	}
+
+	if *exportBundle != "" && (*reuse || *resume) {
+		fatal("-reuse and -resume cannot be combined with -export-bundle: reused verdicts have no recorded responses")
+	}
+	if *exportBundle != "" && multiRepo {
+		fatal("-export-bundle records a single repository; analyze one -repo at a time")
+	}
+
+	// Offline verdicts are recorded and reused under their own model name
+	if *offline {
+		*modelName = analyzer.HeuristicModelName
+	}
+
+	// Pick up where an interrupted run for the same error and model left
+	// off
+	var resumed *checkpoint
+	if *resume {
+		cp, err := readCheckpoint(*checkpointPath)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			logger.Info("No checkpoint to resume at " + *checkpointPath + ", analyzing all commits")
+		case err != nil:
+			fatal(fmt.Sprintf("Failed to read checkpoint: %v", err))
+		case cp.ErrorMessage != *errorMsg || cp.Model != *modelName:
+			logger.Warn(fmt.Sprintf("Checkpoint %s is for another error or model, analyzing all commits", *checkpointPath))
+		default:
+			resumed = cp
+			logger.Info(fmt.Sprintf("Resuming the run interrupted at %s", cp.CreatedAt.Format(time.RFC3339)))
 		}
-		defer os.RemoveAll(tempDir) // Clean up on normal exit
+	}
 
-		logJSON("INFO", "Cloning "+*repoPath+" into temporary directory...")
-		r, err = git.PlainClone(tempDir, false, &git.CloneOptions{
-			URL: *repoPath,
-		})
-		if err != nil {
-			fatalJSON("Failed to clone repo: " + err.Error())
+	if *apiKey != "" {
+		logger.Warn("API key passed via command line may be visible in process list. Consider using GEMINI_API_KEY environment variable or llm.api_key_ref instead.")
+	}
+	var keys []config.ResolvedKey
+	var trackers issues.Config
+	var forges ci.Config
+	if !*offline {
+		if keys, err = cfg.APIKeys(ctx, *apiKey); err != nil {
+			fatal("Error: " + err.Error())
 		}
-	} else {
-		// Local repo
-		r, err = git.PlainOpen(*repoPath)
-		if err != nil {
-			fatalJSON("Failed to open git repo at " + *repoPath + ": " + err.Error())
+		// Issue titles and CI statuses only enrich the prompt; analysis
+		// goes on without them
+		if trackers, err = cfg.IssueTrackers(ctx); err != nil {
+			logger.Warn(fmt.Sprintf("Issue titles unavailable: %v", err))
 		}
+		cfg.CI.Enabled = *ciStatus
+		if forges, err = cfg.CIForges(ctx); err != nil {
+			logger.Warn(fmt.Sprintf("CI statuses unavailable: %v", err))
+		}
+	}
+
+	// Route git remotes and the LLM API through the proxy and CA bundle
+	if err := network.Configure(network.Options{Proxy: *proxy, CABundle: *caBundle}); err != nil {
+		fatal("Invalid network settings: " + err.Error())
 	}
 
-	// Get HEAD reference (or specified branch)
-	var headRef *plumbing.Reference
-	if *branch != "" {
-		refName := plumbing.NewBranchReferenceName(*branch)
-		headRef, err = r.Reference(refName, true)
+	// Open every repository, cloning remote ones
+	if *cloneDepth < 0 {
+		fatal(fmt.Sprintf("Invalid -clone-depth: must not be negative, got %d", *cloneDepth))
+	}
+	cfg.Clone.Username, cfg.Clone.SSHKey = *gitUser, *sshKey
+	cloneOpts := analyzer.CloneOptions{
+		Depth:        *cloneDepth,
+		SingleBranch: *singleBranch,
+		Branch:       *branch,
+		Auth:         analyzer.RemoteAuthFromEnv(cfg.Clone.Username, cfg.Clone.SSHKey),
+	}
+	var cache *analyzer.CloneCache
+	if *cloneCache != "" {
+		if cache, err = analyzer.NewCloneCache(*cloneCache); err != nil {
+			fatal("Invalid -clone-cache: " + err.Error())
+		}
+	}
+	targets := make([]*repoTarget, len(repoPaths))
+	for i, path := range repoPaths {
+		t := &repoTarget{path: path, id: path, diffOpts: diffOpts}
+
+		repoDir := path
+		if analyzer.IsRemoteURL(path) && cache != nil {
+			logger.Info("Updating cached clone of " + path + "...")
+			t.r, repoDir, err = cache.Open(ctx, path, cloneOpts)
+			if err != nil {
+				fatal("Failed to clone repo: " + err.Error())
+			}
+		} else if analyzer.IsRemoteURL(path) {
+			repoDir, err = os.MkdirTemp("", "git-analysis-*")
+			if err != nil {
+				fatal(err.Error())
+			}
+			tempDirs = append(tempDirs, repoDir)
+			defer os.RemoveAll(repoDir) // Clean up on normal exit
+
+			logger.Info("Cloning " + path + " into temporary directory...")
+			t.r, err = analyzer.CloneRepository(ctx, repoDir, path, cloneOpts)
+			if err != nil {
+				fatal("Failed to clone repo: " + err.Error())
+			}
+		} else {
+			// Local repo
+			t.r, err = analyzer.OpenRepository(path)
+			if err != nil {
+				fatal("Failed to open git repo at " + path + ": " + err.Error())
+			}
+			if abs, err := filepath.Abs(path); err == nil {
+				t.id = abs
+			}
+		}
+
+		t.diffOpts.Provider, err = gitdiff.NewProvider(*diffBackend, repoDir)
 		if err != nil {
-			fatalJSON(fmt.Sprintf("Failed to find branch %s: %v", *branch, err))
+			fatal("Invalid diff backend: " + err.Error())
 		}
-		logJSON("INFO", fmt.Sprintf("Analyzing branch: %s", *branch))
-	} else {
-		headRef, err = r.Head()
+
+		// Resolve HEAD (or the specified branch, tag, or commit)
+		t.start, err = analyzer.ResolveCommit(t.r, *branch)
 		if err != nil {
-			fatalJSON("Failed to get HEAD: " + err.Error())
+			fatal(fmt.Sprintf("Failed to resolve %s: %v", path, err))
 		}
+		if *branch != "" {
+			logger.Info(fmt.Sprintf("Analyzing %s at %s", *branch, t.start.Hash.String()[:8]))
+		} else if head, err := t.r.Head(); err == nil && head.Name() == plumbing.HEAD {
+			logger.Info(fmt.Sprintf("Analyzing detached HEAD at %s", t.start.Hash.String()[:8]))
+		}
+		if *deepen && analyzer.IsShallow(t.r) {
+			deepened, err := analyzer.DeepenShallow(ctx, t.r, t.start, *numCommits+1)
+			if err != nil {
+				logger.Warn(fmt.Sprintf("Shallow clone could not be deepened: %v", err))
+			} else if deepened {
+				logger.Info(fmt.Sprintf("Deepened shallow clone of %s to %d commits", path, *numCommits+1))
+			}
+		}
+
+		// Get the commit to compare against once for all goroutines
+		// (performance optimization)
+		t.headCommit = t.start
+		if *headRef != "" {
+			t.headCommit, err = analyzer.ResolveCommit(t.r, *headRef)
+			if err != nil {
+				fatal(fmt.Sprintf("Failed to resolve %s: %v", path, err))
+			}
+			logger.Info(fmt.Sprintf("Comparing against %s at %s", *headRef, t.headCommit.Hash.String()[:8]))
+		}
+
+		targets[i] = t
 	}
 
-	// Get HEAD commit once for all goroutines (performance optimization)
-	headCommit, err := r.CommitObject(headRef.Hash())
-	if err != nil {
-		fatalJSON("Failed to get HEAD commit: " + err.Error())
+	// Open history database (failures are non-fatal)
+	var store *history.Store
+	if !*noHistory || *reuse {
+		store, err = history.Open(cfg.History.Path)
+		if err != nil {
+			logger.Warn(fmt.Sprintf("History disabled: %v", err))
+			store = nil
+		} else {
+			defer store.Close()
+		}
 	}
+	fingerprint := history.Fingerprint(*errorMsg)
 
-	// Initialize Gemini
-	client, err := genai.NewClient(ctx, option.WithAPIKey(key))
-	if err != nil {
-		fatalJSON("Failed to create Gemini client: " + err.Error())
+	if *verifyCmd != "" {
+		logger.Info(fmt.Sprintf("Verifying up to %d suspects by running: %s", *verifyTop, *verifyCmd))
 	}
-	defer client.Close()
 
-	model := client.GenerativeModel(*modelName)
-	model.SetTemperature(cfg.LLM.Temperature)
-	
-	logJSON("INFO", fmt.Sprintf("Using LLM model: %s", *modelName))
+	// Initialize Gemini, unless commits are scored offline
+	var model analyzer.LLMModel
+	var promptCache *analyzer.ContextCache
+	if *offline {
+		logger.Info("Offline mode: rating commits with heuristics, without an LLM")
+	} else {
+		// In batch mode all prompts wait to be submitted together
+		if *batchMode {
+			if len(keys) > 1 {
+				logger.Warn(fmt.Sprintf("Batch mode submits with the first API key only (%s)", keys[0].Name))
+			}
+			model = batch.New(batch.Options{
+				APIKey:       keys[0].Value,
+				Model:        *modelName,
+				Temperature:  cfg.LLM.Temperature,
+				PollInterval: cfg.LLM.BatchPollInterval,
+				Logf: func(format string, args ...any) {
+					logger.Info(fmt.Sprintf(format, args...))
+				},
+			})
+			if *contextCache {
+				logger.Warn("The context cache is not used in batch mode")
+			}
+		} else {
+			// Several API keys, or one with a request cap, share the
+			// calls; prompts go through the context cache, once it is
+			// prepared below
+			geminiOpts := geminiOptions(cfg, keys, *modelName, func(format string, args ...any) {
+				logger.Warn(fmt.Sprintf(format, args...))
+			})
+			geminiOpts.Stream, geminiOpts.ContextCache = *stream, *contextCache
+			gemini, err := analyzer.NewGeminiModel(ctx, geminiOpts)
+			if err != nil {
+				fatal(err.Error())
+			}
+			defer func() {
+				closeCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+				defer cancel()
+				if err := gemini.Close(closeCtx); err != nil {
+					logger.Warn(fmt.Sprintf("Context cache not deleted, it expires in %v: %v", cfg.LLM.ContextCacheTTL, err))
+				}
+			}()
+			model, promptCache = gemini, gemini.Cache
 
-	if *verbose {
-		logJSON("DEBUG", fmt.Sprintf("Using model: %s, timeout: %v", *modelName, *timeout))
-	}
+			// Diffs are fitted to their budget in the model's tokens
+			tokenizer := gemini.Tokenizer(ctx)
+			for _, t := range targets {
+				t.diffOpts.Tokenizer = tokenizer
+			}
+			if gemini.Pooled {
+				logger.Info(fmt.Sprintf("Spreading LLM calls over %d API keys (%s)", len(keys), cfg.LLM.KeyRotation))
+				if *contextCache {
+					logger.Warn("The context cache is not used with llm.api_keys")
+				}
+			}
+		}
 
-	// Iterate Commits
-	cIter, err := r.Log(&git.LogOptions{From: headRef.Hash()})
-	if err != nil {
-		fatalJSON("Failed to get commit log: " + err.Error())
+		// Record every LLM interaction when an audit log is configured
+		if *auditPath == "" && cfg.Audit.Enabled {
+			*auditPath = cfg.Audit.Path
+		}
+		if *auditPath != "" {
+			auditLog, err := audit.Open(*auditPath)
+			if err != nil {
+				fatal(err.Error())
+			}
+			defer auditLog.Close()
+			model = audit.Wrap(model, *modelName, auditLog)
+		}
+
+		logger.Info(fmt.Sprintf("Using LLM model: %s", *modelName))
 	}
 
-	logJSON("INFO", fmt.Sprintf("Analyzing last %d commits for error: %q", *numCommits, *errorMsg))
+	logger.Debug(fmt.Sprintf("Using model: %s, timeout: %v", *modelName, *timeout))
+
+	logger.Info(fmt.Sprintf("Analyzing last %d commits for error: %q", *numCommits, *errorMsg))
 
 	// Collect commits first
-	var commits []*object.Commit
-	count := 0
-	for {
-		if count >= *numCommits {
-			break
+	for _, t := range targets {
+		t.commits, _, err = analyzer.CollectCommits(t.r, analyzer.AnalysisOptions{
+			NumCommits: *numCommits,
+			Branch:     t.start.Hash.String(),
+			Diff:       t.diffOpts,
+		})
+		if err != nil {
+			fatal("Failed to collect commits: " + err.Error())
 		}
-		c, err := cIter.Next()
-		if err == io.EOF {
-			break
+		if len(t.commits) < *numCommits && analyzer.IsShallow(t.r) {
+			logger.Warn(fmt.Sprintf("Shallow clone of %s ends after %d commits; fetch more history or pass -deepen", t.path, len(t.commits)))
 		}
-		if err != nil {
-			fatalJSON("Error iterating commits: " + err.Error())
+
+		// Results stream in commit order per repository
+		t.printer = newOrderedPrinter(sink, logger, len(t.commits))
+		t.printer.debugDir = *debugDir
+		if multiRepo {
+			t.printer.repo = t.path
 		}
+	}
 
-		// Skip merge commits
-		if len(c.ParentHashes) > 1 {
-			continue
+	// Cache the part of the prompts all commits share; without it they
+	// are sent whole
+	if promptCache != nil {
+		var head strings.Builder
+		var err error
+		for _, t := range targets {
+			var files string
+			files, err = analyzer.HeadContext(t.commits, t.headCommit, t.diffOpts.Filter, cfg.LLM.ContextCacheTokens/len(targets))
+			if err != nil {
+				break
+			}
+			if multiRepo && files != "" {
+				fmt.Fprintf(&head, "REPOSITORY %s:\n", t.path)
+			}
+			head.WriteString(files)
 		}
+		if err == nil {
+			err = promptCache.Prepare(ctx, *errorMsg, head.String())
+		}
+		if err != nil {
+			logger.Warn(fmt.Sprintf("Context cache unavailable, sending whole prompts: %v", err))
+		} else {
+			logger.Info("Cached the prompt context shared by all commits")
+		}
+	}
 
-		commits = append(commits, c)
-		count++
+	// Capture diffs, prompts, and responses for the reproducibility bundle
+	var recorder *bundle.Recorder
+	if *exportBundle != "" {
+		recorder = bundle.NewRecorder(bundle.Manifest{
+			Repo:         targets[0].id,
+			Branch:       *branch,
+			ErrorMessage: *errorMsg,
+			Model:        *modelName,
+		}, cfg, targets[0].commits)
 	}
 
 	startTime := time.Now()
 
-	// Parallel Processing with ordered streaming output
-	printer := newOrderedPrinter(encoder, len(commits))
-	var wg sync.WaitGroup
 	if *numWorkers < 1 {
 		*numWorkers = 1
 	}
-	sem := make(chan struct{}, *numWorkers) // Limit to N concurrent requests
 
-	for i, c := range commits {
-		wg.Add(1)
-		sem <- struct{}{}
+	// An interrupt cancels ctx, which keeps further commits from starting;
+	// commits in flight run on until they finish, or a second interrupt
+	// aborts them
+	workCtx, abort := context.WithCancel(context.WithoutCancel(ctx))
+	defer abort()
 
-		go func(idx int, commit *object.Commit) {
+	// Every repository is analyzed at once by the shared pipeline, their
+	// LLM calls sharing the -j workers and their diffs extracted on a share
+	// of -j repository handles each, with results streaming in commit
+	// order per repository
+	workers := *numWorkers
+	if *batchMode && !*offline {
+		// Every prompt must be pending for them to be submitted as one
+		// batch; diffs are still extracted by -j workers
+		workers = 0
+		for _, t := range targets {
+			workers += len(t.commits)
+		}
+		workers = max(workers, 1)
+	}
+	slots := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for _, t := range targets {
+		wg.Add(1)
+		go func() {
 			defer wg.Done()
-			defer func() { <-sem }()
-
-			// Check for cancellation before starting
-			select {
-			case <-ctx.Done():
-				printer.submit(&commitResult{index: idx, err: ctx.Err(), commit: commit})
+			printer := t.printer
+			if ctx.Err() != nil {
+				for i, c := range t.commits {
+					if recorder != nil {
+						recorder.RecordError(i, errInterrupted)
+					}
+					printer.submit(&commitResult{index: i, err: errInterrupted, commit: c})
+				}
 				return
-			default:
 			}
+			results, err := analyzer.RunPipeline(workCtx, t.r, t.commits, t.headCommit, model, analyzer.AnalysisOptions{
+				ErrorMessage:   *errorMsg,
+				Slots:          slots,
+				ExtractWorkers: max(*numWorkers/len(targets), 1),
+				Timeout:        *timeout,
+				Budget:         budget,
+				Retry:          retry,
+				Diff:           t.diffOpts,
+				Offline:        *offline,
+				ScoreWeights:   weights,
+				Verbosity:      verbosity,
+				Issues:         trackers,
+				CI:             forges,
+				DedupePatches:  *dedupe,
+				ObjectCacheMB:  *objectCacheMB,
 
-			// Create a context with timeout for each request
-			reqCtx, cancel := context.WithTimeout(ctx, *timeout)
-			defer cancel()
+				FilterProfiles: filterProfiles,
+				SuggestOwners:  *suggestOwners,
+				AffectedTests:  *affectedTests,
+				HotspotHistory: *hotspotHistory,
+				TechStack:      *techStack,
+				RelatedFiles:   *relatedFiles,
+				GoCallers:      *goCallers,
+				Verify: analyzer.VerifyOptions{
+					Command: *verifyCmd,
+					Top:     *verifyTop,
+					Timeout: *verifyTimeout,
+				},
 
-			if *verbose {
-				logJSON("DEBUG", fmt.Sprintf("Starting analysis of commit %s", commit.Hash.String()[:8]))
-			}
+				OnProgress: func(msg string) { logger.Debug(printer.repoPrefix() + msg) },
 
-			// Use retry logic for transient failures
-			var res *analyzer.AnalysisResult
-			err := analyzer.WithRetry(reqCtx, analyzer.DefaultRetryConfig(), func() error {
-				var analyzeErr error
-				res, analyzeErr = analyzer.AnalyzeCommit(reqCtx, r, commit, headCommit, *errorMsg, model)
-				return analyzeErr
+				// Reuse a known verdict instead of calling the LLM again
+				Known: func(i int, c *object.Commit) *analyzer.AnalysisResult {
+					if v, ok := resumed.verdict(t.id, c.Hash.String()); ok {
+						return &analyzer.AnalysisResult{Probability: analyzer.Probability(v.Probability), Reasoning: v.Reasoning}
+					}
+					if *reuse && store != nil {
+						if v, ok, err := store.Lookup(t.id, fingerprint, c.Hash.String(), *modelName); err == nil && ok {
+							logger.Debug(fmt.Sprintf("Reusing stored verdict for commit %s%s from run %s", printer.repoPrefix(), c.Hash.String()[:8], v.RunID))
+							return &analyzer.AnalysisResult{Probability: analyzer.Probability(v.Probability), Reasoning: v.Reasoning}
+						}
+					}
+					return nil
+				},
+				OnExtracted: func(i int, dc *analyzer.CommitDiffContext, err error) {
+					if recorder != nil && dc != nil {
+						recorder.RecordDiffs(i, dc)
+					}
+				},
+				BeforeAnalyze: func(i int, dc *analyzer.CommitDiffContext) error {
+					if ctx.Err() != nil {
+						return errInterrupted
+					}
+					logger.Debug(fmt.Sprintf("Starting analysis of commit %s%s", printer.repoPrefix(), dc.Commit.Hash.String()[:8]))
+					return nil
+				},
+				CommitModel: func(i int, model analyzer.LLMModel) analyzer.LLMModel {
+					if recorder != nil {
+						return recorder.Model(i, model)
+					}
+					return model
+				},
+				OnResult: func(r analyzer.CommitAnalysisResult) {
+					if recorder != nil {
+						recorder.RecordError(r.Index, r.Error)
+					}
+					printer.submit(&commitResult{index: r.Index, result: r.Result, err: r.Error, retries: r.Retries, commit: t.commits[r.Index]})
+				},
 			})
-
-			// Submit result for ordered streaming output
-			printer.submit(&commitResult{index: idx, result: res, err: err, commit: commit})
-		}(i, c)
+			if err != nil && workCtx.Err() == nil {
+				logger.Error(fmt.Sprintf("Failed to analyze %s: %v", t.path, err))
+				failed.Store(true)
+			}
+			// A run failing before its first result, such as on a HEAD it
+			// cannot read, fails every commit, for the summary to count
+			if err != nil && results == nil {
+				for i, c := range t.commits {
+					if recorder != nil {
+						recorder.RecordError(i, err)
+					}
+					printer.submit(&commitResult{index: i, err: err, commit: c})
+				}
+			}
+		}()
 	}
-
-	// Wait for completion or cancellation
 	done := make(chan struct{})
 	go func() {
 		wg.Wait()
 		close(done)
 	}()
 
+	// Wait for completion or cancellation
+
+	interrupted, aborted := false, false
 	select {
 	case <-done:
 		// Normal completion
 	case <-ctx.Done():
-		logJSON("WARN", "Received interrupt signal, shutting down...")
-		// Wait briefly for goroutines to finish
+		interrupted = true
+		logger.Warn("Received interrupt signal, finishing the commits in flight (interrupt again to abort them)...")
+		stop()
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(sig)
 		select {
 		case <-done:
-		case <-time.After(5 * time.Second):
-			logJSON("WARN", "Timeout waiting for goroutines, forcing exit")
+		case <-sig:
+			logger.Warn("Received second interrupt signal, aborting the commits in flight...")
+			aborted = true
+			abort()
+			// Wait briefly for goroutines to finish
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				logger.Warn("Timeout waiting for goroutines, forcing exit")
+			}
+		}
+	}
+
+	// A run interrupted after every commit started, and not aborted, is
+	// complete
+	if interrupted && !aborted {
+		interrupted = false
+		for _, t := range targets {
+			t.printer.mu.Lock()
+			interrupted = interrupted || t.printer.interrupted > 0
+			t.printer.mu.Unlock()
 		}
 	}
 
-	// Output summary
-	if err := encoder.Encode(printer.summary(time.Since(startTime), *modelName)); err != nil {
+	// Record the verdicts so far for -resume; a completed run leaves
+	// nothing to resume
+	if interrupted {
+		cp := &checkpoint{CreatedAt: time.Now().UTC(), ErrorMessage: *errorMsg, Model: *modelName, Branch: *branch}
+		for _, t := range targets {
+			cp.Repos = append(cp.Repos, t.printer.checkpointRepo(t.id))
+		}
+		if err := writeCheckpoint(*checkpointPath, cp); err != nil {
+			logger.Warn(fmt.Sprintf("Failed to write checkpoint: %v", err))
+		} else {
+			logger.Info(fmt.Sprintf("Wrote checkpoint to %s; rerun with -resume to analyze the remaining commits", *checkpointPath))
+		}
+	} else if resumed != nil {
+		if err := os.Remove(*checkpointPath); err != nil {
+			logger.Warn(fmt.Sprintf("Failed to remove checkpoint: %v", err))
+		}
+	}
+
+	// Output summary, merged across repositories
+	duration := time.Since(startTime)
+	summary := targets[0].printer.summary(duration, *modelName)
+	if multiRepo {
+		printers := make([]*orderedPrinter, len(targets))
+		for i, t := range targets {
+			printers[i] = t.printer
+		}
+		summary = mergeSummaries(printers, duration, *modelName)
+	}
+	summary.Partial = interrupted
+	if err := sink.WriteSummary(summary); err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to encode summary: %v\n", err)
 	}
+
+	if recorder != nil {
+		if err := recorder.WriteFile(*exportBundle, summary); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write bundle: %v\n", err)
+		} else {
+			logger.Info("Wrote reproducibility bundle to " + *exportBundle)
+		}
+	}
+
+	// Record the run in the history database, one run per repository
+	if store != nil && !*noHistory {
+		for _, t := range targets {
+			repoSummary := t.printer.summary(duration, *modelName)
+			run := history.Run{
+				ID:           history.NewRunID(),
+				Repo:         t.id,
+				Branch:       *branch,
+				ErrorMessage: *errorMsg,
+				Fingerprint:  fingerprint,
+				Model:        *modelName,
+				Total:        repoSummary.Total,
+				High:         repoSummary.High,
+				Medium:       repoSummary.Medium,
+				Low:          repoSummary.Low,
+				Skipped:      repoSummary.Skipped,
+				Errors:       repoSummary.Errors,
+				Duration:     repoSummary.Duration,
+			}
+			if err := store.SaveRun(run, t.printer.verdicts); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to record run in history: %v\n", err)
+			}
+		}
+	}
 }
@@ -0,0 +1,45 @@
+package main
+
+import "testing"
+
+func TestProfileArg(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{nil, ""},
+		{[]string{"-error", "x"}, ""},
+		{[]string{"-config-profile", "nightly", "-error", "x"}, "nightly"},
+		{[]string{"-error", "x", "--config-profile=incident"}, "incident"},
+		{[]string{"-error", "-config-profile"}, ""},
+		{[]string{"-error", "x", "--", "-config-profile", "nightly"}, ""},
+		{[]string{"-profile", "go"}, ""},
+		{[]string{"-config-profile", "nightly", "-config-profile", "incident"}, "incident"},
+	}
+	for _, tt := range tests {
+		if got := profileArg(tt.args); got != tt.want {
+			t.Errorf("profileArg(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestRepoConfigDir(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{nil, "."},
+		{[]string{"-error", "x"}, "."},
+		{[]string{"-repo", "../service", "-error", "x"}, "../service"},
+		{[]string{"--repo=../service"}, "../service"},
+		{[]string{"-repo", "a", "-repo", "b"}, ""},
+		{[]string{"-repo", "https://github.com/example/repo.git"}, ""},
+		{[]string{"-repos-file", "repos.txt"}, ""},
+		{[]string{"doctor", "-repo", "../service"}, ""},
+	}
+	for _, tt := range tests {
+		if got := repoConfigDir(tt.args); got != tt.want {
+			t.Errorf("repoConfigDir(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/kerneldump/git-dual-context/pkg/analyzer"
+	"github.com/kerneldump/git-dual-context/pkg/config"
+
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+const modelsUsage = `usage: git-commit-analysis models <command> [flags]
+
+Commands:
+  list              List models available to your API key`
+
+// modelRecord is one line of "models list" output
+type modelRecord struct {
+	Type             string                 `json:"type"`
+	Name             string                 `json:"name"`
+	DisplayName      string                 `json:"display_name,omitempty"`
+	InputTokenLimit  int32                  `json:"input_token_limit"`
+	OutputTokenLimit int32                  `json:"output_token_limit"`
+	Pricing          *analyzer.ModelPricing `json:"pricing,omitempty"`
+}
+
+// runModels implements the "models" subcommand
+func runModels(ctx context.Context, cfg *config.Config, args []string, w io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing command\n%s", modelsUsage)
+	}
+	if args[0] != "list" {
+		return fmt.Errorf("unknown command %q\n%s", args[0], modelsUsage)
+	}
+
+	fs := flag.NewFlagSet("models list", flag.ContinueOnError)
+	apiKey := fs.String("apikey", "", "Google Gemini API Key (prefer GEMINI_API_KEY env var)")
+	all := fs.Bool("all", false, "Include models that cannot generate content (e.g. embeddings)")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	key, err := cfg.APIKey(ctx, *apiKey)
+	if err != nil {
+		return err
+	}
+
+	client, err := genai.NewClient(ctx, option.WithAPIKey(key))
+	if err != nil {
+		return fmt.Errorf("failed to create Gemini client: %w", err)
+	}
+	defer client.Close()
+
+	encoder := json.NewEncoder(w)
+	it := client.ListModels(ctx)
+	for {
+		info, err := it.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to list models: %w", err)
+		}
+		if !*all && !slices.Contains(info.SupportedGenerationMethods, "generateContent") {
+			continue
+		}
+		if err := encoder.Encode(newModelRecord(info)); err != nil {
+			return err
+		}
+	}
+}
+
+// newModelRecord converts provider metadata, attaching list pricing when known
+func newModelRecord(info *genai.ModelInfo) modelRecord {
+	rec := modelRecord{
+		Type:             "model",
+		Name:             info.Name,
+		DisplayName:      info.DisplayName,
+		InputTokenLimit:  info.InputTokenLimit,
+		OutputTokenLimit: info.OutputTokenLimit,
+	}
+	if p, ok := analyzer.LookupPricing(info.Name); ok {
+		rec.Pricing = &p
+	}
+	return rec
+}
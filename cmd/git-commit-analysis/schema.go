@@ -0,0 +1,38 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/kerneldump/git-dual-context/pkg/analyzer"
+)
+
+var schemaUsage = `usage: git-commit-analysis schema [` + strings.Join(analyzer.RecordTypes, "|") + `]
+
+Prints the JSON Schema of the analysis output records; without a record
+type, a schema matching any of them`
+
+// runSchema implements the "schema" subcommand
+func runSchema(args []string, w io.Writer) error {
+	if len(args) > 1 {
+		return fmt.Errorf("too many arguments\n%s", schemaUsage)
+	}
+	var recordType string
+	if len(args) == 1 {
+		recordType = args[0]
+	}
+	if recordType == "-h" || recordType == "-help" || recordType == "--help" {
+		fmt.Fprintln(w, schemaUsage)
+		return nil
+	}
+
+	schema, err := analyzer.RecordSchema(recordType)
+	if err != nil {
+		return fmt.Errorf("%w\n%s", err, schemaUsage)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(schema)
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/kerneldump/git-dual-context/pkg/analyzer"
+	"github.com/kerneldump/git-dual-context/pkg/audit"
+	"github.com/kerneldump/git-dual-context/pkg/config"
+	"github.com/kerneldump/git-dual-context/pkg/gitdiff"
+	"github.com/kerneldump/git-dual-context/pkg/history"
+	"github.com/kerneldump/git-dual-context/pkg/secret"
+	"github.com/kerneldump/git-dual-context/pkg/server"
+	"github.com/kerneldump/git-dual-context/pkg/telemetry"
+
+	"google.golang.org/grpc"
+)
+
+// runServe implements the "serve" subcommand, running the REST daemon
+// and/or the gRPC service until ctx is cancelled. cfg was loaded from
+// configPath with profile, and is reloaded the same way when the file
+// changes.
+func runServe(ctx context.Context, cfg *config.Config, configPath, profile string, args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := fs.String("http", "localhost:8080", "Address to listen on for the REST API; a bare port such as :8080 listens on every interface, which requires serve.auth_token_ref (empty to disable)")
+	grpcAddr := fs.String("grpc", "", "Address to listen on for the gRPC API, like -http (empty to disable)")
+	modelName := fs.String("model", cfg.LLM.Model, "Gemini model to use")
+	apiKey := fs.String("apikey", "", "Google Gemini API Key (prefer GEMINI_API_KEY env var)")
+	auditPath := fs.String("audit-log", "", "Append every LLM prompt and response hash to this JSONL file (default: audit.path when audit.enabled)")
+	noHistory := fs.Bool("no-history", !cfg.History.Enabled, "Do not record jobs in the history database (disables the dashboard)")
+	reloadInterval := fs.Duration("reload-interval", config.DefaultReloadInterval, "How often to check the config file for changes (0 to disable)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *addr == "" && *grpcAddr == "" {
+		return fmt.Errorf("at least one of -http or -grpc must be set")
+	}
+
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+
+	shutdownTracing, err := telemetry.Setup(ctx, "git-commit-analysis")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(flushCtx); err != nil {
+			logger.Printf("WARN: flushing traces: %v", err)
+		}
+	}()
+	if telemetry.Enabled() {
+		logger.Println("Exporting traces via OTLP")
+	}
+
+	if *apiKey != "" && cfg.LLM.Provider != config.ProviderHeuristic {
+		logger.Println("WARN: API key passed via command line may be visible in process list. Consider using GEMINI_API_KEY environment variable or llm.api_key_ref instead.")
+	}
+	if *auditPath == "" && cfg.Audit.Enabled {
+		*auditPath = cfg.Audit.Path
+	}
+	var auditLog *audit.Log
+	if *auditPath != "" {
+		auditLog, err = audit.Open(*auditPath)
+		if err != nil {
+			return err
+		}
+		defer auditLog.Close()
+		logger.Printf("Auditing LLM interactions to %s", *auditPath)
+	}
+
+	// The -model flag outlasts config reloads; otherwise llm.model applies
+	modelFlagSet := false
+	fs.Visit(func(f *flag.Flag) { modelFlagSet = modelFlagSet || f.Name == "model" })
+	modelFor := func(cfg *config.Config) string {
+		if modelFlagSet {
+			return *modelName
+		}
+		return cfg.LLM.Model
+	}
+
+	token, err := serveAuthToken(cfg.Serve)
+	if err != nil {
+		return fmt.Errorf("failed to resolve serve.auth_token_ref: %w", err)
+	}
+	// Without a token, anyone reaching the server could analyze any
+	// repository with the operator's API key, so only loopback is served
+	if token == "" {
+		for _, a := range []string{*addr, *grpcAddr} {
+			if a != "" && !loopbackAddr(a) {
+				return fmt.Errorf("refusing to serve %s without authentication: set serve.auth_token_ref, or listen on localhost", a)
+			}
+		}
+	}
+
+	current, err := newServeModel(ctx, cfg, *modelName, *apiKey, auditLog, logger)
+	if err != nil {
+		return err
+	}
+	startModel := current.name
+
+	// Replaced models may still serve running jobs, so all are closed at
+	// shutdown, after the config watcher and the jobs have stopped
+	closers := []func(){current.close}
+	defer func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			closers[i]()
+		}
+	}()
+
+	var store *history.Store
+	if !*noHistory {
+		store, err = history.Open(cfg.History.Path)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+	}
+
+	srv := server.New(server.Options{
+		Model:        current.model,
+		ModelName:    current.name,
+		ContextCache: current.cache,
+		Tokenizer:    current.tokenizer,
+		Config:       cfg,
+		History:      store,
+		Logger:       logger,
+		AuthToken:    token,
+	})
+	defer srv.Close()
+
+	// New jobs pick up changes to the config file; a changed llm section
+	// rebuilds the model
+	if configPath != "" && *reloadInterval > 0 {
+		watchCtx, stopWatching := context.WithCancel(ctx)
+		watched := make(chan struct{})
+		defer func() {
+			stopWatching()
+			<-watched
+		}()
+		currentCfg := cfg
+		watcher := &config.Watcher{
+			Path:     configPath,
+			Interval: *reloadInterval,
+			Load: func() (*config.Config, error) {
+				newCfg, _, err := config.Load(configPath, "", profile)
+				return newCfg, err
+			},
+			OnReload: func(newCfg *config.Config) error {
+				if !reflect.DeepEqual(newCfg.LLM, currentCfg.LLM) {
+					rebuilt, err := newServeModel(ctx, newCfg, modelFor(newCfg), *apiKey, auditLog, logger)
+					if err != nil {
+						return err
+					}
+					closers = append(closers, rebuilt.close)
+					current = rebuilt
+				}
+				srv.Reload(server.Options{
+					Model:        current.model,
+					ModelName:    current.name,
+					ContextCache: current.cache,
+					Tokenizer:    current.tokenizer,
+					Config:       newCfg,
+				})
+				currentCfg = newCfg
+				logger.Printf("Reloaded config from %s (model: %s)", configPath, current.name)
+				return nil
+			},
+			OnError: func(err error) {
+				logger.Printf("WARN: Ignoring changed config %s, new jobs keep the current settings: %v", configPath, err)
+			},
+		}
+		go func() {
+			defer close(watched)
+			watcher.Run(watchCtx)
+		}()
+		logger.Printf("Watching %s for config changes", configPath)
+	}
+
+	errCh := make(chan error, 2)
+
+	var httpServer *http.Server
+	if *addr != "" {
+		httpServer = &http.Server{
+			Addr:              *addr,
+			Handler:           srv.Handler(),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		go func() {
+			logger.Printf("Serving REST API on %s (model: %s)", *addr, startModel)
+			errCh <- httpServer.ListenAndServe()
+		}()
+	}
+
+	var grpcServer *grpc.Server
+	if *grpcAddr != "" {
+		lis, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s: %w", *grpcAddr, err)
+		}
+		grpcServer = grpc.NewServer()
+		srv.RegisterGRPC(grpcServer)
+		go func() {
+			logger.Printf("Serving gRPC API on %s (model: %s)", *grpcAddr, startModel)
+			errCh <- grpcServer.Serve(lis)
+		}()
+	}
+
+	var serveErr error
+	select {
+	case serveErr = <-errCh:
+		if errors.Is(serveErr, http.ErrServerClosed) {
+			serveErr = nil
+		}
+	case <-ctx.Done():
+		logger.Println("Received interrupt signal, shutting down...")
+	}
+
+	// Cancel running jobs first so open result streams can drain
+	srv.Close()
+	if grpcServer != nil {
+		grpcServer.GracefulStop()
+	}
+	if httpServer != nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := httpServer.Shutdown(shutdownCtx); err != nil && serveErr == nil {
+			serveErr = err
+		}
+	}
+	return serveErr
+}
+
+// loopbackAddr reports whether the listen address addr only accepts
+// connections from this host: localhost, or a loopback IP
+func loopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// serveAuthToken returns the bearer token API clients must send, resolved
+// from serve.auth_token_ref (empty: no authentication)
+func serveAuthToken(cfg config.ServeConfig) (string, error) {
+	if cfg.AuthTokenRef == "" {
+		return "", nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), secret.CommandTimeout)
+	defer cancel()
+	return secret.Resolve(ctx, cfg.AuthTokenRef)
+}
+
+// serveModel is the model jobs call under one config
+type serveModel struct {
+	model     analyzer.LLMModel // nil with llm.provider heuristic
+	name      string
+	cache     *analyzer.ContextCache
+	tokenizer gitdiff.Tokenizer
+	close     func()
+}
+
+// newServeModel builds the model named name for cfg: through a key pool
+// for several API keys, the context cache when enabled, and auditLog if set
+func newServeModel(ctx context.Context, cfg *config.Config, name, apiKey string, auditLog *audit.Log, logger *log.Logger) (*serveModel, error) {
+	// With llm.provider heuristic, jobs are rated offline without an LLM
+	if cfg.LLM.Provider == config.ProviderHeuristic {
+		return &serveModel{name: analyzer.HeuristicModelName, close: func() {}}, nil
+	}
+
+	keys, err := cfg.APIKeys(ctx, apiKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// Several API keys, or one with a request cap, share all jobs' calls;
+	// each job caches its prompt context, whose entries expire after the
+	// TTL unless the server stops first
+	gemini, err := analyzer.NewGeminiModel(ctx, geminiOptions(cfg, keys, name, func(format string, args ...any) {
+		logger.Printf("WARN: "+format, args...)
+	}))
+	if err != nil {
+		return nil, err
+	}
+	if gemini.Pooled {
+		logger.Printf("Spreading LLM calls over %d API keys (%s)", len(keys), cfg.LLM.KeyRotation)
+		if cfg.LLM.ContextCache {
+			logger.Println("WARN: The context cache is not used with llm.api_keys")
+		}
+	}
+
+	var model analyzer.LLMModel = gemini
+	if auditLog != nil {
+		model = audit.Wrap(model, name, auditLog)
+	}
+	closeModel := func() { gemini.Close(context.Background()) }
+	return &serveModel{model: model, name: name, cache: gemini.Cache, tokenizer: gemini.Tokenizer(ctx), close: closeModel}, nil
+}
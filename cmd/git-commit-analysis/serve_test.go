@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestLoopbackAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"localhost:8080", true},
+		{"127.0.0.1:8080", true},
+		{"[::1]:8080", true},
+		{":8080", false},
+		{"0.0.0.0:8080", false},
+		{"192.168.1.10:8080", false},
+		{"example.com:8080", false},
+		{"8080", false},
+	}
+	for _, tt := range tests {
+		if got := loopbackAddr(tt.addr); got != tt.want {
+			t.Errorf("loopbackAddr(%q) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
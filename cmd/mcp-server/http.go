@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/kerneldump/git-dual-context/cmd/mcp-server/internal/tools"
+	"github.com/kerneldump/git-dual-context/pkg/config"
+	"github.com/kerneldump/git-dual-context/pkg/secret"
+
+	"github.com/modelcontextprotocol/go-sdk/auth"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// authToken returns the bearer token HTTP clients must send, resolved from
+// mcp.auth_token_ref (empty: no authentication)
+func authToken(cfg config.MCPConfig) (string, error) {
+	if cfg.AuthTokenRef == "" {
+		return "", nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), secret.CommandTimeout)
+	defer cancel()
+	return secret.Resolve(ctx, cfg.AuthTokenRef)
+}
+
+// newHTTPHandler serves server over the streamable HTTP transport at /mcp,
+// and the older HTTP+SSE transport at /sse for clients that predate it.
+// With a token, every request must carry it as a bearer token.
+func newHTTPHandler(server *mcp.Server, token string) http.Handler {
+	getServer := func(*http.Request) *mcp.Server { return server }
+
+	mux := http.NewServeMux()
+	mux.Handle("/mcp", mcp.NewStreamableHTTPHandler(getServer, nil))
+	mux.Handle("/sse", mcp.NewSSEHandler(getServer, nil))
+	handler := withClient(mux)
+	if token == "" {
+		return handler
+	}
+
+	verify := func(ctx context.Context, got string, r *http.Request) (*auth.TokenInfo, error) {
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			return nil, fmt.Errorf("%w: wrong bearer token", auth.ErrInvalidToken)
+		}
+		// The token itself does not expire; each request is checked anew
+		return &auth.TokenInfo{Expiration: time.Now().Add(time.Hour)}, nil
+	}
+	return auth.RequireBearerToken(verify, nil)(handler)
+}
+
+// withClient tags each request with its client's address, so that all
+// MCP sessions from one address share its limits (see tools.WithClient).
+// A session keeps the client of the request that opened it.
+func withClient(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		next.ServeHTTP(w, r.WithContext(tools.WithClient(r.Context(), host)))
+	})
+}
+
+// serveHTTP serves server on addr until ctx is cancelled
+func serveHTTP(ctx context.Context, server *mcp.Server, addr, token string) error {
+	if token == "" {
+		log.Printf("WARN: Serving MCP over HTTP without authentication; set mcp.auth_token_ref to require a bearer token")
+	}
+	httpServer := &http.Server{
+		Addr:              addr,
+		Handler:           newHTTPHandler(server, token),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		log.Printf("Serving MCP on http://%s/mcp (streamable HTTP) and /sse (SSE)", addr)
+		errCh <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+		log.Println("Received interrupt signal, shutting down...")
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return httpServer.Shutdown(shutdownCtx)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// bearerTransport adds a bearer token to every request
+type bearerTransport struct {
+	token string
+}
+
+func (t bearerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.Header.Set("Authorization", "Bearer "+t.token)
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestHTTPHandler(t *testing.T) {
+	ts := httptest.NewServer(newHTTPHandler(newServer(), "s3cret"))
+	defer ts.Close()
+
+	connect := func(transport mcp.Transport) (*mcp.ClientSession, error) {
+		client := mcp.NewClient(&mcp.Implementation{Name: "test", Version: "0.0.1"}, nil)
+		return client.Connect(context.Background(), transport, nil)
+	}
+	withToken := func(token string) *http.Client {
+		return &http.Client{Transport: bearerTransport{token: token}}
+	}
+
+	for name, transport := range map[string]mcp.Transport{
+		"streamable": &mcp.StreamableClientTransport{Endpoint: ts.URL + "/mcp", HTTPClient: withToken("s3cret"), MaxRetries: -1},
+		"sse":        &mcp.SSEClientTransport{Endpoint: ts.URL + "/sse", HTTPClient: withToken("s3cret")},
+	} {
+		session, err := connect(transport)
+		if err != nil {
+			t.Fatalf("%s: connect failed: %v", name, err)
+		}
+		tools, err := session.ListTools(context.Background(), nil)
+		if err != nil {
+			t.Fatalf("%s: ListTools failed: %v", name, err)
+		}
+		if len(tools.Tools) == 0 {
+			t.Errorf("%s: expected the server's tools", name)
+		}
+		session.Close()
+	}
+
+	for name, client := range map[string]*http.Client{"no token": http.DefaultClient, "wrong token": withToken("guess")} {
+		if _, err := connect(&mcp.StreamableClientTransport{Endpoint: ts.URL + "/mcp", HTTPClient: client, MaxRetries: -1}); err == nil {
+			t.Errorf("%s: expected the connection to be refused", name)
+		}
+		resp, err := client.Get(ts.URL + "/sse")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("%s: GET /sse status = %d, want %d", name, resp.StatusCode, http.StatusUnauthorized)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/kerneldump/git-dual-context/pkg/analyzer"
+	"github.com/kerneldump/git-dual-context/pkg/gitdiff"
+	"github.com/kerneldump/git-dual-context/pkg/validator"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// maxListedFiles caps the changed files listed per commit; FileCount still
+// counts them all
+const maxListedFiles = 50
+
+// ListCommitsInput represents the input parameters for the
+// list_recent_commits tool
+type ListCommitsInput struct {
+	RepoPath     string `json:"repo_path" required:"true" description:"Path to a local git repository, or a remote URL to clone"`
+	NumCommits   int    `json:"num_commits,omitempty" description:"Number of recent commits to list (default: 5)"`
+	Branch       string `json:"branch,omitempty" description:"Branch, tag, remote-tracking ref, or commit whose history is listed (default: current HEAD)"`
+	IncludeTests bool   `json:"include_tests,omitempty" description:"Count test file changes as relevant, as analysis with include_tests does"`
+
+	Only []string `json:"only,omitempty" description:"Glob patterns (e.g. pkg/auth/**) restricting the commits listed to those touching a known subsystem"`
+}
+
+// CommitInfo describes a commit listed by list_recent_commits
+type CommitInfo struct {
+	Hash    string    `json:"hash" description:"Full commit hash"`
+	Author  string    `json:"author" description:"Author name and email"`
+	Date    time.Time `json:"date" description:"Commit date"`
+	Subject string    `json:"subject" description:"First line of the commit message"`
+
+	// Files are the paths the commit changed, at most maxListedFiles
+	Files []string `json:"files" description:"Changed paths, at most 50"`
+
+	// FileCount counts every path the commit changed
+	FileCount int `json:"file_count" description:"Number of changed paths"`
+
+	// Skip says why analysis would skip the commit without an LLM call,
+	// such as "merge commit" (empty: it would be analyzed)
+	Skip string `json:"skip,omitempty" description:"Why analysis would skip the commit without an LLM call; absent if it would be analyzed"`
+}
+
+// ListCommitsOutput represents the output of the list_recent_commits tool
+type ListCommitsOutput struct {
+	Commits []CommitInfo `json:"commits" description:"Commits, newest first"`
+}
+
+// ListRecentCommits lists the recent commits of a repository with what
+// analysis would need to know to choose among them, without extracting
+// diffs or calling the LLM. Unlike analysis, merge commits are listed, and
+// marked as skipped.
+func ListRecentCommits(ctx context.Context, input ListCommitsInput, progress *Progress) (*ListCommitsOutput, error) {
+	cfg, err := loadConfig(input.RepoPath, progress)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.NumCommits <= 0 {
+		input.NumCommits = cfg.Analysis.DefaultCommits
+	}
+	if err := validator.ValidateNumCommits(input.NumCommits); err != nil {
+		return nil, fmt.Errorf("invalid number of commits: %w", err)
+	}
+	if err := validator.ValidateRef(input.Branch); err != nil {
+		return nil, fmt.Errorf("invalid branch name: %w", err)
+	}
+
+	diffOpts, err := newDiffOptions(cfg, "", input.IncludeTests, input.Only)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, _, cleanup, err := openRepo(ctx, cfg, AnalyzeInput{RepoPath: input.RepoPath, Branch: input.Branch})
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	start, err := analyzer.ResolveCommit(repo, input.Branch)
+	if err != nil {
+		return nil, err
+	}
+	opts := analyzer.AnalysisOptions{Diff: diffOpts, FilterProfiles: cfg.Analysis.FilterProfiles}
+	if diffOpts, err = opts.DiffOptions(ctx, start); err != nil {
+		return nil, err
+	}
+
+	cIter, err := repo.Log(&git.LogOptions{From: start.Hash, PathFilter: diffOpts.Filter.CommitPathFilter()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get commit log: %w", err)
+	}
+	output := &ListCommitsOutput{Commits: []CommitInfo{}}
+	for len(output.Commits) < input.NumCommits {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		c, err := cIter.Next()
+		if err == io.EOF || analyzer.IsShallowBoundary(repo, err) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error iterating commits: %w", err)
+		}
+		info, err := describeCommit(repo, c, diffOpts)
+		if err != nil {
+			return nil, err
+		}
+		output.Commits = append(output.Commits, info)
+	}
+	return output, nil
+}
+
+// describeCommit lists c's changed paths and whether analysis would skip
+// it, judged from paths alone as analyzer.SkipForPaths does
+func describeCommit(repo *git.Repository, c *object.Commit, opts gitdiff.Options) (CommitInfo, error) {
+	subject, _, _ := strings.Cut(strings.TrimSpace(c.Message), "\n")
+	info := CommitInfo{
+		Hash:    c.Hash.String(),
+		Author:  fmt.Sprintf("%s <%s>", c.Author.Name, c.Author.Email),
+		Date:    c.Committer.When,
+		Subject: subject,
+		Files:   []string{},
+	}
+
+	var parent *object.Commit
+	if len(c.ParentHashes) > 0 {
+		var err error
+		parent, err = c.Parent(0)
+		if analyzer.IsShallowBoundary(repo, err) {
+			info.Skip = (&analyzer.SkipInfo{Reason: analyzer.SkipShallow}).String()
+			return info, nil
+		}
+		if err != nil {
+			return CommitInfo{}, fmt.Errorf("getting parent commit for %s: %w", c.Hash.String()[:8], err)
+		}
+	}
+	paths, err := gitdiff.ChangedPaths(c, parent)
+	if err != nil {
+		return CommitInfo{}, fmt.Errorf("listing changed paths of %s: %w", c.Hash.String()[:8], err)
+	}
+	info.FileCount = len(paths)
+	info.Files = paths[:min(len(paths), maxListedFiles)]
+
+	skip := analyzer.SkipForPaths(paths, opts.Filter)
+	if len(c.ParentHashes) > 1 {
+		skip = &analyzer.SkipInfo{Reason: analyzer.SkipMerge}
+	}
+	if skip != nil {
+		info.Skip = skip.String()
+	}
+	return info, nil
+}
+
+// FormatCommitsAsText formats listed commits as human-readable text
+func FormatCommitsAsText(output *ListCommitsOutput) string {
+	var sb strings.Builder
+
+	sb.WriteString("## Recent Commits\n\n")
+	if len(output.Commits) == 0 {
+		sb.WriteString("No commits found.\n")
+		return sb.String()
+	}
+	for _, c := range output.Commits {
+		sb.WriteString(fmt.Sprintf("- **%s** %s (%s, %s)\n", c.Hash[:8], c.Subject, c.Author, c.Date.Format(time.RFC3339)))
+		files := strings.Join(c.Files, ", ")
+		if c.FileCount > len(c.Files) {
+			files += fmt.Sprintf(", and %d more", c.FileCount-len(c.Files))
+		}
+		sb.WriteString(fmt.Sprintf("  - Files (%d): %s\n", c.FileCount, files))
+		if c.Skip != "" {
+			sb.WriteString(fmt.Sprintf("  - Skipped by analysis: %s\n", c.Skip))
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,77 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestListRecentCommits(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Chdir(home)
+	dir, hashes := createTestRepo(t,
+		"package main\n\nfunc main() {}\n",
+		"package main\n\nfunc main() {\n\tpanic(\"nil map\")\n}\n",
+	)
+
+	// A commit touching only a lock file is skipped by analysis
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go.sum"), []byte("example.com/x v1.0.0 h1:abc=\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Add("go.sum"); err != nil {
+		t.Fatal(err)
+	}
+	lockHash, err := w.Commit("Update go.sum\n\nBody text", &git.CommitOptions{
+		Author: &object.Signature{Name: "Test User", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := ListRecentCommits(context.Background(), ListCommitsInput{RepoPath: dir, NumCommits: 10}, nil)
+	if err != nil {
+		t.Fatalf("ListRecentCommits failed: %v", err)
+	}
+	if len(output.Commits) != 3 {
+		t.Fatalf("expected 3 commits, got %+v", output.Commits)
+	}
+
+	lock := output.Commits[0]
+	if lock.Hash != lockHash.String() || lock.Subject != "Update go.sum" {
+		t.Errorf("expected the newest commit first with its subject, got %+v", lock)
+	}
+	if lock.Skip == "" || lock.FileCount != 1 || lock.Files[0] != "go.sum" {
+		t.Errorf("expected the lock file commit marked as skipped, got %+v", lock)
+	}
+	if c := output.Commits[1]; c.Hash != hashes[1] || c.Skip != "" || c.Author != "Test User <test@example.com>" {
+		t.Errorf("expected an analyzed commit with its author, got %+v", c)
+	}
+
+	output, err = ListRecentCommits(context.Background(), ListCommitsInput{RepoPath: dir, NumCommits: 1, Branch: hashes[1]}, nil)
+	if err != nil {
+		t.Fatalf("ListRecentCommits failed: %v", err)
+	}
+	if len(output.Commits) != 1 || output.Commits[0].Hash != hashes[1] {
+		t.Errorf("expected the one commit at the branch, got %+v", output.Commits)
+	}
+
+	text := FormatCommitsAsText(output)
+	if !strings.Contains(text, hashes[1][:8]) || !strings.Contains(text, "main.go") {
+		t.Errorf("text output lacks the commit:\n%s", text)
+	}
+}
@@ -0,0 +1,184 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kerneldump/git-dual-context/pkg/analyzer"
+	"github.com/kerneldump/git-dual-context/pkg/config"
+	"github.com/kerneldump/git-dual-context/pkg/gitdiff"
+	"github.com/kerneldump/git-dual-context/pkg/validator"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// DiffInput represents the input parameters for the get_dual_context_diff
+// tool
+type DiffInput struct {
+	RepoPath     string `json:"repo_path" required:"true" description:"Path to a local git repository, or a remote URL to clone"`
+	Commit       string `json:"commit" required:"true" description:"Commit to diff: a full or abbreviated hash, or a ref with ~N/^N suffixes such as HEAD~2"`
+	HeadRef      string `json:"head_ref,omitempty" description:"Ref the evolution diff compares the commit against, e.g. the deployed tag (default: current HEAD)"`
+	ErrorMessage string `json:"error_message,omitempty" description:"Error message whose files and identifiers are kept first when the diffs must be truncated"`
+	MaxTokens    int    `json:"max_tokens,omitempty" description:"Token budget for the diffs (default: the configured budget for the model)"`
+	IncludeTests bool   `json:"include_tests,omitempty" description:"Include test files, which are left out by default"`
+
+	Only []string `json:"only,omitempty" description:"Glob patterns (e.g. pkg/auth/**) restricting the files diffed"`
+}
+
+// DiffOutput represents the output of the get_dual_context_diff tool: a
+// commit's dual context, without a verdict
+type DiffOutput struct {
+	Hash    string `json:"hash" description:"Full hash of the commit"`
+	Head    string `json:"head" description:"Full hash of the commit the evolution diff compares against"`
+	Message string `json:"message" description:"Commit message"`
+
+	// StandardDiff is the commit against its first parent: what it changed
+	StandardDiff string `json:"standard_diff" description:"The commit against its first parent: what it changed"`
+
+	// EvolutionDiff is the commit's files against the head: how the code
+	// it changed has evolved since
+	EvolutionDiff string `json:"evolution_diff" description:"The commit's files against the head: how the code it changed has evolved since"`
+
+	ModifiedFiles []string `json:"modified_files" description:"Files the commit changed, after filtering"`
+	MaxTokens     int      `json:"max_tokens" description:"Token budget the diffs were fitted to"`
+
+	// Skipped is set when the commit changed no relevant files; the diffs
+	// are then empty
+	Skipped bool `json:"skipped,omitempty" description:"Set when the commit changed no relevant files; the diffs are then empty"`
+
+	// Skip says why a skipped commit is not analyzed
+	Skip *analyzer.SkipInfo `json:"skip,omitempty" description:"Why the commit is skipped, such as tests_only, with the count of files each filter rule ignored"`
+
+	// NonFunctional explains why the commit cannot change behavior, such
+	// as "only whitespace changed" (empty: it may)
+	NonFunctional string `json:"non_functional,omitempty" description:"Why the commit cannot change behavior, such as only whitespace changed"`
+
+	Stats     *gitdiff.DiffStats    `json:"stats,omitempty" description:"Lines inserted and deleted per changed file, with totals"`
+	Symbols   []gitdiff.FileSymbols `json:"symbols,omitempty" description:"Functions, methods, and types the commit changed, per file"`
+	FollowUps []gitdiff.FollowUp    `json:"follow_ups,omitempty" description:"Later commits that revert or fix this one"`
+
+	*analyzer.CommitMetadata
+}
+
+// GetDualContextDiff extracts the dual context of one commit without the
+// LLM: its standard diff and its evolution diff to the head, fitted to a
+// token budget as they would be for analysis. Merge commits are diffed
+// against their first parent.
+func GetDualContextDiff(ctx context.Context, input DiffInput, progress *Progress) (*DiffOutput, error) {
+	cfg, err := loadConfig(input.RepoPath, progress)
+	if err != nil {
+		return nil, err
+	}
+	diffCtx, headCommit, diffOpts, err := extractCommit(ctx, cfg, input, progress)
+	if err != nil {
+		return nil, err
+	}
+	commit := diffCtx.Commit
+
+	return &DiffOutput{
+		Hash:          commit.Hash.String(),
+		Head:          headCommit.Hash.String(),
+		Message:       strings.TrimSpace(commit.Message),
+		StandardDiff:  diffCtx.StandardDiff,
+		EvolutionDiff: diffCtx.FullDiff,
+		ModifiedFiles: diffCtx.ModifiedFiles,
+		MaxTokens:     diffOpts.MaxTokens,
+		Skipped:       diffCtx.Skipped,
+		Skip:          diffCtx.Skip,
+		NonFunctional: diffCtx.NonFunctional,
+		Stats:         diffCtx.Stats,
+		Symbols:       diffCtx.Symbols,
+		FollowUps:     diffCtx.FollowUps,
+
+		CommitMetadata: diffCtx.Metadata,
+	}, nil
+}
+
+// extractCommit extracts the dual context of input's commit against its
+// head, as GetDualContextDiff returns it, and returns it with the head and
+// the options it was extracted with
+func extractCommit(ctx context.Context, cfg *config.Config, input DiffInput, progress *Progress) (*analyzer.CommitDiffContext, *object.Commit, gitdiff.Options, error) {
+	fail := func(err error) (*analyzer.CommitDiffContext, *object.Commit, gitdiff.Options, error) {
+		return nil, nil, gitdiff.Options{}, err
+	}
+	if err := validator.ValidateRevSpec(input.Commit); err != nil {
+		return fail(fmt.Errorf("invalid commit: %w", err))
+	}
+	if err := validator.ValidateRef(input.HeadRef); err != nil {
+		return fail(fmt.Errorf("invalid head ref: %w", err))
+	}
+	if input.MaxTokens < 0 {
+		return fail(fmt.Errorf("invalid max tokens: cannot be negative, got %d", input.MaxTokens))
+	}
+
+	diffOpts, err := newDiffOptions(cfg, input.ErrorMessage, input.IncludeTests, input.Only)
+	if err != nil {
+		return fail(err)
+	}
+	diffOpts.MaxTokens = input.MaxTokens
+	if diffOpts.MaxTokens == 0 {
+		diffOpts.MaxTokens = analyzer.DiffTokenBudget(cfg.LLM.Model, cfg.Analysis.MaxDiffTokens)
+	}
+	// The diffs are returned whole, truncated to the budget, never split
+	diffOpts.MaxChunks = 0
+
+	repo, repoDir, cleanup, err := openRepo(ctx, cfg, AnalyzeInput{RepoPath: input.RepoPath})
+	if err != nil {
+		return fail(err)
+	}
+	defer cleanup()
+	diffOpts.Provider, err = gitdiff.NewProvider(cfg.Analysis.DiffBackend, repoDir)
+	if err != nil {
+		return fail(fmt.Errorf("invalid diff backend: %w", err))
+	}
+
+	commit, err := analyzer.ResolveCommit(repo, input.Commit)
+	if err != nil {
+		return fail(err)
+	}
+	headCommit, err := analyzer.ResolveCommit(repo, input.HeadRef)
+	if err != nil {
+		return fail(err)
+	}
+	opts := withHeadOptions(cfg, analyzer.AnalysisOptions{Diff: diffOpts})
+	if diffOpts, err = opts.DiffOptions(ctx, headCommit); err != nil {
+		return fail(err)
+	}
+
+	progress.Logf("Extracting diffs of %s against %s", commit.Hash.String()[:8], headCommit.Hash.String()[:8])
+	diffCtx, err := analyzer.ExtractDiffsContext(ctx, repo, commit, headCommit, diffOpts)
+	if err != nil {
+		return fail(fmt.Errorf("failed to extract diffs of %s: %w", commit.Hash.String()[:8], err))
+	}
+	return diffCtx, headCommit, diffOpts, nil
+}
+
+// FormatDiffAsText formats a commit's dual context as human-readable text
+func FormatDiffAsText(output *DiffOutput) string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("## Dual Context of Commit %s\n\n", output.Hash[:8]))
+	sb.WriteString(fmt.Sprintf("**Message:** %s\n\n", output.Message))
+	if output.CommitMetadata != nil {
+		sb.WriteString(fmt.Sprintf("**Author:** %s, %s\n\n", output.Author, output.Date.Format(time.RFC3339)))
+	}
+	if output.Skipped {
+		sb.WriteString(fmt.Sprintf("No relevant code changes (%s).\n", output.Skip))
+		return sb.String()
+	}
+	if output.NonFunctional != "" {
+		sb.WriteString(fmt.Sprintf("**Non-functional:** %s\n\n", output.NonFunctional))
+	}
+	if len(output.FollowUps) > 0 {
+		sb.WriteString(fmt.Sprintf("**Later history:** %s\n\n", strings.TrimSuffix(gitdiff.FormatFollowUps(output.FollowUps), "\n")))
+	}
+
+	sb.WriteString(fmt.Sprintf("### Standard Diff (%s vs parent)\n\n", output.Hash[:8]))
+	sb.WriteString("```diff\n" + strings.TrimSuffix(output.StandardDiff, "\n") + "\n```\n\n")
+	sb.WriteString(fmt.Sprintf("### Evolution Diff (%s vs %s)\n\n", output.Hash[:8], output.Head[:8]))
+	sb.WriteString("```diff\n" + strings.TrimSuffix(output.EvolutionDiff, "\n") + "\n```\n")
+
+	return sb.String()
+}
@@ -0,0 +1,64 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestGetDualContextDiff(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Chdir(home)
+	dir, hashes := createTestRepo(t,
+		"package main\n\nfunc main() {}\n",
+		"package main\n\nfunc main() {\n\tpanic(\"nil map\")\n}\n",
+		"package main\n\nfunc main() {\n\tpanic(\"nil map: config\")\n}\n",
+	)
+
+	output, err := GetDualContextDiff(context.Background(), DiffInput{RepoPath: dir, Commit: "HEAD~1"}, nil)
+	if err != nil {
+		t.Fatalf("GetDualContextDiff failed: %v", err)
+	}
+	if output.Hash != hashes[1] || output.Head != hashes[2] {
+		t.Errorf("expected %s against %s, got %s against %s", hashes[1], hashes[2], output.Hash, output.Head)
+	}
+	if output.Message != "commit b" {
+		t.Errorf("expected the commit message, got %q", output.Message)
+	}
+	if !strings.Contains(output.StandardDiff, `+	panic("nil map")`) {
+		t.Errorf("standard diff lacks the commit's change:\n%s", output.StandardDiff)
+	}
+	if !strings.Contains(output.EvolutionDiff, `+	panic("nil map: config")`) {
+		t.Errorf("evolution diff lacks the later change:\n%s", output.EvolutionDiff)
+	}
+	if output.MaxTokens <= 0 {
+		t.Errorf("expected the default token budget, got %d", output.MaxTokens)
+	}
+
+	text := FormatDiffAsText(output)
+	for _, want := range []string{"Standard Diff", "Evolution Diff", hashes[2][:8]} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text output lacks %q:\n%s", want, text)
+		}
+	}
+
+	// The evolution diff compares against head_ref when given
+	output, err = GetDualContextDiff(context.Background(), DiffInput{RepoPath: dir, Commit: hashes[1][:8], HeadRef: hashes[1]}, nil)
+	if err != nil {
+		t.Fatalf("GetDualContextDiff failed: %v", err)
+	}
+	if strings.Contains(output.EvolutionDiff, "config") {
+		t.Errorf("evolution diff against the commit itself shows later changes:\n%s", output.EvolutionDiff)
+	}
+
+	for _, input := range []DiffInput{
+		{RepoPath: dir, Commit: ""},
+		{RepoPath: dir, Commit: "HEAD@{1}"},
+		{RepoPath: dir, Commit: "HEAD", MaxTokens: -1},
+	} {
+		if _, err := GetDualContextDiff(context.Background(), input, nil); err == nil {
+			t.Errorf("GetDualContextDiff(%+v): expected an error", input)
+		}
+	}
+}
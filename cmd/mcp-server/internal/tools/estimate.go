@@ -0,0 +1,118 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/kerneldump/git-dual-context/pkg/analyzer"
+	"github.com/kerneldump/git-dual-context/pkg/config"
+)
+
+// EstimateOutput represents the output of the estimate_analysis_cost tool
+type EstimateOutput struct {
+	Model string `json:"model" description:"LLM model the analysis would use, or heuristic offline"`
+
+	// Commits counts the commits the analysis would consider
+	Commits int `json:"commits" description:"Commits the analysis would consider"`
+
+	// Skipped counts the commits that would get a verdict without an LLM
+	// call: no relevant changes, or no functional change
+	Skipped int `json:"skipped" description:"Commits that would get a verdict without an LLM call"`
+
+	// Errors counts the commits whose diffs could not be extracted, which
+	// the analysis would report as errors
+	Errors int `json:"errors" description:"Commits whose diffs could not be extracted"`
+
+	analyzer.UsageEstimate
+
+	// CostUSD is the estimated list price of the calls; absent when the
+	// model's price is unknown, and 0 offline
+	CostUSD *float64 `json:"cost_usd,omitempty" description:"Estimated list price of the calls in US dollars; absent when the model price is unknown, 0 offline"`
+
+	// Pricing is the model's list price the cost is based on
+	Pricing *analyzer.ModelPricing `json:"pricing,omitempty" description:"Model list price per million tokens the cost is based on"`
+}
+
+// EstimateAnalysisCost estimates the LLM calls, tokens, and cost that
+// AnalyzeRootCause would spend on input, by extracting the diffs and
+// building the prompts without sending them. Prompt tokens are estimated
+// offline, and each response is assumed to be
+// analyzer.EstimatedOutputTokens long.
+func EstimateAnalysisCost(ctx context.Context, input AnalyzeInput, progress *Progress) (*EstimateOutput, error) {
+	cfg, err := loadConfig(input.RepoPath, progress)
+	if err != nil {
+		return nil, err
+	}
+	if err := prepareInput(cfg, &input); err != nil {
+		return nil, err
+	}
+
+	offline := input.Offline || cfg.LLM.Provider == config.ProviderHeuristic
+	modelName := cfg.LLM.Model
+	if offline {
+		modelName = analyzer.HeuristicModelName
+	}
+
+	diffOpts, err := newDiffOptions(cfg, input.ErrorMessage, input.IncludeTests, input.Only)
+	if err != nil {
+		return nil, err
+	}
+	diffOpts.MaxTokens = analyzer.DiffTokenBudget(modelName, cfg.Analysis.MaxDiffTokens)
+
+	target, cleanup, err := openTarget(ctx, cfg, input, &diffOpts)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	opts := withHeadOptions(cfg, analyzer.AnalysisOptions{Diff: diffOpts})
+	if diffOpts, err = opts.DiffOptions(ctx, target.head); err != nil {
+		return nil, err
+	}
+
+	output := &EstimateOutput{Model: modelName, Commits: len(target.commits)}
+	diffContexts, err := extractDiffs(ctx, cfg, target, diffOpts, input.Concurrency, progress)
+	if err != nil {
+		return nil, err
+	}
+	for _, diffCtx := range diffContexts {
+		if diffCtx == nil {
+			output.Errors++
+			continue
+		}
+		usage := analyzer.EstimateUsage(diffCtx, input.ErrorMessage)
+		if usage.Calls == 0 {
+			output.Skipped++
+		}
+		if !offline {
+			output.Add(usage)
+		}
+	}
+
+	if offline {
+		output.CostUSD = new(float64)
+	} else if pricing, ok := analyzer.LookupPricing(modelName); ok {
+		cost := pricing.Cost(output.PromptTokens, output.OutputTokens)
+		output.CostUSD = &cost
+		output.Pricing = &pricing
+	}
+	return output, nil
+}
+
+// FormatEstimateAsText formats a cost estimate as human-readable text
+func FormatEstimateAsText(output *EstimateOutput) string {
+	var sb strings.Builder
+
+	sb.WriteString("## Analysis Cost Estimate\n\n")
+	sb.WriteString(fmt.Sprintf("- **Model:** %s\n", output.Model))
+	sb.WriteString(fmt.Sprintf("- **Commits:** %d (%d without an LLM call, %d failing to extract)\n", output.Commits, output.Skipped, output.Errors))
+	sb.WriteString(fmt.Sprintf("- **LLM calls:** %d\n", output.Calls))
+	sb.WriteString(fmt.Sprintf("- **Prompt tokens:** ~%d\n", output.PromptTokens))
+	sb.WriteString(fmt.Sprintf("- **Output tokens:** ~%d\n", output.OutputTokens))
+	if output.CostUSD != nil {
+		sb.WriteString(fmt.Sprintf("- **Estimated cost:** $%.4f\n", *output.CostUSD))
+	} else {
+		sb.WriteString("- **Estimated cost:** unknown (no list price for this model)\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,57 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/kerneldump/git-dual-context/pkg/analyzer"
+)
+
+func TestEstimateAnalysisCost(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Chdir(home)
+	if err := os.WriteFile(".git-dual-context.yaml", []byte("llm:\n  model: gemini-2.5-flash\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dir, _ := createTestRepo(t,
+		"package main\n\nfunc main() {}\n",
+		"package main\n\nfunc main() {\n\tpanic(\"nil map\")\n}\n",
+		"package main\n\nfunc main() {\n\tpanic(\"nil map: config\")\n}\n",
+	)
+
+	output, err := EstimateAnalysisCost(context.Background(), AnalyzeInput{RepoPath: dir, ErrorMessage: "panic: nil map", NumCommits: 2}, nil)
+	if err != nil {
+		t.Fatalf("EstimateAnalysisCost failed: %v", err)
+	}
+	if output.Model != "gemini-2.5-flash" || output.Commits != 2 || output.Errors != 0 {
+		t.Errorf("expected 2 commits for the configured model, got %+v", output)
+	}
+	if output.Calls != 2-output.Skipped || output.Calls == 0 {
+		t.Errorf("expected a call per analyzed commit, got %+v", output)
+	}
+	if output.OutputTokens != output.Calls*analyzer.EstimatedOutputTokens || output.PromptTokens <= output.Calls {
+		t.Errorf("expected token estimates for each call, got %+v", output)
+	}
+	if output.CostUSD == nil || *output.CostUSD <= 0 || output.Pricing == nil {
+		t.Errorf("expected a cost from the model's list price, got %+v", output)
+	}
+	if text := FormatEstimateAsText(output); !strings.Contains(text, "Estimated cost:** $") {
+		t.Errorf("text output lacks the cost:\n%s", text)
+	}
+
+	// Offline analysis costs nothing
+	output, err = EstimateAnalysisCost(context.Background(), AnalyzeInput{RepoPath: dir, ErrorMessage: "panic: nil map", Offline: true}, nil)
+	if err != nil {
+		t.Fatalf("EstimateAnalysisCost failed: %v", err)
+	}
+	if output.Calls != 0 || output.CostUSD == nil || *output.CostUSD != 0 {
+		t.Errorf("expected no calls or cost offline, got %+v", output)
+	}
+
+	if _, err := EstimateAnalysisCost(context.Background(), AnalyzeInput{RepoPath: dir}, nil); err == nil {
+		t.Error("expected an error without an error message")
+	}
+}
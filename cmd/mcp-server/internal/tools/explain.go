@@ -0,0 +1,162 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/kerneldump/git-dual-context/pkg/analyzer"
+	"github.com/kerneldump/git-dual-context/pkg/audit"
+	"github.com/kerneldump/git-dual-context/pkg/config"
+)
+
+// ExplainInput represents the input parameters for the explain_commit tool
+type ExplainInput struct {
+	RepoPath     string `json:"repo_path" required:"true" description:"Path to a local git repository, or a remote URL to clone"`
+	Commit       string `json:"commit" required:"true" description:"Commit to explain: a full or abbreviated hash, or a ref with ~N/^N suffixes such as HEAD~2"`
+	HeadRef      string `json:"head_ref,omitempty" description:"Ref the evolution is explained up to, e.g. the deployed tag (default: current HEAD)"`
+	IncludeTests bool   `json:"include_tests,omitempty" description:"Include test files, which are left out by default"`
+
+	Only []string `json:"only,omitempty" description:"Glob patterns (e.g. pkg/auth/**) restricting the files explained"`
+}
+
+// ExplainOutput represents the output of the explain_commit tool
+type ExplainOutput struct {
+	Hash    string `json:"hash" description:"Full hash of the commit"`
+	Head    string `json:"head" description:"Full hash of the commit the evolution is explained up to"`
+	Message string `json:"message" description:"Commit message"`
+
+	// Summary and Evolution are the LLM's explanation, empty when the
+	// commit changed no relevant files
+	Summary   string `json:"summary,omitempty" description:"Plain-language explanation of what the commit changed, and why if known"`
+	Evolution string `json:"evolution,omitempty" description:"What became of the change by the head: kept, modified, moved, or removed"`
+
+	// Skipped is set when the commit changed no relevant files; it is
+	// then not sent to the LLM
+	Skipped bool `json:"skipped,omitempty" description:"Set when the commit changed no relevant files, which are then not explained"`
+
+	// Skip says why a skipped commit is not explained
+	Skip *analyzer.SkipInfo `json:"skip,omitempty" description:"Why the commit is skipped, such as tests_only, with the count of files each filter rule ignored"`
+
+	Model  string `json:"model" description:"LLM model that wrote the explanation"`
+	Tokens int    `json:"tokens,omitempty" description:"Prompt and output tokens spent"`
+
+	*analyzer.CommitMetadata
+}
+
+// ExplainCommit asks the LLM for a plain-language account of one commit:
+// what it changed, from its standard diff, and what became of the change
+// since, from its evolution diff to the head. Unlike AnalyzeCommit there
+// is no bug to look for; it serves questions about the code itself.
+func ExplainCommit(ctx context.Context, input ExplainInput, progress *Progress) (*ExplainOutput, error) {
+	cfg, err := loadConfig(input.RepoPath, progress)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.LLM.Provider == config.ProviderHeuristic {
+		return nil, fmt.Errorf("explaining a commit needs an LLM, but llm.provider is %s", config.ProviderHeuristic)
+	}
+
+	caller := limitsKey(ctx)
+	release, err := clientLimits.acquire(cfg.MCP, caller, true)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	apiKeys, err := cfg.APIKeys(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	diffCtx, headCommit, _, err := extractCommit(ctx, cfg, DiffInput{
+		RepoPath:     input.RepoPath,
+		Commit:       input.Commit,
+		HeadRef:      input.HeadRef,
+		IncludeTests: input.IncludeTests,
+		Only:         input.Only,
+	}, progress)
+	if err != nil {
+		return nil, err
+	}
+	output := &ExplainOutput{
+		Hash:    diffCtx.Commit.Hash.String(),
+		Head:    headCommit.Hash.String(),
+		Message: strings.TrimSpace(diffCtx.Commit.Message),
+		Skipped: diffCtx.Skipped,
+		Skip:    diffCtx.Skip,
+		Model:   cfg.LLM.Model,
+
+		CommitMetadata: diffCtx.Metadata,
+	}
+	if diffCtx.Skipped {
+		return output, nil
+	}
+
+	// One prompt has nothing to share through the context cache
+	progress.Logf("Using LLM model: %s", cfg.LLM.Model)
+	geminiOpts := geminiOptions(cfg, cfg.LLM.Model, apiKeys, progress)
+	geminiOpts.ContextCache = false
+	gemini, err := analyzer.NewGeminiModel(ctx, geminiOpts)
+	if err != nil {
+		return nil, err
+	}
+	defer gemini.Close(context.WithoutCancel(ctx))
+	var model analyzer.LLMModel = gemini
+	if cfg.Audit.Enabled {
+		auditLog, err := audit.Open(cfg.Audit.Path)
+		if err != nil {
+			return nil, err
+		}
+		defer auditLog.Close()
+		model = audit.Wrap(model, cfg.LLM.Model, auditLog)
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx, cfg.LLM.Timeout)
+	defer cancel()
+	log.Printf("Explaining commit %s with LLM", diffCtx.Commit.Hash.String()[:8])
+	var explanation *analyzer.Explanation
+	err = analyzer.WithRetryStats(reqCtx, analyzer.RetryConfig(cfg.Retry()), nil, func() error {
+		var explainErr error
+		explanation, explainErr = analyzer.ExplainCommit(reqCtx, diffCtx, model)
+		return explainErr
+	})
+	if explanation != nil {
+		output.Tokens = int(explanation.PromptTokens + explanation.OutputTokens)
+		clientLimits.spend(caller, output.Tokens)
+	}
+	if err != nil {
+		entry := analyzer.NewErrorEntry(err.Error(), diffCtx.Commit.Hash.String(), err, cfg.Output.DebugDir)
+		if entry.DebugFile != "" {
+			log.Printf("Commit %s: prompt and raw response saved to %s", diffCtx.Commit.Hash.String()[:8], entry.DebugFile)
+		}
+		return nil, fmt.Errorf("failed to explain commit %s (%s): %w", diffCtx.Commit.Hash.String()[:8], entry.ErrorKind, err)
+	}
+
+	output.Summary = explanation.Summary
+	output.Evolution = explanation.Evolution
+	return output, nil
+}
+
+// FormatExplanationAsText formats a commit's explanation as human-readable
+// text
+func FormatExplanationAsText(output *ExplainOutput) string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("## Commit %s\n\n", output.Hash[:8]))
+	sb.WriteString(fmt.Sprintf("**Message:** %s\n\n", output.Message))
+	if output.CommitMetadata != nil {
+		sb.WriteString(fmt.Sprintf("**Author:** %s, %s\n\n", output.Author, output.Date.Format(time.RFC3339)))
+	}
+	if output.Skipped {
+		sb.WriteString(fmt.Sprintf("No relevant code changes to explain (%s).\n", output.Skip))
+		return sb.String()
+	}
+	sb.WriteString("### What Changed\n\n")
+	sb.WriteString(output.Summary + "\n\n")
+	sb.WriteString(fmt.Sprintf("### Since Then (up to %s)\n\n", output.Head[:8]))
+	sb.WriteString(output.Evolution + "\n")
+	return sb.String()
+}
@@ -0,0 +1,50 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestExplainCommit(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("GEMINI_API_KEY", "")
+	t.Chdir(home)
+	dir, hashes := createTestRepo(t, "package main\n\nfunc main() {}\n")
+	input := ExplainInput{RepoPath: dir, Commit: hashes[0]}
+
+	// The explanation comes from the LLM, so a key is needed
+	if _, err := ExplainCommit(context.Background(), input, nil); err == nil || !strings.Contains(err.Error(), "API key") {
+		t.Errorf("expected an API key error, got %v", err)
+	}
+
+	if err := os.WriteFile(".git-dual-context.yaml", []byte("llm:\n  provider: heuristic\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ExplainCommit(context.Background(), input, nil); err == nil || !strings.Contains(err.Error(), "needs an LLM") {
+		t.Errorf("expected a heuristic provider error, got %v", err)
+	}
+}
+
+func TestFormatExplanationAsText(t *testing.T) {
+	output := &ExplainOutput{
+		Hash:      "1a2b3c4d5e6f",
+		Head:      "9f8e7d6c5b4a",
+		Message:   "Add retries",
+		Summary:   "Wraps the call in a retry loop.",
+		Evolution: "Unchanged since.",
+	}
+	text := FormatExplanationAsText(output)
+	for _, want := range []string{"## Commit 1a2b3c4d", "Wraps the call in a retry loop.", "### Since Then (up to 9f8e7d6c)", "Unchanged since."} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected %q in:\n%s", want, text)
+		}
+	}
+
+	output.Skipped = true
+	if text := FormatExplanationAsText(output); !strings.Contains(text, "No relevant code changes") {
+		t.Errorf("expected a skipped note, got:\n%s", text)
+	}
+}
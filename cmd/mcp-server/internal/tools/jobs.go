@@ -0,0 +1,214 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/kerneldump/git-dual-context/pkg/history"
+)
+
+// jobRetention is how long a finished job's result stays available
+const jobRetention = time.Hour
+
+// Job statuses
+const (
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+)
+
+// JobInput represents the input parameters of the get_analysis_status and
+// get_analysis_result tools
+type JobInput struct {
+	JobID string `json:"job_id" required:"true" description:"Job ID returned by start_analysis"`
+}
+
+// JobStatusOutput represents the output of the start_analysis and
+// get_analysis_status tools
+type JobStatusOutput struct {
+	JobID   string `json:"job_id" description:"Job ID to pass to get_analysis_status and get_analysis_result"`
+	Status  string `json:"status" description:"running, completed, or failed"`
+	Done    int    `json:"done" description:"Steps done: each commit extracted, then each commit analyzed"`
+	Total   int    `json:"total" description:"Steps known so far (0 until the commits are listed)"`
+	Message string `json:"message,omitempty" description:"Latest progress message, such as the last commit analyzed"`
+	Error   string `json:"error,omitempty" description:"Why the job failed"`
+	Elapsed string `json:"elapsed" description:"Time since the job started, or its duration once finished"`
+}
+
+// job is an analysis running in the background
+type job struct {
+	id      string
+	session string
+	started time.Time
+
+	mu       sync.Mutex
+	status   string
+	done     int
+	total    int
+	message  string
+	finished time.Time
+	output   *AnalyzeOutput
+	err      error
+}
+
+// statusOutput returns the job's status as reported to clients
+func (j *job) statusOutput() *JobStatusOutput {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	end := j.finished
+	if end.IsZero() {
+		end = time.Now()
+	}
+	output := &JobStatusOutput{
+		JobID:   j.id,
+		Status:  j.status,
+		Done:    j.done,
+		Total:   j.total,
+		Message: j.message,
+		Elapsed: end.Sub(j.started).Round(time.Second).String(),
+	}
+	if j.err != nil {
+		output.Error = j.err.Error()
+	}
+	return output
+}
+
+// jobManager keeps the jobs of every session, dropping finished ones
+// after jobRetention
+type jobManager struct {
+	mu   sync.Mutex
+	jobs map[string]*job
+}
+
+// analysisJobs holds the jobs started by start_analysis
+var analysisJobs = &jobManager{jobs: map[string]*job{}}
+
+// start runs analyze in the background as a new job of session
+func (m *jobManager) start(session string, analyze func(progress *Progress) (*AnalyzeOutput, error)) *job {
+	j := &job{id: history.NewRunID(), session: session, started: time.Now(), status: JobRunning}
+
+	m.mu.Lock()
+	for id, old := range m.jobs {
+		old.mu.Lock()
+		expired := !old.finished.IsZero() && time.Since(old.finished) > jobRetention
+		old.mu.Unlock()
+		if expired {
+			delete(m.jobs, id)
+		}
+	}
+	m.jobs[j.id] = j
+	m.mu.Unlock()
+
+	progress := &Progress{
+		Log: func(msg string) {
+			j.mu.Lock()
+			j.message = msg
+			j.mu.Unlock()
+		},
+		Advance: func(done, total int, msg string) {
+			j.mu.Lock()
+			j.done, j.total = done, total
+			j.mu.Unlock()
+		},
+	}
+	go func() {
+		output, err := analyze(progress)
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		j.finished = time.Now()
+		j.output, j.err = output, err
+		j.status = JobCompleted
+		if err != nil {
+			j.status = JobFailed
+			log.Printf("Job %s failed: %v", j.id, err)
+		} else {
+			log.Printf("Job %s completed in %s", j.id, j.finished.Sub(j.started).Round(time.Second))
+		}
+	}()
+	return j
+}
+
+// get returns session's job id; the jobs of other sessions are not found
+func (m *jobManager) get(session, id string) (*job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok || j.session != session {
+		return nil, fmt.Errorf("job %s not found: it may have expired %s after finishing", id, jobRetention)
+	}
+	return j, nil
+}
+
+// StartAnalysis starts AnalyzeRootCause on input in the background and
+// returns at once with the job's ID, for analyses longer than clients
+// wait for a tool call. Invalid input fails here rather than in the job.
+// The job keeps the session and client of ctx, and so its limits, but not
+// its cancellation.
+func StartAnalysis(ctx context.Context, input AnalyzeInput) (*JobStatusOutput, error) {
+	cfg, err := loadConfig(input.RepoPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	checked := input
+	if err := prepareInput(cfg, &checked); err != nil {
+		return nil, err
+	}
+
+	jobCtx := context.WithoutCancel(ctx)
+	j := analysisJobs.start(sessionFrom(ctx), func(progress *Progress) (*AnalyzeOutput, error) {
+		return AnalyzeRootCause(jobCtx, input, progress)
+	})
+	log.Printf("Job %s started: analyzing %s", j.id, input.RepoPath)
+	return j.statusOutput(), nil
+}
+
+// GetAnalysisStatus reports the progress of a job started by
+// StartAnalysis in the session of ctx
+func GetAnalysisStatus(ctx context.Context, input JobInput) (*JobStatusOutput, error) {
+	j, err := analysisJobs.get(sessionFrom(ctx), input.JobID)
+	if err != nil {
+		return nil, err
+	}
+	return j.statusOutput(), nil
+}
+
+// GetAnalysisResult returns the output of a completed job started by
+// StartAnalysis in the session of ctx, the error of a failed one, or an
+// error saying a running one is not done yet
+func GetAnalysisResult(ctx context.Context, input JobInput) (*AnalyzeOutput, error) {
+	j, err := analysisJobs.get(sessionFrom(ctx), input.JobID)
+	if err != nil {
+		return nil, err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	switch j.status {
+	case JobRunning:
+		return nil, fmt.Errorf("job %s is still running (%d of %d steps done); poll get_analysis_status until it completes", j.id, j.done, j.total)
+	case JobFailed:
+		return nil, fmt.Errorf("job %s failed: %w", j.id, j.err)
+	}
+	return j.output, nil
+}
+
+// FormatJobStatusAsText formats a job's status as human-readable text
+func FormatJobStatusAsText(output *JobStatusOutput) string {
+	text := fmt.Sprintf("Job %s: %s", output.JobID, output.Status)
+	if output.Total > 0 {
+		text += fmt.Sprintf(", %d of %d steps done (%d%%)", output.Done, output.Total, output.Done*100/output.Total)
+	}
+	text += fmt.Sprintf(", %s elapsed\n", output.Elapsed)
+	if output.Message != "" {
+		text += fmt.Sprintf("\nLatest: %s\n", output.Message)
+	}
+	if output.Error != "" {
+		text += fmt.Sprintf("\nError: %s\n", output.Error)
+	}
+	if output.Status == JobRunning {
+		text += "\nPoll get_analysis_status until the job completes, then call get_analysis_result.\n"
+	}
+	return text
+}
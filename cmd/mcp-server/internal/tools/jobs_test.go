@@ -0,0 +1,102 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAnalysisJob(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Chdir(home)
+	dir, _ := createTestRepo(t, "package main\n", "package main\n\nfunc main() {}\n")
+
+	// Invalid input fails at once, without starting a job
+	if _, err := StartAnalysis(context.Background(), AnalyzeInput{RepoPath: dir}); err == nil {
+		t.Error("expected an error for a missing error message")
+	}
+
+	ctx := WithSession(context.Background(), "a")
+	ctx, cancel := context.WithCancel(ctx)
+	started, err := StartAnalysis(ctx, AnalyzeInput{RepoPath: dir, ErrorMessage: "boom", NumCommits: 2, Offline: true})
+	if err != nil {
+		t.Fatalf("StartAnalysis failed: %v", err)
+	}
+	// The job outlives the call that started it
+	cancel()
+	if started.JobID == "" || started.Status != JobRunning {
+		t.Errorf("expected a running job, got %+v", started)
+	}
+
+	ctx = WithSession(context.Background(), "a")
+	input := JobInput{JobID: started.JobID}
+	var status *JobStatusOutput
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if status, err = GetAnalysisStatus(ctx, input); err != nil {
+			t.Fatalf("GetAnalysisStatus failed: %v", err)
+		}
+		if status.Status != JobRunning {
+			break
+		}
+		if _, err := GetAnalysisResult(ctx, input); err == nil || !strings.Contains(err.Error(), "still running") {
+			t.Errorf("expected a still running error, got %v", err)
+		}
+	}
+	if status.Status != JobCompleted || status.Done != 4 || status.Total != 4 {
+		t.Fatalf("expected a completed job of 4 steps, got %+v", status)
+	}
+	if text := FormatJobStatusAsText(status); !strings.Contains(text, "completed, 4 of 4 steps done (100%)") {
+		t.Errorf("unexpected status text:\n%s", text)
+	}
+
+	output, err := GetAnalysisResult(ctx, input)
+	if err != nil {
+		t.Fatalf("GetAnalysisResult failed: %v", err)
+	}
+	if output.Summary.Total != 2 {
+		t.Errorf("expected 2 commits analyzed, got %d", output.Summary.Total)
+	}
+
+	// Other sessions cannot see the job
+	other := WithSession(context.Background(), "b")
+	if _, err := GetAnalysisStatus(other, input); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("expected a not found error for another session, got %v", err)
+	}
+	if _, err := GetAnalysisResult(ctx, JobInput{JobID: "missing"}); err == nil {
+		t.Error("expected an error for an unknown job")
+	}
+}
+
+func TestAnalysisJobFailed(t *testing.T) {
+	m := &jobManager{jobs: map[string]*job{}}
+	done := make(chan struct{})
+	j := m.start("a", func(progress *Progress) (*AnalyzeOutput, error) {
+		defer close(done)
+		progress.AddSteps(2)
+		progress.Step("Commit abc: error (timeout)")
+		return nil, context.DeadlineExceeded
+	})
+	<-done
+	for j.statusOutput().Status == JobRunning {
+		time.Sleep(time.Millisecond)
+	}
+
+	status := j.statusOutput()
+	if status.Status != JobFailed || status.Done != 1 || status.Total != 2 || status.Message != "Commit abc: error (timeout)" {
+		t.Errorf("unexpected status: %+v", status)
+	}
+	if !strings.Contains(status.Error, "deadline exceeded") {
+		t.Errorf("expected the job's error, got %q", status.Error)
+	}
+
+	// Finished jobs expire after jobRetention
+	j.mu.Lock()
+	j.finished = j.finished.Add(-jobRetention - time.Minute)
+	j.mu.Unlock()
+	m.start("a", func(*Progress) (*AnalyzeOutput, error) { return &AnalyzeOutput{}, nil })
+	if _, err := m.get("a", j.id); err == nil {
+		t.Error("expected the expired job to be dropped")
+	}
+}
@@ -0,0 +1,131 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kerneldump/git-dual-context/pkg/config"
+)
+
+// ErrQuotaExceeded is the error of commits left unanalyzed because the
+// client spent its daily token quota (mcp.daily_token_limit)
+var ErrQuotaExceeded = errors.New("daily token quota spent, commit not analyzed")
+
+// sessionKey is the context key of the MCP session a call belongs to
+type sessionKey struct{}
+
+// WithSession returns ctx for a tool call of the MCP session id, which
+// owns the jobs the call starts
+func WithSession(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, sessionKey{}, id)
+}
+
+// sessionFrom returns the session of a tool call ("" for calls made
+// without WithSession)
+func sessionFrom(ctx context.Context) string {
+	id, _ := ctx.Value(sessionKey{}).(string)
+	return id
+}
+
+// clientKey is the context key of the client a call comes from
+type clientKey struct{}
+
+// WithClient returns ctx for the tool calls of client, such as the remote
+// address of an HTTP connection. Every session of a client shares the
+// limits of the mcp config section, so reconnecting does not reset them.
+func WithClient(ctx context.Context, client string) context.Context {
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+// limitsKey returns whose limits a tool call counts against: its
+// client's, or for calls without WithClient, such as over stdio, its
+// session's
+func limitsKey(ctx context.Context) string {
+	if client, _ := ctx.Value(clientKey{}).(string); client != "" {
+		return "client " + client
+	}
+	return "session " + sessionFrom(ctx)
+}
+
+// clientLimits tracks the limits of every client of the server
+var clientLimits = newLimits()
+
+// limits counts each client's running analyses and the tokens it spent
+// today, in UTC, by limitsKey
+type limits struct {
+	mu     sync.Mutex
+	active map[string]int
+	spent  map[string]int
+	day    string
+
+	// now returns the current time; tests replace it
+	now func() time.Time
+}
+
+func newLimits() *limits {
+	return &limits{active: map[string]int{}, spent: map[string]int{}, now: time.Now}
+}
+
+// resetDay forgets the tokens spent on earlier days; l.mu must be held
+func (l *limits) resetDay() {
+	day := l.now().UTC().Format(time.DateOnly)
+	if day != l.day {
+		l.day = day
+		clear(l.spent)
+	}
+}
+
+// acquire starts an analysis for client, or fails when the client
+// already runs cfg.MaxConcurrentAnalyses of them, or when an analysis
+// calling the LLM finds its daily token quota spent. The returned
+// function ends the analysis.
+func (l *limits) acquire(cfg config.MCPConfig, client string, usesTokens bool) (func(), error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if cfg.MaxConcurrentAnalyses > 0 && l.active[client] >= cfg.MaxConcurrentAnalyses {
+		return nil, fmt.Errorf("too many concurrent analyses: this client already runs %d (mcp.max_concurrent_analyses); wait for one to finish", l.active[client])
+	}
+	l.resetDay()
+	if usesTokens && cfg.DailyTokenLimit > 0 && l.spent[client] >= cfg.DailyTokenLimit {
+		return nil, fmt.Errorf("daily token quota spent: this client used %d of %d tokens today (mcp.daily_token_limit); it resets at midnight UTC, or use offline mode", l.spent[client], cfg.DailyTokenLimit)
+	}
+
+	l.active[client]++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			if l.active[client]--; l.active[client] <= 0 {
+				delete(l.active, client)
+			}
+		})
+	}, nil
+}
+
+// exhausted reports whether client spent its daily token quota, so that
+// no more LLM calls are made for it
+func (l *limits) exhausted(cfg config.MCPConfig, client string) bool {
+	if cfg.DailyTokenLimit <= 0 {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.resetDay()
+	return l.spent[client] >= cfg.DailyTokenLimit
+}
+
+// spend records tokens spent by client today
+func (l *limits) spend(client string, tokens int) {
+	if tokens <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.resetDay()
+	l.spent[client] += tokens
+}
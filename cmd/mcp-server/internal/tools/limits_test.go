@@ -0,0 +1,115 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kerneldump/git-dual-context/pkg/config"
+)
+
+func TestLimits(t *testing.T) {
+	now := time.Date(2025, 3, 1, 23, 0, 0, 0, time.UTC)
+	l := newLimits()
+	l.now = func() time.Time { return now }
+	cfg := config.MCPConfig{MaxConcurrentAnalyses: 1, DailyTokenLimit: 1000}
+
+	release, err := l.acquire(cfg, "a", true)
+	if err != nil {
+		t.Fatalf("acquire failed: %v", err)
+	}
+	if _, err := l.acquire(cfg, "a", true); err == nil || !strings.Contains(err.Error(), "too many concurrent analyses") {
+		t.Errorf("expected a concurrency error, got %v", err)
+	}
+	// Other sessions have limits of their own
+	releaseB, err := l.acquire(cfg, "b", true)
+	if err != nil {
+		t.Fatalf("acquire for another session failed: %v", err)
+	}
+	releaseB()
+	release()
+	release() // ending an analysis twice is harmless
+	if l.active["a"] != 0 {
+		t.Errorf("expected no running analyses, got %d", l.active["a"])
+	}
+
+	l.spend("a", 600)
+	if l.exhausted(cfg, "a") {
+		t.Error("expected quota left after 600 tokens")
+	}
+	l.spend("a", 400)
+	if !l.exhausted(cfg, "a") || l.exhausted(cfg, "b") {
+		t.Error("expected only session a's quota to be spent")
+	}
+	if _, err := l.acquire(cfg, "a", true); err == nil || !strings.Contains(err.Error(), "daily token quota spent") {
+		t.Errorf("expected a quota error, got %v", err)
+	}
+	// Offline analyses spend no tokens
+	release, err = l.acquire(cfg, "a", false)
+	if err != nil {
+		t.Fatalf("offline acquire failed: %v", err)
+	}
+	release()
+
+	// The quota resets at midnight UTC
+	now = now.Add(2 * time.Hour)
+	if l.exhausted(cfg, "a") {
+		t.Error("expected the quota to reset on a new day")
+	}
+
+	if l.exhausted(config.MCPConfig{}, "a") {
+		t.Error("expected no quota without a limit")
+	}
+}
+
+func TestLimitsSharedByClient(t *testing.T) {
+	l := newLimits()
+	cfg := config.MCPConfig{DailyTokenLimit: 1000}
+	session := func(client, id string) string {
+		ctx := WithSession(context.Background(), id)
+		if client != "" {
+			ctx = WithClient(ctx, client)
+		}
+		return limitsKey(ctx)
+	}
+
+	first := session("10.0.0.1", "s1")
+	l.spend(first, 1000)
+
+	// Reconnecting gives a client a new session, but not a new quota
+	second := session("10.0.0.1", "s2")
+	if second != first || !l.exhausted(cfg, second) {
+		t.Errorf("expected a second session of the client to share its spent quota, got key %q", second)
+	}
+	if _, err := l.acquire(cfg, second, true); err == nil || !strings.Contains(err.Error(), "daily token quota spent") {
+		t.Errorf("expected the second session refused for the quota, got %v", err)
+	}
+	if l.exhausted(cfg, session("10.0.0.2", "s3")) {
+		t.Error("expected another client to have a quota of its own")
+	}
+
+	// Without a client, as over stdio, each session has its own limits
+	if session("", "s1") == session("", "s2") || session("", "s1") == first {
+		t.Error("expected sessions without a client keyed by session")
+	}
+}
+
+func TestAnalyzeRootCauseMaxCommits(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Chdir(home)
+	dir, _ := createTestRepo(t, "package main\n")
+	if err := os.WriteFile(".git-dual-context.yaml", []byte("mcp:\n  max_commits: 3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := AnalyzeRootCause(context.Background(), AnalyzeInput{RepoPath: dir, ErrorMessage: "boom", NumCommits: 4, Offline: true}, nil)
+	if err == nil || !strings.Contains(err.Error(), "mcp.max_commits") {
+		t.Errorf("expected a max commits error, got %v", err)
+	}
+	if _, err := AnalyzeRootCause(context.Background(), AnalyzeInput{RepoPath: dir, ErrorMessage: "boom", NumCommits: 3, Offline: true}, nil); err != nil {
+		t.Errorf("AnalyzeRootCause within the limit failed: %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package tools
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Progress reports the progress of a tool call to the client: messages
+// about the call, and the steps done out of a total, such as each commit
+// extracted and analyzed. Its methods may be called concurrently, and do
+// nothing on a nil *Progress.
+type Progress struct {
+	// Log receives messages about the call, such as the model used
+	Log func(msg string)
+
+	// Advance receives the steps done after each step, of total steps
+	// (0: not known yet), with a message naming the step
+	Advance func(done, total int, msg string)
+
+	mu    sync.Mutex
+	done  int
+	total int
+}
+
+// Logf sends a formatted message to Log
+func (p *Progress) Logf(format string, args ...any) {
+	if p == nil || p.Log == nil {
+		return
+	}
+	p.Log(fmt.Sprintf(format, args...))
+}
+
+// AddSteps adds n steps to the total
+func (p *Progress) AddSteps(n int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.total += n
+	p.mu.Unlock()
+}
+
+// Step marks a step done, with msg naming it, and logs msg
+func (p *Progress) Step(msg string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.done++
+	done, total := p.done, p.total
+	if p.Advance != nil {
+		// Under the lock, so that the client sees progress increase
+		p.Advance(done, total, msg)
+	}
+	p.mu.Unlock()
+	p.Logf("%s", msg)
+}
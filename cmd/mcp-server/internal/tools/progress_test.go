@@ -0,0 +1,35 @@
+package tools
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestProgress(t *testing.T) {
+	// A nil Progress discards everything
+	var none *Progress
+	none.AddSteps(1)
+	none.Step("step")
+	none.Logf("log %d", 1)
+
+	var logs []string
+	var advances [][2]int
+	p := &Progress{
+		Log: func(msg string) { logs = append(logs, msg) },
+		Advance: func(done, total int, msg string) {
+			advances = append(advances, [2]int{done, total})
+		},
+	}
+	p.AddSteps(2)
+	p.Logf("Using LLM model: %s", "m")
+	p.Step("one")
+	p.AddSteps(1)
+	p.Step("two")
+
+	if want := []string{"Using LLM model: m", "one", "two"}; !slices.Equal(logs, want) {
+		t.Errorf("logs = %q, want %q", logs, want)
+	}
+	if want := [][2]int{{1, 2}, {2, 3}}; !slices.Equal(advances, want) {
+		t.Errorf("advances = %v, want %v", advances, want)
+	}
+}
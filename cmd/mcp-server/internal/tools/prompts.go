@@ -0,0 +1,131 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PromptArgument describes an argument of a prompt template
+type PromptArgument struct {
+	Name        string
+	Description string
+	Required    bool
+}
+
+// PromptTemplate is an MCP prompt: a message that walks an agent through
+// a debugging workflow with the server's tools, filled in from the
+// arguments the user gives
+type PromptTemplate struct {
+	Name        string
+	Description string
+	Arguments   []PromptArgument
+
+	// render builds the message from the arguments, all required ones set
+	render func(args map[string]string) string
+}
+
+// Render returns the prompt's message for args. Missing required arguments
+// are an error; surrounding whitespace is trimmed from every argument.
+func (p PromptTemplate) Render(args map[string]string) (string, error) {
+	trimmed := make(map[string]string, len(args))
+	for name, value := range args {
+		trimmed[name] = strings.TrimSpace(value)
+	}
+	for _, arg := range p.Arguments {
+		if arg.Required && trimmed[arg.Name] == "" {
+			return "", fmt.Errorf("prompt %s: missing required argument %s", p.Name, arg.Name)
+		}
+	}
+	return p.render(trimmed), nil
+}
+
+// Prompts lists the prompt templates the server registers
+var Prompts = []PromptTemplate{
+	{
+		Name:        "triage_production_error",
+		Description: "Find the commit that most likely caused an error seen in production, checking the cost first and drilling into the suspects.",
+		Arguments: []PromptArgument{
+			{Name: "repo_path", Description: "Path to the local repository, or a remote URL", Required: true},
+			{Name: "error_message", Description: "The error, log line, or stack trace seen in production", Required: true},
+			{Name: "deployed_ref", Description: "Tag or commit deployed to production (default: current HEAD)"},
+			{Name: "subsystem", Description: "Glob pattern of the code the error comes from, such as pkg/auth/**"},
+		},
+		render: renderTriagePrompt,
+	},
+	{
+		Name:        "review_pr_for_bug",
+		Description: "Check whether the commits of a pull request caused a reported bug, one commit at a time.",
+		Arguments: []PromptArgument{
+			{Name: "repo_path", Description: "Path to the local repository, or a remote URL", Required: true},
+			{Name: "bug_report", Description: "The reported bug: error message, failing test, or description", Required: true},
+			{Name: "pr_branch", Description: "Branch holding the pull request's commits", Required: true},
+			{Name: "num_commits", Description: "Number of commits in the pull request (default: ask the user)"},
+		},
+		render: renderReviewPRPrompt,
+	},
+}
+
+// LookupPrompt returns the prompt template called name
+func LookupPrompt(name string) (PromptTemplate, bool) {
+	for _, p := range Prompts {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return PromptTemplate{}, false
+}
+
+func renderTriagePrompt(args map[string]string) string {
+	var sb strings.Builder
+
+	sb.WriteString("Triage this production error and find the commit that most likely caused it.\n\n")
+	sb.WriteString(fmt.Sprintf("Repository: %s\n", args["repo_path"]))
+	if args["deployed_ref"] != "" {
+		sb.WriteString(fmt.Sprintf("Deployed version: %s\n", args["deployed_ref"]))
+	}
+	if args["subsystem"] != "" {
+		sb.WriteString(fmt.Sprintf("Subsystem: %s\n", args["subsystem"]))
+	}
+	sb.WriteString(fmt.Sprintf("\nError:\n```\n%s\n```\n\n", args["error_message"]))
+
+	// Every tool call passes the same repository and, when known, the
+	// deployed version and subsystem
+	var common []string
+	common = append(common, fmt.Sprintf("repo_path=%q", args["repo_path"]))
+	if args["deployed_ref"] != "" {
+		common = append(common, fmt.Sprintf("branch=%q", args["deployed_ref"]))
+	}
+	if args["subsystem"] != "" {
+		common = append(common, fmt.Sprintf("only=[%q]", args["subsystem"]))
+	}
+	callArgs := strings.Join(common, ", ")
+
+	sb.WriteString("Work through these steps:\n\n")
+	sb.WriteString(fmt.Sprintf("1. Call `list_recent_commits` with %s and num_commits=10 to see the candidates. Commits marked as skipped cannot be the cause.\n", callArgs))
+	sb.WriteString(fmt.Sprintf("2. Call `estimate_analysis_cost` with %s, the error as error_message, and num_commits covering the candidates. Tell me the estimate and wait for my go-ahead if it is over $1.\n", callArgs))
+	sb.WriteString(fmt.Sprintf("3. Call `analyze_root_cause` with %s, the error as error_message, and the same num_commits. Pass the error verbatim, stack trace included.\n", callArgs))
+	sb.WriteString("4. For each HIGH or MEDIUM commit, call `get_dual_context_diff` and check the reasoning against the diffs yourself; use `analyze_commit` to re-check a commit against more of the error if needed.\n")
+	sb.WriteString("5. Report the most likely culprit with its hash, author, and the lines that cause the error, how confident you are and why, and whether to revert it or fix forward. If no commit is rated HIGH, say so, and suggest widening num_commits or the subsystem.\n")
+	return sb.String()
+}
+
+func renderReviewPRPrompt(args map[string]string) string {
+	var sb strings.Builder
+
+	sb.WriteString("Review this pull request for the bug reported against it.\n\n")
+	sb.WriteString(fmt.Sprintf("Repository: %s\n", args["repo_path"]))
+	sb.WriteString(fmt.Sprintf("Pull request branch: %s\n", args["pr_branch"]))
+	sb.WriteString(fmt.Sprintf("\nBug report:\n```\n%s\n```\n\n", args["bug_report"]))
+
+	numCommits := args["num_commits"]
+	if numCommits == "" {
+		numCommits = "the number of commits in the pull request (ask me if you do not know it)"
+	}
+
+	sb.WriteString("Work through these steps:\n\n")
+	sb.WriteString(fmt.Sprintf("1. Call `list_recent_commits` with repo_path=%q, branch=%q, and num_commits set to %s, to list the pull request's commits.\n", args["repo_path"], args["pr_branch"], numCommits))
+	sb.WriteString(fmt.Sprintf("2. Call `analyze_commit` on each commit that is not skipped, with the bug report as error_message and head_ref=%q, so each commit is judged against the final state of the pull request.\n", args["pr_branch"]))
+	sb.WriteString("3. For each HIGH or MEDIUM commit, call `get_dual_context_diff` and point to the lines that cause the bug. A later commit of the pull request may already fix it: check the evolution diff.\n")
+	sb.WriteString("4. Write the review: which commit introduces the bug and where, a suggested change, and any commit you cleared with the reason. If no commit is rated HIGH, say the pull request is probably not the cause.\n")
+	return sb.String()
+}
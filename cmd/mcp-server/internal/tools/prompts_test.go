@@ -0,0 +1,61 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPrompts(t *testing.T) {
+	triage, ok := LookupPrompt("triage_production_error")
+	if !ok {
+		t.Fatal("triage_production_error not found")
+	}
+	text, err := triage.Render(map[string]string{
+		"repo_path":     "/src/app",
+		"error_message": "panic: nil map\n\tat auth.go:42",
+		"deployed_ref":  " v2.3.0 ",
+		"subsystem":     "pkg/auth/**",
+	})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	for _, want := range []string{
+		"panic: nil map\n\tat auth.go:42",
+		`repo_path="/src/app", branch="v2.3.0", only=["pkg/auth/**"]`,
+		"`list_recent_commits`", "`estimate_analysis_cost`", "`analyze_root_cause`", "`get_dual_context_diff`",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected the triage prompt to contain %q, got:\n%s", want, text)
+		}
+	}
+
+	// Optional arguments are left out of the tool calls
+	text, err = triage.Render(map[string]string{"repo_path": "/src/app", "error_message": "boom"})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if strings.Contains(text, "branch=") || strings.Contains(text, "only=") {
+		t.Errorf("expected no branch or only arguments, got:\n%s", text)
+	}
+
+	review, ok := LookupPrompt("review_pr_for_bug")
+	if !ok {
+		t.Fatal("review_pr_for_bug not found")
+	}
+	text, err = review.Render(map[string]string{"repo_path": "/src/app", "bug_report": "login fails", "pr_branch": "feature/sso", "num_commits": "3"})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	for _, want := range []string{`branch="feature/sso", and num_commits set to 3`, `head_ref="feature/sso"`, "`analyze_commit`"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected the review prompt to contain %q, got:\n%s", want, text)
+		}
+	}
+
+	if _, err := review.Render(map[string]string{"repo_path": "/src/app", "bug_report": "  "}); err == nil || !strings.Contains(err.Error(), "bug_report") {
+		t.Errorf("expected a missing bug_report error, got %v", err)
+	}
+	if _, ok := LookupPrompt("unknown"); ok {
+		t.Error("expected no unknown prompt")
+	}
+}
@@ -0,0 +1,174 @@
+package tools
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kerneldump/git-dual-context/pkg/analyzer"
+	"github.com/kerneldump/git-dual-context/pkg/config"
+	"github.com/kerneldump/git-dual-context/pkg/history"
+)
+
+// ReportURIPrefix prefixes the URIs of the MCP resources holding past
+// analysis reports: analysis://<run-id>
+const ReportURIPrefix = "analysis://"
+
+// RecentReportsURI is the URI of the MCP resource listing recent reports
+const RecentReportsURI = ReportURIPrefix + "recent"
+
+// recentReports is how many runs the recent reports resource lists
+const recentReports = 20
+
+// ReportURI returns the URI of the MCP resource holding a run's report
+func ReportURI(runID string) string {
+	return ReportURIPrefix + runID
+}
+
+// historyRepo returns the key a repository is recorded under in the
+// history database: the absolute path of a local repository, as the CLI
+// and the REST daemon record it, or the URL of a remote one
+func historyRepo(repoPath string) string {
+	if analyzer.IsRemoteURL(repoPath) {
+		return repoPath
+	}
+	if abs, err := filepath.Abs(repoPath); err == nil {
+		return abs
+	}
+	return repoPath
+}
+
+// recordRun stores a finished analysis in the history database when
+// cfg.History is enabled, and returns its run ID (empty: not recorded). A
+// failure to record is logged, not returned: the analysis itself succeeded.
+func recordRun(cfg *config.Config, input AnalyzeInput, output *AnalyzeOutput, verdicts []history.Verdict) string {
+	if !cfg.History.Enabled {
+		return ""
+	}
+	store, err := history.Open(cfg.History.Path)
+	if err != nil {
+		log.Printf("Failed to open history database: %v", err)
+		return ""
+	}
+	defer store.Close()
+
+	run := history.Run{
+		ID:           history.NewRunID(),
+		Repo:         historyRepo(input.RepoPath),
+		Branch:       input.Branch,
+		ErrorMessage: input.ErrorMessage,
+		Model:        output.Summary.Model,
+		Total:        output.Summary.Total,
+		High:         output.Summary.High,
+		Medium:       output.Summary.Medium,
+		Low:          output.Summary.Low,
+		Skipped:      output.Summary.Skipped,
+		Errors:       output.Summary.Errors,
+		Duration:     output.Summary.Duration,
+	}
+	if err := store.SaveRun(run, verdicts); err != nil {
+		log.Printf("Failed to record run in history: %v", err)
+		return ""
+	}
+	return run.ID
+}
+
+// openHistory opens the history database of the server's config, whether
+// or not new runs are recorded in it
+func openHistory() (*config.Config, *history.Store, error) {
+	cfg, err := loadServerConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	store, err := history.Open(cfg.History.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cfg, store, nil
+}
+
+// ReadReport returns the report of the past analysis at uri, such as
+// analysis://3f9c2a71b0d4e815, from the history database as markdown. Runs
+// on repositories outside the server's sandbox are not found, as if they
+// did not exist: history.ErrRunNotFound.
+func ReadReport(uri string) (string, error) {
+	id, ok := strings.CutPrefix(uri, ReportURIPrefix)
+	if !ok || id == "" {
+		return "", fmt.Errorf("invalid report URI %q: expected %s<run-id>", uri, ReportURIPrefix)
+	}
+	cfg, store, err := openHistory()
+	if err != nil {
+		return "", err
+	}
+	defer store.Close()
+
+	run, verdicts, err := store.GetRun(id)
+	if err != nil {
+		return "", err
+	}
+	if checkSandbox(cfg.MCP, run.Repo) != nil {
+		return "", history.ErrRunNotFound
+	}
+	return FormatReportAsText(run, verdicts), nil
+}
+
+// ListReports returns the most recent runs in the history database, with
+// their report URIs, as markdown. Runs on repositories outside the
+// server's sandbox are left out.
+func ListReports() (string, error) {
+	cfg, store, err := openHistory()
+	if err != nil {
+		return "", err
+	}
+	defer store.Close()
+
+	runs, err := store.ListRuns(recentReports)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString("## Recent Analysis Reports\n\n")
+	listed := 0
+	for _, run := range runs {
+		if checkSandbox(cfg.MCP, run.Repo) != nil {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("- %s: %s, %q (%d high, %d medium, %d low; %s)\n",
+			ReportURI(run.ID), run.Repo, run.ErrorMessage, run.High, run.Medium, run.Low, run.CreatedAt.Format(time.RFC3339)))
+		listed++
+	}
+	if listed == 0 {
+		sb.WriteString("No analyses recorded yet.\n")
+	}
+	return sb.String(), nil
+}
+
+// FormatReportAsText formats a past run and its verdicts as markdown
+func FormatReportAsText(run *history.Run, verdicts []history.Verdict) string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("## Analysis Report %s\n\n", run.ID))
+	sb.WriteString(fmt.Sprintf("- **Repository:** %s\n", run.Repo))
+	if run.Branch != "" {
+		sb.WriteString(fmt.Sprintf("- **Branch:** %s\n", run.Branch))
+	}
+	sb.WriteString(fmt.Sprintf("- **Error:** %s\n", run.ErrorMessage))
+	sb.WriteString(fmt.Sprintf("- **Date:** %s\n", run.CreatedAt.Format(time.RFC3339)))
+	sb.WriteString(fmt.Sprintf("- **Model:** %s\n", run.Model))
+	sb.WriteString(fmt.Sprintf("- **Duration:** %s\n", run.Duration))
+	sb.WriteString(fmt.Sprintf("- **Commits:** %d (%d high, %d medium, %d low, %d skipped, %d errors)\n\n",
+		run.Total, run.High, run.Medium, run.Low, run.Skipped, run.Errors))
+
+	for _, v := range verdicts {
+		sb.WriteString(fmt.Sprintf("### [%s] Commit %s\n\n", v.Probability, v.Commit[:min(8, len(v.Commit))]))
+		if v.Message != "" {
+			sb.WriteString(fmt.Sprintf("**Message:** %s\n\n", v.Message))
+		}
+		sb.WriteString(fmt.Sprintf("**Reasoning:** %s\n\n", v.Reasoning))
+		sb.WriteString("---\n\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,78 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/kerneldump/git-dual-context/pkg/history"
+)
+
+func TestReports(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Chdir(home)
+	dir, hashes := createTestRepo(t,
+		"package main\n\nfunc main() {}\n",
+		"package main\n\nfunc main() {\n\tpanic(\"nil map\")\n}\n",
+	)
+
+	output, err := AnalyzeRootCause(context.Background(), AnalyzeInput{
+		RepoPath:     dir,
+		ErrorMessage: "panic: nil map",
+		NumCommits:   1,
+		Offline:      true,
+	}, nil)
+	if err != nil {
+		t.Fatalf("AnalyzeRootCause failed: %v", err)
+	}
+	if output.RunID == "" {
+		t.Fatal("expected the run to be recorded in history")
+	}
+	if text := FormatResultsAsText(output); !strings.Contains(text, ReportURI(output.RunID)) {
+		t.Errorf("expected the results to name the report URI, got:\n%s", text)
+	}
+
+	report, err := ReadReport(ReportURI(output.RunID))
+	if err != nil {
+		t.Fatalf("ReadReport failed: %v", err)
+	}
+	for _, want := range []string{output.RunID, dir, "panic: nil map", "Commit " + hashes[1][:8], "**Reasoning:**"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("expected the report to contain %q, got:\n%s", want, report)
+		}
+	}
+
+	list, err := ListReports()
+	if err != nil {
+		t.Fatalf("ListReports failed: %v", err)
+	}
+	if !strings.Contains(list, ReportURI(output.RunID)) {
+		t.Errorf("expected the recent reports to list %s, got:\n%s", ReportURI(output.RunID), list)
+	}
+
+	if _, err := ReadReport(ReportURI("0123456789abcdef")); !errors.Is(err, history.ErrRunNotFound) {
+		t.Errorf("expected ErrRunNotFound for an unknown run, got %v", err)
+	}
+	if _, err := ReadReport("file:///etc/passwd"); err == nil || !strings.Contains(err.Error(), "invalid report URI") {
+		t.Errorf("expected an invalid URI error, got %v", err)
+	}
+
+	// Runs on repositories outside the sandbox are hidden
+	root := t.TempDir()
+	if err := os.WriteFile(".git-dual-context.yaml", []byte("mcp:\n  allowed_roots: ["+root+"]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadReport(ReportURI(output.RunID)); !errors.Is(err, history.ErrRunNotFound) {
+		t.Errorf("expected ErrRunNotFound outside the sandbox, got %v", err)
+	}
+	list, err = ListReports()
+	if err != nil {
+		t.Fatalf("ListReports failed: %v", err)
+	}
+	if strings.Contains(list, output.RunID) {
+		t.Errorf("expected the recent reports to leave out %s, got:\n%s", output.RunID, list)
+	}
+}
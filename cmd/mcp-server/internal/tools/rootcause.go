@@ -1,80 +1,228 @@
 package tools
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
-	"io"
 	"log"
+	"maps"
 	"os"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/kerneldump/git-dual-context/pkg/analyzer"
+	"github.com/kerneldump/git-dual-context/pkg/audit"
+	"github.com/kerneldump/git-dual-context/pkg/ci"
 	"github.com/kerneldump/git-dual-context/pkg/config"
+	"github.com/kerneldump/git-dual-context/pkg/gitdiff"
+	"github.com/kerneldump/git-dual-context/pkg/history"
+	"github.com/kerneldump/git-dual-context/pkg/issues"
 	"github.com/kerneldump/git-dual-context/pkg/validator"
 
 	"github.com/go-git/go-git/v5"
-	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
-	"github.com/google/generative-ai-go/genai"
-	"google.golang.org/api/option"
 )
 
 // AnalyzeInput represents the input parameters for the analyze_root_cause tool
 type AnalyzeInput struct {
-	RepoPath     string `json:"repo_path" required:"true" description:"Path to local git repository"`
+	RepoPath     string `json:"repo_path" required:"true" description:"Path to a local git repository, or a remote URL to clone"`
 	ErrorMessage string `json:"error_message" required:"true" description:"Bug description or error message to diagnose"`
 	NumCommits   int    `json:"num_commits,omitempty" description:"Number of recent commits to analyze (default: 5)"`
-	Branch       string `json:"branch,omitempty" description:"Branch to analyze (default: current HEAD)"`
+	Branch       string `json:"branch,omitempty" description:"Branch, tag, remote-tracking ref, or commit whose history is analyzed (default: current HEAD)"`
+	HeadRef      string `json:"head_ref,omitempty" description:"Ref to compare commits against for the macro context, e.g. the deployed tag (default: branch)"`
 	Concurrency  int    `json:"concurrency,omitempty" description:"Number of concurrent workers (default: 3)"`
+	IncludeTests bool   `json:"include_tests,omitempty" description:"Analyze test files too; use when the bug is a failing or flaky test"`
+
+	Only []string `json:"only,omitempty" description:"Glob patterns (e.g. pkg/auth/**) restricting both the files diffed and the commits considered to a known subsystem"`
+
+	Offline bool `json:"offline,omitempty" description:"Rate commits with heuristics (stack trace paths, error keywords, churn, recency) instead of the LLM, e.g. when the API is unavailable"`
+
+	// Model, Provider, and Temperature override the llm settings for
+	// one call, within what mcp.allowed_models and mcp.allowed_providers
+	// permit
+	Model       string   `json:"model,omitempty" description:"LLM model for this call, such as a cheaper one for a quick check; the server must allow it (default: the configured model)"`
+	Provider    string   `json:"provider,omitempty" description:"LLM provider for this call, such as heuristic; the server must allow it (default: the configured provider)"`
+	Temperature *float32 `json:"temperature,omitempty" description:"Sampling temperature from 0 to 1 for this call (default: the configured temperature)"`
+
+	MaxResults int    `json:"max_results,omitempty" description:"Most suspicious results to return; the others are counted in omitted and listed in the report resource (default: the server's mcp.max_results)"`
+	Reasoning  string `json:"reasoning,omitempty" description:"Length of each result's reasoning: short (one or two sentences), standard, or verbose (the whole chain of reasoning); short keeps large analyses small (default: analysis.reasoning)"`
+
+	// commit, set by AnalyzeCommit, analyzes that one commit instead of
+	// the branch's recent ones
+	commit string
+
+	// workingTree, set by AnalyzeWorkingTree, analyzes the uncommitted
+	// changes instead, only the staged ones with staged
+	workingTree bool
+	staged      bool
+}
+
+// AnalyzeCommitInput represents the input parameters for the
+// analyze_commit tool
+type AnalyzeCommitInput struct {
+	RepoPath     string `json:"repo_path" required:"true" description:"Path to a local git repository, or a remote URL to clone"`
+	ErrorMessage string `json:"error_message" required:"true" description:"Bug description or error message to diagnose"`
+	Commit       string `json:"commit" required:"true" description:"Commit to analyze: a full or abbreviated hash, or a ref with ~N/^N suffixes such as HEAD~2"`
+	HeadRef      string `json:"head_ref,omitempty" description:"Ref to compare the commit against for the macro context, e.g. the deployed tag (default: current HEAD)"`
+	IncludeTests bool   `json:"include_tests,omitempty" description:"Analyze test files too; use when the bug is a failing or flaky test"`
+
+	Offline bool `json:"offline,omitempty" description:"Rate the commit with heuristics instead of the LLM, e.g. when the API is unavailable"`
+}
+
+// AnalyzeWorkingTreeInput represents the input parameters for the
+// analyze_working_tree tool
+type AnalyzeWorkingTreeInput struct {
+	RepoPath     string `json:"repo_path" required:"true" description:"Path to a local git repository with uncommitted changes"`
+	ErrorMessage string `json:"error_message" required:"true" description:"Bug description or error message to diagnose"`
+	StagedOnly   bool   `json:"staged_only,omitempty" description:"Analyze only the changes added to the index (default: every change in the working tree, untracked files included)"`
+	IncludeTests bool   `json:"include_tests,omitempty" description:"Analyze test files too; use when the bug is a failing or flaky test"`
+
+	Offline bool `json:"offline,omitempty" description:"Rate the changes with heuristics instead of the LLM, e.g. when the API is unavailable"`
 }
 
 // CommitResult represents the analysis result for a single commit
 type CommitResult struct {
-	Hash        string `json:"hash"`
-	Message     string `json:"message"`
-	Probability string `json:"probability"`
-	Reasoning   string `json:"reasoning"`
+	Hash        string               `json:"hash" description:"Abbreviated commit hash"`
+	Message     string               `json:"message" description:"Commit message, truncated to output.commit_message_max_length"`
+	Probability string               `json:"probability" description:"Likelihood that the commit caused the bug: HIGH, MEDIUM, or LOW"`
+	Reasoning   string               `json:"reasoning" description:"Why the commit was given its probability"`
+	Stats       *gitdiff.DiffStats   `json:"stats,omitempty" description:"Lines inserted and deleted per changed file, with totals"`
+	FollowUps   []gitdiff.FollowUp   `json:"follow_ups,omitempty" description:"Later commits that revert or fix this one"`
+	Owners      []gitdiff.Owner      `json:"owners,omitempty" description:"Who to ask about the changed files, for HIGH and MEDIUM results"`
+	Hotspot     *gitdiff.Hotspot     `json:"hotspot,omitempty" description:"Churn and bug-fix history of the most fragile changed files"`
+	Tests       []string             `json:"affected_tests,omitempty" description:"Test files to run to confirm a HIGH or MEDIUM result"`
+	Heuristics  *analyzer.Heuristics `json:"heuristics,omitempty" description:"Stack trace, keyword, churn, and recency signals"`
+	Suspicion   float64              `json:"suspicion,omitempty" description:"Score from 0 to 1 blending the probability with the heuristics; results are ranked by it"`
+	DuplicateOf string               `json:"duplicate_of,omitempty" description:"Commit with an identical patch whose verdict was reused"`
+	Retries     *analyzer.RetryStats `json:"retries,omitempty" description:"LLM call attempts and failures, for verdicts that needed more than one call"`
+
+	*analyzer.CommitMetadata
 }
 
 // AnalyzeSummary represents the summary of the analysis
 type AnalyzeSummary struct {
-	Total   int `json:"total"`
-	High    int `json:"high"`
-	Medium  int `json:"medium"`
-	Low     int `json:"low"`
-			Skipped  int    `json:"skipped"`
-			Errors   int    `json:"errors"`
-			Duration string `json:"duration"`
-			Model    string `json:"model"`
+	Total   int `json:"total" description:"Commits considered"`
+	High    int `json:"high" description:"Commits rated HIGH"`
+	Medium  int `json:"medium" description:"Commits rated MEDIUM"`
+	Low     int `json:"low" description:"Commits rated LOW"`
+			Skipped  int    `json:"skipped" description:"Commits not analyzed: no relevant changes, run deadline reached, or token quota spent"`
+			Errors   int    `json:"errors" description:"Commits whose analysis failed"`
+			Duration string `json:"duration" description:"Wall-clock time of the analysis"`
+			Model    string `json:"model" description:"LLM model, or heuristic offline"`
+
+	// OverBudget counts the skipped commits the run's deadline left no
+	// time for
+	OverBudget int `json:"over_budget,omitempty" description:"Skipped commits the run deadline left no time for"`
+
+	// OverQuota counts the skipped commits the client's daily token
+	// quota left no tokens for
+	OverQuota int `json:"over_quota,omitempty" description:"Skipped commits the client token quota left no tokens for"`
+
+	// Tokens counts the prompt and output tokens the LLM calls spent
+	Tokens int `json:"tokens,omitempty" description:"Prompt and output tokens spent"`
+
+	// ErrorKinds breaks Errors down by analyzer.ErrorKind
+	ErrorKinds map[string]int `json:"error_kinds,omitempty" description:"Errors by kind, such as timeout or parse_failure"`
+
+	// SkipReasons breaks the commits skipped for their changes down by
+	// analyzer.SkipReason
+	SkipReasons map[string]int `json:"skip_reasons,omitempty" description:"Commits skipped for their changes by reason, such as tests_only or lockfiles_only; many filtered commits suggest the filters may hide the culprit"`
 		}
 
 // AnalyzeOutput represents the output of the analyze_root_cause tool
 type AnalyzeOutput struct {
-	Results []CommitResult `json:"results"`
-	Summary AnalyzeSummary `json:"summary"`
-}
+	Results []CommitResult `json:"results" description:"Analyzed commits in history order; skipped and failed commits are only counted in the summary"`
+	Summary AnalyzeSummary `json:"summary" description:"Counts and run details"`
 
-// commitWork holds the work item for concurrent processing
-type commitWork struct {
-	index  int
-	commit *object.Commit
+	// RunID identifies the run in the history database, whose report
+	// stays readable as the resource ReportURI(RunID) (empty: not
+	// recorded)
+	RunID string `json:"run_id,omitempty" description:"History run ID; the report stays readable as the resource analysis://<run_id>"`
+
+	// Omitted counts the least suspicious results left out to stay within
+	// max_results; the report lists them all
+	Omitted int `json:"omitted,omitempty" description:"Less suspicious results left out to stay within max_results; the report resource analysis://<run_id> lists them all"`
 }
 
-// commitResultInternal holds the internal result for ordering
-type commitResultInternal struct {
-	index  int
-	result *analyzer.AnalysisResult
-	commit *object.Commit
-	err    error
+// cloneCaches holds one clone cache per directory, so that concurrent calls
+// for the same remote take turns updating its clone
+var (
+	cloneCachesMu sync.Mutex
+	cloneCaches   = map[string]*analyzer.CloneCache{}
+)
+
+// openRepo opens the repository at input.RepoPath and returns it with its
+// directory. Remote URLs are fetched into clone.cache_dir when set, or
+// cloned into a temporary directory that cleanup removes.
+func openRepo(ctx context.Context, cfg *config.Config, input AnalyzeInput) (*git.Repository, string, func(), error) {
+	noop := func() {}
+	if !analyzer.IsRemoteURL(input.RepoPath) {
+		repo, err := analyzer.OpenRepository(input.RepoPath)
+		if err != nil {
+			return nil, "", noop, fmt.Errorf("failed to open git repository at %s: %w", input.RepoPath, err)
+		}
+		return repo, input.RepoPath, noop, nil
+	}
+
+	opts := analyzer.CloneOptions{
+		Depth:        cfg.Clone.Depth,
+		SingleBranch: cfg.Clone.SingleBranch,
+		Branch:       input.Branch,
+		Auth:         analyzer.RemoteAuthFromEnv(cfg.Clone.Username, cfg.Clone.SSHKey),
+	}
+	if cfg.Clone.CacheDir != "" {
+		cloneCachesMu.Lock()
+		cache := cloneCaches[cfg.Clone.CacheDir]
+		if cache == nil {
+			var err error
+			if cache, err = analyzer.NewCloneCache(cfg.Clone.CacheDir); err != nil {
+				cloneCachesMu.Unlock()
+				return nil, "", noop, fmt.Errorf("invalid clone cache: %w", err)
+			}
+			cloneCaches[cfg.Clone.CacheDir] = cache
+		}
+		cloneCachesMu.Unlock()
+		repo, dir, err := cache.Open(ctx, input.RepoPath, opts)
+		if err != nil {
+			return nil, "", noop, err
+		}
+		return repo, dir, noop, nil
+	}
+
+	dir, err := os.MkdirTemp("", "git-analysis-*")
+	if err != nil {
+		return nil, "", noop, err
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+	repo, err := analyzer.CloneRepository(ctx, dir, input.RepoPath, opts)
+	if err != nil {
+		cleanup()
+		return nil, "", noop, fmt.Errorf("failed to clone %s: %w", input.RepoPath, err)
+	}
+	return repo, dir, cleanup, nil
 }
 
-// AnalyzeRootCause performs dual-context analysis on a git repository
-func AnalyzeRootCause(ctx context.Context, input AnalyzeInput, progress func(string)) (*AnalyzeOutput, error) {
-	// Load config for defaults
-	cfg, _ := config.LoadConfig(config.FindConfigFile())
+// checkSandbox rejects repositories outside mcp.allowed_roots, and remote
+// URLs unless mcp.allow_remote is set
+func checkSandbox(sandbox config.MCPConfig, repoPath string) error {
+	if err := validator.ValidateRepoPath(repoPath); err != nil {
+		return fmt.Errorf("invalid repository path: %w", err)
+	}
+	if err := validator.ValidateRepoRoot(repoPath, sandbox.AllowedRoots); err != nil {
+		return fmt.Errorf("invalid repository path: %w", err)
+	}
+	if analyzer.IsRemoteURL(repoPath) && !sandbox.AllowRemote {
+		return fmt.Errorf("invalid repository path: remote repositories are not allowed (mcp.allow_remote is off)")
+	}
+	return nil
+}
 
+// prepareInput fills in the defaults of input from cfg and validates it
+func prepareInput(cfg *config.Config, input *AnalyzeInput) error {
 	// Apply defaults from config
 	if input.NumCommits <= 0 {
 		input.NumCommits = cfg.Analysis.DefaultCommits
@@ -85,232 +233,538 @@ func AnalyzeRootCause(ctx context.Context, input AnalyzeInput, progress func(str
 
 	// Validate inputs
 	if err := validator.ValidateErrorMessage(input.ErrorMessage); err != nil {
-		return nil, fmt.Errorf("invalid error message: %w", err)
+		return fmt.Errorf("invalid error message: %w", err)
 	}
 	if err := validator.ValidateNumCommits(input.NumCommits); err != nil {
-		return nil, fmt.Errorf("invalid number of commits: %w", err)
+		return fmt.Errorf("invalid number of commits: %w", err)
+	}
+	if cfg.MCP.MaxCommits > 0 && input.NumCommits > cfg.MCP.MaxCommits {
+		return fmt.Errorf("invalid number of commits: %d exceeds the server's limit of %d (mcp.max_commits)", input.NumCommits, cfg.MCP.MaxCommits)
 	}
 	if err := validator.ValidateNumWorkers(input.Concurrency); err != nil {
-		return nil, fmt.Errorf("invalid concurrency value: %w", err)
+		return fmt.Errorf("invalid concurrency value: %w", err)
 	}
-	if err := validator.ValidateBranchName(input.Branch); err != nil {
-		return nil, fmt.Errorf("invalid branch name: %w", err)
+	if input.MaxResults < 0 {
+		return fmt.Errorf("invalid max results: cannot be negative, got %d", input.MaxResults)
 	}
-	if err := validator.ValidateRepoPath(input.RepoPath); err != nil {
-		return nil, fmt.Errorf("invalid repository path: %w", err)
+	if input.Reasoning != "" {
+		verbosity, err := analyzer.ParseVerbosity(input.Reasoning)
+		if err != nil {
+			return fmt.Errorf("invalid reasoning: %w", err)
+		}
+		cfg.Analysis.Reasoning = string(verbosity)
 	}
-
-	// Get API key from environment
-	apiKey := os.Getenv("GEMINI_API_KEY")
-	if apiKey == "" {
-		return nil, fmt.Errorf("GEMINI_API_KEY environment variable is required")
+	if err := validator.ValidateRef(input.Branch); err != nil {
+		return fmt.Errorf("invalid branch name: %w", err)
+	}
+	if err := validator.ValidateRef(input.HeadRef); err != nil {
+		return fmt.Errorf("invalid head ref: %w", err)
+	}
+	if input.commit != "" {
+		if err := validator.ValidateRevSpec(input.commit); err != nil {
+			return fmt.Errorf("invalid commit: %w", err)
+		}
 	}
+	return applyLLMOverrides(cfg, *input)
+}
 
-	// Get model from environment or use config default
-	modelName := os.Getenv("GEMINI_MODEL")
-	if modelName == "" {
-		modelName = cfg.LLM.Model
+// applyLLMOverrides sets the model, provider, and temperature input asks
+// for in cfg. A model or provider other than the configured one must be
+// listed in mcp.allowed_models or mcp.allowed_providers.
+func applyLLMOverrides(cfg *config.Config, input AnalyzeInput) error {
+	if input.Model != "" && input.Model != cfg.LLM.Model {
+		if !slices.Contains(cfg.MCP.AllowedModels, input.Model) {
+			return fmt.Errorf("invalid model: %s is not allowed; use one of %s (mcp.allowed_models)", input.Model, strings.Join(append([]string{cfg.LLM.Model}, cfg.MCP.AllowedModels...), ", "))
+		}
+		cfg.LLM.Model = input.Model
+	}
+	if input.Provider != "" && input.Provider != cfg.LLM.Provider {
+		if !slices.Contains(cfg.MCP.AllowedProviders, input.Provider) {
+			return fmt.Errorf("invalid provider: %s is not allowed; use one of %s (mcp.allowed_providers)", input.Provider, strings.Join(append([]string{cfg.LLM.Provider}, cfg.MCP.AllowedProviders...), ", "))
+		}
+		cfg.LLM.Provider = input.Provider
+	}
+	if input.Temperature != nil {
+		if t := *input.Temperature; t < 0 || t > 1 {
+			return fmt.Errorf("invalid temperature: must be between 0 and 1, got %g", t)
+		}
+		cfg.LLM.Temperature = *input.Temperature
 	}
+	return nil
+}
+
+// serverConfig is the server's own config as last set by SetServerConfig
+var serverConfig atomic.Pointer[config.Config]
+
+// SetServerConfig makes new tool calls start from cfg, the server's own
+// validated config, instead of reading the config file on each call. The
+// server sets it at startup and again for each valid change to the file,
+// so calls keep the last valid config while the file is broken.
+func SetServerConfig(cfg *config.Config) {
+	serverConfig.Store(cfg)
+}
 
-	// Open the repository
-	repo, err := git.PlainOpen(input.RepoPath)
+// loadServerConfig returns a copy of the server's own config, without any
+// repository's settings: the one set by SetServerConfig, or else the
+// config file's
+func loadServerConfig() (*config.Config, error) {
+	if cfg := serverConfig.Load(); cfg != nil {
+		c := *cfg
+		return &c, nil
+	}
+	cfg, _, err := config.Load(config.FindConfigFile(), "", "")
 	if err != nil {
-		return nil, fmt.Errorf("failed to open git repository at %s: %w", input.RepoPath, err)
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
 	}
+	return cfg, nil
+}
 
-	// Get HEAD reference (or specified branch)
-	var headRef *plumbing.Reference
-	if input.Branch != "" {
-		refName := plumbing.NewBranchReferenceName(input.Branch)
-		headRef, err = repo.Reference(refName, true)
-		if err != nil {
-			return nil, fmt.Errorf("failed to find branch %s: %w", input.Branch, err)
-		}
-	} else {
-		headRef, err = repo.Head()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get HEAD: %w", err)
-		}
+// loadConfig loads the config of a tool call on repoPath. It checks that
+// the repository is one the server may open before reading anything from
+// it, then applies the repository's own analysis settings.
+func loadConfig(repoPath string, progress *Progress) (*config.Config, error) {
+	cfg, err := loadServerConfig()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSandbox(cfg.MCP, repoPath); err != nil {
+		return nil, err
+	}
+	if err := validator.ValidateRepoHost(repoPath, cfg.Clone.AllowedHosts, cfg.Clone.DeniedHosts); err != nil {
+		return nil, fmt.Errorf("invalid repository path: %w", err)
 	}
 
-	// Get HEAD commit for comparison
-	headCommit, err := repo.CommitObject(headRef.Hash())
+	if analyzer.IsRemoteURL(repoPath) {
+		return cfg, nil
+	}
+	repoConfig := config.FindRepoConfigFile(repoPath)
+	if repoConfig == "" || config.SameFile(config.FindConfigFile(), repoConfig) {
+		return cfg, nil
+	}
+	cfg, ignoredSections, err := cfg.WithRepoConfig(repoConfig, "")
 	if err != nil {
-		return nil, fmt.Errorf("failed to get HEAD commit: %w", err)
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+	if len(ignoredSections) > 0 {
+		progress.Logf("Repository config %s may only set analysis settings; ignoring %s", repoConfig, strings.Join(ignoredSections, ", "))
 	}
+	return cfg, nil
+}
 
-	// Initialize Gemini client
-	client, err := genai.NewClient(ctx, option.WithAPIKey(apiKey))
+// newDiffOptions returns the diff extraction options of cfg for a call
+// about errorMsg, with the call's test file and path settings. MaxTokens
+// and Provider are left for the caller.
+func newDiffOptions(cfg *config.Config, errorMsg string, includeTests bool, only []string) (gitdiff.Options, error) {
+	filter, err := gitdiff.NewFilter(cfg.Analysis.IncludeFiles, cfg.Analysis.FileFilters)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create Gemini client: %w", err)
+		return gitdiff.Options{}, fmt.Errorf("invalid file filter: %w", err)
+	}
+	filter.IncludeTests = cfg.Analysis.IncludeTests || includeTests
+	if err := filter.SetOnly(only); err != nil {
+		return gitdiff.Options{}, fmt.Errorf("invalid only pattern: %w", err)
 	}
-	defer client.Close()
+	return gitdiff.Options{
+		Filter:          filter,
+		ContextLines:    cfg.Analysis.ContextLines,
+		FunctionContext: cfg.Analysis.FunctionContext,
+		ErrorMessage:    errorMsg,
+		MaxChunks:       cfg.Analysis.MaxChunks,
+		MinChangedLines: cfg.Analysis.MinChangedLines,
+
+		MaxDiffSize:          cfg.Analysis.MaxDiffSize,
+		MaxFullDiffSize:      cfg.Analysis.MaxFullDiffSize,
+		MaxPromptDiffSize:    cfg.Analysis.MaxPromptDiffSize,
+		AnalyzeNonFunctional: cfg.Analysis.AnalyzeNonFunctional,
+		SemanticDiff:         cfg.Analysis.SemanticDiff,
+		DropIrrelevantHunks:  cfg.Analysis.DropIrrelevantHunks,
+		FullFileMaxBytes:     cfg.Analysis.FullFileMaxBytes,
+		BlameEvolution:       cfg.Analysis.BlameEvolution,
+	}, nil
+}
 
-	if progress != nil {
-		progress(fmt.Sprintf("Using LLM model: %s", modelName))
+// geminiOptions returns the settings of cfg for a Gemini model named
+// modelName calling with keys, whose rate limited keys are reported to
+// progress
+func geminiOptions(cfg *config.Config, modelName string, keys []config.ResolvedKey, progress *Progress) analyzer.GeminiOptions {
+	opts := analyzer.GeminiOptions{
+		Model:           modelName,
+		KeyRotation:     cfg.LLM.KeyRotation,
+		Temperature:     cfg.LLM.Temperature,
+		Stream:          cfg.LLM.Stream,
+		ContextCache:    cfg.LLM.ContextCache,
+		ContextCacheTTL: cfg.LLM.ContextCacheTTL,
+		Logf:            progress.Logf,
 	}
+	for _, k := range keys {
+		opts.Keys = append(opts.Keys, analyzer.APIKey(k))
+	}
+	return opts
+}
 
-	model := client.GenerativeModel(modelName)
-	model.SetTemperature(cfg.LLM.Temperature)
+// withHeadOptions returns opts with the settings of cfg it reads from the
+// head (see analyzer.AnalysisOptions.DiffOptions): the filter profiles,
+// owners, affected tests, the hotspot prior, the tech stack, related
+// files, and Go callers
+func withHeadOptions(cfg *config.Config, opts analyzer.AnalysisOptions) analyzer.AnalysisOptions {
+	opts.FilterProfiles = cfg.Analysis.FilterProfiles
+	opts.SuggestOwners = cfg.Analysis.SuggestOwners
+	opts.AffectedTests = cfg.Analysis.AffectedTests
+	opts.HotspotHistory = cfg.Analysis.HotspotHistory
+	opts.TechStack = cfg.Analysis.TechStack
+	opts.RelatedFiles = cfg.Analysis.RelatedFiles
+	opts.GoCallers = cfg.Analysis.GoCallers
+	return opts
+}
 
-	// Collect commits
-	cIter, err := repo.Log(&git.LogOptions{From: headRef.Hash()})
+// analysisTarget is the repository and commits a tool call analyzes
+type analysisTarget struct {
+	repo    *git.Repository
+	head    *object.Commit
+	commits []*object.Commit
+
+	// uncommitted marks a target whose one commit holds the working
+	// tree's changes, kept in memory (see analyzer.UncommittedCommit)
+	uncommitted bool
+}
+
+// openTarget opens the repository of input, cloning remote ones, and
+// collects the commits to analyze against the head. It sets the diff
+// backend of diffOpts to the repository's. The returned cleanup removes a
+// temporary clone.
+func openTarget(ctx context.Context, cfg *config.Config, input AnalyzeInput, diffOpts *gitdiff.Options) (*analysisTarget, func(), error) {
+	repo, repoDir, cleanup, err := openRepo(ctx, cfg, input)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get commit log: %w", err)
+		return nil, nil, err
+	}
+	fail := func(err error) (*analysisTarget, func(), error) {
+		cleanup()
+		return nil, nil, err
+	}
+	diffOpts.Provider, err = gitdiff.NewProvider(cfg.Analysis.DiffBackend, repoDir)
+	if err != nil {
+		return fail(fmt.Errorf("invalid diff backend: %w", err))
 	}
 
-	var commits []*object.Commit
-	count := 0
-	for count < input.NumCommits {
-		c, err := cIter.Next()
-		if err == io.EOF {
-			break
-		}
+	// Uncommitted changes are compared with nothing newer: the commit
+	// holding them is its own head. Only go-git can diff it, since it is
+	// not in the repository.
+	if input.workingTree {
+		c, err := analyzer.UncommittedCommit(repo, input.staged, diffOpts.Filter)
 		if err != nil {
-			return nil, fmt.Errorf("error iterating commits: %w", err)
+			return fail(fmt.Errorf("failed to read uncommitted changes: %w", err))
 		}
+		diffOpts.Provider = gitdiff.GoGitProvider{}
+		return &analysisTarget{repo: repo, head: c, commits: []*object.Commit{c}, uncommitted: true}, cleanup, nil
+	}
 
-		// Skip merge commits
-		if len(c.ParentHashes) > 1 {
-			continue
-		}
+	// Resolve HEAD (or the specified branch, tag, or commit)
+	start, err := analyzer.ResolveCommit(repo, input.Branch)
+	if err != nil {
+		return fail(err)
+	}
 
-		commits = append(commits, c)
-		count++
+	if cfg.Analysis.DeepenShallow {
+		// Without more history, analysis stops at the oldest fetched commit
+		_, _ = analyzer.DeepenShallow(ctx, repo, start, input.NumCommits+1)
 	}
 
-	if len(commits) == 0 {
-		return &AnalyzeOutput{
-			Results: []CommitResult{},
-			Summary: AnalyzeSummary{Total: 0},
-		}, nil
+	// Get the commit to compare against
+	headCommit := start
+	if input.HeadRef != "" {
+		headCommit, err = analyzer.ResolveCommit(repo, input.HeadRef)
+		if err != nil {
+			return fail(err)
+		}
 	}
 
-	startTime := time.Now()
+	commits, err := collectCommits(repo, start, diffOpts.Filter, input)
+	if err != nil {
+		return fail(err)
+	}
+	return &analysisTarget{repo: repo, head: headCommit, commits: commits}, cleanup, nil
+}
 
-	// ========================================================================
-	// TWO-PHASE ANALYSIS: Separates git operations from LLM calls
-	// Phase 1: Extract diffs sequentially (go-git is NOT thread-safe)
-	// Phase 2: Call LLM in parallel (Gemini API IS thread-safe)
-	// ========================================================================
-
-	// Phase 1: Extract all diffs sequentially
-	log.Printf("Phase 1: Extracting diffs from %d commits (sequential)", len(commits))
-	diffContexts := make([]*analyzer.CommitDiffContext, len(commits))
-
-	for i, c := range commits {
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		default:
+// extractDiffs extracts the diffs of target's commits in parallel, each
+// worker on a repository handle of its own (go-git is NOT thread-safe). A
+// commit whose extraction failed has a nil diff context. Each commit is a
+// step of progress.
+func extractDiffs(ctx context.Context, cfg *config.Config, target *analysisTarget, diffOpts gitdiff.Options, workers int, progress *Progress) ([]*analyzer.CommitDiffContext, error) {
+	commits := target.commits
+	if target.uncommitted {
+		// Other repository handles cannot see the in-memory commit
+		progress.AddSteps(1)
+		diffCtx, err := analyzer.ExtractDiffsContext(ctx, target.repo, commits[0], target.head, diffOpts)
+		progress.Step("Extracting diffs of the uncommitted changes")
+		if err != nil {
+			log.Printf("Uncommitted changes: failed to extract diffs - %v", err)
 		}
-
+		return []*analyzer.CommitDiffContext{diffCtx}, nil
+	}
+	log.Printf("Phase 1: Extracting diffs from %d commits (parallel, %d workers)", len(commits), workers)
+	pool, err := analyzer.NewRepoPool(target.repo, workers, cfg.Performance.ObjectCacheMB)
+	if err != nil {
+		return nil, err
+	}
+	progress.AddSteps(len(commits))
+	diffContexts, errs := pool.ExtractAll(ctx, commits, target.head, diffOpts, func(i int, c *object.Commit) {
 		msg := fmt.Sprintf("Extracting diffs %d/%d: %s", i+1, len(commits), c.Hash.String()[:8])
 		log.Println(msg)
-		if progress != nil {
-			progress(msg)
+		progress.Step(msg)
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	for i, err := range errs {
+		switch {
+		case err != nil:
+			log.Printf("Commit %s: failed to extract diffs - %v", commits[i].Hash.String()[:8], err)
+		case diffContexts[i].Skipped:
+			log.Printf("Commit %s: SKIPPED (%s)", commits[i].Hash.String()[:8], diffContexts[i].Skip)
 		}
+	}
+	return diffContexts, nil
+}
 
-		diffCtx, err := analyzer.ExtractDiffs(repo, c, headCommit)
+// collectCommits returns the commits to analyze: input.NumCommits from
+// start, only those touching the requested paths if any, or the one
+// commit AnalyzeCommit asked for
+func collectCommits(repo *git.Repository, start *object.Commit, filter *gitdiff.Filter, input AnalyzeInput) ([]*object.Commit, error) {
+	if input.commit != "" {
+		c, err := analyzer.ResolveCommit(repo, input.commit)
 		if err != nil {
-			log.Printf("Commit %s: failed to extract diffs - %v", c.Hash.String()[:8], err)
-			// Store nil to mark as error, will be handled in phase 2
-			diffContexts[i] = nil
-			continue
+			return nil, err
 		}
-		diffContexts[i] = diffCtx
-
-		if diffCtx.Skipped {
-			log.Printf("Commit %s: SKIPPED (no relevant changes)", c.Hash.String()[:8])
+		if len(c.ParentHashes) > 1 {
+			return nil, fmt.Errorf("commit %s is a merge commit, which is not analyzed; pass one of its parents' commits instead", c.Hash.String()[:8])
 		}
+		return []*object.Commit{c}, nil
 	}
 
-	// Phase 2: Analyze with LLM in parallel
-	log.Printf("Phase 2: Analyzing %d commits with LLM (parallel, %d workers)", len(commits), input.Concurrency)
-	results := make([]*commitResultInternal, len(commits))
+	commits, _, err := analyzer.CollectCommits(repo, analyzer.AnalysisOptions{
+		NumCommits: input.NumCommits,
+		Branch:     start.Hash.String(),
+		Diff:       gitdiff.Options{Filter: filter},
+	})
+	return commits, err
+}
 
-	// Use semaphore for concurrency control
-	sem := make(chan struct{}, input.Concurrency)
-	var wg sync.WaitGroup
+// AnalyzeCommit performs dual-context analysis of one commit, such as a
+// suspect found by AnalyzeRootCause. The output holds its verdict, or none
+// if the commit has no relevant changes; a failed analysis is an error.
+func AnalyzeCommit(ctx context.Context, input AnalyzeCommitInput, progress *Progress) (*AnalyzeOutput, error) {
+	if input.Commit == "" {
+		return nil, fmt.Errorf("invalid commit: commit cannot be empty")
+	}
+	return AnalyzeRootCause(ctx, AnalyzeInput{
+		RepoPath:     input.RepoPath,
+		ErrorMessage: input.ErrorMessage,
+		NumCommits:   1,
+		HeadRef:      input.HeadRef,
+		Concurrency:  1,
+		IncludeTests: input.IncludeTests,
+		Offline:      input.Offline,
+		commit:       input.Commit,
+	}, progress)
+}
 
-	for i, diffCtx := range diffContexts {
-		// Handle extraction errors
-		if diffCtx == nil {
-			results[i] = &commitResultInternal{
-				index:  i,
-				commit: commits[i],
-				err:    fmt.Errorf("diff extraction failed"),
-			}
-			continue
-		}
+// AnalyzeWorkingTree performs dual-context analysis of the changes not yet
+// committed in a local repository, to tell whether a change in progress,
+// such as an attempted fix, bears on the bug. The changes are analyzed as
+// one commit on top of HEAD and, not being a commit, are not recorded in
+// the history database. A failed analysis is an error.
+func AnalyzeWorkingTree(ctx context.Context, input AnalyzeWorkingTreeInput, progress *Progress) (*AnalyzeOutput, error) {
+	if analyzer.IsRemoteURL(input.RepoPath) {
+		return nil, fmt.Errorf("invalid repository path: uncommitted changes can only be read from a local repository")
+	}
+	return AnalyzeRootCause(ctx, AnalyzeInput{
+		RepoPath:     input.RepoPath,
+		ErrorMessage: input.ErrorMessage,
+		NumCommits:   1,
+		Concurrency:  1,
+		IncludeTests: input.IncludeTests,
+		Offline:      input.Offline,
+		workingTree:  true,
+		staged:       input.StagedOnly,
+	}, progress)
+}
 
-		// Skip commits with no relevant changes (already logged in phase 1)
-		if diffCtx.Skipped {
-			results[i] = &commitResultInternal{
-				index:  i,
-				commit: diffCtx.Commit,
-				result: &analyzer.AnalysisResult{Skipped: true},
-			}
-			continue
-		}
+// AnalyzeRootCause performs dual-context analysis on a git repository
+func AnalyzeRootCause(ctx context.Context, input AnalyzeInput, progress *Progress) (*AnalyzeOutput, error) {
+	cfg, err := loadConfig(input.RepoPath, progress)
+	if err != nil {
+		return nil, err
+	}
+	if err := prepareInput(cfg, &input); err != nil {
+		return nil, err
+	}
 
-		wg.Add(1)
-		sem <- struct{}{} // Acquire semaphore
+	// Count the analysis against the client's limits; only LLM calls
+	// spend its token quota
+	offline := input.Offline || cfg.LLM.Provider == config.ProviderHeuristic
+	caller := limitsKey(ctx)
+	release, err := clientLimits.acquire(cfg.MCP, caller, !offline)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// Get API key from the environment or llm.api_key_ref
+	var apiKeys []config.ResolvedKey
+	var trackers issues.Config
+	var forges ci.Config
+	if !offline {
+		if apiKeys, err = cfg.APIKeys(ctx, ""); err != nil {
+			return nil, err
+		}
+		// Issue titles and CI statuses only enrich the prompt; analysis
+		// goes on without them
+		if trackers, err = cfg.IssueTrackers(ctx); err != nil {
+			log.Printf("Issue titles unavailable: %v", err)
+		}
+		if forges, err = cfg.CIForges(ctx); err != nil {
+			log.Printf("CI statuses unavailable: %v", err)
+		}
+	}
 
-		go func(idx int, dc *analyzer.CommitDiffContext) {
-			defer wg.Done()
-			defer func() { <-sem }() // Release semaphore
+	// Get model from config, where GEMINI_MODEL and GDC_MODEL override it
+	modelName := cfg.LLM.Model
+	if offline {
+		modelName = analyzer.HeuristicModelName
+	}
 
-			// Check for cancellation
-			select {
-			case <-ctx.Done():
-				results[idx] = &commitResultInternal{
-					index:  idx,
-					commit: dc.Commit,
-					err:    ctx.Err(),
-				}
-				return
-			default:
-			}
+	diffOpts, err := newDiffOptions(cfg, input.ErrorMessage, input.IncludeTests, input.Only)
+	if err != nil {
+		return nil, err
+	}
+	diffOpts.MaxTokens = analyzer.DiffTokenBudget(modelName, cfg.Analysis.MaxDiffTokens)
 
-			msg := fmt.Sprintf("Analyzing commit %s with LLM", dc.Commit.Hash.String()[:8])
-			log.Println(msg)
-			if progress != nil {
-				progress(msg)
-			}
+	// Open the repository, cloning remote ones
+	target, cleanup, err := openTarget(ctx, cfg, input, &diffOpts)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	headCommit, commits := target.head, target.commits
 
-			// Create a context with timeout for the request
-			reqCtx, cancel := context.WithTimeout(ctx, cfg.LLM.Timeout)
-			defer cancel()
+	if len(commits) == 0 {
+		return &AnalyzeOutput{
+			Results: []CommitResult{},
+			Summary: AnalyzeSummary{Total: 0},
+		}, nil
+	}
 
-			// Perform LLM analysis with retry
-			var res *analyzer.AnalysisResult
-			err := analyzer.WithRetry(reqCtx, analyzer.DefaultRetryConfig(), func() error {
-				var analyzeErr error
-				res, analyzeErr = analyzer.AnalyzeWithDiffs(reqCtx, dc, input.ErrorMessage, model)
-				return analyzeErr
-			})
+	// Initialize Gemini client, unless commits are scored offline
+	var model analyzer.LLMModel
+	var promptCache *analyzer.ContextCache
+	if offline {
+		progress.Logf("Offline mode: rating commits with heuristics, without an LLM")
+	} else {
+		progress.Logf("Using LLM model: %s", modelName)
+		gemini, err := analyzer.NewGeminiModel(ctx, geminiOptions(cfg, modelName, apiKeys, progress))
+		if err != nil {
+			return nil, err
+		}
+		defer gemini.Close(context.WithoutCancel(ctx))
+		model, promptCache = gemini, gemini.Cache
+		diffOpts.Tokenizer = gemini.Tokenizer(ctx)
 
+		// Record every LLM interaction when auditing is enabled
+		if cfg.Audit.Enabled {
+			auditLog, err := audit.Open(cfg.Audit.Path)
 			if err != nil {
-				log.Printf("Commit %s: ERROR - %v", dc.Commit.Hash.String()[:8], err)
-			} else if res != nil {
-				resultMsg := fmt.Sprintf("Commit %s: %s probability", dc.Commit.Hash.String()[:8], res.Probability)
-				log.Println(resultMsg)
-				if progress != nil {
-					progress(resultMsg)
-				}
+				return nil, err
 			}
+			defer auditLog.Close()
+			model = audit.Wrap(model, modelName, auditLog)
+		}
+	}
 
-			results[idx] = &commitResultInternal{
-				index:  idx,
-				commit: dc.Commit,
-				result: res,
-				err:    err,
+	startTime := time.Now()
+
+	// Extract the diffs, then analyze them in parallel; the pipeline is
+	// shared with the CLI, and only reported here
+	progress.AddSteps(2 * len(commits))
+	results, err := analyzer.RunPipeline(ctx, target.repo, commits, headCommit, model, withHeadOptions(cfg, analyzer.AnalysisOptions{
+		ErrorMessage:       input.ErrorMessage,
+		Workers:            input.Concurrency,
+		Timeout:            cfg.LLM.Timeout,
+		RunTimeout:         cfg.Performance.RunTimeout,
+		Retry:              analyzer.RetryConfig(cfg.Retry()),
+		Diff:               diffOpts,
+		Offline:            offline,
+		ScoreWeights:       analyzer.ScoreWeights(cfg.Analysis.ScoreWeights),
+		Verbosity:          analyzer.Verbosity(cfg.Analysis.Reasoning),
+		Issues:             trackers,
+		CI:                 forges,
+		DedupePatches:      cfg.Analysis.DedupePatches,
+		ObjectCacheMB:      cfg.Performance.ObjectCacheMB,
+		ContextCache:       promptCache,
+		ContextCacheTokens: cfg.LLM.ContextCacheTokens,
+
+		OnProgress: func(msg string) {
+			log.Println(msg)
+			progress.Logf("%s", msg)
+		},
+		OnExtracted: func(i int, dc *analyzer.CommitDiffContext, err error) {
+			hash := commits[i].Hash.String()[:8]
+			switch {
+			case err != nil:
+				log.Printf("Commit %s: failed to extract diffs - %v", hash, err)
+				progress.Step(fmt.Sprintf("Commit %s: diff extraction failed", hash))
+			case dc.Skipped:
+				progress.Step(fmt.Sprintf("Commit %s: skipped (%s)", hash, dc.Skip))
+			default:
+				progress.Step(fmt.Sprintf("Commit %s: diffs extracted", hash))
+			}
+		},
+		// Stop calling the LLM once the client's quota is spent,
+		// possibly by another of its calls
+		BeforeAnalyze: func(i int, dc *analyzer.CommitDiffContext) error {
+			if clientLimits.exhausted(cfg.MCP, caller) {
+				return ErrQuotaExceeded
+			}
+			return nil
+		},
+		OnAnalyzed: func(r analyzer.CommitAnalysisResult) {
+			hash := r.Hash[:8]
+			if r.Result != nil {
+				clientLimits.spend(caller, int(r.Result.PromptTokens+r.Result.OutputTokens))
+			}
+			switch {
+			case errors.Is(r.Error, ErrQuotaExceeded):
+				progress.Step(fmt.Sprintf("Commit %s: not analyzed (daily token quota spent)", hash))
+			case r.Error != nil:
+				entry := analyzer.NewErrorEntry(r.Error.Error(), r.Hash, r.Error, cfg.Output.DebugDir)
+				log.Printf("Commit %s: ERROR (%s) - %s", hash, entry.ErrorKind, entry.Msg)
+				if entry.DebugFile != "" {
+					log.Printf("Commit %s: prompt and raw response saved to %s", hash, entry.DebugFile)
+				}
+				progress.Step(fmt.Sprintf("Commit %s: error (%s)", hash, entry.ErrorKind))
+			case r.Result == nil:
+				progress.Step(fmt.Sprintf("Commit %s: no result", hash))
+			case r.Result.Skipped:
+				progress.Step(fmt.Sprintf("Commit %s: skipped (%s)", hash, r.Result.Skip))
+			default:
+				resultMsg := fmt.Sprintf("Commit %s: %s probability", hash, r.Result.Probability)
+				log.Println(resultMsg)
+				progress.Step(resultMsg)
 			}
-		}(i, diffCtx)
+		},
+	}))
+	// A cancelled run returns no partial report: the client aborted it
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		log.Printf("Analysis cancelled: %v", ctxErr)
+		return nil, fmt.Errorf("analysis cancelled: %w", ctxErr)
+	}
+	if err != nil {
+		return nil, err
 	}
-
-	wg.Wait()
 	log.Printf("All commits analyzed")
 
 	// Build output
@@ -323,22 +777,48 @@ func AnalyzeRootCause(ctx context.Context, input AnalyzeInput, progress func(str
 		},
 	}
 
+	var verdicts []history.Verdict
 	for _, r := range results {
-		if r.err != nil {
-			output.Summary.Errors++
+		if input.workingTree && r.Error != nil {
+			return nil, fmt.Errorf("failed to analyze the uncommitted changes: %w", r.Error)
+		}
+		if input.commit != "" && r.Error != nil {
+			return nil, fmt.Errorf("failed to analyze commit %s: %w", r.Hash[:8], r.Error)
+		}
+		if errors.Is(r.Error, analyzer.ErrBudgetExhausted) {
+			output.Summary.Skipped++
+			output.Summary.OverBudget++
 			continue
 		}
-		if r.result == nil {
+		if errors.Is(r.Error, ErrQuotaExceeded) {
+			output.Summary.Skipped++
+			output.Summary.OverQuota++
+			continue
+		}
+		if r.Result != nil {
+			output.Summary.Tokens += int(r.Result.PromptTokens + r.Result.OutputTokens)
+		}
+		if r.Error != nil || r.Result == nil {
 			output.Summary.Errors++
+			if output.Summary.ErrorKinds == nil {
+				output.Summary.ErrorKinds = map[string]int{}
+			}
+			output.Summary.ErrorKinds[cmp.Or(analyzer.ErrorKind(r.Error), analyzer.ErrorKindOther)]++
 			continue
 		}
-		if r.result.Skipped {
+		if r.Result.Skipped {
 			output.Summary.Skipped++
+			if r.Result.Skip != nil {
+				if output.Summary.SkipReasons == nil {
+					output.Summary.SkipReasons = map[string]int{}
+				}
+				output.Summary.SkipReasons[string(r.Result.Skip.Reason)]++
+			}
 			continue
 		}
 
 		// Count by probability
-		switch r.result.Probability {
+		switch r.Result.Probability {
 		case analyzer.ProbHigh:
 			output.Summary.High++
 		case analyzer.ProbMedium:
@@ -348,16 +828,43 @@ func AnalyzeRootCause(ctx context.Context, input AnalyzeInput, progress func(str
 		}
 
 		output.Results = append(output.Results, CommitResult{
-			Hash:        r.commit.Hash.String()[:8],
-			Message:     analyzer.TruncateCommitMessage(r.commit.Message, cfg.Output.CommitMessageMaxLength),
-			Probability: string(r.result.Probability),
-			Reasoning:   r.result.Reasoning,
+			Hash:        r.Hash[:8],
+			Message:     analyzer.TruncateCommitMessage(r.Message, cfg.Output.CommitMessageMaxLength),
+			Probability: string(r.Result.Probability),
+			Reasoning:   truncateReasoning(r.Result.Reasoning, cfg.MCP.MaxReasoningLength),
+			Stats:       r.Result.Stats,
+			FollowUps:   r.Result.FollowUps,
+			Owners:      r.Result.Owners,
+			Hotspot:     r.Result.Hotspot,
+			Tests:       r.Result.AffectedTests,
+			Heuristics:  r.Result.Heuristics,
+			Suspicion:   r.Result.Suspicion,
+			DuplicateOf: r.Result.DuplicateOf[:min(8, len(r.Result.DuplicateOf))],
+			Retries:     r.Result.Retries,
+
+			CommitMetadata: r.Result.Metadata,
+		})
+		verdicts = append(verdicts, history.Verdict{
+			Commit:       r.Hash,
+			Message:      analyzer.TruncateCommitMessage(r.Message, cfg.Output.CommitMessageMaxLength),
+			Probability:  string(r.Result.Probability),
+			Reasoning:    r.Result.Reasoning,
+			PromptTokens: int(r.Result.PromptTokens),
+			OutputTokens: int(r.Result.OutputTokens),
 		})
 	}
 
+	if !input.workingTree {
+		output.RunID = recordRun(cfg, input, output, verdicts)
+	}
+	trimResults(output, cmp.Or(input.MaxResults, cfg.MCP.MaxResults))
 	return output, nil
 }
 
+// probabilityOrder lists the verdicts shown in text results, most
+// suspicious first
+var probabilityOrder = map[string]int{"HIGH": 0, "MEDIUM": 1, "LOW": 2}
+
 // FormatResultsAsText formats the analysis results as human-readable text
 func FormatResultsAsText(output *AnalyzeOutput) string {
 	var sb strings.Builder
@@ -367,16 +874,52 @@ func FormatResultsAsText(output *AnalyzeOutput) string {
 	if len(output.Results) == 0 {
 		sb.WriteString("No commits with relevant code changes found.\n\n")
 	} else {
-		// Sort by probability (HIGH first)
-		for _, prob := range []string{"HIGH", "MEDIUM", "LOW"} {
-			for _, r := range output.Results {
-				if r.Probability == prob {
-					sb.WriteString(fmt.Sprintf("### [%s] Commit %s\n", r.Probability, r.Hash))
-					sb.WriteString(fmt.Sprintf("**Message:** %s\n\n", r.Message))
-					sb.WriteString(fmt.Sprintf("**Analysis:** %s\n\n", r.Reasoning))
-					sb.WriteString("---\n\n")
+		// Sort by suspicion score, then by probability (HIGH first)
+		for _, r := range rankResults(output.Results) {
+			if _, ok := probabilityOrder[r.Probability]; !ok {
+				continue
+			}
+			header := fmt.Sprintf("### [%s] Commit %s", r.Probability, r.Hash)
+			if r.Suspicion > 0 {
+				header += fmt.Sprintf(" (suspicion %.2f)", r.Suspicion)
+			}
+			sb.WriteString(header + "\n")
+			sb.WriteString(fmt.Sprintf("**Message:** %s\n\n", r.Message))
+			if r.CommitMetadata != nil {
+				sb.WriteString(fmt.Sprintf("**Author:** %s, %s\n\n", r.Author, r.Date.Format(time.RFC3339)))
+			}
+			sb.WriteString(fmt.Sprintf("**Analysis:** %s\n\n", r.Reasoning))
+			if r.DuplicateOf != "" {
+				sb.WriteString(fmt.Sprintf("**Duplicate of:** %s (identical patch, verdict reused)\n\n", r.DuplicateOf))
+			}
+			if r.Retries != nil {
+				kinds := make([]string, len(r.Retries.Errors))
+				for i, e := range r.Retries.Errors {
+					kinds[i] = e.Kind
 				}
+				sb.WriteString(fmt.Sprintf("**Retries:** %d attempts, %s backing off (%s)\n\n", r.Retries.Attempts, time.Duration(r.Retries.BackoffMS)*time.Millisecond, strings.Join(kinds, ", ")))
 			}
+			if len(r.Owners) > 0 {
+				var owners []string
+				for _, o := range r.Owners {
+					owners = append(owners, fmt.Sprintf("%s (%s)", o.Owner, strings.Join(o.Files, ", ")))
+				}
+				sb.WriteString(fmt.Sprintf("**Owners:** %s\n\n", strings.Join(owners, "; ")))
+			}
+			if r.Hotspot != nil {
+				var files []string
+				for _, f := range r.Hotspot.Files {
+					files = append(files, fmt.Sprintf("%s (%d commits, %d fixes)", f.Path, f.Commits, f.Fixes))
+				}
+				sb.WriteString(fmt.Sprintf("**Fragile files:** %s\n\n", strings.Join(files, ", ")))
+			}
+			if len(r.Tests) > 0 {
+				sb.WriteString(fmt.Sprintf("**Tests to run:** %s\n\n", strings.Join(r.Tests, ", ")))
+			}
+			if len(r.FollowUps) > 0 {
+				sb.WriteString(fmt.Sprintf("**Later history:** %s\n", strings.TrimSuffix(gitdiff.FormatFollowUps(r.FollowUps), "\n")))
+			}
+			sb.WriteString("---\n\n")
 		}
 	}
 
@@ -388,7 +931,29 @@ func FormatResultsAsText(output *AnalyzeOutput) string {
 	sb.WriteString(fmt.Sprintf("- **Medium probability:** %d\n", output.Summary.Medium))
 	sb.WriteString(fmt.Sprintf("- **Low probability:** %d\n", output.Summary.Low))
 	sb.WriteString(fmt.Sprintf("- **Skipped (no code changes):** %d\n", output.Summary.Skipped))
+	for _, reason := range slices.Sorted(maps.Keys(output.Summary.SkipReasons)) {
+		sb.WriteString(fmt.Sprintf("  - %s: %d\n", reason, output.Summary.SkipReasons[reason]))
+	}
 	sb.WriteString(fmt.Sprintf("- **Errors:** %d\n", output.Summary.Errors))
+	for _, kind := range slices.Sorted(maps.Keys(output.Summary.ErrorKinds)) {
+		sb.WriteString(fmt.Sprintf("  - %s: %d\n", kind, output.Summary.ErrorKinds[kind]))
+	}
+	if output.Summary.OverBudget > 0 {
+		sb.WriteString(fmt.Sprintf("- **Not analyzed (run deadline reached):** %d, included in skipped\n", output.Summary.OverBudget))
+	}
+	if output.Summary.OverQuota > 0 {
+		sb.WriteString(fmt.Sprintf("- **Not analyzed (daily token quota spent):** %d, included in skipped\n", output.Summary.OverQuota))
+	}
+	if output.RunID != "" {
+		sb.WriteString(fmt.Sprintf("- **Report:** %s\n", ReportURI(output.RunID)))
+	}
+	if output.Omitted > 0 {
+		if output.RunID != "" {
+			sb.WriteString(fmt.Sprintf("- **Omitted:** %d less suspicious results; read %s for all of them\n", output.Omitted, ReportURI(output.RunID)))
+		} else {
+			sb.WriteString(fmt.Sprintf("- **Omitted:** %d less suspicious results; raise max_results to see them\n", output.Omitted))
+		}
+	}
 
 	return sb.String()
 }
@@ -1,10 +1,21 @@
 package tools
 
 import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/kerneldump/git-dual-context/pkg/analyzer"
+	"github.com/kerneldump/git-dual-context/pkg/config"
+	"github.com/kerneldump/git-dual-context/pkg/gitdiff"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
 )
 
 func TestFormatResultsAsText(t *testing.T) {
@@ -264,3 +275,321 @@ func TestFormatResultsMultipleSameProbability(t *testing.T) {
 		t.Errorf("Expected 3 [HIGH] markers, found %d", count)
 	}
 }
+
+func TestFormatResultsSuspicionOrder(t *testing.T) {
+	output := &AnalyzeOutput{
+		Results: []CommitResult{
+			{Hash: "aaa", Message: "Plain", Probability: "HIGH", Reasoning: "Reason 1", Suspicion: 0.7},
+			{Hash: "bbb", Message: "Fragile", Probability: "HIGH", Reasoning: "Reason 2", Suspicion: 0.9,
+				Hotspot: &gitdiff.Hotspot{Score: 0.9, Files: []gitdiff.FileChurn{{Path: "auth.go", Commits: 12, Fixes: 5}}}},
+			{Hash: "ccc", Message: "Low", Probability: "LOW", Reasoning: "Reason 3", Suspicion: 0.35,
+				Hotspot: &gitdiff.Hotspot{Score: 1, Files: []gitdiff.FileChurn{{Path: "db.go", Commits: 20, Fixes: 9}}}},
+			{Hash: "ddd", Message: "Medium", Probability: "MEDIUM", Reasoning: "Reason 4", Suspicion: 0.3},
+		},
+	}
+
+	text := FormatResultsAsText(output)
+
+	order := []int{strings.Index(text, "bbb"), strings.Index(text, "aaa"), strings.Index(text, "ccc"), strings.Index(text, "ddd")}
+	if !slices.IsSorted(order) {
+		t.Errorf("Expected results ordered by suspicion score across verdicts:\n%s", text)
+	}
+	if !strings.Contains(text, "### [HIGH] Commit bbb (suspicion 0.90)") {
+		t.Errorf("Expected the suspicion score in the header:\n%s", text)
+	}
+	if !strings.Contains(text, "**Fragile files:** auth.go (12 commits, 5 fixes)") {
+		t.Errorf("Expected fragile files to be listed:\n%s", text)
+	}
+	if output.Results[0].Hash != "aaa" {
+		t.Error("Formatting should not reorder the results")
+	}
+}
+
+func TestAnalyzeRootCauseInvalidConfig(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Chdir(dir)
+	if err := os.WriteFile(".git-dual-context.yaml", []byte("output:\n  log_format: xml\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := AnalyzeRootCause(context.Background(), AnalyzeInput{RepoPath: dir, ErrorMessage: "boom"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "invalid config") {
+		t.Errorf("expected the call rejected for its config, got %v", err)
+	}
+}
+
+func TestSetServerConfig(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Chdir(dir)
+	if err := os.WriteFile(".git-dual-context.yaml", []byte("output:\n  log_format: xml\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	repo := filepath.Join(dir, "src", "repo")
+	if _, err := git.PlainInit(repo, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repo, ".git-dual-context.yaml"), []byte("analysis:\n  default_commits: 30\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Calls use the config set, not the broken file
+	cfg := config.DefaultConfig()
+	cfg.MCP.AllowedRoots = []string{filepath.Join(dir, "src")}
+	SetServerConfig(cfg)
+	t.Cleanup(func() { SetServerConfig(nil) })
+
+	_, err := AnalyzeRootCause(context.Background(), AnalyzeInput{RepoPath: dir, ErrorMessage: "boom"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "outside the allowed roots") {
+		t.Errorf("Expected the set config's sandbox applied, got %v", err)
+	}
+
+	got, err := loadConfig(repo, nil)
+	if err != nil {
+		t.Fatalf("loadConfig failed: %v", err)
+	}
+	if got.Analysis.DefaultCommits != 30 || cfg.Analysis.DefaultCommits == 30 {
+		t.Errorf("Expected the repository config applied to a copy, got %d and %d commits", got.Analysis.DefaultCommits, cfg.Analysis.DefaultCommits)
+	}
+}
+
+func TestAnalyzeRootCauseSandbox(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Chdir(dir)
+	config := "mcp:\n  allowed_roots: [" + dir + "/src]\n  allow_remote: false\n"
+	if err := os.WriteFile(".git-dual-context.yaml", []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll("other", 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		repoPath string
+		want     string
+	}{
+		{"outside the roots", dir + "/other", "outside the allowed roots"},
+		{"remote", "https://github.com/org/repo.git", "remote repositories are not allowed"},
+		{"file URL outside the roots", "file://" + dir + "/other", "outside the allowed roots"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := AnalyzeRootCause(context.Background(), AnalyzeInput{RepoPath: tt.repoPath, ErrorMessage: "boom"}, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+// createTestRepo creates a repository with a commit for each of contents,
+// each writing main.go, and returns its directory and commit hashes
+func createTestRepo(t *testing.T, contents ...string) (string, []string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatalf("Failed to init repo: %v", err)
+	}
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("Failed to get worktree: %v", err)
+	}
+
+	var hashes []string
+	for i, content := range contents {
+		if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		if _, err := w.Add("main.go"); err != nil {
+			t.Fatalf("Failed to add file: %v", err)
+		}
+		hash, err := w.Commit("commit "+string(rune('a'+i)), &git.CommitOptions{
+			Author: &object.Signature{Name: "Test User", Email: "test@example.com", When: time.Now()},
+		})
+		if err != nil {
+			t.Fatalf("Failed to commit: %v", err)
+		}
+		hashes = append(hashes, hash.String())
+	}
+	return dir, hashes
+}
+
+func TestAnalyzeCommit(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Chdir(home)
+	dir, hashes := createTestRepo(t,
+		"package main\n\nfunc main() {}\n",
+		"package main\n\nfunc main() {\n\tpanic(\"nil map\")\n}\n",
+		"package main\n\nfunc main() {\n\tpanic(\"nil map\")\n}\n\nfunc helper() {}\n",
+	)
+
+	for _, commit := range []string{hashes[1], hashes[1][:8], "HEAD~1"} {
+		output, err := AnalyzeCommit(context.Background(), AnalyzeCommitInput{
+			RepoPath:     dir,
+			ErrorMessage: "panic: nil map",
+			Commit:       commit,
+			Offline:      true,
+		}, nil)
+		if err != nil {
+			t.Fatalf("AnalyzeCommit(%s) failed: %v", commit, err)
+		}
+		if output.Summary.Total != 1 || len(output.Results) != 1 {
+			t.Fatalf("AnalyzeCommit(%s): expected one result, got %+v", commit, output)
+		}
+		if output.Results[0].Hash != hashes[1][:8] {
+			t.Errorf("AnalyzeCommit(%s): expected commit %s, got %s", commit, hashes[1][:8], output.Results[0].Hash)
+		}
+	}
+
+	for commit, want := range map[string]string{
+		"":         "commit cannot be empty",
+		"HEAD@{1}": "invalid commit",
+		"deadbeef": "failed to resolve",
+	} {
+		_, err := AnalyzeCommit(context.Background(), AnalyzeCommitInput{
+			RepoPath:     dir,
+			ErrorMessage: "panic: nil map",
+			Commit:       commit,
+			Offline:      true,
+		}, nil)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("AnalyzeCommit(%q): expected %q, got %v", commit, want, err)
+		}
+	}
+}
+
+func TestAnalyzeWorkingTree(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Chdir(home)
+	dir, _ := createTestRepo(t, "package main\n\nfunc main() {}\n")
+	// The in-memory commit is diffed with go-git whatever the backend
+	if err := os.WriteFile(".git-dual-context.yaml", []byte("analysis:\n  diff_backend: git\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	input := AnalyzeWorkingTreeInput{RepoPath: dir, ErrorMessage: "panic: nil map", Offline: true}
+
+	if _, err := AnalyzeWorkingTree(context.Background(), input, nil); err == nil || !strings.Contains(err.Error(), "no uncommitted changes") {
+		t.Errorf("expected a no changes error, got %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {\n\tpanic(\"nil map\")\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	output, err := AnalyzeWorkingTree(context.Background(), input, nil)
+	if err != nil {
+		t.Fatalf("AnalyzeWorkingTree failed: %v", err)
+	}
+	if output.Summary.Total != 1 || len(output.Results) != 1 {
+		t.Fatalf("expected one result, got %+v", output)
+	}
+	if r := output.Results[0]; !strings.Contains(r.Message, "Uncommitted changes") || r.Stats == nil || r.Stats.Insertions == 0 {
+		t.Errorf("unexpected result: %+v", r)
+	}
+	if output.RunID != "" {
+		t.Errorf("expected uncommitted changes not to be recorded, got run %s", output.RunID)
+	}
+
+	// Nothing is staged
+	input.StagedOnly = true
+	if _, err := AnalyzeWorkingTree(context.Background(), input, nil); err == nil || !strings.Contains(err.Error(), "no uncommitted changes") {
+		t.Errorf("expected a no changes error for staged changes, got %v", err)
+	}
+
+	input.RepoPath = "https://example.com/repo.git"
+	if _, err := AnalyzeWorkingTree(context.Background(), input, nil); err == nil || !strings.Contains(err.Error(), "local repository") {
+		t.Errorf("expected a local repository error, got %v", err)
+	}
+}
+
+func TestAnalyzeRootCauseProgress(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Chdir(home)
+	dir, _ := createTestRepo(t,
+		"package main\n\nfunc main() {}\n",
+		"package main\n\nfunc main() {\n\tpanic(\"nil map\")\n}\n",
+		"package main\n\nfunc main() {\n\tpanic(\"nil map\")\n}\n\nfunc helper() {}\n",
+	)
+	input := AnalyzeInput{RepoPath: dir, ErrorMessage: "panic: nil map", NumCommits: 2, Offline: true}
+
+	// Each commit is a step of extraction and one of analysis
+	var steps []int
+	var lastTotal int
+	progress := &Progress{Advance: func(done, total int, msg string) {
+		steps = append(steps, done)
+		lastTotal = total
+	}}
+	if _, err := AnalyzeRootCause(context.Background(), input, progress); err != nil {
+		t.Fatalf("AnalyzeRootCause failed: %v", err)
+	}
+	if !slices.Equal(steps, []int{1, 2, 3, 4}) || lastTotal != 4 {
+		t.Errorf("expected steps 1-4 of 4, got %v of %d", steps, lastTotal)
+	}
+
+	// Cancelling mid-run aborts the analysis
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	progress = &Progress{Advance: func(done, total int, msg string) {
+		cancel()
+	}}
+	_, err := AnalyzeRootCause(ctx, input, progress)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected a cancellation error, got %v", err)
+	}
+}
+
+func TestAnalyzeRootCauseLLMOverrides(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("GEMINI_API_KEY", "")
+	t.Chdir(home)
+	dir, _ := createTestRepo(t, "package main\n", "package main\n\nfunc main() {}\n")
+	cfg := "mcp:\n  allowed_models: [gemini-2.5-pro]\n  allowed_providers: [heuristic]\n"
+	if err := os.WriteFile(".git-dual-context.yaml", []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	input := AnalyzeInput{RepoPath: dir, ErrorMessage: "boom", NumCommits: 2}
+
+	// An allowed provider applies to the call: heuristic needs no API key
+	heuristic := input
+	heuristic.Provider = "heuristic"
+	output, err := AnalyzeRootCause(context.Background(), heuristic, nil)
+	if err != nil {
+		t.Fatalf("AnalyzeRootCause with the heuristic provider failed: %v", err)
+	}
+	if output.Summary.Model != "heuristic" {
+		t.Errorf("expected heuristic verdicts, got model %s", output.Summary.Model)
+	}
+
+	estimateInput := input
+	estimateInput.Model = "gemini-2.5-pro"
+	estimate, err := EstimateAnalysisCost(context.Background(), estimateInput, nil)
+	if err != nil {
+		t.Fatalf("EstimateAnalysisCost failed: %v", err)
+	}
+	if estimate.Model != "gemini-2.5-pro" {
+		t.Errorf("expected the allowed model, got %s", estimate.Model)
+	}
+
+	tooHot := float32(1.5)
+	for name, tt := range map[string]struct {
+		input AnalyzeInput
+		want  string
+	}{
+		"model":       {AnalyzeInput{Model: "gemini-ultra"}, "mcp.allowed_models"},
+		"provider":    {AnalyzeInput{Provider: "openai"}, "mcp.allowed_providers"},
+		"temperature": {AnalyzeInput{Temperature: &tooHot}, "invalid temperature"},
+	} {
+		tt.input.RepoPath, tt.input.ErrorMessage = dir, "boom"
+		if _, err := AnalyzeRootCause(context.Background(), tt.input, nil); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected an error mentioning %q, got %v", name, tt.want, err)
+		}
+	}
+}
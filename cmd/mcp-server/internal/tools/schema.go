@@ -0,0 +1,55 @@
+package tools
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/google/jsonschema-go/jsonschema"
+)
+
+// Schema returns the JSON schema of a tool's input or output type T, as
+// the MCP SDK infers it, with each property described by its field's
+// `description` struct tag. Tools declare it so that clients can validate
+// and consume the structured output without parsing the text block. It
+// panics if T cannot be described, which is a programming error.
+func Schema[T any]() *jsonschema.Schema {
+	s, err := jsonschema.For[T](nil)
+	if err != nil {
+		panic(fmt.Sprintf("schema of %T: %v", *new(T), err))
+	}
+	describe(s, reflect.TypeFor[T]())
+	return s
+}
+
+// describe sets the descriptions of s's properties, the schema of t, from
+// the `description` tags of t's fields, and of the structs they hold
+func describe(s *jsonschema.Schema, t reflect.Type) {
+	// Look through pointers, and through slices to their items
+	for s != nil && (t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+		if t.Kind() != reflect.Pointer {
+			s = s.Items
+		}
+		t = t.Elem()
+	}
+	if s == nil || t.Kind() != reflect.Struct {
+		return
+	}
+	for _, f := range reflect.VisibleFields(t) {
+		if f.Anonymous || !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" {
+			name = f.Name
+		}
+		p, ok := s.Properties[name]
+		if !ok {
+			continue
+		}
+		if d := f.Tag.Get("description"); d != "" {
+			p.Description = d
+		}
+		describe(p, f.Type)
+	}
+}
@@ -0,0 +1,83 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+// validate checks that output, marshaled as the MCP SDK does, matches the
+// schema of T
+func validate[T any](t *testing.T, output T) {
+	t.Helper()
+	resolved, err := Schema[T]().Resolve(nil)
+	if err != nil {
+		t.Fatalf("resolving the schema of %T: %v", output, err)
+	}
+	data, err := json.Marshal(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var instance any
+	if err := json.Unmarshal(data, &instance); err != nil {
+		t.Fatal(err)
+	}
+	if err := resolved.Validate(instance); err != nil {
+		t.Errorf("%T does not match its schema: %v\n%s", output, err, data)
+	}
+}
+
+func TestSchemaDescriptions(t *testing.T) {
+	s := Schema[AnalyzeOutput]()
+	if s.Properties["run_id"].Description == "" || s.Properties["summary"].Properties["tokens"].Description == "" {
+		t.Error("expected top-level and nested properties to be described")
+	}
+	probability := s.Properties["results"].Items.Properties["probability"]
+	if probability == nil || probability.Description == "" {
+		t.Errorf("expected the results' probability to be described, got %+v", probability)
+	}
+
+	in := Schema[AnalyzeInput]()
+	if in.Properties["repo_path"].Description == "" {
+		t.Error("expected input properties to be described")
+	}
+	if _, ok := in.Properties["commit"]; ok {
+		t.Error("expected the unexported commit field to be left out")
+	}
+}
+
+func TestSchemaMatchesOutput(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Chdir(home)
+	dir, hashes := createTestRepo(t,
+		"package main\n\nfunc main() {}\n",
+		"package main\n\nfunc main() {\n\tpanic(\"nil map\")\n}\n",
+	)
+	ctx := context.Background()
+	input := AnalyzeInput{RepoPath: dir, ErrorMessage: "panic: nil map", NumCommits: 2, Offline: true}
+
+	analysis, err := AnalyzeRootCause(ctx, input, nil)
+	if err != nil {
+		t.Fatalf("AnalyzeRootCause failed: %v", err)
+	}
+	validate(t, *analysis)
+
+	diff, err := GetDualContextDiff(ctx, DiffInput{RepoPath: dir, Commit: hashes[1]}, nil)
+	if err != nil {
+		t.Fatalf("GetDualContextDiff failed: %v", err)
+	}
+	validate(t, *diff)
+
+	commits, err := ListRecentCommits(ctx, ListCommitsInput{RepoPath: dir}, nil)
+	if err != nil {
+		t.Fatalf("ListRecentCommits failed: %v", err)
+	}
+	validate(t, *commits)
+
+	estimate, err := EstimateAnalysisCost(ctx, input, nil)
+	if err != nil {
+		t.Fatalf("EstimateAnalysisCost failed: %v", err)
+	}
+	validate(t, *estimate)
+}
@@ -0,0 +1,44 @@
+package tools
+
+import (
+	"slices"
+	"sort"
+)
+
+// rankResults returns results most suspicious first: by suspicion score,
+// then by probability
+func rankResults(results []CommitResult) []CommitResult {
+	ranked := slices.Clone(results)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].Suspicion != ranked[j].Suspicion {
+			return ranked[i].Suspicion > ranked[j].Suspicion
+		}
+		return probabilityOrder[ranked[i].Probability] < probabilityOrder[ranked[j].Probability]
+	})
+	return ranked
+}
+
+// trimResults keeps the max most suspicious of output's results, in their
+// history order, and counts the others in output.Omitted (max 0: keeps
+// them all)
+func trimResults(output *AnalyzeOutput, max int) {
+	if max <= 0 || len(output.Results) <= max {
+		return
+	}
+	kept := map[string]bool{}
+	for _, r := range rankResults(output.Results)[:max] {
+		kept[r.Hash] = true
+	}
+	output.Omitted = len(output.Results) - max
+	output.Results = slices.DeleteFunc(output.Results, func(r CommitResult) bool { return !kept[r.Hash] })
+}
+
+// truncateReasoning shortens reasoning to max characters, marking the cut
+// (max 0: whole)
+func truncateReasoning(reasoning string, max int) string {
+	runes := []rune(reasoning)
+	if max <= 0 || len(runes) <= max {
+		return reasoning
+	}
+	return string(runes[:max]) + "… (truncated)"
+}
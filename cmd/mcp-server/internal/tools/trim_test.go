@@ -0,0 +1,54 @@
+package tools
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestTrimResults(t *testing.T) {
+	output := &AnalyzeOutput{Results: []CommitResult{
+		{Hash: "a", Probability: "LOW", Suspicion: 0.1},
+		{Hash: "b", Probability: "HIGH", Suspicion: 0.9},
+		{Hash: "c", Probability: "MEDIUM", Suspicion: 0.5},
+		{Hash: "d", Probability: "LOW", Suspicion: 0.2},
+	}}
+
+	trimResults(output, 0)
+	if len(output.Results) != 4 || output.Omitted != 0 {
+		t.Fatalf("expected no trimming without a cap, got %d results", len(output.Results))
+	}
+
+	trimResults(output, 2)
+	var hashes []string
+	for _, r := range output.Results {
+		hashes = append(hashes, r.Hash)
+	}
+	// The most suspicious are kept, in history order
+	if !slices.Equal(hashes, []string{"b", "c"}) || output.Omitted != 2 {
+		t.Errorf("expected [b c] with 2 omitted, got %v with %d", hashes, output.Omitted)
+	}
+
+	output.RunID = "run-1"
+	if text := FormatResultsAsText(output); !strings.Contains(text, "2 less suspicious results; read analysis://run-1") {
+		t.Errorf("expected a pointer to the full report in:\n%s", text)
+	}
+}
+
+func TestTruncateReasoning(t *testing.T) {
+	tests := []struct {
+		reasoning string
+		max       int
+		want      string
+	}{
+		{"short", 0, "short"},
+		{"short", 10, "short"},
+		{"a longer reasoning", 8, "a longer… (truncated)"},
+		{"héllo wörld", 7, "héllo w… (truncated)"},
+	}
+	for _, tt := range tests {
+		if got := truncateReasoning(tt.reasoning, tt.max); got != tt.want {
+			t.Errorf("truncateReasoning(%q, %d) = %q, want %q", tt.reasoning, tt.max, got, tt.want)
+		}
+	}
+}
@@ -3,10 +3,20 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/kerneldump/git-dual-context/cmd/mcp-server/internal/tools"
+	"github.com/kerneldump/git-dual-context/pkg/config"
+	"github.com/kerneldump/git-dual-context/pkg/history"
+	"github.com/kerneldump/git-dual-context/pkg/network"
+	"github.com/kerneldump/git-dual-context/pkg/telemetry"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
@@ -15,7 +25,82 @@ func main() {
 	// Redirect logs to stderr so they don't interfere with MCP JSON-RPC on stdout
 	log.SetOutput(os.Stderr)
 
-	// Create MCP server
+	shutdownTracing, err := telemetry.Setup(context.Background(), "git-dual-context-mcp")
+	if err != nil {
+		log.Fatalf("Tracing setup error: %v", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			log.Printf("Flushing traces: %v", err)
+		}
+	}()
+
+	// Route git remotes and the LLM API through the configured proxy and
+	// CA bundle; this cannot change between tool calls
+	configPath := config.FindConfigFile()
+	cfg, _, err := config.Load(configPath, "", "")
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		log.Fatalf("Config error: %v", err)
+	}
+	tools.SetServerConfig(cfg)
+	if err := network.Configure(network.Options{Proxy: cfg.Network.Proxy, CABundle: cfg.Network.CABundle}); err != nil {
+		log.Fatalf("Network setup error: %v", err)
+	}
+
+	httpAddr := flag.String("http", cfg.MCP.Listen, "Address to serve MCP over streamable HTTP and SSE on, such as :8090 (default: mcp.listen; empty: stdio)")
+	flag.Parse()
+
+	// New tool calls use each valid change to the config file; a broken
+	// file is rejected and calls keep the last valid config
+	if configPath != "" {
+		watcher := &config.Watcher{
+			Path: configPath,
+			Load: func() (*config.Config, error) {
+				newCfg, _, err := config.Load(configPath, "", "")
+				return newCfg, err
+			},
+			OnReload: func(newCfg *config.Config) error {
+				tools.SetServerConfig(newCfg)
+				log.Printf("Reloaded config from %s; new tool calls use it (network settings need a restart)", configPath)
+				return nil
+			},
+			OnError: func(err error) {
+				log.Printf("WARN: Ignoring changed config %s, new tool calls keep the last valid config: %v", configPath, err)
+			},
+		}
+		go watcher.Run(context.Background())
+	}
+
+	server := newServer()
+
+	log.Println("Starting Git Dual-Context MCP Server...")
+
+	// Serve over HTTP when an address is set, shared by many clients;
+	// otherwise over stdio, as a subprocess of one client
+	if *httpAddr != "" {
+		token, err := authToken(cfg.MCP)
+		if err != nil {
+			log.Fatalf("Auth token error: %v", err)
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if err := serveHTTP(ctx, server, *httpAddr, token); err != nil {
+			log.Fatalf("Server error: %v", err)
+		}
+		return
+	}
+	if err := server.Run(context.Background(), &mcp.StdioTransport{}); err != nil {
+		log.Fatalf("Server error: %v", err)
+	}
+}
+
+// newServer creates the MCP server with its tools, resources, and prompts
+func newServer() *mcp.Server {
 	server := mcp.NewServer(&mcp.Implementation{
 		Name:    "git-dual-context-mcp",
 		Version: "0.1.0",
@@ -23,16 +108,134 @@ func main() {
 
 	// Register the analyze_root_cause tool
 	mcp.AddTool(server, &mcp.Tool{
-		Name:        "analyze_root_cause",
-		Description: "Diagnose bugs using dual-context diff analysis. Analyzes recent commits in a git repository to identify which commit most likely caused a given error or bug. Uses LLM-powered reasoning to compare immediate changes (micro-context) with evolutionary changes to HEAD (macro-context).",
+		Name:         "analyze_root_cause",
+		Description:  "Diagnose bugs using dual-context diff analysis. Analyzes recent commits in a git repository to identify which commit most likely caused a given error or bug. Uses LLM-powered reasoning to compare immediate changes (micro-context) with evolutionary changes to HEAD (macro-context).",
+		InputSchema:  tools.Schema[tools.AnalyzeInput](),
+		OutputSchema: tools.Schema[tools.AnalyzeOutput](),
 	}, handleAnalyzeRootCause)
 
-	log.Println("Starting Git Dual-Context MCP Server...")
+	// Register the analyze_commit tool
+	mcp.AddTool(server, &mcp.Tool{
+		Name:         "analyze_commit",
+		Description:  "Analyze one commit against an error message with dual-context diff analysis, returning its probability of having caused the bug and the reasoning. Use it to drill into a suspect found by analyze_root_cause, or a commit the user names, without re-running the whole range.",
+		InputSchema:  tools.Schema[tools.AnalyzeCommitInput](),
+		OutputSchema: tools.Schema[tools.AnalyzeOutput](),
+	}, handleAnalyzeCommit)
 
-	// Run server over stdio transport
-	if err := server.Run(context.Background(), &mcp.StdioTransport{}); err != nil {
-		log.Fatalf("Server error: %v", err)
+	// Register the analyze_working_tree tool
+	mcp.AddTool(server, &mcp.Tool{
+		Name:         "analyze_working_tree",
+		Description:  "Analyze the uncommitted changes of a local repository against an error message, as if they were one commit on top of HEAD, returning the probability that they are linked to the bug and the reasoning. Use it to check whether a change in progress, such as an attempted fix, bears on the bug before committing it.",
+		InputSchema:  tools.Schema[tools.AnalyzeWorkingTreeInput](),
+		OutputSchema: tools.Schema[tools.AnalyzeOutput](),
+	}, handleAnalyzeWorkingTree)
+
+	// Register the get_dual_context_diff tool
+	mcp.AddTool(server, &mcp.Tool{
+		Name:         "get_dual_context_diff",
+		Description:  "Return a commit's dual context without analyzing it: the standard diff (the commit against its parent) and the evolution diff (the commit's files against HEAD or a given ref), fitted to a token budget. Use it to reason about a commit yourself instead of getting a verdict.",
+		InputSchema:  tools.Schema[tools.DiffInput](),
+		OutputSchema: tools.Schema[tools.DiffOutput](),
+	}, handleGetDualContextDiff)
+
+	// Register the explain_commit tool
+	mcp.AddTool(server, &mcp.Tool{
+		Name:         "explain_commit",
+		Description:  "Explain a commit in plain language with the LLM: what it changed, from its diff against its parent, and what became of the change since, from its diff against HEAD or a given ref. No bug is assumed; use it to understand unfamiliar code or history rather than to diagnose an error.",
+		InputSchema:  tools.Schema[tools.ExplainInput](),
+		OutputSchema: tools.Schema[tools.ExplainOutput](),
+	}, handleExplainCommit)
+
+	// Register the list_recent_commits tool
+	mcp.AddTool(server, &mcp.Tool{
+		Name:         "list_recent_commits",
+		Description:  "List recent commits of a git repository with their author, date, subject, changed files, and whether analysis would skip them, without calling the LLM. Use it to choose which commits to analyze before spending tokens.",
+		InputSchema:  tools.Schema[tools.ListCommitsInput](),
+		OutputSchema: tools.Schema[tools.ListCommitsOutput](),
+	}, handleListRecentCommits)
+
+	// Register the estimate_analysis_cost tool
+	mcp.AddTool(server, &mcp.Tool{
+		Name:         "estimate_analysis_cost",
+		Description:  "Estimate the LLM calls, prompt tokens, and dollar cost of an analyze_root_cause call with the same arguments, without calling the LLM. Use it to have spend approved before running the analysis.",
+		InputSchema:  tools.Schema[tools.AnalyzeInput](),
+		OutputSchema: tools.Schema[tools.EstimateOutput](),
+	}, handleEstimateAnalysisCost)
+
+	// Register the job tools, which run analyze_root_cause in the
+	// background for analyses longer than clients wait for a tool call
+	mcp.AddTool(server, &mcp.Tool{
+		Name:         "start_analysis",
+		Description:  "Start an analyze_root_cause run with the same arguments in the background and return its job_id at once. Use it for large repositories or many commits, where the analysis can take minutes, then poll get_analysis_status and fetch the output with get_analysis_result.",
+		InputSchema:  tools.Schema[tools.AnalyzeInput](),
+		OutputSchema: tools.Schema[tools.JobStatusOutput](),
+	}, handleStartAnalysis)
+	mcp.AddTool(server, &mcp.Tool{
+		Name:         "get_analysis_status",
+		Description:  "Report the progress of an analysis started by start_analysis: running, completed, or failed, with the steps done and the latest commit analyzed.",
+		InputSchema:  tools.Schema[tools.JobInput](),
+		OutputSchema: tools.Schema[tools.JobStatusOutput](),
+	}, handleGetAnalysisStatus)
+	mcp.AddTool(server, &mcp.Tool{
+		Name:         "get_analysis_result",
+		Description:  "Return the output of a completed analysis started by start_analysis, the same as analyze_root_cause returns. Fails while the analysis is still running.",
+		InputSchema:  tools.Schema[tools.JobInput](),
+		OutputSchema: tools.Schema[tools.AnalyzeOutput](),
+	}, handleGetAnalysisResult)
+
+	// Register the past analysis reports, read from the history database
+	server.AddResource(&mcp.Resource{
+		URI:         tools.RecentReportsURI,
+		Name:        "recent_reports",
+		Description: "The most recent analyses recorded in the history database, with the URIs of their reports.",
+		MIMEType:    "text/markdown",
+	}, handleRecentReports)
+	server.AddResourceTemplate(&mcp.ResourceTemplate{
+		URITemplate: tools.ReportURIPrefix + "{run_id}",
+		Name:        "analysis_report",
+		Description: "The report of a past analysis, by the run_id an analyze_root_cause or analyze_commit call returned: its summary and each commit's verdict and reasoning, re-read without re-running the analysis.",
+		MIMEType:    "text/markdown",
+	}, handleReadReport)
+
+	// Register the prompt templates for guided debugging workflows
+	for _, p := range tools.Prompts {
+		prompt := &mcp.Prompt{Name: p.Name, Description: p.Description}
+		for _, arg := range p.Arguments {
+			prompt.Arguments = append(prompt.Arguments, &mcp.PromptArgument{
+				Name:        arg.Name,
+				Description: arg.Description,
+				Required:    arg.Required,
+			})
+		}
+		server.AddPrompt(prompt, handleGetPrompt)
+	}
+
+	return server
+}
+
+// newProgress reports a tool call's progress to the client: messages as
+// logs, and steps as progress notifications when the request asked for
+// them with a progress token
+func newProgress(ctx context.Context, request *mcp.CallToolRequest) *tools.Progress {
+	progress := &tools.Progress{
+		Log: func(msg string) {
+			_ = request.Session.Log(ctx, &mcp.LoggingMessageParams{
+				Level: "info",
+				Data:  msg,
+			})
+		},
+	}
+	if token := request.Params.GetProgressToken(); token != nil {
+		progress.Advance = func(done, total int, msg string) {
+			_ = request.Session.NotifyProgress(ctx, &mcp.ProgressNotificationParams{
+				ProgressToken: token,
+				Message:       msg,
+				Progress:      float64(done),
+				Total:         float64(total),
+			})
+		}
 	}
+	return progress
 }
 
 // handleAnalyzeRootCause is the MCP tool handler for analyze_root_cause
@@ -43,13 +246,8 @@ func handleAnalyzeRootCause(
 ) (*mcp.CallToolResult, tools.AnalyzeOutput, error) {
 	log.Printf("Analyzing repository: %s for error: %q", input.RepoPath, input.ErrorMessage)
 
-	output, err := tools.AnalyzeRootCause(ctx, input, func(msg string) {
-		// Send progress logs to the client
-		_ = request.Session.Log(ctx, &mcp.LoggingMessageParams{
-			Level: "info",
-			Data:  msg,
-		})
-	})
+	ctx = tools.WithSession(ctx, request.Session.ID())
+	output, err := tools.AnalyzeRootCause(ctx, input, newProgress(ctx, request))
 	if err != nil {
 		log.Printf("Analysis failed: %v", err)
 		return nil, tools.AnalyzeOutput{}, err
@@ -58,18 +256,279 @@ func handleAnalyzeRootCause(
 	log.Printf("Analysis complete: %d commits analyzed, %d high, %d medium, %d low probability, %d errors",
 		output.Summary.Total, output.Summary.High, output.Summary.Medium, output.Summary.Low, output.Summary.Errors)
 
-	// Build a human-readable text summary for the Content field
-	summaryText := tools.FormatResultsAsText(output)
-
 	// Marshal structured output for debugging
 	jsonBytes, _ := json.MarshalIndent(output, "", "  ")
 	log.Printf("Structured output: %s", string(jsonBytes))
 
+	return &mcp.CallToolResult{Content: analysisContent(output)}, *output, nil
+}
+
+// analysisContent is the Content of an analysis's result: a human-readable
+// text summary, and a link to the full report when results were omitted
+func analysisContent(output *tools.AnalyzeOutput) []mcp.Content {
+	content := []mcp.Content{
+		&mcp.TextContent{
+			Text: tools.FormatResultsAsText(output),
+		},
+	}
+	if output.Omitted > 0 && output.RunID != "" {
+		content = append(content, &mcp.ResourceLink{
+			URI:         tools.ReportURI(output.RunID),
+			Name:        "analysis_report",
+			Description: fmt.Sprintf("The full report, with the %d less suspicious results left out here", output.Omitted),
+			MIMEType:    "text/markdown",
+		})
+	}
+	return content
+}
+
+// handleAnalyzeCommit is the MCP tool handler for analyze_commit
+func handleAnalyzeCommit(
+	ctx context.Context,
+	request *mcp.CallToolRequest,
+	input tools.AnalyzeCommitInput,
+) (*mcp.CallToolResult, tools.AnalyzeOutput, error) {
+	log.Printf("Analyzing commit %s in repository: %s for error: %q", input.Commit, input.RepoPath, input.ErrorMessage)
+
+	ctx = tools.WithSession(ctx, request.Session.ID())
+	output, err := tools.AnalyzeCommit(ctx, input, newProgress(ctx, request))
+	if err != nil {
+		log.Printf("Analysis failed: %v", err)
+		return nil, tools.AnalyzeOutput{}, err
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{
+				Text: tools.FormatResultsAsText(output),
+			},
+		},
+	}, *output, nil
+}
+
+// handleAnalyzeWorkingTree is the MCP tool handler for analyze_working_tree
+func handleAnalyzeWorkingTree(
+	ctx context.Context,
+	request *mcp.CallToolRequest,
+	input tools.AnalyzeWorkingTreeInput,
+) (*mcp.CallToolResult, tools.AnalyzeOutput, error) {
+	log.Printf("Analyzing uncommitted changes in repository: %s for error: %q", input.RepoPath, input.ErrorMessage)
+
+	ctx = tools.WithSession(ctx, request.Session.ID())
+	output, err := tools.AnalyzeWorkingTree(ctx, input, newProgress(ctx, request))
+	if err != nil {
+		log.Printf("Analysis failed: %v", err)
+		return nil, tools.AnalyzeOutput{}, err
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{
+				Text: tools.FormatResultsAsText(output),
+			},
+		},
+	}, *output, nil
+}
+
+// handleGetDualContextDiff is the MCP tool handler for get_dual_context_diff
+func handleGetDualContextDiff(
+	ctx context.Context,
+	request *mcp.CallToolRequest,
+	input tools.DiffInput,
+) (*mcp.CallToolResult, tools.DiffOutput, error) {
+	log.Printf("Extracting diffs of commit %s in repository: %s", input.Commit, input.RepoPath)
+
+	output, err := tools.GetDualContextDiff(ctx, input, newProgress(ctx, request))
+	if err != nil {
+		log.Printf("Diff extraction failed: %v", err)
+		return nil, tools.DiffOutput{}, err
+	}
+
 	return &mcp.CallToolResult{
 		Content: []mcp.Content{
 			&mcp.TextContent{
-				Text: summaryText,
+				Text: tools.FormatDiffAsText(output),
 			},
 		},
 	}, *output, nil
 }
+
+// handleExplainCommit is the MCP tool handler for explain_commit
+func handleExplainCommit(
+	ctx context.Context,
+	request *mcp.CallToolRequest,
+	input tools.ExplainInput,
+) (*mcp.CallToolResult, tools.ExplainOutput, error) {
+	log.Printf("Explaining commit %s in repository: %s", input.Commit, input.RepoPath)
+
+	ctx = tools.WithSession(ctx, request.Session.ID())
+	output, err := tools.ExplainCommit(ctx, input, newProgress(ctx, request))
+	if err != nil {
+		log.Printf("Explanation failed: %v", err)
+		return nil, tools.ExplainOutput{}, err
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{
+				Text: tools.FormatExplanationAsText(output),
+			},
+		},
+	}, *output, nil
+}
+
+// handleListRecentCommits is the MCP tool handler for list_recent_commits
+func handleListRecentCommits(
+	ctx context.Context,
+	request *mcp.CallToolRequest,
+	input tools.ListCommitsInput,
+) (*mcp.CallToolResult, tools.ListCommitsOutput, error) {
+	log.Printf("Listing commits of repository: %s", input.RepoPath)
+
+	output, err := tools.ListRecentCommits(ctx, input, newProgress(ctx, request))
+	if err != nil {
+		log.Printf("Listing commits failed: %v", err)
+		return nil, tools.ListCommitsOutput{}, err
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{
+				Text: tools.FormatCommitsAsText(output),
+			},
+		},
+	}, *output, nil
+}
+
+// handleEstimateAnalysisCost is the MCP tool handler for
+// estimate_analysis_cost
+func handleEstimateAnalysisCost(
+	ctx context.Context,
+	request *mcp.CallToolRequest,
+	input tools.AnalyzeInput,
+) (*mcp.CallToolResult, tools.EstimateOutput, error) {
+	log.Printf("Estimating analysis cost for repository: %s", input.RepoPath)
+
+	output, err := tools.EstimateAnalysisCost(ctx, input, newProgress(ctx, request))
+	if err != nil {
+		log.Printf("Estimate failed: %v", err)
+		return nil, tools.EstimateOutput{}, err
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{
+				Text: tools.FormatEstimateAsText(output),
+			},
+		},
+	}, *output, nil
+}
+
+// handleStartAnalysis is the MCP tool handler for start_analysis
+func handleStartAnalysis(
+	ctx context.Context,
+	request *mcp.CallToolRequest,
+	input tools.AnalyzeInput,
+) (*mcp.CallToolResult, tools.JobStatusOutput, error) {
+	log.Printf("Starting analysis of repository: %s for error: %q", input.RepoPath, input.ErrorMessage)
+
+	ctx = tools.WithSession(ctx, request.Session.ID())
+	output, err := tools.StartAnalysis(ctx, input)
+	if err != nil {
+		log.Printf("Starting analysis failed: %v", err)
+		return nil, tools.JobStatusOutput{}, err
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{
+				Text: tools.FormatJobStatusAsText(output),
+			},
+		},
+	}, *output, nil
+}
+
+// handleGetAnalysisStatus is the MCP tool handler for get_analysis_status
+func handleGetAnalysisStatus(
+	ctx context.Context,
+	request *mcp.CallToolRequest,
+	input tools.JobInput,
+) (*mcp.CallToolResult, tools.JobStatusOutput, error) {
+	ctx = tools.WithSession(ctx, request.Session.ID())
+	output, err := tools.GetAnalysisStatus(ctx, input)
+	if err != nil {
+		return nil, tools.JobStatusOutput{}, err
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			&mcp.TextContent{
+				Text: tools.FormatJobStatusAsText(output),
+			},
+		},
+	}, *output, nil
+}
+
+// handleGetAnalysisResult is the MCP tool handler for get_analysis_result
+func handleGetAnalysisResult(
+	ctx context.Context,
+	request *mcp.CallToolRequest,
+	input tools.JobInput,
+) (*mcp.CallToolResult, tools.AnalyzeOutput, error) {
+	ctx = tools.WithSession(ctx, request.Session.ID())
+	output, err := tools.GetAnalysisResult(ctx, input)
+	if err != nil {
+		return nil, tools.AnalyzeOutput{}, err
+	}
+
+	return &mcp.CallToolResult{Content: analysisContent(output)}, *output, nil
+}
+
+// handleRecentReports is the MCP resource handler listing recent reports
+func handleRecentReports(ctx context.Context, request *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+	text, err := tools.ListReports()
+	if err != nil {
+		log.Printf("Listing reports failed: %v", err)
+		return nil, err
+	}
+	return &mcp.ReadResourceResult{
+		Contents: []*mcp.ResourceContents{
+			{URI: request.Params.URI, MIMEType: "text/markdown", Text: text},
+		},
+	}, nil
+}
+
+// handleReadReport is the MCP resource handler for analysis://<run-id>
+func handleReadReport(ctx context.Context, request *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+	text, err := tools.ReadReport(request.Params.URI)
+	if errors.Is(err, history.ErrRunNotFound) {
+		return nil, mcp.ResourceNotFoundError(request.Params.URI)
+	}
+	if err != nil {
+		log.Printf("Reading report %s failed: %v", request.Params.URI, err)
+		return nil, err
+	}
+	return &mcp.ReadResourceResult{
+		Contents: []*mcp.ResourceContents{
+			{URI: request.Params.URI, MIMEType: "text/markdown", Text: text},
+		},
+	}, nil
+}
+
+// handleGetPrompt is the MCP prompt handler for the prompt templates
+func handleGetPrompt(ctx context.Context, request *mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	prompt, ok := tools.LookupPrompt(request.Params.Name)
+	if !ok {
+		return nil, fmt.Errorf("unknown prompt %q", request.Params.Name)
+	}
+	text, err := prompt.Render(request.Params.Arguments)
+	if err != nil {
+		return nil, err
+	}
+	return &mcp.GetPromptResult{
+		Description: prompt.Description,
+		Messages: []*mcp.PromptMessage{
+			{Role: "user", Content: &mcp.TextContent{Text: text}},
+		},
+	}, nil
+}
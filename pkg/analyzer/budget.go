@@ -0,0 +1,46 @@
+package analyzer
+
+import (
+	"errors"
+	"time"
+)
+
+// MinCallBudget is the least time left before a run's deadline for which
+// another commit is analyzed; an LLM call given less would likely time out
+// after being paid for
+const MinCallBudget = 15 * time.Second
+
+// ErrBudgetExhausted is the error of commits left unanalyzed because the
+// run's deadline was too close
+var ErrBudgetExhausted = errors.New("run deadline reached, commit not analyzed")
+
+// Budget divides a run's deadline among its LLM calls: each call gets its
+// own timeout, cut short to the time left, and once less than MinCallBudget
+// remains no call starts. A nil *Budget has no deadline. It is safe for
+// concurrent use.
+type Budget struct {
+	deadline time.Time
+	now      func() time.Time
+}
+
+// NewBudget returns a budget for a run ending d from now, or nil if d is
+// not positive
+func NewBudget(d time.Duration) *Budget {
+	if d <= 0 {
+		return nil
+	}
+	return &Budget{deadline: time.Now().Add(d), now: time.Now}
+}
+
+// Timeout returns the timeout for a call that would take up to perCall
+// without a deadline, or ErrBudgetExhausted
+func (b *Budget) Timeout(perCall time.Duration) (time.Duration, error) {
+	if b == nil {
+		return perCall, nil
+	}
+	left := b.deadline.Sub(b.now())
+	if left < MinCallBudget {
+		return 0, ErrBudgetExhausted
+	}
+	return min(perCall, left), nil
+}
@@ -0,0 +1,35 @@
+package analyzer
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBudgetTimeout(t *testing.T) {
+	var none *Budget
+	if d, err := none.Timeout(time.Minute); err != nil || d != time.Minute {
+		t.Errorf("Expected a nil budget to keep the call timeout, got %v, %v", d, err)
+	}
+	if NewBudget(0) != nil {
+		t.Error("Expected no budget without a run timeout")
+	}
+
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	b := &Budget{deadline: now.Add(10 * time.Minute), now: func() time.Time { return now }}
+	if d, err := b.Timeout(time.Minute); err != nil || d != time.Minute {
+		t.Errorf("Expected the full call timeout, got %v, %v", d, err)
+	}
+
+	// Calls are cut short to the time left
+	now = now.Add(9*time.Minute + 30*time.Second)
+	if d, err := b.Timeout(time.Minute); err != nil || d != 30*time.Second {
+		t.Errorf("Expected 30s left, got %v, %v", d, err)
+	}
+
+	// With less than MinCallBudget left, no call starts
+	now = now.Add(20 * time.Second)
+	if _, err := b.Timeout(time.Minute); !errors.Is(err, ErrBudgetExhausted) {
+		t.Errorf("Expected ErrBudgetExhausted, got %v", err)
+	}
+}
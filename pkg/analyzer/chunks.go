@@ -0,0 +1,63 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// probabilityRank orders verdicts from least to most suspicious
+var probabilityRank = map[Probability]int{
+	ProbLow:    0,
+	ProbMedium: 1,
+	ProbHigh:   2,
+}
+
+// analyzeChunks analyzes each chunk of a large commit with its own LLM
+// call and merges the verdicts. Chunks are analyzed one after another;
+// parallelism comes from analyzing several commits at once.
+func analyzeChunks(ctx context.Context, diffCtx *CommitDiffContext, errorMsg string, model LLMModel) (result *AnalysisResult, err error) {
+	ctx, span := tracer.Start(ctx, "AnalyzeChunks", trace.WithAttributes(
+		attribute.String("git.commit", diffCtx.Commit.Hash.String()),
+		attribute.Int("analysis.chunks", len(diffCtx.Chunks)),
+	))
+	defer func() { endSpan(span, err) }()
+
+	results := make([]*AnalysisResult, len(diffCtx.Chunks))
+	for i, chunk := range diffCtx.Chunks {
+		r, err := AnalyzeWithDiffs(ctx, chunk, errorMsg, model)
+		if err != nil {
+			return nil, fmt.Errorf("chunk %d/%d: %w", i+1, len(diffCtx.Chunks), err)
+		}
+		results[i] = r
+	}
+	return mergeChunkResults(diffCtx.Chunks, results), nil
+}
+
+// mergeChunkResults combines per-chunk verdicts. A commit is as suspicious
+// as its most suspicious chunk, so the highest probability wins and the
+// reasoning of every chunk with that probability is kept, labelled with
+// its files. Token usage is summed over all chunks.
+func mergeChunkResults(chunks []*CommitDiffContext, results []*AnalysisResult) *AnalysisResult {
+	merged := &AnalysisResult{Probability: ProbLow}
+	for _, r := range results {
+		if probabilityRank[r.Probability] > probabilityRank[merged.Probability] {
+			merged.Probability = r.Probability
+		}
+		merged.PromptTokens += r.PromptTokens
+		merged.OutputTokens += r.OutputTokens
+	}
+
+	var reasons []string
+	for i, r := range results {
+		if r.Probability != merged.Probability {
+			continue
+		}
+		reasons = append(reasons, fmt.Sprintf("[%s] %s", strings.Join(chunks[i].ModifiedFiles, ", "), r.Reasoning))
+	}
+	merged.Reasoning = strings.Join(reasons, "\n")
+	return merged
+}
@@ -0,0 +1,90 @@
+package analyzer
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/google/generative-ai-go/genai"
+)
+
+// promptModel answers HIGH for prompts containing a marker and LOW otherwise
+type promptModel struct {
+	mu     sync.Mutex
+	marker string
+	calls  int
+}
+
+func (m *promptModel) GenerateContent(ctx context.Context, parts ...genai.Part) (*genai.GenerateContentResponse, error) {
+	m.mu.Lock()
+	m.calls++
+	m.mu.Unlock()
+	response := `{"probability": "LOW", "reasoning": "unrelated"}`
+	if strings.Contains(string(parts[0].(genai.Text)), m.marker) {
+		response = `{"probability": "HIGH", "reasoning": "found it"}`
+	}
+	return &genai.GenerateContentResponse{
+		Candidates: []*genai.Candidate{
+			{Content: &genai.Content{Parts: []genai.Part{genai.Text(response)}}},
+		},
+		UsageMetadata: &genai.UsageMetadata{PromptTokenCount: 100, CandidatesTokenCount: 10},
+	}, nil
+}
+
+func TestMergeChunkResults(t *testing.T) {
+	chunks := []*CommitDiffContext{
+		{ModifiedFiles: []string{"a.go", "b.go"}},
+		{ModifiedFiles: []string{"c.go"}},
+		{ModifiedFiles: []string{"d.go"}},
+	}
+	results := []*AnalysisResult{
+		{Probability: ProbMedium, Reasoning: "maybe", PromptTokens: 1, OutputTokens: 2},
+		{Probability: ProbLow, Reasoning: "no", PromptTokens: 3, OutputTokens: 4},
+		{Probability: ProbMedium, Reasoning: "perhaps", PromptTokens: 5, OutputTokens: 6},
+	}
+
+	merged := mergeChunkResults(chunks, results)
+
+	if merged.Probability != ProbMedium {
+		t.Errorf("Expected MEDIUM, got %s", merged.Probability)
+	}
+	expected := "[a.go, b.go] maybe\n[d.go] perhaps"
+	if merged.Reasoning != expected {
+		t.Errorf("Expected reasoning %q, got %q", expected, merged.Reasoning)
+	}
+	if merged.PromptTokens != 9 || merged.OutputTokens != 12 {
+		t.Errorf("Expected summed tokens 9/12, got %d/%d", merged.PromptTokens, merged.OutputTokens)
+	}
+}
+
+func TestAnalyzeWithDiffsChunks(t *testing.T) {
+	commit := &object.Commit{Message: "big change"}
+	diffCtx := &CommitDiffContext{
+		Commit:        commit,
+		ModifiedFiles: []string{"a.go", "b.go"},
+		Chunks: []*CommitDiffContext{
+			{Commit: commit, StandardDiff: "--- a.go\n+ok\n", ModifiedFiles: []string{"a.go"}},
+			{Commit: commit, StandardDiff: "--- b.go\n+BUG\n", ModifiedFiles: []string{"b.go"}},
+		},
+	}
+	model := &promptModel{marker: "+BUG"}
+
+	result, err := AnalyzeWithDiffs(context.Background(), diffCtx, "boom", model)
+	if err != nil {
+		t.Fatalf("AnalyzeWithDiffs failed: %v", err)
+	}
+	if model.calls != 2 {
+		t.Errorf("Expected one call per chunk, got %d", model.calls)
+	}
+	if result.Probability != ProbHigh {
+		t.Errorf("Expected HIGH from the suspicious chunk, got %s", result.Probability)
+	}
+	if result.Reasoning != "[b.go] found it" {
+		t.Errorf("Expected reasoning from b.go chunk, got %q", result.Reasoning)
+	}
+	if result.PromptTokens != 200 {
+		t.Errorf("Expected 200 prompt tokens, got %d", result.PromptTokens)
+	}
+}
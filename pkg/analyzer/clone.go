@@ -0,0 +1,130 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kerneldump/git-dual-context/pkg/validator"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+)
+
+// CloneOptions configures how a remote repository is cloned for analysis
+type CloneOptions struct {
+	// Depth is how many commits of history to fetch (0: all of it)
+	Depth int
+
+	// SingleBranch fetches only Branch, or the remote HEAD if it is empty
+	SingleBranch bool
+
+	// Branch is the branch (or full ref name) fetched with SingleBranch
+	Branch string
+
+	// Auth authenticates to the remote
+	Auth RemoteAuth
+}
+
+// RemoteAuth holds credentials for a remote repository. Over HTTPS a token
+// or a username and password are sent as basic auth; over SSH a private key
+// file is used, or the SSH agent if none is set.
+type RemoteAuth struct {
+	Username         string
+	Password         string
+	Token            string
+	SSHKeyPath       string
+	SSHKeyPassphrase string
+}
+
+// RemoteAuthFromEnv returns credentials with the given username and SSH
+// key file, and the secrets from the environment: GIT_TOKEN, GIT_PASSWORD,
+// and GIT_SSH_KEY_PASSPHRASE
+func RemoteAuthFromEnv(username, sshKeyPath string) RemoteAuth {
+	return RemoteAuth{
+		Username:         username,
+		Password:         os.Getenv("GIT_PASSWORD"),
+		Token:            os.Getenv("GIT_TOKEN"),
+		SSHKeyPath:       sshKeyPath,
+		SSHKeyPassphrase: os.Getenv("GIT_SSH_KEY_PASSPHRASE"),
+	}
+}
+
+// Method returns the go-git auth method for url, or nil to connect
+// anonymously (or through the SSH agent)
+func (a RemoteAuth) Method(url string) (transport.AuthMethod, error) {
+	if isSSHURL(url) {
+		if a.SSHKeyPath == "" {
+			return nil, nil
+		}
+		user := "git"
+		if ep, err := transport.NewEndpoint(url); err == nil && ep.User != "" {
+			user = ep.User
+		}
+		path := a.SSHKeyPath
+		if strings.HasPrefix(path, "~/") {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return nil, fmt.Errorf("failed to get home directory: %w", err)
+			}
+			path = filepath.Join(home, path[2:])
+		}
+		keys, err := ssh.NewPublicKeysFromFile(user, path, a.SSHKeyPassphrase)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load SSH key %s: %w", a.SSHKeyPath, err)
+		}
+		return keys, nil
+	}
+	switch {
+	case a.Token != "":
+		// Hosts accepting tokens ignore the username, but it cannot be empty
+		user := a.Username
+		if user == "" {
+			user = "git"
+		}
+		return &http.BasicAuth{Username: user, Password: a.Token}, nil
+	case a.Username != "" || a.Password != "":
+		return &http.BasicAuth{Username: a.Username, Password: a.Password}, nil
+	}
+	return nil, nil
+}
+
+// IsRemoteURL reports whether a repository path refers to a remote
+// repository, which is cloned, rather than a local one
+func IsRemoteURL(path string) bool {
+	return validator.IsRepoURL(path)
+}
+
+// isSSHURL reports whether url is reached over SSH: ssh:// URLs and the
+// scp-like [user@]host:path form
+func isSSHURL(url string) bool {
+	u, err := validator.ParseRepoURL(url)
+	return err == nil && u.Scheme == "ssh"
+}
+
+// CloneRepository clones url into dir as a bare repository, since analysis
+// only reads history
+func CloneRepository(ctx context.Context, dir, url string, opts CloneOptions) (*git.Repository, error) {
+	auth, err := opts.Auth.Method(url)
+	if err != nil {
+		return nil, err
+	}
+	co := &git.CloneOptions{
+		URL:          url,
+		Auth:         auth,
+		Depth:        opts.Depth,
+		SingleBranch: opts.SingleBranch,
+	}
+	if opts.SingleBranch && opts.Branch != "" {
+		co.ReferenceName = plumbing.ReferenceName(opts.Branch)
+		if !strings.HasPrefix(opts.Branch, "refs/") {
+			co.ReferenceName = plumbing.NewBranchReferenceName(opts.Branch)
+		}
+	}
+	return git.PlainCloneContext(ctx, dir, true, co)
+}
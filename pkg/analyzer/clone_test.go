@@ -0,0 +1,89 @@
+package analyzer
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+func TestRemoteAuthMethod(t *testing.T) {
+	tests := []struct {
+		name     string
+		auth     RemoteAuth
+		url      string
+		expected *http.BasicAuth
+		wantErr  bool
+	}{
+		{"anonymous", RemoteAuth{}, "https://example.com/repo.git", nil, false},
+		{"token", RemoteAuth{Token: "secret"}, "https://example.com/repo.git", &http.BasicAuth{Username: "git", Password: "secret"}, false},
+		{"token with username", RemoteAuth{Username: "ci", Token: "secret"}, "https://example.com/repo.git", &http.BasicAuth{Username: "ci", Password: "secret"}, false},
+		{"basic", RemoteAuth{Username: "alice", Password: "pw"}, "https://example.com/repo.git", &http.BasicAuth{Username: "alice", Password: "pw"}, false},
+		{"ssh agent", RemoteAuth{Token: "secret"}, "git@example.com:org/repo.git", nil, false},
+		{"missing ssh key", RemoteAuth{SSHKeyPath: filepath.Join(t.TempDir(), "id_ed25519")}, "ssh://git@example.com/repo.git", nil, true},
+	}
+	for _, tt := range tests {
+		method, err := tt.auth.Method(tt.url)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: expected error %v, got %v", tt.name, tt.wantErr, err)
+			continue
+		}
+		if tt.expected == nil {
+			if method != nil {
+				t.Errorf("%s: expected no auth, got %v", tt.name, method)
+			}
+			continue
+		}
+		basic, ok := method.(*http.BasicAuth)
+		if !ok || *basic != *tt.expected {
+			t.Errorf("%s: expected %+v, got %v", tt.name, tt.expected, method)
+		}
+	}
+}
+
+func TestCloneRepository(t *testing.T) {
+	// go-git serves local clones through git-upload-pack
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	src := createTestRepo(t, []struct{ path, content string }{
+		{"main.go", "package main\n"},
+		{"main.go", "package main\n\nfunc main() {}\n"},
+		{"main.go", "package main\n\nfunc main() { run() }\n"},
+	})
+	head, err := src.Head()
+	if err != nil {
+		t.Fatalf("Failed to get HEAD: %v", err)
+	}
+	if err := src.Storer.SetReference(plumbing.NewHashReference("refs/heads/other", head.Hash())); err != nil {
+		t.Fatalf("Failed to create branch: %v", err)
+	}
+	w, err := src.Worktree()
+	if err != nil {
+		t.Fatalf("Failed to get worktree: %v", err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "clone")
+	repo, err := CloneRepository(context.Background(), dir, "file://"+w.Filesystem.Root(), CloneOptions{Depth: 2, SingleBranch: true, Branch: "master"})
+	if err != nil {
+		t.Fatalf("CloneRepository failed: %v", err)
+	}
+	if !IsShallow(repo) {
+		t.Error("Expected a shallow clone")
+	}
+	commits, _, err := CollectCommits(repo, AnalysisOptions{NumCommits: 5})
+	if err != nil {
+		t.Fatalf("CollectCommits failed: %v", err)
+	}
+	if len(commits) != 2 {
+		t.Errorf("Expected 2 commits, got %d", len(commits))
+	}
+	for _, name := range []plumbing.ReferenceName{"refs/heads/other", "refs/remotes/origin/other"} {
+		if _, err := repo.Reference(name, false); err == nil {
+			t.Errorf("Expected only master to be fetched, found %s", name)
+		}
+	}
+}
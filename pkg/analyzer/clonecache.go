@@ -0,0 +1,171 @@
+package analyzer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// CloneCache keeps bare clones of remote repositories in a directory, one
+// per URL, and fetches new commits into them instead of cloning again. It
+// is safe for concurrent use; each URL is updated by one caller at a time.
+type CloneCache struct {
+	dir string
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// NewCloneCache returns a cache of clones under dir, which is created on
+// first use. A leading ~/ is expanded to the home directory.
+func NewCloneCache(dir string) (*CloneCache, error) {
+	if strings.HasPrefix(dir, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get home directory: %w", err)
+		}
+		dir = filepath.Join(home, dir[2:])
+	}
+	return &CloneCache{dir: dir, locks: map[string]*sync.Mutex{}}, nil
+}
+
+// Open returns the cached clone of url and its directory, after fetching
+// new commits into it, or clones url on first use
+func (c *CloneCache) Open(ctx context.Context, url string, opts CloneOptions) (*git.Repository, string, error) {
+	key := cacheKey(url)
+	lock := c.lock(key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	dir := filepath.Join(c.dir, key)
+	repo, err := git.PlainOpen(dir)
+	switch {
+	case err == nil:
+		if err := updateClone(ctx, repo, opts); err != nil {
+			return nil, "", fmt.Errorf("failed to update cached clone of %s: %w", url, err)
+		}
+		return repo, dir, nil
+	case !errors.Is(err, git.ErrRepositoryNotExists):
+		return nil, "", fmt.Errorf("failed to open cached clone of %s: %w", url, err)
+	}
+
+	// Clone next to the final directory and move it into place, so an
+	// interrupted clone is never mistaken for a cached one
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return nil, "", fmt.Errorf("failed to create clone cache: %w", err)
+	}
+	tmp, err := os.MkdirTemp(c.dir, key+".tmp-*")
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create clone cache: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+	if _, err := CloneRepository(ctx, tmp, url, opts); err != nil {
+		return nil, "", err
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		// Another process may have cached the same URL meanwhile
+		if repo, openErr := git.PlainOpen(dir); openErr == nil {
+			return repo, dir, nil
+		}
+		return nil, "", fmt.Errorf("failed to move clone into cache: %w", err)
+	}
+	repo, err = git.PlainOpen(dir)
+	if err != nil {
+		return nil, "", err
+	}
+	return repo, dir, nil
+}
+
+// lock returns the mutex serializing updates of the clone with key
+func (c *CloneCache) lock(key string) *sync.Mutex {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	l := c.locks[key]
+	if l == nil {
+		l = &sync.Mutex{}
+		c.locks[key] = l
+	}
+	return l
+}
+
+// unsafeKeyChars matches characters kept out of cache directory names
+var unsafeKeyChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// cacheKey names the cache directory of url: its repository name, for
+// people browsing the cache, and a hash of the whole URL
+func cacheKey(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	name := strings.TrimSuffix(path.Base(strings.ReplaceAll(url, ":", "/")), ".git")
+	name = unsafeKeyChars.ReplaceAllString(name, "_")
+	return name + "-" + hex.EncodeToString(sum[:])[:16] + ".git"
+}
+
+// updateClone fetches new commits into a cached clone and moves its local
+// branches, which HEAD and plain branch names resolve to, to the fetched
+// remote-tracking branches
+func updateClone(ctx context.Context, repo *git.Repository, opts CloneOptions) error {
+	auth, err := opts.Auth.Method(remoteURL(repo))
+	if err != nil {
+		return err
+	}
+	fetch := &git.FetchOptions{Auth: auth, Force: true}
+	if IsShallow(repo) {
+		fetch.Depth = opts.Depth
+	}
+	if opts.SingleBranch && opts.Branch != "" && !strings.HasPrefix(opts.Branch, "refs/") {
+		// A single-branch clone only fetches the branch it was made for
+		if remote, err := repo.Remote(git.DefaultRemoteName); err == nil {
+			branch := plumbing.NewBranchReferenceName(opts.Branch)
+			specs := remote.Config().Fetch
+			if !slices.ContainsFunc(specs, func(s gitconfig.RefSpec) bool { return s.Match(branch) }) {
+				fetch.RefSpecs = append(slices.Clone(specs), gitconfig.RefSpec(
+					fmt.Sprintf("+%s:refs/remotes/%s/%s", branch, git.DefaultRemoteName, opts.Branch)))
+			}
+		}
+	}
+	err = repo.FetchContext(ctx, fetch)
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return err
+	}
+	if fetch.Depth > 0 {
+		if err := pruneShallow(repo); err != nil {
+			return err
+		}
+	}
+
+	refs, err := repo.References()
+	if err != nil {
+		return err
+	}
+	prefix := "refs/remotes/" + git.DefaultRemoteName + "/"
+	return refs.ForEach(func(ref *plumbing.Reference) error {
+		name := ref.Name().String()
+		if ref.Type() != plumbing.HashReference || !strings.HasPrefix(name, prefix) || name == prefix+"HEAD" {
+			return nil
+		}
+		branch := plumbing.NewBranchReferenceName(strings.TrimPrefix(name, prefix))
+		return repo.Storer.SetReference(plumbing.NewHashReference(branch, ref.Hash()))
+	})
+}
+
+// remoteURL returns the URL repo was cloned from
+func remoteURL(repo *git.Repository) string {
+	remote, err := repo.Remote(git.DefaultRemoteName)
+	if err != nil || len(remote.Config().URLs) == 0 {
+		return ""
+	}
+	return remote.Config().URLs[0]
+}
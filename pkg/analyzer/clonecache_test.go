@@ -0,0 +1,98 @@
+package analyzer
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestCloneCache(t *testing.T) {
+	// go-git serves local clones through git-upload-pack
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	src := createTestRepo(t, []struct{ path, content string }{
+		{"main.go", "package main\n"},
+		{"main.go", "package main\n\nfunc main() {}\n"},
+	})
+	w, err := src.Worktree()
+	if err != nil {
+		t.Fatalf("Failed to get worktree: %v", err)
+	}
+	url := "file://" + w.Filesystem.Root()
+
+	cacheDir := t.TempDir()
+	cache, err := NewCloneCache(cacheDir)
+	if err != nil {
+		t.Fatalf("NewCloneCache failed: %v", err)
+	}
+	_, dir, err := cache.Open(context.Background(), url, CloneOptions{})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if filepath.Dir(dir) != cacheDir {
+		t.Errorf("Expected clone in %s, got %s", cacheDir, dir)
+	}
+
+	// A new upstream commit is fetched into the same clone
+	if err := os.WriteFile(filepath.Join(w.Filesystem.Root(), "main.go"), []byte("package main\n\nfunc main() { run() }\n"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := w.Add("main.go"); err != nil {
+		t.Fatalf("Failed to add file: %v", err)
+	}
+	latest, err := w.Commit("commit 2: main.go", &git.CommitOptions{
+		Author: &object.Signature{Name: "Test User", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+
+	repo, again, err := cache.Open(context.Background(), url, CloneOptions{})
+	if err != nil {
+		t.Fatalf("Open of cached clone failed: %v", err)
+	}
+	if again != dir {
+		t.Errorf("Expected the cached clone %s to be reused, got %s", dir, again)
+	}
+	head, err := ResolveCommit(repo, "")
+	if err != nil {
+		t.Fatalf("Failed to resolve HEAD: %v", err)
+	}
+	if head.Hash != latest {
+		t.Errorf("Expected HEAD to move to fetched commit %s, got %s", latest, head.Hash)
+	}
+
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil {
+		t.Fatalf("Failed to read cache: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected one cached clone, got %d entries", len(entries))
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{"https://github.com/org/app.git", "https://github.com/org/app.git", true},
+		{"https://github.com/org/app.git", "https://github.com/other/app.git", false},
+		{"git@github.com:org/app.git", "https://github.com/org/app.git", false},
+	}
+	for _, tt := range tests {
+		if got := cacheKey(tt.a) == cacheKey(tt.b); got != tt.same {
+			t.Errorf("cacheKey(%q) == cacheKey(%q): expected %v, got %v", tt.a, tt.b, tt.same, got)
+		}
+	}
+	if key := cacheKey("git@github.com:org/app.git"); key[:4] != "app-" {
+		t.Errorf("Expected key to start with the repository name, got %s", key)
+	}
+}
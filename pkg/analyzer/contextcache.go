@@ -0,0 +1,226 @@
+package analyzer
+
+import (
+	"cmp"
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/google/generative-ai-go/genai"
+
+	"github.com/kerneldump/git-dual-context/pkg/gitdiff"
+)
+
+//go:embed prompts/head.txt
+var headPromptTemplate string
+
+// ContextCache keeps the part of the prompt shared by every commit of a
+// run in Gemini's context cache: the instructions, the bug description,
+// and the HEAD versions of the files the analyzed commits changed. Prompts
+// sent through a model from Model are stripped of the shared part, which
+// is billed at the cached rate instead of once per commit. A nil
+// *ContextCache caches nothing. It is safe for concurrent use.
+type ContextCache struct {
+	// Stream streams the responses of the cached models (see
+	// StreamingModel)
+	Stream bool
+
+	client    *genai.Client
+	modelName string
+	ttl       time.Duration
+
+	// configure copies the generation settings of the run's model onto
+	// each cached model
+	configure func(*genai.GenerativeModel)
+
+	mu      sync.Mutex
+	entries map[string]LLMModel // by SharedPrompt
+	names   []string            // cached contents, deleted by Close
+}
+
+// NewContextCache returns a cache for modelName whose entries expire
+// after ttl unless deleted by Close first. Cached models get base's
+// generation and safety settings.
+func NewContextCache(client *genai.Client, modelName string, base *genai.GenerativeModel, ttl time.Duration) *ContextCache {
+	return &ContextCache{
+		client:    client,
+		modelName: modelName,
+		ttl:       ttl,
+		configure: func(m *genai.GenerativeModel) {
+			m.GenerationConfig = base.GenerationConfig
+			m.SafetySettings = base.SafetySettings
+		},
+		entries: map[string]LLMModel{},
+	}
+}
+
+// Prepare caches the shared prompt for errorMsg, followed by head, the
+// HEAD-side code from HeadContext (empty: none). Gemini only caches
+// content over a model-specific minimum of tokens, 1,024 for Flash
+// models; below it, or on any other error, prompts for errorMsg keep
+// being sent whole. Preparing errorMsg again replaces its entry.
+func (c *ContextCache) Prepare(ctx context.Context, errorMsg, head string) error {
+	if c == nil {
+		return nil
+	}
+	shared := SharedPrompt(errorMsg)
+	instruction := shared
+	if head != "" {
+		instruction += fmt.Sprintf(headPromptTemplate, head)
+	}
+	cc, err := c.client.CreateCachedContent(ctx, &genai.CachedContent{
+		Model:             c.modelName,
+		DisplayName:       "git-dual-context",
+		SystemInstruction: &genai.Content{Parts: []genai.Part{genai.Text(instruction)}},
+		Expiration:        genai.ExpireTimeOrTTL{TTL: c.ttl},
+	})
+	if err != nil {
+		return fmt.Errorf("creating context cache: %w", err)
+	}
+	cached := c.client.GenerativeModelFromCachedContent(cc)
+	c.configure(cached)
+	var model LLMModel = cached
+	if c.Stream {
+		model = NewStreamingModel(cached)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[shared] = model
+	c.names = append(c.names, cc.Name)
+	return nil
+}
+
+// Model returns a model sending prompts that start with a prepared shared
+// prompt, without it, to that entry's cached model, and any other prompt
+// to model
+func (c *ContextCache) Model(model LLMModel) LLMModel {
+	if c == nil {
+		return model
+	}
+	return &cachedModel{cache: c, fallback: model}
+}
+
+// Close deletes the cached contents, which otherwise live until their TTL
+// expires
+func (c *ContextCache) Close(ctx context.Context) error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var errs []error
+	for _, name := range c.names {
+		if err := c.client.DeleteCachedContent(ctx, name); err != nil {
+			errs = append(errs, fmt.Errorf("deleting context cache %s: %w", name, err))
+		}
+	}
+	c.entries = map[string]LLMModel{}
+	c.names = nil
+	return errors.Join(errs...)
+}
+
+// lookup returns the cached model whose shared prompt starts prompt, and
+// the rest of the prompt, or nil
+func (c *ContextCache) lookup(prompt string) (LLMModel, string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for shared, model := range c.entries {
+		if rest, ok := strings.CutPrefix(prompt, shared); ok {
+			return model, rest
+		}
+	}
+	return nil, ""
+}
+
+// cachedModel routes prompts to the cached models of a ContextCache
+type cachedModel struct {
+	cache    *ContextCache
+	fallback LLMModel
+}
+
+// GenerateContent implements LLMModel
+func (m *cachedModel) GenerateContent(ctx context.Context, parts ...genai.Part) (*genai.GenerateContentResponse, error) {
+	if len(parts) == 1 {
+		if text, ok := parts[0].(genai.Text); ok {
+			if model, rest := m.cache.lookup(string(text)); model != nil {
+				return model.GenerateContent(ctx, genai.Text(rest))
+			}
+		}
+	}
+	return m.fallback.GenerateContent(ctx, parts...)
+}
+
+// HeadContext renders the HEAD versions of the files commits changed, for
+// ContextCache.Prepare: files changed by more of the commits first, as
+// many as fit in maxTokens. Files the filter ignores, binary files, and
+// files deleted since are left out.
+func HeadContext(commits []*object.Commit, headCommit *object.Commit, filter *gitdiff.Filter, maxTokens int) (string, error) {
+	changes := map[string]int{}
+	for _, c := range commits {
+		var parent *object.Commit
+		if c.NumParents() > 0 {
+			p, err := c.Parent(0)
+			if errors.Is(err, plumbing.ErrObjectNotFound) {
+				// Beyond a shallow clone's boundary
+				continue
+			}
+			if err != nil {
+				return "", fmt.Errorf("getting parent commit for %s: %w", c.Hash.String()[:8], err)
+			}
+			parent = p
+		}
+		paths, err := gitdiff.ChangedPaths(c, parent)
+		if err != nil {
+			return "", fmt.Errorf("listing changed paths of %s: %w", c.Hash.String()[:8], err)
+		}
+		for _, path := range paths {
+			if !filter.Ignore(path) {
+				changes[path]++
+			}
+		}
+	}
+	paths := slices.SortedFunc(maps.Keys(changes), func(a, b string) int {
+		return cmp.Or(changes[b]-changes[a], strings.Compare(a, b))
+	})
+
+	tree, err := headCommit.Tree()
+	if err != nil {
+		return "", fmt.Errorf("getting HEAD tree: %w", err)
+	}
+	var b strings.Builder
+	tokens := 0
+	for _, path := range paths {
+		f, err := tree.File(path)
+		if errors.Is(err, object.ErrFileNotFound) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("reading %s at HEAD: %w", path, err)
+		}
+		if binary, err := f.IsBinary(); err != nil || binary {
+			continue
+		}
+		content, err := f.Contents()
+		if err != nil {
+			return "", fmt.Errorf("reading %s at HEAD: %w", path, err)
+		}
+		entry := fmt.Sprintf("=== %s ===\n%s\n", path, content)
+		// A smaller file further down may still fit
+		n := gitdiff.EstimateTokens(entry)
+		if tokens+n > maxTokens {
+			continue
+		}
+		b.WriteString(entry)
+		tokens += n
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,83 @@
+package analyzer
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/google/generative-ai-go/genai"
+)
+
+func TestContextCacheModel(t *testing.T) {
+	c := &object.Commit{Message: "Fix session expiry"}
+	prompt := BuildPrompt("sessions expire early", c, "std diff", "full diff")
+	if !strings.HasPrefix(prompt, SharedPrompt("sessions expire early")) {
+		t.Fatal("Expected the prompt to start with the shared prompt")
+	}
+
+	// The cached model answers HIGH if the shared part reaches it
+	cached := &promptModel{marker: "SKEPTIC PERSONA"}
+	fallback := &promptModel{marker: "SKEPTIC PERSONA"}
+	cache := &ContextCache{entries: map[string]LLMModel{SharedPrompt("sessions expire early"): cached}}
+	model := cache.Model(fallback)
+
+	resp, err := model.GenerateContent(context.Background(), genai.Text(prompt))
+	if err != nil {
+		t.Fatalf("GenerateContent failed: %v", err)
+	}
+	if cached.calls != 1 || fallback.calls != 0 {
+		t.Fatalf("Expected the cached model to be called, got %d cached and %d fallback calls", cached.calls, fallback.calls)
+	}
+	if text := string(resp.Candidates[0].Content.Parts[0].(genai.Text)); strings.Contains(text, "HIGH") {
+		t.Error("Expected the shared prompt to be stripped")
+	}
+
+	// Prompts for another error are sent whole
+	other := BuildPrompt("disk full", c, "std diff", "full diff")
+	if _, err := model.GenerateContent(context.Background(), genai.Text(other)); err != nil {
+		t.Fatalf("GenerateContent failed: %v", err)
+	}
+	if cached.calls != 1 || fallback.calls != 1 {
+		t.Errorf("Expected the fallback model to be called, got %d cached and %d fallback calls", cached.calls, fallback.calls)
+	}
+
+	var none *ContextCache
+	if none.Model(fallback) != LLMModel(fallback) {
+		t.Error("Expected a nil cache to return the model unchanged")
+	}
+	if err := none.Prepare(context.Background(), "disk full", ""); err != nil {
+		t.Errorf("Expected a nil cache to prepare nothing, got %v", err)
+	}
+}
+
+func TestHeadContext(t *testing.T) {
+	repo := createTestRepo(t, []struct{ path, content string }{
+		{"auth/session.go", "package auth\n"},
+		{"auth/token.go", "package auth\n\nconst ttl = 60\n"},
+		{"package-lock.json", "{}\n"},
+		{"auth/session.go", "package auth\n\nfunc Expire() {}\n"},
+	})
+	commits, head, err := CollectCommits(repo, AnalysisOptions{NumCommits: 3})
+	if err != nil {
+		t.Fatalf("CollectCommits failed: %v", err)
+	}
+
+	context, err := HeadContext(commits, head, nil, 1000)
+	if err != nil {
+		t.Fatalf("HeadContext failed: %v", err)
+	}
+	want := "=== auth/session.go ===\npackage auth\n\nfunc Expire() {}\n\n=== auth/token.go ===\npackage auth\n\nconst ttl = 60\n\n"
+	if context != want {
+		t.Errorf("Expected the HEAD versions of changed files, ignoring lock files, got:\n%s", context)
+	}
+
+	// Files over the budget are left out
+	context, err = HeadContext(commits, head, nil, 10)
+	if err != nil {
+		t.Fatalf("HeadContext failed: %v", err)
+	}
+	if context != "" {
+		t.Errorf("Expected no files within 10 tokens, got:\n%s", context)
+	}
+}
@@ -0,0 +1,80 @@
+package analyzer
+
+import (
+	"sync"
+)
+
+// PatchDedup analyzes each distinct patch once per run, so commits
+// cherry-picked or repeated with an identical patch (see gitdiff.PatchID)
+// reuse the first one's verdict instead of another LLM call. It is safe
+// for concurrent use.
+type PatchDedup struct {
+	errorMsg string
+
+	mu    sync.Mutex
+	calls map[string]*patchCall
+}
+
+// patchCall is the analysis of the first commit seen with a patch
+type patchCall struct {
+	done chan struct{}
+	hash string
+	res  *AnalysisResult
+	err  error
+}
+
+// NewPatchDedup returns a PatchDedup for a run analyzing errorMsg
+func NewPatchDedup(errorMsg string) *PatchDedup {
+	return &PatchDedup{errorMsg: errorMsg, calls: make(map[string]*patchCall)}
+}
+
+// Analyze returns analyze's result for diffCtx, unless a commit with the
+// same patch has been or is being analyzed: then it waits for that
+// analysis and returns a copy of its verdict marked DuplicateOf it. If
+// that analysis failed, diffCtx is analyzed on its own. A nil PatchDedup
+// always calls analyze.
+func (d *PatchDedup) Analyze(diffCtx *CommitDiffContext, analyze func() (*AnalysisResult, error)) (*AnalysisResult, error) {
+	if d == nil || diffCtx.Skipped || diffCtx.PatchID == "" {
+		return analyze()
+	}
+
+	d.mu.Lock()
+	call, seen := d.calls[diffCtx.PatchID]
+	if !seen {
+		call = &patchCall{done: make(chan struct{}), hash: diffCtx.Commit.Hash.String()}
+		d.calls[diffCtx.PatchID] = call
+	}
+	d.mu.Unlock()
+
+	if !seen {
+		call.res, call.err = analyze()
+		close(call.done)
+		return call.res, call.err
+	}
+	<-call.done
+	if call.err != nil || call.res == nil {
+		return analyze()
+	}
+	return call.res.duplicate(diffCtx, call.hash, d.errorMsg), nil
+}
+
+// duplicate copies the verdict for diffCtx, a commit with the same patch
+// as the analyzed commit hash, keeping diffCtx's own history and context
+func (ar *AnalysisResult) duplicate(diffCtx *CommitDiffContext, hash, errorMsg string) *AnalysisResult {
+	dup := &AnalysisResult{
+		Probability: ar.Probability,
+		Reasoning:   ar.Reasoning,
+		Stats:       diffCtx.Stats,
+		FollowUps:   diffCtx.FollowUps,
+		Metadata:    diffCtx.Metadata,
+		Hotspot:     diffCtx.Hotspot,
+		DuplicateOf: hash,
+		offline:     ar.offline,
+	}
+	if ar.Heuristics != nil {
+		dup.Heuristics = ScoreHeuristics(errorMsg, diffCtx)
+	}
+	dup.addSuspectDetails(diffCtx)
+	dup.Score(DefaultScoreWeights)
+	return dup
+}
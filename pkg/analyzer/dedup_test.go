@@ -0,0 +1,78 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestRunAnalysisDedupePatches(t *testing.T) {
+	// The newest commit re-applies the change of the third newest
+	repo := createTestRepo(t, []struct{ path, content string }{
+		{"main.go", "package main\n"},
+		{"main.go", "package main\n\nfunc main() {}\n"},
+		{"main.go", "package main\n"},
+		{"main.go", "package main\n\nfunc main() {}\n"},
+	})
+
+	run := func(dedupe bool) ([]CommitAnalysisResult, int) {
+		model := &mockModel{response: `{"probability": "HIGH", "reasoning": "mock"}`}
+		results, err := RunAnalysis(context.Background(), repo, model, AnalysisOptions{
+			NumCommits:    4,
+			ErrorMessage:  "main missing",
+			Workers:       1,
+			DedupePatches: dedupe,
+		})
+		if err != nil {
+			t.Fatalf("RunAnalysis failed: %v", err)
+		}
+		return results, model.calls
+	}
+
+	results, calls := run(true)
+	_, allCalls := run(false)
+	if calls != allCalls-1 {
+		t.Errorf("Expected one LLM call fewer with dedupe (%d without), got %d", allCalls, calls)
+	}
+
+	dup := results[2].Result
+	if dup == nil || dup.DuplicateOf != results[0].Hash {
+		t.Fatalf("Expected commit 2 to reuse the verdict of %s, got %+v", results[0].Hash, dup)
+	}
+	if dup.Probability != ProbHigh || dup.Reasoning != "mock" {
+		t.Errorf("Expected the reused verdict, got %s %q", dup.Probability, dup.Reasoning)
+	}
+	if dup.PromptTokens != 0 || dup.OutputTokens != 0 {
+		t.Errorf("Expected no tokens for a reused verdict, got %d/%d", dup.PromptTokens, dup.OutputTokens)
+	}
+	if got := dup.ToJSONResult(results[2].Hash, "").DuplicateOf; got != results[0].Hash[:8] {
+		t.Errorf("Expected duplicate_of %s, got %s", results[0].Hash[:8], got)
+	}
+	if results[1].Result.DuplicateOf != "" {
+		t.Errorf("Expected the revert to be analyzed on its own, got duplicate of %s", results[1].Result.DuplicateOf)
+	}
+}
+
+func TestPatchDedupRetriesFailures(t *testing.T) {
+	d := NewPatchDedup("error")
+	first := &CommitDiffContext{Commit: &object.Commit{Hash: plumbing.NewHash("1111")}, PatchID: "same"}
+	second := &CommitDiffContext{Commit: &object.Commit{Hash: plumbing.NewHash("2222")}, PatchID: "same"}
+
+	if _, err := d.Analyze(first, func() (*AnalysisResult, error) { return nil, fmt.Errorf("timeout") }); err == nil {
+		t.Fatal("Expected the first analysis to fail")
+	}
+	res, err := d.Analyze(second, func() (*AnalysisResult, error) {
+		return &AnalysisResult{Probability: ProbLow, Reasoning: "own"}, nil
+	})
+	if err != nil || res.Reasoning != "own" || res.DuplicateOf != "" {
+		t.Errorf("Expected a failed analysis to be retried on its own, got %+v, %v", res, err)
+	}
+
+	var none *PatchDedup
+	if res, _ := none.Analyze(second, func() (*AnalysisResult, error) { return &AnalysisResult{Reasoning: "direct"}, nil }); res.Reasoning != "direct" {
+		t.Errorf("Expected nil PatchDedup to analyze directly, got %+v", res)
+	}
+}
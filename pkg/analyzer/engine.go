@@ -1,11 +1,13 @@
 package analyzer
 
 import (
+	"cmp"
 	"context"
 	_ "embed"
 	"encoding/json"
 	"fmt"
 	"regexp"
+	"slices"
 	"strings"
 	"time"
 
@@ -14,10 +16,19 @@ import (
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/google/generative-ai-go/genai"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-//go:embed prompts/analysis.txt
-var analysisPromptTemplate string
+//go:embed prompts/instructions.txt
+var instructionsPromptTemplate string
+
+//go:embed prompts/commit.txt
+var commitPromptTemplate string
+
+// analysisPromptTemplate is the whole prompt: the instructions and bug
+// description shared by every commit of a run, then the commit's context
+var analysisPromptTemplate = instructionsPromptTemplate + commitPromptTemplate
 
 // LLMModel is an interface for LLM interaction, allowing for mocking in tests
 // and abstracting different provider-specific implementations.
@@ -59,29 +70,137 @@ type AnalysisResult struct {
 	Probability Probability `json:"probability"`
 	Reasoning   string      `json:"reasoning"`
 	Skipped     bool        `json:"-"`
+
+	// Skip says why a skipped commit was not analyzed (nil if unknown)
+	Skip *SkipInfo `json:"-"`
+
+	// Token usage reported by the LLM for this analysis (0 if unknown)
+	PromptTokens int32 `json:"-"`
+	OutputTokens int32 `json:"-"`
+
+	// Stats summarizes the commit's changes (nil if unknown)
+	Stats *gitdiff.DiffStats `json:"-"`
+
+	// FollowUps lists later commits that revert or fix the commit
+	FollowUps []gitdiff.FollowUp `json:"-"`
+
+	// Metadata describes the commit's author, date, and references (nil
+	// if unknown)
+	Metadata *CommitMetadata `json:"-"`
+
+	// Owners suggests who to ask about a HIGH or MEDIUM commit
+	Owners []gitdiff.Owner `json:"-"`
+
+	// AffectedTests are the test files to run to confirm a HIGH or MEDIUM
+	// commit is at fault
+	AffectedTests []string `json:"-"`
+
+	// Hotspot is the prior from the churn and bug-fix history of the
+	// commit's files (nil if unknown)
+	Hotspot *gitdiff.Hotspot `json:"-"`
+
+	// Heuristics holds the heuristic signals of the commit, which decide
+	// offline verdicts and feed the suspicion score (nil if not scored)
+	Heuristics *Heuristics `json:"-"`
+
+	// Suspicion blends the verdict, heuristics, and recency into a score
+	// from 0 to 1 for sorting (see Score)
+	Suspicion float64 `json:"-"`
+
+	// DuplicateOf is the hash of the commit with an identical patch whose
+	// verdict was reused (empty: analyzed on its own)
+	DuplicateOf string `json:"-"`
+
+	// Retries records the attempts the verdict took, if more than one
+	// (see RecordRetries)
+	Retries *RetryStats `json:"-"`
+
+	// Verification is the outcome of running the verification command at
+	// the commit, which may have changed Probability (nil if not verified)
+	Verification *Verification `json:"-"`
+
+	// offline is set when Heuristics decided the verdict
+	offline bool
 }
 
 // JSONResult represents the final output format for the CLI
 type JSONResult struct {
-	Type        string      `json:"type"`
-	Hash        string      `json:"hash"`
-	Message     string      `json:"message,omitempty"`
-	Probability Probability `json:"probability"`
-	Reasoning   string      `json:"reasoning"`
+	Type    string `json:"type"`
+	Repo    string `json:"repo,omitempty"`
+	Hash    string `json:"hash"`
+	Message string `json:"message,omitempty"`
+
+	// Status is StatusAnalyzed for a verdict, StatusSkipped or StatusError
+	// for a commit without one, which then has no probability or reasoning
+	Status string `json:"status"`
+
+	Probability Probability        `json:"probability,omitempty"`
+	Reasoning   string             `json:"reasoning,omitempty"`
+	Stats       *gitdiff.DiffStats `json:"stats,omitempty"`
+	FollowUps   []gitdiff.FollowUp `json:"follow_ups,omitempty"`
+	Owners      []gitdiff.Owner    `json:"owners,omitempty"`
+	Hotspot     *gitdiff.Hotspot   `json:"hotspot,omitempty"`
+	Heuristics  *Heuristics        `json:"heuristics,omitempty"`
+	Suspicion   float64            `json:"suspicion,omitempty"`
+	DuplicateOf string             `json:"duplicate_of,omitempty"`
+	Retries     *RetryStats        `json:"retries,omitempty"`
+
+	// AffectedTests are the test files to run to confirm a HIGH or MEDIUM
+	// commit is at fault
+	AffectedTests []string `json:"affected_tests,omitempty"`
+
+	// Verification is the outcome of running the verification command at
+	// the commit and its parent
+	Verification *Verification `json:"verification,omitempty"`
+
+	// Skip says why a skipped commit was not analyzed
+	Skip *SkipInfo `json:"skip,omitempty"`
+
+	// Error and ErrorKind describe why the analysis of a commit failed
+	// (see NewErrorResult)
+	Error     string `json:"error,omitempty"`
+	ErrorKind string `json:"error_kind,omitempty"`
+
+	// SchemaVersion is the SchemaVersion of the record's format
+	SchemaVersion int `json:"schema_version"`
+
+	// Author, date, issue references, and changed-files count, if known
+	*CommitMetadata
 }
 
 // Summary represents the final analysis summary
 type Summary struct {
-	Type    string `json:"type"`
-	Total   int    `json:"total"`
-	High    int    `json:"high"`
-	Medium  int    `json:"medium"`
-	Low     int    `json:"low"`
-	Skipped int    `json:"skipped"`
-		Errors   int    `json:"errors"`
-		Duration string `json:"duration"`
-		Model    string `json:"model"`
-	}
+	Type     string `json:"type"`
+	Total    int    `json:"total"`
+	High     int    `json:"high"`
+	Medium   int    `json:"medium"`
+	Low      int    `json:"low"`
+	Skipped  int    `json:"skipped"`
+	Errors   int    `json:"errors"`
+	Duration string `json:"duration"`
+	Model    string `json:"model"`
+
+	// OverBudget counts the skipped commits the run's deadline left no
+	// time for (see Budget)
+	OverBudget int `json:"over_budget,omitempty"`
+
+	// Partial marks the summary of a run interrupted before every commit
+	// was analyzed
+	Partial bool `json:"partial,omitempty"`
+
+	// ErrorKinds breaks errors down by ErrorKind
+	ErrorKinds map[string]int `json:"error_kinds,omitempty"`
+
+	// SkipReasons breaks the commits skipped for their changes down by
+	// SkipReason
+	SkipReasons map[string]int `json:"skip_reasons,omitempty"`
+
+	// Ranking lists the HIGH and MEDIUM commits, most suspicious first
+	Ranking []string `json:"ranking,omitempty"`
+
+	// SchemaVersion is the SchemaVersion of the record's format
+	SchemaVersion int `json:"schema_version"`
+}
 
 // LogEntry represents a structured log message
 type LogEntry struct {
@@ -89,101 +208,209 @@ type LogEntry struct {
 	Level     string `json:"level"`
 	Msg       string `json:"msg"`
 	Timestamp string `json:"timestamp"`
+
+	// Commit and ErrorKind identify the commit and kind of error of a
+	// failed analysis (see NewErrorEntry)
+	Commit    string `json:"commit,omitempty"`
+	ErrorKind string `json:"error_kind,omitempty"`
+
+	// Skip says why the commit was skipped (see NewSkipEntry)
+	Skip *SkipInfo `json:"skip,omitempty"`
+
+	// DebugFile holds the prompt and raw response of a response that
+	// could not be parsed (see SaveParseFailure)
+	DebugFile string `json:"debug_file,omitempty"`
+
+	// SchemaVersion is the SchemaVersion of the record's format
+	SchemaVersion int `json:"schema_version"`
 }
 
 // NewLogEntry creates a new LogEntry with the current timestamp
 func NewLogEntry(level, msg string) LogEntry {
 	return LogEntry{
-		Type:      "log",
+		Type:      RecordLog,
 		Level:     level,
 		Msg:       msg,
 		Timestamp: time.Now().UTC().Format(time.RFC3339),
+
+		SchemaVersion: SchemaVersion,
+	}
+}
+
+// NewErrorEntry creates an ERROR LogEntry for commit, whose analysis
+// failed with err. If debugDir is not empty, the prompt and raw response
+// of an unparsable response are saved there, and the entry names the
+// file; failing to save them is noted in the message.
+func NewErrorEntry(msg, commit string, err error, debugDir string) LogEntry {
+	entry := NewLogEntry("ERROR", msg)
+	entry.Commit = commit
+	entry.ErrorKind = cmp.Or(ErrorKind(err), ErrorKindOther)
+	if debugDir != "" {
+		path, saveErr := SaveParseFailure(debugDir, err)
+		if saveErr != nil {
+			entry.Msg += fmt.Sprintf(" (raw response not saved: %v)", saveErr)
+		}
+		entry.DebugFile = path
+	}
+	return entry
+}
+
+// NewSkipEntry creates an INFO LogEntry for commit, skipped for skip
+// (nil if unknown), whose hash is abbreviated as display in the message
+func NewSkipEntry(display, commit string, skip *SkipInfo) LogEntry {
+	entry := NewLogEntry("INFO", fmt.Sprintf("Commit: %s | [Skipped - %s]", display, skip))
+	entry.Commit = commit
+	entry.Skip = skip
+	return entry
+}
+
+// NewErrorResult creates the StatusError record of a commit whose
+// analysis failed with err
+func NewErrorResult(hash, message string, err error) JSONResult {
+	return JSONResult{
+		Type:      RecordResult,
+		Hash:      hash,
+		Message:   TruncateCommitMessage(message, DefaultCommitMessageMaxLength),
+		Status:    StatusError,
+		Error:     err.Error(),
+		ErrorKind: cmp.Or(ErrorKind(err), ErrorKindOther),
+
+		SchemaVersion: SchemaVersion,
 	}
 }
 
-// ToJSONResult converts an internal AnalysisResult to the CLI-friendly JSONResult
+// Result statuses, the "status" field of result records
+const (
+	StatusAnalyzed = "analyzed"
+	StatusSkipped  = "skipped"
+	StatusError    = "error"
+)
+
+// ToJSONResult converts an internal AnalysisResult to the CLI-friendly
+// JSONResult. A skipped result converts to a StatusSkipped record without
+// a verdict.
 func (ar *AnalysisResult) ToJSONResult(hash string, message string) JSONResult {
+	if ar.Skipped {
+		return JSONResult{
+			Type:    RecordResult,
+			Hash:    hash,
+			Message: TruncateCommitMessage(message, DefaultCommitMessageMaxLength),
+			Status:  StatusSkipped,
+			Stats:   ar.Stats,
+			Skip:    ar.Skip,
+
+			SchemaVersion:  SchemaVersion,
+			CommitMetadata: ar.Metadata,
+		}
+	}
 	return JSONResult{
-		Type:        "result",
+		Type:        RecordResult,
 		Hash:        hash,
 		Message:     TruncateCommitMessage(message, DefaultCommitMessageMaxLength),
+		Status:      StatusAnalyzed,
 		Probability: ar.Probability,
 		Reasoning:   ar.Reasoning,
+		Stats:       ar.Stats,
+		FollowUps:   ar.FollowUps,
+		Owners:      ar.Owners,
+		Hotspot:     ar.Hotspot,
+		Heuristics:  ar.Heuristics,
+		Suspicion:   ar.Suspicion,
+		DuplicateOf: ar.DuplicateOf[:min(8, len(ar.DuplicateOf))],
+		Retries:     ar.Retries,
+
+		AffectedTests:  ar.AffectedTests,
+		Verification:   ar.Verification,
+		SchemaVersion:  SchemaVersion,
+		CommitMetadata: ar.Metadata,
+	}
+}
+
+// RecordRetries attaches stats to the result if the verdict took more
+// than one attempt
+func (ar *AnalysisResult) RecordRetries(stats RetryStats) {
+	if ar != nil {
+		ar.Retries = stats.retried()
 	}
 }
 
 // AnalyzeCommit performs the dual-context analysis on a single commit.
 // The model parameter accepts any LLMModel implementation (including *genai.GenerativeModel).
-func AnalyzeCommit(ctx context.Context, r *git.Repository, c, headCommit *object.Commit, errorMsg string, model LLMModel) (*AnalysisResult, error) {
-	// 1. Standard Diff (C vs Parent)
-	// For the very first commit, parent is empty. Handle gracefully.
-	var parent *object.Commit
-	if len(c.ParentHashes) > 0 {
-		var err error
-		parent, err = c.Parent(0)
-		if err != nil {
-			return nil, fmt.Errorf("getting parent commit for %s: %w", c.Hash.String()[:8], err)
-		}
-	}
+func AnalyzeCommit(ctx context.Context, r *git.Repository, c, headCommit *object.Commit, errorMsg string, model LLMModel) (result *AnalysisResult, err error) {
+	ctx, span := tracer.Start(ctx, "AnalyzeCommit", trace.WithAttributes(
+		attribute.String("git.commit", c.Hash.String()),
+	))
+	defer func() { endSpan(span, err) }()
 
-	stdDiff, modifiedFiles, err := gitdiff.GetStandardDiff(c, parent)
+	diffCtx, err := ExtractDiffsContext(ctx, r, c, headCommit, gitdiff.Options{ErrorMessage: errorMsg})
 	if err != nil {
-		return nil, fmt.Errorf("getting standard diff: %w", err)
+		return nil, err
 	}
+	return AnalyzeWithDiffs(ctx, diffCtx, errorMsg, model)
+}
 
-	if len(modifiedFiles) == 0 {
-		return &AnalysisResult{Skipped: true}, nil
-	}
-
-	// 2. Full Comparison Diff (C vs HEAD), filtered by modifiedFiles
-	fullDiff, err := gitdiff.GetFullDiff(c, headCommit, modifiedFiles)
-	if err != nil {
-		return nil, fmt.Errorf("getting full diff: %w", err)
+// recordUsage copies token counts from the LLM response, if present
+func (ar *AnalysisResult) recordUsage(resp *genai.GenerateContentResponse) {
+	if resp.UsageMetadata == nil {
+		return
 	}
+	ar.PromptTokens = resp.UsageMetadata.PromptTokenCount
+	ar.OutputTokens = resp.UsageMetadata.CandidatesTokenCount
+}
 
-	// 3. Construct Prompt
-	prompt := BuildPrompt(errorMsg, c, stdDiff, fullDiff)
+// BuildPrompt constructs the multi-step analytical prompt for the LLM.
+// It incorporates the bug description, commit diffs, and the skeptical persona instructions.
+// The prompt templates are loaded from prompts/instructions.txt and
+// prompts/commit.txt via go:embed.
+func BuildPrompt(errorMsg string, c *object.Commit, stdDiff, fullDiff string) string {
+	return BuildPromptWithSymbols(errorMsg, c, "", stdDiff, fullDiff)
+}
 
-	// 4. Call Gemini
-	resp, err := model.GenerateContent(ctx, genai.Text(prompt))
-	if err != nil {
-		return nil, fmt.Errorf("gemini api call: %w", err)
-	}
+// BuildPromptWithSymbols is BuildPrompt with the commit's changed symbols,
+// as rendered by gitdiff.FormatChangedSymbols, listed ahead of the diffs
+// to help the LLM connect names in the error to the change.
+func BuildPromptWithSymbols(errorMsg string, c *object.Commit, symbols, stdDiff, fullDiff string) string {
+	return buildPrompt(errorMsg, c, NewCommitMetadata(c, nil), nil, symbols, "(not checked)", "(not checked)", stdDiff, fullDiff)
+}
 
-	if len(resp.Candidates) == 0 || len(resp.Candidates[0].Content.Parts) == 0 {
-		return nil, fmt.Errorf("empty response from gemini for commit %s", c.Hash.String()[:8])
+// BuildPromptFromContext builds the prompt for pre-extracted diffs,
+// including the commit's metadata, the repository's tech stack, its
+// changed symbols and their callers, and the later commits that revert or
+// fix it
+func BuildPromptFromContext(errorMsg string, diffCtx *CommitDiffContext) string {
+	followUps := gitdiff.FormatFollowUps(diffCtx.FollowUps)
+	if followUps == "" {
+		followUps = "(none found)"
 	}
-
-	// Parse Response
-	var result AnalysisResult
-	found := false
-
-	for _, part := range resp.Candidates[0].Content.Parts {
-		if txt, ok := part.(genai.Text); ok {
-			found = true
-			cleanTxt := FindJSONBlock(string(txt))
-			if cleanTxt == "" {
-				return nil, fmt.Errorf("no JSON found in response for %s", c.Hash.String()[:8])
-			}
-			if err := json.Unmarshal([]byte(cleanTxt), &result); err != nil {
-				return nil, fmt.Errorf("parsing JSON for %s: %v. Raw: %s", c.Hash.String()[:8], err, string(txt))
-			}
-			break // Found and parsed, exit loop
-		}
+	meta := diffCtx.Metadata
+	if meta == nil {
+		meta = NewCommitMetadata(diffCtx.Commit, nil)
 	}
-
-	if !found {
-		return nil, fmt.Errorf("no text content in gemini response for %s", c.Hash.String()[:8])
+	callers := gitdiff.FormatCallers(diffCtx.Callers)
+	if callers == "" {
+		callers = "(none listed)"
 	}
+	return buildPrompt(errorMsg, diffCtx.Commit, meta, diffCtx.TechStack, gitdiff.FormatChangedSymbols(diffCtx.Symbols), callers, followUps, diffCtx.StandardDiff, diffCtx.FullDiff)
+}
 
-	return &result, nil
+// SharedPrompt returns the beginning of every commit's prompt for
+// errorMsg: the instructions and the bug description, which a
+// ContextCache sends once per run
+func SharedPrompt(errorMsg string) string {
+	return fmt.Sprintf(instructionsPromptTemplate, errorMsg)
 }
 
-// BuildPrompt constructs the multi-step analytical prompt for the LLM.
-// It incorporates the bug description, commit diffs, and the skeptical persona instructions.
-// The prompt template is loaded from prompts/analysis.txt via go:embed.
-func BuildPrompt(errorMsg string, c *object.Commit, stdDiff, fullDiff string) string {
-	return fmt.Sprintf(analysisPromptTemplate, errorMsg, c.Hash.String(), c.Message, stdDiff, fullDiff)
+// buildPrompt fills the prompt templates
+func buildPrompt(errorMsg string, c *object.Commit, meta *CommitMetadata, stack *gitdiff.TechStack, symbols, callers, followUps, stdDiff, fullDiff string) string {
+	if symbols == "" {
+		symbols = "(none detected)"
+	}
+	techStack := stack.String()
+	if techStack == "" {
+		techStack = "(unknown)"
+	}
+	return SharedPrompt(errorMsg) + fmt.Sprintf(commitPromptTemplate, c.Hash.String(), meta.format(), c.Message,
+		strings.TrimRight(techStack, "\n"), strings.TrimRight(symbols, "\n"), strings.TrimRight(callers, "\n"), strings.TrimRight(followUps, "\n"), stdDiff, fullDiff)
 }
 
 // CommitDiffContext holds pre-extracted diff data for a commit.
@@ -194,14 +421,91 @@ type CommitDiffContext struct {
 	FullDiff      string
 	ModifiedFiles []string
 	Skipped       bool // true if no relevant files were modified
+
+	// Skip says why the commit is skipped (nil: it is not)
+	Skip *SkipInfo
+
+	// Chunks splits a commit too large for one LLM call into groups of
+	// files that are analyzed separately (empty: analyze as a whole)
+	Chunks []*CommitDiffContext
+
+	// Stats counts the changed lines of every file in the commit
+	Stats *gitdiff.DiffStats
+
+	// NonFunctional explains why the commit cannot change behavior, such
+	// as "only whitespace changed" (empty: it may)
+	NonFunctional string
+
+	// Symbols lists the functions, methods, and types the commit changed
+	// in ModifiedFiles
+	Symbols []gitdiff.FileSymbols
+
+	// FollowUps lists the commits between this one and HEAD that revert
+	// or fix it
+	FollowUps []gitdiff.FollowUp
+
+	// Metadata describes the commit's author, date, and references
+	Metadata *CommitMetadata
+
+	// Owners suggests who to ask about the commit's files (see
+	// gitdiff.Options.Owners)
+	Owners []gitdiff.Owner
+
+	// AffectedTests are the test files likely to exercise the commit's
+	// files (see gitdiff.Options.Tests)
+	AffectedTests []string
+
+	// Hotspot is the churn and bug-fix prior of the commit's files (see
+	// gitdiff.Options.Hotspots)
+	Hotspot *gitdiff.Hotspot
+
+	// TechStack is the repository's dominant languages and frameworks,
+	// which the prompt names (see gitdiff.Options.TechStack)
+	TechStack *gitdiff.TechStack
+
+	// Callers are the call sites at HEAD of the functions the commit
+	// changed, which the prompt lists (see gitdiff.Options.Callers)
+	Callers []gitdiff.SymbolCallers
+
+	// Related are the files the commit did not change whose evolution the
+	// full diff includes (see gitdiff.Options.Related)
+	Related []gitdiff.RelatedFile
+
+	// PatchID identifies StandardDiff for reusing the verdict of an
+	// identical patch (see PatchDedup)
+	PatchID string
 }
 
 // ExtractDiffs extracts the dual-context diffs from a commit.
-// This function performs git operations and is NOT thread-safe with go-git.
-// Call this sequentially, then use AnalyzeWithDiffs for parallel LLM calls.
+// This function performs git operations and is NOT thread-safe with go-git:
+// call it sequentially on one repository, or use a RepoPool to extract
+// several commits at once, then use AnalyzeWithDiffs for parallel LLM calls.
 func ExtractDiffs(r *git.Repository, c, headCommit *object.Commit) (*CommitDiffContext, error) {
-	ctx := &CommitDiffContext{
-		Commit: c,
+	return ExtractDiffsContext(context.Background(), r, c, headCommit, gitdiff.Options{})
+}
+
+// ExtractDiffsContext is ExtractDiffs with a context used for tracing and
+// options controlling diff extraction (such as file filters).
+func ExtractDiffsContext(ctx context.Context, r *git.Repository, c, headCommit *object.Commit, opts gitdiff.Options) (diffCtx *CommitDiffContext, err error) {
+	_, span := tracer.Start(ctx, "ExtractDiffs", trace.WithAttributes(
+		attribute.String("git.commit", c.Hash.String()),
+	))
+	defer func() {
+		if diffCtx != nil {
+			span.SetAttributes(
+				attribute.Int("git.modified_files", len(diffCtx.ModifiedFiles)),
+				attribute.Int("diff.standard.bytes", len(diffCtx.StandardDiff)),
+				attribute.Int("diff.full.bytes", len(diffCtx.FullDiff)),
+				attribute.Bool("analysis.skipped", diffCtx.Skipped),
+			)
+		}
+		err = withKind(ErrorKindGit, err)
+		endSpan(span, err)
+	}()
+
+	diffCtx = &CommitDiffContext{
+		Commit:   c,
+		Metadata: NewCommitMetadata(c, headCommit),
 	}
 
 	// 1. Standard Diff (C vs Parent)
@@ -209,32 +513,136 @@ func ExtractDiffs(r *git.Repository, c, headCommit *object.Commit) (*CommitDiffC
 	if len(c.ParentHashes) > 0 {
 		var err error
 		parent, err = c.Parent(0)
+		if IsShallowBoundary(r, err) {
+			// The oldest commit of a shallow clone cannot be diffed
+			diffCtx.skip(SkipShallow)
+			return diffCtx, nil
+		}
 		if err != nil {
 			return nil, fmt.Errorf("getting parent commit for %s: %w", c.Hash.String()[:8], err)
 		}
 	}
 
-	stdDiff, modifiedFiles, err := gitdiff.GetStandardDiff(c, parent)
+	// Commits touching only ignored paths (lock files, CI configuration,
+	// excluded directories) are skipped from a comparison of tree entries,
+	// before any patch is built
+	paths, err := gitdiff.ChangedPaths(c, parent)
+	if err != nil {
+		return nil, fmt.Errorf("listing changed paths: %w", err)
+	}
+	if skip := SkipForPaths(paths, opts.Filter); skip != nil {
+		diffCtx.Skipped = true
+		diffCtx.Skip = skip
+		return diffCtx, nil
+	}
+
+	stats, err := gitdiff.StatsWithOptions(c, parent, opts)
+	if err != nil {
+		return nil, fmt.Errorf("getting diff stats: %w", err)
+	}
+	diffCtx.Stats = stats
+	diffCtx.Metadata.ChangedFiles = len(stats.Files)
+
+	chunks, err := gitdiff.GetStandardDiffChunks(c, parent, opts)
 	if err != nil {
 		return nil, fmt.Errorf("getting standard diff: %w", err)
 	}
 
-	if len(modifiedFiles) == 0 {
-		ctx.Skipped = true
-		return ctx, nil
+	if len(chunks) == 0 {
+		diffCtx.skip(SkipNoDiff)
+		return diffCtx, nil
+	}
+
+	var files []string
+	for _, chunk := range chunks {
+		files = append(files, chunk.Files...)
 	}
 
-	ctx.StandardDiff = stdDiff
-	ctx.ModifiedFiles = modifiedFiles
+	// Commits changing only a few lines of relevant files are skipped
+	// before any LLM call
+	if opts.MinChangedLines > 0 && stats.ChangedLines(files) < opts.MinChangedLines {
+		diffCtx.skip(SkipTrivial)
+		return diffCtx, nil
+	}
+
+	// Reformatting, comment edits, and import reordering cannot cause a
+	// bug; such commits get a LOW verdict without an LLM call
+	if !opts.AnalyzeNonFunctional {
+		reason, err := gitdiff.NonFunctionalChange(c, parent, files)
+		if err != nil {
+			return nil, fmt.Errorf("checking for functional changes: %w", err)
+		}
+		diffCtx.NonFunctional = reason
+	}
 
-	// 2. Full Comparison Diff (C vs HEAD)
-	fullDiff, err := gitdiff.GetFullDiff(c, headCommit, modifiedFiles)
+	symbols, err := gitdiff.ChangedSymbolsWithOptions(c, parent, files, opts)
 	if err != nil {
-		return nil, fmt.Errorf("getting full diff: %w", err)
+		return nil, fmt.Errorf("getting changed symbols: %w", err)
+	}
+	diffCtx.Symbols = symbols
+	diffCtx.Callers = opts.Callers.For(symbols)
+
+	// A commit already reverted or fixed is unlikely to be the current
+	// root cause; the LLM and the triager are told about it
+	followUps, err := gitdiff.FindFollowUps(c, headCommit)
+	if err != nil {
+		return nil, fmt.Errorf("finding reverts and fixes: %w", err)
+	}
+	diffCtx.FollowUps = followUps
+	diffCtx.Owners = opts.Owners.Suggest(files)
+	diffCtx.AffectedTests = opts.Tests.For(files)
+	diffCtx.Hotspot = opts.Hotspots.For(files)
+	diffCtx.TechStack = opts.TechStack
+
+	// 2. Full Comparison Diff (C vs HEAD), per chunk of files
+	var stdDiffs, fullDiffs []string
+	for _, chunk := range chunks {
+		fullDiff, err := gitdiff.GetFullDiffWithOptions(c, headCommit, chunk.Files, opts)
+		if err != nil {
+			return nil, fmt.Errorf("getting full diff: %w", err)
+		}
+		relatedDiff, related, err := opts.Related.Diff(c, headCommit, chunk.Files, opts)
+		if err != nil {
+			return nil, fmt.Errorf("getting related files' diff: %w", err)
+		}
+		if relatedDiff != "" {
+			fullDiff += "\n\n" + relatedDiff
+			diffCtx.Related = append(diffCtx.Related, related...)
+		}
+		stdDiff, fullDiff := gitdiff.FitPromptDiffs(chunk.Diff, fullDiff, opts.MaxPromptDiffSize)
+		diffCtx.ModifiedFiles = append(diffCtx.ModifiedFiles, chunk.Files...)
+		stdDiffs = append(stdDiffs, stdDiff)
+		fullDiffs = append(fullDiffs, fullDiff)
+		if len(chunks) > 1 {
+			diffCtx.Chunks = append(diffCtx.Chunks, &CommitDiffContext{
+				Commit:        c,
+				StandardDiff:  stdDiff,
+				FullDiff:      fullDiff,
+				ModifiedFiles: chunk.Files,
+				Symbols:       symbolsIn(symbols, chunk.Files),
+				Callers:       opts.Callers.For(symbolsIn(symbols, chunk.Files)),
+				FollowUps:     followUps,
+				Metadata:      diffCtx.Metadata,
+				TechStack:     opts.TechStack,
+			})
+		}
 	}
-	ctx.FullDiff = fullDiff
+	diffCtx.StandardDiff = strings.Join(stdDiffs, "")
+	diffCtx.FullDiff = strings.Join(fullDiffs, "\n")
+	diffCtx.PatchID = gitdiff.PatchID(diffCtx.StandardDiff)
+
+	return diffCtx, nil
+}
 
-	return ctx, nil
+// symbolsIn returns the changed symbols of the given files
+func symbolsIn(symbols []gitdiff.FileSymbols, files []string) []gitdiff.FileSymbols {
+	var result []gitdiff.FileSymbols
+	for _, fs := range symbols {
+		if slices.Contains(files, fs.Path) {
+			result = append(result, fs)
+		}
+	}
+	return result
 }
 
 // AnalyzeWithDiffs performs LLM analysis using pre-extracted diffs.
@@ -242,20 +650,59 @@ func ExtractDiffs(r *git.Repository, c, headCommit *object.Commit) (*CommitDiffC
 // The model parameter accepts any LLMModel implementation (including *genai.GenerativeModel).
 func AnalyzeWithDiffs(ctx context.Context, diffCtx *CommitDiffContext, errorMsg string, model LLMModel) (*AnalysisResult, error) {
 	if diffCtx.Skipped {
-		return &AnalysisResult{Skipped: true}, nil
+		return &AnalysisResult{Skipped: true, Skip: diffCtx.Skip, Stats: diffCtx.Stats, Metadata: diffCtx.Metadata}, nil
+	}
+	if diffCtx.NonFunctional != "" {
+		return &AnalysisResult{
+			Probability: ProbLow,
+			Reasoning:   fmt.Sprintf("No functional change (%s); not sent to the LLM.", diffCtx.NonFunctional),
+			Stats:       diffCtx.Stats,
+			FollowUps:   diffCtx.FollowUps,
+			Metadata:    diffCtx.Metadata,
+			Hotspot:     diffCtx.Hotspot,
+		}, nil
+	}
+	if len(diffCtx.Chunks) > 0 {
+		result, err := analyzeChunks(ctx, diffCtx, errorMsg, model)
+		if err != nil {
+			return nil, err
+		}
+		result.Stats = diffCtx.Stats
+		result.FollowUps = diffCtx.FollowUps
+		result.Metadata = diffCtx.Metadata
+		result.Hotspot = diffCtx.Hotspot
+		result.Heuristics = ScoreHeuristics(errorMsg, diffCtx)
+		result.addSuspectDetails(diffCtx)
+		result.Score(DefaultScoreWeights)
+		return result, nil
 	}
 
 	// Build prompt with pre-extracted diffs
-	prompt := BuildPrompt(errorMsg, diffCtx.Commit, diffCtx.StandardDiff, diffCtx.FullDiff)
+	_, promptSpan := tracer.Start(ctx, "BuildPrompt")
+	prompt := BuildPromptFromContext(errorMsg, diffCtx)
+	promptSpan.SetAttributes(attribute.Int("prompt.bytes", len(prompt)))
+	promptSpan.End()
 
 	// Call Gemini (thread-safe)
-	resp, err := model.GenerateContent(ctx, genai.Text(prompt))
+	llmCtx, llmSpan := tracer.Start(ctx, "GenerateContent", trace.WithAttributes(
+		attribute.String("git.commit", diffCtx.Commit.Hash.String()),
+	))
+	resp, err := model.GenerateContent(llmCtx, genai.Text(prompt))
 	if err != nil {
-		return nil, fmt.Errorf("gemini api call: %w", err)
+		err = fmt.Errorf("gemini api call: %w", err)
+		endSpan(llmSpan, err)
+		return nil, err
 	}
+	if resp.UsageMetadata != nil {
+		llmSpan.SetAttributes(
+			attribute.Int("llm.prompt_tokens", int(resp.UsageMetadata.PromptTokenCount)),
+			attribute.Int("llm.output_tokens", int(resp.UsageMetadata.CandidatesTokenCount)),
+		)
+	}
+	llmSpan.End()
 
 	if len(resp.Candidates) == 0 || len(resp.Candidates[0].Content.Parts) == 0 {
-		return nil, fmt.Errorf("empty response from gemini for commit %s", diffCtx.Commit.Hash.String()[:8])
+		return nil, newParseError(diffCtx, prompt, "", fmt.Errorf("empty response from gemini for commit %s", diffCtx.Commit.Hash.String()[:8]))
 	}
 
 	// Parse Response
@@ -267,22 +714,40 @@ func AnalyzeWithDiffs(ctx context.Context, diffCtx *CommitDiffContext, errorMsg
 			found = true
 			cleanTxt := FindJSONBlock(string(txt))
 			if cleanTxt == "" {
-				return nil, fmt.Errorf("no JSON found in response for %s", diffCtx.Commit.Hash.String()[:8])
+				return nil, newParseError(diffCtx, prompt, string(txt), fmt.Errorf("no JSON found in response for %s", diffCtx.Commit.Hash.String()[:8]))
 			}
 			if err := json.Unmarshal([]byte(cleanTxt), &result); err != nil {
-				return nil, fmt.Errorf("parsing JSON for %s: %v. Raw: %s", diffCtx.Commit.Hash.String()[:8], err, string(txt))
+				return nil, newParseError(diffCtx, prompt, string(txt), fmt.Errorf("parsing JSON for %s: %v. Raw: %s", diffCtx.Commit.Hash.String()[:8], err, string(txt)))
 			}
 			break
 		}
 	}
 
 	if !found {
-		return nil, fmt.Errorf("no text content in gemini response for %s", diffCtx.Commit.Hash.String()[:8])
+		return nil, newParseError(diffCtx, prompt, "", fmt.Errorf("no text content in gemini response for %s", diffCtx.Commit.Hash.String()[:8]))
 	}
 
+	result.recordUsage(resp)
+	result.Stats = diffCtx.Stats
+	result.FollowUps = diffCtx.FollowUps
+	result.Metadata = diffCtx.Metadata
+	result.Hotspot = diffCtx.Hotspot
+	result.Heuristics = ScoreHeuristics(errorMsg, diffCtx)
+	result.addSuspectDetails(diffCtx)
+	result.Score(DefaultScoreWeights)
 	return &result, nil
 }
 
+// addSuspectDetails attaches who to ask about the commit, and which tests
+// to run to confirm it, to HIGH and MEDIUM results, the ones someone has
+// to follow up on
+func (ar *AnalysisResult) addSuspectDetails(diffCtx *CommitDiffContext) {
+	if ar.Probability == ProbHigh || ar.Probability == ProbMedium {
+		ar.Owners = diffCtx.Owners
+		ar.AffectedTests = diffCtx.AffectedTests
+	}
+}
+
 // jsonFallbackRegex is used as a fallback for extracting JSON when brace matching fails.
 // Compiled once at package initialization for efficiency.
 var jsonFallbackRegex = regexp.MustCompile(`(?s)\{[^{}]*"probability"\s*:\s*"[^"]*"[^{}]*\}`)
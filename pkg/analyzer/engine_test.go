@@ -2,9 +2,12 @@ package analyzer
 
 import (
 	"encoding/json"
+	"errors"
 	"strings"
 	"testing"
 
+	"github.com/kerneldump/git-dual-context/pkg/gitdiff"
+
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
 )
@@ -66,6 +69,76 @@ func TestBuildPrompt(t *testing.T) {
 			t.Errorf("prompt missing section: %s", section)
 		}
 	}
+	if !strings.Contains(prompt, "CHANGED SYMBOLS (functions, methods, and types this commit touches):\n(none detected)\n") {
+		t.Error("prompt should report no changed symbols")
+	}
+}
+
+func TestBuildPromptWithSymbols(t *testing.T) {
+	c := &object.Commit{
+		Hash:    plumbing.NewHash("a1b2c3d4"),
+		Message: "test message",
+	}
+	symbols := "server.go: method Server.Handle (modified)\n"
+
+	prompt := BuildPromptWithSymbols("panic in Handle", c, symbols, "std diff content", "full diff content")
+
+	if !strings.Contains(prompt, "this commit touches):\nserver.go: method Server.Handle (modified)\n\nCALLERS AT HEAD") {
+		t.Errorf("prompt missing changed symbols:\n%s", prompt)
+	}
+	if strings.Index(prompt, "CHANGED SYMBOLS") > strings.Index(prompt, "STANDARD DIFF") {
+		t.Error("changed symbols should precede the diffs")
+	}
+}
+
+func TestBuildPromptFromContext(t *testing.T) {
+	c := &object.Commit{
+		Hash:    plumbing.NewHash("a1b2c3d4"),
+		Message: "Shorten session TTL",
+	}
+	diffCtx := &CommitDiffContext{
+		Commit:       c,
+		StandardDiff: "std diff content",
+		FullDiff:     "full diff content",
+		FollowUps: []gitdiff.FollowUp{
+			{Hash: "9f8e7d6c5b4a39281706f5e4d3c2b1a098765432", Subject: "Revert \"Shorten session TTL\"", Kind: gitdiff.FollowUpRevert, Evidence: "revert message"},
+		},
+	}
+
+	prompt := BuildPromptFromContext("sessions expire early", diffCtx)
+	if !strings.Contains(prompt, "revert or fix it):\n9f8e7d6c (Revert \"Shorten session TTL\") reverts this commit (revert message)\n") {
+		t.Errorf("prompt missing later history:\n%s", prompt)
+	}
+
+	diffCtx.FollowUps = nil
+	if prompt := BuildPromptFromContext("sessions expire early", diffCtx); !strings.Contains(prompt, "revert or fix it):\n(none found)\n") {
+		t.Errorf("prompt should report no follow-ups:\n%s", prompt)
+	}
+	if prompt := BuildPrompt("sessions expire early", c, "std", "full"); !strings.Contains(prompt, "revert or fix it):\n(not checked)\n") {
+		t.Errorf("prompt without diff context should say history was not checked:\n%s", prompt)
+	}
+
+	if !strings.Contains(prompt, "and frameworks):\n(unknown)\n") {
+		t.Errorf("prompt should report an unknown tech stack:\n%s", prompt)
+	}
+	diffCtx.TechStack = &gitdiff.TechStack{Languages: []gitdiff.LanguageShare{{Name: "Elixir", Fraction: 0.9}}, Frameworks: []string{"Phoenix"}}
+	if prompt := BuildPromptFromContext("sessions expire early", diffCtx); !strings.Contains(prompt, "and frameworks):\nLanguages: Elixir (90%)\nFrameworks: Phoenix\n\nCHANGED SYMBOLS") {
+		t.Errorf("prompt missing tech stack:\n%s", prompt)
+	}
+
+	if !strings.Contains(prompt, "called from today):\n(none listed)\n") {
+		t.Errorf("prompt should report no callers:\n%s", prompt)
+	}
+	diffCtx.Callers = []gitdiff.SymbolCallers{{Path: "store/session.go", Symbol: "TTL", Total: 1, Sites: []gitdiff.CallSite{{Path: "api/login.go", Line: 12, Caller: "Login", Code: "exp := store.TTL()"}}}}
+	if prompt := BuildPromptFromContext("sessions expire early", diffCtx); !strings.Contains(prompt, "called from today):\nstore/session.go TTL, called in 1 place:\n  api/login.go:12, in Login:\n    exp := store.TTL()\n\nLATER HISTORY") {
+		t.Errorf("prompt missing callers:\n%s", prompt)
+	}
+
+	diffCtx.Metadata = &CommitMetadata{Author: "Dana <dana@example.com>", Issues: []string{"#12"}, ChangedFiles: 3}
+	if prompt := BuildPromptFromContext("sessions expire early", diffCtx); !strings.Contains(prompt, "Hash: "+c.Hash.String()+"\nAuthor: Dana <dana@example.com>\n") ||
+		!strings.Contains(prompt, "References: #12\nChanged files: 3\nMessage: Shorten session TTL") {
+		t.Errorf("prompt missing commit metadata:\n%s", prompt)
+	}
 }
 
 func TestNoisyJSONParsing(t *testing.T) {
@@ -96,8 +169,11 @@ func TestJSONResultSerialization(t *testing.T) {
 		Type:        "result",
 		Hash:        "12345678",
 		Message:     "Fix bug",
+		Status:      StatusAnalyzed,
 		Probability: ProbHigh,
 		Reasoning:   "Testing serialization",
+
+		SchemaVersion: SchemaVersion,
 	}
 
 	data, err := json.Marshal(result)
@@ -105,7 +181,7 @@ func TestJSONResultSerialization(t *testing.T) {
 		t.Fatalf("failed to marshal JSONResult: %v", err)
 	}
 
-	expected := `{"type":"result","hash":"12345678","message":"Fix bug","probability":"HIGH","reasoning":"Testing serialization"}`
+	expected := `{"type":"result","hash":"12345678","message":"Fix bug","status":"analyzed","probability":"HIGH","reasoning":"Testing serialization","schema_version":2}`
 	if string(data) != expected {
 		t.Errorf("expected %s, got %s", expected, string(data))
 	}
@@ -161,6 +237,8 @@ func TestLogEntrySerialization(t *testing.T) {
 		Level:     "INFO",
 		Msg:       "Started analysis",
 		Timestamp: "2026-01-17T17:00:00Z",
+
+		SchemaVersion: SchemaVersion,
 	}
 
 	data, err := json.Marshal(entry)
@@ -168,7 +246,7 @@ func TestLogEntrySerialization(t *testing.T) {
 		t.Fatalf("failed to marshal LogEntry: %v", err)
 	}
 
-	expected := `{"type":"log","level":"INFO","msg":"Started analysis","timestamp":"2026-01-17T17:00:00Z"}`
+	expected := `{"type":"log","level":"INFO","msg":"Started analysis","timestamp":"2026-01-17T17:00:00Z","schema_version":2}`
 	if string(data) != expected {
 		t.Errorf("expected %s, got %s", expected, string(data))
 	}
@@ -312,3 +390,28 @@ func TestAnalysisPromptTemplateLoaded(t *testing.T) {
 		}
 	}
 }
+
+func TestJSONResultStatus(t *testing.T) {
+	analyzed := (&AnalysisResult{Probability: ProbLow, Reasoning: "r"}).ToJSONResult("12345678", "msg")
+	if analyzed.Status != StatusAnalyzed || analyzed.Probability != ProbLow {
+		t.Errorf("Expected an analyzed record with its verdict, got %+v", analyzed)
+	}
+
+	skip := &SkipInfo{Reason: SkipTests, IgnoredFiles: map[string]int{"test": 2}}
+	skipped := (&AnalysisResult{Skipped: true, Skip: skip}).ToJSONResult("12345678", "msg")
+	if skipped.Status != StatusSkipped || skipped.Skip != skip || skipped.Probability != "" {
+		t.Errorf("Expected a skipped record with its reason and no verdict, got %+v", skipped)
+	}
+
+	failed := NewErrorResult("12345678", "msg", &ParseError{Commit: "12345678", err: errors.New("bad")})
+	if failed.Status != StatusError || failed.ErrorKind != ErrorKindParse || failed.Error == "" {
+		t.Errorf("Expected an error record with its kind, got %+v", failed)
+	}
+	data, err := json.Marshal(failed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "probability") {
+		t.Errorf("Expected no probability in an error record, got %s", data)
+	}
+}
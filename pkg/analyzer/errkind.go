@@ -0,0 +1,80 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"google.golang.org/api/googleapi"
+)
+
+// Kinds of analysis errors, reported as error_kind so quota problems can
+// be told from bugs
+const (
+	// ErrorKindRateLimited: the LLM API rejected calls over quota
+	ErrorKindRateLimited = "rate_limited"
+
+	// ErrorKindTimeout: the commit's timeout expired
+	ErrorKindTimeout = "timeout"
+
+	// ErrorKindParse: the LLM's response held no valid verdict
+	ErrorKindParse = "parse_failure"
+
+	// ErrorKindGit: reading the commit or its diffs failed
+	ErrorKindGit = "git_error"
+
+	// ErrorKindCancelled: the run was cancelled
+	ErrorKindCancelled = "cancelled"
+
+	// ErrorKindOther: any other error, such as a rejected API key
+	ErrorKindOther = "other"
+)
+
+// kindError attaches an error kind to an error without changing its
+// message
+type kindError struct {
+	kind string
+	err  error
+}
+
+func (e *kindError) Error() string { return e.err.Error() }
+func (e *kindError) Unwrap() error { return e.err }
+
+// withKind marks err, if not nil, as of kind
+func withKind(kind string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &kindError{kind: kind, err: err}
+}
+
+// ErrorKind classifies an analysis error as one of the ErrorKind
+// constants, or returns "" for a nil error
+func ErrorKind(err error) string {
+	if err == nil {
+		return ""
+	}
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusTooManyRequests {
+		return ErrorKindRateLimited
+	}
+	if msg := strings.ToLower(err.Error()); strings.Contains(msg, "resource exhausted") || strings.Contains(msg, "resourceexhausted") || strings.Contains(msg, "quota") {
+		return ErrorKindRateLimited
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorKindTimeout
+	}
+	if errors.Is(err, context.Canceled) {
+		return ErrorKindCancelled
+	}
+	var pe *ParseError
+	if errors.As(err, &pe) {
+		return ErrorKindParse
+	}
+	var ke *kindError
+	if errors.As(err, &ke) {
+		return ke.kind
+	}
+	return ErrorKindOther
+}
@@ -0,0 +1,58 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"google.golang.org/api/googleapi"
+)
+
+func TestErrorKind(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"nil", nil, ""},
+		{"rate limited", fmt.Errorf("gemini api call: %w", &googleapi.Error{Code: 429}), ErrorKindRateLimited},
+		{"quota message", errors.New("rpc error: code = ResourceExhausted desc = Resource has been exhausted (e.g. check quota)"), ErrorKindRateLimited},
+		{"retries exhausted", fmt.Errorf("max retries (3) exceeded: %w", &googleapi.Error{Code: 429}), ErrorKindRateLimited},
+		{"timeout", fmt.Errorf("gemini api call: %w", context.DeadlineExceeded), ErrorKindTimeout},
+		{"cancelled", context.Canceled, ErrorKindCancelled},
+		{"cancelled git", withKind(ErrorKindGit, fmt.Errorf("getting diff stats: %w", context.Canceled)), ErrorKindCancelled},
+		{"parse", fmt.Errorf("chunk 1/2: %w", &ParseError{err: errors.New("no JSON found")}), ErrorKindParse},
+		{"git", withKind(ErrorKindGit, errors.New("object not found")), ErrorKindGit},
+		{"server error", &googleapi.Error{Code: 500}, ErrorKindOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ErrorKind(tt.err); got != tt.want {
+				t.Errorf("ErrorKind(%v) = %q, want %q", tt.err, got, tt.want)
+			}
+		})
+	}
+	if err := withKind(ErrorKindGit, errors.New("object not found")); err.Error() != "object not found" {
+		t.Errorf("Expected the message to be kept, got %q", err.Error())
+	}
+}
+
+func TestAnalyzeWithDiffsParseFailureKind(t *testing.T) {
+	repo := createTestRepo(t, []struct{ path, content string }{
+		{"main.go", "package main\n"},
+	})
+	results, err := RunAnalysis(context.Background(), repo, &mockModel{response: "I cannot decide."}, AnalysisOptions{
+		NumCommits:   1,
+		ErrorMessage: "test error",
+	})
+	if err != nil {
+		t.Fatalf("RunAnalysis failed: %v", err)
+	}
+	if kind := ErrorKind(results[0].Error); kind != ErrorKindParse {
+		t.Errorf("Expected a parse failure, got %q (%v)", kind, results[0].Error)
+	}
+	if summary := CalculateSummary(results); summary.ErrorKinds[ErrorKindParse] != 1 {
+		t.Errorf("Expected 1 parse failure in the summary, got %v", summary.ErrorKinds)
+	}
+}
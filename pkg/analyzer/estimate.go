@@ -0,0 +1,45 @@
+package analyzer
+
+import "github.com/kerneldump/git-dual-context/pkg/gitdiff"
+
+// EstimatedOutputTokens is the response length in tokens assumed for each
+// LLM call when estimating usage before a run: a verdict with a few
+// paragraphs of reasoning
+const EstimatedOutputTokens = 400
+
+// UsageEstimate is the LLM usage expected for analyzing commits
+type UsageEstimate struct {
+	Calls        int `json:"calls"`
+	PromptTokens int `json:"prompt_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// Add adds o to u
+func (u *UsageEstimate) Add(o UsageEstimate) {
+	u.Calls += o.Calls
+	u.PromptTokens += o.PromptTokens
+	u.OutputTokens += o.OutputTokens
+}
+
+// EstimateUsage returns the LLM usage of analyzing diffCtx for errorMsg
+// with AnalyzeWithDiffs: no calls for skipped and non-functional commits,
+// and one call per chunk otherwise. Prompt tokens are counted with
+// gitdiff.EstimateTokens, without the savings of a context cache or of
+// reusing an identical patch's verdict.
+func EstimateUsage(diffCtx *CommitDiffContext, errorMsg string) UsageEstimate {
+	if diffCtx.Skipped || diffCtx.NonFunctional != "" {
+		return UsageEstimate{}
+	}
+	if len(diffCtx.Chunks) > 0 {
+		var u UsageEstimate
+		for _, chunk := range diffCtx.Chunks {
+			u.Add(EstimateUsage(chunk, errorMsg))
+		}
+		return u
+	}
+	return UsageEstimate{
+		Calls:        1,
+		PromptTokens: gitdiff.EstimateTokens(BuildPromptFromContext(errorMsg, diffCtx)),
+		OutputTokens: EstimatedOutputTokens,
+	}
+}
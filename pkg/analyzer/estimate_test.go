@@ -0,0 +1,41 @@
+package analyzer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestEstimateUsage(t *testing.T) {
+	commit := &object.Commit{Message: "Change handler"}
+	whole := &CommitDiffContext{Commit: commit, StandardDiff: "+func handler() {}\n", FullDiff: "+func handler() {}\n"}
+
+	u := EstimateUsage(whole, "panic in handler")
+	if u.Calls != 1 || u.PromptTokens <= 0 || u.OutputTokens != EstimatedOutputTokens {
+		t.Errorf("expected one call with a prompt, got %+v", u)
+	}
+
+	chunked := &CommitDiffContext{Commit: commit, Chunks: []*CommitDiffContext{whole, whole, whole}}
+	if got := EstimateUsage(chunked, "panic in handler"); got.Calls != 3 || got.PromptTokens != 3*u.PromptTokens {
+		t.Errorf("expected a call per chunk, got %+v", got)
+	}
+
+	// The estimate matches the calls AnalyzeWithDiffs makes
+	model := &promptModel{marker: "never"}
+	if _, err := AnalyzeWithDiffs(context.Background(), chunked, "panic in handler", model); err != nil {
+		t.Fatal(err)
+	}
+	if model.calls != 3 {
+		t.Errorf("expected 3 LLM calls, got %d", model.calls)
+	}
+
+	for _, diffCtx := range []*CommitDiffContext{
+		{Commit: commit, Skipped: true},
+		{Commit: commit, StandardDiff: "+// comment\n", NonFunctional: "only comments changed"},
+	} {
+		if got := EstimateUsage(diffCtx, "panic"); got != (UsageEstimate{}) {
+			t.Errorf("expected no calls for %+v, got %+v", diffCtx, got)
+		}
+	}
+}
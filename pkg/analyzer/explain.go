@@ -0,0 +1,108 @@
+package analyzer
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/kerneldump/git-dual-context/pkg/gitdiff"
+
+	"github.com/google/generative-ai-go/genai"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+//go:embed prompts/explain.txt
+var explainPromptTemplate string
+
+// Explanation is the LLM's plain-language account of a commit, written
+// for understanding the code rather than finding a bug
+type Explanation struct {
+	// Summary explains what the commit changed, and why if known
+	Summary string `json:"summary"`
+
+	// Evolution explains what became of the change by HEAD
+	Evolution string `json:"evolution"`
+
+	// Token usage reported by the LLM (0 if unknown)
+	PromptTokens int32 `json:"-"`
+	OutputTokens int32 `json:"-"`
+}
+
+// BuildExplainPrompt builds the prompt asking the LLM to explain the
+// commit of diffCtx from its dual context, without a bug to look for
+func BuildExplainPrompt(diffCtx *CommitDiffContext) string {
+	symbols := gitdiff.FormatChangedSymbols(diffCtx.Symbols)
+	if symbols == "" {
+		symbols = "(none detected)"
+	}
+	followUps := gitdiff.FormatFollowUps(diffCtx.FollowUps)
+	if followUps == "" {
+		followUps = "(none found)"
+	}
+	meta := diffCtx.Metadata
+	if meta == nil {
+		meta = NewCommitMetadata(diffCtx.Commit, nil)
+	}
+	return fmt.Sprintf(explainPromptTemplate, diffCtx.Commit.Hash.String(), meta.format(), diffCtx.Commit.Message,
+		strings.TrimRight(symbols, "\n"), strings.TrimRight(followUps, "\n"), diffCtx.StandardDiff, diffCtx.FullDiff)
+}
+
+// ExplainCommit asks model to explain the commit of diffCtx, whose diffs
+// must not be split into chunks. It is safe for concurrent use.
+func ExplainCommit(ctx context.Context, diffCtx *CommitDiffContext, model LLMModel) (explanation *Explanation, err error) {
+	ctx, span := tracer.Start(ctx, "ExplainCommit", trace.WithAttributes(
+		attribute.String("git.commit", diffCtx.Commit.Hash.String()),
+	))
+	defer func() { endSpan(span, err) }()
+
+	prompt := BuildExplainPrompt(diffCtx)
+	resp, err := model.GenerateContent(ctx, genai.Text(prompt))
+	if err != nil {
+		return nil, fmt.Errorf("gemini api call: %w", err)
+	}
+
+	var text strings.Builder
+	if len(resp.Candidates) > 0 && resp.Candidates[0].Content != nil {
+		for _, part := range resp.Candidates[0].Content.Parts {
+			if txt, ok := part.(genai.Text); ok {
+				text.WriteString(string(txt))
+			}
+		}
+	}
+	if text.Len() == 0 {
+		return nil, newParseError(diffCtx, prompt, "", fmt.Errorf("empty response from gemini for commit %s", diffCtx.Commit.Hash.String()[:8]))
+	}
+
+	explanation = &Explanation{}
+	block := findExplanationJSON(text.String())
+	if block == "" {
+		return nil, newParseError(diffCtx, prompt, text.String(), fmt.Errorf("no JSON found in response for %s", diffCtx.Commit.Hash.String()[:8]))
+	}
+	if err := json.Unmarshal([]byte(block), explanation); err != nil {
+		return nil, newParseError(diffCtx, prompt, text.String(), fmt.Errorf("parsing JSON for %s: %v", diffCtx.Commit.Hash.String()[:8], err))
+	}
+	if resp.UsageMetadata != nil {
+		explanation.PromptTokens = resp.UsageMetadata.PromptTokenCount
+		explanation.OutputTokens = resp.UsageMetadata.CandidatesTokenCount
+	}
+	return explanation, nil
+}
+
+// findExplanationJSON returns the last JSON object in text holding a
+// summary, scanning back from the last closing brace like FindJSONBlock
+func findExplanationJSON(text string) string {
+	end := strings.LastIndex(text, "}")
+	if end == -1 {
+		return ""
+	}
+	for start := strings.LastIndex(text[:end], "{"); start != -1; start = strings.LastIndex(text[:start], "{") {
+		candidate := text[start : end+1]
+		if strings.Contains(candidate, `"summary"`) && json.Valid([]byte(candidate)) {
+			return candidate
+		}
+	}
+	return ""
+}
@@ -0,0 +1,50 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/kerneldump/git-dual-context/pkg/gitdiff"
+)
+
+func TestExplainCommit(t *testing.T) {
+	repo := createTestRepo(t, []struct{ path, content string }{
+		{"main.go", "package main\n\nfunc main() {}\n"},
+		{"main.go", "package main\n\nfunc main() { run() }\n\nfunc run() {}\n"},
+	})
+	head, err := ResolveCommit(repo, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	diffCtx, err := ExtractDiffsContext(context.Background(), repo, head, head, gitdiff.Options{})
+	if err != nil {
+		t.Fatalf("ExtractDiffsContext failed: %v", err)
+	}
+
+	prompt := BuildExplainPrompt(diffCtx)
+	for _, want := range []string{head.Hash.String(), "func run() {}", "do not look for bugs", `"evolution"`} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("expected the prompt to contain %q", want)
+		}
+	}
+	if strings.Contains(prompt, "%!") {
+		t.Errorf("prompt has a formatting error:\n%s", prompt)
+	}
+
+	model := &mockModel{response: "Here you go:\n" + `{"summary": "Adds run and calls it from main.", "evolution": "Unchanged since."}`}
+	explanation, err := ExplainCommit(context.Background(), diffCtx, model)
+	if err != nil {
+		t.Fatalf("ExplainCommit failed: %v", err)
+	}
+	if explanation.Summary != "Adds run and calls it from main." || explanation.Evolution != "Unchanged since." {
+		t.Errorf("unexpected explanation: %+v", explanation)
+	}
+
+	_, err = ExplainCommit(context.Background(), diffCtx, &mockModel{response: "It adds run."})
+	var pe *ParseError
+	if !errors.As(err, &pe) {
+		t.Errorf("expected a parse error, got %v", err)
+	}
+}
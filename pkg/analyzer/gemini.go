@@ -0,0 +1,139 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/option"
+)
+
+// APIKey is a Gemini API key, with the settings of its llm.api_keys entry
+type APIKey struct {
+	// Name identifies the key in logs; never the key itself
+	Name string
+
+	// Value is the key
+	Value string
+
+	// RequestsPerMinute caps the calls made with the key (0: no cap)
+	RequestsPerMinute int
+}
+
+// GeminiOptions configures NewGeminiModel
+type GeminiOptions struct {
+	// Model names the Gemini model called
+	Model string
+
+	// Keys are the API keys calls are made with; with several, or one
+	// with a cap on its requests, calls are spread over them by a KeyPool
+	Keys []APIKey
+
+	// KeyRotation is the KeyPool's strategy, one of the KeyRotation
+	// constants
+	KeyRotation string
+
+	// Temperature is the sampling temperature
+	Temperature float32
+
+	// Stream streams responses (see StreamingModel)
+	Stream bool
+
+	// ContextCache routes prompts through a ContextCache, to be prepared
+	// before the calls. Cached content belongs to one key's project, so
+	// no cache is used with a KeyPool.
+	ContextCache    bool
+	ContextCacheTTL time.Duration
+
+	// Logf, if set, reports keys the API rate limits
+	Logf func(format string, args ...any)
+}
+
+// GeminiModel is an LLMModel calling Gemini, built by NewGeminiModel
+type GeminiModel struct {
+	LLMModel
+
+	// Cache is the context cache prompts go through, or nil without one
+	Cache *ContextCache
+
+	// Pooled reports whether calls are spread over several keys by a
+	// KeyPool
+	Pooled bool
+
+	name    string
+	clients []*genai.Client
+}
+
+// NewGeminiModel returns the model of opts: one Gemini client for the
+// first key, or a KeyPool of a client per key, with the context cache in
+// front when enabled. Close releases it.
+func NewGeminiModel(ctx context.Context, opts GeminiOptions) (*GeminiModel, error) {
+	if len(opts.Keys) == 0 {
+		return nil, fmt.Errorf("no API key for the Gemini client")
+	}
+	m := &GeminiModel{Pooled: len(opts.Keys) > 1 || opts.Keys[0].RequestsPerMinute > 0, name: opts.Model}
+	newModel := func(key APIKey) (*genai.GenerativeModel, error) {
+		client, err := genai.NewClient(ctx, option.WithAPIKey(key.Value))
+		if err != nil {
+			return nil, err
+		}
+		m.clients = append(m.clients, client)
+		genModel := client.GenerativeModel(opts.Model)
+		genModel.SetTemperature(opts.Temperature)
+		return genModel, nil
+	}
+	stream := func(genModel *genai.GenerativeModel) LLMModel {
+		if opts.Stream {
+			return NewStreamingModel(genModel)
+		}
+		return genModel
+	}
+
+	genModel, err := newModel(opts.Keys[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Gemini client: %w", err)
+	}
+	m.LLMModel = stream(genModel)
+
+	// Several API keys, or one with a request cap, share the calls
+	if m.Pooled {
+		poolKeys := make([]PoolKey, len(opts.Keys))
+		for i, k := range opts.Keys {
+			keyModel, err := newModel(k)
+			if err != nil {
+				m.Close(ctx)
+				return nil, fmt.Errorf("failed to create Gemini client for API key %s: %w", k.Name, err)
+			}
+			poolKeys[i] = PoolKey{Name: k.Name, Model: stream(keyModel), RequestsPerMinute: k.RequestsPerMinute}
+		}
+		pool := NewKeyPool(opts.KeyRotation, poolKeys)
+		pool.Logf = opts.Logf
+		m.LLMModel = pool
+		return m, nil
+	}
+
+	if opts.ContextCache {
+		m.Cache = NewContextCache(m.clients[0], opts.Model, genModel, opts.ContextCacheTTL)
+		m.Cache.Stream = opts.Stream
+		m.LLMModel = m.Cache.Model(m.LLMModel)
+	}
+	return m, nil
+}
+
+// Tokenizer returns a GeminiTokenizer counting tokens for the model with
+// the first key, under ctx, to fit diffs to their budget
+func (m *GeminiModel) Tokenizer(ctx context.Context) *GeminiTokenizer {
+	return NewGeminiTokenizer(ctx, m.clients[0].GenerativeModel(m.name))
+}
+
+// Close deletes the cached contents of the context cache, if any, and
+// closes the clients. It returns the error deleting them, which otherwise
+// expire with their TTL.
+func (m *GeminiModel) Close(ctx context.Context) error {
+	err := m.Cache.Close(ctx)
+	for _, c := range m.clients {
+		c.Close()
+	}
+	return err
+}
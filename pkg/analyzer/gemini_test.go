@@ -0,0 +1,12 @@
+package analyzer
+
+import (
+	"context"
+	"testing"
+)
+
+func TestNewGeminiModelNoKeys(t *testing.T) {
+	if _, err := NewGeminiModel(context.Background(), GeminiOptions{Model: "gemini-2.5-flash"}); err == nil {
+		t.Fatal("NewGeminiModel without keys: want an error")
+	}
+}
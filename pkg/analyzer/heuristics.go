@@ -0,0 +1,138 @@
+package analyzer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kerneldump/git-dual-context/pkg/gitdiff"
+)
+
+// HeuristicModelName names the offline scorer where a model name is
+// recorded, as in summaries and the history database
+const HeuristicModelName = "heuristic"
+
+// Weights of the heuristic signals; they sum to 1
+const (
+	stackTraceWeight = 0.4
+	keywordWeight    = 0.3
+	churnWeight      = 0.15
+	recencyWeight    = 0.15
+)
+
+// Heuristic score thresholds for a HIGH or MEDIUM verdict
+const (
+	heuristicHigh   = 0.6
+	heuristicMedium = 0.3
+)
+
+// recencyHalfLife is the age before HEAD at which a commit's recency
+// signal halves
+const recencyHalfLife = 7 * 24 * time.Hour
+
+// Heuristics scores a commit without an LLM, from how its diff relates to
+// the error and from its files' history. It decides offline verdicts and
+// is blended with LLM verdicts into the suspicion score.
+type Heuristics struct {
+	// Score is the weighted sum of the signals below, from 0 to 1
+	Score float64 `json:"score"`
+
+	// StackFiles are the commit's files named by a stack trace in the error
+	StackFiles []string `json:"stack_files,omitempty"`
+
+	// Keywords are the error's identifiers and words found in the diff
+	Keywords []string `json:"keywords,omitempty"`
+
+	// Churn is the hotspot prior of the commit's files (0 to 1)
+	Churn float64 `json:"churn"`
+
+	// Recency is 1 for HEAD, halving every week before it
+	Recency float64 `json:"recency"`
+}
+
+// ScoreHeuristics rates a commit on path overlap with a stack trace in
+// errorMsg, keyword overlap between errorMsg and the diff, the churn and
+// bug-fix history of its files, and how recently it was made
+func ScoreHeuristics(errorMsg string, diffCtx *CommitDiffContext) *Heuristics {
+	match := gitdiff.MatchError(errorMsg, diffCtx.ModifiedFiles, diffCtx.StandardDiff)
+	h := &Heuristics{
+		StackFiles: match.StackFiles,
+		Keywords:   match.Keywords,
+		Recency:    1,
+	}
+	if diffCtx.Hotspot != nil {
+		h.Churn = diffCtx.Hotspot.Score
+	}
+	if diffCtx.Metadata != nil && diffCtx.Metadata.BeforeHead > 0 {
+		h.Recency = 1 / (1 + float64(diffCtx.Metadata.BeforeHead)/float64(recencyHalfLife))
+	}
+
+	if len(h.StackFiles) > 0 {
+		h.Score += stackTraceWeight
+	}
+	if match.Terms > 0 {
+		h.Score += keywordWeight * float64(len(h.Keywords)) / float64(match.Terms)
+	}
+	h.Score += churnWeight*h.Churn + recencyWeight*h.Recency
+	return h
+}
+
+// Prior is the score without recency, rescaled to 0 to 1: the stack
+// trace, keyword, and churn signals alone
+func (h *Heuristics) Prior() float64 {
+	return (h.Score - recencyWeight*h.Recency) / (1 - recencyWeight)
+}
+
+// Probability maps the score to a verdict
+func (h *Heuristics) Probability() Probability {
+	switch {
+	case h.Score >= heuristicHigh:
+		return ProbHigh
+	case h.Score >= heuristicMedium:
+		return ProbMedium
+	default:
+		return ProbLow
+	}
+}
+
+// reasoning explains the score in place of an LLM's reasoning
+func (h *Heuristics) reasoning() string {
+	var signals []string
+	if len(h.StackFiles) > 0 {
+		signals = append(signals, fmt.Sprintf("the stack trace names %s", strings.Join(h.StackFiles, ", ")))
+	}
+	if len(h.Keywords) > 0 {
+		signals = append(signals, fmt.Sprintf("the diff mentions %s", strings.Join(h.Keywords, ", ")))
+	}
+	signals = append(signals, fmt.Sprintf("churn %.2f", h.Churn), fmt.Sprintf("recency %.2f", h.Recency))
+	return fmt.Sprintf("Heuristic score %.2f (no LLM): %s.", h.Score, strings.Join(signals, "; "))
+}
+
+// AnalyzeHeuristically rates a commit from its pre-extracted diffs with
+// ScoreHeuristics instead of an LLM, for air-gapped use or when the API is
+// down. Skipped and non-functional commits are handled as in
+// AnalyzeWithDiffs.
+func AnalyzeHeuristically(diffCtx *CommitDiffContext, errorMsg string) *AnalysisResult {
+	if diffCtx.Skipped {
+		return &AnalysisResult{Skipped: true, Skip: diffCtx.Skip, Stats: diffCtx.Stats, Metadata: diffCtx.Metadata}
+	}
+	result := &AnalysisResult{
+		Stats:     diffCtx.Stats,
+		FollowUps: diffCtx.FollowUps,
+		Metadata:  diffCtx.Metadata,
+		Hotspot:   diffCtx.Hotspot,
+	}
+	if diffCtx.NonFunctional != "" {
+		result.Probability = ProbLow
+		result.Reasoning = fmt.Sprintf("No functional change (%s); not scored.", diffCtx.NonFunctional)
+		return result
+	}
+
+	result.Heuristics = ScoreHeuristics(errorMsg, diffCtx)
+	result.offline = true
+	result.Probability = result.Heuristics.Probability()
+	result.Reasoning = result.Heuristics.reasoning()
+	result.addSuspectDetails(diffCtx)
+	result.Score(DefaultScoreWeights)
+	return result
+}
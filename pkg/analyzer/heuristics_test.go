@@ -0,0 +1,75 @@
+package analyzer
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kerneldump/git-dual-context/pkg/gitdiff"
+)
+
+func TestScoreHeuristics(t *testing.T) {
+	diffCtx := &CommitDiffContext{
+		ModifiedFiles: []string{"pkg/auth/session.go", "README.md"},
+		StandardDiff:  "--- pkg/auth/session.go\n-\tttl := 24 * time.Hour\n+\tttl := time.Minute\n",
+		Hotspot:       &gitdiff.Hotspot{Score: 0.6},
+		Metadata:      &CommitMetadata{BeforeHead: 7 * 24 * time.Hour},
+	}
+	errorMsg := "session expired: ttl too short\n\tat pkg/auth/session.go:42"
+
+	h := ScoreHeuristics(errorMsg, diffCtx)
+
+	if !reflect.DeepEqual(h.StackFiles, []string{"pkg/auth/session.go"}) {
+		t.Errorf("Expected stack trace to name session.go, got %v", h.StackFiles)
+	}
+	if !reflect.DeepEqual(h.Keywords, []string{"session", "ttl", "pkg", "auth"}) {
+		t.Errorf("Expected keywords session, ttl, pkg, and auth, got %v", h.Keywords)
+	}
+	if math.Abs(h.Recency-0.5) > 1e-9 {
+		t.Errorf("Expected a week-old commit to have recency 0.5, got %f", h.Recency)
+	}
+	if h.Probability() != ProbHigh {
+		t.Errorf("Expected HIGH for a stack trace and keyword match, got %s (score %f)", h.Probability(), h.Score)
+	}
+
+	unrelated := ScoreHeuristics("database connection refused", &CommitDiffContext{
+		ModifiedFiles: []string{"docs/intro.md"},
+		StandardDiff:  "+Welcome\n",
+		Metadata:      &CommitMetadata{BeforeHead: 30 * 24 * time.Hour},
+	})
+	if unrelated.Probability() != ProbLow {
+		t.Errorf("Expected LOW for an unrelated commit, got %s (score %f)", unrelated.Probability(), unrelated.Score)
+	}
+}
+
+func TestAnalyzeHeuristically(t *testing.T) {
+	diffCtx := &CommitDiffContext{
+		ModifiedFiles: []string{"cache.go"},
+		StandardDiff:  "+\tevictAll()\n",
+		Owners:        []gitdiff.Owner{{Owner: "@acme/cache", Files: []string{"cache.go"}, Source: gitdiff.OwnerSourceCodeOwners}},
+		AffectedTests: []string{"cache_test.go"},
+	}
+
+	res := AnalyzeHeuristically(diffCtx, "panic in evictAll at cache.go:10")
+	if res.Heuristics == nil || res.Probability != ProbHigh {
+		t.Fatalf("Expected a HIGH heuristic verdict, got %+v", res)
+	}
+	if !strings.HasPrefix(res.Reasoning, "Heuristic score") || !strings.Contains(res.Reasoning, "the stack trace names cache.go") {
+		t.Errorf("Unexpected reasoning: %s", res.Reasoning)
+	}
+	if len(res.Owners) != 1 {
+		t.Errorf("Expected owners on a HIGH verdict, got %+v", res.Owners)
+	}
+	if len(res.AffectedTests) != 1 || res.ToJSONResult("abc", "msg").AffectedTests[0] != "cache_test.go" {
+		t.Errorf("Expected affected tests on a HIGH verdict, got %+v", res.AffectedTests)
+	}
+
+	if res := AnalyzeHeuristically(&CommitDiffContext{Skipped: true}, "x"); !res.Skipped {
+		t.Error("Expected skipped commit to stay skipped")
+	}
+	if res := AnalyzeHeuristically(&CommitDiffContext{NonFunctional: "whitespace only"}, "x"); res.Probability != ProbLow || res.Heuristics != nil {
+		t.Errorf("Expected non-functional commit to be LOW without scoring, got %+v", res)
+	}
+}
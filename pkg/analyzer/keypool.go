@@ -0,0 +1,155 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+	"golang.org/x/time/rate"
+)
+
+// Strategies of a KeyPool for picking the key of each call
+const (
+	// KeyRotationRoundRobin spreads calls over the keys in turn
+	KeyRotationRoundRobin = "round_robin"
+
+	// KeyRotationFailover uses the first key until it is rate limited,
+	// then the next
+	KeyRotationFailover = "failover"
+)
+
+// KeyCooldown is how long a KeyPool passes over a key after the API rate
+// limits it
+const KeyCooldown = time.Minute
+
+// PoolKey is one API key of a KeyPool
+type PoolKey struct {
+	// Name identifies the key in logs; never the key itself
+	Name string
+
+	// Model calls the LLM with the key
+	Model LLMModel
+
+	// RequestsPerMinute caps the calls made with the key; 0 for no cap
+	RequestsPerMinute int
+}
+
+// KeyPool is an LLMModel that spreads calls over several API keys, such as
+// keys of different projects whose quotas add up. Each key's calls are
+// held to its RequestsPerMinute, and a call the API rate limits moves on
+// to the next key while the limited one cools down for KeyCooldown.
+type KeyPool struct {
+	// Logf, if set, reports keys that are rate limited
+	Logf func(format string, args ...any)
+
+	rotation string
+	keys     []*pooledKey
+	now      func() time.Time
+
+	mu   sync.Mutex
+	next int
+}
+
+// pooledKey is a PoolKey with its rate limit state
+type pooledKey struct {
+	PoolKey
+	limiter   *rate.Limiter // nil without a cap
+	coolUntil time.Time
+}
+
+// NewKeyPool returns a pool of keys rotated by rotation, one of the
+// KeyRotation constants
+func NewKeyPool(rotation string, keys []PoolKey) *KeyPool {
+	p := &KeyPool{rotation: rotation, now: time.Now}
+	for _, k := range keys {
+		pk := &pooledKey{PoolKey: k}
+		if k.RequestsPerMinute > 0 {
+			pk.limiter = rate.NewLimiter(rate.Limit(float64(k.RequestsPerMinute)/60), 1)
+		}
+		p.keys = append(p.keys, pk)
+	}
+	return p
+}
+
+// GenerateContent calls the model of the next available key, trying each
+// key at most once while the API rate limits them
+func (p *KeyPool) GenerateContent(ctx context.Context, parts ...genai.Part) (*genai.GenerateContentResponse, error) {
+	if len(p.keys) == 0 {
+		return nil, fmt.Errorf("no API keys in pool")
+	}
+	var lastErr error
+	for range p.keys {
+		k, err := p.acquire(ctx)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := k.Model.GenerateContent(ctx, parts...)
+		if ErrorKind(err) != ErrorKindRateLimited {
+			return resp, err
+		}
+		p.cool(k)
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// acquire picks the key for a call and waits until its cap allows the
+// call. It prefers, in rotation order, a key that is neither cooling down
+// nor at its cap, then one that is not cooling down, then the one whose
+// cooldown ends first.
+func (p *KeyPool) acquire(ctx context.Context) (*pooledKey, error) {
+	p.mu.Lock()
+	now := p.now()
+	start := 0
+	if p.rotation == KeyRotationRoundRobin {
+		start = p.next
+	}
+	pick := -1
+	for pass := 0; pass < 2 && pick < 0; pass++ {
+		for i := range p.keys {
+			idx := (start + i) % len(p.keys)
+			k := p.keys[idx]
+			if now.Before(k.coolUntil) {
+				continue
+			}
+			if pass == 0 && k.limiter != nil && k.limiter.TokensAt(now) < 1 {
+				continue
+			}
+			pick = idx
+			break
+		}
+	}
+	if pick < 0 {
+		pick = 0
+		for i, k := range p.keys {
+			if k.coolUntil.Before(p.keys[pick].coolUntil) {
+				pick = i
+			}
+		}
+	}
+	p.next = (pick + 1) % len(p.keys)
+	k := p.keys[pick]
+	p.mu.Unlock()
+
+	if k.limiter != nil {
+		if err := k.limiter.Wait(ctx); err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, withKind(ErrorKindTimeout, fmt.Errorf("waiting for API key %s: %w", k.Name, err))
+		}
+	}
+	return k, nil
+}
+
+// cool keeps calls off k for KeyCooldown
+func (p *KeyPool) cool(k *pooledKey) {
+	p.mu.Lock()
+	k.coolUntil = p.now().Add(KeyCooldown)
+	p.mu.Unlock()
+	if p.Logf != nil {
+		p.Logf("API key %s is rate limited, resting it for %v", k.Name, KeyCooldown)
+	}
+}
@@ -0,0 +1,138 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/googleapi"
+)
+
+// keyModel records the calls made with one key of a KeyPool
+type keyModel struct {
+	name  string
+	calls *[]string
+	mu    *sync.Mutex
+	err   error
+}
+
+func (m keyModel) GenerateContent(ctx context.Context, parts ...genai.Part) (*genai.GenerateContentResponse, error) {
+	m.mu.Lock()
+	*m.calls = append(*m.calls, m.name)
+	m.mu.Unlock()
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &genai.GenerateContentResponse{}, nil
+}
+
+// newTestPool returns a pool of keys named a, b, c with the given errors
+// and the record of the keys called
+func newTestPool(rotation string, errs map[string]error) (*KeyPool, *[]string) {
+	calls := &[]string{}
+	mu := &sync.Mutex{}
+	var keys []PoolKey
+	for _, name := range []string{"a", "b", "c"} {
+		keys = append(keys, PoolKey{Name: name, Model: keyModel{name: name, calls: calls, mu: mu, err: errs[name]}})
+	}
+	return NewKeyPool(rotation, keys), calls
+}
+
+func TestKeyPoolRoundRobin(t *testing.T) {
+	pool, calls := newTestPool(KeyRotationRoundRobin, nil)
+	for range 4 {
+		if _, err := pool.GenerateContent(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := []string{"a", "b", "c", "a"}; !reflect.DeepEqual(*calls, want) {
+		t.Errorf("called %v, want %v", *calls, want)
+	}
+}
+
+func TestKeyPoolFailover(t *testing.T) {
+	limited := &googleapi.Error{Code: 429}
+	pool, calls := newTestPool(KeyRotationFailover, map[string]error{"a": limited})
+	var logged []string
+	pool.Logf = func(format string, args ...any) { logged = append(logged, args[0].(string)) }
+	now := time.Now()
+	pool.now = func() time.Time { return now }
+
+	for range 3 {
+		if _, err := pool.GenerateContent(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a is rate limited once, then passed over while it cools down
+	if want := []string{"a", "b", "b", "b"}; !reflect.DeepEqual(*calls, want) {
+		t.Errorf("called %v, want %v", *calls, want)
+	}
+	if !reflect.DeepEqual(logged, []string{"a"}) {
+		t.Errorf("expected a reported rate limited, got %v", logged)
+	}
+
+	now = now.Add(KeyCooldown)
+	*calls = nil
+	pool.GenerateContent(context.Background())
+	if want := []string{"a", "b"}; !reflect.DeepEqual(*calls, want) {
+		t.Errorf("after the cooldown called %v, want %v", *calls, want)
+	}
+}
+
+func TestKeyPoolAllRateLimited(t *testing.T) {
+	limited := &googleapi.Error{Code: 429}
+	pool, calls := newTestPool(KeyRotationRoundRobin, map[string]error{"a": limited, "b": limited, "c": limited})
+
+	_, err := pool.GenerateContent(context.Background())
+	if ErrorKind(err) != ErrorKindRateLimited {
+		t.Errorf("expected a rate limit error, got %v", err)
+	}
+	if len(*calls) != 3 {
+		t.Errorf("expected each key tried once, got %v", *calls)
+	}
+}
+
+func TestKeyPoolOtherErrors(t *testing.T) {
+	boom := errors.New("invalid argument")
+	pool, calls := newTestPool(KeyRotationFailover, map[string]error{"a": boom})
+	if _, err := pool.GenerateContent(context.Background()); !errors.Is(err, boom) {
+		t.Errorf("expected the key's error, got %v", err)
+	}
+	if len(*calls) != 1 {
+		t.Errorf("expected no other key tried, got %v", *calls)
+	}
+}
+
+func TestKeyPoolRequestsPerMinute(t *testing.T) {
+	calls := &[]string{}
+	mu := &sync.Mutex{}
+	pool := NewKeyPool(KeyRotationFailover, []PoolKey{
+		{Name: "slow", Model: keyModel{name: "slow", calls: calls, mu: mu}, RequestsPerMinute: 1},
+		{Name: "fast", Model: keyModel{name: "fast", calls: calls, mu: mu}},
+	})
+	for range 3 {
+		if _, err := pool.GenerateContent(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The capped key's one request this minute is spent, so calls go on to
+	// the next key instead of waiting
+	if want := []string{"slow", "fast", "fast"}; !reflect.DeepEqual(*calls, want) {
+		t.Errorf("called %v, want %v", *calls, want)
+	}
+
+	// A single capped key makes calls wait, up to the context's deadline
+	pool = NewKeyPool(KeyRotationRoundRobin, []PoolKey{{Name: "slow", Model: keyModel{name: "slow", calls: calls, mu: mu}, RequestsPerMinute: 1}})
+	if _, err := pool.GenerateContent(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := pool.GenerateContent(ctx); ErrorKind(err) != ErrorKindTimeout {
+		t.Errorf("expected a timeout waiting for the key, got %v", err)
+	}
+}
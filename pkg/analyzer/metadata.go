@@ -0,0 +1,151 @@
+package analyzer
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kerneldump/git-dual-context/pkg/ci"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+var (
+	// issueRefRe matches "#123" and "GH-123" issue and pull request
+	// references
+	issueRefRe = regexp.MustCompile(`(?:^|[\s(\[,;:])(?:GH-|#)(\d+)\b`)
+	// issueURLRe matches GitHub and GitLab issue and pull request URLs
+	issueURLRe = regexp.MustCompile(`/(?:-/)?(?:issues|pull|merge_requests)/(\d+)\b`)
+	// trackerKeyRe matches Jira-style keys such as "PROJ-123"
+	trackerKeyRe = regexp.MustCompile(`\b[A-Z][A-Z0-9]+-\d+\b`)
+)
+
+// CommitMetadata describes who made a commit and when, for the LLM and
+// the triager: a commit that landed hours before an incident and closes
+// a related issue deserves a closer look.
+type CommitMetadata struct {
+	// Author is "Name <email>"
+	Author string `json:"author,omitempty"`
+
+	// Date is when the commit was made (the committer date)
+	Date time.Time `json:"date,omitzero"`
+
+	// Issues are the issue, pull request, and tracker references in the
+	// message, such as "#123" and "PROJ-42"
+	Issues []string `json:"issues,omitempty"`
+
+	// IssueTitles are the titles of the referenced issues the configured
+	// trackers know, keyed by reference (see issues.Resolver)
+	IssueTitles map[string]string `json:"issue_titles,omitempty"`
+
+	// CI is whether the commit passed its CI checks (nil: unknown; see
+	// ci.Checker)
+	CI *ci.Status `json:"ci,omitempty"`
+
+	// ChangedFiles counts every file the commit changed, before filtering
+	ChangedFiles int `json:"changed_files,omitempty"`
+
+	// BeforeHead is how long before HEAD the commit was made (0: unknown)
+	BeforeHead time.Duration `json:"-"`
+}
+
+// NewCommitMetadata reads the metadata of c. With head, the commit's age
+// relative to HEAD is filled in. ChangedFiles is left for the caller.
+func NewCommitMetadata(c, head *object.Commit) *CommitMetadata {
+	m := &CommitMetadata{
+		Author: fmt.Sprintf("%s <%s>", c.Author.Name, c.Author.Email),
+		Date:   c.Committer.When,
+		Issues: IssueReferences(c.Message),
+	}
+	if head != nil && head.Hash != c.Hash && head.Committer.When.After(m.Date) {
+		m.BeforeHead = head.Committer.When.Sub(m.Date)
+	}
+	return m
+}
+
+// IssueReferences returns the distinct issue and pull request references
+// in a commit message, in order of appearance. Numbers from GitHub and
+// GitLab URLs are given as "#123".
+func IssueReferences(message string) []string {
+	type match struct {
+		pos int
+		ref string
+	}
+	var matches []match
+	for _, m := range issueRefRe.FindAllStringSubmatchIndex(message, -1) {
+		matches = append(matches, match{m[2], "#" + message[m[2]:m[3]]})
+	}
+	for _, m := range issueURLRe.FindAllStringSubmatchIndex(message, -1) {
+		matches = append(matches, match{m[2], "#" + message[m[2]:m[3]]})
+	}
+	for _, m := range trackerKeyRe.FindAllStringIndex(message, -1) {
+		ref := message[m[0]:m[1]]
+		// "UTF-8" and "SHA-256" look like tracker keys but are not
+		if strings.HasPrefix(ref, "UTF-") || strings.HasPrefix(ref, "SHA-") || strings.HasPrefix(ref, "GH-") {
+			continue
+		}
+		matches = append(matches, match{m[0], ref})
+	}
+
+	// Order by position so that the references read as in the message
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].pos < matches[j].pos })
+	var refs []string
+	seen := map[string]bool{}
+	for _, m := range matches {
+		if !seen[m.ref] {
+			seen[m.ref] = true
+			refs = append(refs, m.ref)
+		}
+	}
+	return refs
+}
+
+// format renders the metadata as prompt lines
+func (m *CommitMetadata) format() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Author: %s\n", m.Author))
+	sb.WriteString(fmt.Sprintf("Date: %s", m.Date.Format(time.RFC3339)))
+	if m.BeforeHead > 0 {
+		sb.WriteString(fmt.Sprintf(" (%s before HEAD)", humanizeDuration(m.BeforeHead)))
+	}
+	sb.WriteString("\n")
+	if len(m.Issues) > 0 {
+		refs := make([]string, len(m.Issues))
+		for i, ref := range m.Issues {
+			refs[i] = ref
+			if title := m.IssueTitles[ref]; title != "" {
+				refs[i] = fmt.Sprintf("%s (%s)", ref, title)
+			}
+		}
+		sb.WriteString(fmt.Sprintf("References: %s\n", strings.Join(refs, ", ")))
+	}
+	if m.ChangedFiles > 0 {
+		sb.WriteString(fmt.Sprintf("Changed files: %d\n", m.ChangedFiles))
+	}
+	if m.CI != nil {
+		sb.WriteString(fmt.Sprintf("CI status: %s\n", m.CI))
+	}
+	return sb.String()
+}
+
+// humanizeDuration renders d in its largest whole unit, like "3 hours"
+func humanizeDuration(d time.Duration) string {
+	plural := func(n int, unit string) string {
+		if n == 1 {
+			return fmt.Sprintf("1 %s", unit)
+		}
+		return fmt.Sprintf("%d %ss", n, unit)
+	}
+	switch {
+	case d >= 48*time.Hour:
+		return plural(int(d/(24*time.Hour)), "day")
+	case d >= time.Hour:
+		return plural(int(d/time.Hour), "hour")
+	case d >= time.Minute:
+		return plural(int(d/time.Minute), "minute")
+	default:
+		return "less than a minute"
+	}
+}
@@ -0,0 +1,113 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestIssueReferences(t *testing.T) {
+	tests := []struct {
+		message  string
+		expected []string
+	}{
+		{"Fix login loop (#123)", []string{"#123"}},
+		{"PROJ-42: cache tokens\n\nCloses #7, refs GH-9 and #7", []string{"PROJ-42", "#7", "#9"}},
+		{"See https://github.com/acme/app/pull/88", []string{"#88"}},
+		{"See https://gitlab.com/acme/app/-/merge_requests/5", []string{"#5"}},
+		{"Decode UTF-8 and SHA-256 input", nil},
+		{"Bump version to 1.2#3", nil},
+	}
+
+	for _, tt := range tests {
+		if got := IssueReferences(tt.message); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("IssueReferences(%q): expected %v, got %v", tt.message, tt.expected, got)
+		}
+	}
+}
+
+func TestNewCommitMetadata(t *testing.T) {
+	when := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	c := &object.Commit{
+		Hash:      plumbing.NewHash("a1b2c3d4"),
+		Message:   "Shorten session TTL (#12)",
+		Author:    object.Signature{Name: "Dana", Email: "dana@example.com", When: when.Add(-time.Hour)},
+		Committer: object.Signature{Name: "CI", Email: "ci@example.com", When: when},
+	}
+	head := &object.Commit{
+		Hash:      plumbing.NewHash("e5f6a7b8"),
+		Committer: object.Signature{When: when.Add(3 * time.Hour)},
+	}
+
+	m := NewCommitMetadata(c, head)
+	m.ChangedFiles = 4
+	if m.Author != "Dana <dana@example.com>" || !m.Date.Equal(when) || m.BeforeHead != 3*time.Hour {
+		t.Errorf("Unexpected metadata: %+v", m)
+	}
+
+	expected := "Author: Dana <dana@example.com>\nDate: 2026-03-01T09:00:00Z (3 hours before HEAD)\nReferences: #12\nChanged files: 4\n"
+	if got := m.format(); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+
+	m.IssueTitles = map[string]string{"#12": "Sessions expire early"}
+	if got := m.format(); !strings.Contains(got, "References: #12 (Sessions expire early)\n") {
+		t.Errorf("Expected the issue title in %q", got)
+	}
+
+	if m := NewCommitMetadata(c, c); m.BeforeHead != 0 || strings.Contains(m.format(), "before HEAD") {
+		t.Errorf("Expected no age for HEAD itself, got %+v", m)
+	}
+}
+
+func TestHumanizeDuration(t *testing.T) {
+	tests := []struct {
+		d        time.Duration
+		expected string
+	}{
+		{30 * time.Second, "less than a minute"},
+		{time.Minute, "1 minute"},
+		{90 * time.Minute, "1 hour"},
+		{47 * time.Hour, "47 hours"},
+		{72 * time.Hour, "3 days"},
+	}
+	for _, tt := range tests {
+		if got := humanizeDuration(tt.d); got != tt.expected {
+			t.Errorf("humanizeDuration(%v): expected %q, got %q", tt.d, tt.expected, got)
+		}
+	}
+}
+
+func TestToJSONResultIncludesMetadata(t *testing.T) {
+	ar := &AnalysisResult{
+		Probability: ProbHigh,
+		Metadata: &CommitMetadata{
+			Author:       "Dana <dana@example.com>",
+			Date:         time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC),
+			Issues:       []string{"#12"},
+			ChangedFiles: 4,
+		},
+	}
+	data, err := json.Marshal(ar.ToJSONResult("a1b2c3d4", "msg"))
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	for _, want := range []string{`"author":"Dana \u003cdana@example.com\u003e"`, `"date":"2026-03-01T09:00:00Z"`, `"issues":["#12"]`, `"changed_files":4`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("Expected %s in %s", want, data)
+		}
+	}
+
+	data, err = json.Marshal((&AnalysisResult{Probability: ProbLow}).ToJSONResult("a1b2c3d4", "msg"))
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if strings.Contains(string(data), "author") || strings.Contains(string(data), "date") {
+		t.Errorf("Expected no metadata fields without metadata, got %s", data)
+	}
+}
@@ -2,14 +2,24 @@
 package analyzer
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kerneldump/git-dual-context/pkg/ci"
+	"github.com/kerneldump/git-dual-context/pkg/gitdiff"
+	"github.com/kerneldump/git-dual-context/pkg/issues"
 
 	"github.com/go-git/go-git/v5"
-	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/google/generative-ai-go/genai"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // AnalysisOptions configures the analysis orchestration.
@@ -17,23 +27,174 @@ type AnalysisOptions struct {
 	// NumCommits is the number of commits to analyze
 	NumCommits int
 
-	// Branch is the branch to analyze (empty for HEAD)
+	// Branch is the branch, tag, remote-tracking ref, or commit whose
+	// history is analyzed (empty for HEAD; see ResolveCommit)
 	Branch string
 
+	// HeadRef is the ref commits are compared against for the macro
+	// context (empty: Branch)
+	HeadRef string
+
 	// ErrorMessage is the bug description to analyze
 	ErrorMessage string
 
 	// OnProgress is called with progress messages (optional)
 	OnProgress func(msg string)
+
+	// Workers is the number of concurrent LLM calls (default: DefaultNumWorkers)
+	Workers int
+
+	// Timeout bounds each commit's LLM analysis (default: DefaultTimeout)
+	Timeout time.Duration
+
+	// RunTimeout bounds the whole run: LLM calls are cut short to the time
+	// left, and commits not started in time fail with ErrBudgetExhausted
+	// (0: no limit; see Budget)
+	RunTimeout time.Duration
+
+	// Retry is how failed LLM calls are retried (zero: DefaultRetryConfig)
+	Retry RetryConfig
+
+	// OnResult is called once per commit, in commit order, as results
+	// become available, or with Verify once the suspects are verified
+	// (optional)
+	OnResult func(r CommitAnalysisResult)
+
+	// Diff controls diff extraction, such as file filters (optional)
+	Diff gitdiff.Options
+
+	// FilterProfiles adds ecosystem exclude patterns to Diff.Filter, with
+	// "auto" detecting them from HEAD (see gitdiff.Filter.AddProfiles)
+	FilterProfiles []string
+
+	// SuggestOwners attaches the CODEOWNERS owners (or, failing that, the
+	// last authors) of HIGH and MEDIUM commits' files to their results
+	SuggestOwners bool
+
+	// AffectedTests attaches the test files likely to exercise HIGH and
+	// MEDIUM commits' files to their results (see gitdiff.LoadTestMap)
+	AffectedTests bool
+
+	// HotspotHistory is how many commits back from HEAD the churn and
+	// bug-fix prior of each commit's files is computed over (0: no prior)
+	HotspotHistory int
+
+	// TechStack names the dominant languages and frameworks of HEAD in
+	// every prompt (see gitdiff.DetectTechStack)
+	TechStack bool
+
+	// RelatedFiles adds to each commit's full diff the evolution of up to
+	// this many files it did not change that historically change with its
+	// files or, in Go, import their packages (0: none; see
+	// gitdiff.LoadRelated)
+	RelatedFiles int
+
+	// GoCallers lists in each prompt where the Go functions the commit
+	// changed are called at HEAD, found by type-checking HEAD's modules
+	// (see gitdiff.LoadCallers)
+	GoCallers bool
+
+	// Issues are the trackers the titles of the issues commit messages
+	// reference are looked up in before each commit is analyzed, for its
+	// COMMIT CONTEXT (zero: none). GitHub references are resolved in the
+	// repository of the origin remote unless Issues.GitHubRepo names one.
+	Issues issues.Config
+
+	// CI are the forges each commit's CI status is looked up on before it
+	// is analyzed, for its metadata and COMMIT CONTEXT (zero: none). The
+	// repository of the origin remote is queried unless CI names one.
+	CI ci.Config
+
+	// Verify runs a command, such as a test, in checkouts of the most
+	// suspicious commits and their parents once every commit is analyzed,
+	// confirming or refuting their verdicts (zero: no verification)
+	Verify VerifyOptions
+
+	// Offline rates commits with AnalyzeHeuristically instead of the LLM,
+	// which may then be nil
+	Offline bool
+
+	// ScoreWeights blends each result's suspicion score (zero: default)
+	ScoreWeights ScoreWeights
+
+	// Verbosity is how long the reasoning of verdicts is asked to be, and
+	// cut to (empty: VerbosityStandard)
+	Verbosity Verbosity
+
+	// DedupePatches analyzes commits with identical patches once and
+	// reuses the verdict for the others (see PatchDedup)
+	DedupePatches bool
+
+	// DeepenShallow fetches missing history when the repository is a
+	// shallow clone too short for NumCommits (see DeepenShallow); without
+	// it, or if fetching fails, analysis stops at the oldest fetched commit
+	DeepenShallow bool
+
+	// ObjectCacheMB sizes the object cache shared by the workers extracting
+	// diffs, in megabytes (0: go-git's default)
+	ObjectCacheMB int
+
+	// ContextCache, if not nil, caches the instructions, bug description,
+	// and HEAD-side code shared by the prompts of the run; model must route
+	// prompts through ContextCache.Model for it to be used
+	ContextCache *ContextCache
+
+	// ContextCacheTokens caps the HEAD-side code cached (see HeadContext)
+	ContextCacheTokens int
+
+	// The remaining options are hooks of RunPipeline, letting callers
+	// follow and steer a run as it goes; all are optional.
+
+	// Budget is the deadline shared with other runs, such as those of the
+	// other repositories of a multi-repo analysis (nil: a Budget of
+	// RunTimeout for this run alone)
+	Budget *Budget
+
+	// ExtractWorkers is the number of repository handles extracting diffs
+	// (default: Workers)
+	ExtractWorkers int
+
+	// Slots, if not nil, bounds the concurrent LLM calls instead of
+	// Workers, for runs sharing one budget of workers, such as the
+	// repositories of a multi-repo run analyzed together
+	Slots chan struct{}
+
+	// Known returns the verdict already known for the commit at index i,
+	// such as one stored by a previous run, or nil to analyze it; a known
+	// commit is neither extracted nor sent to the LLM
+	Known func(i int, c *object.Commit) *AnalysisResult
+
+	// OnExtracted is called, concurrently, as each commit's diffs are
+	// extracted, with their context or the error extracting them
+	OnExtracted func(i int, dc *CommitDiffContext, err error)
+
+	// BeforeAnalyze is called before a commit is sent to the LLM; an error
+	// keeps it from being sent and becomes its result's Error, such as
+	// when a quota is spent or the run is interrupted
+	BeforeAnalyze func(i int, dc *CommitDiffContext) error
+
+	// CommitModel wraps the model analyzing the commit at index i, such as
+	// to record its prompts and responses
+	CommitModel func(i int, model LLMModel) LLMModel
+
+	// OnAnalyzed is called, concurrently and in completion order, as each
+	// commit's result is known; unlike OnResult it does not wait for the
+	// results of earlier commits
+	OnAnalyzed func(r CommitAnalysisResult)
 }
 
 // CommitAnalysisResult represents the result of analyzing a single commit.
 type CommitAnalysisResult struct {
 	Index   int
+	Repo    string // repository, in runs analyzing several (optional)
 	Hash    string
 	Message string
 	Result  *AnalysisResult
 	Error   error
+
+	// Retries records the LLM call attempts of the commit, if more than
+	// one, whether or not they produced a result
+	Retries *RetryStats
 }
 
 // AnalysisSummary represents the summary of an analysis run.
@@ -44,50 +205,58 @@ type AnalysisSummary struct {
 	Low     int
 	Skipped int
 	Errors  int
+
+	// OverBudget counts the commits skipped for the run's deadline, which
+	// are included in Skipped
+	OverBudget int
+
+	// ErrorKinds breaks Errors down by ErrorKind (nil without errors)
+	ErrorKinds map[string]int
+
+	// SkipReasons breaks the commits skipped for their changes down by
+	// SkipReason (nil without such commits)
+	SkipReasons map[string]int
 }
 
 // CollectCommits gathers commits from a repository for analysis.
-// It skips merge commits and respects the branch and numCommits options.
+// It skips merge commits and respects the branch and numCommits options,
+// and the Only patterns of opts.Diff.Filter. The returned head is the
+// commit of opts.HeadRef, or of opts.Branch if unset. In a shallow clone,
+// collection stops at the oldest fetched commit.
 //
 // Two-Phase Analysis Architecture:
 // To safely enable parallel LLM calls while respecting go-git's thread-safety
 // limitations, use a two-phase approach:
 //
-//   Phase 1 (Sequential): Extract diffs using ExtractDiffs() - go-git operations
+//   Phase 1 (Per handle): Extract diffs using a RepoPool - go-git operations
 //   Phase 2 (Parallel):   Analyze with AnalyzeWithDiffs() - LLM API calls
 //
-// This allows maximum parallelism for the expensive LLM calls while keeping
-// git operations sequential. See ExtractDiffs and AnalyzeWithDiffs in engine.go.
+// A go-git repository must not be used concurrently, so the RepoPool gives
+// each extraction its own repository handle. RunPipeline implements both
+// phases; see also RepoPool in repopool.go and AnalyzeWithDiffs in
+// engine.go.
 func CollectCommits(repo *git.Repository, opts AnalysisOptions) ([]*object.Commit, *object.Commit, error) {
 	if opts.NumCommits <= 0 {
 		opts.NumCommits = DefaultNumCommits
 	}
 
-	// Get HEAD reference (or specified branch)
-	var headRef *plumbing.Reference
-	var err error
+	// Resolve where history starts (HEAD or the specified ref)
+	start, err := ResolveCommit(repo, opts.Branch)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	if opts.Branch != "" {
-		refName := plumbing.NewBranchReferenceName(opts.Branch)
-		headRef, err = repo.Reference(refName, true)
+	// Get the commit to compare against
+	headCommit := start
+	if opts.HeadRef != "" {
+		headCommit, err = ResolveCommit(repo, opts.HeadRef)
 		if err != nil {
-			return nil, nil, fmt.Errorf("failed to find branch %s: %w", opts.Branch, err)
+			return nil, nil, err
 		}
-	} else {
-		headRef, err = repo.Head()
-		if err != nil {
-			return nil, nil, fmt.Errorf("failed to get HEAD: %w", err)
-		}
-	}
-
-	// Get HEAD commit for comparison
-	headCommit, err := repo.CommitObject(headRef.Hash())
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to get HEAD commit: %w", err)
 	}
 
-	// Collect commits
-	cIter, err := repo.Log(&git.LogOptions{From: headRef.Hash()})
+	// Collect commits, only those touching Filter.Only paths if set
+	cIter, err := repo.Log(&git.LogOptions{From: start.Hash, PathFilter: opts.Diff.Filter.CommitPathFilter()})
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to get commit log: %w", err)
 	}
@@ -100,6 +269,9 @@ func CollectCommits(repo *git.Repository, opts AnalysisOptions) ([]*object.Commi
 		if err == io.EOF {
 			break
 		}
+		if IsShallowBoundary(repo, err) {
+			break
+		}
 		if err != nil {
 			return nil, nil, fmt.Errorf("error iterating commits: %w", err)
 		}
@@ -127,14 +299,38 @@ func AnalyzeCommitSequential(
 	model *genai.GenerativeModel,
 ) (*AnalysisResult, error) {
 	var res *AnalysisResult
-	err := WithRetry(ctx, DefaultRetryConfig(), func() error {
+	var stats RetryStats
+	err := WithRetryStats(ctx, DefaultRetryConfig(), &stats, func() error {
 		var analyzeErr error
 		res, analyzeErr = AnalyzeCommit(ctx, repo, commit, headCommit, errorMessage, model)
 		return analyzeErr
 	})
+	res.RecordRetries(stats)
 	return res, err
 }
 
+// Ranking returns the hashes, abbreviated as in JSONResult, of the HIGH
+// and MEDIUM results ordered by suspicion score, most suspicious first.
+// Results with a Repo are listed as "<repo>@<hash>".
+func Ranking(results []CommitAnalysisResult) []string {
+	var suspects []CommitAnalysisResult
+	for _, r := range results {
+		if r.Error == nil && r.Result != nil && !r.Result.Skipped &&
+			(r.Result.Probability == ProbHigh || r.Result.Probability == ProbMedium) {
+			suspects = append(suspects, r)
+		}
+	}
+	sort.SliceStable(suspects, func(i, j int) bool { return suspects[i].Result.Rank() > suspects[j].Result.Rank() })
+	hashes := make([]string, len(suspects))
+	for i, r := range suspects {
+		hashes[i] = r.Hash[:min(8, len(r.Hash))]
+		if r.Repo != "" {
+			hashes[i] = r.Repo + "@" + hashes[i]
+		}
+	}
+	return hashes
+}
+
 // CalculateSummary computes summary statistics from analysis results.
 func CalculateSummary(results []CommitAnalysisResult) AnalysisSummary {
 	summary := AnalysisSummary{
@@ -142,16 +338,27 @@ func CalculateSummary(results []CommitAnalysisResult) AnalysisSummary {
 	}
 
 	for _, r := range results {
-		if r.Error != nil {
-			summary.Errors++
+		if errors.Is(r.Error, ErrBudgetExhausted) {
+			summary.Skipped++
+			summary.OverBudget++
 			continue
 		}
-		if r.Result == nil {
+		if r.Error != nil || r.Result == nil {
 			summary.Errors++
+			if summary.ErrorKinds == nil {
+				summary.ErrorKinds = map[string]int{}
+			}
+			summary.ErrorKinds[cmp.Or(ErrorKind(r.Error), ErrorKindOther)]++
 			continue
 		}
 		if r.Result.Skipped {
 			summary.Skipped++
+			if r.Result.Skip != nil {
+				if summary.SkipReasons == nil {
+					summary.SkipReasons = map[string]int{}
+				}
+				summary.SkipReasons[string(r.Result.Skip.Reason)]++
+			}
 			continue
 		}
 
@@ -167,3 +374,67 @@ func CalculateSummary(results []CommitAnalysisResult) AnalysisSummary {
 
 	return summary
 }
+
+// RunAnalysis collects commits, deepening a shallow clone first if
+// opts.DeepenShallow, and analyzes them with RunPipeline. Results are
+// returned in commit order.
+func RunAnalysis(ctx context.Context, repo *git.Repository, model LLMModel, opts AnalysisOptions) (results []CommitAnalysisResult, err error) {
+	ctx, span := tracer.Start(ctx, "RunAnalysis", trace.WithAttributes(
+		attribute.Int("analysis.num_commits", opts.NumCommits),
+		attribute.String("git.branch", opts.Branch),
+	))
+	defer func() { endSpan(span, err) }()
+
+	if opts.DeepenShallow && IsShallow(repo) {
+		if start, err := ResolveCommit(repo, opts.Branch); err == nil {
+			deepened, err := DeepenShallow(ctx, repo, start, opts.NumCommits+1)
+			if err != nil {
+				opts.progress(fmt.Sprintf("Shallow clone could not be deepened: %v", err))
+			} else if deepened {
+				opts.progress(fmt.Sprintf("Deepened shallow clone to %d commits", opts.NumCommits+1))
+			}
+		}
+	}
+
+	commits, headCommit, err := CollectCommits(repo, opts)
+	if err != nil {
+		return nil, err
+	}
+	return RunPipeline(ctx, repo, commits, headCommit, model, opts)
+}
+
+// orderedEmitter forwards results to a callback in index order,
+// buffering results that complete ahead of their predecessors.
+type orderedEmitter struct {
+	mu      sync.Mutex
+	fn      func(CommitAnalysisResult)
+	pending map[int]CommitAnalysisResult
+	next    int
+}
+
+func newOrderedEmitter(fn func(CommitAnalysisResult)) *orderedEmitter {
+	return &orderedEmitter{
+		fn:      fn,
+		pending: make(map[int]CommitAnalysisResult),
+	}
+}
+
+// submit records a result and flushes all consecutive ready results
+func (e *orderedEmitter) submit(r CommitAnalysisResult) {
+	if e.fn == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.pending[r.Index] = r
+	for {
+		ready, ok := e.pending[e.next]
+		if !ok {
+			break
+		}
+		e.fn(ready)
+		delete(e.pending, e.next)
+		e.next++
+	}
+}
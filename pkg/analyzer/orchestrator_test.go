@@ -1,10 +1,82 @@
 package analyzer
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/kerneldump/git-dual-context/pkg/gitdiff"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/googleapi"
 )
 
+// mockModel is an LLMModel that returns a canned response
+type mockModel struct {
+	mu       sync.Mutex
+	response string
+	err      error
+	calls    int
+}
+
+func (m *mockModel) GenerateContent(ctx context.Context, parts ...genai.Part) (*genai.GenerateContentResponse, error) {
+	m.mu.Lock()
+	m.calls++
+	m.mu.Unlock()
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &genai.GenerateContentResponse{
+		Candidates: []*genai.Candidate{
+			{Content: &genai.Content{Parts: []genai.Part{genai.Text(m.response)}}},
+		},
+	}, nil
+}
+
+// createTestRepo initializes a repository with one commit per file entry
+func createTestRepo(t *testing.T, files []struct{ path, content string }) *git.Repository {
+	t.Helper()
+
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatalf("Failed to init repo: %v", err)
+	}
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("Failed to get worktree: %v", err)
+	}
+
+	for i, f := range files {
+		full := filepath.Join(dir, f.path)
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(full, []byte(f.content), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		if _, err := w.Add(f.path); err != nil {
+			t.Fatalf("Failed to add file: %v", err)
+		}
+		if _, err := w.Commit(fmt.Sprintf("commit %d: %s", i, f.path), &git.CommitOptions{
+			Author: &object.Signature{Name: "Test User", Email: "test@example.com", When: time.Now()},
+		}); err != nil {
+			t.Fatalf("Failed to commit: %v", err)
+		}
+	}
+
+	return repo
+}
+
 func TestAnalysisOptionsDefaults(t *testing.T) {
 	opts := AnalysisOptions{
 		ErrorMessage: "test error",
@@ -72,6 +144,9 @@ func TestCalculateSummary(t *testing.T) {
 	if summary.Errors != 1 {
 		t.Errorf("Expected errors 1, got %d", summary.Errors)
 	}
+	if summary.ErrorKinds[ErrorKindOther] != 1 {
+		t.Errorf("Expected 1 other error, got %v", summary.ErrorKinds)
+	}
 }
 
 func TestCalculateSummaryEmpty(t *testing.T) {
@@ -94,3 +169,380 @@ func TestCalculateSummaryNilResult(t *testing.T) {
 		t.Errorf("Expected errors 1 for nil result, got %d", summary.Errors)
 	}
 }
+
+func TestRanking(t *testing.T) {
+	results := []CommitAnalysisResult{
+		{Hash: "1111111111", Result: &AnalysisResult{Probability: ProbMedium, Suspicion: 0.72}},
+		{Hash: "2222222222", Result: &AnalysisResult{Probability: ProbHigh, Suspicion: 0.65}},
+		{Hash: "3333333333", Result: &AnalysisResult{Probability: ProbLow, Suspicion: 0.9}},
+		{Hash: "4444444444", Result: &AnalysisResult{Probability: ProbMedium, Suspicion: 0.3}},
+		{Hash: "5555555555", Result: &AnalysisResult{Probability: ProbHigh, Suspicion: 0.95}},
+		{Hash: "6666666666", Result: &AnalysisResult{Skipped: true}},
+		{Hash: "7777777777", Error: fmt.Errorf("timeout")},
+	}
+
+	expected := []string{"55555555", "11111111", "22222222", "44444444"}
+	if got := Ranking(results); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected ranking %v, got %v", expected, got)
+	}
+
+	results[0].Repo = "svc-auth"
+	if got := Ranking(results); got[1] != "svc-auth@11111111" {
+		t.Errorf("Expected results with a repository to be qualified, got %v", got)
+	}
+}
+
+func TestRunAnalysis(t *testing.T) {
+	repo := createTestRepo(t, []struct{ path, content string }{
+		{"main.go", "package main\n"},
+		{"go.sum", "checksum\n"},
+		{"main.go", "package main\n\nfunc main() {}\n"},
+	})
+	model := &mockModel{response: `{"probability": "HIGH", "reasoning": "mock"}`}
+
+	var emitted []int
+	results, err := RunAnalysis(context.Background(), repo, model, AnalysisOptions{
+		NumCommits:   3,
+		ErrorMessage: "test error",
+		Workers:      2,
+		OnResult: func(r CommitAnalysisResult) {
+			emitted = append(emitted, r.Index)
+		},
+	})
+	if err != nil {
+		t.Fatalf("RunAnalysis failed: %v", err)
+	}
+
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(results))
+	}
+	for i, idx := range emitted {
+		if idx != i {
+			t.Errorf("Results emitted out of order: %v", emitted)
+			break
+		}
+	}
+	if len(emitted) != 3 {
+		t.Errorf("Expected 3 emitted results, got %d", len(emitted))
+	}
+
+	summary := CalculateSummary(results)
+	if summary.High != 2 || summary.Skipped != 1 || summary.SkipReasons[string(SkipLockFiles)] != 1 {
+		t.Errorf("Expected 2 high and 1 skipped for its lock file, got %+v", summary)
+	}
+	if model.calls != 2 {
+		t.Errorf("Expected 2 LLM calls, got %d", model.calls)
+	}
+}
+
+func TestExtractDiffsRootCommit(t *testing.T) {
+	repo := createTestRepo(t, []struct{ path, content string }{
+		{"main.go", "package main\n\nfunc main() {}\n"},
+		{"server.go", "package main\n\nfunc serve() {}\n"},
+	})
+	commits, head, err := CollectCommits(repo, AnalysisOptions{NumCommits: 2})
+	if err != nil {
+		t.Fatalf("CollectCommits failed: %v", err)
+	}
+	root := commits[1]
+
+	diffCtx, err := ExtractDiffsContext(context.Background(), repo, root, head, gitdiff.Options{})
+	if err != nil {
+		t.Fatalf("ExtractDiffsContext failed: %v", err)
+	}
+	if diffCtx.Skipped || !strings.Contains(diffCtx.StandardDiff, "+func main() {}") {
+		t.Errorf("Expected the root commit's file added, got:\n%s", diffCtx.StandardDiff)
+	}
+	if !strings.Contains(diffCtx.FullDiff, "first commit; files added since, not shown here: 1.") {
+		t.Errorf("Expected the file added since counted, got %q", diffCtx.FullDiff)
+	}
+}
+
+func TestExtractDiffsSkipsIgnoredPathsBeforeStats(t *testing.T) {
+	repo := createTestRepo(t, []struct{ path, content string }{
+		{"main.go", "package main\n"},
+		{".github/workflows/ci.yml", "on: push\n"},
+	})
+	head, err := repo.Head()
+	if err != nil {
+		t.Fatalf("Failed to get HEAD: %v", err)
+	}
+	c, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		t.Fatalf("Failed to get commit: %v", err)
+	}
+
+	diffCtx, err := ExtractDiffsContext(context.Background(), repo, c, c, gitdiff.Options{})
+	if err != nil {
+		t.Fatalf("ExtractDiffsContext failed: %v", err)
+	}
+	if !diffCtx.Skipped {
+		t.Error("Expected CI-only commit to be skipped")
+	}
+	if diffCtx.Stats != nil {
+		t.Errorf("Expected no patch stats for a pre-filtered commit, got %+v", diffCtx.Stats)
+	}
+}
+
+func TestRunAnalysisFilterProfiles(t *testing.T) {
+	repo := createTestRepo(t, []struct{ path, content string }{
+		{"go.mod", "module example.com/app\n"},
+		{"main.go", "package main\n"},
+		{"api/service.pb.go", "package api\n"},
+	})
+	model := &mockModel{response: `{"probability": "LOW", "reasoning": "mock"}`}
+	filter := &gitdiff.Filter{}
+
+	var progress []string
+	results, err := RunAnalysis(context.Background(), repo, model, AnalysisOptions{
+		NumCommits:     1,
+		ErrorMessage:   "test error",
+		FilterProfiles: []string{"auto"},
+		Diff:           gitdiff.Options{Filter: filter},
+		OnProgress:     func(msg string) { progress = append(progress, msg) },
+	})
+	if err != nil {
+		t.Fatalf("RunAnalysis failed: %v", err)
+	}
+
+	if results[0].Result == nil || !results[0].Result.Skipped {
+		t.Errorf("Expected protobuf-only commit to be skipped by the go profile, got %+v", results[0].Result)
+	}
+	if len(filter.Exclude) != 0 {
+		t.Errorf("Expected caller's filter to be left unchanged, got %v", filter.Exclude)
+	}
+	if len(progress) == 0 || progress[0] != "Filter profiles: go" {
+		t.Errorf("Expected applied profiles to be reported, got %v", progress)
+	}
+
+	if _, err := RunAnalysis(context.Background(), repo, model, AnalysisOptions{
+		NumCommits:     1,
+		ErrorMessage:   "test error",
+		FilterProfiles: []string{"cobol"},
+	}); err == nil {
+		t.Error("Expected error for unknown filter profile")
+	}
+}
+
+func TestRunAnalysisSuggestOwners(t *testing.T) {
+	repo := createTestRepo(t, []struct{ path, content string }{
+		{"CODEOWNERS", "*.go @acme/app\n"},
+		{"main.go", "package main\n"},
+	})
+
+	for _, tt := range []struct {
+		probability Probability
+		expected    int
+	}{
+		{ProbHigh, 1},
+		{ProbLow, 0},
+	} {
+		model := &mockModel{response: fmt.Sprintf(`{"probability": %q, "reasoning": "mock"}`, tt.probability)}
+		results, err := RunAnalysis(context.Background(), repo, model, AnalysisOptions{
+			NumCommits:    1,
+			ErrorMessage:  "test error",
+			SuggestOwners: true,
+		})
+		if err != nil {
+			t.Fatalf("RunAnalysis failed: %v", err)
+		}
+		owners := results[0].Result.Owners
+		if len(owners) != tt.expected {
+			t.Fatalf("Expected %d owners for a %s result, got %+v", tt.expected, tt.probability, owners)
+		}
+		if tt.expected > 0 && (owners[0].Owner != "@acme/app" || owners[0].Files[0] != "main.go") {
+			t.Errorf("Expected @acme/app to own main.go, got %+v", owners[0])
+		}
+	}
+}
+
+func TestRunAnalysisOffline(t *testing.T) {
+	repo := createTestRepo(t, []struct{ path, content string }{
+		{"cache.go", "package cache\n\nfunc evictAll() {}\n"},
+		{"README.md", "# Cache\n"},
+	})
+
+	var emitted int
+	results, err := RunAnalysis(context.Background(), repo, nil, AnalysisOptions{
+		NumCommits:   2,
+		ErrorMessage: "panic in evictAll at cache.go:3",
+		Offline:      true,
+		OnResult:     func(CommitAnalysisResult) { emitted++ },
+	})
+	if err != nil {
+		t.Fatalf("RunAnalysis failed: %v", err)
+	}
+	if emitted != 2 {
+		t.Errorf("Expected 2 emitted results, got %d", emitted)
+	}
+	res := results[1].Result
+	if res == nil || res.Heuristics == nil || res.Probability != ProbHigh {
+		t.Errorf("Expected a HIGH heuristic verdict for cache.go, got %+v", res)
+	}
+}
+
+func TestCollectCommitsOnly(t *testing.T) {
+	repo := createTestRepo(t, []struct{ path, content string }{
+		{"pkg/auth/session.go", "package auth\n"},
+		{"pkg/billing/invoice.go", "package billing\n"},
+		{"pkg/billing/tax.go", "package billing\n"},
+	})
+	filter := &gitdiff.Filter{}
+	if err := filter.SetOnly([]string{"pkg/auth/**"}); err != nil {
+		t.Fatalf("SetOnly failed: %v", err)
+	}
+
+	commits, _, err := CollectCommits(repo, AnalysisOptions{NumCommits: 2, Diff: gitdiff.Options{Filter: filter}})
+	if err != nil {
+		t.Fatalf("CollectCommits failed: %v", err)
+	}
+	if len(commits) != 1 || commits[0].Message != "commit 0: pkg/auth/session.go" {
+		t.Errorf("Expected only the commit touching pkg/auth, got %d commits", len(commits))
+	}
+}
+
+func TestRunAnalysisMinChangedLines(t *testing.T) {
+	repo := createTestRepo(t, []struct{ path, content string }{
+		{"main.go", "package main\n"},
+		{"main.go", "package main\n\nfunc main() {}\n"},
+	})
+	model := &mockModel{response: `{"probability": "LOW", "reasoning": "mock"}`}
+
+	results, err := RunAnalysis(context.Background(), repo, model, AnalysisOptions{
+		NumCommits:   2,
+		ErrorMessage: "test error",
+		Diff:         gitdiff.Options{MinChangedLines: 2},
+	})
+	if err != nil {
+		t.Fatalf("RunAnalysis failed: %v", err)
+	}
+
+	// The latest commit adds two lines; the first adds one and is skipped
+	if results[0].Result == nil || results[0].Result.Skipped {
+		t.Errorf("Expected 2-line commit to be analyzed, got %+v", results[0].Result)
+	}
+	if results[1].Result == nil || !results[1].Result.Skipped || results[1].Result.Skip == nil || results[1].Result.Skip.Reason != SkipTrivial {
+		t.Errorf("Expected 1-line commit to be skipped as too small, got %+v", results[1].Result)
+	}
+	if stats := results[0].Result.Stats; stats == nil || stats.Insertions != 2 {
+		t.Errorf("Expected stats with 2 insertions, got %+v", stats)
+	}
+	if model.calls != 1 {
+		t.Errorf("Expected 1 LLM call, got %d", model.calls)
+	}
+}
+
+func TestRunAnalysisNonFunctional(t *testing.T) {
+	repo := createTestRepo(t, []struct{ path, content string }{
+		{"main.go", "package main\n\nfunc main() {}\n"},
+		{"main.go", "package main\n\n// main is the entry point\nfunc main() {\n}\n"},
+	})
+	model := &mockModel{response: `{"probability": "HIGH", "reasoning": "mock"}`}
+
+	results, err := RunAnalysis(context.Background(), repo, model, AnalysisOptions{
+		NumCommits:   1,
+		ErrorMessage: "test error",
+	})
+	if err != nil {
+		t.Fatalf("RunAnalysis failed: %v", err)
+	}
+
+	r := results[0].Result
+	if r == nil || r.Probability != ProbLow {
+		t.Fatalf("Expected LOW verdict, got %+v", r)
+	}
+	if r.Reasoning != "No functional change (only comments changed); not sent to the LLM." {
+		t.Errorf("Unexpected reasoning: %q", r.Reasoning)
+	}
+	if model.calls != 0 {
+		t.Errorf("Expected no LLM calls, got %d", model.calls)
+	}
+
+	results, err = RunAnalysis(context.Background(), repo, model, AnalysisOptions{
+		NumCommits:   1,
+		ErrorMessage: "test error",
+		Diff:         gitdiff.Options{AnalyzeNonFunctional: true},
+	})
+	if err != nil {
+		t.Fatalf("RunAnalysis failed: %v", err)
+	}
+	if results[0].Result == nil || results[0].Result.Probability != ProbHigh || model.calls != 1 {
+		t.Errorf("Expected LLM verdict with AnalyzeNonFunctional, got %+v after %d calls", results[0].Result, model.calls)
+	}
+}
+
+func TestRunAnalysisModelError(t *testing.T) {
+	repo := createTestRepo(t, []struct{ path, content string }{
+		{"main.go", "package main\n"},
+	})
+	model := &mockModel{err: fmt.Errorf("invalid request")}
+
+	results, err := RunAnalysis(context.Background(), repo, model, AnalysisOptions{
+		NumCommits:   1,
+		ErrorMessage: "test error",
+	})
+	if err != nil {
+		t.Fatalf("RunAnalysis failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Error == nil {
+		t.Errorf("Expected a per-commit error, got %+v", results)
+	}
+}
+
+func TestRunAnalysisRetry(t *testing.T) {
+	repo := createTestRepo(t, []struct{ path, content string }{
+		{"main.go", "package main\n"},
+	})
+	model := &mockModel{err: &googleapi.Error{Code: 503}}
+
+	results, err := RunAnalysis(context.Background(), repo, model, AnalysisOptions{
+		NumCommits:   1,
+		ErrorMessage: "test error",
+		Retry:        RetryConfig{MaxRetries: 1, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("RunAnalysis failed: %v", err)
+	}
+	if len(results) != 1 || results[0].Error == nil {
+		t.Fatalf("Expected a per-commit error, got %+v", results)
+	}
+	if model.calls != 2 {
+		t.Errorf("Expected 2 attempts with MaxRetries 1, got %d", model.calls)
+	}
+
+	// The attempts are reported without a result
+	retries := results[0].Retries
+	if retries == nil || retries.Attempts != 2 || len(retries.Errors) != 2 || retries.BackoffMS < 1 {
+		t.Fatalf("Expected 2 failed attempts with backoff, got %+v", retries)
+	}
+}
+
+func TestRunAnalysisRunTimeout(t *testing.T) {
+	repo := createTestRepo(t, []struct{ path, content string }{
+		{"main.go", "package main\n"},
+		{"main.go", "package main\n\nfunc main() {}\n"},
+	})
+	model := &mockModel{response: `{"probability": "HIGH", "reasoning": "mock"}`}
+
+	// A deadline shorter than MinCallBudget leaves no time for any call
+	results, err := RunAnalysis(context.Background(), repo, model, AnalysisOptions{
+		NumCommits:   2,
+		ErrorMessage: "test error",
+		RunTimeout:   time.Second,
+	})
+	if err != nil {
+		t.Fatalf("RunAnalysis failed: %v", err)
+	}
+	for _, r := range results {
+		if !errors.Is(r.Error, ErrBudgetExhausted) {
+			t.Errorf("Expected ErrBudgetExhausted, got %v", r.Error)
+		}
+	}
+	if model.calls != 0 {
+		t.Errorf("Expected no LLM calls, got %d", model.calls)
+	}
+	summary := CalculateSummary(results)
+	if summary.Skipped != 2 || summary.OverBudget != 2 || summary.Errors != 0 {
+		t.Errorf("Expected 2 commits skipped over budget, got %+v", summary)
+	}
+}
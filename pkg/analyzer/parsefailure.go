@@ -0,0 +1,60 @@
+package analyzer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ParseError is the error of an LLM response holding no valid verdict. It
+// keeps the prompt and the raw response, which SaveParseFailure writes out
+// for debugging.
+type ParseError struct {
+	Commit   string // full hash
+	Prompt   string
+	Response string // raw text, empty if the response had none
+
+	err error
+}
+
+func (e *ParseError) Error() string { return e.err.Error() }
+func (e *ParseError) Unwrap() error { return e.err }
+
+// newParseError returns a ParseError for the response to prompt
+func newParseError(diffCtx *CommitDiffContext, prompt, response string, err error) *ParseError {
+	return &ParseError{Commit: diffCtx.Commit.Hash.String(), Prompt: prompt, Response: response, err: err}
+}
+
+// SaveParseFailure writes the prompt and raw response of the ParseError in
+// err to a new file in dir, which is created if needed, and returns its
+// path. It returns "" if err holds no ParseError. A leading ~/ in dir is
+// expanded to the home directory. Prompts include source code, so only the
+// user can read the files.
+func SaveParseFailure(dir string, err error) (string, error) {
+	var pe *ParseError
+	if !errors.As(err, &pe) {
+		return "", nil
+	}
+	if strings.HasPrefix(dir, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get home directory: %w", err)
+		}
+		dir = filepath.Join(home, dir[2:])
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("creating debug directory: %w", err)
+	}
+	f, err := os.CreateTemp(dir, fmt.Sprintf("%s-%s-*.txt", pe.Commit[:min(8, len(pe.Commit))], time.Now().UTC().Format("20060102T150405")))
+	if err != nil {
+		return "", fmt.Errorf("creating debug file: %w", err)
+	}
+	fmt.Fprintf(f, "commit: %s\nerror: %s\n\n=== PROMPT ===\n%s\n\n=== RESPONSE ===\n%s\n", pe.Commit, pe.err, pe.Prompt, pe.Response)
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("writing debug file: %w", err)
+	}
+	return f.Name(), nil
+}
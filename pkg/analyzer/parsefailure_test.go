@@ -0,0 +1,50 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSaveParseFailure(t *testing.T) {
+	repo := createTestRepo(t, []struct{ path, content string }{
+		{"main.go", "package main\n"},
+	})
+	results, err := RunAnalysis(context.Background(), repo, &mockModel{response: "I cannot decide."}, AnalysisOptions{
+		NumCommits:   1,
+		ErrorMessage: "session expired too early",
+	})
+	if err != nil {
+		t.Fatalf("RunAnalysis failed: %v", err)
+	}
+	var pe *ParseError
+	if !errors.As(results[0].Error, &pe) || pe.Response != "I cannot decide." || pe.Commit != results[0].Hash {
+		t.Fatalf("Expected a ParseError with the raw response, got %#v", results[0].Error)
+	}
+
+	dir := filepath.Join(t.TempDir(), "debug")
+	entry := NewErrorEntry("Failed to analyze commit", results[0].Hash, results[0].Error, dir)
+	if entry.ErrorKind != ErrorKindParse || !strings.HasPrefix(entry.DebugFile, dir) {
+		t.Fatalf("Expected a parse failure saved in %s, got %+v", dir, entry)
+	}
+	data, err := os.ReadFile(entry.DebugFile)
+	if err != nil {
+		t.Fatalf("Reading debug file failed: %v", err)
+	}
+	for _, want := range []string{results[0].Hash, "no JSON found", "session expired too early", "=== RESPONSE ===\nI cannot decide."} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("Expected the debug file to contain %q:\n%s", want, data)
+		}
+	}
+
+	// Other errors have nothing to save
+	if path, err := SaveParseFailure(dir, errors.New("connection reset")); path != "" || err != nil {
+		t.Errorf("Expected nothing saved, got %q, %v", path, err)
+	}
+	if entry := NewErrorEntry("Failed", "abc", results[0].Error, ""); entry.DebugFile != "" {
+		t.Errorf("Expected nothing saved without a debug directory, got %q", entry.DebugFile)
+	}
+}
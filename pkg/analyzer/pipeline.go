@@ -0,0 +1,433 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/kerneldump/git-dual-context/pkg/ci"
+	"github.com/kerneldump/git-dual-context/pkg/gitdiff"
+	"github.com/kerneldump/git-dual-context/pkg/issues"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RunPipeline analyzes commits, collected beforehand such as by
+// CollectCommits, against headCommit using the two-phase architecture:
+// diffs are extracted on opts.ExtractWorkers repository handles, then LLM
+// calls run in parallel bounded by opts.Workers, or opts.Slots. It is the analysis shared
+// by the CLI, the MCP server, and the HTTP server, which only differ in the
+// hooks of opts they follow the run with.
+//
+// Results are returned, and passed to opts.OnResult, in commit order. With
+// opts.Offline, commits are scored with AnalyzeHeuristically instead of the
+// LLM, which may then be nil. Commits not stored in repo, such as those of
+// UncommittedCommit, cannot be read by other handles and are extracted on
+// repo itself, one at a time. With opts.Verify, the most suspicious
+// commits are then verified by running its command in checkouts of them
+// (see Verify), and results are passed to opts.OnResult after that.
+//
+// If ctx is cancelled while diffs are extracted, the results are returned
+// with ctx's error; commits left unanalyzed fail with it. Any other error,
+// such as an invalid filter profile, is returned before any result is
+// passed to opts.OnResult, with nil results.
+func RunPipeline(ctx context.Context, repo *git.Repository, commits []*object.Commit, headCommit *object.Commit, model LLMModel, opts AnalysisOptions) (results []CommitAnalysisResult, err error) {
+	ctx, span := tracer.Start(ctx, "RunPipeline", trace.WithAttributes(
+		attribute.Int("analysis.num_commits", len(commits)),
+	))
+	defer func() { endSpan(span, err) }()
+
+	if opts.Workers <= 0 {
+		opts.Workers = DefaultNumWorkers
+	}
+	if opts.ExtractWorkers <= 0 {
+		opts.ExtractWorkers = opts.Workers
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.Retry == (RetryConfig{}) {
+		opts.Retry = DefaultRetryConfig()
+	}
+	budget := opts.Budget
+	if budget == nil {
+		budget = NewBudget(opts.RunTimeout)
+	}
+
+	results = make([]CommitAnalysisResult, len(commits))
+	for i, c := range commits {
+		results[i] = CommitAnalysisResult{
+			Index:   i,
+			Hash:    c.Hash.String(),
+			Message: c.Message,
+		}
+	}
+	// With verification, results are held back until the suspects among
+	// them are verified
+	emitter := newOrderedEmitter(opts.OnResult)
+	verify := opts.Verify.Command != ""
+	finish := func(r CommitAnalysisResult) {
+		if r.Result != nil {
+			r.Result.Reasoning = opts.Verbosity.Trim(r.Result.Reasoning)
+		}
+		if opts.OnAnalyzed != nil {
+			opts.OnAnalyzed(r)
+		}
+		if !verify {
+			emitter.submit(r)
+		}
+	}
+	flush := func() {
+		if verify {
+			for _, r := range results {
+				emitter.submit(r)
+			}
+		}
+	}
+	if len(commits) == 0 {
+		return results, nil
+	}
+
+	diffOpts, err := opts.DiffOptions(ctx, headCommit)
+	if err != nil {
+		return nil, err
+	}
+
+	// Phase 1: Extract diffs, each on a repository handle of its own (go-git
+	// is NOT thread-safe), but for commits whose verdict is known
+	var pending []*object.Commit
+	var indexes []int
+	for i, c := range commits {
+		if opts.Known != nil {
+			if res := opts.Known(i, c); res != nil {
+				res.Score(opts.ScoreWeights)
+				results[i].Result = res
+				continue
+			}
+		}
+		pending = append(pending, c)
+		indexes = append(indexes, i)
+	}
+	diffContexts := make([]*CommitDiffContext, len(commits))
+	extracted := func(j int, dc *CommitDiffContext, err error) {
+		i := indexes[j]
+		diffContexts[i] = dc
+		if err != nil {
+			results[i].Error = fmt.Errorf("diff extraction failed: %w", err)
+		}
+		if opts.OnExtracted != nil {
+			opts.OnExtracted(i, dc, err)
+		}
+	}
+	started := func(j int, c *object.Commit) {
+		opts.progress(fmt.Sprintf("Extracting diffs %d/%d: %s", j+1, len(pending), c.Hash.String()[:8]))
+	}
+	if stored(repo, append([]*object.Commit{headCommit}, pending...)) {
+		pool, err := NewRepoPool(repo, opts.ExtractWorkers, opts.ObjectCacheMB)
+		if err != nil {
+			return nil, err
+		}
+		pool.extractEach(ctx, pending, headCommit, diffOpts, started, extracted)
+	} else {
+		for j, c := range pending {
+			started(j, c)
+			dc, err := ExtractDiffsContext(ctx, repo, c, headCommit, diffOpts)
+			extracted(j, dc, err)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		for i := range results {
+			if results[i].Result == nil {
+				results[i].Error = err
+			}
+			finish(results[i])
+		}
+		flush()
+		return results, err
+	}
+
+	// The part of the prompts shared by all commits is cached once; the
+	// commits are analyzed with whole prompts without it
+	if opts.ContextCache != nil && !opts.Offline && len(pending) > 0 {
+		head, err := HeadContext(pending, headCommit, diffOpts.Filter, opts.ContextCacheTokens)
+		if err == nil {
+			err = opts.ContextCache.Prepare(ctx, opts.ErrorMessage, head)
+		}
+		if err != nil {
+			opts.progress(fmt.Sprintf("Context cache unavailable: %v", err))
+		}
+	}
+
+	// Phase 2: Analyze with LLM in parallel, emitting results in order
+	origin := originURL(repo)
+	resolver := opts.issueResolver(origin)
+	checker := opts.ciChecker(origin)
+	var dedup *PatchDedup
+	if opts.DedupePatches {
+		dedup = NewPatchDedup(opts.ErrorMessage)
+	}
+	sem := opts.Slots
+	if sem == nil {
+		sem = make(chan struct{}, opts.Workers)
+	}
+	var wg sync.WaitGroup
+
+	for i, diffCtx := range diffContexts {
+		switch {
+		case diffCtx == nil:
+			// Known, or failed to extract
+			finish(results[i])
+			continue
+		case opts.Offline:
+			results[i].Result = AnalyzeHeuristically(diffCtx, opts.ErrorMessage)
+			results[i].Result.Score(opts.ScoreWeights)
+			finish(results[i])
+			continue
+		case diffCtx.Skipped:
+			results[i].Result = &AnalysisResult{Skipped: true, Skip: diffCtx.Skip}
+			finish(results[i])
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(idx int, dc *CommitDiffContext) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := ctx.Err(); err != nil {
+				results[idx].Error = err
+				finish(results[idx])
+				return
+			}
+			if opts.BeforeAnalyze != nil {
+				if err := opts.BeforeAnalyze(idx, dc); err != nil {
+					results[idx].Error = err
+					finish(results[idx])
+					return
+				}
+			}
+
+			// Commits the deadline leaves no time for are skipped
+			timeout, err := budget.Timeout(opts.Timeout)
+			if err != nil {
+				results[idx].Error = err
+				finish(results[idx])
+				return
+			}
+
+			opts.resolveIssues(ctx, resolver, dc)
+			opts.checkCI(ctx, checker, dc)
+			opts.progress(fmt.Sprintf("Analyzing commit %s with LLM", dc.Commit.Hash.String()[:8]))
+
+			llm := model
+			if opts.CommitModel != nil {
+				llm = opts.CommitModel(idx, model)
+			}
+			llm = opts.Verbosity.Model(llm)
+			spanCtx, commitSpan := tracer.Start(ctx, "AnalyzeCommit", trace.WithAttributes(
+				attribute.String("git.commit", dc.Commit.Hash.String()),
+			))
+			reqCtx, cancel := context.WithTimeout(spanCtx, timeout)
+			defer cancel()
+
+			var stats RetryStats
+			res, err := dedup.Analyze(dc, func() (*AnalysisResult, error) {
+				var res *AnalysisResult
+				stats = RetryStats{}
+				err := WithRetryStats(reqCtx, opts.Retry, &stats, func() error {
+					var analyzeErr error
+					res, analyzeErr = AnalyzeWithDiffs(reqCtx, dc, opts.ErrorMessage, llm)
+					return analyzeErr
+				})
+				res.RecordRetries(stats)
+				return res, err
+			})
+			if res != nil {
+				res.Score(opts.ScoreWeights)
+				commitSpan.SetAttributes(attribute.String("analysis.probability", string(res.Probability)))
+			}
+			endSpan(commitSpan, err)
+
+			results[idx].Result = res
+			results[idx].Error = err
+			results[idx].Retries = stats.retried()
+			finish(results[idx])
+		}(i, diffCtx)
+	}
+
+	wg.Wait()
+	if verify {
+		opts.verifySuspects(ctx, commits, results)
+	}
+	flush()
+	return results, nil
+}
+
+// progress reports msg to OnProgress, if set
+func (opts *AnalysisOptions) progress(msg string) {
+	if opts.OnProgress != nil {
+		opts.OnProgress(msg)
+	}
+}
+
+// originURL returns the URL of repo's origin remote, or "" without one
+func originURL(repo *git.Repository) string {
+	origin, err := repo.Remote(git.DefaultRemoteName)
+	if err != nil || len(origin.Config().URLs) == 0 {
+		return ""
+	}
+	return origin.Config().URLs[0]
+}
+
+// issueResolver returns the resolver of opts.Issues, resolving GitHub
+// references in the repository at the origin URL unless one is named, or
+// nil if no tracker is configured
+func (opts *AnalysisOptions) issueResolver(origin string) *issues.Resolver {
+	cfg := opts.Issues
+	if cfg.GitHubRepo == "" {
+		cfg.GitHubRepo = issues.GitHubRepo(origin)
+	}
+	return issues.New(cfg)
+}
+
+// ciChecker returns the checker of opts.CI, querying the repository at the
+// origin URL on GitHub, or else GitLab, unless one is named, or nil if no
+// forge is configured
+func (opts *AnalysisOptions) ciChecker(origin string) *ci.Checker {
+	cfg := opts.CI
+	if cfg.GitHubRepo == "" {
+		cfg.GitHubRepo = issues.GitHubRepo(origin)
+	}
+	if cfg.GitLabProject == "" && issues.GitHubRepo(origin) == "" {
+		cfg.GitLabProject = ci.RemotePath(origin)
+	}
+	return ci.New(cfg)
+}
+
+// checkCI adds dc's CI status to its metadata. A lookup that fails is
+// reported and left out.
+func (opts *AnalysisOptions) checkCI(ctx context.Context, checker *ci.Checker, dc *CommitDiffContext) {
+	if checker == nil || dc.Metadata == nil {
+		return
+	}
+	status, err := checker.Status(ctx, dc.Commit.Hash.String())
+	if err != nil {
+		opts.progress(fmt.Sprintf("CI status unavailable for %s: %v", dc.Commit.Hash.String()[:8], err))
+	}
+	dc.Metadata.CI = status
+}
+
+// resolveIssues adds the titles of the issues dc's message references to
+// its metadata. Lookups that fail are reported and left out.
+func (opts *AnalysisOptions) resolveIssues(ctx context.Context, resolver *issues.Resolver, dc *CommitDiffContext) {
+	if resolver == nil || dc.Metadata == nil || len(dc.Metadata.Issues) == 0 {
+		return
+	}
+	titles, err := resolver.Titles(ctx, dc.Metadata.Issues)
+	if err != nil {
+		opts.progress(fmt.Sprintf("Issue titles unavailable for %s: %v", dc.Commit.Hash.String()[:8], err))
+	}
+	dc.Metadata.IssueTitles = titles
+}
+
+// DiffOptions returns opts.Diff completed with the error message and what
+// the other options read from headCommit: filter profiles, owners,
+// affected tests, the hotspot prior, the tech stack, related files, and Go
+// callers. RunPipeline extracts diffs with them; callers extracting diffs
+// themselves get the same. Only an invalid filter profile is an error:
+// the others are reported to OnProgress and left out.
+func (opts *AnalysisOptions) DiffOptions(ctx context.Context, headCommit *object.Commit) (gitdiff.Options, error) {
+	diffOpts := opts.Diff
+	if diffOpts.ErrorMessage == "" {
+		diffOpts.ErrorMessage = opts.ErrorMessage
+	}
+	if len(opts.FilterProfiles) > 0 {
+		// Copy the filter so the caller's is not extended
+		filter := &gitdiff.Filter{}
+		if diffOpts.Filter != nil {
+			*filter = *diffOpts.Filter
+			filter.Exclude = slices.Clone(filter.Exclude)
+		}
+		headTree, err := headCommit.Tree()
+		if err != nil {
+			return diffOpts, fmt.Errorf("failed to get HEAD tree: %w", err)
+		}
+		applied, err := filter.AddProfiles(opts.FilterProfiles, headTree)
+		if err != nil {
+			return diffOpts, fmt.Errorf("invalid filter profile: %w", err)
+		}
+		if len(applied) > 0 {
+			opts.progress(fmt.Sprintf("Filter profiles: %s", strings.Join(applied, ", ")))
+		}
+		diffOpts.Filter = filter
+	}
+	if opts.SuggestOwners && diffOpts.Owners == nil {
+		diffOpts.Owners = gitdiff.NewOwnerResolver(headCommit)
+	}
+	if opts.AffectedTests && diffOpts.Tests == nil {
+		tests, err := gitdiff.LoadTestMap(headCommit)
+		if err != nil {
+			opts.progress(fmt.Sprintf("Affected tests unavailable: %v", err))
+		}
+		diffOpts.Tests = tests
+	}
+	if opts.HotspotHistory > 0 && diffOpts.Hotspots == nil {
+		// The prior only orders results; analysis goes on without it
+		hotspots, err := gitdiff.LoadHotspots(headCommit, opts.HotspotHistory)
+		if err != nil {
+			opts.progress(fmt.Sprintf("Hotspot prior unavailable: %v", err))
+		}
+		diffOpts.Hotspots = hotspots
+	}
+	if opts.TechStack && diffOpts.TechStack == nil {
+		stack, err := detectTechStack(headCommit)
+		if err != nil {
+			opts.progress(fmt.Sprintf("Tech stack unavailable: %v", err))
+		}
+		diffOpts.TechStack = stack
+	}
+	if opts.RelatedFiles > 0 && diffOpts.Related == nil {
+		// Related files only add context; analysis goes on without them
+		related, err := gitdiff.LoadRelated(headCommit, gitdiff.DefaultHotspotHistory, opts.RelatedFiles)
+		if err != nil {
+			opts.progress(fmt.Sprintf("Related files unavailable: %v", err))
+		}
+		diffOpts.Related = related
+	}
+	if opts.GoCallers && diffOpts.Callers == nil {
+		opts.progress("Indexing Go call sites at HEAD")
+		callers, err := gitdiff.LoadCallers(ctx, headCommit)
+		if err != nil {
+			opts.progress(fmt.Sprintf("Go callers unavailable: %v", err))
+		}
+		diffOpts.Callers = callers
+	}
+	return diffOpts, nil
+}
+
+// detectTechStack detects the tech stack of head's tree
+func detectTechStack(head *object.Commit) (*gitdiff.TechStack, error) {
+	tree, err := head.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get HEAD tree: %w", err)
+	}
+	return gitdiff.DetectTechStack(tree)
+}
+
+// stored reports whether all commits are stored in repo, where the other
+// handles of a RepoPool can read them
+func stored(repo *git.Repository, commits []*object.Commit) bool {
+	for _, c := range commits {
+		if repo.Storer.HasEncodedObject(c.Hash) != nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,253 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kerneldump/git-dual-context/pkg/ci"
+	"github.com/kerneldump/git-dual-context/pkg/issues"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/google/generative-ai-go/genai"
+)
+
+func TestRunPipelineHooks(t *testing.T) {
+	repo := createTestRepo(t, []struct{ path, content string }{
+		{"main.go", "package main\n"},
+		{"util.go", "package main\n\nfunc util() {}\n"},
+		{"go.sum", "checksum\n"},
+		{"main.go", "package main\n\nfunc main() { util() }\n"},
+	})
+	commits, head, err := CollectCommits(repo, AnalysisOptions{NumCommits: 4})
+	if err != nil {
+		t.Fatalf("CollectCommits failed: %v", err)
+	}
+	model := &mockModel{response: `{"probability": "HIGH", "reasoning": "mock"}`}
+
+	// The newest commit's verdict is known, and the oldest is held back
+	errHeld := errors.New("held back")
+	var mu sync.Mutex
+	var extracted, analyzed, wrapped []int
+	results, err := RunPipeline(context.Background(), repo, commits, head, model, AnalysisOptions{
+		ErrorMessage: "test error",
+		Workers:      2,
+		Known: func(i int, c *object.Commit) *AnalysisResult {
+			if i == 0 {
+				return &AnalysisResult{Probability: ProbLow, Reasoning: "stored"}
+			}
+			return nil
+		},
+		OnExtracted: func(i int, dc *CommitDiffContext, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			extracted = append(extracted, i)
+		},
+		BeforeAnalyze: func(i int, dc *CommitDiffContext) error {
+			if i == 3 {
+				return errHeld
+			}
+			return nil
+		},
+		CommitModel: func(i int, model LLMModel) LLMModel {
+			mu.Lock()
+			defer mu.Unlock()
+			wrapped = append(wrapped, i)
+			return model
+		},
+		OnAnalyzed: func(r CommitAnalysisResult) {
+			mu.Lock()
+			defer mu.Unlock()
+			analyzed = append(analyzed, r.Index)
+		},
+	})
+	if err != nil {
+		t.Fatalf("RunPipeline failed: %v", err)
+	}
+
+	if len(extracted) != 3 {
+		t.Errorf("Expected the 3 commits without a known verdict extracted, got %v", extracted)
+	}
+	if len(analyzed) != 4 {
+		t.Errorf("Expected every commit's result reported, got %v", analyzed)
+	}
+	if len(wrapped) != 1 || wrapped[0] != 2 || model.calls != 1 {
+		t.Errorf("Expected only commit 2 sent to the LLM, got %v (%d calls)", wrapped, model.calls)
+	}
+	if r := results[0].Result; r == nil || r.Reasoning != "stored" {
+		t.Errorf("Expected the known verdict, got %+v", r)
+	}
+	if r := results[1].Result; r == nil || !r.Skipped {
+		t.Errorf("Expected the go.sum commit skipped, got %+v", r)
+	}
+	if r := results[2].Result; r == nil || r.Probability != ProbHigh {
+		t.Errorf("Expected commit 2 rated HIGH, got %+v", r)
+	}
+	if !errors.Is(results[3].Error, errHeld) {
+		t.Errorf("Expected the held back commit to fail with the hook's error, got %v", results[3].Error)
+	}
+}
+
+func TestRunPipelineUncommitted(t *testing.T) {
+	repo := createTestRepo(t, []struct{ path, content string }{
+		{"main.go", "package main\n"},
+	})
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("Failed to get worktree: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(wt.Filesystem.Root(), "main.go"), []byte("package main\n\nfunc main() { panic(nil) }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := UncommittedCommit(repo, false, nil)
+	if err != nil {
+		t.Fatalf("UncommittedCommit failed: %v", err)
+	}
+
+	// The commit is not in the repository, so no other handle can read it
+	results, err := RunPipeline(context.Background(), repo, []*object.Commit{c}, c, nil, AnalysisOptions{
+		ErrorMessage: "panic in main",
+		Workers:      4,
+		Offline:      true,
+	})
+	if err != nil {
+		t.Fatalf("RunPipeline failed: %v", err)
+	}
+	if results[0].Error != nil || results[0].Result == nil || results[0].Result.Skipped {
+		t.Errorf("Expected the uncommitted changes analyzed, got %+v", results[0])
+	}
+}
+
+func TestRunPipelineIssuesAndCI(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/repos/acme/app/issues/12":
+			io.WriteString(w, `{"title": "Sessions expire after 5 minutes"}`)
+		case strings.HasSuffix(r.URL.Path, "/check-runs"):
+			io.WriteString(w, `{"check_runs": [{"name": "test", "status": "completed", "conclusion": "failure"}]}`)
+		case strings.HasSuffix(r.URL.Path, "/status"):
+			io.WriteString(w, `{"statuses": []}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	repo := createTestRepo(t, []struct{ path, content string }{
+		{"session.go", "package main\n\nconst ttl = 60\n"},
+	})
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("Failed to get worktree: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(wt.Filesystem.Root(), "session.go"), []byte("package main\n\nconst ttl = 5\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := wt.Commit("Shorten session TTL (#12)", &git.CommitOptions{All: true, Author: &object.Signature{Name: "Dana", Email: "dana@example.com", When: time.Now()}})
+	if err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+	if _, err := repo.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{"git@github.com:acme/app.git"}}); err != nil {
+		t.Fatalf("Failed to create remote: %v", err)
+	}
+	c, err := repo.CommitObject(hash)
+	if err != nil {
+		t.Fatalf("Failed to get commit: %v", err)
+	}
+
+	var prompt string
+	model := &mockModel{response: `{"probability": "HIGH", "reasoning": "mock"}`}
+	results, err := RunPipeline(context.Background(), repo, []*object.Commit{c}, c, model, AnalysisOptions{
+		ErrorMessage: "sessions expire early",
+		Issues:       issues.Config{GitHubToken: "token", GitHubAPI: srv.URL},
+		CI:           ci.Config{GitHubToken: "token", GitHubAPI: srv.URL},
+		OnExtracted: func(i int, dc *CommitDiffContext, err error) {
+			prompt = BuildPromptFromContext("sessions expire early", dc)
+		},
+	})
+	if err != nil {
+		t.Fatalf("RunPipeline failed: %v", err)
+	}
+	if strings.Contains(prompt, "Sessions expire after") {
+		t.Error("Expected the title looked up after extraction")
+	}
+	meta := results[0].Result.Metadata
+	if meta == nil || meta.IssueTitles["#12"] != "Sessions expire after 5 minutes" {
+		t.Errorf("Expected the title of #12 in the metadata, got %+v", meta)
+	}
+	if !strings.Contains(meta.format(), "References: #12 (Sessions expire after 5 minutes)") {
+		t.Errorf("Expected the title in COMMIT CONTEXT, got %q", meta.format())
+	}
+	if meta.CI == nil || meta.CI.State != ci.StateFailure {
+		t.Errorf("Expected the failed CI status in the metadata, got %+v", meta.CI)
+	}
+	if !strings.Contains(meta.format(), "CI status: failure (test)\n") {
+		t.Errorf("Expected the CI status in COMMIT CONTEXT, got %q", meta.format())
+	}
+}
+
+// inFlightModel records the most calls made to it at once
+type inFlightModel struct {
+	mockModel
+	inFlight, most int
+}
+
+func (m *inFlightModel) GenerateContent(ctx context.Context, parts ...genai.Part) (*genai.GenerateContentResponse, error) {
+	m.mu.Lock()
+	m.inFlight++
+	m.most = max(m.most, m.inFlight)
+	m.mu.Unlock()
+	time.Sleep(10 * time.Millisecond)
+	m.mu.Lock()
+	m.inFlight--
+	m.mu.Unlock()
+	return m.mockModel.GenerateContent(ctx, parts...)
+}
+
+func TestRunPipelineSharedSlots(t *testing.T) {
+	model := &inFlightModel{mockModel: mockModel{response: `{"probability": "LOW", "reasoning": "mock"}`}}
+	slots := make(chan struct{}, 2)
+
+	// Two runs analyzed together share the slots' two calls at a time
+	var wg sync.WaitGroup
+	for range 2 {
+		repo := createTestRepo(t, []struct{ path, content string }{
+			{"a.go", "package main\n\nfunc a() {}\n"},
+			{"b.go", "package main\n\nfunc b() {}\n"},
+			{"c.go", "package main\n\nfunc c() {}\n"},
+		})
+		commits, head, err := CollectCommits(repo, AnalysisOptions{NumCommits: 3})
+		if err != nil {
+			t.Fatalf("CollectCommits failed: %v", err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := RunPipeline(context.Background(), repo, commits, head, model, AnalysisOptions{
+				ErrorMessage: "test error",
+				Workers:      3,
+				Slots:        slots,
+			}); err != nil {
+				t.Errorf("RunPipeline failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if model.calls != 6 {
+		t.Errorf("Expected every commit of both runs analyzed, got %d calls", model.calls)
+	}
+	if model.most > 2 {
+		t.Errorf("Expected at most 2 calls at once across the runs, got %d", model.most)
+	}
+}
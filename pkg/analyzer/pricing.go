@@ -0,0 +1,78 @@
+package analyzer
+
+import "strings"
+
+// ModelPricing is the list price of a model in USD per million tokens
+type ModelPricing struct {
+	InputPerMTok  float64 `json:"input_per_mtok"`
+	OutputPerMTok float64 `json:"output_per_mtok"`
+}
+
+// knownPricing holds published Gemini list prices (standard tier, prompts
+// up to 200k tokens). Keys are matched as prefixes of the model name, so
+// the longest key must win.
+var knownPricing = map[string]ModelPricing{
+	"gemini-2.5-pro":        {InputPerMTok: 1.25, OutputPerMTok: 10.00},
+	"gemini-2.5-flash":      {InputPerMTok: 0.30, OutputPerMTok: 2.50},
+	"gemini-2.5-flash-lite": {InputPerMTok: 0.10, OutputPerMTok: 0.40},
+	"gemini-2.0-flash":      {InputPerMTok: 0.10, OutputPerMTok: 0.40},
+	"gemini-2.0-flash-lite": {InputPerMTok: 0.075, OutputPerMTok: 0.30},
+	"gemini-1.5-pro":        {InputPerMTok: 1.25, OutputPerMTok: 5.00},
+	"gemini-1.5-flash":      {InputPerMTok: 0.075, OutputPerMTok: 0.30},
+}
+
+// LookupPricing returns the known list price for a model name, with or
+// without the "models/" prefix. Version suffixes such as "-001" or
+// "-preview-05-20" match their base model.
+func LookupPricing(model string) (ModelPricing, bool) {
+	return lookupModel(knownPricing, model)
+}
+
+// knownInputLimits holds published Gemini input token limits, keyed like
+// knownPricing
+var knownInputLimits = map[string]int{
+	"gemini-2.5-pro":        1048576,
+	"gemini-2.5-flash":      1048576,
+	"gemini-2.5-flash-lite": 1048576,
+	"gemini-2.0-flash":      1048576,
+	"gemini-2.0-flash-lite": 1048576,
+	"gemini-1.5-pro":        2097152,
+	"gemini-1.5-flash":      1048576,
+}
+
+// LookupInputTokenLimit returns the known input token limit for a model
+// name, matched like LookupPricing
+func LookupInputTokenLimit(model string) (int, bool) {
+	return lookupModel(knownInputLimits, model)
+}
+
+// DiffTokenBudget returns the token budget for each of a commit's two
+// diffs: maxTokens, capped for models with a known input limit so that
+// both diffs together use at most half of the context window
+func DiffTokenBudget(model string, maxTokens int) int {
+	if limit, ok := LookupInputTokenLimit(model); ok && maxTokens > limit/4 {
+		return limit / 4
+	}
+	return maxTokens
+}
+
+// lookupModel finds the longest key of table that prefixes model
+func lookupModel[V any](table map[string]V, model string) (V, bool) {
+	model = strings.TrimPrefix(model, "models/")
+	best := ""
+	for name := range table {
+		if strings.HasPrefix(model, name) && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		var zero V
+		return zero, false
+	}
+	return table[best], true
+}
+
+// Cost returns the price in USD of the given token counts
+func (p ModelPricing) Cost(promptTokens, outputTokens int) float64 {
+	return float64(promptTokens)/1e6*p.InputPerMTok + float64(outputTokens)/1e6*p.OutputPerMTok
+}
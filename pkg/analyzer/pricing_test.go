@@ -0,0 +1,56 @@
+package analyzer
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLookupPricing(t *testing.T) {
+	tests := []struct {
+		model string
+		want  ModelPricing
+		found bool
+	}{
+		{"gemini-2.5-flash", ModelPricing{0.30, 2.50}, true},
+		{"models/gemini-2.5-flash-lite", ModelPricing{0.10, 0.40}, true},
+		{"gemini-1.5-pro-002", ModelPricing{1.25, 5.00}, true},
+		{"gemini-flash-latest", ModelPricing{}, false},
+		{"text-embedding-004", ModelPricing{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			got, ok := LookupPricing(tt.model)
+			if ok != tt.found || got != tt.want {
+				t.Errorf("LookupPricing(%q) = %+v, %v; expected %+v, %v", tt.model, got, ok, tt.want, tt.found)
+			}
+		})
+	}
+}
+
+func TestModelPricingCost(t *testing.T) {
+	p := ModelPricing{InputPerMTok: 1.25, OutputPerMTok: 10}
+	got := p.Cost(2_000_000, 100_000)
+	if math.Abs(got-3.5) > 1e-9 {
+		t.Errorf("Expected cost 3.5, got %f", got)
+	}
+}
+
+func TestDiffTokenBudget(t *testing.T) {
+	tests := []struct {
+		model     string
+		maxTokens int
+		want      int
+	}{
+		{"gemini-2.5-flash", 12500, 12500},
+		{"gemini-2.5-flash", 1_000_000, 262144},
+		{"models/gemini-1.5-pro-002", 1_000_000, 524288},
+		{"gemini-flash-latest", 1_000_000, 1_000_000},
+	}
+
+	for _, tt := range tests {
+		if got := DiffTokenBudget(tt.model, tt.maxTokens); got != tt.want {
+			t.Errorf("DiffTokenBudget(%q, %d): expected %d, got %d", tt.model, tt.maxTokens, tt.want, got)
+		}
+	}
+}
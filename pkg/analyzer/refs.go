@@ -0,0 +1,58 @@
+package analyzer
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// ResolveCommit resolves ref to a commit: a branch, a tag (annotated tags
+// are peeled), a remote-tracking ref such as origin/main, a full ref name,
+// or a full or abbreviated commit hash. Names are looked up in git's
+// order, so a tag wins over a branch of the same name. An empty ref means
+// HEAD, which may be detached, as in CI checkouts.
+func ResolveCommit(repo *git.Repository, ref string) (*object.Commit, error) {
+	if ref == "" {
+		head, err := repo.Head()
+		if errors.Is(err, plumbing.ErrReferenceNotFound) {
+			// Bare mirrors may keep HEAD on a branch that no longer exists
+			if sym, symErr := repo.Reference(plumbing.HEAD, false); symErr == nil && sym.Type() == plumbing.SymbolicReference {
+				return nil, fmt.Errorf("HEAD points to %s, which does not exist; pass a branch, tag, or commit to analyze", sym.Target())
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get HEAD: %w", err)
+		}
+		commit, err := repo.CommitObject(head.Hash())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get HEAD commit: %w", err)
+		}
+		return commit, nil
+	}
+
+	hash, err := repo.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", ref, err)
+	}
+	commit, err := repo.CommitObject(*hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get commit %s: %w", ref, err)
+	}
+	return commit, nil
+}
+
+// OpenRepository opens a repository for read-only analysis: a working
+// tree (from its root or any directory below it), a linked worktree added
+// with git worktree add, or a bare repository or mirror
+func OpenRepository(path string) (*git.Repository, error) {
+	// Searching parent directories for .git would miss bare repositories,
+	// so it is only a fallback
+	repo, err := git.PlainOpenWithOptions(path, &git.PlainOpenOptions{EnableDotGitCommonDir: true})
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		repo, err = git.PlainOpenWithOptions(path, &git.PlainOpenOptions{DetectDotGit: true, EnableDotGitCommonDir: true})
+	}
+	return repo, err
+}
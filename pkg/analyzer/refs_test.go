@@ -0,0 +1,165 @@
+package analyzer
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestResolveCommit(t *testing.T) {
+	repo := createTestRepo(t, []struct{ path, content string }{
+		{"main.go", "package main\n"},
+		{"main.go", "package main\n\nfunc main() {}\n"},
+		{"main.go", "package main\n\nfunc main() { run() }\n"},
+	})
+	headRef, err := repo.Head()
+	if err != nil {
+		t.Fatalf("Failed to get HEAD: %v", err)
+	}
+	head, err := repo.CommitObject(headRef.Hash())
+	if err != nil {
+		t.Fatalf("Failed to get HEAD commit: %v", err)
+	}
+	release, err := head.Parent(0)
+	if err != nil {
+		t.Fatalf("Failed to get parent: %v", err)
+	}
+
+	tagger := &object.Signature{Name: "Test User", Email: "test@example.com", When: time.Now()}
+	if _, err := repo.CreateTag("v1.0.0", release.Hash, &git.CreateTagOptions{Message: "Release", Tagger: tagger}); err != nil {
+		t.Fatalf("Failed to create tag: %v", err)
+	}
+	if _, err := repo.CreateTag("deployed", release.Hash, nil); err != nil {
+		t.Fatalf("Failed to create tag: %v", err)
+	}
+	if err := repo.Storer.SetReference(plumbing.NewHashReference("refs/remotes/origin/main", release.Hash)); err != nil {
+		t.Fatalf("Failed to create remote ref: %v", err)
+	}
+
+	tests := []struct {
+		ref      string
+		expected plumbing.Hash
+		wantErr  bool
+	}{
+		{"", head.Hash, false},
+		{"master", head.Hash, false},
+		{"v1.0.0", release.Hash, false},
+		{"deployed", release.Hash, false},
+		{"refs/tags/v1.0.0", release.Hash, false},
+		{"origin/main", release.Hash, false},
+		{release.Hash.String(), release.Hash, false},
+		{release.Hash.String()[:7], release.Hash, false},
+		{"missing", plumbing.ZeroHash, true},
+	}
+	for _, tt := range tests {
+		got, err := ResolveCommit(repo, tt.ref)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ResolveCommit(%q): expected error %v, got %v", tt.ref, tt.wantErr, err)
+			continue
+		}
+		if err == nil && got.Hash != tt.expected {
+			t.Errorf("ResolveCommit(%q): expected %s, got %s", tt.ref, tt.expected, got.Hash)
+		}
+	}
+
+	// History starts at the tag while diffs compare against HEAD
+	commits, compared, err := CollectCommits(repo, AnalysisOptions{NumCommits: 5, Branch: "v1.0.0", HeadRef: head.Hash.String()[:8]})
+	if err != nil {
+		t.Fatalf("CollectCommits failed: %v", err)
+	}
+	if len(commits) != 2 || commits[0].Hash != release.Hash {
+		t.Errorf("Expected the 2 commits up to the tag, got %d starting at %s", len(commits), commits[0].Hash)
+	}
+	if compared.Hash != head.Hash {
+		t.Errorf("Expected comparison against HEAD %s, got %s", head.Hash, compared.Hash)
+	}
+}
+
+func TestOpenRepository(t *testing.T) {
+	repo := createTestRepo(t, []struct{ path, content string }{
+		{"main.go", "package main\n"},
+		{"sub/util.go", "package sub\n"},
+	})
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("Failed to get worktree: %v", err)
+	}
+	dir := w.Filesystem.Root()
+	headRef, err := repo.Head()
+	if err != nil {
+		t.Fatalf("Failed to get HEAD: %v", err)
+	}
+	head, err := repo.CommitObject(headRef.Hash())
+	if err != nil {
+		t.Fatalf("Failed to get HEAD commit: %v", err)
+	}
+	first, err := head.Parent(0)
+	if err != nil {
+		t.Fatalf("Failed to get parent: %v", err)
+	}
+
+	bareDir := filepath.Join(t.TempDir(), "mirror.git")
+	if _, err := git.PlainClone(bareDir, true, &git.CloneOptions{URL: dir}); err != nil {
+		t.Fatalf("Failed to clone bare repo: %v", err)
+	}
+
+	// Detach HEAD the way CI checkouts do
+	if err := w.Checkout(&git.CheckoutOptions{Hash: first.Hash}); err != nil {
+		t.Fatalf("Failed to detach HEAD: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		expected plumbing.Hash
+	}{
+		{"detached HEAD", dir, first.Hash},
+		{"subdirectory", filepath.Join(dir, "sub"), first.Hash},
+		{"bare", bareDir, head.Hash},
+	}
+	for _, tt := range tests {
+		r, err := OpenRepository(tt.path)
+		if err != nil {
+			t.Errorf("%s: failed to open: %v", tt.name, err)
+			continue
+		}
+		got, err := ResolveCommit(r, "")
+		if err != nil {
+			t.Errorf("%s: failed to resolve HEAD: %v", tt.name, err)
+			continue
+		}
+		if got.Hash != tt.expected {
+			t.Errorf("%s: expected HEAD %s, got %s", tt.name, tt.expected, got.Hash)
+		}
+	}
+
+	if _, err := OpenRepository(t.TempDir()); err == nil {
+		t.Errorf("Expected error opening a directory outside any repository")
+	}
+}
+
+func TestResolveCommit_DanglingHEAD(t *testing.T) {
+	repo := createTestRepo(t, []struct{ path, content string }{
+		{"main.go", "package main\n"},
+	})
+	// Mirrors can keep HEAD on a branch that was since deleted upstream
+	if err := repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, "refs/heads/trunk")); err != nil {
+		t.Fatalf("Failed to point HEAD at a missing branch: %v", err)
+	}
+
+	_, err := ResolveCommit(repo, "")
+	if err == nil {
+		t.Fatal("Expected error for HEAD pointing at a missing branch")
+	}
+	if !strings.Contains(err.Error(), "refs/heads/trunk") {
+		t.Errorf("Expected error to name refs/heads/trunk, got %v", err)
+	}
+	if _, err := ResolveCommit(repo, "master"); err != nil {
+		t.Errorf("Expected an explicit branch to still resolve, got %v", err)
+	}
+}
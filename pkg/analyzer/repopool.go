@@ -0,0 +1,196 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+
+	"github.com/kerneldump/git-dual-context/pkg/gitdiff"
+)
+
+// RepoPool lends out independent handles on one repository, so that the
+// diffs of several commits can be extracted at once although a go-git
+// repository is not safe for concurrent use. The handles share one object
+// cache, so a tree or blob decompressed for one commit's diff is reused by
+// the others. A repository not stored on disk is lent out itself, to one
+// caller at a time.
+type RepoPool struct {
+	handles chan *git.Repository
+	objects cache.Object // shared by the handles; nil for a lent-out repo
+}
+
+// NewRepoPool opens n handles on the repository stored where repo is,
+// leaving repo itself free for the caller. Their shared object cache holds
+// up to cacheMB megabytes of decompressed objects (0: go-git's default of
+// 96).
+func NewRepoPool(repo *git.Repository, n, cacheMB int) (*RepoPool, error) {
+	n = max(n, 1)
+	p := &RepoPool{handles: make(chan *git.Repository, n)}
+	st, ok := repo.Storer.(*filesystem.Storage)
+	if !ok {
+		p.handles <- repo
+		return p, nil
+	}
+	size := cache.DefaultMaxSize
+	if cacheMB > 0 {
+		size = cache.FileSize(cacheMB) * cache.MiByte
+	}
+	p.objects = cache.NewObjectLRU(size)
+	for range n {
+		handle, err := git.Open(filesystem.NewStorage(st.Filesystem(), p.objects), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open repository handle: %w", err)
+		}
+		p.handles <- handle
+	}
+	return p, nil
+}
+
+// Preload reads the commits, their first parents, and the trees of
+// headCommit into the shared cache once, before their diffs are extracted.
+// Every commit is diffed against headCommit, so its trees would otherwise
+// be decompressed again by each handle that misses them.
+func (p *RepoPool) Preload(ctx context.Context, commits []*object.Commit, headCommit *object.Commit) error {
+	var handle *git.Repository
+	select {
+	case handle = <-p.handles:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { p.handles <- handle }()
+
+	head, err := handle.CommitObject(headCommit.Hash)
+	if err != nil {
+		return fmt.Errorf("loading commit %s: %w", headCommit.Hash.String()[:8], err)
+	}
+	if err := preloadTree(ctx, handle, head.TreeHash); err != nil {
+		return err
+	}
+	for _, c := range commits {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		commit, err := handle.CommitObject(c.Hash)
+		if err != nil {
+			return fmt.Errorf("loading commit %s: %w", c.Hash.String()[:8], err)
+		}
+		if _, err := commit.Tree(); err != nil {
+			return fmt.Errorf("loading tree of %s: %w", c.Hash.String()[:8], err)
+		}
+		if commit.NumParents() == 0 {
+			continue
+		}
+		// A parent beyond a shallow clone's boundary is not there to load
+		parent, err := commit.Parent(0)
+		if errors.Is(err, plumbing.ErrObjectNotFound) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("loading parent of %s: %w", c.Hash.String()[:8], err)
+		}
+		if _, err := parent.Tree(); err != nil {
+			return fmt.Errorf("loading tree of %s: %w", parent.Hash.String()[:8], err)
+		}
+	}
+	return nil
+}
+
+// preloadTree reads the tree with hash and its subtrees, but not their
+// blobs, which only the commits changing them need
+func preloadTree(ctx context.Context, repo *git.Repository, hash plumbing.Hash) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	tree, err := repo.TreeObject(hash)
+	if err != nil {
+		return fmt.Errorf("loading tree %s: %w", hash.String()[:8], err)
+	}
+	for _, entry := range tree.Entries {
+		if entry.Mode != filemode.Dir {
+			continue
+		}
+		if err := preloadTree(ctx, repo, entry.Hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExtractDiffs is ExtractDiffsContext run on a handle of its own, waiting
+// for one to be free. Commits from any handle, or from the repository the
+// pool was opened on, are accepted; the result's Commit comes from the
+// handle used.
+func (p *RepoPool) ExtractDiffs(ctx context.Context, c, headCommit *object.Commit, opts gitdiff.Options) (*CommitDiffContext, error) {
+	return p.extract(ctx, c, headCommit, opts, nil)
+}
+
+// extract implements ExtractDiffs, calling started, if not nil, once a
+// handle is free
+func (p *RepoPool) extract(ctx context.Context, c, headCommit *object.Commit, opts gitdiff.Options, started func()) (*CommitDiffContext, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var handle *git.Repository
+	select {
+	case handle = <-p.handles:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { p.handles <- handle }()
+	if started != nil {
+		started()
+	}
+
+	commit, err := handle.CommitObject(c.Hash)
+	if err != nil {
+		return nil, withKind(ErrorKindGit, fmt.Errorf("loading commit %s: %w", c.Hash.String()[:8], err))
+	}
+	head, err := handle.CommitObject(headCommit.Hash)
+	if err != nil {
+		return nil, withKind(ErrorKindGit, fmt.Errorf("loading commit %s: %w", headCommit.Hash.String()[:8], err))
+	}
+	return ExtractDiffsContext(ctx, handle, commit, head, opts)
+}
+
+// ExtractAll preloads commits and extracts their diffs against headCommit
+// on all handles at once. Each commit's diff context, or the error
+// extracting it, is at its index. progress, if not nil, is called as each
+// commit's extraction starts.
+func (p *RepoPool) ExtractAll(ctx context.Context, commits []*object.Commit, headCommit *object.Commit, opts gitdiff.Options, progress func(i int, c *object.Commit)) ([]*CommitDiffContext, []error) {
+	diffContexts := make([]*CommitDiffContext, len(commits))
+	errs := make([]error, len(commits))
+	p.extractEach(ctx, commits, headCommit, opts, progress, func(i int, dc *CommitDiffContext, err error) {
+		diffContexts[i], errs[i] = dc, err
+	})
+	return diffContexts, errs
+}
+
+// extractEach implements ExtractAll, calling done concurrently with each
+// commit's diff context or error as its extraction ends
+func (p *RepoPool) extractEach(ctx context.Context, commits []*object.Commit, headCommit *object.Commit, opts gitdiff.Options, progress func(i int, c *object.Commit), done func(i int, dc *CommitDiffContext, err error)) {
+	// Preloading only saves work; a commit it fails on fails extraction
+	// with the same error below
+	_ = p.Preload(ctx, commits, headCommit)
+	var wg sync.WaitGroup
+	for i, c := range commits {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var started func()
+			if progress != nil {
+				started = func() { progress(i, c) }
+			}
+			dc, err := p.extract(ctx, c, headCommit, opts, started)
+			done(i, dc, err)
+		}()
+	}
+	wg.Wait()
+}
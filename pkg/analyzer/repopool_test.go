@@ -0,0 +1,110 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+
+	"github.com/kerneldump/git-dual-context/pkg/gitdiff"
+)
+
+func TestRepoPoolExtractAll(t *testing.T) {
+	var files []struct{ path, content string }
+	for i := range 8 {
+		files = append(files, struct{ path, content string }{
+			fmt.Sprintf("pkg%d/file.go", i%3),
+			fmt.Sprintf("package pkg\n\nfunc F() int { return %d }\n", i),
+		})
+	}
+	repo := createTestRepo(t, files)
+	commits, head, err := CollectCommits(repo, AnalysisOptions{NumCommits: 8})
+	if err != nil {
+		t.Fatalf("CollectCommits failed: %v", err)
+	}
+
+	pool, err := NewRepoPool(repo, 4, 0)
+	if err != nil {
+		t.Fatalf("NewRepoPool failed: %v", err)
+	}
+	started := make(chan int, len(commits))
+	diffContexts, errs := pool.ExtractAll(context.Background(), commits, head, gitdiff.Options{}, func(i int, c *object.Commit) {
+		started <- i
+	})
+	if len(started) != len(commits) {
+		t.Errorf("Expected progress for %d commits, got %d", len(commits), len(started))
+	}
+
+	for i, c := range commits {
+		if errs[i] != nil {
+			t.Errorf("Commit %d: extraction failed: %v", i, errs[i])
+			continue
+		}
+		want, err := ExtractDiffs(repo, c, head)
+		if err != nil {
+			t.Fatalf("ExtractDiffs failed: %v", err)
+		}
+		got := diffContexts[i]
+		if got.Commit.Hash != c.Hash {
+			t.Errorf("Commit %d: expected %s, got %s", i, c.Hash, got.Commit.Hash)
+		}
+		if got.StandardDiff != want.StandardDiff || got.FullDiff != want.FullDiff {
+			t.Errorf("Commit %d: parallel diffs differ from sequential ones", i)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, errs := pool.ExtractAll(ctx, commits[:1], head, gitdiff.Options{}, nil); errs[0] == nil {
+		t.Error("Expected error extracting with a cancelled context")
+	}
+}
+
+func TestRepoPoolPreload(t *testing.T) {
+	repo := createTestRepo(t, []struct{ path, content string }{
+		{"cmd/main.go", "package main\n"},
+		{"pkg/util/util.go", "package util\n"},
+		{"cmd/main.go", "package main\n\nfunc main() {}\n"},
+	})
+	commits, head, err := CollectCommits(repo, AnalysisOptions{NumCommits: 3})
+	if err != nil {
+		t.Fatalf("CollectCommits failed: %v", err)
+	}
+
+	pool, err := NewRepoPool(repo, 2, 1)
+	if err != nil {
+		t.Fatalf("NewRepoPool failed: %v", err)
+	}
+	if err := pool.Preload(context.Background(), commits, head); err != nil {
+		t.Fatalf("Preload failed: %v", err)
+	}
+
+	headTree, err := head.Tree()
+	if err != nil {
+		t.Fatalf("Failed to get HEAD tree: %v", err)
+	}
+	want := []plumbing.Hash{headTree.Hash}
+	for _, dir := range []string{"cmd", "pkg", "pkg/util"} {
+		entry, err := headTree.FindEntry(dir)
+		if err != nil {
+			t.Fatalf("Failed to find %s: %v", dir, err)
+		}
+		want = append(want, entry.Hash)
+	}
+	for _, c := range commits {
+		want = append(want, c.Hash, c.TreeHash)
+	}
+	for _, h := range want {
+		if _, ok := pool.objects.Get(h); !ok {
+			t.Errorf("Expected %s to be cached after preloading", h)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := pool.Preload(ctx, commits, head); err == nil {
+		t.Error("Expected error preloading with a cancelled context")
+	}
+}
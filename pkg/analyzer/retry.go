@@ -7,6 +7,8 @@ import (
 	"strings"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/api/googleapi"
 )
 
@@ -66,15 +68,55 @@ func IsRetryable(err error) bool {
 	return false
 }
 
-// WithRetry executes a function with exponential backoff
+// RetryStats records the attempts of WithRetryStats, to tell whether a
+// slow commit was throttled
+type RetryStats struct {
+	// Attempts is how many times the function was called
+	Attempts int `json:"attempts"`
+
+	// BackoffMS is the time spent waiting between attempts, in milliseconds
+	BackoffMS int64 `json:"backoff_ms"`
+
+	// Errors lists the errors of the failed attempts, in order
+	Errors []RetryError `json:"errors,omitempty"`
+}
+
+// retried returns stats if the call took more than one attempt, or nil
+func (s RetryStats) retried() *RetryStats {
+	if s.Attempts > 1 {
+		return &s
+	}
+	return nil
+}
+
+// RetryError is the error of one failed attempt
+type RetryError struct {
+	Kind    string `json:"kind"` // see ErrorKind
+	Message string `json:"message"`
+}
+
+// WithRetry executes a function with exponential backoff.
+// Each retry is recorded as an event on the span in ctx, if any.
 func WithRetry(ctx context.Context, cfg RetryConfig, fn func() error) error {
+	return WithRetryStats(ctx, cfg, nil, fn)
+}
+
+// WithRetryStats is WithRetry recording its attempts, the time spent
+// backing off, and the errors of failed attempts in stats, if not nil
+func WithRetryStats(ctx context.Context, cfg RetryConfig, stats *RetryStats, fn func() error) error {
 	var lastErr error
+	span := trace.SpanFromContext(ctx)
+	if stats == nil {
+		stats = &RetryStats{}
+	}
 
 	for attempt := 0; attempt <= cfg.MaxRetries; attempt++ {
+		stats.Attempts++
 		lastErr = fn()
 		if lastErr == nil {
 			return nil
 		}
+		stats.Errors = append(stats.Errors, RetryError{Kind: ErrorKind(lastErr), Message: lastErr.Error()})
 
 		if !IsRetryable(lastErr) {
 			return lastErr
@@ -90,12 +132,21 @@ func WithRetry(ctx context.Context, cfg RetryConfig, fn func() error) error {
 			delay = cfg.MaxDelay
 		}
 
+		span.AddEvent("retry", trace.WithAttributes(
+			attribute.Int("retry.attempt", attempt+1),
+			attribute.String("retry.delay", delay.String()),
+			attribute.String("retry.error", lastErr.Error()),
+		))
+
+		start := time.Now()
 		select {
 		case <-ctx.Done():
+			stats.BackoffMS += time.Since(start).Milliseconds()
 			return ctx.Err()
 		case <-time.After(delay):
 			// Continue to next attempt
 		}
+		stats.BackoffMS += time.Since(start).Milliseconds()
 	}
 
 	return fmt.Errorf("max retries (%d) exceeded: %w", cfg.MaxRetries, lastErr)
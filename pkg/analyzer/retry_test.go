@@ -313,3 +313,46 @@ func TestDefaultRetryConfig(t *testing.T) {
 		t.Errorf("Expected MaxDelay=30s, got %v", cfg.MaxDelay)
 	}
 }
+
+func TestWithRetryStats(t *testing.T) {
+	cfg := RetryConfig{
+		MaxRetries: 3,
+		BaseDelay:  10 * time.Millisecond,
+		MaxDelay:   100 * time.Millisecond,
+	}
+
+	callCount := 0
+	var stats RetryStats
+	err := WithRetryStats(context.Background(), cfg, &stats, func() error {
+		callCount++
+		if callCount < 3 {
+			return &googleapi.Error{Code: 429, Message: "quota"}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WithRetryStats() returned error: %v", err)
+	}
+	if stats.Attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", stats.Attempts)
+	}
+	// Backoff of 10ms, then 20ms
+	if stats.BackoffMS < 30 {
+		t.Errorf("Expected at least 30ms of backoff, got %dms", stats.BackoffMS)
+	}
+	if len(stats.Errors) != 2 || stats.Errors[0].Kind != ErrorKindRateLimited {
+		t.Errorf("Expected 2 rate limit errors, got %+v", stats.Errors)
+	}
+
+	res := &AnalysisResult{}
+	res.RecordRetries(RetryStats{Attempts: 1})
+	if res.Retries != nil {
+		t.Error("Expected no retries recorded for a single attempt")
+	}
+	res.RecordRetries(stats)
+	if jr := res.ToJSONResult("abc", "msg"); jr.Retries == nil || jr.Retries.Attempts != 3 {
+		t.Errorf("Expected the retries in the JSON result, got %+v", jr.Retries)
+	}
+	var none *AnalysisResult
+	none.RecordRetries(stats)
+}
@@ -0,0 +1,154 @@
+package analyzer
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SchemaVersion is the version of the result, summary, and log records,
+// emitted as schema_version. It is bumped when a field is removed or
+// changes meaning; added fields keep the version. Version 2 emits result
+// records for skipped and failed commits too, without a probability.
+const SchemaVersion = 2
+
+// JSONSchemaURI is the JSON Schema dialect of RecordSchema
+const JSONSchemaURI = "https://json-schema.org/draft/2020-12/schema"
+
+// Record types, the "type" field of each NDJSON record
+const (
+	RecordResult  = "result"
+	RecordSummary = "summary"
+	RecordLog     = "log"
+)
+
+// RecordTypes lists the record types RecordSchema describes
+var RecordTypes = []string{RecordResult, RecordSummary, RecordLog}
+
+var recordGoTypes = map[string]reflect.Type{
+	RecordResult:  reflect.TypeFor[JSONResult](),
+	RecordSummary: reflect.TypeFor[Summary](),
+	RecordLog:     reflect.TypeFor[LogEntry](),
+}
+
+// RecordSchema returns the JSON Schema of the records of recordType (one
+// of RecordTypes), derived from the Go types that encode them. An empty
+// recordType returns a schema matching any of them.
+func RecordSchema(recordType string) (map[string]any, error) {
+	if recordType == "" {
+		defs := map[string]any{}
+		var refs []any
+		for _, rt := range RecordTypes {
+			defs[rt] = recordSchema(rt)
+			refs = append(refs, map[string]any{"$ref": "#/$defs/" + rt})
+		}
+		return map[string]any{
+			"$schema": JSONSchemaURI,
+			"title":   "git-dual-context record",
+			"oneOf":   refs,
+			"$defs":   defs,
+		}, nil
+	}
+	if _, ok := recordGoTypes[recordType]; !ok {
+		return nil, fmt.Errorf("unknown record type %q (want %s)", recordType, strings.Join(RecordTypes, ", "))
+	}
+	s := recordSchema(recordType)
+	s["$schema"] = JSONSchemaURI
+	return s, nil
+}
+
+// recordSchema describes one record type, pinning its type and version
+func recordSchema(recordType string) map[string]any {
+	s := typeSchema(recordGoTypes[recordType])
+	s["title"] = "git-dual-context " + recordType + " record"
+	props := s["properties"].(map[string]any)
+	props["type"] = map[string]any{"const": recordType}
+	props["schema_version"] = map[string]any{"type": "integer", "const": SchemaVersion}
+	return s
+}
+
+var (
+	timeType        = reflect.TypeFor[time.Time]()
+	probabilityType = reflect.TypeFor[Probability]()
+)
+
+// typeSchema describes how encoding/json encodes values of t
+func typeSchema(t reflect.Type) map[string]any {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == probabilityType:
+		return map[string]any{"type": "string", "enum": []any{ProbHigh, ProbMedium, ProbLow}}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice:
+		// nil slices and maps encode as null
+		return map[string]any{"type": []any{"array", "null"}, "items": typeSchema(t.Elem())}
+	case reflect.Array:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": []any{"object", "null"}, "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Struct:
+		props := map[string]any{}
+		var required []string
+		addFields(t, props, &required, false)
+		sort.Strings(required)
+		s := map[string]any{"type": "object", "properties": props}
+		if len(required) > 0 {
+			s["required"] = required
+		}
+		return s
+	default:
+		return map[string]any{}
+	}
+}
+
+// addFields adds the JSON fields of struct t, including those of embedded
+// structs, to props; fields always present are added to required unless
+// optional is set
+func addFields(t reflect.Type, props map[string]any, required *[]string, optional bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				addFields(ft, props, required, optional || f.Type.Kind() == reflect.Pointer)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = typeSchema(f.Type)
+		omitted := strings.Contains(opts, "omitempty") || strings.Contains(opts, "omitzero") || f.Type.Kind() == reflect.Pointer
+		if !optional && !omitted {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/kerneldump/git-dual-context/pkg/gitdiff"
+)
+
+// schemaProperties returns the property names of a record schema
+func schemaProperties(t *testing.T, recordType string) map[string]any {
+	t.Helper()
+	s, err := RecordSchema(recordType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s["properties"].(map[string]any)
+}
+
+// encodedFields returns the top-level field names v encodes to
+func encodedFields(t *testing.T, v any) map[string]any {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestRecordSchemaCoversEncodedFields(t *testing.T) {
+	ar := &AnalysisResult{
+		Probability: ProbHigh,
+		Reasoning:   "r",
+		Stats:       &gitdiff.DiffStats{Files: []gitdiff.FileStat{{Path: "a.go"}}},
+		Heuristics:  &Heuristics{Score: 0.5},
+		Suspicion:   0.7,
+		DuplicateOf: "0123456789",
+		Retries:     &RetryStats{Attempts: 2},
+		Metadata:    &CommitMetadata{Author: "a <a@b>", Date: time.Now(), Issues: []string{"#1"}, ChangedFiles: 1},
+	}
+	jr := ar.ToJSONResult("01234567", "msg")
+	jr.Repo = "svc"
+
+	entry := NewErrorEntry("failed", "01234567", &ParseError{Commit: "01234567", err: errors.New("bad")}, "")
+	entry.DebugFile = "/tmp/x"
+
+	summary := Summary{Type: RecordSummary, SchemaVersion: SchemaVersion, OverBudget: 1, Partial: true,
+		ErrorKinds: map[string]int{ErrorKindOther: 1}, Ranking: []string{"01234567"}}
+
+	for recordType, v := range map[string]any{RecordResult: jr, RecordLog: entry, RecordSummary: summary} {
+		props := schemaProperties(t, recordType)
+		for field := range encodedFields(t, v) {
+			if _, ok := props[field]; !ok {
+				t.Errorf("%s schema missing field %q", recordType, field)
+			}
+		}
+	}
+}
+
+func TestRecordSchemaPinsTypeAndVersion(t *testing.T) {
+	for _, recordType := range RecordTypes {
+		s, err := RecordSchema(recordType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		props := s["properties"].(map[string]any)
+		if c := props["type"].(map[string]any)["const"]; c != recordType {
+			t.Errorf("%s: type const = %v", recordType, c)
+		}
+		if c := props["schema_version"].(map[string]any)["const"]; c != SchemaVersion {
+			t.Errorf("%s: schema_version const = %v", recordType, c)
+		}
+		if !slices.Contains(s["required"].([]string), "schema_version") {
+			t.Errorf("%s: schema_version not required", recordType)
+		}
+	}
+}
+
+func TestRecordSchemaOptionalFields(t *testing.T) {
+	s, err := RecordSchema(RecordResult)
+	if err != nil {
+		t.Fatal(err)
+	}
+	required := s["required"].([]string)
+	for _, field := range []string{"hash", "status"} {
+		if !slices.Contains(required, field) {
+			t.Errorf("expected %q required", field)
+		}
+	}
+	// Fields of the embedded *CommitMetadata, and the verdict of skipped
+	// and failed commits, may be absent
+	for _, field := range []string{"repo", "stats", "author", "changed_files", "probability", "reasoning"} {
+		if slices.Contains(required, field) {
+			t.Errorf("expected %q optional", field)
+		}
+	}
+}
+
+func TestRecordSchemaAny(t *testing.T) {
+	s, err := RecordSchema("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s["oneOf"].([]any)) != len(RecordTypes) || len(s["$defs"].(map[string]any)) != len(RecordTypes) {
+		t.Errorf("expected one definition per record type: %v", s)
+	}
+	if _, err := RecordSchema("check"); err == nil {
+		t.Error("expected an error for an unknown record type")
+	}
+}
+
+func TestRecordsCarrySchemaVersion(t *testing.T) {
+	if v := NewLogEntry("INFO", "m").SchemaVersion; v != SchemaVersion {
+		t.Errorf("log schema_version = %d", v)
+	}
+	ar := &AnalysisResult{Probability: ProbLow}
+	if v := ar.ToJSONResult("01234567", "m").SchemaVersion; v != SchemaVersion {
+		t.Errorf("result schema_version = %d", v)
+	}
+}
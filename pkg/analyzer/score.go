@@ -0,0 +1,100 @@
+package analyzer
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ScoreWeights weighs the parts of a result's suspicion score. Only their
+// ratios matter.
+type ScoreWeights struct {
+	// LLM weighs the LLM verdict: 1 for HIGH, 0.5 for MEDIUM, 0 for LOW
+	LLM float64
+
+	// Heuristics weighs the stack trace, keyword, and churn signals
+	Heuristics float64
+
+	// Recency weighs how recently the commit was made
+	Recency float64
+}
+
+// DefaultScoreWeights lets the LLM verdict dominate, with heuristics and
+// recency ordering commits within and across neighboring verdicts
+var DefaultScoreWeights = ScoreWeights{LLM: 0.6, Heuristics: 0.25, Recency: 0.15}
+
+// probabilityScore places LLM verdicts on the suspicion scale
+var probabilityScore = map[Probability]float64{
+	ProbLow:    0,
+	ProbMedium: 0.5,
+	ProbHigh:   1,
+}
+
+// Validate checks that the weights are non-negative and not all zero
+func (w ScoreWeights) Validate() error {
+	if w.LLM < 0 || w.Heuristics < 0 || w.Recency < 0 {
+		return fmt.Errorf("score weights cannot be negative, got llm=%g heuristics=%g recency=%g", w.LLM, w.Heuristics, w.Recency)
+	}
+	if w.LLM+w.Heuristics+w.Recency == 0 {
+		return fmt.Errorf("score weights cannot all be zero")
+	}
+	return nil
+}
+
+// ParseScoreWeights parses weights written as "llm=0.6,heuristics=0.25,
+// recency=0.15". Weights left out are zero.
+func ParseScoreWeights(s string) (ScoreWeights, error) {
+	var w ScoreWeights
+	for _, part := range strings.Split(s, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return w, fmt.Errorf("invalid score weight %q (expected name=value)", part)
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return w, fmt.Errorf("invalid score weight %q: %w", part, err)
+		}
+		switch strings.TrimSpace(name) {
+		case "llm":
+			w.LLM = f
+		case "heuristics":
+			w.Heuristics = f
+		case "recency":
+			w.Recency = f
+		default:
+			return w, fmt.Errorf("unknown score weight %q (expected llm, heuristics, or recency)", name)
+		}
+	}
+	return w, w.Validate()
+}
+
+// Score sets the result's Suspicion from 0 to 1 by blending, with w, the
+// LLM verdict, the heuristic prior (stack trace, keyword, and churn
+// signals), and recency. Offline verdicts have no LLM part and keep their
+// heuristic score; results without heuristics, such as reused verdicts,
+// are scored on the verdict alone. Zero weights mean DefaultScoreWeights.
+func (ar *AnalysisResult) Score(w ScoreWeights) {
+	if w == (ScoreWeights{}) {
+		w = DefaultScoreWeights
+	}
+	var score float64
+	switch {
+	case ar.Skipped:
+		score = 0
+	case ar.Heuristics == nil:
+		score = probabilityScore[ar.Probability]
+	case ar.offline:
+		score = ar.Heuristics.Score
+	default:
+		score = (w.LLM*probabilityScore[ar.Probability] +
+			w.Heuristics*ar.Heuristics.Prior() +
+			w.Recency*ar.Heuristics.Recency) / (w.LLM + w.Heuristics + w.Recency)
+	}
+	ar.Suspicion = math.Round(score*1000) / 1000
+}
+
+// Rank orders results for triage by their suspicion score
+func (ar *AnalysisResult) Rank() float64 {
+	return ar.Suspicion
+}
@@ -0,0 +1,78 @@
+package analyzer
+
+import (
+	"testing"
+)
+
+func TestParseScoreWeights(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected ScoreWeights
+		wantErr  bool
+	}{
+		{"llm=0.6,heuristics=0.25,recency=0.15", ScoreWeights{LLM: 0.6, Heuristics: 0.25, Recency: 0.15}, false},
+		{" llm = 1 , recency=1", ScoreWeights{LLM: 1, Recency: 1}, false},
+		{"heuristics=2", ScoreWeights{Heuristics: 2}, false},
+		{"llm=0,heuristics=0", ScoreWeights{}, true},
+		{"llm=-1,heuristics=1", ScoreWeights{}, true},
+		{"llm", ScoreWeights{}, true},
+		{"llm=high", ScoreWeights{}, true},
+		{"churn=0.5", ScoreWeights{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseScoreWeights(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseScoreWeights(%q): expected error %v, got %v", tt.input, tt.wantErr, err)
+			continue
+		}
+		if !tt.wantErr && got != tt.expected {
+			t.Errorf("ParseScoreWeights(%q): expected %+v, got %+v", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestScore(t *testing.T) {
+	// Only recency: a prior of 0
+	recent := &Heuristics{Score: recencyWeight, Recency: 1}
+	// Stack trace match, old commit: a prior of 0.4/0.85
+	traced := &Heuristics{Score: stackTraceWeight, StackFiles: []string{"auth.go"}}
+
+	tests := []struct {
+		name     string
+		result   AnalysisResult
+		weights  ScoreWeights
+		expected float64
+	}{
+		{"skipped", AnalysisResult{Skipped: true, Probability: ProbHigh}, DefaultScoreWeights, 0},
+		{"verdict only", AnalysisResult{Probability: ProbMedium}, DefaultScoreWeights, 0.5},
+		{"recent HIGH", AnalysisResult{Probability: ProbHigh, Heuristics: recent}, DefaultScoreWeights, 0.75},
+		{"traced MEDIUM", AnalysisResult{Probability: ProbMedium, Heuristics: traced}, DefaultScoreWeights, 0.418},
+		{"recent LOW", AnalysisResult{Probability: ProbLow, Heuristics: recent}, DefaultScoreWeights, 0.15},
+		{"zero weights use defaults", AnalysisResult{Probability: ProbHigh, Heuristics: recent}, ScoreWeights{}, 0.75},
+		{"LLM only", AnalysisResult{Probability: ProbHigh, Heuristics: traced}, ScoreWeights{LLM: 1}, 1},
+		{"heuristics only", AnalysisResult{Probability: ProbLow, Heuristics: traced}, ScoreWeights{Heuristics: 2}, 0.471},
+		{"offline", AnalysisResult{Probability: ProbMedium, Heuristics: traced, offline: true}, DefaultScoreWeights, 0.4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.result.Score(tt.weights)
+			if tt.result.Suspicion != tt.expected {
+				t.Errorf("Expected suspicion %v, got %v", tt.expected, tt.result.Suspicion)
+			}
+			if tt.result.Rank() != tt.result.Suspicion {
+				t.Errorf("Expected Rank to be the suspicion score, got %v", tt.result.Rank())
+			}
+		})
+	}
+}
+
+func TestScoreOrdersWithinVerdict(t *testing.T) {
+	plain := &AnalysisResult{Probability: ProbHigh, Heuristics: &Heuristics{}}
+	traced := &AnalysisResult{Probability: ProbHigh, Heuristics: &Heuristics{Score: stackTraceWeight, StackFiles: []string{"auth.go"}}}
+	plain.Score(DefaultScoreWeights)
+	traced.Score(DefaultScoreWeights)
+
+	if traced.Suspicion <= plain.Suspicion {
+		t.Errorf("Expected a stack trace match to raise suspicion, got %v <= %v", traced.Suspicion, plain.Suspicion)
+	}
+}
@@ -0,0 +1,82 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// IsShallow reports whether repo is a shallow clone, as CI checkouts often
+// are
+func IsShallow(repo *git.Repository) bool {
+	shallow, err := repo.Storer.Shallow()
+	return err == nil && len(shallow) > 0
+}
+
+// IsShallowBoundary reports whether err comes from walking past the oldest
+// commit of a shallow clone, whose parents were not fetched. History walks
+// stop there instead of failing.
+func IsShallowBoundary(repo *git.Repository, err error) bool {
+	return errors.Is(err, plumbing.ErrObjectNotFound) && IsShallow(repo)
+}
+
+// DeepenShallow fetches more history from origin when from has fewer than
+// depth commits of first-parent history in a shallow clone, so that each
+// analyzed commit can be diffed against its parent. The depth counts from
+// the tips of the remote branches. It reports whether it fetched.
+func DeepenShallow(ctx context.Context, repo *git.Repository, from *object.Commit, depth int) (bool, error) {
+	if !IsShallow(repo) || !missingHistory(repo, from, depth) {
+		return false, nil
+	}
+	err := repo.FetchContext(ctx, &git.FetchOptions{Depth: depth})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return false, fmt.Errorf("failed to deepen shallow clone: %w", err)
+	}
+	if err := pruneShallow(repo); err != nil {
+		return false, fmt.Errorf("failed to update shallow commits: %w", err)
+	}
+	return true, nil
+}
+
+// missingHistory reports whether the first depth commits of from's
+// first-parent history are not all present
+func missingHistory(repo *git.Repository, from *object.Commit, depth int) bool {
+	current := from
+	for i := 1; i < depth && len(current.ParentHashes) > 0; i++ {
+		parent, err := repo.CommitObject(current.ParentHashes[0])
+		if err != nil {
+			return true
+		}
+		current = parent
+	}
+	return false
+}
+
+// pruneShallow drops commits whose parents have since been fetched from
+// the shallow list; go-git adds the new boundary but keeps the old one,
+// which would still end history there
+func pruneShallow(repo *git.Repository) error {
+	shallow, err := repo.Storer.Shallow()
+	if err != nil {
+		return err
+	}
+	var kept []plumbing.Hash
+	for _, h := range shallow {
+		c, err := repo.CommitObject(h)
+		if err != nil {
+			kept = append(kept, h)
+			continue
+		}
+		for _, p := range c.ParentHashes {
+			if _, err := repo.Storer.EncodedObject(plumbing.CommitObject, p); err != nil {
+				kept = append(kept, h)
+				break
+			}
+		}
+	}
+	return repo.Storer.SetShallow(kept)
+}
@@ -0,0 +1,82 @@
+package analyzer
+
+import (
+	"context"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+
+	"github.com/kerneldump/git-dual-context/pkg/gitdiff"
+)
+
+func TestShallowClone(t *testing.T) {
+	// go-git serves local clones through git-upload-pack
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	src := createTestRepo(t, []struct{ path, content string }{
+		{"main.go", "package main\n"},
+		{"main.go", "package main\n\nfunc main() {}\n"},
+		{"main.go", "package main\n\nfunc main() { run() }\n"},
+		{"main.go", "package main\n\nfunc main() { run(); stop() }\n"},
+	})
+	w, err := src.Worktree()
+	if err != nil {
+		t.Fatalf("Failed to get worktree: %v", err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "shallow")
+	repo, err := git.PlainClone(dir, false, &git.CloneOptions{URL: "file://" + w.Filesystem.Root(), Depth: 2})
+	if err != nil {
+		t.Fatalf("Failed to clone: %v", err)
+	}
+	if !IsShallow(repo) {
+		t.Fatal("Expected clone to be shallow")
+	}
+
+	// Collection stops at the oldest fetched commit, which is skipped
+	// because it cannot be diffed
+	commits, head, err := CollectCommits(repo, AnalysisOptions{NumCommits: 4})
+	if err != nil {
+		t.Fatalf("CollectCommits failed: %v", err)
+	}
+	if len(commits) != 2 {
+		t.Fatalf("Expected 2 commits, got %d", len(commits))
+	}
+	diffCtx, err := ExtractDiffs(repo, commits[1], head)
+	if err != nil {
+		t.Fatalf("ExtractDiffs failed at the shallow boundary: %v", err)
+	}
+	if !diffCtx.Skipped {
+		t.Error("Expected the shallow boundary commit to be skipped")
+	}
+	if _, err := gitdiff.LoadHotspots(head, 10); err != nil {
+		t.Errorf("Expected hotspots to stop at the shallow boundary, got %v", err)
+	}
+
+	deepened, err := DeepenShallow(context.Background(), repo, head, 5)
+	if err != nil {
+		t.Fatalf("DeepenShallow failed: %v", err)
+	}
+	if !deepened {
+		t.Error("Expected DeepenShallow to fetch")
+	}
+	commits, _, err = CollectCommits(repo, AnalysisOptions{NumCommits: 4})
+	if err != nil {
+		t.Fatalf("CollectCommits failed after deepening: %v", err)
+	}
+	if len(commits) != 4 {
+		t.Errorf("Expected 4 commits after deepening, got %d", len(commits))
+	}
+	if IsShallow(repo) {
+		t.Error("Expected the full history to be fetched")
+	}
+
+	// Nothing is fetched once history is deep enough
+	deepened, err = DeepenShallow(context.Background(), repo, head, 4)
+	if err != nil || deepened {
+		t.Errorf("Expected no fetch for a complete history, got %v, %v", deepened, err)
+	}
+}
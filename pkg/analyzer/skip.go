@@ -0,0 +1,118 @@
+package analyzer
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/kerneldump/git-dual-context/pkg/gitdiff"
+)
+
+// SkipReason says why a commit was skipped without a verdict
+type SkipReason string
+
+const (
+	SkipEmpty     SkipReason = "empty"            // the commit changes no files
+	SkipMerge     SkipReason = "merge"            // merge commits are not analyzed
+	SkipShallow   SkipReason = "shallow_boundary" // the parent was not fetched
+	SkipTests     SkipReason = "tests_only"       // every file is a test file
+	SkipLockFiles SkipReason = "lockfiles_only"   // every file is a lock file
+	SkipFiltered  SkipReason = "filtered"         // every file is left out by the Include, Only, or Exclude patterns
+	SkipIgnored   SkipReason = "ignored_files"    // every file is ignored, by several rules or CI and directory rules
+	SkipNoDiff    SkipReason = "no_relevant_diff" // the files left are binary or generated
+	SkipTrivial   SkipReason = "too_few_lines"    // fewer changed lines than gitdiff.Options.MinChangedLines
+	SkipDeadline  SkipReason = "run_deadline"     // the run's deadline left no time for the commit (see Budget)
+)
+
+// SkipInfo explains a skipped commit, so that users can tell whether the
+// filters hide the culprit
+type SkipInfo struct {
+	Reason SkipReason `json:"reason"`
+
+	// IgnoredFiles counts the commit's files by the rule that ignored
+	// them (see gitdiff.Filter.IgnoreRule), for the reasons that ignore
+	// files
+	IgnoredFiles map[string]int `json:"ignored_files,omitempty"`
+}
+
+// String describes the skip, such as "only test files changed (3 files)".
+// A nil SkipInfo, of a skip whose reason is unknown, is described as "no
+// relevant code changes".
+func (s *SkipInfo) String() string {
+	if s == nil {
+		return "no relevant code changes"
+	}
+	var desc string
+	switch s.Reason {
+	case SkipEmpty:
+		return "empty commit"
+	case SkipMerge:
+		return "merge commit"
+	case SkipShallow:
+		return "parent not fetched (shallow clone)"
+	case SkipNoDiff:
+		return "only binary or generated files changed"
+	case SkipTrivial:
+		return "too few changed lines"
+	case SkipDeadline:
+		return "run deadline reached"
+	case SkipTests:
+		desc = "only test files changed"
+	case SkipLockFiles:
+		desc = "only lock files changed"
+	case SkipFiltered:
+		desc = "all files excluded by filters"
+	default:
+		desc = "only ignored files changed"
+	}
+	if len(s.IgnoredFiles) == 1 {
+		for _, n := range s.IgnoredFiles {
+			return fmt.Sprintf("%s (%d files)", desc, n)
+		}
+	}
+	var counts []string
+	for _, rule := range slices.Sorted(maps.Keys(s.IgnoredFiles)) {
+		counts = append(counts, fmt.Sprintf("%s: %d", rule, s.IgnoredFiles[rule]))
+	}
+	if len(counts) == 0 {
+		return desc
+	}
+	return fmt.Sprintf("%s (%s)", desc, strings.Join(counts, ", "))
+}
+
+// skip marks the commit skipped for reason
+func (dc *CommitDiffContext) skip(reason SkipReason) {
+	dc.Skipped = true
+	dc.Skip = &SkipInfo{Reason: reason}
+}
+
+// SkipForPaths returns why a commit changing paths is skipped before any
+// patch is built, or nil if filter keeps one of them. Only path rules are
+// applied, as in gitdiff.OnlyIgnoredChanges.
+func SkipForPaths(paths []string, filter *gitdiff.Filter) *SkipInfo {
+	if len(paths) == 0 {
+		return &SkipInfo{Reason: SkipEmpty}
+	}
+	counts := map[string]int{}
+	for _, p := range paths {
+		rule := filter.IgnoreRule(p)
+		if rule == "" {
+			return nil
+		}
+		counts[rule]++
+	}
+
+	skip := &SkipInfo{Reason: SkipIgnored, IgnoredFiles: counts}
+	if len(counts) == 1 {
+		switch {
+		case counts[gitdiff.RuleTest] > 0:
+			skip.Reason = SkipTests
+		case counts[gitdiff.RuleLockFile] > 0:
+			skip.Reason = SkipLockFiles
+		case counts[gitdiff.RuleFilter] > 0:
+			skip.Reason = SkipFiltered
+		}
+	}
+	return skip
+}
@@ -0,0 +1,77 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kerneldump/git-dual-context/pkg/gitdiff"
+)
+
+func TestSkipForPaths(t *testing.T) {
+	filter, err := gitdiff.NewFilter(nil, []string{"docs/**"})
+	if err != nil {
+		t.Fatalf("NewFilter failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		paths    []string
+		expected *SkipInfo
+	}{
+		{"kept", []string{"main_test.go", "main.go"}, nil},
+		{"empty", nil, &SkipInfo{Reason: SkipEmpty}},
+		{"tests", []string{"a_test.go", "b_test.go"}, &SkipInfo{Reason: SkipTests, IgnoredFiles: map[string]int{"test": 2}}},
+		{"lock files", []string{"go.sum", "yarn.lock"}, &SkipInfo{Reason: SkipLockFiles, IgnoredFiles: map[string]int{"lockfile": 2}}},
+		{"filtered", []string{"docs/setup.md"}, &SkipInfo{Reason: SkipFiltered, IgnoredFiles: map[string]int{"filter": 1}}},
+		{"mixed", []string{"go.sum", "main_test.go"}, &SkipInfo{Reason: SkipIgnored, IgnoredFiles: map[string]int{"lockfile": 1, "test": 1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SkipForPaths(tt.paths, filter); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestSkipInfoString(t *testing.T) {
+	tests := []struct {
+		skip     *SkipInfo
+		expected string
+	}{
+		{nil, "no relevant code changes"},
+		{&SkipInfo{Reason: SkipMerge}, "merge commit"},
+		{&SkipInfo{Reason: SkipTests, IgnoredFiles: map[string]int{"test": 3}}, "only test files changed (3 files)"},
+		{&SkipInfo{Reason: SkipIgnored, IgnoredFiles: map[string]int{"test": 1, "ci": 2}}, "only ignored files changed (ci: 2, test: 1)"},
+	}
+	for _, tt := range tests {
+		if got := tt.skip.String(); got != tt.expected {
+			t.Errorf("Expected %q, got %q", tt.expected, got)
+		}
+	}
+}
+
+func TestExtractDiffsSkipReason(t *testing.T) {
+	repo := createTestRepo(t, []struct{ path, content string }{
+		{"main.go", "package main\n"},
+		{"main_test.go", "package main\n"},
+		{"go.sum", "checksum\n"},
+		{"logo.png", "\x89PNG\x00\x01"},
+	})
+	commits, head, err := CollectCommits(repo, AnalysisOptions{NumCommits: 3})
+	if err != nil {
+		t.Fatalf("CollectCommits failed: %v", err)
+	}
+
+	// Newest first
+	expected := []SkipReason{SkipNoDiff, SkipLockFiles, SkipTests}
+	for i, c := range commits {
+		dc, err := ExtractDiffs(repo, c, head)
+		if err != nil {
+			t.Fatalf("ExtractDiffs failed: %v", err)
+		}
+		if !dc.Skipped || dc.Skip == nil || dc.Skip.Reason != expected[i] {
+			t.Errorf("Commit %d: expected skip reason %s, got %+v", i, expected[i], dc.Skip)
+		}
+	}
+}
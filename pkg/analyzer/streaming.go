@@ -0,0 +1,88 @@
+package analyzer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/iterator"
+)
+
+// responseIterator yields the chunks of a streamed response, like
+// *genai.GenerateContentResponseIterator
+type responseIterator interface {
+	Next() (*genai.GenerateContentResponse, error)
+}
+
+// StreamingModel streams the responses of a Gemini model and stops
+// generation as soon as the text holds a complete verdict JSON block,
+// saving the latency and billed output tokens of anything the model would
+// write after it. Responses are returned whole, as one text part.
+type StreamingModel struct {
+	stream func(ctx context.Context, parts ...genai.Part) responseIterator
+}
+
+// NewStreamingModel returns a StreamingModel generating with model
+func NewStreamingModel(model *genai.GenerativeModel) *StreamingModel {
+	return &StreamingModel{stream: func(ctx context.Context, parts ...genai.Part) responseIterator {
+		return model.GenerateContentStream(ctx, parts...)
+	}}
+}
+
+// GenerateContent implements LLMModel
+func (m *StreamingModel) GenerateContent(ctx context.Context, parts ...genai.Part) (*genai.GenerateContentResponse, error) {
+	// Cancelling the stream once the verdict is in stops generation
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	it := m.stream(ctx, parts...)
+	var text strings.Builder
+	candidate := &genai.Candidate{Content: &genai.Content{Role: "model"}}
+	resp := &genai.GenerateContentResponse{}
+	for {
+		chunk, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		// Usage is reported with every chunk, counting the output so far
+		if chunk.UsageMetadata != nil {
+			resp.UsageMetadata = chunk.UsageMetadata
+		}
+		if len(chunk.Candidates) == 0 || chunk.Candidates[0].Content == nil {
+			continue
+		}
+		candidate.FinishReason = chunk.Candidates[0].FinishReason
+		for _, part := range chunk.Candidates[0].Content.Parts {
+			if t, ok := part.(genai.Text); ok {
+				text.WriteString(string(t))
+			}
+		}
+		if completeVerdict(text.String()) {
+			break
+		}
+	}
+	if text.Len() > 0 {
+		candidate.Content.Parts = []genai.Part{genai.Text(text.String())}
+		resp.Candidates = []*genai.Candidate{candidate}
+	}
+	return resp, nil
+}
+
+// completeVerdict reports whether text holds a JSON block with both
+// fields of a verdict
+func completeVerdict(text string) bool {
+	block := FindJSONBlock(text)
+	if block == "" {
+		return false
+	}
+	var verdict struct {
+		Probability string `json:"probability"`
+		Reasoning   string `json:"reasoning"`
+	}
+	return json.Unmarshal([]byte(block), &verdict) == nil && verdict.Probability != "" && verdict.Reasoning != ""
+}
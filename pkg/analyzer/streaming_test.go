@@ -0,0 +1,108 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/iterator"
+)
+
+// chunkIterator yields text chunks, then err (default: iterator.Done)
+type chunkIterator struct {
+	chunks []string
+	err    error
+	next   int
+}
+
+func (it *chunkIterator) Next() (*genai.GenerateContentResponse, error) {
+	if it.next == len(it.chunks) {
+		if it.err != nil {
+			return nil, it.err
+		}
+		return nil, iterator.Done
+	}
+	it.next++
+	return &genai.GenerateContentResponse{
+		Candidates:    []*genai.Candidate{{Content: &genai.Content{Parts: []genai.Part{genai.Text(it.chunks[it.next-1])}}}},
+		UsageMetadata: &genai.UsageMetadata{CandidatesTokenCount: int32(it.next)},
+	}, nil
+}
+
+func streamingModel(it *chunkIterator) *StreamingModel {
+	return &StreamingModel{stream: func(ctx context.Context, parts ...genai.Part) responseIterator { return it }}
+}
+
+func TestStreamingModelStopsAtVerdict(t *testing.T) {
+	it := &chunkIterator{chunks: []string{
+		"```json\n{\"probability\": \"HI",
+		"GH\", \"reasoning\": \"Changes the TTL\"}\n```",
+		"\nSome trailing commentary",
+		"that should never be read",
+	}}
+	resp, err := streamingModel(it).GenerateContent(context.Background(), genai.Text("prompt"))
+	if err != nil {
+		t.Fatalf("GenerateContent failed: %v", err)
+	}
+	if it.next != 2 {
+		t.Errorf("Expected the stream to stop after 2 chunks, read %d", it.next)
+	}
+	text := string(resp.Candidates[0].Content.Parts[0].(genai.Text))
+	if text != it.chunks[0]+it.chunks[1] {
+		t.Errorf("Expected the chunks to be joined, got %q", text)
+	}
+	if resp.UsageMetadata.CandidatesTokenCount != 2 {
+		t.Errorf("Expected the latest usage, got %d", resp.UsageMetadata.CandidatesTokenCount)
+	}
+}
+
+func TestStreamingModelReadsWholeStream(t *testing.T) {
+	// Without a complete verdict the whole response is returned
+	it := &chunkIterator{chunks: []string{"{\"probability\": ", "\"LOW\"}", " and nothing else"}}
+	resp, err := streamingModel(it).GenerateContent(context.Background(), genai.Text("prompt"))
+	if err != nil {
+		t.Fatalf("GenerateContent failed: %v", err)
+	}
+	if it.next != 3 {
+		t.Errorf("Expected all 3 chunks to be read, read %d", it.next)
+	}
+	if text := string(resp.Candidates[0].Content.Parts[0].(genai.Text)); text != "{\"probability\": \"LOW\"} and nothing else" {
+		t.Errorf("Unexpected text %q", text)
+	}
+
+	empty, err := streamingModel(&chunkIterator{}).GenerateContent(context.Background(), genai.Text("prompt"))
+	if err != nil {
+		t.Fatalf("GenerateContent failed: %v", err)
+	}
+	if len(empty.Candidates) != 0 {
+		t.Errorf("Expected no candidates for an empty stream, got %d", len(empty.Candidates))
+	}
+}
+
+func TestStreamingModelError(t *testing.T) {
+	want := errors.New("stream broken")
+	_, err := streamingModel(&chunkIterator{chunks: []string{"{\"prob"}, err: want}).GenerateContent(context.Background(), genai.Text("prompt"))
+	if !errors.Is(err, want) {
+		t.Errorf("Expected the stream error, got %v", err)
+	}
+}
+
+func TestCompleteVerdict(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"", false},
+		{"{\"probability\": \"HIGH\"", false},
+		{"{\"probability\": \"HIGH\"}", false},
+		{"{\"probability\": \"LOW\", \"reasoning\": \"Docs only\"}", true},
+		{"Verdict:\n```json\n{\"reasoning\": \"Docs only\", \"probability\": \"LOW\"}\n```", true},
+		{"{\"probability\": \"\", \"reasoning\": \"x\"}", false},
+	}
+	for _, tt := range tests {
+		if got := completeVerdict(tt.text); got != tt.want {
+			t.Errorf("completeVerdict(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package analyzer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+
+	"github.com/kerneldump/git-dual-context/pkg/gitdiff"
+)
+
+// minCountedText is the size, in bytes, from which GeminiTokenizer counts a
+// text with the API. Shorter texts, such as the lines a truncated file is
+// cut at, are estimated, so fitting a diff costs a call per large file or
+// hunk rather than per line.
+const minCountedText = 2048
+
+// countTokensTimeout bounds each CountTokens call
+const countTokensTimeout = 10 * time.Second
+
+// TokenCounter counts the tokens of a prompt, as *genai.GenerativeModel does
+type TokenCounter interface {
+	CountTokens(ctx context.Context, parts ...genai.Part) (*genai.CountTokensResponse, error)
+}
+
+// GeminiTokenizer is a gitdiff.Tokenizer counting the tokens of the texts
+// it is given with a model's CountTokens. Texts under minCountedText are
+// estimated with gitdiff.EstimateTokens, scaled by how the counts so far
+// compare to their estimates. Once a call fails, such as offline, every
+// text is estimated that way. It is safe for concurrent use.
+type GeminiTokenizer struct {
+	ctx     context.Context
+	counter TokenCounter
+
+	mu        sync.Mutex
+	counted   int // tokens of the texts counted by the API
+	estimated int // estimated tokens of the same texts
+	failed    bool
+}
+
+// NewGeminiTokenizer returns a tokenizer calling counter under ctx
+func NewGeminiTokenizer(ctx context.Context, counter TokenCounter) *GeminiTokenizer {
+	return &GeminiTokenizer{ctx: ctx, counter: counter}
+}
+
+// CountTokens implements gitdiff.Tokenizer
+func (t *GeminiTokenizer) CountTokens(text string) int {
+	estimate := gitdiff.EstimateTokens(text)
+
+	t.mu.Lock()
+	failed := t.failed
+	t.mu.Unlock()
+	if failed || len(text) < minCountedText {
+		return t.scale(estimate)
+	}
+
+	ctx, cancel := context.WithTimeout(t.ctx, countTokensTimeout)
+	defer cancel()
+	resp, err := t.counter.CountTokens(ctx, genai.Text(text))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err != nil || resp == nil {
+		t.failed = true
+		return t.scaleLocked(estimate)
+	}
+	t.counted += int(resp.TotalTokens)
+	t.estimated += estimate
+	return int(resp.TotalTokens)
+}
+
+// scale adjusts an estimate by the ratio of the counted tokens to their
+// estimates, rounding up
+func (t *GeminiTokenizer) scale(estimate int) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.scaleLocked(estimate)
+}
+
+// scaleLocked is scale with t.mu held
+func (t *GeminiTokenizer) scaleLocked(estimate int) int {
+	if t.counted == 0 || t.estimated == 0 {
+		return estimate
+	}
+	return int((int64(estimate)*int64(t.counted) + int64(t.estimated) - 1) / int64(t.estimated))
+}
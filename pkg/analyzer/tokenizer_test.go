@@ -0,0 +1,54 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/generative-ai-go/genai"
+
+	"github.com/kerneldump/git-dual-context/pkg/gitdiff"
+)
+
+// doublingCounter counts twice the estimated tokens of a text, or fails
+type doublingCounter struct {
+	calls int
+	err   error
+}
+
+func (c *doublingCounter) CountTokens(ctx context.Context, parts ...genai.Part) (*genai.CountTokensResponse, error) {
+	c.calls++
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &genai.CountTokensResponse{TotalTokens: int32(2 * gitdiff.EstimateTokens(string(parts[0].(genai.Text))))}, nil
+}
+
+func TestGeminiTokenizer(t *testing.T) {
+	counter := &doublingCounter{}
+	tok := NewGeminiTokenizer(context.Background(), counter)
+	line := "+\tif err := refresh(ctx, token); err != nil {\n"
+	large := strings.Repeat(line, minCountedText/len(line)+1)
+
+	// A short text is estimated until a count calibrates the estimates
+	if got, want := tok.CountTokens(line), gitdiff.EstimateTokens(line); got != want || counter.calls != 0 {
+		t.Fatalf("Short text: got %d tokens and %d calls, want %d and none", got, counter.calls, want)
+	}
+	if got, want := tok.CountTokens(large), 2*gitdiff.EstimateTokens(large); got != want || counter.calls != 1 {
+		t.Fatalf("Large text: got %d tokens and %d calls, want %d and one", got, counter.calls, want)
+	}
+	if got, want := tok.CountTokens(line), 2*gitdiff.EstimateTokens(line); got != want || counter.calls != 1 {
+		t.Fatalf("Calibrated short text: got %d tokens and %d calls, want %d and no more", got, counter.calls, want)
+	}
+
+	// After a failed call every text is estimated, calibrated as before
+	counter.err = errors.New("offline")
+	if got, want := tok.CountTokens(large+line), 2*gitdiff.EstimateTokens(large+line); got != want {
+		t.Errorf("Failed count: got %d tokens, want %d", got, want)
+	}
+	tok.CountTokens(large)
+	if counter.calls != 2 {
+		t.Errorf("Expected no call after a failure, got %d calls", counter.calls)
+	}
+}
@@ -0,0 +1,21 @@
+package analyzer
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer emits spans for each pipeline stage. It uses the global
+// TracerProvider, which is a no-op unless the host installs one
+// (see pkg/telemetry).
+var tracer = otel.Tracer("github.com/kerneldump/git-dual-context/pkg/analyzer")
+
+// endSpan records err (if any) on the span and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,271 @@
+package analyzer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/storage/memory"
+
+	"github.com/kerneldump/git-dual-context/pkg/gitdiff"
+)
+
+// maxWorkingTreeFileSize bounds the working tree files UncommittedCommit
+// reads; larger ones are replaced by a stub diffed as a binary file
+const maxWorkingTreeFileSize = 1 << 20
+
+// ErrNoUncommittedChanges is returned by UncommittedCommit when the index,
+// or the working tree, matches HEAD
+var ErrNoUncommittedChanges = errors.New("no uncommitted changes")
+
+// UncommittedCommit returns the changes not yet committed in r's working
+// tree as a commit on top of HEAD, so that they are diffed and analyzed
+// like any commit: with staged, the changes added to the index; otherwise
+// every change in the working tree, untracked files not ignored by
+// .gitignore included.
+//
+// Working tree files the diff would leave out, by filter (nil: the
+// built-in rules only), are not read and keep their staged content, and
+// files over maxWorkingTreeFileSize are stubbed as binary files, so only
+// the changes to analyze are held in memory.
+//
+// The commit and its trees are kept in memory, not written to the
+// repository. They can only be read through the returned commit, so it
+// must not go to a RepoPool or the system git diff backend, which look
+// commits up in the repository.
+func UncommittedCommit(r *git.Repository, staged bool, filter *gitdiff.Filter) (*object.Commit, error) {
+	wt, err := r.Worktree()
+	if errors.Is(err, git.ErrIsBareRepository) {
+		return nil, fmt.Errorf("a bare repository has no uncommitted changes")
+	}
+	if err != nil {
+		return nil, err
+	}
+	head, err := ResolveCommit(r, "")
+	if err != nil {
+		return nil, err
+	}
+
+	// Start from the index, the staged state of every tracked file
+	idx, err := r.Storer.Index()
+	if err != nil {
+		return nil, fmt.Errorf("reading the index: %w", err)
+	}
+	entries := make(map[string]object.TreeEntry, len(idx.Entries))
+	for _, e := range idx.Entries {
+		if e.Stage != 0 {
+			return nil, fmt.Errorf("the index has unmerged paths, such as %s; resolve the conflicts first", e.Name)
+		}
+		entries[e.Name] = object.TreeEntry{Name: e.Name, Mode: e.Mode, Hash: e.Hash}
+	}
+
+	s := &overlayStorer{EncodedObjectStorer: r.Storer, mem: memory.NewStorage()}
+	if !staged {
+		status, err := wt.Status()
+		if err != nil {
+			return nil, fmt.Errorf("reading the working tree status: %w", err)
+		}
+		for path, fs := range status {
+			switch fs.Worktree {
+			case git.Unmodified:
+				continue
+			case git.Deleted:
+				delete(entries, path)
+				continue
+			}
+			if filter.Ignore(path) {
+				continue
+			}
+			entry, err := workingTreeEntry(wt, s, path)
+			if err != nil {
+				return nil, fmt.Errorf("reading %s: %w", path, err)
+			}
+			entries[path] = entry
+		}
+	}
+
+	root := &treeNode{}
+	for path, entry := range entries {
+		root.add(strings.Split(path, "/"), entry)
+	}
+	treeHash, err := root.write(s)
+	if err != nil {
+		return nil, fmt.Errorf("building the tree: %w", err)
+	}
+	if treeHash == head.TreeHash {
+		return nil, ErrNoUncommittedChanges
+	}
+
+	message := "Uncommitted changes (working tree)\n"
+	if staged {
+		message = "Uncommitted changes (staged)\n"
+	}
+	sig := object.Signature{Name: "Working tree", When: time.Now()}
+	if cfg, err := r.ConfigScoped(gitconfig.GlobalScope); err == nil && cfg.User.Name != "" {
+		sig.Name, sig.Email = cfg.User.Name, cfg.User.Email
+	}
+	commit := &object.Commit{
+		Author:       sig,
+		Committer:    sig,
+		Message:      message,
+		TreeHash:     treeHash,
+		ParentHashes: []plumbing.Hash{head.Hash},
+	}
+	obj := s.NewEncodedObject()
+	if err := commit.Encode(obj); err != nil {
+		return nil, err
+	}
+	if _, err := s.SetEncodedObject(obj); err != nil {
+		return nil, err
+	}
+	return object.DecodeCommit(s, obj)
+}
+
+// workingTreeEntry stores the file at path in wt as a blob in s and
+// returns its tree entry. A file over maxWorkingTreeFileSize is stored as
+// a stub starting with a NUL byte, so that it is diffed as binary.
+func workingTreeEntry(wt *git.Worktree, s storer.EncodedObjectStorer, path string) (object.TreeEntry, error) {
+	info, err := wt.Filesystem.Lstat(path)
+	if err != nil {
+		return object.TreeEntry{}, err
+	}
+	mode, err := filemode.NewFromOSFileMode(info.Mode())
+	if err != nil {
+		return object.TreeEntry{}, err
+	}
+
+	obj := s.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	w, err := obj.Writer()
+	if err != nil {
+		return object.TreeEntry{}, err
+	}
+	if mode == filemode.Symlink {
+		target, err := wt.Filesystem.Readlink(path)
+		if err != nil {
+			return object.TreeEntry{}, err
+		}
+		_, err = io.WriteString(w, target)
+	} else if info.Size() > maxWorkingTreeFileSize {
+		_, err = fmt.Fprintf(w, "\x00[%d bytes, over the %d-byte limit: not read]\n", info.Size(), maxWorkingTreeFileSize)
+	} else {
+		f, err := wt.Filesystem.Open(path)
+		if err != nil {
+			return object.TreeEntry{}, err
+		}
+		_, err = io.Copy(w, f)
+		f.Close()
+	}
+	if err != nil {
+		return object.TreeEntry{}, err
+	}
+	if err := w.Close(); err != nil {
+		return object.TreeEntry{}, err
+	}
+	hash, err := s.SetEncodedObject(obj)
+	if err != nil {
+		return object.TreeEntry{}, err
+	}
+	return object.TreeEntry{Name: path, Mode: mode, Hash: hash}, nil
+}
+
+// treeNode is a directory of the tree UncommittedCommit builds
+type treeNode struct {
+	files map[string]object.TreeEntry
+	dirs  map[string]*treeNode
+}
+
+// add places entry at the path made of parts below n
+func (n *treeNode) add(parts []string, entry object.TreeEntry) {
+	if len(parts) == 1 {
+		if n.files == nil {
+			n.files = map[string]object.TreeEntry{}
+		}
+		entry.Name = parts[0]
+		n.files[parts[0]] = entry
+		return
+	}
+	if n.dirs == nil {
+		n.dirs = map[string]*treeNode{}
+	}
+	dir := n.dirs[parts[0]]
+	if dir == nil {
+		dir = &treeNode{}
+		n.dirs[parts[0]] = dir
+	}
+	dir.add(parts[1:], entry)
+}
+
+// write stores n and its subdirectories as trees in s and returns n's hash
+func (n *treeNode) write(s storer.EncodedObjectStorer) (plumbing.Hash, error) {
+	entries := make([]object.TreeEntry, 0, len(n.files)+len(n.dirs))
+	for _, entry := range n.files {
+		entries = append(entries, entry)
+	}
+	for name, dir := range n.dirs {
+		hash, err := dir.write(s)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		entries = append(entries, object.TreeEntry{Name: name, Mode: filemode.Dir, Hash: hash})
+	}
+	// Git sorts entries by name, directories as if followed by a slash
+	sortKey := func(e object.TreeEntry) string {
+		if e.Mode == filemode.Dir {
+			return e.Name + "/"
+		}
+		return e.Name
+	}
+	sort.Slice(entries, func(i, j int) bool { return sortKey(entries[i]) < sortKey(entries[j]) })
+
+	obj := s.NewEncodedObject()
+	if err := (&object.Tree{Entries: entries}).Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return s.SetEncodedObject(obj)
+}
+
+// overlayStorer reads objects from memory first, then from a repository's
+// storage, and writes them to memory only
+type overlayStorer struct {
+	storer.EncodedObjectStorer
+	mem *memory.Storage
+}
+
+func (o *overlayStorer) NewEncodedObject() plumbing.EncodedObject {
+	return o.mem.NewEncodedObject()
+}
+
+func (o *overlayStorer) SetEncodedObject(obj plumbing.EncodedObject) (plumbing.Hash, error) {
+	return o.mem.SetEncodedObject(obj)
+}
+
+func (o *overlayStorer) EncodedObject(t plumbing.ObjectType, h plumbing.Hash) (plumbing.EncodedObject, error) {
+	if obj, err := o.mem.EncodedObject(t, h); err == nil {
+		return obj, nil
+	}
+	return o.EncodedObjectStorer.EncodedObject(t, h)
+}
+
+func (o *overlayStorer) HasEncodedObject(h plumbing.Hash) error {
+	if o.mem.HasEncodedObject(h) == nil {
+		return nil
+	}
+	return o.EncodedObjectStorer.HasEncodedObject(h)
+}
+
+func (o *overlayStorer) EncodedObjectSize(h plumbing.Hash) (int64, error) {
+	if size, err := o.mem.EncodedObjectSize(h); err == nil {
+		return size, nil
+	}
+	return o.EncodedObjectStorer.EncodedObjectSize(h)
+}
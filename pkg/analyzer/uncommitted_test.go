@@ -0,0 +1,125 @@
+package analyzer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/kerneldump/git-dual-context/pkg/gitdiff"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestUncommittedCommit(t *testing.T) {
+	repo := createTestRepo(t, []struct{ path, content string }{
+		{"main.go", "package main\n"},
+		{"pkg/util/util.go", "package util\n"},
+		{"pkg/util.go", "package pkg\n"},
+		{"pkg-a/a.go", "package a\n"},
+	})
+	wt, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("Failed to get worktree: %v", err)
+	}
+	dir := wt.Filesystem.Root()
+
+	// A clean working tree rebuilds HEAD's tree exactly
+	for _, staged := range []bool{true, false} {
+		if _, err := UncommittedCommit(repo, staged, nil); !errors.Is(err, ErrNoUncommittedChanges) {
+			t.Errorf("staged=%v: expected ErrNoUncommittedChanges, got %v", staged, err)
+		}
+	}
+
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, path), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("pkg/new.go", "package pkg\n\nfunc New() {}\n")
+	if _, err := wt.Add("pkg/new.go"); err != nil {
+		t.Fatal(err)
+	}
+	write("main.go", "package main\n\nfunc main() { panic(nil) }\n")
+	write("untracked.go", "package main\n")
+	if err := os.Remove(filepath.Join(dir, "pkg-a/a.go")); err != nil {
+		t.Fatal(err)
+	}
+
+	changed := func(c *object.Commit) []string {
+		t.Helper()
+		parent, err := c.Parent(0)
+		if err != nil {
+			t.Fatalf("Failed to get parent: %v", err)
+		}
+		patch, err := parent.Patch(c)
+		if err != nil {
+			t.Fatalf("Failed to diff: %v", err)
+		}
+		var paths []string
+		for _, fp := range patch.FilePatches() {
+			from, to := fp.Files()
+			if to != nil {
+				paths = append(paths, to.Path())
+			} else {
+				paths = append(paths, from.Path())
+			}
+		}
+		slices.Sort(paths)
+		return paths
+	}
+
+	staged, err := UncommittedCommit(repo, true, nil)
+	if err != nil {
+		t.Fatalf("UncommittedCommit(staged) failed: %v", err)
+	}
+	if got := changed(staged); !slices.Equal(got, []string{"pkg/new.go"}) {
+		t.Errorf("expected only the staged file, got %v", got)
+	}
+	if !strings.Contains(staged.Message, "staged") {
+		t.Errorf("unexpected message %q", staged.Message)
+	}
+
+	all, err := UncommittedCommit(repo, false, nil)
+	if err != nil {
+		t.Fatalf("UncommittedCommit failed: %v", err)
+	}
+	if got, want := changed(all), []string{"main.go", "pkg-a/a.go", "pkg/new.go", "untracked.go"}; !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	// The commit is not written to the repository
+	if _, err := repo.CommitObject(all.Hash); err == nil {
+		t.Error("expected the commit to stay out of the repository")
+	}
+
+	// It is analyzed like any commit, as its own head
+	diffCtx, err := ExtractDiffsContext(t.Context(), repo, all, all, gitdiff.Options{})
+	if err != nil {
+		t.Fatalf("ExtractDiffsContext failed: %v", err)
+	}
+	if diffCtx.Skipped || !strings.Contains(diffCtx.StandardDiff, "panic(nil)") {
+		t.Errorf("expected the working tree change in the diff, got:\n%s", diffCtx.StandardDiff)
+	}
+
+	// Ignored files are left out unread, and oversized ones are stubbed
+	write("package-lock.json", "{}\n")
+	write("huge.go", "package main\n"+strings.Repeat("// filler\n", maxWorkingTreeFileSize/10+1))
+	all, err = UncommittedCommit(repo, false, nil)
+	if err != nil {
+		t.Fatalf("UncommittedCommit failed: %v", err)
+	}
+	if got, want := changed(all), []string{"huge.go", "main.go", "pkg-a/a.go", "pkg/new.go", "untracked.go"}; !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	huge, err := all.File("huge.go")
+	if err != nil {
+		t.Fatalf("Failed to read the stub: %v", err)
+	}
+	if binary, err := huge.IsBinary(); err != nil || !binary || huge.Size > 100 {
+		t.Errorf("expected a small binary stub, got %d bytes (binary: %v, %v)", huge.Size, binary, err)
+	}
+}
@@ -0,0 +1,101 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// Verbosity is how long a verdict's reasoning is asked to be
+type Verbosity string
+
+const (
+	// VerbosityShort asks for one or two sentences, and cuts longer
+	// reasoning to them, for MCP payloads and reports over many commits
+	VerbosityShort Verbosity = "short"
+
+	// VerbosityStandard asks for a concise summary, as the prompt does
+	VerbosityStandard Verbosity = "standard"
+
+	// VerbosityVerbose asks for the whole chain of reasoning: the
+	// hypotheses, the values traced, and the evidence for and against
+	VerbosityVerbose Verbosity = "verbose"
+)
+
+// shortReasoningSentences is how many sentences VerbosityShort keeps
+const shortReasoningSentences = 2
+
+// verbosityInstructions extend the prompt's output format for the
+// verbosities other than VerbosityStandard
+var verbosityInstructions = map[Verbosity]string{
+	VerbosityShort:   `REASONING LENGTH: Keep the "reasoning" field of the JSON to one or two sentences: the decisive evidence and the verdict.`,
+	VerbosityVerbose: `REASONING LENGTH: Make the "reasoning" field of the JSON a detailed account of your analysis: the hypotheses you considered, how the values in the bug description trace through the diffs, the evidence for and against this commit, and why it outweighs the rest.`,
+}
+
+// ParseVerbosity parses "short", "standard", or "verbose"; empty is
+// VerbosityStandard
+func ParseVerbosity(s string) (Verbosity, error) {
+	switch v := Verbosity(strings.ToLower(strings.TrimSpace(s))); v {
+	case "":
+		return VerbosityStandard, nil
+	case VerbosityShort, VerbosityStandard, VerbosityVerbose:
+		return v, nil
+	default:
+		return "", fmt.Errorf("must be short, standard, or verbose, got %q", s)
+	}
+}
+
+// Model returns model asking for reasoning of v's length. The instruction
+// is appended to the prompt's last text part, so that a ContextCache
+// still finds its shared prompt at the start of a single part.
+func (v Verbosity) Model(model LLMModel) LLMModel {
+	instruction, ok := verbosityInstructions[v]
+	if !ok {
+		return model
+	}
+	return &verbosityModel{model: model, instruction: instruction}
+}
+
+// Trim cuts reasoning to v's length: the first sentences for
+// VerbosityShort, unchanged otherwise
+func (v Verbosity) Trim(reasoning string) string {
+	if v != VerbosityShort {
+		return reasoning
+	}
+	sentences := 0
+	for i, r := range reasoning {
+		if r != '.' && r != '!' && r != '?' {
+			continue
+		}
+		next := reasoning[i+1:]
+		if next != "" && !unicode.IsSpace(rune(next[0])) {
+			// Not a sentence end, as in "v1.2" or "pkg.Func"
+			continue
+		}
+		if sentences++; sentences == shortReasoningSentences {
+			return strings.TrimSpace(reasoning[:i+1])
+		}
+	}
+	return reasoning
+}
+
+// verbosityModel appends a reasoning length instruction to prompts
+type verbosityModel struct {
+	model       LLMModel
+	instruction string
+}
+
+// GenerateContent implements LLMModel
+func (m *verbosityModel) GenerateContent(ctx context.Context, parts ...genai.Part) (*genai.GenerateContentResponse, error) {
+	for i := len(parts) - 1; i >= 0; i-- {
+		if text, ok := parts[i].(genai.Text); ok {
+			parts = append([]genai.Part(nil), parts...)
+			parts[i] = genai.Text(string(text) + "\n\n" + m.instruction)
+			break
+		}
+	}
+	return m.model.GenerateContent(ctx, parts...)
+}
@@ -0,0 +1,72 @@
+package analyzer
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+func TestParseVerbosity(t *testing.T) {
+	for in, expected := range map[string]Verbosity{"": VerbosityStandard, "short": VerbosityShort, " Verbose ": VerbosityVerbose} {
+		if v, err := ParseVerbosity(in); err != nil || v != expected {
+			t.Errorf("ParseVerbosity(%q) = %q, %v; expected %q", in, v, err, expected)
+		}
+	}
+	if _, err := ParseVerbosity("terse"); err == nil {
+		t.Error("Expected an error for an unknown verbosity")
+	}
+}
+
+func TestVerbosityTrim(t *testing.T) {
+	reasoning := "The commit calls pkg.Parse on v1.2 input. It drops the guard! Later commits keep it."
+	if got := VerbosityShort.Trim(reasoning); got != "The commit calls pkg.Parse on v1.2 input. It drops the guard!" {
+		t.Errorf("Expected the first two sentences, got %q", got)
+	}
+	if got := VerbosityShort.Trim("One sentence only"); got != "One sentence only" {
+		t.Errorf("Expected short reasoning kept, got %q", got)
+	}
+	if got := VerbosityVerbose.Trim(reasoning); got != reasoning {
+		t.Errorf("Expected verbose reasoning kept whole, got %q", got)
+	}
+}
+
+func TestVerbosityModel(t *testing.T) {
+	model := &promptModel{marker: "REASONING LENGTH"}
+	if VerbosityStandard.Model(model) != LLMModel(model) {
+		t.Error("Expected the standard verbosity to leave the model unwrapped")
+	}
+
+	resp, err := VerbosityShort.Model(model).GenerateContent(context.Background(), genai.Text("prompt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// promptModel rates prompts containing its marker HIGH
+	if text := resp.Candidates[0].Content.Parts[0].(genai.Text); !strings.Contains(string(text), "HIGH") {
+		t.Errorf("Expected the instruction appended to the prompt, got %s", text)
+	}
+}
+
+func TestRunPipelineVerbosity(t *testing.T) {
+	repo := createTestRepo(t, []struct{ path, content string }{
+		{"main.go", "package main\n"},
+		{"main.go", "package main\n\nfunc main() {}\n"},
+	})
+	commits, head, err := CollectCommits(repo, AnalysisOptions{NumCommits: 1})
+	if err != nil {
+		t.Fatalf("CollectCommits failed: %v", err)
+	}
+	model := &mockModel{response: `{"probability": "HIGH", "reasoning": "main changed. It panics. Nothing else matters."}`}
+
+	results, err := RunPipeline(context.Background(), repo, commits, head, model, AnalysisOptions{
+		ErrorMessage: "test error",
+		Verbosity:    VerbosityShort,
+	})
+	if err != nil {
+		t.Fatalf("RunPipeline failed: %v", err)
+	}
+	if r := results[0].Result; r == nil || r.Reasoning != "main changed. It panics." {
+		t.Errorf("Expected the reasoning cut to two sentences, got %+v", r)
+	}
+}
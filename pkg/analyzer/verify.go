@@ -0,0 +1,216 @@
+package analyzer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kerneldump/git-dual-context/pkg/worktree"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Verification verdicts, the "verdict" of a result's verification
+const (
+	// VerifyConfirmed: the command fails at the commit and passes at its
+	// parent, so the commit introduced the failure
+	VerifyConfirmed = "confirmed"
+
+	// VerifyRefuted: the command passes at the commit, or already fails
+	// at its parent
+	VerifyRefuted = "refuted"
+
+	// VerifyInconclusive: the command could not be run to completion at
+	// the commit or its parent, or the commit has no parent
+	VerifyInconclusive = "inconclusive"
+)
+
+// Outcomes of one run of the verification command
+const (
+	RunPass  = "pass"
+	RunFail  = "fail"
+	RunError = "error"
+)
+
+// Defaults of VerifyOptions
+const (
+	DefaultVerifyTop     = 3
+	DefaultVerifyTimeout = 10 * time.Minute
+)
+
+// maxVerifyOutput bounds the output kept of a run that did not pass
+const maxVerifyOutput = 2000
+
+// VerifyOptions configures the verification of suspects by running a
+// command, such as a test, in checkouts of them (see Verify)
+type VerifyOptions struct {
+	// Command is run with sh -c at the root of each checkout; a zero exit
+	// status passes (empty: no verification)
+	Command string
+
+	// Top is how many of the most suspicious HIGH and MEDIUM commits are
+	// verified (0: DefaultVerifyTop)
+	Top int
+
+	// Timeout bounds each run of Command (0: DefaultVerifyTimeout)
+	Timeout time.Duration
+
+	// Worktrees checks out the commits Command runs at (nil: a Manager of
+	// each Verify call's own)
+	Worktrees *worktree.Manager
+}
+
+// Verification is the outcome of running a command at a suspect commit
+// and at its first parent
+type Verification struct {
+	Command string `json:"command"`
+	Verdict string `json:"verdict"`
+
+	// Commit and Parent are the outcomes at the commit and at its parent,
+	// RunPass, RunFail, or RunError (Parent is empty if not run)
+	Commit string `json:"commit"`
+	Parent string `json:"parent,omitempty"`
+
+	// Before is the LLM's verdict, which a confirmation raises to HIGH and
+	// a refutation lowers to LOW
+	Before Probability `json:"before"`
+
+	// Output is the end of the output at the commit, or of the error
+	// keeping the command from running, if it did not pass
+	Output string `json:"output,omitempty"`
+}
+
+// Verify runs opts.Command in a checkout of c and, if it fails there, in
+// one of c's first parent. Failing at c but passing at its parent
+// confirms c introduced the failure; passing at c, or failing at both,
+// refutes it.
+func Verify(ctx context.Context, c *object.Commit, opts VerifyOptions) *Verification {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultVerifyTimeout
+	}
+	if opts.Worktrees == nil {
+		opts.Worktrees = worktree.NewManager("")
+		defer opts.Worktrees.Close()
+	}
+	v := &Verification{Command: opts.Command, Verdict: VerifyInconclusive}
+	v.Commit, v.Output = runAt(ctx, c, opts)
+	switch v.Commit {
+	case RunPass:
+		v.Verdict = VerifyRefuted
+		return v
+	case RunError:
+		return v
+	}
+
+	parent, err := c.Parent(0)
+	if errors.Is(err, object.ErrParentNotFound) {
+		return v
+	}
+	if err != nil {
+		v.Parent = RunError
+		return v
+	}
+	v.Parent, _ = runAt(ctx, parent, opts)
+	switch v.Parent {
+	case RunPass:
+		v.Verdict = VerifyConfirmed
+	case RunFail:
+		v.Verdict = VerifyRefuted
+	}
+	return v
+}
+
+// runAt runs opts.Command in a checkout of c, returning its outcome and,
+// unless it passed, the end of its output
+func runAt(ctx context.Context, c *object.Commit, opts VerifyOptions) (string, string) {
+	wt, err := opts.Worktrees.Checkout(c)
+	if err != nil {
+		return RunError, err.Error()
+	}
+	defer wt.Release()
+
+	runCtx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+	cmd := exec.CommandContext(runCtx, "sh", "-c", opts.Command)
+	cmd.Dir = wt.Dir
+	cmd.WaitDelay = 5 * time.Second
+	out, err := cmd.CombinedOutput()
+
+	var exitErr *exec.ExitError
+	switch {
+	case runCtx.Err() != nil:
+		return RunError, tail(string(out)+fmt.Sprintf("\n(stopped: %v)", context.Cause(runCtx)), maxVerifyOutput)
+	case err == nil:
+		return RunPass, ""
+	case errors.As(err, &exitErr) && exitErr.ExitCode() != 126 && exitErr.ExitCode() != 127:
+		return RunFail, tail(string(out), maxVerifyOutput)
+	default:
+		// The shell could not run the command (126, 127) or sh itself
+		// could not start
+		return RunError, tail(string(out)+"\n"+err.Error(), maxVerifyOutput)
+	}
+}
+
+// tail returns the last n bytes of s, from the start of a line
+func tail(s string, n int) string {
+	s = strings.TrimSpace(s)
+	if len(s) <= n {
+		return s
+	}
+	s = s[len(s)-n:]
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[i+1:]
+	}
+	return s
+}
+
+// ApplyVerification attaches v to the result and raises its verdict to
+// HIGH if v confirms it or lowers it to LOW if v refutes it, rescoring it
+// with w
+func (ar *AnalysisResult) ApplyVerification(v *Verification, w ScoreWeights) {
+	v.Before = ar.Probability
+	ar.Verification = v
+	switch v.Verdict {
+	case VerifyConfirmed:
+		ar.Probability = ProbHigh
+	case VerifyRefuted:
+		ar.Probability = ProbLow
+	}
+	ar.Score(w)
+}
+
+// verifySuspects verifies the opts.Verify.Top most suspicious HIGH and
+// MEDIUM results, one at a time, in checkouts of their commits; a commit
+// checked out as the parent of one suspect is reused for another
+func (opts *AnalysisOptions) verifySuspects(ctx context.Context, commits []*object.Commit, results []CommitAnalysisResult) {
+	verify := opts.Verify
+	if verify.Top <= 0 {
+		verify.Top = DefaultVerifyTop
+	}
+	if verify.Worktrees == nil {
+		verify.Worktrees = worktree.NewManager("")
+		defer verify.Worktrees.Close()
+	}
+	var suspects []int
+	for i, r := range results {
+		if r.Error == nil && r.Result != nil && !r.Result.Skipped &&
+			(r.Result.Probability == ProbHigh || r.Result.Probability == ProbMedium) {
+			suspects = append(suspects, i)
+		}
+	}
+	sort.SliceStable(suspects, func(a, b int) bool {
+		return results[suspects[a]].Result.Rank() > results[suspects[b]].Result.Rank()
+	})
+
+	for _, i := range suspects[:min(verify.Top, len(suspects))] {
+		if ctx.Err() != nil {
+			return
+		}
+		opts.progress(fmt.Sprintf("Verifying commit %s: %s", results[i].Hash[:8], opts.Verify.Command))
+		results[i].Result.ApplyVerification(Verify(ctx, commits[i], verify), opts.ScoreWeights)
+	}
+}
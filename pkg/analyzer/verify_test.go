@@ -0,0 +1,120 @@
+package analyzer
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// verifyTestRepo creates a repository whose check.go passes the check in
+// its first two commits and fails it from the third on, and returns its
+// commits, newest first, and HEAD
+func verifyTestRepo(t *testing.T) (*git.Repository, []*object.Commit, *object.Commit) {
+	t.Helper()
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	repo := createTestRepo(t, []struct{ path, content string }{
+		{"check.go", "package main\n\nconst state = \"good\"\n"},
+		{"util.go", "package main\n"},
+		{"check.go", "package main\n\nconst state = \"bad\"\n"},
+		{"main.go", "package main\n\nfunc main() {}\n"},
+	})
+	commits, head, err := CollectCommits(repo, AnalysisOptions{NumCommits: 4})
+	if err != nil {
+		t.Fatalf("CollectCommits failed: %v", err)
+	}
+	return repo, commits, head
+}
+
+func TestVerify(t *testing.T) {
+	_, commits, _ := verifyTestRepo(t)
+	check := VerifyOptions{Command: "echo checking; grep -q good check.go"}
+
+	tests := []struct {
+		name               string
+		commit             int
+		verdict            string
+		atCommit, atParent string
+	}{
+		{"passes at the commit", 2, VerifyRefuted, RunPass, ""},
+		{"fails from the commit on", 1, VerifyConfirmed, RunFail, RunPass},
+		{"fails before the commit", 0, VerifyRefuted, RunFail, RunFail},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := Verify(context.Background(), commits[tt.commit], check)
+			if v.Verdict != tt.verdict || v.Commit != tt.atCommit || v.Parent != tt.atParent {
+				t.Errorf("Expected %s (commit %s, parent %q), got %+v", tt.verdict, tt.atCommit, tt.atParent, v)
+			}
+			if tt.atCommit == RunFail && v.Output != "checking" {
+				t.Errorf("Expected the output of the failing run, got %q", v.Output)
+			}
+		})
+	}
+
+	v := Verify(context.Background(), commits[0], VerifyOptions{Command: "no-such-command-gdc"})
+	if v.Verdict != VerifyInconclusive || v.Commit != RunError || !strings.Contains(v.Output, "not found") {
+		t.Errorf("Expected a command that cannot run to be inconclusive, got %+v", v)
+	}
+	v = Verify(context.Background(), commits[0], VerifyOptions{Command: "exec sleep 5", Timeout: 50 * time.Millisecond})
+	if v.Verdict != VerifyInconclusive || v.Commit != RunError || !strings.Contains(v.Output, "deadline exceeded") {
+		t.Errorf("Expected a timed-out command to be inconclusive, got %+v", v)
+	}
+}
+
+func TestRunPipelineVerify(t *testing.T) {
+	repo, commits, head := verifyTestRepo(t)
+	model := &mockModel{response: `{"probability": "MEDIUM", "reasoning": "mock"}`}
+
+	var emitted []CommitAnalysisResult
+	results, err := RunPipeline(context.Background(), repo, commits, head, model, AnalysisOptions{
+		ErrorMessage: "check fails",
+		Verify:       VerifyOptions{Command: "grep -q good check.go", Top: 3},
+		OnResult: func(r CommitAnalysisResult) {
+			emitted = append(emitted, r)
+		},
+	})
+	if err != nil {
+		t.Fatalf("RunPipeline failed: %v", err)
+	}
+	if len(emitted) != len(results) {
+		t.Fatalf("Expected %d results passed to OnResult, got %d", len(results), len(emitted))
+	}
+
+	verified := 0
+	for i, r := range emitted {
+		if r.Index != i {
+			t.Errorf("Expected results in commit order, got %d at %d", r.Index, i)
+		}
+		v := r.Result.Verification
+		if v == nil {
+			if r.Result.Probability != ProbMedium {
+				t.Errorf("Expected unverified commit %d to keep its verdict, got %s", i, r.Result.Probability)
+			}
+			continue
+		}
+		verified++
+		if v.Before != ProbMedium {
+			t.Errorf("Expected the LLM's verdict kept, got %s", v.Before)
+		}
+		want := ProbLow
+		if i == 1 {
+			want = ProbHigh
+		}
+		if r.Result.Probability != want {
+			t.Errorf("Expected commit %d %s after %s verification, got %s", i, want, v.Verdict, r.Result.Probability)
+		}
+	}
+	if verified != 3 {
+		t.Errorf("Expected the 3 most suspicious commits verified, got %d", verified)
+	}
+	if emitted[1].Result.Verification == nil {
+		t.Errorf("Expected the culprit among the verified commits")
+	}
+}
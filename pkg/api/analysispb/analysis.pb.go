@@ -0,0 +1,545 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        v5.29.3
+// source: analysis/v1/analysis.proto
+
+package analysispb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// AnalyzeRequest mirrors the REST job request.
+type AnalyzeRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Path to a local git repository.
+	RepoPath string `protobuf:"bytes,1,opt,name=repo_path,json=repoPath,proto3" json:"repo_path,omitempty"`
+	// Bug description or error message to diagnose.
+	ErrorMessage string `protobuf:"bytes,2,opt,name=error_message,json=errorMessage,proto3" json:"error_message,omitempty"`
+	// Number of recent commits to analyze (default from config).
+	NumCommits int32 `protobuf:"varint,3,opt,name=num_commits,json=numCommits,proto3" json:"num_commits,omitempty"`
+	// Branch to analyze (default: current HEAD).
+	Branch string `protobuf:"bytes,4,opt,name=branch,proto3" json:"branch,omitempty"`
+	// Number of concurrent LLM calls (default from config).
+	Concurrency   int32 `protobuf:"varint,5,opt,name=concurrency,proto3" json:"concurrency,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AnalyzeRequest) Reset() {
+	*x = AnalyzeRequest{}
+	mi := &file_analysis_v1_analysis_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AnalyzeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnalyzeRequest) ProtoMessage() {}
+
+func (x *AnalyzeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_analysis_v1_analysis_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnalyzeRequest.ProtoReflect.Descriptor instead.
+func (*AnalyzeRequest) Descriptor() ([]byte, []int) {
+	return file_analysis_v1_analysis_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *AnalyzeRequest) GetRepoPath() string {
+	if x != nil {
+		return x.RepoPath
+	}
+	return ""
+}
+
+func (x *AnalyzeRequest) GetErrorMessage() string {
+	if x != nil {
+		return x.ErrorMessage
+	}
+	return ""
+}
+
+func (x *AnalyzeRequest) GetNumCommits() int32 {
+	if x != nil {
+		return x.NumCommits
+	}
+	return 0
+}
+
+func (x *AnalyzeRequest) GetBranch() string {
+	if x != nil {
+		return x.Branch
+	}
+	return ""
+}
+
+func (x *AnalyzeRequest) GetConcurrency() int32 {
+	if x != nil {
+		return x.Concurrency
+	}
+	return 0
+}
+
+// AnalyzeResult is a single streamed record.
+type AnalyzeResult struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Identifier of the job producing this record.
+	JobId string `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	// Types that are valid to be assigned to Record:
+	//
+	//	*AnalyzeResult_Result
+	//	*AnalyzeResult_Log
+	//	*AnalyzeResult_Summary
+	Record        isAnalyzeResult_Record `protobuf_oneof:"record"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AnalyzeResult) Reset() {
+	*x = AnalyzeResult{}
+	mi := &file_analysis_v1_analysis_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AnalyzeResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnalyzeResult) ProtoMessage() {}
+
+func (x *AnalyzeResult) ProtoReflect() protoreflect.Message {
+	mi := &file_analysis_v1_analysis_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnalyzeResult.ProtoReflect.Descriptor instead.
+func (*AnalyzeResult) Descriptor() ([]byte, []int) {
+	return file_analysis_v1_analysis_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *AnalyzeResult) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+func (x *AnalyzeResult) GetRecord() isAnalyzeResult_Record {
+	if x != nil {
+		return x.Record
+	}
+	return nil
+}
+
+func (x *AnalyzeResult) GetResult() *CommitResult {
+	if x != nil {
+		if x, ok := x.Record.(*AnalyzeResult_Result); ok {
+			return x.Result
+		}
+	}
+	return nil
+}
+
+func (x *AnalyzeResult) GetLog() *LogEntry {
+	if x != nil {
+		if x, ok := x.Record.(*AnalyzeResult_Log); ok {
+			return x.Log
+		}
+	}
+	return nil
+}
+
+func (x *AnalyzeResult) GetSummary() *Summary {
+	if x != nil {
+		if x, ok := x.Record.(*AnalyzeResult_Summary); ok {
+			return x.Summary
+		}
+	}
+	return nil
+}
+
+type isAnalyzeResult_Record interface {
+	isAnalyzeResult_Record()
+}
+
+type AnalyzeResult_Result struct {
+	Result *CommitResult `protobuf:"bytes,2,opt,name=result,proto3,oneof"`
+}
+
+type AnalyzeResult_Log struct {
+	Log *LogEntry `protobuf:"bytes,3,opt,name=log,proto3,oneof"`
+}
+
+type AnalyzeResult_Summary struct {
+	Summary *Summary `protobuf:"bytes,4,opt,name=summary,proto3,oneof"`
+}
+
+func (*AnalyzeResult_Result) isAnalyzeResult_Record() {}
+
+func (*AnalyzeResult_Log) isAnalyzeResult_Record() {}
+
+func (*AnalyzeResult_Summary) isAnalyzeResult_Record() {}
+
+// CommitResult is the verdict for a single commit.
+type CommitResult struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Hash    string                 `protobuf:"bytes,1,opt,name=hash,proto3" json:"hash,omitempty"`
+	Message string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	// One of HIGH, MEDIUM, LOW.
+	Probability   string `protobuf:"bytes,3,opt,name=probability,proto3" json:"probability,omitempty"`
+	Reasoning     string `protobuf:"bytes,4,opt,name=reasoning,proto3" json:"reasoning,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CommitResult) Reset() {
+	*x = CommitResult{}
+	mi := &file_analysis_v1_analysis_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CommitResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CommitResult) ProtoMessage() {}
+
+func (x *CommitResult) ProtoReflect() protoreflect.Message {
+	mi := &file_analysis_v1_analysis_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CommitResult.ProtoReflect.Descriptor instead.
+func (*CommitResult) Descriptor() ([]byte, []int) {
+	return file_analysis_v1_analysis_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *CommitResult) GetHash() string {
+	if x != nil {
+		return x.Hash
+	}
+	return ""
+}
+
+func (x *CommitResult) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *CommitResult) GetProbability() string {
+	if x != nil {
+		return x.Probability
+	}
+	return ""
+}
+
+func (x *CommitResult) GetReasoning() string {
+	if x != nil {
+		return x.Reasoning
+	}
+	return ""
+}
+
+// LogEntry reports skipped commits and per-commit errors.
+type LogEntry struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Level         string                 `protobuf:"bytes,1,opt,name=level,proto3" json:"level,omitempty"`
+	Msg           string                 `protobuf:"bytes,2,opt,name=msg,proto3" json:"msg,omitempty"`
+	Timestamp     string                 `protobuf:"bytes,3,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *LogEntry) Reset() {
+	*x = LogEntry{}
+	mi := &file_analysis_v1_analysis_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LogEntry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LogEntry) ProtoMessage() {}
+
+func (x *LogEntry) ProtoReflect() protoreflect.Message {
+	mi := &file_analysis_v1_analysis_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LogEntry.ProtoReflect.Descriptor instead.
+func (*LogEntry) Descriptor() ([]byte, []int) {
+	return file_analysis_v1_analysis_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *LogEntry) GetLevel() string {
+	if x != nil {
+		return x.Level
+	}
+	return ""
+}
+
+func (x *LogEntry) GetMsg() string {
+	if x != nil {
+		return x.Msg
+	}
+	return ""
+}
+
+func (x *LogEntry) GetTimestamp() string {
+	if x != nil {
+		return x.Timestamp
+	}
+	return ""
+}
+
+// Summary is always the last record of a successful stream.
+type Summary struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Total         int32                  `protobuf:"varint,1,opt,name=total,proto3" json:"total,omitempty"`
+	High          int32                  `protobuf:"varint,2,opt,name=high,proto3" json:"high,omitempty"`
+	Medium        int32                  `protobuf:"varint,3,opt,name=medium,proto3" json:"medium,omitempty"`
+	Low           int32                  `protobuf:"varint,4,opt,name=low,proto3" json:"low,omitempty"`
+	Skipped       int32                  `protobuf:"varint,5,opt,name=skipped,proto3" json:"skipped,omitempty"`
+	Errors        int32                  `protobuf:"varint,6,opt,name=errors,proto3" json:"errors,omitempty"`
+	Duration      string                 `protobuf:"bytes,7,opt,name=duration,proto3" json:"duration,omitempty"`
+	Model         string                 `protobuf:"bytes,8,opt,name=model,proto3" json:"model,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Summary) Reset() {
+	*x = Summary{}
+	mi := &file_analysis_v1_analysis_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Summary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Summary) ProtoMessage() {}
+
+func (x *Summary) ProtoReflect() protoreflect.Message {
+	mi := &file_analysis_v1_analysis_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Summary.ProtoReflect.Descriptor instead.
+func (*Summary) Descriptor() ([]byte, []int) {
+	return file_analysis_v1_analysis_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Summary) GetTotal() int32 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *Summary) GetHigh() int32 {
+	if x != nil {
+		return x.High
+	}
+	return 0
+}
+
+func (x *Summary) GetMedium() int32 {
+	if x != nil {
+		return x.Medium
+	}
+	return 0
+}
+
+func (x *Summary) GetLow() int32 {
+	if x != nil {
+		return x.Low
+	}
+	return 0
+}
+
+func (x *Summary) GetSkipped() int32 {
+	if x != nil {
+		return x.Skipped
+	}
+	return 0
+}
+
+func (x *Summary) GetErrors() int32 {
+	if x != nil {
+		return x.Errors
+	}
+	return 0
+}
+
+func (x *Summary) GetDuration() string {
+	if x != nil {
+		return x.Duration
+	}
+	return ""
+}
+
+func (x *Summary) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+var File_analysis_v1_analysis_proto protoreflect.FileDescriptor
+
+const file_analysis_v1_analysis_proto_rawDesc = "" +
+	"\n" +
+	"\x1aanalysis/v1/analysis.proto\x12\x1agitdualcontext.analysis.v1\"\xad\x01\n" +
+	"\x0eAnalyzeRequest\x12\x1b\n" +
+	"\trepo_path\x18\x01 \x01(\tR\brepoPath\x12#\n" +
+	"\rerror_message\x18\x02 \x01(\tR\ferrorMessage\x12\x1f\n" +
+	"\vnum_commits\x18\x03 \x01(\x05R\n" +
+	"numCommits\x12\x16\n" +
+	"\x06branch\x18\x04 \x01(\tR\x06branch\x12 \n" +
+	"\vconcurrency\x18\x05 \x01(\x05R\vconcurrency\"\xef\x01\n" +
+	"\rAnalyzeResult\x12\x15\n" +
+	"\x06job_id\x18\x01 \x01(\tR\x05jobId\x12B\n" +
+	"\x06result\x18\x02 \x01(\v2(.gitdualcontext.analysis.v1.CommitResultH\x00R\x06result\x128\n" +
+	"\x03log\x18\x03 \x01(\v2$.gitdualcontext.analysis.v1.LogEntryH\x00R\x03log\x12?\n" +
+	"\asummary\x18\x04 \x01(\v2#.gitdualcontext.analysis.v1.SummaryH\x00R\asummaryB\b\n" +
+	"\x06record\"|\n" +
+	"\fCommitResult\x12\x12\n" +
+	"\x04hash\x18\x01 \x01(\tR\x04hash\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12 \n" +
+	"\vprobability\x18\x03 \x01(\tR\vprobability\x12\x1c\n" +
+	"\treasoning\x18\x04 \x01(\tR\treasoning\"P\n" +
+	"\bLogEntry\x12\x14\n" +
+	"\x05level\x18\x01 \x01(\tR\x05level\x12\x10\n" +
+	"\x03msg\x18\x02 \x01(\tR\x03msg\x12\x1c\n" +
+	"\ttimestamp\x18\x03 \x01(\tR\ttimestamp\"\xc1\x01\n" +
+	"\aSummary\x12\x14\n" +
+	"\x05total\x18\x01 \x01(\x05R\x05total\x12\x12\n" +
+	"\x04high\x18\x02 \x01(\x05R\x04high\x12\x16\n" +
+	"\x06medium\x18\x03 \x01(\x05R\x06medium\x12\x10\n" +
+	"\x03low\x18\x04 \x01(\x05R\x03low\x12\x18\n" +
+	"\askipped\x18\x05 \x01(\x05R\askipped\x12\x16\n" +
+	"\x06errors\x18\x06 \x01(\x05R\x06errors\x12\x1a\n" +
+	"\bduration\x18\a \x01(\tR\bduration\x12\x14\n" +
+	"\x05model\x18\b \x01(\tR\x05model2u\n" +
+	"\x0fAnalysisService\x12b\n" +
+	"\aAnalyze\x12*.gitdualcontext.analysis.v1.AnalyzeRequest\x1a).gitdualcontext.analysis.v1.AnalyzeResult0\x01B;Z9github.com/kerneldump/git-dual-context/pkg/api/analysispbb\x06proto3"
+
+var (
+	file_analysis_v1_analysis_proto_rawDescOnce sync.Once
+	file_analysis_v1_analysis_proto_rawDescData []byte
+)
+
+func file_analysis_v1_analysis_proto_rawDescGZIP() []byte {
+	file_analysis_v1_analysis_proto_rawDescOnce.Do(func() {
+		file_analysis_v1_analysis_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_analysis_v1_analysis_proto_rawDesc), len(file_analysis_v1_analysis_proto_rawDesc)))
+	})
+	return file_analysis_v1_analysis_proto_rawDescData
+}
+
+var file_analysis_v1_analysis_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_analysis_v1_analysis_proto_goTypes = []any{
+	(*AnalyzeRequest)(nil), // 0: gitdualcontext.analysis.v1.AnalyzeRequest
+	(*AnalyzeResult)(nil),  // 1: gitdualcontext.analysis.v1.AnalyzeResult
+	(*CommitResult)(nil),   // 2: gitdualcontext.analysis.v1.CommitResult
+	(*LogEntry)(nil),       // 3: gitdualcontext.analysis.v1.LogEntry
+	(*Summary)(nil),        // 4: gitdualcontext.analysis.v1.Summary
+}
+var file_analysis_v1_analysis_proto_depIdxs = []int32{
+	2, // 0: gitdualcontext.analysis.v1.AnalyzeResult.result:type_name -> gitdualcontext.analysis.v1.CommitResult
+	3, // 1: gitdualcontext.analysis.v1.AnalyzeResult.log:type_name -> gitdualcontext.analysis.v1.LogEntry
+	4, // 2: gitdualcontext.analysis.v1.AnalyzeResult.summary:type_name -> gitdualcontext.analysis.v1.Summary
+	0, // 3: gitdualcontext.analysis.v1.AnalysisService.Analyze:input_type -> gitdualcontext.analysis.v1.AnalyzeRequest
+	1, // 4: gitdualcontext.analysis.v1.AnalysisService.Analyze:output_type -> gitdualcontext.analysis.v1.AnalyzeResult
+	4, // [4:5] is the sub-list for method output_type
+	3, // [3:4] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_analysis_v1_analysis_proto_init() }
+func file_analysis_v1_analysis_proto_init() {
+	if File_analysis_v1_analysis_proto != nil {
+		return
+	}
+	file_analysis_v1_analysis_proto_msgTypes[1].OneofWrappers = []any{
+		(*AnalyzeResult_Result)(nil),
+		(*AnalyzeResult_Log)(nil),
+		(*AnalyzeResult_Summary)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_analysis_v1_analysis_proto_rawDesc), len(file_analysis_v1_analysis_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   5,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_analysis_v1_analysis_proto_goTypes,
+		DependencyIndexes: file_analysis_v1_analysis_proto_depIdxs,
+		MessageInfos:      file_analysis_v1_analysis_proto_msgTypes,
+	}.Build()
+	File_analysis_v1_analysis_proto = out.File
+	file_analysis_v1_analysis_proto_goTypes = nil
+	file_analysis_v1_analysis_proto_depIdxs = nil
+}
@@ -0,0 +1,153 @@
+// Package audit records every LLM interaction in an append-only JSONL file.
+//
+// Each record captures the full prompt sent to the provider, the model name,
+// a SHA-256 hash of the response text, and the reported token counts. The log
+// lets operators prove exactly which source code left the machine and when,
+// without storing model output verbatim.
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kerneldump/git-dual-context/pkg/analyzer"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// Record is a single audited LLM call
+type Record struct {
+	Timestamp    time.Time `json:"timestamp"`
+	Model        string    `json:"model"`
+	Prompt       string    `json:"prompt"`
+	PromptHash   string    `json:"prompt_sha256"`
+	ResponseHash string    `json:"response_sha256,omitempty"`
+	PromptTokens int32     `json:"prompt_tokens"`
+	OutputTokens int32     `json:"output_tokens"`
+	DurationMs   int64     `json:"duration_ms"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// Log is an append-only JSONL audit log, safe for concurrent use
+type Log struct {
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+}
+
+// Open opens (creating if necessary) the audit log at path for appending.
+// A leading "~/" is expanded to the user's home directory. The file is
+// created with owner-only permissions since it contains source code.
+func Open(path string) (*Log, error) {
+	if path == "" {
+		return nil, fmt.Errorf("audit log path cannot be empty")
+	}
+	if strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get home directory: %w", err)
+		}
+		path = filepath.Join(home, path[2:])
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create audit log directory: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return &Log{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+// Write appends a record and syncs it to disk
+func (l *Log) Write(rec Record) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.enc.Encode(rec); err != nil {
+		return fmt.Errorf("failed to write audit record: %w", err)
+	}
+	return l.f.Sync()
+}
+
+// Close closes the underlying file
+func (l *Log) Close() error {
+	return l.f.Close()
+}
+
+// Model wraps an analyzer.LLMModel so that every GenerateContent call is
+// recorded in the audit log, including failed calls. If the record cannot
+// be written, the call fails so that no interaction goes unaudited.
+type Model struct {
+	model analyzer.LLMModel
+	name  string
+	log   *Log
+}
+
+// Wrap returns model with auditing enabled. name is the model name
+// recorded with each interaction.
+func Wrap(model analyzer.LLMModel, name string, log *Log) *Model {
+	return &Model{model: model, name: name, log: log}
+}
+
+// GenerateContent implements analyzer.LLMModel
+func (m *Model) GenerateContent(ctx context.Context, parts ...genai.Part) (*genai.GenerateContentResponse, error) {
+	prompt := partsText(parts)
+	rec := Record{
+		Timestamp:  time.Now().UTC(),
+		Model:      m.name,
+		Prompt:     prompt,
+		PromptHash: hashString(prompt),
+	}
+
+	start := time.Now()
+	resp, err := m.model.GenerateContent(ctx, parts...)
+	rec.DurationMs = time.Since(start).Milliseconds()
+
+	if err != nil {
+		rec.Error = err.Error()
+	} else if resp != nil {
+		rec.ResponseHash = hashString(responseText(resp))
+		if resp.UsageMetadata != nil {
+			rec.PromptTokens = resp.UsageMetadata.PromptTokenCount
+			rec.OutputTokens = resp.UsageMetadata.CandidatesTokenCount
+		}
+	}
+
+	if werr := m.log.Write(rec); werr != nil {
+		return nil, werr
+	}
+	return resp, err
+}
+
+// partsText concatenates the text parts of a request
+func partsText(parts []genai.Part) string {
+	var sb strings.Builder
+	for _, p := range parts {
+		if txt, ok := p.(genai.Text); ok {
+			sb.WriteString(string(txt))
+		}
+	}
+	return sb.String()
+}
+
+// responseText concatenates the text parts of the first candidate
+func responseText(resp *genai.GenerateContentResponse) string {
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+		return ""
+	}
+	return partsText(resp.Candidates[0].Content.Parts)
+}
+
+func hashString(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,126 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// stubModel returns a fixed response or error
+type stubModel struct {
+	text string
+	err  error
+}
+
+func (m *stubModel) GenerateContent(ctx context.Context, parts ...genai.Part) (*genai.GenerateContentResponse, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &genai.GenerateContentResponse{
+		Candidates: []*genai.Candidate{
+			{Content: &genai.Content{Parts: []genai.Part{genai.Text(m.text)}}},
+		},
+		UsageMetadata: &genai.UsageMetadata{PromptTokenCount: 120, CandidatesTokenCount: 30},
+	}, nil
+}
+
+func readRecords(t *testing.T, path string) []Record {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open audit log: %v", err)
+	}
+	defer f.Close()
+
+	var recs []Record
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for scanner.Scan() {
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatalf("Invalid audit record %q: %v", scanner.Text(), err)
+		}
+		recs = append(recs, r)
+	}
+	return recs
+}
+
+func TestModelRecordsInteractions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "audit.jsonl")
+	log, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	m := Wrap(&stubModel{text: `{"probability": "HIGH"}`}, "gemini-test", log)
+	if _, err := m.GenerateContent(context.Background(), genai.Text("diff --git a/main.go")); err != nil {
+		t.Fatalf("GenerateContent failed: %v", err)
+	}
+
+	failing := Wrap(&stubModel{err: errors.New("quota exceeded")}, "gemini-test", log)
+	if _, err := failing.GenerateContent(context.Background(), genai.Text("second prompt")); err == nil {
+		t.Fatal("Expected provider error to be returned")
+	}
+	log.Close()
+
+	recs := readRecords(t, path)
+	if len(recs) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(recs))
+	}
+
+	r := recs[0]
+	if r.Model != "gemini-test" || r.Prompt != "diff --git a/main.go" {
+		t.Errorf("Unexpected record: %+v", r)
+	}
+	if r.PromptHash != hashString("diff --git a/main.go") {
+		t.Errorf("Unexpected prompt hash %q", r.PromptHash)
+	}
+	if r.ResponseHash != hashString(`{"probability": "HIGH"}`) {
+		t.Errorf("Unexpected response hash %q", r.ResponseHash)
+	}
+	if r.PromptTokens != 120 || r.OutputTokens != 30 {
+		t.Errorf("Expected 120/30 tokens, got %d/%d", r.PromptTokens, r.OutputTokens)
+	}
+
+	if recs[1].Error != "quota exceeded" || recs[1].ResponseHash != "" {
+		t.Errorf("Expected failed call to be recorded without response, got %+v", recs[1])
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("Expected 0600 permissions, got %o", perm)
+	}
+}
+
+func TestOpenAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	for i := 0; i < 2; i++ {
+		log, err := Open(path)
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		if err := log.Write(Record{Model: "m"}); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		log.Close()
+	}
+
+	if n := len(readRecords(t, path)); n != 2 {
+		t.Errorf("Expected 2 records after reopening, got %d", n)
+	}
+}
+
+func TestOpenEmptyPath(t *testing.T) {
+	if _, err := Open(""); err == nil {
+		t.Error("Expected error for empty path")
+	}
+}
@@ -0,0 +1,323 @@
+// Package batch sends LLM prompts through Gemini's Batch API, which
+// processes them asynchronously at half the price of interactive calls,
+// usually within minutes and at most within a day.
+//
+// Model implements analyzer.LLMModel: each GenerateContent call waits while
+// prompts from concurrent calls accumulate, and once none has arrived for a
+// short window all of them are submitted as one batch job, which is polled
+// until it finishes. Callers analyze commits as usual, only with enough
+// concurrency for all prompts of a run to be pending at once.
+package batch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/googleapi"
+)
+
+// DefaultEndpoint is the Gemini API the batches are submitted to
+const DefaultEndpoint = "https://generativelanguage.googleapis.com/v1beta"
+
+// Defaults for Options
+const (
+	DefaultWindow       = 2 * time.Second
+	DefaultPollInterval = 30 * time.Second
+)
+
+// maxInlineBytes keeps each batch under the API's 20 MB limit on requests
+// sent inline
+const maxInlineBytes = 18 << 20
+
+// Options configures a Model
+type Options struct {
+	// APIKey authenticates to the Gemini API (required)
+	APIKey string
+
+	// Model is the Gemini model name, with or without "models/" (required)
+	Model string
+
+	// Temperature is sent with every prompt
+	Temperature float32
+
+	// Window is how long after the latest prompt a batch is submitted
+	// (default: DefaultWindow)
+	Window time.Duration
+
+	// PollInterval is how often a submitted batch is checked (default:
+	// DefaultPollInterval)
+	PollInterval time.Duration
+
+	// Endpoint is the API base URL (default: DefaultEndpoint)
+	Endpoint string
+
+	// Client sends the API requests (default: http.DefaultClient)
+	Client *http.Client
+
+	// Logf, if set, receives progress messages
+	Logf func(format string, args ...any)
+}
+
+// Model submits prompts in batches. It is safe for concurrent use.
+type Model struct {
+	opts Options
+
+	mu      sync.Mutex
+	pending []*request
+	timer   *time.Timer
+}
+
+// request is one GenerateContent call waiting for its batch
+type request struct {
+	ctx    context.Context
+	prompt string
+	done   chan result
+}
+
+type result struct {
+	resp *genai.GenerateContentResponse
+	err  error
+}
+
+// New returns a Model with opts
+func New(opts Options) *Model {
+	if opts.Window <= 0 {
+		opts.Window = DefaultWindow
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = DefaultPollInterval
+	}
+	if opts.Endpoint == "" {
+		opts.Endpoint = DefaultEndpoint
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	opts.Model = strings.TrimPrefix(opts.Model, "models/")
+	return &Model{opts: opts}
+}
+
+// GenerateContent implements analyzer.LLMModel. It returns once the batch
+// holding the prompt has finished, or ctx is done.
+func (m *Model) GenerateContent(ctx context.Context, parts ...genai.Part) (*genai.GenerateContentResponse, error) {
+	var prompt strings.Builder
+	for _, p := range parts {
+		text, ok := p.(genai.Text)
+		if !ok {
+			return nil, fmt.Errorf("batch: only text prompts are supported, got %T", p)
+		}
+		prompt.WriteString(string(text))
+	}
+	req := &request{ctx: ctx, prompt: prompt.String(), done: make(chan result, 1)}
+
+	m.mu.Lock()
+	m.pending = append(m.pending, req)
+	if m.timer != nil {
+		m.timer.Stop()
+	}
+	m.timer = time.AfterFunc(m.opts.Window, m.flush)
+	m.mu.Unlock()
+
+	select {
+	case r := <-req.done:
+		return r.resp, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// flush submits the pending prompts, in as many batches as their size
+// requires
+func (m *Model) flush() {
+	m.mu.Lock()
+	reqs := m.pending
+	m.pending = nil
+	m.mu.Unlock()
+
+	for len(reqs) > 0 {
+		n, size := 0, 0
+		for n < len(reqs) && (n == 0 || size+len(reqs[n].prompt) <= maxInlineBytes) {
+			size += len(reqs[n].prompt)
+			n++
+		}
+		go m.run(reqs[:n])
+		reqs = reqs[n:]
+	}
+}
+
+// run submits reqs as one batch, waits for it, and answers each request.
+// The batch is cancelled once no request is waiting for it anymore.
+func (m *Model) run(reqs []*request) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		for _, r := range reqs {
+			select {
+			case <-r.ctx.Done():
+			case <-ctx.Done():
+				return
+			}
+		}
+		cancel()
+	}()
+
+	responses, err := m.process(ctx, reqs)
+	for i, r := range reqs {
+		switch {
+		case err != nil:
+			r.done <- result{err: err}
+		case responses[i] == nil:
+			r.done <- result{err: fmt.Errorf("batch: no response for prompt %d", i)}
+		default:
+			r.done <- *responses[i]
+		}
+	}
+}
+
+// process submits the batch and polls it until it finishes, returning the
+// responses by request index
+func (m *Model) process(ctx context.Context, reqs []*request) ([]*result, error) {
+	job, err := m.submit(ctx, reqs)
+	if err != nil {
+		return nil, err
+	}
+	m.logf("Submitted batch %s with %d prompts", job.Name, len(reqs))
+
+	ticker := time.NewTicker(m.opts.PollInterval)
+	defer ticker.Stop()
+	state := ""
+	for !job.Done {
+		select {
+		case <-ctx.Done():
+			// Nobody waits for the results; stop paying for them
+			cancelCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			if err := m.call(cancelCtx, http.MethodPost, job.Name+":cancel", struct{}{}, nil); err != nil {
+				m.logf("Failed to cancel batch %s: %v", job.Name, err)
+			}
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+		if err := m.call(ctx, http.MethodGet, job.Name, nil, &job); err != nil {
+			if ctx.Err() != nil {
+				continue
+			}
+			return nil, fmt.Errorf("batch: polling %s: %w", job.Name, err)
+		}
+		if job.Metadata.State != state {
+			state = job.Metadata.State
+			m.logf("Batch %s: %s", job.Name, state)
+		}
+	}
+	if job.Error != nil {
+		return nil, fmt.Errorf("batch %s failed: %s", job.Name, job.Error.Message)
+	}
+	if !strings.HasSuffix(job.Metadata.State, "_SUCCEEDED") {
+		return nil, fmt.Errorf("batch %s ended in state %s", job.Name, job.Metadata.State)
+	}
+
+	inlined, err := job.Response.responses()
+	if err != nil {
+		return nil, fmt.Errorf("batch %s: %w", job.Name, err)
+	}
+	results := make([]*result, len(reqs))
+	for i, r := range inlined {
+		// Responses carry the key they were submitted with; without one,
+		// they are in submission order
+		idx := i
+		if r.Metadata.Key != "" {
+			idx, err = strconv.Atoi(r.Metadata.Key)
+			if err != nil || idx < 0 || idx >= len(reqs) {
+				return nil, fmt.Errorf("batch %s: unexpected response key %q", job.Name, r.Metadata.Key)
+			}
+		}
+		if r.Error != nil {
+			results[idx] = &result{err: fmt.Errorf("gemini batch error %d: %s", r.Error.Code, r.Error.Message)}
+			continue
+		}
+		if r.Response == nil {
+			continue
+		}
+		results[idx] = &result{resp: r.Response.toGenai()}
+	}
+	return results, nil
+}
+
+// submit creates the batch job for reqs
+func (m *Model) submit(ctx context.Context, reqs []*request) (*operation, error) {
+	var body batchRequest
+	body.Batch.DisplayName = "git-dual-context"
+	for i, r := range reqs {
+		var item inlinedRequest
+		item.Request.Contents = []content{{Role: "user", Parts: []part{{Text: r.prompt}}}}
+		item.Request.GenerationConfig = &generationConfig{Temperature: m.opts.Temperature}
+		item.Metadata.Key = strconv.Itoa(i)
+		body.Batch.InputConfig.Requests.Requests = append(body.Batch.InputConfig.Requests.Requests, item)
+	}
+	var job operation
+	if err := m.call(ctx, http.MethodPost, "models/"+m.opts.Model+":batchGenerateContent", body, &job); err != nil {
+		return nil, fmt.Errorf("batch: submitting %d prompts: %w", len(reqs), err)
+	}
+	if job.Name == "" {
+		return nil, errors.New("batch: submission returned no batch name")
+	}
+	return &job, nil
+}
+
+// call sends an API request with a JSON body, if not nil, and decodes the
+// JSON response into out, if not nil
+func (m *Model) call(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(m.opts.Endpoint, "/")+"/"+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("x-goog-api-key", m.opts.APIKey)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := m.opts.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		// Rate limits and server errors are retried like interactive calls
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		apiErr := &googleapi.Error{Code: resp.StatusCode, Header: resp.Header, Body: string(msg)}
+		var decoded struct {
+			Error apiError `json:"error"`
+		}
+		if json.Unmarshal(msg, &decoded) == nil {
+			apiErr.Message = decoded.Error.Message
+		}
+		return apiErr
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (m *Model) logf(format string, args ...any) {
+	if m.opts.Logf != nil {
+		m.opts.Logf(format, args...)
+	}
+}
@@ -0,0 +1,203 @@
+package batch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/googleapi"
+)
+
+// fakeAPI answers each prompt of a batch with its text reversed, after
+// the batch has been polled once
+type fakeAPI struct {
+	mu       sync.Mutex
+	batches  map[string]batchRequest
+	polls    map[string]int
+	cancels  int
+	fail     bool
+	badKey   string
+	received int
+}
+
+func newFakeAPI(t *testing.T) (*fakeAPI, *httptest.Server) {
+	api := &fakeAPI{batches: map[string]batchRequest{}, polls: map[string]int{}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		api.mu.Lock()
+		defer api.mu.Unlock()
+		if r.Header.Get("x-goog-api-key") != "key" {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"error": {"code": 403, "message": "bad key"}}`)
+			return
+		}
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/models/gemini-test:batchGenerateContent":
+			var req batchRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Errorf("Invalid batch request: %v", err)
+			}
+			name := fmt.Sprintf("batches/%d", len(api.batches)+1)
+			api.batches[name] = req
+			api.received += len(req.Batch.InputConfig.Requests.Requests)
+			fmt.Fprintf(w, `{"name": %q, "metadata": {"state": "BATCH_STATE_PENDING"}}`, name)
+		case r.Method == http.MethodPost && len(r.URL.Path) > 7 && r.URL.Path[len(r.URL.Path)-7:] == ":cancel":
+			api.cancels++
+			fmt.Fprint(w, `{}`)
+		case r.Method == http.MethodGet:
+			name := r.URL.Path[1:]
+			req, ok := api.batches[name]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			api.polls[name]++
+			if api.polls[name] < 2 {
+				fmt.Fprintf(w, `{"name": %q, "metadata": {"state": "BATCH_STATE_RUNNING"}}`, name)
+				return
+			}
+			if api.fail {
+				fmt.Fprintf(w, `{"name": %q, "done": true, "metadata": {"state": "BATCH_STATE_FAILED"}, "error": {"code": 500, "message": "internal"}}`, name)
+				return
+			}
+			var responses []map[string]any
+			// Answer in reverse order to check responses are matched by key
+			reqs := req.Batch.InputConfig.Requests.Requests
+			for i := len(reqs) - 1; i >= 0; i-- {
+				text := []rune(reqs[i].Request.Contents[0].Parts[0].Text)
+				for a, b := 0, len(text)-1; a < b; a, b = a+1, b-1 {
+					text[a], text[b] = text[b], text[a]
+				}
+				key := reqs[i].Metadata.Key
+				if api.badKey != "" {
+					key = api.badKey
+				}
+				responses = append(responses, map[string]any{
+					"metadata": map[string]string{"key": key},
+					"response": map[string]any{
+						"candidates":    []any{map[string]any{"content": map[string]any{"role": "model", "parts": []any{map[string]string{"text": string(text)}}}}},
+						"usageMetadata": map[string]int{"promptTokenCount": len(text), "candidatesTokenCount": 1},
+					},
+				})
+			}
+			json.NewEncoder(w).Encode(map[string]any{
+				"name":     name,
+				"done":     true,
+				"metadata": map[string]string{"state": "BATCH_STATE_SUCCEEDED"},
+				"response": map[string]any{"inlinedResponses": map[string]any{"inlinedResponses": responses}},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return api, srv
+}
+
+func newTestModel(srv *httptest.Server, key string) *Model {
+	return New(Options{
+		APIKey:       key,
+		Model:        "models/gemini-test",
+		Window:       50 * time.Millisecond,
+		PollInterval: 10 * time.Millisecond,
+		Endpoint:     srv.URL,
+	})
+}
+
+func responseText(resp *genai.GenerateContentResponse) string {
+	return string(resp.Candidates[0].Content.Parts[0].(genai.Text))
+}
+
+func TestModelBatchesConcurrentPrompts(t *testing.T) {
+	api, srv := newFakeAPI(t)
+	model := newTestModel(srv, "key")
+
+	prompts := []string{"abc", "hello", "batch"}
+	texts := make([]string, len(prompts))
+	errs := make([]error, len(prompts))
+	var wg sync.WaitGroup
+	for i, p := range prompts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := model.GenerateContent(context.Background(), genai.Text(p))
+			errs[i] = err
+			if err == nil {
+				texts[i] = responseText(resp)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i, want := range []string{"cba", "olleh", "hctab"} {
+		if errs[i] != nil {
+			t.Errorf("Prompt %d failed: %v", i, errs[i])
+		} else if texts[i] != want {
+			t.Errorf("Prompt %d: expected %q, got %q", i, want, texts[i])
+		}
+	}
+	if len(api.batches) != 1 || api.received != 3 {
+		t.Errorf("Expected one batch of 3 prompts, got %d batches of %d prompts", len(api.batches), api.received)
+	}
+}
+
+func TestModelErrors(t *testing.T) {
+	api, srv := newFakeAPI(t)
+
+	var apiErr *googleapi.Error
+	_, err := newTestModel(srv, "wrong").GenerateContent(context.Background(), genai.Text("x"))
+	if !errors.As(err, &apiErr) || apiErr.Code != http.StatusForbidden || apiErr.Message != "bad key" {
+		t.Errorf("Expected a 403 API error, got %v", err)
+	}
+
+	api.fail = true
+	if _, err := newTestModel(srv, "key").GenerateContent(context.Background(), genai.Text("x")); err == nil {
+		t.Error("Expected an error from a failed batch")
+	}
+
+	api.fail = false
+	api.badKey = "7"
+	if _, err := newTestModel(srv, "key").GenerateContent(context.Background(), genai.Text("x")); err == nil {
+		t.Error("Expected an error for a response with an unknown key")
+	}
+
+	if _, err := newTestModel(srv, "key").GenerateContent(context.Background(), genai.Blob{MIMEType: "image/png"}); err == nil {
+		t.Error("Expected an error for a non-text prompt")
+	}
+}
+
+func TestModelCancelsAbandonedBatch(t *testing.T) {
+	api, srv := newFakeAPI(t)
+	model := New(Options{
+		APIKey:       "key",
+		Model:        "gemini-test",
+		Window:       10 * time.Millisecond,
+		PollInterval: time.Hour,
+		Endpoint:     srv.URL,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if _, err := model.GenerateContent(ctx, genai.Text("x")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the caller's deadline, got %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		api.mu.Lock()
+		cancels := api.cancels
+		api.mu.Unlock()
+		if cancels == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the abandoned batch to be cancelled")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,130 @@
+package batch
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// The Batch API's JSON messages, as far as they are used
+
+type batchRequest struct {
+	Batch struct {
+		DisplayName string `json:"display_name"`
+		InputConfig struct {
+			Requests struct {
+				Requests []inlinedRequest `json:"requests"`
+			} `json:"requests"`
+		} `json:"input_config"`
+	} `json:"batch"`
+}
+
+type inlinedRequest struct {
+	Request struct {
+		Contents         []content         `json:"contents"`
+		GenerationConfig *generationConfig `json:"generationConfig,omitempty"`
+	} `json:"request"`
+	Metadata requestMetadata `json:"metadata"`
+}
+
+type requestMetadata struct {
+	Key string `json:"key"`
+}
+
+type content struct {
+	Role  string `json:"role,omitempty"`
+	Parts []part `json:"parts"`
+}
+
+type part struct {
+	Text string `json:"text"`
+}
+
+type generationConfig struct {
+	Temperature float32 `json:"temperature"`
+}
+
+type apiError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// operation is a batch job, as returned on submission and when polled
+type operation struct {
+	Name     string `json:"name"`
+	Done     bool   `json:"done"`
+	Metadata struct {
+		// State is BATCH_STATE_PENDING, _RUNNING, _SUCCEEDED, _FAILED,
+		// _CANCELLED, or _EXPIRED
+		State string `json:"state"`
+	} `json:"metadata"`
+	Error    *apiError    `json:"error"`
+	Response *batchOutput `json:"response"`
+}
+
+type batchOutput struct {
+	// InlinedResponses wraps the list of responses in an object of the
+	// same name
+	InlinedResponses json.RawMessage `json:"inlinedResponses"`
+}
+
+type inlinedResponse struct {
+	Response *generateContentResponse `json:"response"`
+	Error    *apiError                `json:"error"`
+	Metadata requestMetadata          `json:"metadata"`
+}
+
+// responses returns the inlined responses of a finished batch
+func (o *batchOutput) responses() ([]inlinedResponse, error) {
+	if o == nil || len(o.InlinedResponses) == 0 {
+		return nil, errors.New("no responses in finished batch")
+	}
+	var wrapped struct {
+		InlinedResponses []inlinedResponse `json:"inlinedResponses"`
+	}
+	if err := json.Unmarshal(o.InlinedResponses, &wrapped); err == nil {
+		return wrapped.InlinedResponses, nil
+	}
+	var list []inlinedResponse
+	if err := json.Unmarshal(o.InlinedResponses, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+type generateContentResponse struct {
+	Candidates []struct {
+		Content *content `json:"content"`
+	} `json:"candidates"`
+	UsageMetadata *struct {
+		PromptTokenCount        int32 `json:"promptTokenCount"`
+		CachedContentTokenCount int32 `json:"cachedContentTokenCount"`
+		CandidatesTokenCount    int32 `json:"candidatesTokenCount"`
+		TotalTokenCount         int32 `json:"totalTokenCount"`
+	} `json:"usageMetadata"`
+}
+
+// toGenai converts the response to the SDK's type
+func (r *generateContentResponse) toGenai() *genai.GenerateContentResponse {
+	resp := &genai.GenerateContentResponse{}
+	for _, c := range r.Candidates {
+		candidate := &genai.Candidate{Content: &genai.Content{}}
+		if c.Content != nil {
+			candidate.Content.Role = c.Content.Role
+			for _, p := range c.Content.Parts {
+				candidate.Content.Parts = append(candidate.Content.Parts, genai.Text(p.Text))
+			}
+		}
+		resp.Candidates = append(resp.Candidates, candidate)
+	}
+	if u := r.UsageMetadata; u != nil {
+		resp.UsageMetadata = &genai.UsageMetadata{
+			PromptTokenCount:        u.PromptTokenCount,
+			CachedContentTokenCount: u.CachedContentTokenCount,
+			CandidatesTokenCount:    u.CandidatesTokenCount,
+			TotalTokenCount:         u.TotalTokenCount,
+		}
+	}
+	return resp
+}
@@ -0,0 +1,317 @@
+// Package bundle captures everything needed to reproduce an analysis run
+// in a single zip file: the diffs, prompts, and raw LLM responses for each
+// commit, plus the effective configuration and summary.
+//
+// A bundle can be replayed offline with Read and Commit.Result, which
+// re-parses the stored responses without access to the repository or the
+// LLM. This makes it possible to investigate a disputed verdict long after
+// the run, on a different machine.
+package bundle
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kerneldump/git-dual-context/pkg/analyzer"
+	"github.com/kerneldump/git-dual-context/pkg/config"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/google/generative-ai-go/genai"
+)
+
+// FormatVersion is incremented when the bundle layout changes incompatibly
+const FormatVersion = 1
+
+const manifestName = "manifest.json"
+
+// Per-commit file names inside commits/<hash>/
+const (
+	standardDiffName = "standard.diff"
+	fullDiffName     = "full.diff"
+	promptName       = "prompt.txt"
+	responseName     = "response.txt"
+)
+
+// Manifest describes a run. It is stored as manifest.json at the bundle root.
+type Manifest struct {
+	FormatVersion int              `json:"format_version"`
+	CreatedAt     time.Time        `json:"created_at"`
+	Repo          string           `json:"repo"`
+	Branch        string           `json:"branch,omitempty"`
+	ErrorMessage  string           `json:"error_message"`
+	Model         string           `json:"model"`
+	Config        *config.Config   `json:"config,omitempty"`
+	Summary       analyzer.Summary `json:"summary"`
+	Commits       []*Commit        `json:"commits"`
+}
+
+// Commit holds the captured inputs and output of one commit's analysis.
+// Diffs, prompt, and response are stored as separate files in the bundle.
+type Commit struct {
+	Index         int      `json:"index"`
+	Hash          string   `json:"hash"`
+	Message       string   `json:"message"`
+	ModifiedFiles []string `json:"modified_files,omitempty"`
+	Skipped       bool     `json:"skipped,omitempty"`
+	Error         string   `json:"error,omitempty"`
+
+	// Skip says why a skipped commit was not analyzed
+	Skip *analyzer.SkipInfo `json:"skip,omitempty"`
+
+	StandardDiff string `json:"-"`
+	FullDiff     string `json:"-"`
+	Prompt       string `json:"-"`
+	Response     string `json:"-"`
+}
+
+// Result re-parses the stored LLM response into an AnalysisResult,
+// exactly as the live run did. Skipped commits yield a skipped result and
+// commits that failed during the run yield their recorded error.
+func (c *Commit) Result() (*analyzer.AnalysisResult, error) {
+	if c.Skipped {
+		return &analyzer.AnalysisResult{Skipped: true, Skip: c.Skip}, nil
+	}
+	if c.Error != "" {
+		return nil, errors.New(c.Error)
+	}
+	if c.Response == "" {
+		return nil, fmt.Errorf("no response recorded for %s", c.Hash)
+	}
+	cleanTxt := analyzer.FindJSONBlock(c.Response)
+	if cleanTxt == "" {
+		return nil, fmt.Errorf("no JSON found in response for %s", c.Hash)
+	}
+	var result analyzer.AnalysisResult
+	if err := json.Unmarshal([]byte(cleanTxt), &result); err != nil {
+		return nil, fmt.Errorf("parsing JSON for %s: %w", c.Hash, err)
+	}
+	return &result, nil
+}
+
+// Recorder collects a run's data while it executes. All methods are safe
+// for concurrent use.
+type Recorder struct {
+	mu       sync.Mutex
+	manifest Manifest
+}
+
+// NewRecorder starts a bundle for the given commits. API keys, issue
+// tracker tokens, and their references are removed from cfg before it is
+// stored.
+func NewRecorder(m Manifest, cfg *config.Config, commits []*object.Commit) *Recorder {
+	m.FormatVersion = FormatVersion
+	if m.CreatedAt.IsZero() {
+		m.CreatedAt = time.Now().UTC()
+	}
+	if cfg != nil {
+		redacted := *cfg
+		redacted.LLM.APIKey = ""
+		redacted.LLM.APIKeyRef = ""
+		redacted.LLM.APIKeys = nil
+		redacted.Issues.GitHubTokenRef = ""
+		redacted.Issues.JiraTokenRef = ""
+		redacted.CI.GitLabTokenRef = ""
+		for _, k := range cfg.LLM.APIKeys {
+			redacted.LLM.APIKeys = append(redacted.LLM.APIKeys, config.APIKeyConfig{Name: k.Name, RequestsPerMinute: k.RequestsPerMinute})
+		}
+		m.Config = &redacted
+	}
+	m.Commits = make([]*Commit, len(commits))
+	for i, c := range commits {
+		m.Commits[i] = &Commit{Index: i, Hash: c.Hash.String(), Message: c.Message}
+	}
+	return &Recorder{manifest: m}
+}
+
+// RecordDiffs stores the extracted diffs for commit index
+func (r *Recorder) RecordDiffs(index int, dc *analyzer.CommitDiffContext) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c := r.manifest.Commits[index]
+	c.StandardDiff = dc.StandardDiff
+	c.FullDiff = dc.FullDiff
+	c.ModifiedFiles = dc.ModifiedFiles
+	c.Skipped = dc.Skipped
+	c.Skip = dc.Skip
+}
+
+// RecordError stores the final error for commit index, if any
+func (r *Recorder) RecordError(index int, err error) {
+	if err == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.manifest.Commits[index].Error = err.Error()
+}
+
+// Model returns an LLMModel that records the prompt and raw response of
+// every call for commit index. When a call is retried, the last attempt
+// is kept; likewise only the last chunk of a commit split into chunks
+// (see analyzer.CommitDiffContext.Chunks) is kept.
+func (r *Recorder) Model(index int, model analyzer.LLMModel) analyzer.LLMModel {
+	return &recordingModel{model: model, rec: r, index: index}
+}
+
+type recordingModel struct {
+	model analyzer.LLMModel
+	rec   *Recorder
+	index int
+}
+
+// GenerateContent implements analyzer.LLMModel
+func (m *recordingModel) GenerateContent(ctx context.Context, parts ...genai.Part) (*genai.GenerateContentResponse, error) {
+	resp, err := m.model.GenerateContent(ctx, parts...)
+
+	m.rec.mu.Lock()
+	defer m.rec.mu.Unlock()
+	c := m.rec.manifest.Commits[m.index]
+	c.Prompt = textOf(parts)
+	c.Response = ""
+	if err == nil && resp != nil && len(resp.Candidates) > 0 && resp.Candidates[0].Content != nil {
+		c.Response = textOf(resp.Candidates[0].Content.Parts)
+	}
+	return resp, err
+}
+
+func textOf(parts []genai.Part) string {
+	var sb strings.Builder
+	for _, p := range parts {
+		if txt, ok := p.(genai.Text); ok {
+			sb.WriteString(string(txt))
+		}
+	}
+	return sb.String()
+}
+
+// WriteFile finalizes the bundle with summary and writes it to filename
+func (r *Recorder) WriteFile(filename string, summary analyzer.Summary) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.manifest.Summary = summary
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create bundle: %w", err)
+	}
+	if err := write(f, &r.manifest); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func write(w io.Writer, m *Manifest) error {
+	zw := zip.NewWriter(w)
+
+	add := func(name, content string) error {
+		fw, err := zw.Create(name)
+		if err != nil {
+			return fmt.Errorf("failed to add %s to bundle: %w", name, err)
+		}
+		_, err = io.WriteString(fw, content)
+		return err
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode bundle manifest: %w", err)
+	}
+	if err := add(manifestName, string(data)); err != nil {
+		return err
+	}
+
+	for _, c := range m.Commits {
+		dir := path.Join("commits", c.Hash)
+		files := []struct{ name, content string }{
+			{standardDiffName, c.StandardDiff},
+			{fullDiffName, c.FullDiff},
+			{promptName, c.Prompt},
+			{responseName, c.Response},
+		}
+		for _, f := range files {
+			if f.content == "" {
+				continue
+			}
+			if err := add(path.Join(dir, f.name), f.content); err != nil {
+				return err
+			}
+		}
+	}
+
+	return zw.Close()
+}
+
+// Read loads a bundle written by Recorder.WriteFile
+func Read(filename string) (*Manifest, error) {
+	zr, err := zip.OpenReader(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bundle: %w", err)
+	}
+	defer zr.Close()
+
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	readFile := func(name string) (string, error) {
+		f, ok := files[name]
+		if !ok {
+			return "", nil
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s from bundle: %w", name, err)
+		}
+		defer rc.Close()
+		data, err := io.ReadAll(rc)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s from bundle: %w", name, err)
+		}
+		return string(data), nil
+	}
+
+	data, err := readFile(manifestName)
+	if err != nil {
+		return nil, err
+	}
+	if data == "" {
+		return nil, fmt.Errorf("bundle has no %s", manifestName)
+	}
+	var m Manifest
+	if err := json.Unmarshal([]byte(data), &m); err != nil {
+		return nil, fmt.Errorf("failed to parse bundle manifest: %w", err)
+	}
+	if m.FormatVersion != FormatVersion {
+		return nil, fmt.Errorf("unsupported bundle format version %d (expected %d)", m.FormatVersion, FormatVersion)
+	}
+
+	for _, c := range m.Commits {
+		dir := path.Join("commits", c.Hash)
+		for _, f := range []struct {
+			name string
+			dst  *string
+		}{
+			{standardDiffName, &c.StandardDiff},
+			{fullDiffName, &c.FullDiff},
+			{promptName, &c.Prompt},
+			{responseName, &c.Response},
+		} {
+			if *f.dst, err = readFile(path.Join(dir, f.name)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return &m, nil
+}
@@ -0,0 +1,121 @@
+package bundle
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kerneldump/git-dual-context/pkg/analyzer"
+	"github.com/kerneldump/git-dual-context/pkg/config"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/google/generative-ai-go/genai"
+)
+
+// stubModel returns a fixed text response
+type stubModel struct {
+	text string
+}
+
+func (m *stubModel) GenerateContent(ctx context.Context, parts ...genai.Part) (*genai.GenerateContentResponse, error) {
+	return &genai.GenerateContentResponse{
+		Candidates: []*genai.Candidate{
+			{Content: &genai.Content{Parts: []genai.Part{genai.Text(m.text)}}},
+		},
+	}, nil
+}
+
+func testCommits() []*object.Commit {
+	return []*object.Commit{
+		{Hash: plumbing.NewHash("1111111111111111111111111111111111111111"), Message: "Remove nil check"},
+		{Hash: plumbing.NewHash("2222222222222222222222222222222222222222"), Message: "Update README"},
+		{Hash: plumbing.NewHash("3333333333333333333333333333333333333333"), Message: "Refactor handler"},
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.LLM.APIKey = "secret"
+	cfg.LLM.APIKeyRef = "command:echo secret"
+	cfg.LLM.APIKeys = []config.APIKeyConfig{{Name: "team-a", Key: "secret", RequestsPerMinute: 60}}
+	cfg.Issues.GitHubTokenRef = "command:echo secret"
+
+	rec := NewRecorder(Manifest{
+		Repo:         "/src/app",
+		ErrorMessage: "nil pointer dereference",
+		Model:        "gemini-test",
+	}, cfg, testCommits())
+
+	// Commit 0: analyzed
+	rec.RecordDiffs(0, &analyzer.CommitDiffContext{
+		StandardDiff:  "--- main.go\n-if x != nil {\n",
+		FullDiff:      "No further changes to these files since this commit.",
+		ModifiedFiles: []string{"main.go"},
+	})
+	model := rec.Model(0, &stubModel{text: "Thinking...\n```json\n{\"probability\": \"HIGH\", \"reasoning\": \"nil check removed\"}\n```"})
+	if _, err := model.GenerateContent(context.Background(), genai.Text("prompt for commit 0")); err != nil {
+		t.Fatalf("GenerateContent failed: %v", err)
+	}
+
+	// Commit 1: skipped, commit 2: failed
+	rec.RecordDiffs(1, &analyzer.CommitDiffContext{Skipped: true})
+	rec.RecordError(2, errors.New("gemini api call: quota exceeded"))
+
+	path := filepath.Join(t.TempDir(), "run.zip")
+	summary := analyzer.Summary{Type: "summary", Total: 3, High: 1, Skipped: 1, Errors: 1, Duration: "4.2s", Model: "gemini-test"}
+	if err := rec.WriteFile(path, summary); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	m, err := Read(path)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+
+	if m.Repo != "/src/app" || m.ErrorMessage != "nil pointer dereference" || m.Summary.High != 1 {
+		t.Errorf("Unexpected manifest: %+v", m)
+	}
+	if m.Config == nil || m.Config.LLM.APIKey != "" || m.Config.LLM.APIKeyRef != "" {
+		t.Errorf("Expected config with API key redacted, got %+v", m.Config)
+	}
+	if k := m.Config.LLM.APIKeys; len(k) != 1 || k[0].Key != "" || k[0].Name != "team-a" {
+		t.Errorf("Expected llm.api_keys redacted, got %+v", k)
+	}
+	if m.Config.Issues.GitHubTokenRef != "" {
+		t.Errorf("Expected issues.github_token_ref redacted, got %q", m.Config.Issues.GitHubTokenRef)
+	}
+	if cfg.LLM.APIKey != "secret" || cfg.LLM.APIKeys[0].Key != "secret" {
+		t.Error("Redaction must not modify the caller's config")
+	}
+	if len(m.Commits) != 3 {
+		t.Fatalf("Expected 3 commits, got %d", len(m.Commits))
+	}
+
+	c := m.Commits[0]
+	if c.Prompt != "prompt for commit 0" || !strings.Contains(c.StandardDiff, "if x != nil") {
+		t.Errorf("Unexpected captured data: %+v", c)
+	}
+	res, err := c.Result()
+	if err != nil {
+		t.Fatalf("Result failed: %v", err)
+	}
+	if res.Probability != analyzer.ProbHigh || res.Reasoning != "nil check removed" {
+		t.Errorf("Unexpected replayed result: %+v", res)
+	}
+
+	if res, err := m.Commits[1].Result(); err != nil || !res.Skipped {
+		t.Errorf("Expected skipped result, got %+v, %v", res, err)
+	}
+	if _, err := m.Commits[2].Result(); err == nil || !strings.Contains(err.Error(), "quota exceeded") {
+		t.Errorf("Expected recorded error, got %v", err)
+	}
+}
+
+func TestReadMissingFile(t *testing.T) {
+	if _, err := Read(filepath.Join(t.TempDir(), "missing.zip")); err == nil {
+		t.Error("Expected error for missing bundle")
+	}
+}
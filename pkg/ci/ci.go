@@ -0,0 +1,328 @@
+// Package ci looks up whether a commit passed CI, from the GitHub checks
+// and commit statuses APIs or GitLab's commit statuses. A commit whose
+// checks failed when it landed is a much stronger suspect than one that
+// passed them.
+//
+// A forge is only queried once its credentials are configured; without
+// any, a Checker is nil and finds no status.
+package ci
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Default API URLs
+const (
+	DefaultGitHubAPI = "https://api.github.com"
+	DefaultGitLabAPI = "https://gitlab.com/api/v4"
+)
+
+// DefaultTimeout bounds the lookups of each commit
+const DefaultTimeout = 10 * time.Second
+
+// maxResponseSize bounds the responses read
+const maxResponseSize = 4 << 20
+
+// maxFailedChecks bounds the failed checks listed in a Status
+const maxFailedChecks = 5
+
+// States of a commit's CI
+const (
+	StateSuccess = "success" // every finished check passed
+	StateFailure = "failure" // a check failed
+	StatePending = "pending" // no check failed, and some have not finished
+)
+
+// remotePathRe matches the repository path of SSH and HTTPS remote URLs,
+// such as group/sub/project in git@gitlab.com:group/sub/project.git
+var remotePathRe = regexp.MustCompile(`^(?:[a-z+]+://)?(?:[^@/]+@)?[^:/]+(?::\d+)?[:/](.+?)(?:\.git)?/?$`)
+
+// Status is the CI outcome of a commit
+type Status struct {
+	// State is StateSuccess, StateFailure, or StatePending
+	State string `json:"state"`
+
+	// Checks counts the checks and statuses that passed, failed, or are
+	// pending; skipped and cancelled ones, and GitLab jobs allowed to fail
+	// that failed, are left out
+	Checks int `json:"checks"`
+
+	// Failed names the checks that failed (at most five)
+	Failed []string `json:"failed,omitempty"`
+}
+
+// String formats s for the prompt, such as "failure (test, lint)"
+func (s *Status) String() string {
+	if s == nil {
+		return ""
+	}
+	if len(s.Failed) > 0 {
+		return fmt.Sprintf("%s (%s)", s.State, strings.Join(s.Failed, ", "))
+	}
+	return s.State
+}
+
+// Config names the forges to query and their credentials
+type Config struct {
+	// GitHubRepo is the "owner/name" repository on GitHub (empty: GitHub
+	// is not queried)
+	GitHubRepo string
+
+	// GitHubToken authenticates GitHub requests (empty: GitHub is not
+	// queried)
+	GitHubToken string
+
+	// GitHubAPI is the API URL, for GitHub Enterprise (empty:
+	// DefaultGitHubAPI)
+	GitHubAPI string
+
+	// GitLabProject is the project path on GitLab, such as group/project
+	// (empty: GitLab is not queried)
+	GitLabProject string
+
+	// GitLabToken authenticates GitLab requests (empty: GitLab is not
+	// queried)
+	GitLabToken string
+
+	// GitLabAPI is the API URL, for self-managed GitLab (empty:
+	// DefaultGitLabAPI)
+	GitLabAPI string
+
+	// Timeout bounds the lookups of each commit (0: DefaultTimeout)
+	Timeout time.Duration
+
+	// Client makes the requests (nil: http.DefaultClient)
+	Client *http.Client
+}
+
+// github reports whether GitHub is queried
+func (c Config) github() bool {
+	return c.GitHubRepo != "" && c.GitHubToken != ""
+}
+
+// gitlab reports whether GitLab is queried
+func (c Config) gitlab() bool {
+	return c.GitLabProject != "" && c.GitLabToken != ""
+}
+
+// RemotePath returns the repository path of a remote URL, such as
+// group/project for git@gitlab.com:group/project.git, or "" for local
+// paths
+func RemotePath(remoteURL string) string {
+	remoteURL = strings.TrimSpace(remoteURL)
+	if strings.HasPrefix(remoteURL, "/") || strings.HasPrefix(remoteURL, "file://") {
+		return ""
+	}
+	m := remotePathRe.FindStringSubmatch(remoteURL)
+	if m == nil {
+		return ""
+	}
+	return m[1]
+}
+
+// Checker looks up the CI status of commits. It is safe for concurrent
+// use, and a nil Checker finds no status.
+type Checker struct {
+	cfg Config
+}
+
+// New returns a Checker for the forges of cfg, or nil if cfg has the
+// credentials of none
+func New(cfg Config) *Checker {
+	if !cfg.github() && !cfg.gitlab() {
+		return nil
+	}
+	if cfg.GitHubAPI == "" {
+		cfg.GitHubAPI = DefaultGitHubAPI
+	}
+	if cfg.GitLabAPI == "" {
+		cfg.GitLabAPI = DefaultGitLabAPI
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	return &Checker{cfg: cfg}
+}
+
+// Status returns the CI status of the commit hash, or nil if no check or
+// status was reported for it, or the forge does not have it
+func (c *Checker) Status(ctx context.Context, hash string) (*Status, error) {
+	if c == nil {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+
+	t := &tally{}
+	if c.cfg.github() {
+		if err := c.github(ctx, hash, t); err != nil && !errors.Is(err, errUnknownCommit) {
+			return nil, fmt.Errorf("GitHub checks of %s: %w", short(hash), err)
+		}
+	}
+	if c.cfg.gitlab() {
+		if err := c.gitlab(ctx, hash, t); err != nil && !errors.Is(err, errUnknownCommit) {
+			return nil, fmt.Errorf("GitLab statuses of %s: %w", short(hash), err)
+		}
+	}
+	return t.status(), nil
+}
+
+// github tallies the check runs and commit statuses of hash on GitHub
+func (c *Checker) github(ctx context.Context, hash string, t *tally) error {
+	base := fmt.Sprintf("%s/repos/%s/commits/%s", strings.TrimRight(c.cfg.GitHubAPI, "/"), c.cfg.GitHubRepo, hash)
+	auth := "Bearer " + c.cfg.GitHubToken
+
+	var runs struct {
+		CheckRuns []struct {
+			Name       string `json:"name"`
+			Status     string `json:"status"`
+			Conclusion string `json:"conclusion"`
+		} `json:"check_runs"`
+	}
+	if err := c.get(ctx, base+"/check-runs?per_page=100", auth, &runs); err != nil {
+		return err
+	}
+	for _, r := range runs.CheckRuns {
+		switch {
+		case r.Status != "completed":
+			t.add(r.Name, StatePending)
+		case r.Conclusion == "failure" || r.Conclusion == "timed_out":
+			t.add(r.Name, StateFailure)
+		case r.Conclusion == "success":
+			t.add(r.Name, StateSuccess)
+		}
+	}
+
+	var combined struct {
+		Statuses []struct {
+			Context string `json:"context"`
+			State   string `json:"state"`
+		} `json:"statuses"`
+	}
+	if err := c.get(ctx, base+"/status?per_page=100", auth, &combined); err != nil {
+		return err
+	}
+	for _, s := range combined.Statuses {
+		switch s.State {
+		case "failure", "error":
+			t.add(s.Context, StateFailure)
+		case "pending":
+			t.add(s.Context, StatePending)
+		case "success":
+			t.add(s.Context, StateSuccess)
+		}
+	}
+	return nil
+}
+
+// gitlab tallies the commit statuses of hash on GitLab, which include
+// every pipeline job
+func (c *Checker) gitlab(ctx context.Context, hash string, t *tally) error {
+	endpoint := fmt.Sprintf("%s/projects/%s/repository/commits/%s/statuses?per_page=100&all=true",
+		strings.TrimRight(c.cfg.GitLabAPI, "/"), url.PathEscape(c.cfg.GitLabProject), hash)
+	var statuses []struct {
+		Name         string `json:"name"`
+		Status       string `json:"status"`
+		AllowFailure bool   `json:"allow_failure"`
+	}
+	if err := c.get(ctx, endpoint, "Bearer "+c.cfg.GitLabToken, &statuses); err != nil {
+		return err
+	}
+	for _, s := range statuses {
+		switch s.Status {
+		case "failed":
+			if !s.AllowFailure {
+				t.add(s.Name, StateFailure)
+			}
+		case "created", "waiting_for_resource", "preparing", "pending", "running", "scheduled":
+			t.add(s.Name, StatePending)
+		case "success":
+			t.add(s.Name, StateSuccess)
+		}
+	}
+	return nil
+}
+
+// errUnknownCommit is returned by get when the forge does not have the
+// commit, such as one never pushed
+var errUnknownCommit = errors.New("unknown commit")
+
+// get decodes the JSON at endpoint into v
+func (c *Checker) get(ctx context.Context, endpoint, auth string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", auth)
+	resp, err := c.cfg.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusUnprocessableEntity:
+		return errUnknownCommit
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(v); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	return nil
+}
+
+// tally counts check outcomes into a Status
+type tally struct {
+	checks  int
+	pending bool
+	failed  []string
+}
+
+// add counts the check name in state
+func (t *tally) add(name, state string) {
+	t.checks++
+	switch state {
+	case StatePending:
+		t.pending = true
+	case StateFailure:
+		if !slices.Contains(t.failed, name) {
+			t.failed = append(t.failed, name)
+		}
+	}
+}
+
+// status returns the Status of the checks counted, or nil for none
+func (t *tally) status() *Status {
+	if t.checks == 0 {
+		return nil
+	}
+	s := &Status{State: StateSuccess, Checks: t.checks}
+	switch {
+	case len(t.failed) > 0:
+		s.State = StateFailure
+		s.Failed = t.failed[:min(len(t.failed), maxFailedChecks)]
+	case t.pending:
+		s.State = StatePending
+	}
+	return s
+}
+
+// short abbreviates a commit hash for messages
+func short(hash string) string {
+	return hash[:min(len(hash), 8)]
+}
@@ -0,0 +1,115 @@
+package ci
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+const testHash = "a1b2c3d4e5f6a7b8c9d0e1f2a3b4c5d6e7f8a9b0"
+
+func TestRemotePath(t *testing.T) {
+	tests := []struct {
+		remote   string
+		expected string
+	}{
+		{"git@gitlab.com:group/sub/project.git", "group/sub/project"},
+		{"https://gitlab.example.com/group/project", "group/project"},
+		{"ssh://git@gitlab.example.com:2222/group/project.git", "group/project"},
+		{"https://github.com/acme/api.git/", "acme/api"},
+		{"/src/app", ""},
+		{"file:///src/app", ""},
+	}
+	for _, tt := range tests {
+		if got := RemotePath(tt.remote); got != tt.expected {
+			t.Errorf("RemotePath(%q) = %q, expected %q", tt.remote, got, tt.expected)
+		}
+	}
+}
+
+func TestCheckerGitHub(t *testing.T) {
+	checkRuns := `{"check_runs": [
+		{"name": "test", "status": "completed", "conclusion": "failure"},
+		{"name": "lint", "status": "completed", "conclusion": "success"},
+		{"name": "docs", "status": "completed", "conclusion": "skipped"}]}`
+	statuses := `{"statuses": [{"context": "ci/jenkins", "state": "error"}]}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer gh-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/repos/acme/api/commits/" + testHash + "/check-runs":
+			io.WriteString(w, checkRuns)
+		case "/repos/acme/api/commits/" + testHash + "/status":
+			io.WriteString(w, statuses)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	c := New(Config{GitHubRepo: "acme/api", GitHubToken: "gh-token", GitHubAPI: srv.URL})
+	status, err := c.Status(context.Background(), testHash)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	expected := &Status{State: StateFailure, Checks: 3, Failed: []string{"test", "ci/jenkins"}}
+	if !reflect.DeepEqual(status, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, status)
+	}
+	if s := status.String(); s != "failure (test, ci/jenkins)" {
+		t.Errorf("Unexpected format %q", s)
+	}
+
+	checkRuns = `{"check_runs": [{"name": "test", "status": "in_progress"}]}`
+	statuses = `{"statuses": [{"context": "ci/jenkins", "state": "success"}]}`
+	if status, err := c.Status(context.Background(), testHash); err != nil || status.State != StatePending {
+		t.Errorf("Expected a pending status, got %+v (%v)", status, err)
+	}
+
+	// A commit the forge does not have, such as one never pushed, has none
+	if status, err := c.Status(context.Background(), "0000000000000000000000000000000000000000"); err != nil || status != nil {
+		t.Errorf("Expected no status for an unknown commit, got %+v (%v)", status, err)
+	}
+}
+
+func TestCheckerGitLab(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/projects/group%2Fproject/repository/commits/"+testHash+"/statuses" || r.Header.Get("Authorization") != "Bearer gl-token" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		io.WriteString(w, `[
+			{"name": "build", "status": "success"},
+			{"name": "flaky", "status": "failed", "allow_failure": true},
+			{"name": "deploy", "status": "canceled"}]`)
+	}))
+	defer srv.Close()
+
+	c := New(Config{GitLabProject: "group/project", GitLabToken: "gl-token", GitLabAPI: srv.URL})
+	status, err := c.Status(context.Background(), testHash)
+	if err != nil {
+		t.Fatalf("Status failed: %v", err)
+	}
+	if expected := (&Status{State: StateSuccess, Checks: 1}); !reflect.DeepEqual(status, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, status)
+	}
+
+	if _, err := New(Config{GitLabProject: "other/project", GitLabToken: "gl-token", GitLabAPI: srv.URL}).Status(context.Background(), testHash); err == nil {
+		t.Error("Expected the server error reported")
+	}
+}
+
+func TestNew(t *testing.T) {
+	if c := New(Config{GitHubRepo: "acme/api"}); c != nil {
+		t.Error("Expected no checker without a token")
+	}
+	var c *Checker
+	if status, err := c.Status(context.Background(), testHash); status != nil || err != nil {
+		t.Errorf("Expected a nil checker to find nothing, got %+v, %v", status, err)
+	}
+}
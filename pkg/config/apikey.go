@@ -0,0 +1,88 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/kerneldump/git-dual-context/pkg/secret"
+)
+
+// APIKeyEnv holds the Gemini API key
+const APIKeyEnv = "GEMINI_API_KEY"
+
+// ErrNoAPIKey is returned by APIKey when no API key is configured
+var ErrNoAPIKey = errors.New("no API key provided. Please use -apikey flag, set GEMINI_API_KEY environment variable, or set llm.api_key_ref")
+
+// ResolvedKey is an API key with its llm.api_keys settings
+type ResolvedKey struct {
+	// Name identifies the key in logs; never the key itself
+	Name string
+
+	// Value is the key
+	Value string
+
+	// RequestsPerMinute caps the calls made with the key (0: no cap)
+	RequestsPerMinute int
+}
+
+// APIKey returns the API key: flagKey, from the -apikey flag, if set; else
+// GEMINI_API_KEY; else the first of llm.api_keys, the secret
+// llm.api_key_ref points at, or llm.api_key
+func (c *Config) APIKey(ctx context.Context, flagKey string) (string, error) {
+	if flagKey != "" {
+		return flagKey, nil
+	}
+	if key := os.Getenv(APIKeyEnv); key != "" {
+		return key, nil
+	}
+	if len(c.LLM.APIKeys) > 0 {
+		k, err := c.LLM.APIKeys[0].resolve(ctx, 0)
+		return k.Value, err
+	}
+	if c.LLM.APIKeyRef != "" {
+		return secret.Resolve(ctx, c.LLM.APIKeyRef)
+	}
+	if c.LLM.APIKey != "" {
+		return c.LLM.APIKey, nil
+	}
+	return "", ErrNoAPIKey
+}
+
+// APIKeys returns the API keys to spread calls over: each of llm.api_keys,
+// unless flagKey or GEMINI_API_KEY overrides them with the single key
+// APIKey returns
+func (c *Config) APIKeys(ctx context.Context, flagKey string) ([]ResolvedKey, error) {
+	if flagKey != "" || os.Getenv(APIKeyEnv) != "" || len(c.LLM.APIKeys) == 0 {
+		key, err := c.APIKey(ctx, flagKey)
+		if err != nil {
+			return nil, err
+		}
+		return []ResolvedKey{{Name: "default", Value: key}}, nil
+	}
+	keys := make([]ResolvedKey, len(c.LLM.APIKeys))
+	for i, k := range c.LLM.APIKeys {
+		var err error
+		if keys[i], err = k.resolve(ctx, i); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// resolve returns the key of llm.api_keys[i]
+func (k APIKeyConfig) resolve(ctx context.Context, i int) (ResolvedKey, error) {
+	r := ResolvedKey{Name: k.Name, Value: k.Key, RequestsPerMinute: k.RequestsPerMinute}
+	if r.Name == "" {
+		r.Name = fmt.Sprintf("#%d", i+1)
+	}
+	if k.Ref != "" {
+		value, err := secret.Resolve(ctx, k.Ref)
+		if err != nil {
+			return ResolvedKey{}, fmt.Errorf("llm.api_keys[%d]: %w", i, err)
+		}
+		r.Value = value
+	}
+	return r, nil
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAPIKey(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv(APIKeyEnv, "")
+	t.Setenv("GDC_TEST_REF_KEY", "from-ref")
+
+	cfg := DefaultConfig()
+	if _, err := cfg.APIKey(ctx, ""); !errors.Is(err, ErrNoAPIKey) {
+		t.Errorf("expected ErrNoAPIKey, got %v", err)
+	}
+
+	cfg.LLM.APIKey = "from-file"
+	if key, _ := cfg.APIKey(ctx, ""); key != "from-file" {
+		t.Errorf("expected llm.api_key, got %q", key)
+	}
+
+	cfg.LLM.APIKey = ""
+	cfg.LLM.APIKeyRef = "env:GDC_TEST_REF_KEY"
+	if key, _ := cfg.APIKey(ctx, ""); key != "from-ref" {
+		t.Errorf("expected the referenced key, got %q", key)
+	}
+
+	t.Setenv(APIKeyEnv, "from-env")
+	if key, _ := cfg.APIKey(ctx, ""); key != "from-env" {
+		t.Errorf("expected GEMINI_API_KEY over the reference, got %q", key)
+	}
+	if key, _ := cfg.APIKey(ctx, "from-flag"); key != "from-flag" {
+		t.Errorf("expected the flag over everything, got %q", key)
+	}
+
+	t.Setenv(APIKeyEnv, "")
+	cfg.LLM.APIKeyRef = "env:GDC_TEST_UNSET_KEY"
+	if _, err := cfg.APIKey(ctx, ""); err == nil {
+		t.Error("expected an error for an unresolvable reference")
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv(APIKeyEnv, "")
+	t.Setenv("GDC_TEST_KEY_A", "key-a")
+
+	cfg := DefaultConfig()
+	cfg.LLM.APIKeys = []APIKeyConfig{
+		{Name: "team-a", Ref: "env:GDC_TEST_KEY_A", RequestsPerMinute: 60},
+		{Key: "key-b"},
+	}
+	keys, err := cfg.APIKeys(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ResolvedKey{{Name: "team-a", Value: "key-a", RequestsPerMinute: 60}, {Name: "#2", Value: "key-b"}}
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("APIKeys() = %+v, want %+v", keys, want)
+	}
+	if key, _ := cfg.APIKey(ctx, ""); key != "key-a" {
+		t.Errorf("expected the first of llm.api_keys as the single key, got %q", key)
+	}
+
+	t.Setenv(APIKeyEnv, "from-env")
+	keys, err = cfg.APIKeys(ctx, "")
+	if err != nil || len(keys) != 1 || keys[0].Value != "from-env" {
+		t.Errorf("expected GEMINI_API_KEY to replace llm.api_keys, got %+v, %v", keys, err)
+	}
+
+	t.Setenv(APIKeyEnv, "")
+	cfg.LLM.APIKeys[1] = APIKeyConfig{Ref: "env:GDC_TEST_UNSET_KEY"}
+	if _, err := cfg.APIKeys(ctx, ""); err == nil || !strings.Contains(err.Error(), "llm.api_keys[1]") {
+		t.Errorf("expected an error naming the entry, got %v", err)
+	}
+}
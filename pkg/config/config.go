@@ -3,13 +3,27 @@ package config
 
 import (
 	"fmt"
+	"maps"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/kerneldump/git-dual-context/pkg/issues"
+	"github.com/kerneldump/git-dual-context/pkg/network"
+	"github.com/kerneldump/git-dual-context/pkg/secret"
+	"github.com/kerneldump/git-dual-context/pkg/validator"
+
 	"gopkg.in/yaml.v3"
 )
 
+// ProviderHeuristic is the llm.provider that rates commits offline with
+// heuristics instead of an LLM
+const ProviderHeuristic = "heuristic"
+
 // Config represents the complete configuration for git-dual-context
 type Config struct {
 	// LLM settings
@@ -23,11 +37,41 @@ type Config struct {
 
 	// Output settings
 	Output OutputConfig `yaml:"output"`
+
+	// History settings
+	History HistoryConfig `yaml:"history"`
+
+	// Audit log settings
+	Audit AuditConfig `yaml:"audit"`
+
+	// Remote clone settings
+	Clone CloneConfig `yaml:"clone"`
+
+	// Proxy and certificate settings
+	Network NetworkConfig `yaml:"network"`
+
+	// Issue tracker settings
+	Issues IssuesConfig `yaml:"issues"`
+
+	// CI status settings
+	CI CIConfig `yaml:"ci"`
+
+	// MCP server sandbox settings
+	MCP MCPConfig `yaml:"mcp"`
+
+	// Daemon (serve) access and retention settings
+	Serve ServeConfig `yaml:"serve"`
+
+	// Profiles are named sets of overrides of the settings above, such as
+	// a quick "incident" profile and a thorough "nightly" one (see
+	// ApplyProfile)
+	Profiles map[string]yaml.Node `yaml:"profiles,omitempty"`
 }
 
 // LLMConfig contains LLM-specific settings
 type LLMConfig struct {
-	// Provider is the LLM provider (gemini, openai, anthropic)
+	// Provider is the LLM provider (gemini, openai, anthropic), or
+	// heuristic to rate commits offline without an LLM
 	Provider string `yaml:"provider"`
 
 	// Model is the specific model to use
@@ -36,11 +80,64 @@ type LLMConfig struct {
 	// APIKey is the API key (can be overridden by env var)
 	APIKey string `yaml:"api_key,omitempty"`
 
+	// APIKeyRef points at the API key instead of holding it: env:NAME,
+	// keyring:SERVICE/ACCOUNT, or command:CMD (see package secret)
+	APIKeyRef string `yaml:"api_key_ref,omitempty"`
+
+	// APIKeys spreads calls over several API keys, such as one per
+	// project, in place of APIKey and APIKeyRef
+	APIKeys []APIKeyConfig `yaml:"api_keys,omitempty"`
+
+	// KeyRotation picks the key of each call from APIKeys: round_robin
+	// takes them in turn, failover uses the first until it is rate limited
+	KeyRotation string `yaml:"key_rotation"`
+
 	// Temperature controls randomness (0.0 to 1.0)
 	Temperature float32 `yaml:"temperature"`
 
 	// Timeout for each LLM request
 	Timeout time.Duration `yaml:"timeout"`
+
+	// Stream streams responses and stops generation once the verdict JSON
+	// is complete
+	Stream bool `yaml:"stream"`
+
+	// ContextCache caches the instructions, bug description, and HEAD
+	// versions of the changed files, which every commit's prompt shares,
+	// in Gemini's context cache once per run
+	ContextCache bool `yaml:"context_cache"`
+
+	// ContextCacheTTL is how long a cache outlives a run that could not
+	// delete it
+	ContextCacheTTL time.Duration `yaml:"context_cache_ttl"`
+
+	// ContextCacheTokens caps the HEAD-side code in the cache
+	ContextCacheTokens int `yaml:"context_cache_tokens"`
+
+	// Batch submits all prompts of a run through the Gemini Batch API,
+	// which costs half as much but may take up to a day to answer
+	Batch bool `yaml:"batch"`
+
+	// BatchPollInterval is how often a submitted batch is checked
+	BatchPollInterval time.Duration `yaml:"batch_poll_interval"`
+
+	// BatchTimeout replaces Timeout for each commit in batch mode
+	BatchTimeout time.Duration `yaml:"batch_timeout"`
+}
+
+// APIKeyConfig is one of several API keys in llm.api_keys
+type APIKeyConfig struct {
+	// Name identifies the key in logs (default: its position, as #1)
+	Name string `yaml:"name,omitempty"`
+
+	// Ref points at the key, like llm.api_key_ref
+	Ref string `yaml:"ref,omitempty"`
+
+	// Key is the key itself; prefer Ref
+	Key string `yaml:"key,omitempty"`
+
+	// RequestsPerMinute caps the calls made with the key (0: no cap)
+	RequestsPerMinute int `yaml:"requests_per_minute,omitempty"`
 }
 
 // AnalysisConfig contains analysis-specific settings
@@ -48,14 +145,135 @@ type AnalysisConfig struct {
 	// DefaultCommits is the default number of commits to analyze
 	DefaultCommits int `yaml:"default_commits"`
 
-	// MaxDiffSize is the maximum diff size in characters
+	// MaxDiffSize is the maximum size of each standard diff (commit vs
+	// parent) in characters
 	MaxDiffSize int `yaml:"max_diff_size"`
 
+	// MaxFullDiffSize is the maximum size of each full diff (commit vs
+	// HEAD) in characters
+	MaxFullDiffSize int `yaml:"max_full_diff_size"`
+
+	// MaxPromptDiffSize caps both diffs of one LLM call together, in
+	// characters, cutting the full diff first (0: no combined cap)
+	MaxPromptDiffSize int `yaml:"max_prompt_diff_size"`
+
+	// MaxDiffTokens is the token budget for each diff; files are truncated
+	// in proportion to their size to fit it
+	MaxDiffTokens int `yaml:"max_diff_tokens"`
+
+	// MaxChunks is the most LLM calls a commit over MaxDiffTokens is split
+	// into, one per group of files (1 disables splitting)
+	MaxChunks int `yaml:"max_chunks"`
+
+	// DropIrrelevantHunks removes hunks sharing no identifiers with the
+	// error message from diffs over MaxDiffTokens
+	DropIrrelevantHunks bool `yaml:"drop_irrelevant_hunks"`
+
+	// DiffBackend computes diffs: "go-git" (pure Go), "git" (the system git
+	// binary, faster on large repositories), or "auto" (git when on PATH)
+	DiffBackend string `yaml:"diff_backend"`
+
 	// SkipMergeCommits whether to skip merge commits
 	SkipMergeCommits bool `yaml:"skip_merge_commits"`
 
 	// FileFilters contains glob patterns for files to exclude
 	FileFilters []string `yaml:"file_filters,omitempty"`
+
+	// IncludeFiles, if set, restricts analysis to files matching these
+	// glob patterns
+	IncludeFiles []string `yaml:"include_files,omitempty"`
+
+	// FilterProfiles adds the exclude patterns of ecosystem profiles (go,
+	// node, python, jvm, monorepo); "auto" detects them from the repository
+	FilterProfiles []string `yaml:"filter_profiles,omitempty"`
+
+	// IncludeTests analyzes test files, which are skipped by default
+	IncludeTests bool `yaml:"include_tests"`
+
+	// ContextLines limits the unchanged lines shown around each change
+	// (0 shows whole files)
+	ContextLines int `yaml:"context_lines"`
+
+	// FunctionContext expands each change to its enclosing function
+	FunctionContext bool `yaml:"function_context"`
+
+	// FullFileMaxBytes sends files up to this size whole even when
+	// context_lines, function_context, or semantic_diff is set (0: never)
+	FullFileMaxBytes int `yaml:"full_file_max_bytes"`
+
+	// BlameEvolution notes on each changed line of the evolution diff the
+	// commit that last touched it (slow on long histories)
+	BlameEvolution bool `yaml:"blame_evolution"`
+
+	// MinChangedLines skips commits changing fewer lines of analyzed files
+	// without an LLM call (0 analyzes every commit)
+	MinChangedLines int `yaml:"min_changed_lines"`
+
+	// AnalyzeNonFunctional sends whitespace-, comment-, and import-order-only
+	// commits to the LLM instead of rating them LOW
+	AnalyzeNonFunctional bool `yaml:"analyze_non_functional"`
+
+	// SemanticDiff summarizes changed declarations instead of changed lines
+	// for languages with a symbol parser (Go)
+	SemanticDiff bool `yaml:"semantic_diff"`
+
+	// SuggestOwners lists the CODEOWNERS owners, or for files without any
+	// the last authors, of HIGH and MEDIUM commits' files
+	SuggestOwners bool `yaml:"suggest_owners"`
+
+	// AffectedTests lists the test files likely to exercise HIGH and
+	// MEDIUM commits' files, by naming convention and Go imports
+	AffectedTests bool `yaml:"affected_tests"`
+
+	// HotspotHistory is how many recent commits the churn and bug-fix
+	// prior that ranks equally rated commits is computed over (0 disables)
+	HotspotHistory int `yaml:"hotspot_history"`
+
+	// TechStack names the repository's dominant languages and frameworks,
+	// detected from file extensions and manifests, in every prompt
+	TechStack bool `yaml:"tech_stack"`
+
+	// RelatedFiles adds the evolution of up to this many files a commit
+	// did not change, but that historically change with its files or
+	// import their Go packages, to its full diff (0 disables)
+	RelatedFiles int `yaml:"related_files"`
+
+	// GoCallers lists where the Go functions a commit changed are called
+	// at HEAD in its prompt; HEAD's modules are type-checked with the go
+	// command once per run
+	GoCallers bool `yaml:"go_callers"`
+
+	// VerifyTop is how many of the most suspicious HIGH and MEDIUM commits
+	// the CLI's -verify-cmd is run at
+	VerifyTop int `yaml:"verify_top"`
+
+	// VerifyTimeout bounds each run of -verify-cmd
+	VerifyTimeout time.Duration `yaml:"verify_timeout"`
+
+	// ScoreWeights blends the LLM verdict, heuristics, and recency into
+	// each result's suspicion score
+	ScoreWeights ScoreWeights `yaml:"score_weights"`
+
+	// Reasoning is how long each verdict's reasoning is asked to be:
+	// "short" (one or two sentences, cut to them), "standard", or
+	// "verbose" (the whole chain of reasoning)
+	Reasoning string `yaml:"reasoning"`
+
+	// DedupePatches reuses the verdict of a commit for later commits with
+	// an identical patch, such as cherry-picks, instead of analyzing each
+	DedupePatches bool `yaml:"dedupe_patches"`
+
+	// DeepenShallow fetches missing history from origin when a shallow
+	// clone is too short for the requested commits
+	DeepenShallow bool `yaml:"deepen_shallow"`
+}
+
+// ScoreWeights weighs the parts of the suspicion score; only their ratios
+// matter
+type ScoreWeights struct {
+	LLM        float64 `yaml:"llm"`
+	Heuristics float64 `yaml:"heuristics"`
+	Recency    float64 `yaml:"recency"`
 }
 
 // PerformanceConfig contains performance-related settings
@@ -71,6 +289,68 @@ type PerformanceConfig struct {
 
 	// RetryMaxDelay is the maximum retry delay
 	RetryMaxDelay time.Duration `yaml:"retry_max_delay"`
+
+	// ProviderRetries overrides the retry settings above for calls to the
+	// named llm.provider, such as a provider with tighter rate limits
+	ProviderRetries map[string]RetryOverride `yaml:"provider_retries,omitempty"`
+
+	// ObjectCacheMB is the size in megabytes of the cache of decompressed
+	// git objects shared by the workers extracting diffs
+	ObjectCacheMB int `yaml:"object_cache_mb"`
+
+	// RunTimeout bounds a whole run; commits not analyzed in time are
+	// skipped (0: no limit)
+	RunTimeout time.Duration `yaml:"run_timeout"`
+}
+
+// RetryOverride replaces the retry settings of PerformanceConfig for one
+// provider; settings left out keep the performance values
+type RetryOverride struct {
+	// MaxRetries for failed API calls, if set
+	MaxRetries *int `yaml:"max_retries,omitempty"`
+
+	// RetryBaseDelay is the base delay for exponential backoff, if set
+	RetryBaseDelay time.Duration `yaml:"retry_base_delay,omitempty"`
+
+	// RetryMaxDelay is the maximum retry delay, if set
+	RetryMaxDelay time.Duration `yaml:"retry_max_delay,omitempty"`
+}
+
+// RetryPolicy is how failed LLM calls are retried (see analyzer.RetryConfig)
+type RetryPolicy struct {
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+}
+
+// Retry returns the retry policy of calls to llm.provider: the
+// performance settings, with those of its performance.provider_retries
+// entry in their place
+func (c *Config) Retry() RetryPolicy {
+	return c.retryFor(c.LLM.Provider)
+}
+
+// retryFor returns the retry policy of calls to provider
+func (c *Config) retryFor(provider string) RetryPolicy {
+	p := RetryPolicy{
+		MaxRetries: c.Performance.MaxRetries,
+		BaseDelay:  c.Performance.RetryBaseDelay,
+		MaxDelay:   c.Performance.RetryMaxDelay,
+	}
+	o, ok := c.Performance.ProviderRetries[provider]
+	if !ok {
+		return p
+	}
+	if o.MaxRetries != nil {
+		p.MaxRetries = *o.MaxRetries
+	}
+	if o.RetryBaseDelay > 0 {
+		p.BaseDelay = o.RetryBaseDelay
+	}
+	if o.RetryMaxDelay > 0 {
+		p.MaxDelay = o.RetryMaxDelay
+	}
+	return p
 }
 
 // OutputConfig contains output formatting settings
@@ -81,8 +361,203 @@ type OutputConfig struct {
 	// Verbose enables verbose logging
 	Verbose bool `yaml:"verbose"`
 
+	// LogFormat is the format of the CLI's logs on stderr (json, text)
+	LogFormat string `yaml:"log_format"`
+
+	// LogLevel is the least severe level logged (debug, info, warn, error)
+	LogLevel string `yaml:"log_level"`
+
 	// CommitMessageMaxLength for truncation
 	CommitMessageMaxLength int `yaml:"commit_message_max_length"`
+
+	// DebugDir receives the prompt and raw response of every LLM response
+	// that could not be parsed (empty: not written)
+	DebugDir string `yaml:"debug_dir"`
+}
+
+// HistoryConfig contains result history database settings
+type HistoryConfig struct {
+	// Enabled records every CLI run and MCP analysis in the history
+	// database
+	Enabled bool `yaml:"enabled"`
+
+	// Path is the SQLite database location
+	Path string `yaml:"path"`
+}
+
+// AuditConfig contains LLM interaction audit log settings
+type AuditConfig struct {
+	// Enabled appends every prompt and response hash to the audit log
+	Enabled bool `yaml:"enabled"`
+
+	// Path is the JSONL audit log location
+	Path string `yaml:"path"`
+}
+
+// CloneConfig contains settings for cloning remote repositories. Secrets
+// are read from the environment instead: GIT_TOKEN, GIT_PASSWORD, and
+// GIT_SSH_KEY_PASSPHRASE.
+type CloneConfig struct {
+	// Depth is how many commits of history to fetch (0: all of it)
+	Depth int `yaml:"depth"`
+
+	// SingleBranch fetches only the analyzed branch
+	SingleBranch bool `yaml:"single_branch"`
+
+	// Username for HTTPS basic auth
+	Username string `yaml:"username,omitempty"`
+
+	// SSHKey is the private key file for SSH remotes (default: SSH agent)
+	SSHKey string `yaml:"ssh_key,omitempty"`
+
+	// CacheDir keeps clones of remote repositories between runs, fetching
+	// new commits instead of cloning again (empty: clone into a temporary
+	// directory every run)
+	CacheDir string `yaml:"cache_dir,omitempty"`
+
+	// AllowedHosts, if set, are the only hosts remote repositories may be
+	// cloned from, as patterns such as *.example.com
+	AllowedHosts []string `yaml:"allowed_hosts,omitempty"`
+
+	// DeniedHosts are hosts remote repositories may not be cloned from,
+	// even if allowed
+	DeniedHosts []string `yaml:"denied_hosts,omitempty"`
+}
+
+// NetworkConfig contains settings for HTTPS connections to git remotes
+// and the LLM API. Proxies in HTTPS_PROXY, HTTP_PROXY, and NO_PROXY are
+// used when Proxy is empty.
+type NetworkConfig struct {
+	// Proxy is the http, https, or socks5 proxy URL for all connections
+	Proxy string `yaml:"proxy,omitempty"`
+
+	// CABundle is a PEM file of certificate authorities to trust in
+	// addition to the system roots
+	CABundle string `yaml:"ca_bundle,omitempty"`
+}
+
+// IssuesConfig names the trackers that the issue references in commit
+// messages are looked up in, for the titles of the issues. A tracker is
+// queried once its token reference is set. The GitHub settings are also
+// those of CIConfig.
+type IssuesConfig struct {
+	// GitHubRepo is the "owner/name" repository "#123" references are
+	// resolved in (empty: the one the origin remote points at)
+	GitHubRepo string `yaml:"github_repo,omitempty"`
+
+	// GitHubTokenRef points at a GitHub token that can read the
+	// repository's issues (and checks, for ci.enabled), like
+	// llm.api_key_ref
+	GitHubTokenRef string `yaml:"github_token_ref,omitempty"`
+
+	// GitHubAPI is the API URL of a GitHub Enterprise server (empty:
+	// https://api.github.com)
+	GitHubAPI string `yaml:"github_api,omitempty"`
+
+	// JiraURL is the Jira site "PROJ-42" keys are resolved on
+	JiraURL string `yaml:"jira_url,omitempty"`
+
+	// JiraUser is the Jira Cloud account email of JiraTokenRef's API
+	// token (empty: the token is a Data Center personal access token)
+	JiraUser string `yaml:"jira_user,omitempty"`
+
+	// JiraTokenRef points at the Jira token, like llm.api_key_ref
+	JiraTokenRef string `yaml:"jira_token_ref,omitempty"`
+
+	// LookupTimeout bounds each issue's lookup, and each commit's CI
+	// status lookup
+	LookupTimeout time.Duration `yaml:"lookup_timeout"`
+}
+
+// CIConfig turns on looking up whether each analyzed commit passed CI.
+// GitHub is queried with the issues section's GitHub settings.
+type CIConfig struct {
+	// Enabled looks up the GitHub checks and commit statuses, or the
+	// GitLab commit statuses, of each analyzed commit
+	Enabled bool `yaml:"enabled"`
+
+	// GitLabProject is the project path on GitLab, such as group/project
+	// (empty: the origin remote's, unless it is on GitHub)
+	GitLabProject string `yaml:"gitlab_project,omitempty"`
+
+	// GitLabTokenRef points at a GitLab token with the read_api scope,
+	// like llm.api_key_ref
+	GitLabTokenRef string `yaml:"gitlab_token_ref,omitempty"`
+
+	// GitLabAPI is the API URL of a self-managed GitLab (empty:
+	// https://gitlab.com/api/v4)
+	GitLabAPI string `yaml:"gitlab_api,omitempty"`
+}
+
+// MCPConfig restricts the repositories the MCP server's tools may open,
+// so exposing the server to an agent does not expose the whole machine
+type MCPConfig struct {
+	// AllowedRoots, if set, are the only directories local repositories
+	// (and file URLs) may be under, after symlinks are resolved
+	AllowedRoots []string `yaml:"allowed_roots,omitempty"`
+
+	// AllowRemote permits remote repository URLs, which are cloned
+	AllowRemote bool `yaml:"allow_remote"`
+
+	// Listen is the address to serve MCP over HTTP on, such as :8090
+	// (empty: stdio)
+	Listen string `yaml:"listen,omitempty"`
+
+	// AuthTokenRef points at the bearer token HTTP clients must send,
+	// like llm.api_key_ref (empty: no authentication)
+	AuthTokenRef string `yaml:"auth_token_ref,omitempty"`
+
+	// MaxConcurrentAnalyses caps the analyses one client runs at once
+	// (0: no cap)
+	MaxConcurrentAnalyses int `yaml:"max_concurrent_analyses"`
+
+	// MaxCommits caps num_commits per call (0: the validator's limit)
+	MaxCommits int `yaml:"max_commits"`
+
+	// DailyTokenLimit caps the prompt and output tokens one client's
+	// LLM calls spend per day, in UTC (0: no cap)
+	DailyTokenLimit int `yaml:"daily_token_limit"`
+
+	// AllowedModels are the models a tool call may pick with its model
+	// argument, besides llm.model (empty: every call uses llm.model)
+	AllowedModels []string `yaml:"allowed_models,omitempty"`
+
+	// AllowedProviders are the providers a tool call may pick with its
+	// provider argument, besides llm.provider, such as heuristic
+	AllowedProviders []string `yaml:"allowed_providers,omitempty"`
+
+	// MaxResults caps the commit results an analysis returns, keeping the
+	// most suspicious; its report lists them all (0: no cap)
+	MaxResults int `yaml:"max_results"`
+
+	// MaxReasoningLength truncates each result's reasoning in tool output
+	// to this many characters; reports keep it whole (0: no truncation)
+	MaxReasoningLength int `yaml:"max_reasoning_length"`
+}
+
+// ServeConfig restricts who may use the serve daemon's REST and gRPC APIs
+// and which repositories they may open, and bounds what it keeps in memory
+type ServeConfig struct {
+	// AllowedRoots, if set, are the only directories job repositories may
+	// be under, after symlinks are resolved
+	AllowedRoots []string `yaml:"allowed_roots,omitempty"`
+
+	// AuthTokenRef points at the bearer token REST and gRPC clients must
+	// send, like llm.api_key_ref (empty: no authentication, and loopback
+	// addresses only)
+	AuthTokenRef string `yaml:"auth_token_ref,omitempty"`
+
+	// JobRetention is how long a finished job stays available at
+	// /v1/jobs/{id}; its report outlives it in the report store
+	JobRetention time.Duration `yaml:"job_retention"`
+
+	// MaxReports caps the reports kept in memory, dropping the oldest
+	// (0: no cap)
+	MaxReports int `yaml:"max_reports"`
+
+	// MaxConcurrentJobs caps the jobs running at once; submissions over
+	// it are refused (0: no cap)
+	MaxConcurrentJobs int `yaml:"max_concurrent_jobs"`
 }
 
 // DefaultConfig returns sensible default configuration
@@ -93,11 +568,35 @@ func DefaultConfig() *Config {
 			Model:       "gemini-flash-latest",
 			Temperature: 0.1,
 			Timeout:     10 * time.Minute,
+			Stream:      true,
+
+			ContextCacheTTL:    time.Hour,
+			ContextCacheTokens: 100000,
+
+			BatchPollInterval: 30 * time.Second,
+			BatchTimeout:      24 * time.Hour,
+
+			KeyRotation: "round_robin",
 		},
 		Analysis: AnalysisConfig{
 			DefaultCommits:   5,
 			MaxDiffSize:      50000,
+			MaxFullDiffSize:  50000,
+			MaxDiffTokens:    12500,
+			MaxChunks:        4,
+			DiffBackend:      "go-git",
+			FullFileMaxBytes: 4096,
 			SkipMergeCommits: true,
+			SuggestOwners:    true,
+			AffectedTests:    true,
+			HotspotHistory:   500,
+			TechStack:        true,
+			VerifyTop:        3,
+			VerifyTimeout:    10 * time.Minute,
+			ScoreWeights:     ScoreWeights{LLM: 0.6, Heuristics: 0.25, Recency: 0.15},
+			Reasoning:        "standard",
+			DedupePatches:    true,
+			DeepenShallow:    true,
 			FileFilters:      []string{},
 		},
 		Performance: PerformanceConfig{
@@ -105,11 +604,35 @@ func DefaultConfig() *Config {
 			MaxRetries:     3,
 			RetryBaseDelay: 1 * time.Second,
 			RetryMaxDelay:  30 * time.Second,
+			ObjectCacheMB:  96,
 		},
 		Output: OutputConfig{
 			Format:                 "json",
 			Verbose:                false,
+			LogFormat:              "json",
+			LogLevel:               "info",
 			CommitMessageMaxLength: 80,
+			DebugDir:               "~/.local/share/git-dual-context/debug",
+		},
+		History: HistoryConfig{
+			Enabled: true,
+			Path:    "~/.local/share/git-dual-context/history.db",
+		},
+		Audit: AuditConfig{
+			Enabled: false,
+			Path:    "~/.local/share/git-dual-context/audit.jsonl",
+		},
+		Issues: IssuesConfig{
+			LookupTimeout: 10 * time.Second,
+		},
+		MCP: MCPConfig{
+			AllowRemote: true,
+			MaxResults:  25,
+		},
+		Serve: ServeConfig{
+			JobRetention:      time.Hour,
+			MaxReports:        100,
+			MaxConcurrentJobs: 4,
 		},
 	}
 }
@@ -257,6 +780,42 @@ func (c *Config) Validate() error {
 	if c.LLM.Timeout <= 0 {
 		return fmt.Errorf("llm.timeout must be positive, got %v", c.LLM.Timeout)
 	}
+	if c.LLM.APIKeyRef != "" {
+		if c.LLM.APIKey != "" {
+			return fmt.Errorf("set only one of llm.api_key and llm.api_key_ref")
+		}
+		if err := secret.Validate(c.LLM.APIKeyRef); err != nil {
+			return fmt.Errorf("llm.api_key_ref: %w", err)
+		}
+	}
+	if len(c.LLM.APIKeys) > 0 && (c.LLM.APIKey != "" || c.LLM.APIKeyRef != "") {
+		return fmt.Errorf("set only one of llm.api_keys and llm.api_key or llm.api_key_ref")
+	}
+	for i, k := range c.LLM.APIKeys {
+		if (k.Ref == "") == (k.Key == "") {
+			return fmt.Errorf("llm.api_keys[%d] must set one of ref and key", i)
+		}
+		if k.Ref != "" {
+			if err := secret.Validate(k.Ref); err != nil {
+				return fmt.Errorf("llm.api_keys[%d].ref: %w", i, err)
+			}
+		}
+		if k.RequestsPerMinute < 0 {
+			return fmt.Errorf("llm.api_keys[%d].requests_per_minute cannot be negative, got %d", i, k.RequestsPerMinute)
+		}
+	}
+	if c.LLM.KeyRotation != "round_robin" && c.LLM.KeyRotation != "failover" {
+		return fmt.Errorf("llm.key_rotation must be round_robin or failover, got %q", c.LLM.KeyRotation)
+	}
+	if c.LLM.ContextCache && c.LLM.ContextCacheTTL <= 0 {
+		return fmt.Errorf("llm.context_cache_ttl must be positive, got %v", c.LLM.ContextCacheTTL)
+	}
+	if c.LLM.ContextCacheTokens < 0 {
+		return fmt.Errorf("llm.context_cache_tokens cannot be negative, got %d", c.LLM.ContextCacheTokens)
+	}
+	if c.LLM.Batch && (c.LLM.BatchPollInterval <= 0 || c.LLM.BatchTimeout <= 0) {
+		return fmt.Errorf("llm.batch_poll_interval and llm.batch_timeout must be positive, got %v and %v", c.LLM.BatchPollInterval, c.LLM.BatchTimeout)
+	}
 
 	// Validate Analysis config
 	if c.Analysis.DefaultCommits <= 0 {
@@ -265,6 +824,58 @@ func (c *Config) Validate() error {
 	if c.Analysis.MaxDiffSize <= 0 {
 		return fmt.Errorf("analysis.max_diff_size must be positive, got %d", c.Analysis.MaxDiffSize)
 	}
+	if c.Analysis.MaxFullDiffSize <= 0 {
+		return fmt.Errorf("analysis.max_full_diff_size must be positive, got %d", c.Analysis.MaxFullDiffSize)
+	}
+	if c.Analysis.MaxPromptDiffSize < 0 {
+		return fmt.Errorf("analysis.max_prompt_diff_size cannot be negative, got %d", c.Analysis.MaxPromptDiffSize)
+	}
+	if c.Analysis.MaxDiffTokens <= 0 {
+		return fmt.Errorf("analysis.max_diff_tokens must be positive, got %d", c.Analysis.MaxDiffTokens)
+	}
+	if c.Analysis.MaxChunks <= 0 {
+		return fmt.Errorf("analysis.max_chunks must be positive, got %d", c.Analysis.MaxChunks)
+	}
+	switch c.Analysis.DiffBackend {
+	case "", "go-git", "git", "auto":
+	default:
+		return fmt.Errorf("analysis.diff_backend must be go-git, git, or auto, got %q", c.Analysis.DiffBackend)
+	}
+	switch c.Analysis.Reasoning {
+	case "", "short", "standard", "verbose":
+	default:
+		return fmt.Errorf("analysis.reasoning must be short, standard, or verbose, got %q", c.Analysis.Reasoning)
+	}
+	validProfiles := map[string]bool{"go": true, "node": true, "python": true, "jvm": true, "monorepo": true, "auto": true}
+	for _, p := range c.Analysis.FilterProfiles {
+		if !validProfiles[p] {
+			return fmt.Errorf("analysis.filter_profiles must be go, node, python, jvm, monorepo, or auto, got %q", p)
+		}
+	}
+	if w := c.Analysis.ScoreWeights; w.LLM < 0 || w.Heuristics < 0 || w.Recency < 0 || w.LLM+w.Heuristics+w.Recency == 0 {
+		return fmt.Errorf("analysis.score_weights must be non-negative and not all zero, got llm=%g heuristics=%g recency=%g", w.LLM, w.Heuristics, w.Recency)
+	}
+	if c.Analysis.HotspotHistory < 0 {
+		return fmt.Errorf("analysis.hotspot_history cannot be negative, got %d", c.Analysis.HotspotHistory)
+	}
+	if c.Analysis.RelatedFiles < 0 {
+		return fmt.Errorf("analysis.related_files cannot be negative, got %d", c.Analysis.RelatedFiles)
+	}
+	if c.Analysis.VerifyTop <= 0 {
+		return fmt.Errorf("analysis.verify_top must be positive, got %d", c.Analysis.VerifyTop)
+	}
+	if c.Analysis.VerifyTimeout <= 0 {
+		return fmt.Errorf("analysis.verify_timeout must be positive, got %v", c.Analysis.VerifyTimeout)
+	}
+	if c.Analysis.MinChangedLines < 0 {
+		return fmt.Errorf("analysis.min_changed_lines cannot be negative, got %d", c.Analysis.MinChangedLines)
+	}
+	if c.Analysis.ContextLines < 0 {
+		return fmt.Errorf("analysis.context_lines cannot be negative, got %d", c.Analysis.ContextLines)
+	}
+	if c.Analysis.FullFileMaxBytes < 0 {
+		return fmt.Errorf("analysis.full_file_max_bytes cannot be negative, got %d", c.Analysis.FullFileMaxBytes)
+	}
 
 	// Validate Performance config
 	if c.Performance.Workers <= 0 {
@@ -273,12 +884,185 @@ func (c *Config) Validate() error {
 	if c.Performance.MaxRetries < 0 {
 		return fmt.Errorf("performance.max_retries cannot be negative, got %d", c.Performance.MaxRetries)
 	}
+	if c.Performance.RetryBaseDelay <= 0 {
+		return fmt.Errorf("performance.retry_base_delay must be positive, got %v", c.Performance.RetryBaseDelay)
+	}
+	if c.Performance.RetryMaxDelay < c.Performance.RetryBaseDelay {
+		return fmt.Errorf("performance.retry_max_delay (%v) cannot be less than retry_base_delay (%v)", c.Performance.RetryMaxDelay, c.Performance.RetryBaseDelay)
+	}
+	for _, provider := range slices.Sorted(maps.Keys(c.Performance.ProviderRetries)) {
+		o := c.Performance.ProviderRetries[provider]
+		if o.MaxRetries != nil && *o.MaxRetries < 0 {
+			return fmt.Errorf("performance.provider_retries.%s.max_retries cannot be negative, got %d", provider, *o.MaxRetries)
+		}
+		if o.RetryBaseDelay < 0 || o.RetryMaxDelay < 0 {
+			return fmt.Errorf("performance.provider_retries.%s: retry delays cannot be negative", provider)
+		}
+		if p := c.retryFor(provider); p.MaxDelay < p.BaseDelay {
+			return fmt.Errorf("performance.provider_retries.%s: retry_max_delay (%v) cannot be less than retry_base_delay (%v)", provider, p.MaxDelay, p.BaseDelay)
+		}
+	}
+	if c.Performance.ObjectCacheMB < 0 {
+		return fmt.Errorf("performance.object_cache_mb cannot be negative, got %d", c.Performance.ObjectCacheMB)
+	}
+	if c.Performance.RunTimeout < 0 {
+		return fmt.Errorf("performance.run_timeout cannot be negative, got %v", c.Performance.RunTimeout)
+	}
+
+	// Validate Clone config
+	if c.Clone.Depth < 0 {
+		return fmt.Errorf("clone.depth cannot be negative, got %d", c.Clone.Depth)
+	}
+	for _, p := range c.Clone.AllowedHosts {
+		if err := validator.ValidateHostPattern(p); err != nil {
+			return fmt.Errorf("clone.allowed_hosts: %w", err)
+		}
+	}
+	for _, p := range c.Clone.DeniedHosts {
+		if err := validator.ValidateHostPattern(p); err != nil {
+			return fmt.Errorf("clone.denied_hosts: %w", err)
+		}
+	}
+
+	// Validate Network config
+	if c.Network.Proxy != "" {
+		if _, err := network.ParseProxy(c.Network.Proxy); err != nil {
+			return fmt.Errorf("network.proxy: %w", err)
+		}
+	}
+
+	// Validate Issues and CI config
+	if c.Issues.GitHubRepo != "" && issues.GitHubRepo("github.com/"+c.Issues.GitHubRepo) != c.Issues.GitHubRepo {
+		return fmt.Errorf("issues.github_repo must be owner/name, got %q", c.Issues.GitHubRepo)
+	}
+	if c.Issues.GitHubTokenRef != "" {
+		if err := secret.Validate(c.Issues.GitHubTokenRef); err != nil {
+			return fmt.Errorf("issues.github_token_ref: %w", err)
+		}
+	}
+	for _, f := range []struct{ name, value string }{{"issues.github_api", c.Issues.GitHubAPI}, {"issues.jira_url", c.Issues.JiraURL}, {"ci.gitlab_api", c.CI.GitLabAPI}} {
+		if f.value == "" {
+			continue
+		}
+		if u, err := url.Parse(f.value); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("%s must be an http(s) URL, got %q", f.name, f.value)
+		}
+	}
+	if c.Issues.JiraTokenRef != "" {
+		if c.Issues.JiraURL == "" {
+			return fmt.Errorf("issues.jira_token_ref needs issues.jira_url")
+		}
+		if err := secret.Validate(c.Issues.JiraTokenRef); err != nil {
+			return fmt.Errorf("issues.jira_token_ref: %w", err)
+		}
+	}
+	if c.Issues.LookupTimeout < 0 {
+		return fmt.Errorf("issues.lookup_timeout cannot be negative")
+	}
+	if c.CI.GitLabTokenRef != "" {
+		if err := secret.Validate(c.CI.GitLabTokenRef); err != nil {
+			return fmt.Errorf("ci.gitlab_token_ref: %w", err)
+		}
+	}
+
+	// Validate MCP config
+	for _, root := range c.MCP.AllowedRoots {
+		if err := validator.ValidateRoot(root); err != nil {
+			return fmt.Errorf("mcp.allowed_roots: %w", err)
+		}
+	}
+	if c.MCP.Listen != "" {
+		if _, _, err := net.SplitHostPort(c.MCP.Listen); err != nil {
+			return fmt.Errorf("mcp.listen: %w", err)
+		}
+	}
+	if c.MCP.AuthTokenRef != "" {
+		if err := secret.Validate(c.MCP.AuthTokenRef); err != nil {
+			return fmt.Errorf("mcp.auth_token_ref: %w", err)
+		}
+	}
+	if c.MCP.MaxConcurrentAnalyses < 0 {
+		return fmt.Errorf("mcp.max_concurrent_analyses cannot be negative")
+	}
+	if c.MCP.MaxCommits < 0 {
+		return fmt.Errorf("mcp.max_commits cannot be negative")
+	}
+	if c.MCP.DailyTokenLimit < 0 {
+		return fmt.Errorf("mcp.daily_token_limit cannot be negative")
+	}
+	if c.MCP.MaxResults < 0 {
+		return fmt.Errorf("mcp.max_results cannot be negative")
+	}
+	if c.MCP.MaxReasoningLength < 0 {
+		return fmt.Errorf("mcp.max_reasoning_length cannot be negative")
+	}
+	for _, model := range c.MCP.AllowedModels {
+		if strings.TrimSpace(model) == "" {
+			return fmt.Errorf("mcp.allowed_models cannot contain an empty model name")
+		}
+	}
+	for _, provider := range c.MCP.AllowedProviders {
+		if strings.TrimSpace(provider) == "" {
+			return fmt.Errorf("mcp.allowed_providers cannot contain an empty provider name")
+		}
+	}
+
+	// Validate serve config
+	for _, root := range c.Serve.AllowedRoots {
+		if err := validator.ValidateRoot(root); err != nil {
+			return fmt.Errorf("serve.allowed_roots: %w", err)
+		}
+	}
+	if c.Serve.AuthTokenRef != "" {
+		if err := secret.Validate(c.Serve.AuthTokenRef); err != nil {
+			return fmt.Errorf("serve.auth_token_ref: %w", err)
+		}
+	}
+	if c.Serve.JobRetention <= 0 {
+		return fmt.Errorf("serve.job_retention must be positive")
+	}
+	if c.Serve.MaxReports < 0 {
+		return fmt.Errorf("serve.max_reports cannot be negative")
+	}
+	if c.Serve.MaxConcurrentJobs < 0 {
+		return fmt.Errorf("serve.max_concurrent_jobs cannot be negative")
+	}
+
+	// Validate History config
+	if c.History.Enabled && c.History.Path == "" {
+		return fmt.Errorf("history.path cannot be empty when history is enabled")
+	}
+
+	// Validate Audit config
+	if c.Audit.Enabled && c.Audit.Path == "" {
+		return fmt.Errorf("audit.path cannot be empty when audit is enabled")
+	}
 
 	// Validate Output config
 	validFormats := map[string]bool{"json": true, "text": true, "markdown": true}
 	if !validFormats[c.Output.Format] {
 		return fmt.Errorf("output.format must be json, text, or markdown, got %s", c.Output.Format)
 	}
+	if c.Output.LogFormat != "json" && c.Output.LogFormat != "text" {
+		return fmt.Errorf("output.log_format must be json or text, got %s", c.Output.LogFormat)
+	}
+	validLevels := map[string]bool{"debug": true, "info": true, "warn": true, "error": true}
+	if !validLevels[c.Output.LogLevel] {
+		return fmt.Errorf("output.log_level must be debug, info, warn, or error, got %s", c.Output.LogLevel)
+	}
+
+	// Validate each profile applied over this config
+	for _, name := range slices.Sorted(maps.Keys(c.Profiles)) {
+		profiled := *c
+		err := profiled.ApplyProfile(name)
+		if err == nil {
+			profiled.Profiles = nil
+			err = profiled.Validate()
+		}
+		if err != nil {
+			return fmt.Errorf("profiles.%s: %w", name, err)
+		}
+	}
 
 	return nil
 }
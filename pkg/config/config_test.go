@@ -20,6 +20,18 @@ func TestDefaultConfig(t *testing.T) {
 	if cfg.LLM.Temperature != 0.1 {
 		t.Errorf("Expected default temperature 0.1, got %f", cfg.LLM.Temperature)
 	}
+	if cfg.LLM.KeyRotation != "round_robin" {
+		t.Errorf("Expected default key rotation 'round_robin', got %s", cfg.LLM.KeyRotation)
+	}
+	if !cfg.LLM.Stream {
+		t.Error("Expected Stream to be true by default")
+	}
+	if cfg.LLM.ContextCache || cfg.LLM.ContextCacheTTL != time.Hour {
+		t.Errorf("Expected context cache off with a 1h TTL by default, got %v, %v", cfg.LLM.ContextCache, cfg.LLM.ContextCacheTTL)
+	}
+	if cfg.LLM.Batch || cfg.LLM.BatchTimeout != 24*time.Hour {
+		t.Errorf("Expected batch mode off with a 24h timeout by default, got %v, %v", cfg.LLM.Batch, cfg.LLM.BatchTimeout)
+	}
 
 	// Verify Analysis defaults
 	if cfg.Analysis.DefaultCommits != 5 {
@@ -28,16 +40,69 @@ func TestDefaultConfig(t *testing.T) {
 	if !cfg.Analysis.SkipMergeCommits {
 		t.Error("Expected SkipMergeCommits to be true by default")
 	}
+	if cfg.Analysis.MaxDiffSize != 50000 || cfg.Analysis.MaxFullDiffSize != 50000 || cfg.Analysis.MaxPromptDiffSize != 0 {
+		t.Errorf("Expected diff size limits 50000, 50000, and 0, got %d, %d, and %d", cfg.Analysis.MaxDiffSize, cfg.Analysis.MaxFullDiffSize, cfg.Analysis.MaxPromptDiffSize)
+	}
+	if !cfg.Analysis.SuggestOwners {
+		t.Error("Expected SuggestOwners to be true by default")
+	}
+	if !cfg.Analysis.TechStack {
+		t.Error("Expected TechStack to be true by default")
+	}
+	if !cfg.Analysis.AffectedTests {
+		t.Error("Expected AffectedTests to be true by default")
+	}
+	if cfg.Analysis.VerifyTop != 3 || cfg.Analysis.VerifyTimeout != 10*time.Minute {
+		t.Errorf("Expected verification of 3 commits for up to 10m each, got %d and %v", cfg.Analysis.VerifyTop, cfg.Analysis.VerifyTimeout)
+	}
+	if !cfg.Analysis.DedupePatches {
+		t.Error("Expected DedupePatches to be true by default")
+	}
+	if !cfg.Analysis.DeepenShallow {
+		t.Error("Expected DeepenShallow to be true by default")
+	}
 
 	// Verify Performance defaults
 	if cfg.Performance.Workers != 3 {
 		t.Errorf("Expected default workers 3, got %d", cfg.Performance.Workers)
 	}
+	if cfg.Performance.ObjectCacheMB != 96 {
+		t.Errorf("Expected default object cache 96 MB, got %d", cfg.Performance.ObjectCacheMB)
+	}
+	if cfg.Performance.RunTimeout != 0 {
+		t.Errorf("Expected no default run timeout, got %v", cfg.Performance.RunTimeout)
+	}
 
 	// Verify Output defaults
 	if cfg.Output.Format != "json" {
 		t.Errorf("Expected default format 'json', got %s", cfg.Output.Format)
 	}
+	if cfg.Output.DebugDir == "" {
+		t.Error("Expected a default debug directory")
+	}
+	if cfg.Output.LogFormat != "json" || cfg.Output.LogLevel != "info" {
+		t.Errorf("Expected json logs at info level, got %s at %s", cfg.Output.LogFormat, cfg.Output.LogLevel)
+	}
+
+	// Verify History defaults
+	if !cfg.History.Enabled || cfg.History.Path == "" {
+		t.Errorf("Expected history enabled with a default path, got %+v", cfg.History)
+	}
+
+	// Verify Audit defaults
+	if cfg.Audit.Enabled || cfg.Audit.Path == "" {
+		t.Errorf("Expected audit disabled with a default path, got %+v", cfg.Audit)
+	}
+
+	// Verify MCP defaults
+	if !cfg.MCP.AllowRemote || len(cfg.MCP.AllowedRoots) != 0 {
+		t.Errorf("Expected the MCP server unrestricted, got %+v", cfg.MCP)
+	}
+
+	// Verify serve defaults
+	if cfg.Serve.AuthTokenRef != "" || cfg.Serve.JobRetention != time.Hour || cfg.Serve.MaxReports != 100 || cfg.Serve.MaxConcurrentJobs != 4 {
+		t.Errorf("Unexpected serve defaults: %+v", cfg.Serve)
+	}
 }
 
 func TestLoadConfig(t *testing.T) {
@@ -215,6 +280,72 @@ func TestValidate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "api key reference",
+			setup: func(c *Config) {
+				c.LLM.APIKeyRef = "keyring:git-dual-context/gemini"
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid api key reference",
+			setup: func(c *Config) {
+				c.LLM.APIKeyRef = "GEMINI_API_KEY"
+			},
+			wantErr: true,
+		},
+		{
+			name: "api key and reference",
+			setup: func(c *Config) {
+				c.LLM.APIKey = "key"
+				c.LLM.APIKeyRef = "env:GEMINI_KEY"
+			},
+			wantErr: true,
+		},
+		{
+			name: "several api keys",
+			setup: func(c *Config) {
+				c.LLM.APIKeys = []APIKeyConfig{{Ref: "env:KEY_A", RequestsPerMinute: 60}, {Key: "key-b"}}
+				c.LLM.KeyRotation = "failover"
+			},
+			wantErr: false,
+		},
+		{
+			name: "api keys and api key reference",
+			setup: func(c *Config) {
+				c.LLM.APIKeys = []APIKeyConfig{{Ref: "env:KEY_A"}}
+				c.LLM.APIKeyRef = "env:GEMINI_KEY"
+			},
+			wantErr: true,
+		},
+		{
+			name: "api keys entry without key",
+			setup: func(c *Config) {
+				c.LLM.APIKeys = []APIKeyConfig{{Name: "team-a"}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "api keys entry with invalid reference",
+			setup: func(c *Config) {
+				c.LLM.APIKeys = []APIKeyConfig{{Ref: "vault:team-a"}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative requests per minute",
+			setup: func(c *Config) {
+				c.LLM.APIKeys = []APIKeyConfig{{Key: "key-a", RequestsPerMinute: -1}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown key rotation",
+			setup: func(c *Config) {
+				c.LLM.KeyRotation = "random"
+			},
+			wantErr: true,
+		},
 		{
 			name: "invalid temperature low",
 			setup: func(c *Config) {
@@ -243,6 +374,230 @@ func TestValidate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "zero max diff tokens",
+			setup: func(c *Config) {
+				c.Analysis.MaxDiffTokens = 0
+			},
+			wantErr: true,
+		},
+		{
+			name: "zero max full diff size",
+			setup: func(c *Config) {
+				c.Analysis.MaxFullDiffSize = 0
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative max prompt diff size",
+			setup: func(c *Config) {
+				c.Analysis.MaxPromptDiffSize = -1
+			},
+			wantErr: true,
+		},
+		{
+			name: "zero max chunks",
+			setup: func(c *Config) {
+				c.Analysis.MaxChunks = 0
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown diff backend",
+			setup: func(c *Config) {
+				c.Analysis.DiffBackend = "svn"
+			},
+			wantErr: true,
+		},
+		{
+			name: "short reasoning",
+			setup: func(c *Config) {
+				c.Analysis.Reasoning = "short"
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown reasoning verbosity",
+			setup: func(c *Config) {
+				c.Analysis.Reasoning = "terse"
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown filter profile",
+			setup: func(c *Config) {
+				c.Analysis.FilterProfiles = []string{"go", "cobol"}
+			},
+			wantErr: true,
+		},
+		{
+			name: "auto filter profile",
+			setup: func(c *Config) {
+				c.Analysis.FilterProfiles = []string{"auto"}
+			},
+			wantErr: false,
+		},
+		{
+			name: "negative score weight",
+			setup: func(c *Config) {
+				c.Analysis.ScoreWeights.Recency = -0.1
+			},
+			wantErr: true,
+		},
+		{
+			name: "zero score weights",
+			setup: func(c *Config) {
+				c.Analysis.ScoreWeights = ScoreWeights{}
+			},
+			wantErr: true,
+		},
+		{
+			name: "llm-only score weights",
+			setup: func(c *Config) {
+				c.Analysis.ScoreWeights = ScoreWeights{LLM: 1}
+			},
+			wantErr: false,
+		},
+		{
+			name: "issue trackers",
+			setup: func(c *Config) {
+				c.Issues.GitHubRepo = "acme/api"
+				c.Issues.GitHubTokenRef = "env:GITHUB_TOKEN"
+				c.Issues.JiraURL = "https://acme.atlassian.net"
+				c.Issues.JiraTokenRef = "keyring:jira/dana"
+			},
+			wantErr: false,
+		},
+		{
+			name: "gitlab ci statuses",
+			setup: func(c *Config) {
+				c.CI.Enabled = true
+				c.CI.GitLabTokenRef = "env:GITLAB_TOKEN"
+				c.CI.GitLabAPI = "https://gitlab.example.com/api/v4"
+			},
+			wantErr: false,
+		},
+		{
+			name: "bad gitlab token ref",
+			setup: func(c *Config) {
+				c.CI.GitLabTokenRef = "GITLAB_TOKEN"
+			},
+			wantErr: true,
+		},
+		{
+			name: "github repo not owner/name",
+			setup: func(c *Config) {
+				c.Issues.GitHubRepo = "https://github.com/acme/api"
+			},
+			wantErr: true,
+		},
+		{
+			name: "jira token without url",
+			setup: func(c *Config) {
+				c.Issues.JiraTokenRef = "env:JIRA_TOKEN"
+			},
+			wantErr: true,
+		},
+		{
+			name: "jira url without scheme",
+			setup: func(c *Config) {
+				c.Issues.JiraURL = "acme.atlassian.net"
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative hotspot history",
+			setup: func(c *Config) {
+				c.Analysis.HotspotHistory = -1
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative related files",
+			setup: func(c *Config) {
+				c.Analysis.RelatedFiles = -1
+			},
+			wantErr: true,
+		},
+		{
+			name: "zero verify top",
+			setup: func(c *Config) {
+				c.Analysis.VerifyTop = 0
+			},
+			wantErr: true,
+		},
+		{
+			name: "zero verify timeout",
+			setup: func(c *Config) {
+				c.Analysis.VerifyTimeout = 0
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative min changed lines",
+			setup: func(c *Config) {
+				c.Analysis.MinChangedLines = -1
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative context lines",
+			setup: func(c *Config) {
+				c.Analysis.ContextLines = -1
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative full file max bytes",
+			setup: func(c *Config) {
+				c.Analysis.FullFileMaxBytes = -1
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative clone depth",
+			setup: func(c *Config) {
+				c.Clone.Depth = -1
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid proxy",
+			setup: func(c *Config) {
+				c.Network.Proxy = "http://proxy.corp:3128"
+			},
+			wantErr: false,
+		},
+		{
+			name: "proxy without scheme",
+			setup: func(c *Config) {
+				c.Network.Proxy = "proxy.corp:3128"
+			},
+			wantErr: true,
+		},
+		{
+			name: "context cache without ttl",
+			setup: func(c *Config) {
+				c.LLM.ContextCache = true
+				c.LLM.ContextCacheTTL = 0
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative context cache tokens",
+			setup: func(c *Config) {
+				c.LLM.ContextCacheTokens = -1
+			},
+			wantErr: true,
+		},
+		{
+			name: "batch without timeout",
+			setup: func(c *Config) {
+				c.LLM.Batch = true
+				c.LLM.BatchTimeout = 0
+			},
+			wantErr: true,
+		},
 		{
 			name: "zero workers",
 			setup: func(c *Config) {
@@ -250,6 +605,43 @@ func TestValidate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "negative object cache",
+			setup: func(c *Config) {
+				c.Performance.ObjectCacheMB = -1
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative run timeout",
+			setup: func(c *Config) {
+				c.Performance.RunTimeout = -time.Minute
+			},
+			wantErr: true,
+		},
+		{
+			name: "history enabled without path",
+			setup: func(c *Config) {
+				c.History.Path = ""
+			},
+			wantErr: true,
+		},
+		{
+			name: "history disabled without path",
+			setup: func(c *Config) {
+				c.History.Enabled = false
+				c.History.Path = ""
+			},
+			wantErr: false,
+		},
+		{
+			name: "audit enabled without path",
+			setup: func(c *Config) {
+				c.Audit.Enabled = true
+				c.Audit.Path = ""
+			},
+			wantErr: true,
+		},
 		{
 			name: "invalid output format",
 			setup: func(c *Config) {
@@ -257,6 +649,204 @@ func TestValidate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "invalid log format",
+			setup: func(c *Config) {
+				c.Output.LogFormat = "xml"
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid log level",
+			setup: func(c *Config) {
+				c.Output.LogLevel = "trace"
+			},
+			wantErr: true,
+		},
+		{
+			name: "clone host lists",
+			setup: func(c *Config) {
+				c.Clone.AllowedHosts = []string{"github.com", "*.corp.example"}
+				c.Clone.DeniedHosts = []string{"gist.github.com"}
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid allowed host pattern",
+			setup: func(c *Config) {
+				c.Clone.AllowedHosts = []string{"git[.example"}
+			},
+			wantErr: true,
+		},
+		{
+			name: "empty denied host pattern",
+			setup: func(c *Config) {
+				c.Clone.DeniedHosts = []string{""}
+			},
+			wantErr: true,
+		},
+		{
+			name: "mcp allowed roots",
+			setup: func(c *Config) {
+				c.MCP.AllowedRoots = []string{"/src", "~/work"}
+			},
+			wantErr: false,
+		},
+		{
+			name: "relative mcp allowed root",
+			setup: func(c *Config) {
+				c.MCP.AllowedRoots = []string{"src"}
+			},
+			wantErr: true,
+		},
+		{
+			name: "mcp http listener with auth token",
+			setup: func(c *Config) {
+				c.MCP.Listen = ":8090"
+				c.MCP.AuthTokenRef = "env:GDC_MCP_TOKEN"
+			},
+			wantErr: false,
+		},
+		{
+			name: "mcp listen address without port",
+			setup: func(c *Config) {
+				c.MCP.Listen = "localhost"
+			},
+			wantErr: true,
+		},
+		{
+			name: "mcp session limits",
+			setup: func(c *Config) {
+				c.MCP.MaxConcurrentAnalyses = 2
+				c.MCP.MaxCommits = 20
+				c.MCP.DailyTokenLimit = 1000000
+			},
+			wantErr: false,
+		},
+		{
+			name: "negative mcp daily token limit",
+			setup: func(c *Config) {
+				c.MCP.DailyTokenLimit = -1
+			},
+			wantErr: true,
+		},
+		{
+			name: "mcp allowed models and providers",
+			setup: func(c *Config) {
+				c.MCP.AllowedModels = []string{"gemini-2.5-flash-lite", "gemini-2.5-pro"}
+				c.MCP.AllowedProviders = []string{ProviderHeuristic}
+			},
+			wantErr: false,
+		},
+		{
+			name: "negative mcp max results",
+			setup: func(c *Config) {
+				c.MCP.MaxResults = -1
+			},
+			wantErr: true,
+		},
+		{
+			name: "mcp reasoning truncation",
+			setup: func(c *Config) {
+				c.MCP.MaxResults = 0
+				c.MCP.MaxReasoningLength = 500
+			},
+			wantErr: false,
+		},
+		{
+			name: "empty mcp allowed model",
+			setup: func(c *Config) {
+				c.MCP.AllowedModels = []string{"gemini-2.5-pro", " "}
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid mcp auth token reference",
+			setup: func(c *Config) {
+				c.MCP.AuthTokenRef = "GDC_MCP_TOKEN"
+			},
+			wantErr: true,
+		},
+		{
+			name: "serve allowed roots and auth token",
+			setup: func(c *Config) {
+				c.Serve.AllowedRoots = []string{"/src"}
+				c.Serve.AuthTokenRef = "env:GDC_SERVE_TOKEN"
+				c.Serve.MaxReports = 0
+			},
+			wantErr: false,
+		},
+		{
+			name: "relative serve allowed root",
+			setup: func(c *Config) {
+				c.Serve.AllowedRoots = []string{"src"}
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid serve auth token reference",
+			setup: func(c *Config) {
+				c.Serve.AuthTokenRef = "GDC_SERVE_TOKEN"
+			},
+			wantErr: true,
+		},
+		{
+			name: "zero serve job retention",
+			setup: func(c *Config) {
+				c.Serve.JobRetention = 0
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative serve max reports",
+			setup: func(c *Config) {
+				c.Serve.MaxReports = -1
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative serve max concurrent jobs",
+			setup: func(c *Config) {
+				c.Serve.MaxConcurrentJobs = -1
+			},
+			wantErr: true,
+		},
+		{
+			name: "zero retry base delay",
+			setup: func(c *Config) {
+				c.Performance.RetryBaseDelay = 0
+			},
+			wantErr: true,
+		},
+		{
+			name: "retry max delay below base delay",
+			setup: func(c *Config) {
+				c.Performance.RetryMaxDelay = 500 * time.Millisecond
+			},
+			wantErr: true,
+		},
+		{
+			name: "provider retry override",
+			setup: func(c *Config) {
+				c.Performance.ProviderRetries = map[string]RetryOverride{"gemini": {RetryMaxDelay: 2 * time.Minute}}
+			},
+			wantErr: false,
+		},
+		{
+			name: "negative provider max retries",
+			setup: func(c *Config) {
+				n := -1
+				c.Performance.ProviderRetries = map[string]RetryOverride{"openai": {MaxRetries: &n}}
+			},
+			wantErr: true,
+		},
+		{
+			name: "provider retry max delay below base delay",
+			setup: func(c *Config) {
+				c.Performance.ProviderRetries = map[string]RetryOverride{"openai": {RetryBaseDelay: time.Minute}}
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -272,6 +862,43 @@ func TestValidate(t *testing.T) {
 	}
 }
 
+func TestRetry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `
+performance:
+  max_retries: 2
+  retry_base_delay: 2s
+  provider_retries:
+    gemini:
+      max_retries: 0
+    openai:
+      retry_base_delay: 5s
+      retry_max_delay: 1m
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		provider string
+		want     RetryPolicy
+	}{
+		{"gemini", RetryPolicy{MaxRetries: 0, BaseDelay: 2 * time.Second, MaxDelay: 30 * time.Second}},
+		{"openai", RetryPolicy{MaxRetries: 2, BaseDelay: 5 * time.Second, MaxDelay: time.Minute}},
+		{"anthropic", RetryPolicy{MaxRetries: 2, BaseDelay: 2 * time.Second, MaxDelay: 30 * time.Second}},
+	}
+	for _, tt := range tests {
+		cfg.LLM.Provider = tt.provider
+		if got := cfg.Retry(); got != tt.want {
+			t.Errorf("Retry() for %s = %+v, want %+v", tt.provider, got, tt.want)
+		}
+	}
+}
+
 func TestFindConfigFile(t *testing.T) {
 	// Create a temporary config file in current directory
 	tmpFile := ".git-dual-context.yaml"
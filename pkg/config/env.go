@@ -0,0 +1,189 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix starts the name of every environment variable overriding a
+// config field
+const EnvPrefix = "GDC_"
+
+// legacyModelEnv is the older variable overriding llm.model, honored below
+// GDC_LLM_MODEL and GDC_MODEL
+const legacyModelEnv = "GEMINI_MODEL"
+
+// EnvVar is an environment variable overriding a config field
+type EnvVar struct {
+	// Name is GDC_ followed by the field's dotted YAML key in upper case,
+	// with dots as underscores, such as GDC_PERFORMANCE_WORKERS
+	Name string
+
+	// Alias drops the section from Name, such as GDC_WORKERS, for keys
+	// no other section uses; empty otherwise
+	Alias string
+
+	// Key is the field's dotted YAML key, such as performance.workers
+	Key string
+
+	field []int
+}
+
+// EnvVars lists the environment variables overriding config fields, in
+// the order of the fields
+func EnvVars() []EnvVar {
+	var vars []EnvVar
+	collectEnvVars(reflect.TypeFor[Config](), nil, nil, &vars)
+
+	// Keys without their section are aliases where they are unambiguous
+	sections := map[string]int{}
+	for _, v := range vars {
+		_, rest, _ := strings.Cut(v.Key, ".")
+		sections[rest]++
+	}
+	for i, v := range vars {
+		if _, rest, _ := strings.Cut(v.Key, "."); sections[rest] == 1 {
+			vars[i].Alias = EnvPrefix + envName(rest)
+		}
+	}
+	return vars
+}
+
+func collectEnvVars(t reflect.Type, keys []string, index []int, vars *[]EnvVar) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "" || name == "-" || f.Type.Kind() == reflect.Map {
+			continue
+		}
+		// Lists of sections, such as llm.api_keys, have no single value
+		if f.Type.Kind() == reflect.Slice && f.Type.Elem().Kind() == reflect.Struct {
+			continue
+		}
+		k := append(append([]string(nil), keys...), name)
+		idx := append(append([]int(nil), index...), i)
+		if f.Type.Kind() == reflect.Struct && f.Type != reflect.TypeFor[time.Duration]() {
+			collectEnvVars(f.Type, k, idx, vars)
+			continue
+		}
+		key := strings.Join(k, ".")
+		*vars = append(*vars, EnvVar{Name: EnvPrefix + envName(key), Key: key, field: idx})
+	}
+}
+
+// envName turns a dotted YAML key into an environment variable suffix
+func envName(key string) string {
+	return strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// ApplyEnv overrides config fields with the environment variables of
+// EnvVars found by lookup, and returns the names of those applied. A
+// variable's full name wins over its alias. Lists are comma-separated,
+// durations use Go syntax ("90s"), and booleans strconv.ParseBool's.
+func (c *Config) ApplyEnv(lookup func(string) (string, bool)) ([]string, error) {
+	var applied []string
+	if v, ok := lookup(legacyModelEnv); ok && v != "" {
+		c.LLM.Model = v
+		applied = append(applied, legacyModelEnv)
+	}
+
+	root := reflect.ValueOf(c).Elem()
+	for _, ev := range EnvVars() {
+		name := ev.Name
+		value, ok := lookup(name)
+		if !ok && ev.Alias != "" {
+			name = ev.Alias
+			value, ok = lookup(name)
+		}
+		if !ok {
+			continue
+		}
+		if err := setField(root.FieldByIndex(ev.field), value); err != nil {
+			return applied, fmt.Errorf("%s: %w", name, err)
+		}
+		applied = append(applied, name)
+	}
+	return applied, nil
+}
+
+// setField parses s into the config field v
+func setField(v reflect.Value, s string) error {
+	s = strings.TrimSpace(s)
+	if v.Type() == reflect.TypeFor[time.Duration]() {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", s)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", s)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", s)
+		}
+		v.SetInt(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid number %q", s)
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported field type %s", v.Type())
+	}
+	return nil
+}
+
+// Load loads the config file at path (see LoadConfig), merges the
+// repository config file at repoFile, if any and not the same file (see
+// ApplyRepoConfig), applies the named profile, or the one named by
+// GDC_PROFILE if profile is empty (see ApplyProfile), and then the
+// environment's overrides (see ApplyEnv), which command-line flags in turn
+// override. It returns the repository config's ignored sections.
+func Load(path, repoFile, profile string) (*Config, []string, error) {
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var ignored []string
+	if repoFile != "" && !SameFile(path, repoFile) {
+		if ignored, err = cfg.ApplyRepoConfig(repoFile); err != nil {
+			return nil, nil, err
+		}
+	}
+	if profile == "" {
+		profile = os.Getenv(ProfileEnv)
+	}
+	if profile != "" {
+		if err := cfg.ApplyProfile(profile); err != nil {
+			return nil, nil, err
+		}
+	}
+	if _, err := cfg.ApplyEnv(os.LookupEnv); err != nil {
+		return nil, nil, fmt.Errorf("invalid environment override: %w", err)
+	}
+	return cfg, ignored, nil
+}
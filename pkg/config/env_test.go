@@ -0,0 +1,143 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// envMap returns a lookup function reading env
+func envMap(env map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+}
+
+func TestEnvVarsNaming(t *testing.T) {
+	byKey := map[string]EnvVar{}
+	for _, v := range EnvVars() {
+		byKey[v.Key] = v
+	}
+
+	tests := []struct {
+		key, name, alias string
+	}{
+		{"performance.workers", "GDC_PERFORMANCE_WORKERS", "GDC_WORKERS"},
+		{"llm.timeout", "GDC_LLM_TIMEOUT", "GDC_TIMEOUT"},
+		{"analysis.max_diff_size", "GDC_ANALYSIS_MAX_DIFF_SIZE", "GDC_MAX_DIFF_SIZE"},
+		{"llm.provider", "GDC_LLM_PROVIDER", "GDC_PROVIDER"},
+		{"analysis.score_weights.llm", "GDC_ANALYSIS_SCORE_WEIGHTS_LLM", "GDC_SCORE_WEIGHTS_LLM"},
+		// history.path and audit.path share a key, so neither has an alias
+		{"history.path", "GDC_HISTORY_PATH", ""},
+		{"audit.enabled", "GDC_AUDIT_ENABLED", ""},
+	}
+	for _, tt := range tests {
+		v, ok := byKey[tt.key]
+		if !ok {
+			t.Errorf("no variable for %s", tt.key)
+			continue
+		}
+		if v.Name != tt.name || v.Alias != tt.alias {
+			t.Errorf("%s: got %s (alias %q), want %s (alias %q)", tt.key, v.Name, v.Alias, tt.name, tt.alias)
+		}
+	}
+	if v, ok := byKey["llm.api_keys"]; ok {
+		t.Errorf("expected no variable for a list of sections, got %s", v.Name)
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	cfg := DefaultConfig()
+	applied, err := cfg.ApplyEnv(envMap(map[string]string{
+		"GDC_WORKERS":                  "7",
+		"GDC_LLM_TIMEOUT":              "90s",
+		"GDC_PROVIDER":                 ProviderHeuristic,
+		"GDC_LLM_TEMPERATURE":          "0.5",
+		"GDC_ANALYSIS_INCLUDE_TESTS":   "true",
+		"GDC_FILE_FILTERS":             "*.lock, docs/**",
+		"GDC_SCORE_WEIGHTS_HEURISTICS": "0.25",
+		"GDC_HISTORY_ENABLED":          "false",
+		"UNRELATED":                    "x",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 8 {
+		t.Errorf("expected 8 applied variables, got %v", applied)
+	}
+
+	if cfg.Performance.Workers != 7 || cfg.LLM.Timeout != 90*time.Second || cfg.LLM.Provider != ProviderHeuristic {
+		t.Errorf("unexpected config: workers %d, timeout %v, provider %s", cfg.Performance.Workers, cfg.LLM.Timeout, cfg.LLM.Provider)
+	}
+	if cfg.LLM.Temperature != 0.5 || !cfg.Analysis.IncludeTests || cfg.History.Enabled {
+		t.Errorf("unexpected config: temperature %v, include tests %v, history %v", cfg.LLM.Temperature, cfg.Analysis.IncludeTests, cfg.History.Enabled)
+	}
+	if !reflect.DeepEqual(cfg.Analysis.FileFilters, []string{"*.lock", "docs/**"}) {
+		t.Errorf("unexpected file filters: %v", cfg.Analysis.FileFilters)
+	}
+	if cfg.Analysis.ScoreWeights.Heuristics != 0.25 {
+		t.Errorf("unexpected heuristics weight: %v", cfg.Analysis.ScoreWeights.Heuristics)
+	}
+}
+
+func TestApplyEnvPrecedence(t *testing.T) {
+	cfg := DefaultConfig()
+	_, err := cfg.ApplyEnv(envMap(map[string]string{
+		"GEMINI_MODEL":  "legacy",
+		"GDC_MODEL":     "alias",
+		"GDC_LLM_MODEL": "full",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.LLM.Model != "full" {
+		t.Errorf("expected the full name to win, got %s", cfg.LLM.Model)
+	}
+
+	cfg = DefaultConfig()
+	if _, err := cfg.ApplyEnv(envMap(map[string]string{"GEMINI_MODEL": "legacy", "GDC_MODEL": "alias"})); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.LLM.Model != "alias" {
+		t.Errorf("expected GDC_MODEL over GEMINI_MODEL, got %s", cfg.LLM.Model)
+	}
+}
+
+func TestApplyEnvInvalid(t *testing.T) {
+	for name, value := range map[string]string{
+		"GDC_WORKERS":         "many",
+		"GDC_TIMEOUT":         "10",
+		"GDC_STREAM":          "maybe",
+		"GDC_LLM_TEMPERATURE": "hot",
+	} {
+		_, err := DefaultConfig().ApplyEnv(envMap(map[string]string{name: value}))
+		if err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("%s=%s: expected an error naming the variable, got %v", name, value, err)
+		}
+	}
+}
+
+func TestLoadAppliesEnv(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(cfgPath, []byte("performance:\n  workers: 2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("GDC_WORKERS", "5")
+	cfg, _, err := Load(cfgPath, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Performance.Workers != 5 {
+		t.Errorf("expected the environment over the file, got %d workers", cfg.Performance.Workers)
+	}
+
+	t.Setenv("GDC_WORKERS", "five")
+	if _, _, err := Load(cfgPath, "", ""); err == nil {
+		t.Error("expected an error for an invalid override")
+	}
+}
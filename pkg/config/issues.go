@@ -0,0 +1,72 @@
+package config
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kerneldump/git-dual-context/pkg/ci"
+	"github.com/kerneldump/git-dual-context/pkg/issues"
+	"github.com/kerneldump/git-dual-context/pkg/secret"
+)
+
+// IssueTrackers returns the trackers of the issues section with their
+// token references resolved, for analyzer.AnalysisOptions.Issues. Without
+// token references it is the zero Config, which queries no tracker.
+func (c *Config) IssueTrackers(ctx context.Context) (issues.Config, error) {
+	ic := c.Issues
+	tracker := issues.Config{
+		GitHubRepo: ic.GitHubRepo,
+		GitHubAPI:  ic.GitHubAPI,
+		JiraURL:    ic.JiraURL,
+		JiraUser:   ic.JiraUser,
+		Timeout:    ic.LookupTimeout,
+	}
+	var err error
+	if tracker.GitHubToken, err = c.githubToken(ctx); err != nil {
+		return issues.Config{}, err
+	}
+	if ic.JiraTokenRef != "" {
+		if tracker.JiraToken, err = secret.Resolve(ctx, ic.JiraTokenRef); err != nil {
+			return issues.Config{}, fmt.Errorf("issues.jira_token_ref: %w", err)
+		}
+	}
+	return tracker, nil
+}
+
+// CIForges returns the forges CI statuses are looked up on, with their
+// token references resolved, for analyzer.AnalysisOptions.CI. Unless
+// ci.enabled is set it is the zero Config, which queries no forge.
+func (c *Config) CIForges(ctx context.Context) (ci.Config, error) {
+	if !c.CI.Enabled {
+		return ci.Config{}, nil
+	}
+	forges := ci.Config{
+		GitHubRepo:    c.Issues.GitHubRepo,
+		GitHubAPI:     c.Issues.GitHubAPI,
+		GitLabProject: c.CI.GitLabProject,
+		GitLabAPI:     c.CI.GitLabAPI,
+		Timeout:       c.Issues.LookupTimeout,
+	}
+	var err error
+	if forges.GitHubToken, err = c.githubToken(ctx); err != nil {
+		return ci.Config{}, err
+	}
+	if c.CI.GitLabTokenRef != "" {
+		if forges.GitLabToken, err = secret.Resolve(ctx, c.CI.GitLabTokenRef); err != nil {
+			return ci.Config{}, fmt.Errorf("ci.gitlab_token_ref: %w", err)
+		}
+	}
+	return forges, nil
+}
+
+// githubToken resolves issues.github_token_ref ("" if unset)
+func (c *Config) githubToken(ctx context.Context) (string, error) {
+	if c.Issues.GitHubTokenRef == "" {
+		return "", nil
+	}
+	token, err := secret.Resolve(ctx, c.Issues.GitHubTokenRef)
+	if err != nil {
+		return "", fmt.Errorf("issues.github_token_ref: %w", err)
+	}
+	return token, nil
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestIssueTrackers(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("GDC_TEST_GITHUB_TOKEN", "gh-token")
+
+	cfg := DefaultConfig()
+	tracker, err := cfg.IssueTrackers(ctx)
+	if err != nil || tracker.GitHubToken != "" || tracker.JiraToken != "" {
+		t.Errorf("Expected no tokens by default, got %+v (%v)", tracker, err)
+	}
+
+	cfg.Issues.GitHubRepo = "acme/api"
+	cfg.Issues.GitHubTokenRef = "env:GDC_TEST_GITHUB_TOKEN"
+	tracker, err = cfg.IssueTrackers(ctx)
+	if err != nil {
+		t.Fatalf("IssueTrackers failed: %v", err)
+	}
+	if tracker.GitHubRepo != "acme/api" || tracker.GitHubToken != "gh-token" || tracker.Timeout != 10*time.Second {
+		t.Errorf("Unexpected trackers: %+v", tracker)
+	}
+
+	forges, err := cfg.CIForges(ctx)
+	if err != nil || forges.GitHubToken != "" {
+		t.Errorf("Expected no CI lookups unless enabled, got %+v (%v)", forges, err)
+	}
+	cfg.CI.Enabled = true
+	forges, err = cfg.CIForges(ctx)
+	if err != nil || forges.GitHubRepo != "acme/api" || forges.GitHubToken != "gh-token" {
+		t.Errorf("Expected CI lookups with the GitHub settings of issues, got %+v (%v)", forges, err)
+	}
+
+	cfg.Issues.JiraURL = "https://acme.atlassian.net"
+	cfg.Issues.JiraTokenRef = "env:GDC_TEST_UNSET_TOKEN"
+	if _, err := cfg.IssueTrackers(ctx); err == nil {
+		t.Error("Expected an error for an unresolvable Jira token")
+	}
+}
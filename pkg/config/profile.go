@@ -0,0 +1,44 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProfileEnv selects a profile when no -config-profile flag is given
+const ProfileEnv = "GDC_PROFILE"
+
+// ProfileNames returns the names of the config's profiles, sorted
+func (c *Config) ProfileNames() []string {
+	return slices.Sorted(maps.Keys(c.Profiles))
+}
+
+// ApplyProfile overrides the config with the settings of the named
+// profile. A profile holds any part of the config's sections; settings it
+// leaves out keep their values, and lists it sets replace the config's.
+func (c *Config) ApplyProfile(name string) error {
+	node, ok := c.Profiles[name]
+	if !ok {
+		if len(c.Profiles) == 0 {
+			return fmt.Errorf("unknown profile %q: the config file defines no profiles", name)
+		}
+		return fmt.Errorf("unknown profile %q (have %s)", name, strings.Join(c.ProfileNames(), ", "))
+	}
+
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("profile %q must be a mapping of config sections", name)
+	}
+	for i := 0; i < len(node.Content); i += 2 {
+		if node.Content[i].Value == "profiles" {
+			return fmt.Errorf("profile %q cannot define profiles", name)
+		}
+	}
+	if err := node.Decode(c); err != nil {
+		return fmt.Errorf("profile %q: %w", name, err)
+	}
+	return nil
+}
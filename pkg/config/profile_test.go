@@ -0,0 +1,145 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const profilesYAML = `
+llm:
+  model: gemini-flash-latest
+analysis:
+  default_commits: 10
+  file_filters: ["*.lock"]
+profiles:
+  incident:
+    analysis:
+      default_commits: 5
+    performance:
+      run_timeout: 2m
+  nightly:
+    llm:
+      model: gemini-3-pro-preview
+    analysis:
+      default_commits: 200
+      file_filters: ["docs/**", "*.md"]
+`
+
+// loadProfilesConfig writes profilesYAML to a file and loads it
+func loadProfilesConfig(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(profilesYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestApplyProfile(t *testing.T) {
+	cfg, err := LoadConfig(loadProfilesConfig(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.ProfileNames(); !reflect.DeepEqual(got, []string{"incident", "nightly"}) {
+		t.Fatalf("unexpected profiles: %v", got)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("expected valid profiles: %v", err)
+	}
+
+	if err := cfg.ApplyProfile("incident"); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Analysis.DefaultCommits != 5 || cfg.Performance.RunTimeout != 2*time.Minute {
+		t.Errorf("expected the profile's settings, got %d commits and %v", cfg.Analysis.DefaultCommits, cfg.Performance.RunTimeout)
+	}
+	if cfg.LLM.Model != "gemini-flash-latest" || !reflect.DeepEqual(cfg.Analysis.FileFilters, []string{"*.lock"}) {
+		t.Errorf("expected settings the profile leaves out kept, got %s and %v", cfg.LLM.Model, cfg.Analysis.FileFilters)
+	}
+	if cfg.Performance.Workers != DefaultConfig().Performance.Workers {
+		t.Errorf("expected defaults kept, got %d workers", cfg.Performance.Workers)
+	}
+}
+
+func TestApplyProfileReplacesLists(t *testing.T) {
+	cfg, err := LoadConfig(loadProfilesConfig(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.ApplyProfile("nightly"); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.LLM.Model != "gemini-3-pro-preview" || cfg.Analysis.DefaultCommits != 200 {
+		t.Errorf("unexpected config: %s, %d commits", cfg.LLM.Model, cfg.Analysis.DefaultCommits)
+	}
+	if !reflect.DeepEqual(cfg.Analysis.FileFilters, []string{"docs/**", "*.md"}) {
+		t.Errorf("expected the profile's filters, got %v", cfg.Analysis.FileFilters)
+	}
+}
+
+func TestApplyProfileUnknown(t *testing.T) {
+	cfg, err := LoadConfig(loadProfilesConfig(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cfg.ApplyProfile("weekly")
+	if err == nil || !strings.Contains(err.Error(), "incident, nightly") {
+		t.Errorf("expected an error listing the profiles, got %v", err)
+	}
+	if err := DefaultConfig().ApplyProfile("weekly"); err == nil {
+		t.Error("expected an error without profiles")
+	}
+}
+
+func TestValidateProfiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{"invalid setting", "profiles:\n  bad:\n    performance:\n      workers: 0\n", "profiles.bad"},
+		{"nested profiles", "profiles:\n  bad:\n    profiles:\n      x: {}\n", "cannot define profiles"},
+		{"not a mapping", "profiles:\n  bad: fast\n", "must be a mapping"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(tt.yaml), 0644); err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := LoadConfig(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected an error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLoadProfileBeforeEnv(t *testing.T) {
+	path := loadProfilesConfig(t)
+
+	t.Setenv(ProfileEnv, "nightly")
+	t.Setenv("GDC_DEFAULT_COMMITS", "50")
+	cfg, _, err := Load(path, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.LLM.Model != "gemini-3-pro-preview" || cfg.Analysis.DefaultCommits != 50 {
+		t.Errorf("expected GDC_PROFILE applied under the environment, got %s, %d commits", cfg.LLM.Model, cfg.Analysis.DefaultCommits)
+	}
+
+	cfg, _, err = Load(path, "", "incident")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Performance.RunTimeout != 2*time.Minute {
+		t.Errorf("expected the named profile over GDC_PROFILE, got run timeout %v", cfg.Performance.RunTimeout)
+	}
+}
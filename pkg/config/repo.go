@@ -0,0 +1,117 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RepoConfigNames are the names of a repository's own config file, at the
+// root of its working tree
+var RepoConfigNames = []string{".git-dual-context.yaml", ".git-dual-context.yml"}
+
+// RepoSection is the only config section a repository's config file may
+// set. The other sections hold credentials, proxies, and output paths,
+// which a checked-out repository is not trusted to redirect.
+const RepoSection = "analysis"
+
+// FindRepoConfigFile returns the config file at the root of the working
+// tree containing dir, or "" if there is none
+func FindRepoConfigFile(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			for _, name := range RepoConfigNames {
+				path := filepath.Join(dir, name)
+				if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+					return path
+				}
+			}
+			return ""
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// ApplyRepoConfig merges the analysis section of the repository config
+// file at path over the config, the way ApplyProfile merges a profile. It
+// returns the names of the file's other sections, which are ignored.
+func (c *Config) ApplyRepoConfig(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read repository config: %w", err)
+	}
+	var sections map[string]yaml.Node
+	if err := yaml.Unmarshal(data, &sections); err != nil {
+		return nil, fmt.Errorf("failed to parse repository config %s: %w", path, err)
+	}
+
+	var ignored []string
+	for name := range sections {
+		if name != RepoSection {
+			ignored = append(ignored, name)
+		}
+	}
+	slices.Sort(ignored)
+
+	if node, ok := sections[RepoSection]; ok {
+		if err := node.Decode(&c.Analysis); err != nil {
+			return nil, fmt.Errorf("repository config %s: %w", path, err)
+		}
+	}
+	return ignored, nil
+}
+
+// WithRepoConfig returns a copy of the config, as Load returned it with
+// profile and no repository config file, with the analysis section of the
+// repository config file at repoFile merged in. The profile and GDC_*
+// variables are applied again over it, so they keep their precedence, and
+// the result is the one Load returns with repoFile. The config itself is
+// left unchanged.
+func (c *Config) WithRepoConfig(repoFile, profile string) (*Config, []string, error) {
+	cfg := *c
+	// Profiles decode into maps in place; the copy needs its own
+	cfg.Performance.ProviderRetries = maps.Clone(c.Performance.ProviderRetries)
+
+	ignored, err := cfg.ApplyRepoConfig(repoFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	if profile == "" {
+		profile = os.Getenv(ProfileEnv)
+	}
+	if profile != "" {
+		if err := cfg.ApplyProfile(profile); err != nil {
+			return nil, nil, err
+		}
+	}
+	if _, err := cfg.ApplyEnv(os.LookupEnv); err != nil {
+		return nil, nil, fmt.Errorf("invalid environment override: %w", err)
+	}
+	return &cfg, ignored, nil
+}
+
+// SameFile reports whether paths a and b name the same existing file, such
+// as a repository config file that was also found as the user config
+func SameFile(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	ai, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bi, err := os.Stat(b)
+	return err == nil && os.SameFile(ai, bi)
+}
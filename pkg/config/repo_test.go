@@ -0,0 +1,181 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeFile writes content to path, creating its directory
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFindRepoConfigFile(t *testing.T) {
+	repo := t.TempDir()
+	if err := os.Mkdir(filepath.Join(repo, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	sub := filepath.Join(repo, "pkg", "api")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := FindRepoConfigFile(sub); got != "" {
+		t.Errorf("expected no config file, got %s", got)
+	}
+
+	want := filepath.Join(repo, ".git-dual-context.yml")
+	writeFile(t, want, "analysis:\n  default_commits: 5\n")
+	// A config file below the root is not the repository's
+	writeFile(t, filepath.Join(sub, ".git-dual-context.yaml"), "")
+	if got := FindRepoConfigFile(sub); got != want {
+		t.Errorf("FindRepoConfigFile(%s) = %q, want %q", sub, got, want)
+	}
+	if got := FindRepoConfigFile(repo); got != want {
+		t.Errorf("FindRepoConfigFile(%s) = %q, want %q", repo, got, want)
+	}
+}
+
+func TestApplyRepoConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".git-dual-context.yaml")
+	writeFile(t, path, `
+analysis:
+  default_commits: 40
+  file_filters: ["vendor/**"]
+llm:
+  model: attacker-model
+network:
+  proxy: http://attacker.example:8080
+`)
+
+	cfg := DefaultConfig()
+	cfg.Analysis.ContextLines = 7
+	ignored, err := cfg.ApplyRepoConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ignored, []string{"llm", "network"}) {
+		t.Errorf("expected llm and network ignored, got %v", ignored)
+	}
+	if cfg.Analysis.DefaultCommits != 40 || !reflect.DeepEqual(cfg.Analysis.FileFilters, []string{"vendor/**"}) {
+		t.Errorf("expected the repository's analysis settings, got %d commits and %v", cfg.Analysis.DefaultCommits, cfg.Analysis.FileFilters)
+	}
+	if cfg.Analysis.ContextLines != 7 {
+		t.Errorf("expected settings the repository leaves out kept, got %d context lines", cfg.Analysis.ContextLines)
+	}
+	if cfg.LLM.Model != DefaultConfig().LLM.Model || cfg.Network.Proxy != "" {
+		t.Errorf("expected other sections ignored, got model %s and proxy %q", cfg.LLM.Model, cfg.Network.Proxy)
+	}
+}
+
+func TestLoadRepoConfigPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	userPath := filepath.Join(dir, "config.yaml")
+	writeFile(t, userPath, `
+analysis:
+  default_commits: 10
+  context_lines: 5
+profiles:
+  nightly:
+    analysis:
+      default_commits: 200
+`)
+	repoPath := filepath.Join(dir, "repo", ".git-dual-context.yaml")
+	writeFile(t, repoPath, "analysis:\n  default_commits: 30\n  context_lines: 9\n")
+
+	cfg, _, err := Load(userPath, repoPath, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Analysis.DefaultCommits != 30 || cfg.Analysis.ContextLines != 9 {
+		t.Errorf("expected the repository config over the user config, got %d commits, %d context lines", cfg.Analysis.DefaultCommits, cfg.Analysis.ContextLines)
+	}
+
+	cfg, _, err = Load(userPath, repoPath, "nightly")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Analysis.DefaultCommits != 200 || cfg.Analysis.ContextLines != 9 {
+		t.Errorf("expected the profile over the repository config, got %d commits, %d context lines", cfg.Analysis.DefaultCommits, cfg.Analysis.ContextLines)
+	}
+
+	t.Setenv("GDC_CONTEXT_LINES", "2")
+	cfg, _, err = Load(userPath, repoPath, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Analysis.ContextLines != 2 {
+		t.Errorf("expected the environment over the repository config, got %d context lines", cfg.Analysis.ContextLines)
+	}
+}
+
+func TestWithRepoConfig(t *testing.T) {
+	dir := t.TempDir()
+	userPath := filepath.Join(dir, "config.yaml")
+	writeFile(t, userPath, `
+analysis:
+  default_commits: 10
+  context_lines: 5
+performance:
+  provider_retries:
+    gemini:
+      max_retries: 1
+profiles:
+  nightly:
+    analysis:
+      default_commits: 200
+    performance:
+      provider_retries:
+        openai:
+          max_retries: 2
+`)
+	repoPath := filepath.Join(dir, "repo", ".git-dual-context.yaml")
+	writeFile(t, repoPath, "analysis:\n  default_commits: 30\n  context_lines: 9\nllm:\n  model: other\n")
+	t.Setenv("GDC_FUNCTION_CONTEXT", "true")
+
+	for _, profile := range []string{"", "nightly"} {
+		base, _, err := Load(userPath, "", profile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, wantIgnored, err := Load(userPath, repoPath, profile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		before := len(base.Performance.ProviderRetries)
+
+		got, ignored, err := base.WithRepoConfig(repoPath, profile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) || !reflect.DeepEqual(ignored, wantIgnored) {
+			t.Errorf("profile %q: expected the config Load returns, got %+v, ignored %v", profile, got.Analysis, ignored)
+		}
+		if base.Analysis.DefaultCommits == 30 || len(base.Performance.ProviderRetries) != before {
+			t.Errorf("profile %q: expected the base config left unchanged, got %+v", profile, base.Analysis)
+		}
+	}
+}
+
+func TestLoadRepoConfigSameFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".git-dual-context.yaml")
+	writeFile(t, path, "llm:\n  model: gemini-flash-latest\n")
+
+	// The file found in the current directory may be the repository's
+	// own; its sections all apply and none are reported ignored
+	cfg, ignored, err := Load(path, path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.LLM.Model != "gemini-flash-latest" || len(ignored) != 0 {
+		t.Errorf("expected the file loaded once as the user config, got %s, ignored %v", cfg.LLM.Model, ignored)
+	}
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// DefaultReloadInterval is how often a Watcher checks its file by default
+const DefaultReloadInterval = 2 * time.Second
+
+// Watcher reloads a config file when it changes, for long-lived processes
+// that apply new settings without restarting. It polls the file's size and
+// modification time, so editors that replace the file are seen too.
+type Watcher struct {
+	// Path is the config file to watch
+	Path string
+
+	// Interval is how often to check the file (default: DefaultReloadInterval)
+	Interval time.Duration
+
+	// Load reads the config after the file changes, e.g. with Load, so
+	// profiles and environment overrides apply as they did at startup
+	Load func() (*Config, error)
+
+	// OnReload receives each changed config that validates; an error
+	// rejects the config as if it were invalid
+	OnReload func(*Config) error
+
+	// OnError receives the error of a changed config that fails to load,
+	// validate, or apply; the previous config stays in effect
+	OnError func(error)
+}
+
+// fileState is what a Watcher compares to detect a change
+type fileState struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+func statFile(path string) fileState {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileState{}
+	}
+	return fileState{exists: true, size: info.Size(), modTime: info.ModTime()}
+}
+
+// Run watches the file until ctx is cancelled. The file's state when Run
+// starts is taken as already loaded. A removed file is ignored until it
+// reappears.
+func (w *Watcher) Run(ctx context.Context) {
+	interval := w.Interval
+	if interval <= 0 {
+		interval = DefaultReloadInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := statFile(w.Path)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		state := statFile(w.Path)
+		if state.exists == last.exists && state.size == last.size && state.modTime.Equal(last.modTime) {
+			continue
+		}
+		last = state
+		if state.exists {
+			w.reload()
+		}
+	}
+}
+
+// reload loads, validates, and applies the changed config
+func (w *Watcher) reload() {
+	cfg, err := w.Load()
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err == nil && w.OnReload != nil {
+		err = w.OnReload(cfg)
+	}
+	if err != nil && w.OnError != nil {
+		w.OnError(err)
+	}
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatcher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeFile(t, path, "analysis:\n  default_commits: 5\n")
+
+	reloaded := make(chan *Config, 1)
+	rejected := make(chan error, 1)
+	w := &Watcher{
+		Path:     path,
+		Interval: 5 * time.Millisecond,
+		Load:     func() (*Config, error) { return LoadConfig(path) },
+		OnReload: func(cfg *Config) error {
+			reloaded <- cfg
+			return nil
+		},
+		OnError: func(err error) { rejected <- err },
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(ctx)
+
+	// Each write moves the modification time on, as a slow edit would
+	mtime := time.Now()
+	update := func(content string) {
+		t.Helper()
+		writeFile(t, path, content)
+		mtime = mtime.Add(time.Second)
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Let the watcher record the initial state before changing the file
+	time.Sleep(20 * time.Millisecond)
+	update("analysis:\n  default_commits: 12\n")
+	select {
+	case cfg := <-reloaded:
+		if cfg.Analysis.DefaultCommits != 12 {
+			t.Errorf("expected the changed default_commits, got %d", cfg.Analysis.DefaultCommits)
+		}
+	case err := <-rejected:
+		t.Fatalf("valid config rejected: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("change not reloaded")
+	}
+
+	update("output:\n  log_format: xml\n")
+	select {
+	case cfg := <-reloaded:
+		t.Fatalf("invalid config reloaded: %+v", cfg.Output)
+	case err := <-rejected:
+		if !strings.Contains(err.Error(), "log_format") {
+			t.Errorf("expected a validation error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("invalid config not reported")
+	}
+
+	update("analysis: [")
+	select {
+	case <-reloaded:
+		t.Fatal("unparsable config reloaded")
+	case err := <-rejected:
+		if !strings.Contains(err.Error(), "parse") {
+			t.Errorf("expected a parse error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("unparsable config not reported")
+	}
+}
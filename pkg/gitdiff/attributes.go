@@ -0,0 +1,126 @@
+package gitdiff
+
+import (
+	"path"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/format/gitattributes"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// gitattributesFile is the per-directory attributes file name
+const gitattributesFile = ".gitattributes"
+
+// Attributes answers linguist attribute queries for files in a tree, using
+// the .gitattributes files committed alongside them.
+type Attributes struct {
+	// stack holds patterns in increasing priority: the root file first,
+	// then files deeper in the tree, each in file order
+	stack []gitattributes.MatchAttribute
+}
+
+// LoadAttributes reads the .gitattributes files of tree that can affect
+// paths: the root file and those in every ancestor directory of a path.
+// Missing or unreadable files are skipped.
+func LoadAttributes(tree *object.Tree, paths []string) *Attributes {
+	// Collect directories shallowest first so deeper files take priority
+	seen := map[string]bool{"": true}
+	dirs := []string{""}
+	for _, p := range paths {
+		var parts []string
+		for _, part := range strings.Split(path.Dir(p), "/") {
+			if part == "." || part == "" {
+				continue
+			}
+			parts = append(parts, part)
+			dir := strings.Join(parts, "/")
+			if !seen[dir] {
+				seen[dir] = true
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	sortByDepth(dirs)
+
+	a := &Attributes{}
+	for _, dir := range dirs {
+		f, err := tree.File(path.Join(dir, gitattributesFile))
+		if err != nil {
+			continue
+		}
+		r, err := f.Reader()
+		if err != nil {
+			continue
+		}
+		var domain []string
+		if dir != "" {
+			domain = strings.Split(dir, "/")
+		}
+		// Macros may only be defined in the root file
+		attrs, err := gitattributes.ReadAttributes(r, domain, dir == "")
+		r.Close()
+		if err != nil {
+			continue
+		}
+		a.stack = append(a.stack, attrs...)
+	}
+	return a
+}
+
+// sortByDepth orders directories by their number of path components,
+// keeping the relative order of directories at the same depth
+func sortByDepth(dirs []string) {
+	depth := func(d string) int {
+		if d == "" {
+			return 0
+		}
+		return strings.Count(d, "/") + 1
+	}
+	for i := 1; i < len(dirs); i++ {
+		for j := i; j > 0 && depth(dirs[j]) < depth(dirs[j-1]); j-- {
+			dirs[j], dirs[j-1] = dirs[j-1], dirs[j]
+		}
+	}
+}
+
+// IsGenerated reports whether path is marked linguist-generated
+func (a *Attributes) IsGenerated(p string) bool {
+	return a.isTrue(p, "linguist-generated")
+}
+
+// IsVendored reports whether path is marked linguist-vendored
+func (a *Attributes) IsVendored(p string) bool {
+	return a.isTrue(p, "linguist-vendored")
+}
+
+// isTrue resolves a boolean attribute for path. The highest-priority
+// matching pattern that mentions the attribute decides; "attr" and
+// "attr=true" mean true, "-attr" and "attr=false" mean false.
+func (a *Attributes) isTrue(p, name string) bool {
+	if a == nil {
+		return false
+	}
+	parts := strings.Split(p, "/")
+	for i := len(a.stack) - 1; i >= 0; i-- {
+		ma := a.stack[i]
+		if ma.Pattern == nil || !ma.Pattern.Match(parts) {
+			continue
+		}
+		for j := len(ma.Attributes) - 1; j >= 0; j-- {
+			attr := ma.Attributes[j]
+			if attr.Name() != name {
+				continue
+			}
+			switch {
+			case attr.IsSet():
+				return true
+			case attr.IsValueSet():
+				v := strings.ToLower(attr.Value())
+				return v == "true" || v == "1"
+			default:
+				return false
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package gitdiff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// commitFiles creates an in-memory repository with a single commit
+// containing files
+func commitFiles(t *testing.T, files map[string]string) *object.Commit {
+	t.Helper()
+	return commitHistory(t, files)[0]
+}
+
+func TestAttributes(t *testing.T) {
+	c := commitFiles(t, map[string]string{
+		".gitattributes":         "*.pb.go linguist-generated\nthird_party/** linguist-vendored=true\napi/*.go linguist-generated=false\n",
+		"api/.gitattributes":     "client.go linguist-generated\nkeep.pb.go -linguist-generated\n",
+		"api/client.go":          "package api\n",
+		"api/keep.pb.go":         "package api\n",
+		"api/server.go":          "package api\n",
+		"proto/service.pb.go":    "package proto\n",
+		"third_party/lib/lib.go": "package lib\n",
+		"main.go":                "package main\n",
+	})
+	tree, err := c.Tree()
+	if err != nil {
+		t.Fatalf("Failed to get tree: %v", err)
+	}
+
+	attrs := LoadAttributes(tree, []string{
+		"api/client.go", "api/keep.pb.go", "api/server.go",
+		"proto/service.pb.go", "third_party/lib/lib.go", "main.go",
+	})
+
+	tests := []struct {
+		path      string
+		generated bool
+		vendored  bool
+	}{
+		{"proto/service.pb.go", true, false},
+		{"third_party/lib/lib.go", false, true},
+		{"api/client.go", true, false},   // nested file overrides root
+		{"api/keep.pb.go", false, false}, // explicitly unset
+		{"api/server.go", false, false},  // explicitly false
+		{"main.go", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := attrs.IsGenerated(tt.path); got != tt.generated {
+				t.Errorf("IsGenerated(%q): expected %v, got %v", tt.path, tt.generated, got)
+			}
+			if got := attrs.IsVendored(tt.path); got != tt.vendored {
+				t.Errorf("IsVendored(%q): expected %v, got %v", tt.path, tt.vendored, got)
+			}
+		})
+	}
+}
+
+func TestNilAttributes(t *testing.T) {
+	var attrs *Attributes
+	if attrs.IsGenerated("a.pb.go") || attrs.IsVendored("a.pb.go") {
+		t.Error("Expected nil Attributes to match nothing")
+	}
+}
+
+func TestGetStandardDiffSkipsGeneratedFiles(t *testing.T) {
+	c := commitFiles(t, map[string]string{
+		".gitattributes":      "*.pb.go linguist-generated\n",
+		"proto/service.pb.go": "package proto\n",
+		"main.go":             "package main\n",
+	})
+
+	diff, files, err := GetStandardDiff(c, nil)
+	if err != nil {
+		t.Fatalf("GetStandardDiff failed: %v", err)
+	}
+	if strings.Contains(diff, "service.pb.go") {
+		t.Errorf("Expected generated file to be skipped, got diff:\n%s", diff)
+	}
+	if len(files) != 2 || files[0] != ".gitattributes" || files[1] != "main.go" {
+		t.Errorf("Expected [.gitattributes main.go], got %v", files)
+	}
+
+	_, files, err = GetStandardDiffWithOptions(c, nil, Options{IgnoreGitAttributes: true})
+	if err != nil {
+		t.Fatalf("GetStandardDiffWithOptions failed: %v", err)
+	}
+	if len(files) != 3 {
+		t.Errorf("Expected 3 files with IgnoreGitAttributes, got %v", files)
+	}
+}
@@ -0,0 +1,57 @@
+package gitdiff
+
+import (
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// blameHashLen is the length of the abbreviated hashes in blame notes
+const blameHashLen = 8
+
+// blameHashes returns the hash of the commit that last touched each line
+// of filePath at c, or nil if the file cannot be blamed
+func blameHashes(c *object.Commit, filePath string) []plumbing.Hash {
+	result, err := git.Blame(c, filePath)
+	if err != nil {
+		return nil
+	}
+	hashes := make([]plumbing.Hash, len(result.Lines))
+	for i, line := range result.Lines {
+		hashes[i] = line.Hash
+	}
+	return hashes
+}
+
+// annotateBlame notes on each changed, non-blank line of a commit-to-HEAD
+// patch the commit that last touched it: for added lines, the commit at
+// HEAD that wrote them; for removed lines, the commit that wrote them as of
+// c. A removed line noted with c's own hash is code from the analyzed
+// commit that was overwritten later. The file is at oldPath in c and at
+// newPath in head.
+func annotateBlame(lines []diffLine, c, head *object.Commit, oldPath, newPath string) {
+	oldHashes, newHashes := blameHashes(c, oldPath), blameHashes(head, newPath)
+
+	oldLine, newLine := 0, 0
+	for i, l := range lines {
+		var hashes []plumbing.Hash
+		var n int
+		switch l.op {
+		case '+':
+			hashes, n = newHashes, newLine
+			newLine++
+		case '-':
+			hashes, n = oldHashes, oldLine
+			oldLine++
+		default:
+			oldLine++
+			newLine++
+			continue
+		}
+		if n < len(hashes) && strings.TrimSpace(l.text) != "" {
+			lines[i].note = hashes[n].String()[:blameHashLen]
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package gitdiff
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// deleteFile as the content of a file in a snapshot removes the file
+const deleteFile = "\x00delete"
+
+// commitMessage as a file name in a snapshot sets the commit message
+// instead of writing a file
+const commitMessage = "\x00message"
+
+// Content prefixes in a snapshot that write an executable file or a
+// symbolic link to the rest of the content
+const (
+	executableFile = "\x00exec:"
+	symlinkFile    = "\x00symlink:"
+)
+
+// commitHistory creates an in-memory repository with one commit per
+// snapshot, each writing the given files, and returns the commits in order
+func commitHistory(t *testing.T, snapshots ...map[string]string) []*object.Commit {
+	t.Helper()
+	fs := memfs.New()
+	r, err := git.Init(memory.NewStorage(), fs)
+	if err != nil {
+		t.Fatalf("Failed to init repo: %v", err)
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		t.Fatalf("Failed to get worktree: %v", err)
+	}
+	var commits []*object.Commit
+	for i, files := range snapshots {
+		message := fmt.Sprintf("commit %d", i)
+		for name, content := range files {
+			if name == commitMessage {
+				message = content
+				continue
+			}
+			if content == deleteFile {
+				if _, err := w.Remove(name); err != nil {
+					t.Fatalf("Failed to remove %s: %v", name, err)
+				}
+				continue
+			}
+			// Recreate the file so that its mode and type can change
+			fs.Remove(name)
+			if target, ok := strings.CutPrefix(content, symlinkFile); ok {
+				if err := fs.Symlink(target, name); err != nil {
+					t.Fatalf("Failed to link %s: %v", name, err)
+				}
+				if _, err := w.Add(name); err != nil {
+					t.Fatalf("Failed to add %s: %v", name, err)
+				}
+				continue
+			}
+			perm := os.FileMode(0644)
+			if rest, ok := strings.CutPrefix(content, executableFile); ok {
+				content, perm = rest, 0755
+			}
+			f, err := fs.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+			if err != nil {
+				t.Fatalf("Failed to create %s: %v", name, err)
+			}
+			if _, err := f.Write([]byte(content)); err != nil {
+				t.Fatalf("Failed to write %s: %v", name, err)
+			}
+			f.Close()
+			if _, err := w.Add(name); err != nil {
+				t.Fatalf("Failed to add %s: %v", name, err)
+			}
+		}
+		hash, err := w.Commit(message, &git.CommitOptions{
+			Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Unix(int64(1700000000+i*60), 0)},
+		})
+		if err != nil {
+			t.Fatalf("Failed to commit: %v", err)
+		}
+		c, err := r.CommitObject(hash)
+		if err != nil {
+			t.Fatalf("Failed to get commit: %v", err)
+		}
+		commits = append(commits, c)
+	}
+	return commits
+}
+
+func TestGetFullDiffBlameEvolution(t *testing.T) {
+	commits := commitHistory(t,
+		map[string]string{"loader.go": "package loader\n\nfunc load(n int) bool {\n\treturn true\n}\n"},
+		map[string]string{"loader.go": "package loader\n\nfunc load(n int) bool {\n\treturn n > 0\n}\n"},
+		map[string]string{"loader.go": "package loader\n\nfunc load(n int) bool {\n\treturn n >= 0\n}\n"},
+	)
+	suspect, later := commits[1], commits[2]
+	short := func(c *object.Commit) string { return c.Hash.String()[:blameHashLen] }
+
+	full, err := GetFullDiffWithOptions(suspect, later, []string{"loader.go"}, Options{BlameEvolution: true})
+	if err != nil {
+		t.Fatalf("GetFullDiffWithOptions failed: %v", err)
+	}
+
+	expected := []string{
+		"this commit is " + short(suspect) + ")\n",
+		" package loader\n",
+		"-" + short(suspect) + " | \treturn n > 0\n",
+		"+" + short(later) + " | \treturn n >= 0\n",
+	}
+	for _, want := range expected {
+		if !strings.Contains(full, want) {
+			t.Errorf("Expected %q in:\n%s", want, full)
+		}
+	}
+
+	// Hunk mode carries the same notes
+	full, err = GetFullDiffWithOptions(suspect, later, []string{"loader.go"}, Options{BlameEvolution: true, ContextLines: 1})
+	if err != nil {
+		t.Fatalf("GetFullDiffWithOptions failed: %v", err)
+	}
+	if !strings.Contains(full, "@@ -3 +3 @@\n func load(n int) bool {\n-"+short(suspect)+" | ") {
+		t.Errorf("Expected annotated hunk, got:\n%s", full)
+	}
+
+	full, err = GetFullDiffWithOptions(suspect, later, []string{"loader.go"}, Options{})
+	if err != nil {
+		t.Fatalf("GetFullDiffWithOptions failed: %v", err)
+	}
+	if strings.Contains(full, " | ") {
+		t.Errorf("Expected no blame notes by default, got:\n%s", full)
+	}
+}
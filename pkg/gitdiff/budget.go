@@ -0,0 +1,166 @@
+package gitdiff
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// DefaultMaxDiffTokens is the default token budget for a single diff,
+// roughly equivalent to MaxDiffSize characters of source code
+const DefaultMaxDiffTokens = 12500
+
+// Tokenizer counts the tokens a model would see for a piece of text
+type Tokenizer interface {
+	CountTokens(text string) int
+}
+
+// EstimateTokenizer approximates token counts offline with EstimateTokens
+type EstimateTokenizer struct{}
+
+// CountTokens implements Tokenizer
+func (EstimateTokenizer) CountTokens(text string) int {
+	return EstimateTokens(text)
+}
+
+// EstimateTokens approximates the token count of source code for
+// SentencePiece/BPE-style tokenizers such as Gemini's: identifiers and
+// numbers cost about one token per four characters, each punctuation
+// character costs one token, and runs of indentation cost one token.
+func EstimateTokens(text string) int {
+	tokens := 0
+	word := 0
+	space := 0
+	flush := func() {
+		if word > 0 {
+			tokens += (word + 3) / 4
+			word = 0
+		}
+		if space > 1 {
+			tokens++
+		}
+		space = 0
+	}
+	for _, r := range text {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if space > 0 {
+				flush()
+			}
+			word++
+		case unicode.IsSpace(r):
+			if word > 0 {
+				flush()
+			}
+			space++
+		default:
+			flush()
+			tokens++
+		}
+	}
+	flush()
+	return tokens
+}
+
+// fileSection is the rendered diff of one file: a header line followed by
+// the diff lines
+type fileSection struct {
+	path string
+	text string
+}
+
+// mergeSections joins the sections of a path diffed more than once into
+// the first, in the order the paths first appear. git diffs a file whose
+// type changed, such as a regular file replaced by a symlink, as a
+// deletion and an addition of the same path; merged, the file is listed,
+// budgeted, and chunked once.
+func mergeSections(sections []fileSection) []fileSection {
+	index := make(map[string]int, len(sections))
+	merged := sections[:0:0]
+	for _, s := range sections {
+		if i, ok := index[s.path]; ok {
+			merged[i].text += s.text
+			continue
+		}
+		index[s.path] = len(merged)
+		merged = append(merged, s)
+	}
+	return merged
+}
+
+// budgetSections joins sections, fitting them into maxTokens. When they
+// don't fit and errorMessage gives something to search for, the files and
+// hunks most relevant to it are kept (see prioritizeSections), after first
+// removing hunks unrelated to it if dropHunks is set. Otherwise
+// each file's share of the budget is proportional to its size, so a single
+// huge file can no longer crowd every other file out of the diff.
+// Truncated files keep their header and end with a marker saying how many
+// lines were omitted.
+func budgetSections(sections []fileSection, maxTokens int, tok Tokenizer, errorMessage string, dropHunks bool) string {
+	if maxTokens <= 0 {
+		maxTokens = DefaultMaxDiffTokens
+	}
+	if tok == nil {
+		tok = EstimateTokenizer{}
+	}
+
+	counts := make([]int, len(sections))
+	total := 0
+	for i, s := range sections {
+		counts[i] = tok.CountTokens(s.text)
+		total += counts[i]
+	}
+
+	if total > maxTokens {
+		if rel := newRelevance(errorMessage); !rel.empty() {
+			if dropHunks {
+				sections, counts, total = dropIrrelevantHunks(sections, tok, rel)
+			}
+			if total > maxTokens {
+				return prioritizeSections(sections, counts, maxTokens, tok, rel)
+			}
+		}
+	}
+
+	var sb strings.Builder
+	sb.Grow(defaultDiffBufferSize)
+	for i, s := range sections {
+		if total <= maxTokens {
+			sb.WriteString(s.text)
+			continue
+		}
+		share := int(int64(maxTokens) * int64(counts[i]) / int64(total))
+		sb.WriteString(truncateSection(s.text, share, tok))
+	}
+	return sb.String()
+}
+
+// truncateSection cuts a file section to about maxTokens tokens at a line
+// boundary, always keeping the header line
+func truncateSection(text string, maxTokens int, tok Tokenizer) string {
+	lines := strings.SplitAfter(text, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return text
+	}
+
+	var sb strings.Builder
+	sb.WriteString(lines[0])
+	used := tok.CountTokens(lines[0])
+	kept := 1
+	for _, line := range lines[1:] {
+		n := tok.CountTokens(line)
+		if used+n > maxTokens {
+			break
+		}
+		sb.WriteString(line)
+		used += n
+		kept++
+	}
+	if omitted := len(lines) - kept; omitted > 0 {
+		sb.WriteString(fmt.Sprintf("... [truncated: %d more lines in this file] ...\n", omitted))
+	}
+	return sb.String()
+}
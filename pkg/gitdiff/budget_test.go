@@ -0,0 +1,80 @@
+package gitdiff
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// lineTokenizer counts one token per line, to make budgets predictable
+type lineTokenizer struct{}
+
+func (lineTokenizer) CountTokens(text string) int {
+	return strings.Count(text, "\n")
+}
+
+func section(path string, lines int) fileSection {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("--- %s\n", path))
+	for i := 1; i < lines; i++ {
+		sb.WriteString(fmt.Sprintf("+line %d\n", i))
+	}
+	return fileSection{path: path, text: sb.String()}
+}
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected int
+	}{
+		{"", 0},
+		{"abcd", 1},
+		{"abcde", 2},
+		{"a b", 2},
+		{"x := 1", 4},
+		{"\treturn nil", 3},
+		{"    return nil", 4},
+	}
+
+	for _, tt := range tests {
+		if got := EstimateTokens(tt.text); got != tt.expected {
+			t.Errorf("EstimateTokens(%q): expected %d, got %d", tt.text, tt.expected, got)
+		}
+	}
+}
+
+func TestBudgetSectionsFits(t *testing.T) {
+	sections := []fileSection{section("a.go", 3), section("b.go", 3)}
+	result := budgetSections(sections, 10, lineTokenizer{}, "", false)
+	if result != sections[0].text+sections[1].text {
+		t.Errorf("Expected sections unchanged, got:\n%s", result)
+	}
+}
+
+func TestBudgetSectionsProportional(t *testing.T) {
+	// 90 + 10 lines into a budget of 50: each file gets half its lines
+	sections := []fileSection{section("big.go", 90), section("small.go", 10)}
+	result := budgetSections(sections, 50, lineTokenizer{}, "", false)
+
+	if !strings.Contains(result, "--- small.go\n") {
+		t.Fatalf("Expected small file to survive truncation, got:\n%s", result)
+	}
+	if !strings.Contains(result, "+line 44\n") || strings.Contains(result, "+line 45\n") {
+		t.Errorf("Expected big.go cut after 45 lines, got:\n%s", result)
+	}
+	if !strings.Contains(result, "[truncated: 45 more lines in this file]") {
+		t.Errorf("Expected truncation marker for big.go, got:\n%s", result)
+	}
+	small := result[strings.Index(result, "--- small.go\n"):]
+	if !strings.Contains(small, "+line 4\n") || strings.Contains(small, "+line 5\n") {
+		t.Errorf("Expected small.go cut after 5 lines, got:\n%s", small)
+	}
+}
+
+func TestTruncateSectionKeepsHeader(t *testing.T) {
+	result := truncateSection(section("a.go", 5).text, 0, lineTokenizer{})
+	expected := "--- a.go\n... [truncated: 4 more lines in this file] ...\n"
+	if result != expected {
+		t.Errorf("Expected %q, got %q", expected, result)
+	}
+}
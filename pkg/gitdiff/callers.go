@@ -0,0 +1,320 @@
+package gitdiff
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/types"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"golang.org/x/tools/go/packages"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+// Bounds of the call sites listed for a commit
+const (
+	maxSitesPerSymbol = 3
+	maxCallSites      = 10
+)
+
+// callSiteContext is how many lines around a call are shown
+const callSiteContext = 1
+
+// CallSite is a call, at HEAD, of a function a commit changed
+type CallSite struct {
+	Path string `json:"path"`
+	Line int    `json:"line"`
+
+	// Caller is the function the call is in, such as "Server.login" (empty:
+	// a package-level initializer)
+	Caller string `json:"caller,omitempty"`
+
+	// Code is the call's line with the lines around it
+	Code string `json:"code"`
+}
+
+// SymbolCallers are the call sites of one changed function or method
+type SymbolCallers struct {
+	Path   string `json:"path"`
+	Symbol string `json:"symbol"`
+
+	// Total counts every call site; Sites lists the first few
+	Total int        `json:"total"`
+	Sites []CallSite `json:"sites"`
+}
+
+// Callers indexes the static call sites of the functions and methods of
+// the Go modules at HEAD. It is read-only once loaded and safe for
+// concurrent use.
+type Callers struct {
+	packages map[string]string     // import path of the package of each file
+	sites    map[string][]CallSite // call sites by callee, see calleeKey
+}
+
+// LoadCallers type-checks the Go modules of head's tree with go/packages
+// and indexes every static call of a function or method they declare.
+// The tree's Go files are written to a temporary directory first, so the
+// worktree is not read, but the go command resolves dependencies as
+// usual: from the module cache, a vendor directory, or the network. Test
+// files are left out. Packages that fail to type-check contribute the
+// calls that could still be resolved.
+func LoadCallers(ctx context.Context, head *object.Commit) (*Callers, error) {
+	tree, err := head.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get HEAD tree: %w", err)
+	}
+	dir, err := os.MkdirTemp("", "gdc-callers-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		return nil, err
+	}
+
+	modules, err := writeGoTree(tree, dir)
+	if err != nil {
+		return nil, err
+	}
+	c := &Callers{packages: map[string]string{}, sites: map[string][]CallSite{}}
+	for _, module := range modules {
+		cfg := &packages.Config{
+			Context: ctx,
+			Mode:    packages.NeedName | packages.NeedFiles | packages.NeedSyntax | packages.NeedTypes | packages.NeedTypesInfo,
+			Dir:     filepath.Join(dir, filepath.FromSlash(module)),
+			Env:     append(os.Environ(), "GOWORK=off"),
+		}
+		pkgs, err := packages.Load(cfg, "./...")
+		if err != nil {
+			return nil, fmt.Errorf("loading Go packages of %s: %w", module, err)
+		}
+		sort.Slice(pkgs, func(i, j int) bool { return pkgs[i].PkgPath < pkgs[j].PkgPath })
+		for _, pkg := range pkgs {
+			c.index(pkg, dir)
+		}
+	}
+	return c, nil
+}
+
+// writeGoTree writes the Go files and module files of tree under dir and
+// returns the directories of its modules, leaving out vendored and
+// testdata modules
+func writeGoTree(tree *object.Tree, dir string) ([]string, error) {
+	var modules []string
+	walker := object.NewTreeWalker(tree, true, nil)
+	defer walker.Close()
+	for {
+		name, entry, err := walker.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("walking tree: %w", err)
+		}
+		base := path.Base(name)
+		if !entry.Mode.IsFile() || IsTestFile(name) {
+			continue
+		}
+		if path.Ext(name) != ".go" && base != "go.mod" && base != "go.sum" && base != "modules.txt" {
+			continue
+		}
+		f, err := tree.TreeEntryFile(&entry)
+		if err != nil || f.Size > maxGoSourceSize {
+			continue
+		}
+		content, err := f.Contents()
+		if err != nil {
+			continue
+		}
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(target, []byte(content), 0o644); err != nil {
+			return nil, err
+		}
+		if base == "go.mod" && ignoreRule(name, true) == "" && !strings.Contains("/"+name, "/testdata/") {
+			modules = append(modules, path.Dir(name))
+		}
+	}
+	return modules, nil
+}
+
+// index records the files of pkg and the calls they make to functions of
+// packages in the index
+func (c *Callers) index(pkg *packages.Package, dir string) {
+	rel := func(filename string) string {
+		p, err := filepath.Rel(dir, filename)
+		if err != nil {
+			return filename
+		}
+		return filepath.ToSlash(p)
+	}
+	for _, f := range pkg.GoFiles {
+		c.packages[rel(f)] = pkg.PkgPath
+	}
+	if pkg.TypesInfo == nil {
+		return
+	}
+	for _, file := range pkg.Syntax {
+		pos := pkg.Fset.Position(file.Pos())
+		filePath := rel(pos.Filename)
+		src, err := os.ReadFile(pos.Filename)
+		if err != nil {
+			continue
+		}
+		lines := strings.Split(string(src), "\n")
+
+		record := func(node ast.Node, caller string) {
+			ast.Inspect(node, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok {
+					return true
+				}
+				fn, ok := typeutil.Callee(pkg.TypesInfo, call).(*types.Func)
+				if !ok || fn.Pkg() == nil {
+					return true
+				}
+				key := calleeKey(fn)
+				if key == pkg.PkgPath+"."+caller {
+					return true // recursion
+				}
+				line := pkg.Fset.Position(call.Lparen).Line
+				c.sites[key] = append(c.sites[key], CallSite{
+					Path:   filePath,
+					Line:   line,
+					Caller: caller,
+					Code:   codeAround(lines, line),
+				})
+				return true
+			})
+		}
+		for _, decl := range file.Decls {
+			if fd, ok := decl.(*ast.FuncDecl); ok {
+				name := fd.Name.Name
+				if fd.Recv != nil && len(fd.Recv.List) > 0 {
+					name = receiverName(fd.Recv.List[0].Type) + "." + name
+				}
+				record(fd, name)
+			} else {
+				record(decl, "")
+			}
+		}
+	}
+}
+
+// calleeKey identifies a function as "import/path.Name", or a method as
+// "import/path.Type.Name", matching the symbol names of ChangedSymbols
+func calleeKey(fn *types.Func) string {
+	fn = fn.Origin()
+	name := fn.Name()
+	if recv := fn.Signature().Recv(); recv != nil {
+		t := types.Unalias(recv.Type())
+		if p, ok := t.(*types.Pointer); ok {
+			t = types.Unalias(p.Elem())
+		}
+		if named, ok := t.(*types.Named); ok {
+			name = named.Obj().Name() + "." + name
+		}
+	}
+	return fn.Pkg().Path() + "." + name
+}
+
+// codeAround returns the 1-based line of lines with callSiteContext lines
+// on each side, without their common indentation
+func codeAround(lines []string, line int) string {
+	start, end := max(line-1-callSiteContext, 0), min(line+callSiteContext, len(lines))
+	if start >= end {
+		return ""
+	}
+	snippet := lines[start:end]
+	indent := -1
+	for _, l := range snippet {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		n := len(l) - len(strings.TrimLeft(l, " \t"))
+		if indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	out := make([]string, len(snippet))
+	for i, l := range snippet {
+		if len(l) >= indent && indent > 0 {
+			l = l[indent:]
+		}
+		out[i] = strings.TrimRight(l, " \t\r")
+	}
+	return strings.Join(out, "\n")
+}
+
+// For returns the call sites at HEAD of the functions and methods that
+// symbols lists, at most three for each and ten in all. Symbols of files
+// that are not in a package at HEAD have none. A nil Callers gives nil.
+func (c *Callers) For(symbols []FileSymbols) []SymbolCallers {
+	if c == nil {
+		return nil
+	}
+	var result []SymbolCallers
+	listed := 0
+	for _, fs := range symbols {
+		pkg := c.packages[fs.Path]
+		if pkg == "" {
+			continue
+		}
+		for _, s := range fs.Symbols {
+			if s.Kind != "func" && s.Kind != "method" {
+				continue
+			}
+			sites := c.sites[pkg+"."+s.Name]
+			if len(sites) == 0 {
+				continue
+			}
+			shown := sites[:min(len(sites), maxSitesPerSymbol, maxCallSites-listed)]
+			if len(shown) == 0 {
+				return result
+			}
+			listed += len(shown)
+			result = append(result, SymbolCallers{Path: fs.Path, Symbol: s.Name, Total: len(sites), Sites: shown})
+		}
+	}
+	return result
+}
+
+// FormatCallers renders call sites for the prompt, one block per changed
+// function:
+//
+//	pkg/auth/token.go Refresh, called in 5 places (3 shown):
+//	  pkg/api/handler.go:42, in Server.login:
+//	    tok, err := auth.Refresh(ctx, s.creds)
+func FormatCallers(callers []SymbolCallers) string {
+	var sb strings.Builder
+	for _, sc := range callers {
+		fmt.Fprintf(&sb, "%s %s, called in %d place", sc.Path, sc.Symbol, sc.Total)
+		if sc.Total != 1 {
+			sb.WriteString("s")
+		}
+		if len(sc.Sites) < sc.Total {
+			fmt.Fprintf(&sb, " (%d shown)", len(sc.Sites))
+		}
+		sb.WriteString(":\n")
+		for _, site := range sc.Sites {
+			fmt.Fprintf(&sb, "  %s:%d", site.Path, site.Line)
+			if site.Caller != "" {
+				fmt.Fprintf(&sb, ", in %s", site.Caller)
+			}
+			sb.WriteString(":\n")
+			for _, l := range strings.Split(site.Code, "\n") {
+				fmt.Fprintf(&sb, "%s\n", strings.TrimRight("    "+l, " "))
+			}
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,88 @@
+package gitdiff
+
+import (
+	"context"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadCallers(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command not available")
+	}
+	// The go command must load the repository's module, not this one's
+	t.Setenv("GOFLAGS", "")
+
+	commits := commitHistory(t, map[string]string{
+		"go.mod": "module example.com/app\n\ngo 1.21\n",
+		"store/store.go": `package store
+
+type Store struct{ n int }
+
+func Load() *Store { return &Store{} }
+
+func (s *Store) Get() int {
+	if s.n > 0 {
+		return s.Get()
+	}
+	return s.n
+}
+`,
+		"api/api.go": `package api
+
+import "example.com/app/store"
+
+func Handle() int {
+	s := store.Load()
+	return s.Get()
+}
+
+var cached = store.Load()
+`,
+		"api/api_test.go": "package api\n\nimport \"example.com/app/store\"\n\nvar _ = store.Load()\n",
+	})
+
+	c, err := LoadCallers(context.Background(), commits[0])
+	if err != nil {
+		t.Fatalf("LoadCallers failed: %v", err)
+	}
+
+	got := c.For([]FileSymbols{{Path: "store/store.go", Symbols: []SymbolChange{
+		{Kind: "func", Name: "Load", Change: "modified"},
+		{Kind: "method", Name: "Store.Get", Change: "modified"},
+		{Kind: "type", Name: "Store", Change: "modified"},
+	}}})
+	expected := []SymbolCallers{
+		{Path: "store/store.go", Symbol: "Load", Total: 2, Sites: []CallSite{
+			{Path: "api/api.go", Line: 6, Caller: "Handle", Code: "func Handle() int {\n\ts := store.Load()\n\treturn s.Get()"},
+			{Path: "api/api.go", Line: 10, Caller: "", Code: "\nvar cached = store.Load()\n"},
+		}},
+		{Path: "store/store.go", Symbol: "Store.Get", Total: 1, Sites: []CallSite{
+			{Path: "api/api.go", Line: 7, Caller: "Handle", Code: "\ts := store.Load()\n\treturn s.Get()\n}"},
+		}},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+
+	formatted := FormatCallers(got)
+	for _, want := range []string{
+		"store/store.go Load, called in 2 places:\n  api/api.go:6, in Handle:\n",
+		"  api/api.go:10:\n\n    var cached = store.Load()\n\n",
+		"store/store.go Store.Get, called in 1 place:\n  api/api.go:7, in Handle:\n    \ts := store.Load()\n",
+	} {
+		if !strings.Contains(formatted, want) {
+			t.Errorf("Expected %q in:\n%s", want, formatted)
+		}
+	}
+
+	if got := c.For([]FileSymbols{{Path: "README.md", Symbols: []SymbolChange{{Kind: "func", Name: "Load"}}}}); got != nil {
+		t.Errorf("Expected no callers outside Go packages, got %+v", got)
+	}
+	var none *Callers
+	if got := none.For([]FileSymbols{{Path: "store/store.go"}}); got != nil {
+		t.Errorf("Expected nil Callers to give nil, got %+v", got)
+	}
+}
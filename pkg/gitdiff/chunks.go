@@ -0,0 +1,61 @@
+package gitdiff
+
+import (
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// DiffChunk is a group of files from one commit whose diff is analyzed
+// in a single LLM call
+type DiffChunk struct {
+	Files []string
+	Diff  string
+}
+
+// GetStandardDiffChunks is GetStandardDiffWithOptions for commits too large
+// for one LLM call. If the diff exceeds opts.MaxTokens and opts.MaxChunks
+// allows it, the files are packed in order into groups that each fit the
+// budget, so very large commits are analyzed in full rather than as a
+// truncated blob. Files beyond the last chunk are added to it and
+// truncated as usual. It returns a single chunk when no split is needed.
+func GetStandardDiffChunks(c, parent *object.Commit, opts Options) ([]DiffChunk, error) {
+	sections, files, err := standardSections(c, parent, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	tok := opts.Tokenizer
+	if tok == nil {
+		tok = EstimateTokenizer{}
+	}
+	maxTokens := opts.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = DefaultMaxDiffTokens
+	}
+
+	var groups [][]fileSection
+	var current []fileSection
+	used := 0
+	for _, s := range sections {
+		n := tok.CountTokens(s.text)
+		if len(current) > 0 && used+n > maxTokens && len(groups) < opts.MaxChunks-1 {
+			groups = append(groups, current)
+			current, used = nil, 0
+		}
+		current = append(current, s)
+		used += n
+	}
+	groups = append(groups, current)
+
+	chunks := make([]DiffChunk, len(groups))
+	for i, group := range groups {
+		for _, s := range group {
+			chunks[i].Files = append(chunks[i].Files, s.path)
+		}
+		diff := budgetSections(group, opts.MaxTokens, opts.Tokenizer, opts.ErrorMessage, opts.DropIrrelevantHunks)
+		chunks[i].Diff = TruncateDiff(diff, opts.maxDiffSize())
+	}
+	return chunks, nil
+}
@@ -0,0 +1,49 @@
+package gitdiff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGetStandardDiffChunks(t *testing.T) {
+	c := commitFiles(t, map[string]string{
+		"a.go": strings.Repeat("a\n", 5),
+		"b.go": strings.Repeat("b\n", 5),
+		"c.go": strings.Repeat("c\n", 5),
+	})
+
+	tests := []struct {
+		name      string
+		maxChunks int
+		expected  [][]string
+	}{
+		{"no chunking", 0, [][]string{{"a.go", "b.go", "c.go"}}},
+		{"one file per chunk", 3, [][]string{{"a.go"}, {"b.go"}, {"c.go"}}},
+		{"overflow into last chunk", 2, [][]string{{"a.go"}, {"b.go", "c.go"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Each file is 6 lines; a budget of 8 fits one file per chunk
+			chunks, err := GetStandardDiffChunks(c, nil, Options{
+				MaxTokens: 8,
+				Tokenizer: lineTokenizer{},
+				MaxChunks: tt.maxChunks,
+			})
+			if err != nil {
+				t.Fatalf("GetStandardDiffChunks failed: %v", err)
+			}
+			if len(chunks) != len(tt.expected) {
+				t.Fatalf("Expected %d chunks, got %d", len(tt.expected), len(chunks))
+			}
+			for i, chunk := range chunks {
+				if strings.Join(chunk.Files, ",") != strings.Join(tt.expected[i], ",") {
+					t.Errorf("Chunk %d: expected files %v, got %v", i, tt.expected[i], chunk.Files)
+				}
+				if !strings.Contains(chunk.Diff, "--- "+chunk.Files[0]) {
+					t.Errorf("Chunk %d: expected diff of %s, got:\n%s", i, chunk.Files[0], chunk.Diff)
+				}
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/go-git/go-git/v5/plumbing/format/diff"
 	"github.com/go-git/go-git/v5/plumbing/object"
 )
 
@@ -42,90 +43,332 @@ func TruncateDiff(diff string, maxSize int) string {
 	return diff[:truncateAt] + TruncationMarker
 }
 
-// GetStandardDiff returns the diff string and a list of modified file paths
+// Options controls diff extraction. The zero value applies the built-in
+// defaults.
+type Options struct {
+	// Filter selects which files are included (nil: built-in rules only)
+	Filter *Filter
+
+	// IgnoreGitAttributes disables skipping files that the commit's
+	// .gitattributes marks linguist-generated or linguist-vendored
+	IgnoreGitAttributes bool
+
+	// KeepGenerated disables skipping files whose content looks
+	// machine-generated (see IsGeneratedContent)
+	KeepGenerated bool
+
+	// ContextLines limits the unchanged lines shown around each change.
+	// Zero shows whole files.
+	ContextLines int
+
+	// FunctionContext expands each change to its enclosing function, like
+	// git diff -W, so the LLM sees the surrounding control flow
+	FunctionContext bool
+
+	// MaxTokens is the token budget for each diff (0: DefaultMaxDiffTokens).
+	// Files over budget are truncated in proportion to their size.
+	MaxTokens int
+
+	// Tokenizer counts tokens for MaxTokens (nil: EstimateTokenizer)
+	Tokenizer Tokenizer
+
+	// MaxDiffSize caps each standard diff in characters once it fits
+	// MaxTokens (0: the MaxDiffSize constant)
+	MaxDiffSize int
+
+	// MaxFullDiffSize caps each full diff in characters (0: the
+	// MaxDiffSize constant)
+	MaxFullDiffSize int
+
+	// MaxPromptDiffSize caps the standard and full diffs of one LLM call
+	// together, in characters (0: no cap beyond the two above). See
+	// FitPromptDiffs.
+	MaxPromptDiffSize int
+
+	// ErrorMessage, if set, decides what survives truncation: the files
+	// and hunks most relevant to it are kept and the rest are dropped
+	ErrorMessage string
+
+	// DropIrrelevantHunks, when a diff is over MaxTokens, first removes
+	// the hunks that share no identifiers with ErrorMessage from every
+	// file that has a hunk that does, noting the omitted hunks' line
+	// numbers. Needs ContextLines or FunctionContext to produce hunks.
+	DropIrrelevantHunks bool
+
+	// MaxChunks lets GetStandardDiffChunks split a commit over MaxTokens
+	// into up to this many groups of files (0 or 1: never split)
+	MaxChunks int
+
+	// MinChangedLines skips commits that insert and delete fewer lines in
+	// analyzed files, such as one-line typo fixes (0: never skip)
+	MinChangedLines int
+
+	// AnalyzeNonFunctional sends commits that only change whitespace,
+	// comments, or import order to the LLM instead of rating them LOW
+	// (see NonFunctionalChange)
+	AnalyzeNonFunctional bool
+
+	// SemanticDiff lists the declarations each Go file adds, removes, or
+	// modifies instead of its changed lines (see SymbolParser). Files in
+	// other languages, files that cannot be parsed, and files whose
+	// changes are outside declarations keep the line diff.
+	SemanticDiff bool
+
+	// FullFileMaxBytes sends files of up to this size whole, with every
+	// unchanged line, even when ContextLines, FunctionContext, or
+	// SemanticDiff would shorten them, since isolated hunks of a small file
+	// can hide the logic around a change (0: never). The size is taken at
+	// the commit for the standard diff and at HEAD for the full diff.
+	FullFileMaxBytes int
+
+	// BlameEvolution notes on each changed line of the full diff the
+	// commit that last touched it, so the LLM can tell whether later
+	// commits overwrote the analyzed commit's code. Blame walks history
+	// and is slow on long-lived files.
+	BlameEvolution bool
+
+	// Owners, if set, suggests who to ask about each commit from the
+	// CODEOWNERS file and blame at HEAD (see OwnerResolver)
+	Owners *OwnerResolver
+
+	// Hotspots, if set, gives each commit a prior from the churn and
+	// bug-fix history of its files (see LoadHotspots)
+	Hotspots *Hotspots
+
+	// TechStack, if set, names the repository's dominant languages and
+	// frameworks in the prompt (see DetectTechStack)
+	TechStack *TechStack
+
+	// Related, if set, adds to each full diff the evolution of files the
+	// commit did not change that co-change with or import its files (see
+	// LoadRelated)
+	Related *Related
+
+	// Callers, if set, lists in the prompt where the Go functions a commit
+	// changed are called at HEAD (see LoadCallers)
+	Callers *Callers
+
+	// Tests, if set, lists the test files likely to exercise each HIGH or
+	// MEDIUM commit's files in its result (see LoadTestMap)
+	Tests *TestMap
+
+	// Provider computes the patches the diffs are rendered from (nil:
+	// GoGitProvider). See NewProvider for the system git backend.
+	Provider DiffProvider
+}
+
+// maxDiffSize returns the character cap of a standard diff
+func (opts Options) maxDiffSize() int {
+	if opts.MaxDiffSize > 0 {
+		return opts.MaxDiffSize
+	}
+	return MaxDiffSize
+}
+
+// maxFullDiffSize returns the character cap of a full diff
+func (opts Options) maxFullDiffSize() int {
+	if opts.MaxFullDiffSize > 0 {
+		return opts.MaxFullDiffSize
+	}
+	return MaxDiffSize
+}
+
+// FitPromptDiffs truncates the standard and full diffs of one LLM call to
+// maxSize characters together (0: no cap). The full diff, which only shows
+// how the code evolved, is cut first, down to a quarter of maxSize, and
+// then the standard diff.
+func FitPromptDiffs(stdDiff, fullDiff string, maxSize int) (string, string) {
+	if maxSize <= 0 || len(stdDiff)+len(fullDiff) <= maxSize {
+		return stdDiff, fullDiff
+	}
+	stdDiff = TruncateDiff(stdDiff, maxSize-min(len(fullDiff), maxSize/4))
+	return stdDiff, TruncateDiff(fullDiff, maxSize-len(stdDiff))
+}
+
+// wholeFile returns opts adjusted to render the file at p in tree whole
+// if it is within FullFileMaxBytes
+func (opts Options) wholeFile(tree *object.Tree, p string) Options {
+	if opts.FullFileMaxBytes <= 0 || tree == nil {
+		return opts
+	}
+	f, err := tree.File(p)
+	if err != nil || f.Size > int64(opts.FullFileMaxBytes) {
+		return opts
+	}
+	opts.ContextLines = 0
+	opts.FunctionContext = false
+	opts.SemanticDiff = false
+	return opts
+}
+
+// GetStandardDiff returns the diff string and a list of modified file
+// paths. parent is nil for a root commit, whose files are all added.
 func GetStandardDiff(c, parent *object.Commit) (string, []string, error) {
-	cTree, err := c.Tree()
+	return GetStandardDiffWithOptions(c, parent, Options{})
+}
+
+// GetStandardDiffWithOptions is GetStandardDiff with configurable extraction
+func GetStandardDiffWithOptions(c, parent *object.Commit, opts Options) (string, []string, error) {
+	sections, files, err := standardSections(c, parent, opts)
 	if err != nil {
 		return "", nil, err
 	}
+	result := budgetSections(sections, opts.MaxTokens, opts.Tokenizer, opts.ErrorMessage, opts.DropIrrelevantHunks)
+	return TruncateDiff(result, opts.maxDiffSize()), files, nil
+}
+
+// standardSections renders the filtered, untruncated diff of each file
+// changed by c
+func standardSections(c, parent *object.Commit, opts Options) ([]fileSection, []string, error) {
+	cTree, err := c.Tree()
+	if err != nil {
+		return nil, nil, err
+	}
 
 	var pTree *object.Tree
 	if parent != nil {
 		pTree, err = parent.Tree()
 		if err != nil {
-			return "", nil, err
+			return nil, nil, err
 		}
 	}
 
 	// Diff parent -> commit
-	// For the first commit (no parent), pTree will be nil
-	// Use DiffTree which handles nil trees correctly (treats as empty tree)
-	changes, err := object.DiffTree(pTree, cTree)
+	// For the first commit (no parent), the parent is the empty tree
+	filePatches, err := opts.provider().FilePatches(parent, c, false)
 	if err != nil {
-		return "", nil, fmt.Errorf("failed to diff trees: %w", err)
+		return nil, nil, err
 	}
 
-	patch, err := changes.Patch()
-	if err != nil {
-		return "", nil, fmt.Errorf("failed to generate patch: %w", err)
+	// Generated and vendored code is marked in .gitattributes by many repos;
+	// it is noise to the LLM and can be large enough to crowd out real changes
+	var attrs *Attributes
+	if !opts.IgnoreGitAttributes {
+		paths := make([]string, 0, len(filePatches))
+		for _, fp := range filePatches {
+			paths = append(paths, patchPath(fp))
+		}
+		attrs = LoadAttributes(cTree, paths)
 	}
 
-	var sb strings.Builder
-	sb.Grow(defaultDiffBufferSize)
+	var sections []fileSection
 	var files []string
 
-	for _, fp := range patch.FilePatches() {
+	for _, fp := range filePatches {
+		fp = normalizePatch(fp, pTree, cTree)
 		if fp.IsBinary() {
 			continue
 		}
-		from, to := fp.Files()
-		path := ""
-		if from != nil {
-			path = from.Path()
-		}
-		if to != nil {
-			path = to.Path()
-		}
+		path := patchPath(fp)
 
 		// Filter out irrelevant files to save tokens and reduce noise
-		if ShouldIgnoreFile(path) {
+		if opts.Filter.Ignore(path) {
+			continue
+		}
+		if attrs.IsGenerated(path) || attrs.IsVendored(path) {
+			continue
+		}
+		// Deleted files are only readable from the parent
+		tree := cTree
+		if _, to := fp.Files(); to == nil {
+			tree = pTree
+		}
+		if !opts.KeepGenerated && IsGeneratedContent(path, fileHead(tree, path)) {
 			continue
 		}
 
 		if path != "" {
 			files = append(files, path)
-			sb.WriteString(fmt.Sprintf("--- %s\n", path))
-			for _, chunk := range fp.Chunks() {
-				content := chunk.Content()
-				if len(content) == 0 {
+			opts := opts.wholeFile(tree, path)
+			if opts.SemanticDiff && !modeChanged(fp) {
+				if text, ok := semanticSection(pTree, cTree, path); ok {
+					sections = append(sections, fileSection{path: path, text: text})
 					continue
 				}
-				op := " "
-				switch chunk.Type() {
-				case 0: // Equal (context)
-					op = " "
-				case 1: // Add
-					op = "+"
-				case 2: // Delete
-					op = "-"
-				}
-				lines := strings.Split(content, "\n")
-				for _, line := range lines {
-					if line == "" {
-						continue
-					}
-					sb.WriteString(fmt.Sprintf("%s%s\n", op, line))
-				}
 			}
+			var sb strings.Builder
+			writeFileHeader(&sb, path, fp, "")
+			writeChunks(&sb, fp.Chunks(), opts)
+			sections = append(sections, fileSection{path: path, text: sb.String()})
+		}
+	}
+
+	// Each path is listed once, however many patches it had
+	sections = mergeSections(sections)
+	files = files[:0]
+	for _, s := range sections {
+		files = append(files, s.path)
+	}
+	return sections, files, nil
+}
+
+// writeChunks renders the chunks of a file patch. By default every line
+// of the file is written; with ContextLines or FunctionContext set, only
+// hunks around the changes are.
+func writeChunks(sb *strings.Builder, chunks []diff.Chunk, opts Options) {
+	if opts.ContextLines > 0 || opts.FunctionContext {
+		writeHunks(sb, patchLines(chunks), opts.ContextLines, opts.FunctionContext)
+		return
+	}
+	for _, chunk := range chunks {
+		content := chunk.Content()
+		if len(content) == 0 {
+			continue
+		}
+		op := " "
+		switch chunk.Type() {
+		case 0: // Equal (context)
+			op = " "
+		case 1: // Add
+			op = "+"
+		case 2: // Delete
+			op = "-"
+		}
+		lines := strings.Split(content, "\n")
+		for _, line := range lines {
+			if line == "" {
+				continue
+			}
+			sb.WriteString(fmt.Sprintf("%s%s\n", op, line))
 		}
 	}
+}
 
-	result := sb.String()
-	return TruncateDiff(result, MaxDiffSize), files, nil
+// writeLines renders annotated patch lines like writeChunks
+func writeLines(sb *strings.Builder, lines []diffLine, opts Options) {
+	if opts.ContextLines > 0 || opts.FunctionContext {
+		writeHunks(sb, lines, opts.ContextLines, opts.FunctionContext)
+		return
+	}
+	for _, l := range lines {
+		if l.text != "" {
+			l.writeTo(sb)
+		}
+	}
+}
+
+// patchPath returns the path of a file patch, preferring the new path
+func patchPath(fp diff.FilePatch) string {
+	from, to := fp.Files()
+	path := ""
+	if from != nil {
+		path = from.Path()
+	}
+	if to != nil {
+		path = to.Path()
+	}
+	return path
 }
 
 // GetFullDiff returns the diff between the commit and HEAD, restricted to the provided files
 func GetFullDiff(c, head *object.Commit, filterFiles []string) (string, error) {
+	return GetFullDiffWithOptions(c, head, filterFiles, Options{})
+}
+
+// GetFullDiffWithOptions is GetFullDiff with configurable extraction. Only
+// the context and provider settings of opts apply; files are selected by
+// filterFiles.
+func GetFullDiffWithOptions(c, head *object.Commit, filterFiles []string, opts Options) (string, error) {
 	cTree, err := c.Tree()
 	if err != nil {
 		return "", err
@@ -135,8 +378,9 @@ func GetFullDiff(c, head *object.Commit, filterFiles []string) (string, error) {
 		return "", err
 	}
 
-	// Diff commit -> head (shows what happened *after* the commit)
-	patch, err := cTree.Patch(headTree)
+	// Diff commit -> head (shows what happened *after* the commit),
+	// following files renamed since
+	filePatches, err := opts.provider().FilePatches(c, head, true)
 	if err != nil {
 		return "", err
 	}
@@ -147,72 +391,96 @@ func GetFullDiff(c, head *object.Commit, filterFiles []string) (string, error) {
 		fileSet[f] = true
 	}
 
-	var sb strings.Builder
-	sb.Grow(defaultDiffBufferSize)
+	// A path both deleted and added changed type, as git diffs it; the
+	// addition tells what it became
+	added := make(map[string]bool)
+	for _, fp := range filePatches {
+		if from, to := fp.Files(); from == nil && to != nil {
+			added[to.Path()] = true
+		}
+	}
 
-	for _, fp := range patch.FilePatches() {
+	var sections []fileSection
+
+	for _, fp := range filePatches {
+		fp = normalizePatch(fp, cTree, headTree)
 		from, to := fp.Files()
-		path := ""
+		path := patchPath(fp)
 		if from != nil {
+			// Renamed files are matched by their path at the commit
 			path = from.Path()
 		}
-		if to != nil {
-			path = to.Path()
+		if !fileSet[path] || fp.IsBinary() {
+			continue
 		}
 
-		if fileSet[path] && !fp.IsBinary() {
-			sb.WriteString(fmt.Sprintf("--- %s (Evolution to HEAD)\n", path))
-			for _, chunk := range fp.Chunks() {
-				content := chunk.Content()
-				if len(content) == 0 {
-					continue
-				}
-				op := " "
-				switch chunk.Type() {
-				case 0: // Equal (context)
-					op = " "
-				case 1: // Add
-					op = "+"
-				case 2: // Delete
-					op = "-"
-				}
-				lines := strings.Split(content, "\n")
-				for _, line := range lines {
-					if line == "" {
-						continue
-					}
-					sb.WriteString(fmt.Sprintf("%s%s\n", op, line))
-				}
-			}
+		var sb strings.Builder
+		if to == nil && added[path] {
+			continue
+		}
+		if to == nil {
+			sb.WriteString(fmt.Sprintf("--- %s (Evolution to HEAD; deleted)\n", path))
+			sb.WriteString(deletionNote(c, head, path))
+			sections = append(sections, fileSection{path: path, text: sb.String()})
+			continue
+		}
+
+		header := "Evolution to HEAD"
+		if to.Path() != path {
+			header += "; renamed to " + to.Path()
+		}
+		fileOpts := opts.wholeFile(headTree, to.Path())
+		if opts.BlameEvolution {
+			lines := patchLines(fp.Chunks())
+			annotateBlame(lines, c, head, path, to.Path())
+			header += fmt.Sprintf("; changed lines start with the commit that last touched them, this commit is %s", c.Hash.String()[:blameHashLen])
+			writeFileHeader(&sb, path, fp, header)
+			writeLines(&sb, lines, fileOpts)
+		} else {
+			writeFileHeader(&sb, path, fp, header)
+			writeChunks(&sb, fp.Chunks(), fileOpts)
 		}
+		sections = append(sections, fileSection{path: path, text: sb.String()})
 	}
 
-	if sb.Len() == 0 {
-		return "No further changes to these files since this commit.", nil
+	if len(sections) == 0 {
+		return noFurtherChanges(c, filePatches), nil
 	}
+	sections = mergeSections(sections)
 
-	result := sb.String()
-	return TruncateDiff(result, MaxDiffSize), nil
+	result := budgetSections(sections, opts.MaxTokens, opts.Tokenizer, opts.ErrorMessage, opts.DropIrrelevantHunks)
+	return TruncateDiff(result, opts.maxFullDiffSize()), nil
 }
 
-// ShouldIgnoreFile returns true if the file should be skipped during analysis
-func ShouldIgnoreFile(path string) bool {
-	// Normalize path separators
-	path = strings.ReplaceAll(path, "\\", "/")
-
-	// 1. Lock files and checksums
-	lockFiles := []string{
-		"go.sum", "package-lock.json", "yarn.lock", "Gemfile.lock",
-		"poetry.lock", "pnpm-lock.yaml", "Cargo.lock", "composer.lock",
-		"Pipfile.lock", "shrinkwrap.yaml",
+// noFurtherChanges is the evolution of files unchanged since c. A root
+// commit held the whole repository, so the files added since, which the
+// evolution leaves out, are counted lest it read as if nothing changed.
+func noFurtherChanges(c *object.Commit, filePatches []diff.FilePatch) string {
+	const unchanged = "No further changes to these files since this commit."
+	if len(c.ParentHashes) > 0 {
+		return unchanged
 	}
-	for _, lf := range lockFiles {
-		if strings.HasSuffix(path, lf) {
-			return true
+	added := 0
+	for _, fp := range filePatches {
+		if from, to := fp.Files(); from == nil && to != nil {
+			added++
 		}
 	}
+	if added == 0 {
+		return unchanged + " It is the repository's first commit, and no files were added since."
+	}
+	return fmt.Sprintf("%s It is the repository's first commit; files added since, not shown here: %d.", unchanged, added)
+}
+
+// ShouldIgnoreFile returns true if the file should be skipped during analysis
+func ShouldIgnoreFile(path string) bool {
+	return ignoreRule(path, false) != ""
+}
+
+// IsTestFile reports whether path looks like a test file
+func IsTestFile(path string) bool {
+	path = strings.ReplaceAll(path, "\\", "/")
 
-	// 2. Test files
 	testPatterns := []string{
 		"_test.go", ".test.js", ".test.ts", ".spec.js", ".spec.ts",
 		"_test.py", "_spec.rb",
@@ -230,6 +498,31 @@ func ShouldIgnoreFile(path string) bool {
 			return true
 		}
 	}
+	return false
+}
+
+// ignoreRule applies the built-in rules, returning the one that drops
+// path (empty: kept); test files are kept when includeTests is set
+func ignoreRule(path string, includeTests bool) string {
+	// Normalize path separators
+	path = strings.ReplaceAll(path, "\\", "/")
+
+	// 1. Lock files and checksums
+	lockFiles := []string{
+		"go.sum", "package-lock.json", "yarn.lock", "Gemfile.lock",
+		"poetry.lock", "pnpm-lock.yaml", "Cargo.lock", "composer.lock",
+		"Pipfile.lock", "shrinkwrap.yaml",
+	}
+	for _, lf := range lockFiles {
+		if strings.HasSuffix(path, lf) {
+			return RuleLockFile
+		}
+	}
+
+	// 2. Test files
+	if !includeTests && IsTestFile(path) {
+		return RuleTest
+	}
 
 	// 3. Directories to ignore
 	ignoreDirs := []string{
@@ -239,7 +532,7 @@ func ShouldIgnoreFile(path string) bool {
 	}
 	for _, dir := range ignoreDirs {
 		if strings.Contains(path, dir) {
-			return true
+			return RuleVendored
 		}
 	}
 
@@ -249,8 +542,8 @@ func ShouldIgnoreFile(path string) bool {
 		strings.HasPrefix(path, ".circleci/") ||
 		path == ".gitlab-ci.yml" ||
 		path == ".travis.yml" {
-		return true
+		return RuleCI
 	}
 
-	return false
+	return ""
 }
@@ -1,6 +1,7 @@
 package gitdiff
 
 import (
+	"fmt"
 	"strings"
 	"testing"
 )
@@ -163,3 +164,137 @@ func TestTruncateDiffPreservesLineBreaks(t *testing.T) {
 		t.Errorf("Truncated diff should end at line boundary, got: %q", result)
 	}
 }
+
+func TestIsTestFile(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"handler_test.go", true},
+		{"web/app.spec.ts", true},
+		{"tests/test_api.py", true},
+		{"handler.go", false},
+		{"testdata/input.go", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsTestFile(tt.path); got != tt.expected {
+			t.Errorf("IsTestFile(%q) = %v, expected %v", tt.path, got, tt.expected)
+		}
+	}
+}
+
+func TestFullFileMaxBytes(t *testing.T) {
+	var long []string
+	for i := 1; i <= 40; i++ {
+		long = append(long, fmt.Sprintf("line %d", i))
+	}
+	longOld := strings.Join(long, "\n") + "\n"
+	longNew := strings.Replace(longOld, "line 20\n", "line twenty\n", 1)
+
+	parent := commitFiles(t, map[string]string{
+		"small.txt": "a\nb\nc\nd\ne\nf\n",
+		"large.txt": longOld,
+	})
+	c := commitFiles(t, map[string]string{
+		"small.txt": "a\nb\nc\nd\ne\nF\n",
+		"large.txt": longNew,
+	})
+
+	opts := Options{ContextLines: 1, FullFileMaxBytes: 100}
+	diff, _, err := GetStandardDiffWithOptions(c, parent, opts)
+	if err != nil {
+		t.Fatalf("GetStandardDiffWithOptions failed: %v", err)
+	}
+	if !strings.Contains(diff, "--- small.txt\n a\n b\n c\n d\n e\n-f\n+F\n") {
+		t.Errorf("Expected small file to be sent whole, got:\n%s", diff)
+	}
+	if strings.Contains(diff, " line 1\n") || !strings.Contains(diff, "@@") {
+		t.Errorf("Expected large file to be sent as hunks, got:\n%s", diff)
+	}
+
+	// Evolution diffs are sized at HEAD
+	full, err := GetFullDiffWithOptions(parent, c, []string{"small.txt", "large.txt"}, opts)
+	if err != nil {
+		t.Fatalf("GetFullDiffWithOptions failed: %v", err)
+	}
+	if !strings.Contains(full, "--- small.txt (Evolution to HEAD)\n a\n") {
+		t.Errorf("Expected small file to be sent whole, got:\n%s", full)
+	}
+
+	opts.FullFileMaxBytes = 0
+	diff, _, err = GetStandardDiffWithOptions(c, parent, opts)
+	if err != nil {
+		t.Fatalf("GetStandardDiffWithOptions failed: %v", err)
+	}
+	if strings.Contains(diff, " a\n") {
+		t.Errorf("Expected hunks only with FullFileMaxBytes 0, got:\n%s", diff)
+	}
+}
+
+func TestMaxDiffSizes(t *testing.T) {
+	var old, changed []string
+	for i := 1; i <= 200; i++ {
+		old = append(old, fmt.Sprintf("line %d", i))
+		changed = append(changed, fmt.Sprintf("line %d changed", i))
+	}
+	parent := commitFiles(t, map[string]string{"a.txt": strings.Join(old, "\n") + "\n"})
+	c := commitFiles(t, map[string]string{"a.txt": strings.Join(changed, "\n") + "\n"})
+
+	diff, _, err := GetStandardDiffWithOptions(c, parent, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff) <= 1000 {
+		t.Fatalf("expected a diff over 1000 characters, got %d", len(diff))
+	}
+
+	opts := Options{MaxDiffSize: 1000, MaxFullDiffSize: 500}
+	diff, _, err = GetStandardDiffWithOptions(c, parent, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff) > 1000 || !strings.HasSuffix(diff, TruncationMarker) {
+		t.Errorf("expected the standard diff truncated to 1000 characters, got %d", len(diff))
+	}
+	full, err := GetFullDiffWithOptions(parent, c, []string{"a.txt"}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(full) > 500 || !strings.HasSuffix(full, TruncationMarker) {
+		t.Errorf("expected the full diff truncated to 500 characters, got %d", len(full))
+	}
+}
+
+func TestFitPromptDiffs(t *testing.T) {
+	line := strings.Repeat("x", 49) + "\n"
+	std := strings.Repeat(line, 20)  // 1000 characters
+	full := strings.Repeat(line, 20) // 1000 characters
+
+	tests := []struct {
+		name              string
+		std, full         string
+		maxSize           int
+		wantStd, wantFull int // maximum lengths
+	}{
+		{"no cap", std, full, 0, 1000, 1000},
+		{"fits", std, full, 2000, 1000, 1000},
+		{"full diff cut first", std, full, 1500, 1000, 500},
+		{"both cut, full keeps a quarter", std, full, 800, 600, 200},
+		{"short full diff kept", std, line, 600, 550, 50},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStd, gotFull := FitPromptDiffs(tt.std, tt.full, tt.maxSize)
+			if len(gotStd) > tt.wantStd || len(gotFull) > tt.wantFull {
+				t.Errorf("got %d and %d characters, want at most %d and %d", len(gotStd), len(gotFull), tt.wantStd, tt.wantFull)
+			}
+			if tt.maxSize > 0 && len(gotStd)+len(gotFull) > tt.maxSize {
+				t.Errorf("got %d characters together, over %d", len(gotStd)+len(gotFull), tt.maxSize)
+			}
+			if tt.maxSize == 0 && (gotStd != tt.std || gotFull != tt.full) {
+				t.Error("expected the diffs unchanged without a cap")
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package gitdiff
+
+import (
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	utildiff "github.com/go-git/go-git/v5/utils/diff"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// cp1252High maps bytes 0x80-0x9F of Windows-1252 to runes; the other
+// non-ASCII bytes match Latin-1. Undefined bytes map to U+FFFD.
+var cp1252High = [32]rune{
+	'€', '�', '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', '�', 'Ž', '�',
+	'�', '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', '�', 'ž', 'Ÿ',
+}
+
+// decodeText converts file content to UTF-8 text with LF line endings.
+// UTF-16 with a byte order mark is transcoded, other invalid UTF-8 is read
+// as Windows-1252 (a superset of Latin-1), and CRLF and lone CR line
+// endings become LF. It reports false for content that is not text.
+func decodeText(data string) (string, bool) {
+	switch {
+	case strings.HasPrefix(data, "\xff\xfe"):
+		data = decodeUTF16(data[2:], false)
+	case strings.HasPrefix(data, "\xfe\xff"):
+		data = decodeUTF16(data[2:], true)
+	default:
+		data = strings.TrimPrefix(data, "\xef\xbb\xbf")
+		if strings.IndexByte(data, 0) >= 0 {
+			return "", false
+		}
+		if !utf8.ValidString(data) {
+			data = decodeCP1252(data)
+		}
+	}
+	if strings.IndexByte(data, '\r') >= 0 {
+		data = strings.ReplaceAll(data, "\r\n", "\n")
+		data = strings.ReplaceAll(data, "\r", "\n")
+	}
+	return data, true
+}
+
+// decodeUTF16 transcodes UTF-16 without its byte order mark
+func decodeUTF16(data string, bigEndian bool) string {
+	units := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		if bigEndian {
+			units = append(units, uint16(data[i])<<8|uint16(data[i+1]))
+		} else {
+			units = append(units, uint16(data[i+1])<<8|uint16(data[i]))
+		}
+	}
+	return string(utf16.Decode(units))
+}
+
+// decodeCP1252 transcodes Windows-1252
+func decodeCP1252(data string) string {
+	var sb strings.Builder
+	sb.Grow(len(data))
+	for i := 0; i < len(data); i++ {
+		b := data[i]
+		switch {
+		case b < 0x80:
+			sb.WriteByte(b)
+		case b < 0xA0:
+			sb.WriteRune(cp1252High[b-0x80])
+		default:
+			sb.WriteRune(rune(b))
+		}
+	}
+	return sb.String()
+}
+
+// textPatch is a file patch recomputed from normalized content
+type textPatch struct {
+	from, to diff.File
+	chunks   []diff.Chunk
+}
+
+func (p *textPatch) IsBinary() bool                { return false }
+func (p *textPatch) Files() (diff.File, diff.File) { return p.from, p.to }
+func (p *textPatch) Chunks() []diff.Chunk          { return p.chunks }
+
+// normalizePatch recomputes a file patch from normalized text (see
+// decodeText) when either side has CR line endings or is not UTF-8, so
+// that line-ending conversions do not show as whole-file changes and
+// legacy encodings are readable. Binary patches of UTF-16 files are
+// decoded from the trees. Other patches are returned unchanged.
+func normalizePatch(fp diff.FilePatch, fromTree, toTree *object.Tree) diff.FilePatch {
+	from, to := fp.Files()
+
+	var oldRaw, newRaw string
+	if fp.IsBinary() {
+		var ok bool
+		if from != nil {
+			if oldRaw, ok = readUTF16(fromTree, from.Path()); !ok {
+				return fp
+			}
+		}
+		if to != nil {
+			if newRaw, ok = readUTF16(toTree, to.Path()); !ok {
+				return fp
+			}
+		}
+	} else {
+		var oldSB, newSB strings.Builder
+		for _, chunk := range fp.Chunks() {
+			if chunk.Type() != diff.Add {
+				oldSB.WriteString(chunk.Content())
+			}
+			if chunk.Type() != diff.Delete {
+				newSB.WriteString(chunk.Content())
+			}
+		}
+		oldRaw, newRaw = oldSB.String(), newSB.String()
+		if !needsNormalizing(oldRaw) && !needsNormalizing(newRaw) {
+			return fp
+		}
+	}
+
+	oldText, ok := decodeText(oldRaw)
+	if !ok {
+		return fp
+	}
+	newText, ok := decodeText(newRaw)
+	if !ok {
+		return fp
+	}
+
+	patch := &textPatch{from: from, to: to}
+	for _, d := range utildiff.Do(oldText, newText) {
+		op := diff.Equal
+		switch d.Type {
+		case diffmatchpatch.DiffInsert:
+			op = diff.Add
+		case diffmatchpatch.DiffDelete:
+			op = diff.Delete
+		}
+		patch.chunks = append(patch.chunks, &textChunk{content: d.Text, op: op})
+	}
+	return patch
+}
+
+// needsNormalizing reports whether text has CR line endings or is not
+// valid UTF-8
+func needsNormalizing(text string) bool {
+	return strings.IndexByte(text, '\r') >= 0 || !utf8.ValidString(text)
+}
+
+// readUTF16 returns the content of a file that starts with a UTF-16 byte
+// order mark; other files are not read in full
+func readUTF16(tree *object.Tree, filePath string) (string, bool) {
+	head := string(fileHead(tree, filePath))
+	if !strings.HasPrefix(head, "\xff\xfe") && !strings.HasPrefix(head, "\xfe\xff") {
+		return "", false
+	}
+	return readFile(tree, filePath)
+}
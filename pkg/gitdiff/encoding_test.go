@@ -0,0 +1,72 @@
+package gitdiff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodeText(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected string
+		ok       bool
+	}{
+		{"utf-8", "héllo\n", "héllo\n", true},
+		{"crlf", "a\r\nb\r\n", "a\nb\n", true},
+		{"lone cr", "a\rb\r", "a\nb\n", true},
+		{"utf-8 bom", "\xef\xbb\xbfx\n", "x\n", true},
+		{"latin-1", "caf\xe9\n", "café\n", true},
+		{"windows-1252 quotes", "\x93hi\x94\n", "“hi”\n", true},
+		{"utf-16le", "\xff\xfeh\x00i\x00\r\x00\n\x00", "hi\n", true},
+		{"utf-16be", "\xfe\xff\x00h\x00i", "hi", true},
+		{"binary", "PNG\x00\x01", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := decodeText(tt.data)
+			if ok != tt.ok || got != tt.expected {
+				t.Errorf("decodeText(%q): expected %q %v, got %q %v", tt.data, tt.expected, tt.ok, got, ok)
+			}
+		})
+	}
+}
+
+func TestGetStandardDiffNormalizesLineEndings(t *testing.T) {
+	parent := commitFiles(t, map[string]string{"app.cs": "class A {\n  int x = 1;\n  int y = 2;\n}\n"})
+	c := commitFiles(t, map[string]string{"app.cs": "class A {\r\n  int x = 1;\r\n  int y = 3;\r\n}\r\n"})
+
+	diff, _, err := GetStandardDiffWithOptions(c, parent, Options{})
+	if err != nil {
+		t.Fatalf("GetStandardDiffWithOptions failed: %v", err)
+	}
+	expected := "--- app.cs\n class A {\n   int x = 1;\n-  int y = 2;\n+  int y = 3;\n }\n"
+	if diff != expected {
+		t.Errorf("Expected only the real change, got:\n%q", diff)
+	}
+}
+
+func TestGetStandardDiffTranscodesLegacyEncodings(t *testing.T) {
+	parent := commitFiles(t, map[string]string{
+		"legacy.txt": "na\xefve\n",
+		"wide.txt":   "\xff\xfea\x00\n\x00",
+	})
+	c := commitFiles(t, map[string]string{
+		"legacy.txt": "na\xefve caf\xe9\n",
+		"wide.txt":   "\xff\xfeb\x00\n\x00",
+	})
+
+	diff, files, err := GetStandardDiffWithOptions(c, parent, Options{})
+	if err != nil {
+		t.Fatalf("GetStandardDiffWithOptions failed: %v", err)
+	}
+	for _, want := range []string{"-naïve\n+naïve café\n", "--- wide.txt\n-a\n+b\n"} {
+		if !strings.Contains(diff, want) {
+			t.Errorf("Expected %q in:\n%s", want, diff)
+		}
+	}
+	if len(files) != 2 {
+		t.Errorf("Expected UTF-16 file to be analyzed, got files %v", files)
+	}
+}
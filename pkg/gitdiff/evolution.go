@@ -0,0 +1,54 @@
+package gitdiff
+
+import (
+	"fmt"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// maxDeletionSearch bounds the commits walked back from HEAD to find the
+// one that deleted a file
+const maxDeletionSearch = 1000
+
+// deletedIn returns the commit on HEAD's first-parent history that deleted
+// filePath, or nil if it cannot be found. The walk stops at c, which still
+// had the file.
+func deletedIn(c, head *object.Commit, filePath string) *object.Commit {
+	if hasFile(head, filePath) {
+		return nil
+	}
+	current := head
+	for i := 0; i < maxDeletionSearch && current.Hash != c.Hash; i++ {
+		if len(current.ParentHashes) == 0 {
+			return nil
+		}
+		parent, err := current.Parent(0)
+		if err != nil {
+			return nil
+		}
+		if hasFile(parent, filePath) {
+			return current
+		}
+		current = parent
+	}
+	return nil
+}
+
+// hasFile reports whether filePath exists at c
+func hasFile(c *object.Commit, filePath string) bool {
+	tree, err := c.Tree()
+	if err != nil {
+		return false
+	}
+	_, err = tree.File(filePath)
+	return err == nil
+}
+
+// deletionNote describes the deletion of filePath after c
+func deletionNote(c, head *object.Commit, filePath string) string {
+	if d := deletedIn(c, head, filePath); d != nil {
+		return fmt.Sprintf("File %s was deleted in %s (%s); none of this commit's changes to it remain at HEAD.\n",
+			filePath, d.Hash.String()[:8], commitSubject(d))
+	}
+	return fmt.Sprintf("File %s was deleted after this commit; none of this commit's changes to it remain at HEAD.\n", filePath)
+}
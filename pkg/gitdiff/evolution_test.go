@@ -0,0 +1,144 @@
+package gitdiff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGetFullDiffDeletedAtHead(t *testing.T) {
+	commits := commitHistory(t,
+		map[string]string{"old.go": "package a\n\nfunc old() {}\n", "main.go": "package a\n"},
+		map[string]string{"old.go": "package a\n\nfunc old() { run() }\n"},
+		map[string]string{"main.go": "package a\n\nfunc main() {}\n"},
+		map[string]string{"old.go": deleteFile},
+		map[string]string{"main.go": "package a\n\nfunc main() { run() }\n"},
+	)
+	suspect, deleter, head := commits[1], commits[3], commits[4]
+
+	full, err := GetFullDiffWithOptions(suspect, head, []string{"old.go"}, Options{})
+	if err != nil {
+		t.Fatalf("GetFullDiffWithOptions failed: %v", err)
+	}
+	expected := "--- old.go (Evolution to HEAD; deleted)\nFile old.go was deleted in " + deleter.Hash.String()[:8] + " (commit 3)"
+	if !strings.HasPrefix(full, expected) {
+		t.Errorf("Expected deletion note %q, got:\n%s", expected, full)
+	}
+	if strings.Contains(full, "-func old") {
+		t.Errorf("Expected no removed lines for a deleted file, got:\n%s", full)
+	}
+}
+
+func TestGetFullDiffFollowsRename(t *testing.T) {
+	body := "package a\n\nfunc load(n int) bool {\n\tif n < 0 {\n\t\treturn false\n\t}\n\treturn true\n}\n"
+	commits := commitHistory(t,
+		map[string]string{"loader.go": body},
+		map[string]string{"loader.go": strings.Replace(body, "n < 0", "n <= 0", 1)},
+		map[string]string{"loader.go": deleteFile, "load/loader.go": strings.Replace(body, "n < 0", "n <= 0", 1)},
+		map[string]string{"load/loader.go": strings.Replace(body, "n < 0", "n < 1", 1)},
+	)
+	suspect, head := commits[1], commits[3]
+
+	full, err := GetFullDiffWithOptions(suspect, head, []string{"loader.go"}, Options{})
+	if err != nil {
+		t.Fatalf("GetFullDiffWithOptions failed: %v", err)
+	}
+	for _, want := range []string{
+		"--- loader.go (Evolution to HEAD; renamed to load/loader.go)\n",
+		"-\tif n <= 0 {\n",
+		"+\tif n < 1 {\n",
+	} {
+		if !strings.Contains(full, want) {
+			t.Errorf("Expected %q in:\n%s", want, full)
+		}
+	}
+}
+
+func TestDeletedInNotFound(t *testing.T) {
+	commits := commitHistory(t,
+		map[string]string{"a.go": "package a\n"},
+		map[string]string{"b.go": "package a\n"},
+	)
+	if d := deletedIn(commits[0], commits[1], "a.go"); d != nil {
+		t.Errorf("Expected no deleting commit for a file still present, got %s", d.Hash)
+	}
+	if note := deletionNote(commits[1], commits[0], "b.go"); !strings.Contains(note, "deleted after this commit") {
+		t.Errorf("Expected generic deletion note, got %q", note)
+	}
+}
+
+func TestRootCommitDiffs(t *testing.T) {
+	commits := commitHistory(t,
+		map[string]string{"main.go": "package main\n\nfunc main() {}\n", "go.sum": "checksum\n"},
+		map[string]string{"server.go": "package main\n\nfunc serve() {}\n"},
+		map[string]string{"client.go": "package main\n\nfunc call() {}\n"},
+	)
+	root, head := commits[0], commits[2]
+
+	// Without a parent, every line of the root commit's files is added
+	std, files, err := GetStandardDiffWithOptions(root, nil, Options{})
+	if err != nil {
+		t.Fatalf("GetStandardDiffWithOptions failed: %v", err)
+	}
+	if len(files) != 1 || files[0] != "main.go" {
+		t.Errorf("Expected only main.go diffed, got %v", files)
+	}
+	if !strings.Contains(std, "--- main.go\n+package main\n+func main() {}\n") {
+		t.Errorf("Expected main.go added whole, got:\n%s", std)
+	}
+
+	// Files added after the root commit are counted, not left unmentioned
+	full, err := GetFullDiffWithOptions(root, head, files, Options{})
+	if err != nil {
+		t.Fatalf("GetFullDiffWithOptions failed: %v", err)
+	}
+	if !strings.HasPrefix(full, "No further changes to these files") || !strings.Contains(full, "first commit; files added since, not shown here: 2.") {
+		t.Errorf("Expected the files added since counted, got %q", full)
+	}
+
+	// A root commit that is also HEAD has nothing added since
+	full, err = GetFullDiffWithOptions(root, root, files, Options{})
+	if err != nil {
+		t.Fatalf("GetFullDiffWithOptions failed: %v", err)
+	}
+	if !strings.Contains(full, "no files were added since") {
+		t.Errorf("Expected no files added since, got %q", full)
+	}
+
+	// Later commits keep the plain note
+	full, err = GetFullDiffWithOptions(commits[1], head, []string{"server.go"}, Options{})
+	if err != nil {
+		t.Fatalf("GetFullDiffWithOptions failed: %v", err)
+	}
+	if full != "No further changes to these files since this commit." {
+		t.Errorf("Expected the plain note for a non-root commit, got %q", full)
+	}
+}
+
+func TestRenamedFilesListedOnce(t *testing.T) {
+	body := "package a\n\nfunc load() bool {\n\treturn true\n}\n"
+	commits := commitHistory(t,
+		map[string]string{"loader.go": body},
+		map[string]string{"loader.go": deleteFile, "load.go": strings.Replace(body, "true", "false", 1)},
+		map[string]string{"load.go": strings.Replace(body, "true", "!ok", 1)},
+	)
+	parent, c, head := commits[0], commits[1], commits[2]
+
+	// The standard diff has no rename detection: the old and new paths
+	// are listed once each
+	_, files, err := GetStandardDiffWithOptions(c, parent, Options{})
+	if err != nil {
+		t.Fatalf("GetStandardDiffWithOptions failed: %v", err)
+	}
+	if len(files) != 2 || files[0] != "load.go" || files[1] != "loader.go" {
+		t.Fatalf("Expected load.go and loader.go, got %v", files)
+	}
+
+	// Only the new path has an evolution; the old one is gone at the commit
+	full, err := GetFullDiffWithOptions(c, head, append(files, files...), Options{})
+	if err != nil {
+		t.Fatalf("GetFullDiffWithOptions failed: %v", err)
+	}
+	if strings.Count(full, "--- load.go (Evolution to HEAD)") != 1 || strings.Contains(full, "loader.go") {
+		t.Errorf("Expected one evolution section, for load.go, got:\n%s", full)
+	}
+}
@@ -0,0 +1,138 @@
+package gitdiff
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// Filter selects which changed files are passed to the LLM, on top of the
+// built-in rules in ShouldIgnoreFile.
+//
+// Patterns use doublestar syntax ("**" matches any number of directories).
+// A pattern without a "/" matches the file name at any depth, like
+// .gitignore, so "*.min.js" excludes minified files everywhere.
+type Filter struct {
+	// Include, if non-empty, is an allowlist: only matching files are kept
+	Include []string
+
+	// Exclude drops matching files even if they are included
+	Exclude []string
+
+	// Only, if non-empty, is an allowlist like Include that also limits
+	// the commits considered to those changing a matching file (see
+	// CommitPathFilter), for engineers who already know the subsystem
+	Only []string
+
+	// IncludeTests keeps test files that the built-in rules would skip,
+	// for bugs that are themselves failing or flaky tests
+	IncludeTests bool
+}
+
+// NewFilter validates the patterns and returns a Filter. Empty patterns
+// are ignored.
+func NewFilter(include, exclude []string) (*Filter, error) {
+	f := &Filter{}
+	var err error
+	if f.Include, err = validPatterns("include", include); err != nil {
+		return nil, err
+	}
+	if f.Exclude, err = validPatterns("exclude", exclude); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// SetOnly validates the patterns and sets them as f.Only. Empty patterns
+// are ignored.
+func (f *Filter) SetOnly(patterns []string) error {
+	only, err := validPatterns("only", patterns)
+	if err != nil {
+		return err
+	}
+	f.Only = only
+	return nil
+}
+
+// validPatterns trims patterns, drops empty ones, and checks the syntax of
+// the rest
+func validPatterns(kind string, patterns []string) ([]string, error) {
+	var valid []string
+	for _, p := range patterns {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		if !doublestar.ValidatePattern(p) {
+			return nil, fmt.Errorf("invalid %s pattern %q", kind, p)
+		}
+		valid = append(valid, p)
+	}
+	return valid, nil
+}
+
+// CommitPathFilter returns a filter for git.LogOptions.PathFilter that
+// keeps commits changing a file matched by f.Only, or nil (all commits)
+// when f is nil or Only is empty
+func (f *Filter) CommitPathFilter() func(string) bool {
+	if f == nil || len(f.Only) == 0 {
+		return nil
+	}
+	return func(path string) bool {
+		return matchAny(f.Only, strings.ReplaceAll(path, "\\", "/"))
+	}
+}
+
+// Ignore reports whether path should be left out of the diff. A nil
+// Filter applies only the built-in rules.
+func (f *Filter) Ignore(path string) bool {
+	return f.IgnoreRule(path) != ""
+}
+
+// Rules dropping a file, as returned by Filter.IgnoreRule
+const (
+	RuleLockFile = "lockfile" // lock files and checksums
+	RuleTest     = "test"     // test files, unless IncludeTests
+	RuleVendored = "vendored" // vendored, dependency, build output, and editor directories
+	RuleCI       = "ci"       // CI configuration
+	RuleFilter   = "filter"   // Include, Only, or Exclude patterns
+)
+
+// IgnoreRule returns the rule that drops path, one of the Rule
+// constants, or "" if the file is kept. A nil Filter applies only the
+// built-in rules.
+func (f *Filter) IgnoreRule(path string) string {
+	if f == nil {
+		return ignoreRule(path, false)
+	}
+	if rule := ignoreRule(path, f.IncludeTests); rule != "" {
+		return rule
+	}
+	path = strings.ReplaceAll(path, "\\", "/")
+
+	if (len(f.Include) > 0 && !matchAny(f.Include, path)) ||
+		(len(f.Only) > 0 && !matchAny(f.Only, path)) ||
+		matchAny(f.Exclude, path) {
+		return RuleFilter
+	}
+	return ""
+}
+
+// matchAny reports whether path matches any of the patterns
+func matchAny(patterns []string, path string) bool {
+	base := path
+	if idx := strings.LastIndex(path, "/"); idx != -1 {
+		base = path[idx+1:]
+	}
+	for _, p := range patterns {
+		target := path
+		if !strings.Contains(p, "/") {
+			target = base
+		}
+		// Invalid patterns (rejected by NewFilter) never match
+		if ok, _ := doublestar.Match(p, target); ok {
+			return true
+		}
+	}
+	return false
+}
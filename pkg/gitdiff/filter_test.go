@@ -0,0 +1,146 @@
+package gitdiff
+
+import "testing"
+
+func TestFilterIgnore(t *testing.T) {
+	f, err := NewFilter(
+		[]string{"src/**", "cmd/*/main.go"},
+		[]string{"*.min.js", "src/generated/**", " "},
+	)
+	if err != nil {
+		t.Fatalf("NewFilter failed: %v", err)
+	}
+
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"src/server/handler.go", false},
+		{"cmd/tool/main.go", false},
+		{"cmd/tool/flags.go", true},          // not in allowlist
+		{"README.md", true},                  // not in allowlist
+		{"src/static/app.min.js", true},      // excluded by base name
+		{"src/generated/api.pb.go", true},    // excluded by path
+		{"src/server/handler_test.go", true}, // built-in rule still applies
+		{"src\\server\\util.go", false},      // Windows separators
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := f.Ignore(tt.path); got != tt.expected {
+				t.Errorf("Ignore(%q) = %v, expected %v", tt.path, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFilterExcludeOnly(t *testing.T) {
+	f, err := NewFilter(nil, []string{"docs/**"})
+	if err != nil {
+		t.Fatalf("NewFilter failed: %v", err)
+	}
+	if !f.Ignore("docs/guide/setup.go") {
+		t.Error("Expected docs/** to be excluded")
+	}
+	if f.Ignore("main.go") {
+		t.Error("Expected main.go to be kept without an allowlist")
+	}
+}
+
+func TestNilFilterUsesBuiltinRules(t *testing.T) {
+	var f *Filter
+	if !f.Ignore("go.sum") {
+		t.Error("Expected nil filter to ignore go.sum")
+	}
+	if f.Ignore("main.go") {
+		t.Error("Expected nil filter to keep main.go")
+	}
+}
+
+func TestNewFilterInvalidPattern(t *testing.T) {
+	if _, err := NewFilter([]string{"src/[a-"}, nil); err == nil {
+		t.Error("Expected error for invalid include pattern")
+	}
+	if _, err := NewFilter(nil, []string{"{a,b"}); err == nil {
+		t.Error("Expected error for invalid exclude pattern")
+	}
+}
+
+func TestFilterIncludeTests(t *testing.T) {
+	f := &Filter{IncludeTests: true}
+	for _, path := range []string{"handler_test.go", "src/app.spec.ts", "tests/test_api.py"} {
+		if f.Ignore(path) {
+			t.Errorf("Expected %s to be kept with IncludeTests", path)
+		}
+	}
+	// Other built-in rules still apply
+	if !f.Ignore("vendor/lib/lib_test.go") || !f.Ignore("go.sum") {
+		t.Error("Expected vendor and lock files to stay ignored with IncludeTests")
+	}
+}
+
+func TestFilterOnly(t *testing.T) {
+	f, err := NewFilter(nil, []string{"pkg/auth/mocks/**"})
+	if err != nil {
+		t.Fatalf("NewFilter failed: %v", err)
+	}
+	if err := f.SetOnly([]string{"pkg/auth/**", " "}); err != nil {
+		t.Fatalf("SetOnly failed: %v", err)
+	}
+
+	tests := []struct {
+		path     string
+		ignored  bool
+		selected bool
+	}{
+		{"pkg/auth/session.go", false, true},
+		{"pkg/auth/mocks/store.go", true, true}, // excluded, but the commit is still considered
+		{"pkg/billing/invoice.go", true, false},
+		{"pkg\\auth\\token.go", false, true},
+	}
+	pathFilter := f.CommitPathFilter()
+	for _, tt := range tests {
+		if got := f.Ignore(tt.path); got != tt.ignored {
+			t.Errorf("Ignore(%q) = %v, expected %v", tt.path, got, tt.ignored)
+		}
+		if got := pathFilter(tt.path); got != tt.selected {
+			t.Errorf("CommitPathFilter(%q) = %v, expected %v", tt.path, got, tt.selected)
+		}
+	}
+
+	if (&Filter{}).CommitPathFilter() != nil || (*Filter)(nil).CommitPathFilter() != nil {
+		t.Error("Expected no commit path filter without Only patterns")
+	}
+	if err := f.SetOnly([]string{"pkg/[auth"}); err == nil {
+		t.Error("Expected error for invalid only pattern")
+	}
+}
+
+func TestFilterIgnoreRule(t *testing.T) {
+	f, err := NewFilter(nil, []string{"docs/**"})
+	if err != nil {
+		t.Fatalf("NewFilter failed: %v", err)
+	}
+
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"main.go", ""},
+		{"go.sum", RuleLockFile},
+		{"server/handler_test.go", RuleTest},
+		{"vendor/lib/lib.go", RuleVendored},
+		{".github/workflows/ci.yml", RuleCI},
+		{"docs/guide/setup.go", RuleFilter},
+	}
+	for _, tt := range tests {
+		if got := f.IgnoreRule(tt.path); got != tt.expected {
+			t.Errorf("IgnoreRule(%q) = %q, expected %q", tt.path, got, tt.expected)
+		}
+	}
+
+	var builtin *Filter
+	if got := builtin.IgnoreRule("docs/guide/setup.go"); got != "" {
+		t.Errorf("Expected nil filter to keep docs, got %q", got)
+	}
+}
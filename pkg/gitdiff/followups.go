@@ -0,0 +1,171 @@
+package gitdiff
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// maxFollowUpSearch bounds the commits walked back from HEAD to find
+// reverts and fixes of a commit
+const maxFollowUpSearch = 1000
+
+// Kinds of FollowUp
+const (
+	FollowUpRevert = "revert"
+	FollowUpFix    = "fix"
+)
+
+var (
+	// hashRefRe matches abbreviated or full commit hashes in messages
+	hashRefRe = regexp.MustCompile(`\b[0-9a-f]{7,64}\b`)
+	// fixWordRe matches words saying a commit fixes an earlier one
+	fixWordRe = regexp.MustCompile(`(?i)\b(fix(es|ed)?|regression|broke|broken|follow-?up)\b`)
+)
+
+// FollowUp is a later commit on HEAD's history that reverts or fixes an
+// analyzed commit, which is then unlikely to be the current root cause
+type FollowUp struct {
+	Hash    string `json:"hash"`
+	Subject string `json:"subject"`
+
+	// Kind is FollowUpRevert or FollowUpFix
+	Kind string `json:"kind"`
+
+	// Evidence says how the follow-up was found: "revert message",
+	// "inverse diff", or "references commit"
+	Evidence string `json:"evidence"`
+}
+
+// FindFollowUps walks HEAD's first-parent history back to c and returns
+// the commits that revert c, by a `Revert "<subject>"` or "This reverts
+// commit <hash>" message or by a diff that exactly undoes c's, or that
+// reference c's hash alongside a word like "fixes" (as in kernel-style
+// "Fixes: <hash>" trailers). Follow-ups are returned newest first.
+func FindFollowUps(c, head *object.Commit) ([]FollowUp, error) {
+	if c.Hash == head.Hash {
+		return nil, nil
+	}
+	changes, err := commitChanges(c)
+	if err != nil {
+		return nil, err
+	}
+	hash := c.Hash.String()
+	revertSubject := fmt.Sprintf("Revert %q", commitSubject(c))
+
+	var followUps []FollowUp
+	current := head
+	for i := 0; i < maxFollowUpSearch && current.Hash != c.Hash; i++ {
+		if len(current.ParentHashes) <= 1 {
+			if f, ok := followUpOf(current, hash, revertSubject, changes); ok {
+				followUps = append(followUps, f)
+			}
+		}
+		if len(current.ParentHashes) == 0 {
+			break
+		}
+		if current, err = current.Parent(0); err != nil {
+			return followUps, fmt.Errorf("walking history: %w", err)
+		}
+	}
+	return followUps, nil
+}
+
+// followUpOf checks whether later reverts or fixes the commit with hash,
+// subject-based revert message revertSubject, and tree changes changes
+func followUpOf(later *object.Commit, hash, revertSubject string, changes object.Changes) (FollowUp, bool) {
+	f := FollowUp{Hash: later.Hash.String(), Subject: commitSubject(later)}
+	switch {
+	case strings.Contains(later.Message, "This reverts commit "+hash) || f.Subject == revertSubject:
+		f.Kind, f.Evidence = FollowUpRevert, "revert message"
+		return f, true
+	case referencesHash(later.Message, hash) && fixWordRe.MatchString(later.Message):
+		f.Kind, f.Evidence = FollowUpFix, "references commit"
+		return f, true
+	}
+	if laterChanges, err := commitChanges(later); err == nil && isInverse(changes, laterChanges) {
+		f.Kind, f.Evidence = FollowUpRevert, "inverse diff"
+		return f, true
+	}
+	return FollowUp{}, false
+}
+
+// commitChanges returns the tree changes of c against its first parent
+func commitChanges(c *object.Commit) (object.Changes, error) {
+	cTree, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+	var pTree *object.Tree
+	if len(c.ParentHashes) > 0 {
+		parent, err := c.Parent(0)
+		if err != nil {
+			return nil, err
+		}
+		if pTree, err = parent.Tree(); err != nil {
+			return nil, err
+		}
+	}
+	changes, err := object.DiffTree(pTree, cTree)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff trees: %w", err)
+	}
+	return changes, nil
+}
+
+// isInverse reports whether later changes exactly the files of changes,
+// each back from its new blob and mode to its old ones
+func isInverse(changes, later object.Changes) bool {
+	if len(changes) == 0 || len(changes) != len(later) {
+		return false
+	}
+	type side struct {
+		name string
+		hash string
+		mode uint32
+	}
+	undo := make(map[side]side, len(changes))
+	for _, ch := range changes {
+		undo[side{ch.To.Name, ch.To.TreeEntry.Hash.String(), uint32(ch.To.TreeEntry.Mode)}] =
+			side{ch.From.Name, ch.From.TreeEntry.Hash.String(), uint32(ch.From.TreeEntry.Mode)}
+	}
+	for _, ch := range later {
+		want, ok := undo[side{ch.From.Name, ch.From.TreeEntry.Hash.String(), uint32(ch.From.TreeEntry.Mode)}]
+		if !ok || want != (side{ch.To.Name, ch.To.TreeEntry.Hash.String(), uint32(ch.To.TreeEntry.Mode)}) {
+			return false
+		}
+	}
+	return true
+}
+
+// referencesHash reports whether message mentions hash, in full or
+// abbreviated to at least 7 characters
+func referencesHash(message, hash string) bool {
+	for _, ref := range hashRefRe.FindAllString(strings.ToLower(message), -1) {
+		if strings.HasPrefix(hash, ref) {
+			return true
+		}
+	}
+	return false
+}
+
+// commitSubject returns the first line of a commit message
+func commitSubject(c *object.Commit) string {
+	subject, _, _ := strings.Cut(c.Message, "\n")
+	return strings.TrimSpace(subject)
+}
+
+// FormatFollowUps renders follow-ups for a prompt, one per line
+func FormatFollowUps(followUps []FollowUp) string {
+	var sb strings.Builder
+	for _, f := range followUps {
+		action := "fixes"
+		if f.Kind == FollowUpRevert {
+			action = "reverts"
+		}
+		sb.WriteString(fmt.Sprintf("%s (%s) %s this commit (%s)\n", f.Hash[:8], f.Subject, action, f.Evidence))
+	}
+	return sb.String()
+}
@@ -0,0 +1,59 @@
+package gitdiff
+
+import "testing"
+
+func TestFindFollowUps(t *testing.T) {
+	commits := commitHistory(t,
+		map[string]string{"auth.go": "package auth\n\nconst ttl = 60\n", "db.go": "package db\n"},
+		map[string]string{"auth.go": "package auth\n\nconst ttl = 6\n", commitMessage: "Shorten session TTL\n"},
+		map[string]string{"db.go": "package db\n\n// pool\n"},
+		map[string]string{"auth.go": "package auth\n\nconst ttl = 60\n", commitMessage: "Restore TTL\n"},
+	)
+	suspect := commitHistory(t,
+		map[string]string{"auth.go": "a\n"},
+		map[string]string{"auth.go": "b\n", commitMessage: "Tune cache\n"},
+	)[1]
+	hash := suspect.Hash.String()
+
+	tests := []struct {
+		name     string
+		message  string
+		kind     string
+		evidence string
+	}{
+		{"revert subject", "Revert \"Tune cache\"\n", FollowUpRevert, "revert message"},
+		{"reverts hash", "Undo cache tuning\n\nThis reverts commit " + hash + ".\n", FollowUpRevert, "revert message"},
+		{"fixes trailer", "cache: handle empty keys\n\nFixes: " + hash[:12] + " (\"Tune cache\")\n", FollowUpFix, "references commit"},
+		{"mention without fix", "cache: note on " + hash[:8] + "\n", "", ""},
+		{"unrelated", "Update docs\n", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			later := commitHistory(t,
+				map[string]string{"other.go": "x\n"},
+				map[string]string{"other.go": "y\n", commitMessage: tt.message},
+			)[1]
+			f, ok := followUpOf(later, hash, `Revert "Tune cache"`, nil)
+			if ok != (tt.kind != "") || f.Kind != tt.kind || f.Evidence != tt.evidence {
+				t.Errorf("Expected %q %q, got %+v (found %v)", tt.kind, tt.evidence, f, ok)
+			}
+		})
+	}
+
+	followUps, err := FindFollowUps(commits[1], commits[3])
+	if err != nil {
+		t.Fatalf("FindFollowUps failed: %v", err)
+	}
+	if len(followUps) != 1 || followUps[0].Hash != commits[3].Hash.String() ||
+		followUps[0].Kind != FollowUpRevert || followUps[0].Evidence != "inverse diff" {
+		t.Errorf("Expected the inverse commit as a revert, got %+v", followUps)
+	}
+	if followUps, err := FindFollowUps(commits[2], commits[3]); err != nil || len(followUps) != 0 {
+		t.Errorf("Expected no follow-ups for an unreverted commit, got %+v (%v)", followUps, err)
+	}
+
+	formatted := FormatFollowUps(followUps)
+	if want := commits[3].Hash.String()[:8] + " (Restore TTL) reverts this commit (inverse diff)\n"; formatted != want {
+		t.Errorf("Expected %q, got %q", want, formatted)
+	}
+}
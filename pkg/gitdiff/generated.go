@@ -0,0 +1,85 @@
+package gitdiff
+
+import (
+	"bytes"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+const (
+	// generatedHeaderSize is how much of a file is inspected for markers
+	generatedHeaderSize = 8192
+	// generatedHeaderLines is how many leading lines may hold a marker
+	generatedHeaderLines = 40
+	// minifiedLineLength is the line length above which a script or
+	// stylesheet is treated as minified
+	minifiedLineLength = 1000
+)
+
+// goGeneratedRe is the standard Go marker (https://go.dev/s/generatedcode)
+var goGeneratedRe = regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)
+
+// minifiedExts are file types that are commonly shipped minified
+var minifiedExts = map[string]bool{
+	".js": true, ".mjs": true, ".cjs": true, ".css": true,
+}
+
+// IsGeneratedContent reports whether a file looks machine-generated based
+// on head, the first bytes of its content: a "Code generated ... DO NOT
+// EDIT" header (as written by protoc, mockgen, mockery, stringer, etc.),
+// an @generated tag, or, for scripts and stylesheets, minified lines.
+func IsGeneratedContent(filePath string, head []byte) bool {
+	lines := bytes.SplitN(head, []byte("\n"), generatedHeaderLines+1)
+	for i, line := range lines {
+		if i == generatedHeaderLines {
+			break
+		}
+		text := strings.TrimRight(string(line), "\r")
+		if goGeneratedRe.MatchString(text) {
+			return true
+		}
+		lower := strings.ToLower(text)
+		if strings.Contains(lower, "@generated") {
+			return true
+		}
+		if (strings.Contains(lower, "generated") || strings.Contains(lower, "autogenerated")) &&
+			(strings.Contains(lower, "do not edit") || strings.Contains(lower, "do not modify")) {
+			return true
+		}
+	}
+
+	if minifiedExts[strings.ToLower(path.Ext(filePath))] {
+		for _, line := range bytes.Split(head, []byte("\n")) {
+			if len(line) >= minifiedLineLength {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// fileHead returns up to generatedHeaderSize bytes of path in tree, or nil
+// if the file cannot be read
+func fileHead(tree *object.Tree, filePath string) []byte {
+	if tree == nil {
+		return nil
+	}
+	f, err := tree.File(filePath)
+	if err != nil {
+		return nil
+	}
+	r, err := f.Reader()
+	if err != nil {
+		return nil
+	}
+	defer r.Close()
+	head, err := io.ReadAll(io.LimitReader(r, generatedHeaderSize))
+	if err != nil {
+		return nil
+	}
+	return head
+}
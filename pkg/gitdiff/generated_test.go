@@ -0,0 +1,65 @@
+package gitdiff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIsGeneratedContent(t *testing.T) {
+	longLine := strings.Repeat("var a=1;", 200)
+
+	tests := []struct {
+		name     string
+		path     string
+		content  string
+		expected bool
+	}{
+		{"protoc-gen-go", "api/service.pb.go", "// Code generated by protoc-gen-go. DO NOT EDIT.\n// source: service.proto\n\npackage api\n", true},
+		{"mockgen", "mocks/store.go", "// Code generated by MockGen. DO NOT EDIT.\n// Source: store.go\n\npackage mocks\n", true},
+		{"mockery after license", "mocks/client.go", "// Copyright 2024\n\n// Code generated by mockery v2.40.0. DO NOT EDIT.\n\npackage mocks\n", true},
+		{"CRLF header", "gen.go", "// Code generated by stringer. DO NOT EDIT.\r\n\r\npackage gen\r\n", true},
+		{"@generated tag", "schema.ts", "/**\n * @generated SignedSource<<abc>>\n */\nexport type A = {}\n", true},
+		{"python autogenerated", "pb2.py", "# -*- coding: utf-8 -*-\n# Generated by the protocol buffer compiler.  DO NOT EDIT!\n", true},
+		{"do not modify", "Client.java", "/*\n * Autogenerated file. Do not modify.\n */\n", true},
+		{"minified js", "static/app.min.js", longLine + "\n", true},
+		{"minified css", "static/site.css", strings.Repeat("a{b:c}", 200), true},
+
+		{"handwritten go", "main.go", "package main\n\nfunc main() {}\n", false},
+		{"mention in body", "main.go", "package main\n\n// DO NOT EDIT this constant\nconst x = 1\n", false},
+		{"long line in go", "data.go", "package data\n\nvar s = \"" + longLine + "\"\n", false},
+		{"normal js", "app.js", "function main() {\n  return 1;\n}\n", false},
+		{"empty", "empty.go", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := IsGeneratedContent(tt.path, []byte(tt.content))
+			if result != tt.expected {
+				t.Errorf("IsGeneratedContent(%q): expected %v, got %v", tt.path, tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestGetStandardDiffSkipsGeneratedContent(t *testing.T) {
+	c := commitFiles(t, map[string]string{
+		"mocks/store.go": "// Code generated by MockGen. DO NOT EDIT.\n\npackage mocks\n",
+		"main.go":        "package main\n",
+	})
+
+	_, files, err := GetStandardDiff(c, nil)
+	if err != nil {
+		t.Fatalf("GetStandardDiff failed: %v", err)
+	}
+	if len(files) != 1 || files[0] != "main.go" {
+		t.Errorf("Expected [main.go], got %v", files)
+	}
+
+	_, files, err = GetStandardDiffWithOptions(c, nil, Options{KeepGenerated: true})
+	if err != nil {
+		t.Fatalf("GetStandardDiffWithOptions failed: %v", err)
+	}
+	if len(files) != 2 {
+		t.Errorf("Expected 2 files with KeepGenerated, got %v", files)
+	}
+}
@@ -0,0 +1,302 @@
+package gitdiff
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// wholeFileContext is the -U value that makes git emit every line of a
+// changed file as context
+const wholeFileContext = "-U2147483647"
+
+// GitCLIProvider computes patches by running the system git binary, which
+// is much faster than go-git on very large repositories and shares git's
+// handling of renames, binary files, and submodules
+type GitCLIProvider struct {
+	gitPath   string
+	repoDir   string
+	emptyTree string // hash of the empty tree, for root commits
+}
+
+// NewGitCLIProvider returns a provider running git in repoDir. It fails if
+// git is not on PATH or repoDir is not a git repository.
+func NewGitCLIProvider(repoDir string) (*GitCLIProvider, error) {
+	gitPath, err := exec.LookPath("git")
+	if err != nil {
+		return nil, fmt.Errorf("git binary not found: %w", err)
+	}
+	p := &GitCLIProvider{gitPath: gitPath, repoDir: repoDir}
+	if _, err := p.run("rev-parse", "--git-dir"); err != nil {
+		return nil, fmt.Errorf("%s is not a git repository: %w", repoDir, err)
+	}
+	// Hashing an empty tree (without writing it) works for both SHA-1
+	// and SHA-256 repositories
+	out, err := p.run("hash-object", "-t", "tree", "--stdin")
+	if err != nil {
+		return nil, err
+	}
+	p.emptyTree = strings.TrimSpace(string(out))
+	return p, nil
+}
+
+// run executes git with args and returns its standard output
+func (p *GitCLIProvider) run(args ...string) ([]byte, error) {
+	cmd := exec.Command(p.gitPath, append([]string{"-c", "core.quotepath=off"}, args...)...)
+	cmd.Dir = p.repoDir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return out, nil
+}
+
+// FilePatches implements DiffProvider
+func (p *GitCLIProvider) FilePatches(from, to *object.Commit, detectRenames bool) ([]diff.FilePatch, error) {
+	args := []string{"diff-tree", "-r", "-p", "--no-color", "--no-ext-diff", "--no-textconv",
+		"--full-index", "--src-prefix=a/", "--dst-prefix=b/", wholeFileContext}
+	if detectRenames {
+		args = append(args, "-M")
+	} else {
+		args = append(args, "--no-renames")
+	}
+	fromRev := p.emptyTree
+	if from != nil {
+		fromRev = from.Hash.String()
+	}
+	args = append(args, fromRev, to.Hash.String())
+	out, err := p.run(args...)
+	if err != nil {
+		return nil, err
+	}
+	return parseGitPatch(out)
+}
+
+// gitFile is a diff.File parsed from git output
+type gitFile struct {
+	hash plumbing.Hash
+	mode filemode.FileMode
+	path string
+}
+
+func (f *gitFile) Hash() plumbing.Hash     { return f.hash }
+func (f *gitFile) Mode() filemode.FileMode { return f.mode }
+func (f *gitFile) Path() string            { return f.path }
+
+// gitFilePatch is a diff.FilePatch parsed from git output
+type gitFilePatch struct {
+	from, to *gitFile // nil for added and deleted files
+	binary   bool
+	chunks   []diff.Chunk
+}
+
+func (fp *gitFilePatch) IsBinary() bool       { return fp.binary }
+func (fp *gitFilePatch) Chunks() []diff.Chunk { return fp.chunks }
+
+// Files implements diff.FilePatch, returning untyped nils for missing
+// sides so that callers can compare them with nil
+func (fp *gitFilePatch) Files() (diff.File, diff.File) {
+	var from, to diff.File
+	if fp.from != nil {
+		from = fp.from
+	}
+	if fp.to != nil {
+		to = fp.to
+	}
+	return from, to
+}
+
+// parseGitPatch parses the output of git diff-tree -p with full-index
+// hashes into file patches
+func parseGitPatch(out []byte) ([]diff.FilePatch, error) {
+	var patches []diff.FilePatch
+	var fp *gitFilePatch
+	var chunk *strings.Builder
+	var chunkOp diff.Operation
+
+	flushChunk := func() {
+		if chunk != nil && chunk.Len() > 0 {
+			fp.chunks = append(fp.chunks, &textChunk{content: chunk.String(), op: chunkOp})
+		}
+		chunk = nil
+	}
+	flushFile := func() {
+		if fp != nil {
+			flushChunk()
+			patches = append(patches, fp)
+		}
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<30)
+	inHunk := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "diff --git ") {
+			flushFile()
+			from, to := parseDiffGitPaths(strings.TrimPrefix(line, "diff --git "))
+			fp = &gitFilePatch{from: &gitFile{path: from}, to: &gitFile{path: to}}
+			inHunk = false
+			continue
+		}
+		if fp == nil {
+			continue
+		}
+
+		if inHunk {
+			var op diff.Operation
+			switch {
+			case strings.HasPrefix(line, " "):
+				op = diff.Equal
+			case strings.HasPrefix(line, "+"):
+				op = diff.Add
+			case strings.HasPrefix(line, "-"):
+				op = diff.Delete
+			case strings.HasPrefix(line, `\`):
+				// "\ No newline at end of file" applies to the previous line
+				if chunk != nil {
+					content := strings.TrimSuffix(chunk.String(), "\n")
+					chunk.Reset()
+					chunk.WriteString(content)
+				}
+				continue
+			case strings.HasPrefix(line, "@@"):
+				continue
+			default:
+				inHunk = false
+			}
+			if inHunk {
+				if chunk == nil || op != chunkOp {
+					flushChunk()
+					chunk = &strings.Builder{}
+					chunkOp = op
+				}
+				chunk.WriteString(line[1:])
+				chunk.WriteByte('\n')
+				continue
+			}
+		}
+
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			inHunk = true
+		case strings.HasPrefix(line, "new file mode "):
+			fp.from = nil
+			fp.to.mode = parseMode(strings.TrimPrefix(line, "new file mode "))
+		case strings.HasPrefix(line, "deleted file mode "):
+			fp.from.mode = parseMode(strings.TrimPrefix(line, "deleted file mode "))
+			fp.to = nil
+		case strings.HasPrefix(line, "old mode "):
+			fp.from.mode = parseMode(strings.TrimPrefix(line, "old mode "))
+		case strings.HasPrefix(line, "new mode "):
+			fp.to.mode = parseMode(strings.TrimPrefix(line, "new mode "))
+		case strings.HasPrefix(line, "rename from "):
+			fp.from.path = unquotePath(strings.TrimPrefix(line, "rename from "))
+		case strings.HasPrefix(line, "rename to "):
+			fp.to.path = unquotePath(strings.TrimPrefix(line, "rename to "))
+		case strings.HasPrefix(line, "index "):
+			parseIndexLine(fp, strings.TrimPrefix(line, "index "))
+		case strings.HasPrefix(line, "Binary files "):
+			fp.binary = true
+		case strings.HasPrefix(line, "--- a/") && fp.from != nil:
+			fp.from.path = unquotePath(strings.TrimSuffix(strings.TrimPrefix(line, "--- a/"), "\t"))
+		case strings.HasPrefix(line, "+++ b/") && fp.to != nil:
+			fp.to.path = unquotePath(strings.TrimSuffix(strings.TrimPrefix(line, "+++ b/"), "\t"))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read git diff output: %w", err)
+	}
+	flushFile()
+	return patches, nil
+}
+
+// parseDiffGitPaths splits the "a/<old> b/<new>" of a diff --git header.
+// Unquoted paths may contain spaces, so when both sides are the same path
+// the split is found by length.
+func parseDiffGitPaths(s string) (string, string) {
+	if strings.HasPrefix(s, `"`) {
+		if end := closingQuote(s); end > 0 {
+			from := unquotePath(s[:end+1])
+			to := unquotePath(strings.TrimPrefix(s[end+1:], " "))
+			return strings.TrimPrefix(from, "a/"), strings.TrimPrefix(to, "b/")
+		}
+	}
+	if n := len(s); n >= 5 && (n-1)%2 == 0 {
+		half := (n - 1) / 2
+		if from, to := s[:half], s[half+1:]; strings.HasPrefix(from, "a/") && strings.HasPrefix(to, "b/") && from[2:] == to[2:] {
+			return from[2:], to[2:]
+		}
+	}
+	if i := strings.Index(s, " b/"); i >= 0 {
+		return strings.TrimPrefix(s[:i], "a/"), unquotePath(s[i+3:])
+	}
+	return s, s
+}
+
+// closingQuote returns the index of the quote ending the C-style quoted
+// string at the start of s, or -1
+func closingQuote(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// unquotePath decodes a path git quoted because of special characters
+func unquotePath(p string) string {
+	if len(p) >= 2 && p[0] == '"' && p[len(p)-1] == '"' {
+		if s, err := strconv.Unquote(p); err == nil {
+			return s
+		}
+	}
+	return p
+}
+
+// parseIndexLine reads "<old>..<new>[ <mode>]" into a file patch
+func parseIndexLine(fp *gitFilePatch, s string) {
+	hashes, mode, hasMode := strings.Cut(s, " ")
+	oldHash, newHash, ok := strings.Cut(hashes, "..")
+	if !ok {
+		return
+	}
+	if fp.from != nil {
+		fp.from.hash = plumbing.NewHash(oldHash)
+		if hasMode {
+			fp.from.mode = parseMode(mode)
+		}
+	}
+	if fp.to != nil {
+		fp.to.hash = plumbing.NewHash(newHash)
+		if hasMode {
+			fp.to.mode = parseMode(mode)
+		}
+	}
+}
+
+// parseMode parses an octal git file mode
+func parseMode(s string) filemode.FileMode {
+	m, err := filemode.New(strings.TrimSpace(s))
+	if err != nil {
+		return filemode.Empty
+	}
+	return m
+}
@@ -0,0 +1,293 @@
+package gitdiff
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+const samplePatch = `diff --git a/main.go b/main.go
+index 1111111111111111111111111111111111111111..2222222222222222222222222222222222222222 100644
+--- a/main.go
++++ b/main.go
+@@ -1,3 +1,3 @@
+ package main
+-func a() {}
++func b() {}
+ // end
+\ No newline at end of file
+diff --git a/old name.txt b/new name.txt
+similarity index 90%
+rename from old name.txt
+rename to new name.txt
+index 3333333333333333333333333333333333333333..4444444444444444444444444444444444444444 100644
+--- a/old name.txt
++++ b/new name.txt
+@@ -1 +1 @@
+-x
++y
+diff --git a/logo.png b/logo.png
+deleted file mode 100644
+index 5555555555555555555555555555555555555555..0000000000000000000000000000000000000000
+Binary files a/logo.png and /dev/null differ
+diff --git a/run.sh b/run.sh
+new file mode 100755
+index 0000000000000000000000000000000000000000..6666666666666666666666666666666666666666
+--- /dev/null
++++ b/run.sh
+@@ -0,0 +1 @@
++echo hi
+`
+
+func TestParseGitPatch(t *testing.T) {
+	patches, err := parseGitPatch([]byte(samplePatch))
+	if err != nil {
+		t.Fatalf("parseGitPatch failed: %v", err)
+	}
+	if len(patches) != 4 {
+		t.Fatalf("Expected 4 patches, got %d", len(patches))
+	}
+
+	// Modified file, with the missing final newline preserved
+	from, to := patches[0].Files()
+	if from.Path() != "main.go" || to.Path() != "main.go" || to.Hash().String() != "2222222222222222222222222222222222222222" || to.Mode() != filemode.Regular {
+		t.Errorf("Unexpected files for main.go: %v -> %v", from, to)
+	}
+	chunks := patches[0].Chunks()
+	expected := []struct {
+		op      diff.Operation
+		content string
+	}{
+		{diff.Equal, "package main\n"},
+		{diff.Delete, "func a() {}\n"},
+		{diff.Add, "func b() {}\n"},
+		{diff.Equal, "// end"},
+	}
+	if len(chunks) != len(expected) {
+		t.Fatalf("Expected %d chunks, got %d", len(expected), len(chunks))
+	}
+	for i, want := range expected {
+		if chunks[i].Type() != want.op || chunks[i].Content() != want.content {
+			t.Errorf("Chunk %d: expected %v %q, got %v %q", i, want.op, want.content, chunks[i].Type(), chunks[i].Content())
+		}
+	}
+
+	// Rename between paths with spaces
+	from, to = patches[1].Files()
+	if from.Path() != "old name.txt" || to.Path() != "new name.txt" {
+		t.Errorf("Expected rename from %q to %q, got %q to %q", "old name.txt", "new name.txt", from.Path(), to.Path())
+	}
+
+	// Deleted binary file
+	from, to = patches[2].Files()
+	if !patches[2].IsBinary() || from == nil || from.Path() != "logo.png" || to != nil {
+		t.Errorf("Expected deleted binary logo.png, got binary=%v from=%v to=%v", patches[2].IsBinary(), from, to)
+	}
+
+	// Added executable
+	from, to = patches[3].Files()
+	if from != nil || to.Path() != "run.sh" || to.Mode() != filemode.Executable {
+		t.Errorf("Expected added executable run.sh, got from=%v to=%v", from, to)
+	}
+	if got := patchPath(patches[3]); got != "run.sh" {
+		t.Errorf("Expected patch path run.sh, got %q", got)
+	}
+}
+
+func TestParseDiffGitPaths(t *testing.T) {
+	tests := []struct {
+		header   string
+		from, to string
+	}{
+		{"a/main.go b/main.go", "main.go", "main.go"},
+		{"a/dir b/x b/dir b/x", "dir b/x", "dir b/x"},
+		{"a/old.go b/new.go", "old.go", "new.go"},
+		{`"a/tab\there" "b/tab\there"`, "tab\there", "tab\there"},
+	}
+	for _, tt := range tests {
+		from, to := parseDiffGitPaths(tt.header)
+		if from != tt.from || to != tt.to {
+			t.Errorf("parseDiffGitPaths(%q): expected %q %q, got %q %q", tt.header, tt.from, tt.to, from, to)
+		}
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+	p, err := NewProvider("", t.TempDir())
+	if err != nil {
+		t.Fatalf("NewProvider failed: %v", err)
+	}
+	if _, ok := p.(GoGitProvider); !ok {
+		t.Errorf("Expected go-git provider by default, got %T", p)
+	}
+	if _, err := NewProvider("svn", t.TempDir()); err == nil {
+		t.Error("Expected error for unknown backend")
+	}
+}
+
+func TestGitCLIProviderMatchesGoGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	r, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatalf("Failed to init repo: %v", err)
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		t.Fatalf("Failed to get worktree: %v", err)
+	}
+	commit := func(files map[string]string) *object.Commit {
+		for name, content := range files {
+			if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755); err != nil {
+				t.Fatalf("Failed to create directory: %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+				t.Fatalf("Failed to write %s: %v", name, err)
+			}
+			if _, err := w.Add(name); err != nil {
+				t.Fatalf("Failed to add %s: %v", name, err)
+			}
+		}
+		hash, err := w.Commit("commit", &git.CommitOptions{
+			Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+		})
+		if err != nil {
+			t.Fatalf("Failed to commit: %v", err)
+		}
+		c, err := r.CommitObject(hash)
+		if err != nil {
+			t.Fatalf("Failed to get commit: %v", err)
+		}
+		return c
+	}
+
+	first := commit(map[string]string{"main.go": "package main\n\nfunc main() {\n\trun()\n}\n", "pkg/util.go": "package pkg\n"})
+	second := commit(map[string]string{"main.go": "package main\n\nfunc main() {\n\trun(1)\n}\n", "pkg/new.go": "package pkg\n\nvar x = 1"})
+
+	cli, err := NewGitCLIProvider(dir)
+	if err != nil {
+		t.Fatalf("NewGitCLIProvider failed: %v", err)
+	}
+
+	for _, parent := range []*object.Commit{first, nil} {
+		want, wantFiles, err := GetStandardDiffWithOptions(second, parent, Options{})
+		if err != nil {
+			t.Fatalf("GetStandardDiffWithOptions failed: %v", err)
+		}
+		got, gotFiles, err := GetStandardDiffWithOptions(second, parent, Options{Provider: cli})
+		if err != nil {
+			t.Fatalf("GetStandardDiffWithOptions with git failed: %v", err)
+		}
+		if got != want {
+			t.Errorf("Expected git backend to match go-git.\ngo-git:\n%s\ngit:\n%s", want, got)
+		}
+		if len(gotFiles) != len(wantFiles) {
+			t.Errorf("Expected files %v, got %v", wantFiles, gotFiles)
+		}
+	}
+
+	wantStats, err := Stats(second, first)
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	gotStats, err := StatsWithOptions(second, first, Options{Provider: cli})
+	if err != nil {
+		t.Fatalf("StatsWithOptions failed: %v", err)
+	}
+	if gotStats.Insertions != wantStats.Insertions || gotStats.Deletions != wantStats.Deletions {
+		t.Errorf("Expected stats +%d -%d, got +%d -%d", wantStats.Insertions, wantStats.Deletions, gotStats.Insertions, gotStats.Deletions)
+	}
+
+	if _, err := NewGitCLIProvider(t.TempDir()); err == nil {
+		t.Error("Expected error for a directory that is not a repository")
+	}
+}
+
+// patchProvider is a DiffProvider returning the parsed patch text for any
+// pair of commits
+type patchProvider string
+
+func (p patchProvider) FilePatches(from, to *object.Commit, detectRenames bool) ([]diff.FilePatch, error) {
+	return parseGitPatch([]byte(p))
+}
+
+func TestTypeChangeListedOnce(t *testing.T) {
+	// git diffs a regular file replaced by a symlink as a deletion and an
+	// addition of the same path
+	const typeChange = `diff --git a/run b/run
+deleted file mode 100644
+index 1111111111111111111111111111111111111111..0000000000000000000000000000000000000000
+--- a/run
++++ /dev/null
+@@ -1 +0,0 @@
+-echo hi
+diff --git a/run b/run
+new file mode 120000
+index 0000000000000000000000000000000000000000..2222222222222222222222222222222222222222
+--- /dev/null
++++ b/run
+@@ -0,0 +1 @@
++bin/run
+\ No newline at end of file
+diff --git a/main.go b/main.go
+index 3333333333333333333333333333333333333333..4444444444444444444444444444444444444444 100644
+--- a/main.go
++++ b/main.go
+@@ -1 +1 @@
+-package a
++package main
+`
+	c := commitFiles(t, map[string]string{"main.go": "package main\n"})
+	opts := Options{Provider: patchProvider(typeChange)}
+
+	// Split one file per chunk, the path is still in only one
+	chunks, err := GetStandardDiffChunks(c, c, Options{Provider: opts.Provider, MaxChunks: 3, MaxTokens: 1})
+	if err != nil {
+		t.Fatalf("GetStandardDiffChunks failed: %v", err)
+	}
+	var files []string
+	for _, chunk := range chunks {
+		files = append(files, chunk.Files...)
+	}
+	if len(chunks) != 2 || len(files) != 2 || files[0] != "run" || files[1] != "main.go" {
+		t.Fatalf("Expected run and main.go in a chunk each, got %v", files)
+	}
+
+	std, stdFiles, err := GetStandardDiffWithOptions(c, c, opts)
+	if err != nil {
+		t.Fatalf("GetStandardDiffWithOptions failed: %v", err)
+	}
+	if len(stdFiles) != 2 || strings.Count(std, "--- run") != 2 {
+		t.Errorf("Expected run listed once with both of its patches, got %v:\n%s", stdFiles, std)
+	}
+	if i, j := strings.Index(std, "-echo hi\n"), strings.Index(std, "--- main.go"); i == -1 || i > j || !strings.Contains(std[:j], "+bin/run") {
+		t.Errorf("Expected both sides of the type change before main.go, got:\n%s", std)
+	}
+
+	full, err := GetFullDiffWithOptions(c, c, files, opts)
+	if err != nil {
+		t.Fatalf("GetFullDiffWithOptions failed: %v", err)
+	}
+	if n := strings.Count(full, "--- run (Evolution to HEAD"); n != 1 {
+		t.Errorf("Expected one evolution section for run, got %d:\n%s", n, full)
+	}
+
+	stats, err := StatsWithOptions(c, c, opts)
+	if err != nil {
+		t.Fatalf("StatsWithOptions failed: %v", err)
+	}
+	if len(stats.Files) != 2 || stats.Files[0].Path != "run" || !stats.Files[0].ModeChanged ||
+		stats.Files[0].Insertions != 1 || stats.Files[0].Deletions != 1 {
+		t.Errorf("Expected run counted once as a mode change, got %+v", stats.Files)
+	}
+}
@@ -0,0 +1,147 @@
+package gitdiff
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// DefaultHotspotHistory is how many commits back from HEAD churn and
+// bug-fix counts are gathered over
+const DefaultHotspotHistory = 500
+
+// maxHotspotFiles bounds the files listed in a Hotspot
+const maxHotspotFiles = 3
+
+// Weights of bug-fix density and churn in a file's fragility
+const (
+	fixWeight   = 0.7
+	churnWeight = 0.3
+)
+
+// bugFixRe matches commit messages that say they fix a bug
+var bugFixRe = regexp.MustCompile(`(?i)\b(fix(e[sd])?|bug(s|fix)?|hotfix|regression)\b`)
+
+// FileChurn is how often a file changed in recent history, and how often
+// in a commit fixing a bug
+type FileChurn struct {
+	Path    string `json:"path"`
+	Commits int    `json:"commits"`
+	Fixes   int    `json:"fixes"`
+}
+
+// Hotspot is the prior that a commit is at fault because it touches
+// historically fragile files
+type Hotspot struct {
+	// Score is the fragility of the commit's most fragile file, from 0 to
+	// 1 relative to the most fragile file in the repository
+	Score float64 `json:"score"`
+
+	// Files are the commit's most fragile files, most fragile first
+	Files []FileChurn `json:"files"`
+}
+
+// Hotspots holds the churn and bug-fix counts of every file changed in
+// recent history. It is read-only once loaded and safe for concurrent use.
+type Hotspots struct {
+	files      map[string]*FileChurn
+	maxCommits int
+	maxFixes   int
+}
+
+// LoadHotspots walks up to limit commits of head's first-parent history
+// and counts, for each file, the commits changing it and those among them
+// whose message says they fix a bug (fix, bug, hotfix, regression). Merge
+// commits are not counted. In a shallow clone the walk stops at the oldest
+// fetched commit.
+func LoadHotspots(head *object.Commit, limit int) (*Hotspots, error) {
+	h := &Hotspots{files: map[string]*FileChurn{}}
+	err := walkHistory(head, limit, func(c *object.Commit, paths []string) {
+		fix := bugFixRe.MatchString(c.Message)
+		for _, p := range paths {
+			fc := h.files[p]
+			if fc == nil {
+				fc = &FileChurn{Path: p}
+				h.files[p] = fc
+			}
+			fc.Commits++
+			if fix {
+				fc.Fixes++
+			}
+			h.maxCommits = max(h.maxCommits, fc.Commits)
+			h.maxFixes = max(h.maxFixes, fc.Fixes)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// walkHistory calls fn with each of up to limit commits of head's
+// first-parent history and the paths it changes, skipping merge commits
+// and stopping at a shallow clone's oldest fetched commit
+func walkHistory(head *object.Commit, limit int, fn func(c *object.Commit, paths []string)) error {
+	current := head
+	for i := 0; i < limit && current != nil; i++ {
+		var parent *object.Commit
+		if len(current.ParentHashes) > 0 {
+			var err error
+			if parent, err = current.Parent(0); errors.Is(err, plumbing.ErrObjectNotFound) {
+				break // shallow clone boundary
+			} else if err != nil {
+				return fmt.Errorf("walking history: %w", err)
+			}
+		}
+		if len(current.ParentHashes) <= 1 {
+			paths, err := ChangedPaths(current, parent)
+			if err != nil {
+				return err
+			}
+			fn(current, paths)
+		}
+		current = parent
+	}
+	return nil
+}
+
+// fragility scores a file from its bug-fix and commit counts relative to
+// the repository's most fixed and most changed files
+func (h *Hotspots) fragility(fc *FileChurn) float64 {
+	score := 0.0
+	if h.maxFixes > 0 {
+		score += fixWeight * float64(fc.Fixes) / float64(h.maxFixes)
+	}
+	if h.maxCommits > 0 {
+		score += churnWeight * float64(fc.Commits) / float64(h.maxCommits)
+	}
+	return score
+}
+
+// For returns the hotspot prior of a commit changing paths, or nil if
+// none of them changed in the scanned history. A nil Hotspots gives nil.
+func (h *Hotspots) For(paths []string) *Hotspot {
+	if h == nil {
+		return nil
+	}
+	var files []FileChurn
+	for _, p := range paths {
+		if fc := h.files[p]; fc != nil {
+			files = append(files, *fc)
+		}
+	}
+	if len(files) == 0 {
+		return nil
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return h.fragility(&files[i]) > h.fragility(&files[j])
+	})
+	if len(files) > maxHotspotFiles {
+		files = files[:maxHotspotFiles]
+	}
+	return &Hotspot{Score: h.fragility(&files[0]), Files: files}
+}
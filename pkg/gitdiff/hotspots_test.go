@@ -0,0 +1,78 @@
+package gitdiff
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestLoadHotspots(t *testing.T) {
+	commits := commitHistory(t,
+		map[string]string{"auth.go": "a", "cache.go": "a", "README.md": "a"},
+		map[string]string{"auth.go": "b", commitMessage: "Fix token refresh"},
+		map[string]string{"auth.go": "c", "cache.go": "b", commitMessage: "Bugfix: expire sessions"},
+		map[string]string{"cache.go": "c", commitMessage: "Tune cache size"},
+		map[string]string{"README.md": "b", commitMessage: "Document prefixes"},
+	)
+	head := commits[len(commits)-1]
+
+	h, err := LoadHotspots(head, DefaultHotspotHistory)
+	if err != nil {
+		t.Fatalf("LoadHotspots failed: %v", err)
+	}
+
+	hs := h.For([]string{"cache.go", "auth.go", "new.go"})
+	if hs == nil {
+		t.Fatal("Expected a hotspot for historically changed files")
+	}
+	expected := []FileChurn{
+		{Path: "auth.go", Commits: 3, Fixes: 2},
+		{Path: "cache.go", Commits: 3, Fixes: 1},
+	}
+	if !reflect.DeepEqual(hs.Files, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, hs.Files)
+	}
+	if math.Abs(hs.Score-1) > 1e-9 {
+		t.Errorf("Expected the most fragile file to score 1, got %f", hs.Score)
+	}
+
+	if readme := h.For([]string{"README.md"}); readme == nil || readme.Score >= hs.Score {
+		t.Errorf("Expected README.md to score below auth.go, got %+v", readme)
+	}
+	if got := h.For([]string{"new.go"}); got != nil {
+		t.Errorf("Expected no hotspot for files without history, got %+v", got)
+	}
+
+	// Only the last two commits are scanned
+	h, err = LoadHotspots(head, 2)
+	if err != nil {
+		t.Fatalf("LoadHotspots failed: %v", err)
+	}
+	if got := h.For([]string{"auth.go"}); got != nil {
+		t.Errorf("Expected auth.go outside the scanned history, got %+v", got)
+	}
+
+	var none *Hotspots
+	if got := none.For([]string{"auth.go"}); got != nil {
+		t.Errorf("Expected nil Hotspots to give nil, got %+v", got)
+	}
+}
+
+func TestBugFixMessages(t *testing.T) {
+	tests := []struct {
+		message string
+		fix     bool
+	}{
+		{"Fix token refresh", true},
+		{"fixes #12", true},
+		{"Hotfix for login", true},
+		{"Revert regression in parser", true},
+		{"Add prefix option", false},
+		{"Debug logging for fixtures", false},
+	}
+	for _, tt := range tests {
+		if got := bugFixRe.MatchString(tt.message); got != tt.fix {
+			t.Errorf("bugFixRe(%q): expected %v, got %v", tt.message, tt.fix, got)
+		}
+	}
+}
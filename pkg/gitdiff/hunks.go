@@ -0,0 +1,127 @@
+package gitdiff
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/format/diff"
+)
+
+// diffLine is one line of a file patch
+type diffLine struct {
+	op   byte // ' ', '+', or '-'
+	text string
+	note string // annotation written before the text, such as a blame hash
+}
+
+// writeTo writes the line with its op and note
+func (l diffLine) writeTo(sb *strings.Builder) {
+	sb.WriteByte(l.op)
+	if l.note != "" {
+		sb.WriteString(l.note)
+		sb.WriteString(" | ")
+	}
+	sb.WriteString(l.text)
+	sb.WriteByte('\n')
+}
+
+// patchLines flattens chunks into lines, keeping blank lines so that line
+// numbers stay accurate
+func patchLines(chunks []diff.Chunk) []diffLine {
+	var lines []diffLine
+	for _, chunk := range chunks {
+		content := chunk.Content()
+		if content == "" {
+			continue
+		}
+		op := byte(' ')
+		switch chunk.Type() {
+		case diff.Add:
+			op = '+'
+		case diff.Delete:
+			op = '-'
+		}
+		content = strings.TrimSuffix(content, "\n")
+		for _, text := range strings.Split(content, "\n") {
+			lines = append(lines, diffLine{op: op, text: text})
+		}
+	}
+	return lines
+}
+
+// isFuncLine reports whether line starts a function or other top-level
+// declaration, using git's default funcname heuristic: the line begins
+// with a letter, "_", or "$"
+func isFuncLine(line string) bool {
+	if line == "" {
+		return false
+	}
+	c := line[0]
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == '$'
+}
+
+// writeHunks writes the changed lines of a file with contextLines of
+// unchanged lines around each change. With functionContext, each change
+// is also expanded to cover its enclosing function, like git diff -W.
+// Each hunk starts with an "@@ -old +new @@" header giving the line
+// numbers of its first line.
+func writeHunks(sb *strings.Builder, lines []diffLine, contextLines int, functionContext bool) {
+	n := len(lines)
+	visible := make([]bool, n)
+
+	// For function context, precompute the nearest declaration at or
+	// before each line and the first one after it
+	var prevFunc, nextFunc []int
+	if functionContext {
+		prevFunc = make([]int, n)
+		last := 0
+		for i, l := range lines {
+			if isFuncLine(l.text) {
+				last = i
+			}
+			prevFunc[i] = last
+		}
+		nextFunc = make([]int, n)
+		next := n
+		for i := n - 1; i >= 0; i-- {
+			nextFunc[i] = next
+			if isFuncLine(lines[i].text) {
+				next = i
+			}
+		}
+	}
+
+	for i, l := range lines {
+		if l.op == ' ' {
+			continue
+		}
+		lo, hi := i-contextLines, i+contextLines
+		if functionContext {
+			lo = min(lo, prevFunc[i])
+			hi = max(hi, nextFunc[i]-1)
+		}
+		for j := max(lo, 0); j <= min(hi, n-1); j++ {
+			visible[j] = true
+		}
+	}
+
+	oldLine, newLine := 1, 1
+	inHunk := false
+	for i, l := range lines {
+		if visible[i] {
+			if !inHunk {
+				sb.WriteString(fmt.Sprintf("@@ -%d +%d @@\n", oldLine, newLine))
+				inHunk = true
+			}
+			l.writeTo(sb)
+		} else {
+			inHunk = false
+		}
+		if l.op != '+' {
+			oldLine++
+		}
+		if l.op != '-' {
+			newLine++
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package gitdiff
+
+import (
+	"strings"
+	"testing"
+)
+
+// parseLines builds diffLines from a compact "op text" listing. Empty
+// lines are blank context lines.
+func parseLines(listing string) []diffLine {
+	var lines []diffLine
+	for _, l := range strings.Split(strings.TrimSuffix(listing, "\n"), "\n") {
+		if l == "" {
+			lines = append(lines, diffLine{op: ' '})
+			continue
+		}
+		lines = append(lines, diffLine{op: l[0], text: l[1:]})
+	}
+	return lines
+}
+
+const goSource = ` package main
+
+ func a() {
+ 	x := 1
+ 	y := 2
+-	return x
++	return x + y
+ }
+
+ func b() {
+ 	return
+ }
+`
+
+func TestWriteHunksContextLines(t *testing.T) {
+	var sb strings.Builder
+	writeHunks(&sb, parseLines(goSource), 1, false)
+
+	expected := `@@ -5 +5 @@
+ 	y := 2
+-	return x
++	return x + y
+ }
+`
+	if sb.String() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, sb.String())
+	}
+}
+
+func TestWriteHunksFunctionContext(t *testing.T) {
+	var sb strings.Builder
+	writeHunks(&sb, parseLines(goSource), 0, true)
+
+	expected := "@@ -3 +3 @@\n" +
+		" func a() {\n" +
+		" \tx := 1\n" +
+		" \ty := 2\n" +
+		"-\treturn x\n" +
+		"+\treturn x + y\n" +
+		" }\n" +
+		" \n"
+	if sb.String() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, sb.String())
+	}
+}
+
+func TestWriteHunksSeparateHunks(t *testing.T) {
+	lines := parseLines(` one
+-two
++TWO
+ three
+ four
+ five
+ six
++seven
+`)
+	var sb strings.Builder
+	writeHunks(&sb, lines, 1, false)
+
+	expected := `@@ -1 +1 @@
+ one
+-two
++TWO
+ three
+@@ -6 +6 @@
+ six
++seven
+`
+	if sb.String() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, sb.String())
+	}
+}
+
+func TestIsFuncLine(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected bool
+	}{
+		{"func main() {", true},
+		{"def handler(request):", true},
+		{"class Foo:", true},
+		{"_private = 1", true},
+		{"$var = 1", true},
+		{"\treturn", false},
+		{"    return", false},
+		{"}", false},
+		{"// comment", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := isFuncLine(tt.line); got != tt.expected {
+			t.Errorf("isFuncLine(%q): expected %v, got %v", tt.line, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package gitdiff
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// modeString formats a file mode like git, e.g. "100755"
+func modeString(m filemode.FileMode) string {
+	return fmt.Sprintf("%06o", uint32(m))
+}
+
+// isSymlink reports whether f is a symbolic link
+func isSymlink(f diff.File) bool {
+	return f != nil && f.Mode() == filemode.Symlink
+}
+
+// modeChanged reports whether a patch changes the mode of a file that
+// exists on both sides, such as setting or clearing the executable bit
+func modeChanged(fp diff.FilePatch) bool {
+	from, to := fp.Files()
+	if from == nil || to == nil {
+		return false
+	}
+	// Deprecated group-writable mode is the same as regular to git
+	normalize := func(m filemode.FileMode) filemode.FileMode {
+		if m == filemode.Deprecated {
+			return filemode.Regular
+		}
+		return m
+	}
+	return normalize(from.Mode()) != normalize(to.Mode())
+}
+
+// writeFileHeader writes the "--- path" line of a file section followed,
+// like git, by lines describing mode changes and non-regular new or
+// deleted files. Symbolic links are marked in the header, since their
+// content is the link target rather than code. note is added to the
+// parenthesized header annotation, if any.
+func writeFileHeader(sb *strings.Builder, path string, fp diff.FilePatch, note string) {
+	from, to := fp.Files()
+	var notes []string
+	if note != "" {
+		notes = append(notes, note)
+	}
+	if isSymlink(from) || isSymlink(to) {
+		notes = append(notes, "symlink target")
+	}
+	if len(notes) > 0 {
+		sb.WriteString(fmt.Sprintf("--- %s (%s)\n", path, strings.Join(notes, "; ")))
+	} else {
+		sb.WriteString(fmt.Sprintf("--- %s\n", path))
+	}
+
+	switch {
+	case modeChanged(fp):
+		sb.WriteString(fmt.Sprintf("old mode %s\nnew mode %s\n", modeString(from.Mode()), modeString(to.Mode())))
+	case from == nil && to != nil && !to.Mode().IsRegular():
+		sb.WriteString(fmt.Sprintf("new file mode %s\n", modeString(to.Mode())))
+	case to == nil && from != nil && !from.Mode().IsRegular():
+		sb.WriteString(fmt.Sprintf("deleted file mode %s\n", modeString(from.Mode())))
+	}
+}
+
+// fileMode returns the mode of p in tree, or filemode.Empty if it is
+// missing
+func fileMode(tree *object.Tree, p string) filemode.FileMode {
+	if tree == nil {
+		return filemode.Empty
+	}
+	f, err := tree.File(p)
+	if err != nil {
+		return filemode.Empty
+	}
+	return f.Mode
+}
@@ -0,0 +1,90 @@
+package gitdiff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+)
+
+func TestWriteFileHeader(t *testing.T) {
+	file := func(mode filemode.FileMode) *gitFile {
+		return &gitFile{path: "run.sh", mode: mode}
+	}
+	tests := []struct {
+		name     string
+		fp       *gitFilePatch
+		note     string
+		expected string
+	}{
+		{"unchanged mode", &gitFilePatch{from: file(filemode.Regular), to: file(filemode.Regular)}, "",
+			"--- run.sh\n"},
+		{"deprecated mode", &gitFilePatch{from: file(filemode.Deprecated), to: file(filemode.Regular)}, "",
+			"--- run.sh\n"},
+		{"exec bit lost", &gitFilePatch{from: file(filemode.Executable), to: file(filemode.Regular)}, "",
+			"--- run.sh\nold mode 100755\nnew mode 100644\n"},
+		{"new symlink", &gitFilePatch{to: file(filemode.Symlink)}, "",
+			"--- run.sh (symlink target)\nnew file mode 120000\n"},
+		{"deleted executable", &gitFilePatch{from: file(filemode.Executable)}, "",
+			"--- run.sh\ndeleted file mode 100755\n"},
+		{"new regular file", &gitFilePatch{to: file(filemode.Regular)}, "",
+			"--- run.sh\n"},
+		{"file to symlink", &gitFilePatch{from: file(filemode.Regular), to: file(filemode.Symlink)}, "Evolution to HEAD",
+			"--- run.sh (Evolution to HEAD; symlink target)\nold mode 100644\nnew mode 120000\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sb strings.Builder
+			writeFileHeader(&sb, "run.sh", tt.fp, tt.note)
+			if sb.String() != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, sb.String())
+			}
+		})
+	}
+}
+
+func TestModeChangeInDiffAndStats(t *testing.T) {
+	commits := commitHistory(t,
+		map[string]string{"deploy.sh": executableFile + "echo deploy\n", "current": symlinkFile + "v1"},
+		map[string]string{"deploy.sh": "echo deploy\n", "current": symlinkFile + "v2"},
+	)
+	parent, c := commits[0], commits[1]
+
+	diff, files, err := GetStandardDiffWithOptions(c, parent, Options{})
+	if err != nil {
+		t.Fatalf("GetStandardDiffWithOptions failed: %v", err)
+	}
+	for _, want := range []string{
+		"--- deploy.sh\nold mode 100755\nnew mode 100644\n",
+		"--- current (symlink target)\n-v1\n+v2\n",
+	} {
+		if !strings.Contains(diff, want) {
+			t.Errorf("Expected %q in:\n%s", want, diff)
+		}
+	}
+	if len(files) != 2 {
+		t.Errorf("Expected both files to be analyzed, got %v", files)
+	}
+
+	stats, err := Stats(c, parent)
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+	for _, f := range stats.Files {
+		if f.Path == "deploy.sh" && !f.ModeChanged {
+			t.Errorf("Expected deploy.sh to have a mode change")
+		}
+	}
+	if got := stats.ChangedLines([]string{"deploy.sh"}); got != 1 {
+		t.Errorf("Expected a mode-only change to count as 1 line, got %d", got)
+	}
+
+	kind, err := NonFunctionalChange(c, parent, []string{"deploy.sh"})
+	if err != nil {
+		t.Fatalf("NonFunctionalChange failed: %v", err)
+	}
+	if kind != "" {
+		t.Errorf("Expected a mode change to be functional, got %q", kind)
+	}
+}
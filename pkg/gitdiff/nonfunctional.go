@@ -0,0 +1,308 @@
+package gitdiff
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// commentStyle describes how comments are written in a language
+type commentStyle struct {
+	line       []string // line comment prefixes
+	blockStart string
+	blockEnd   string
+	backtick   bool // backtick-quoted strings
+}
+
+var (
+	cStyle    = commentStyle{line: []string{"//"}, blockStart: "/*", blockEnd: "*/"}
+	jsStyle   = commentStyle{line: []string{"//"}, blockStart: "/*", blockEnd: "*/", backtick: true}
+	hashStyle = commentStyle{line: []string{"#"}}
+	sqlStyle  = commentStyle{line: []string{"--"}, blockStart: "/*", blockEnd: "*/"}
+)
+
+// commentStyles maps file extensions to their comment syntax
+var commentStyles = map[string]commentStyle{
+	".go": jsStyle, ".js": jsStyle, ".mjs": jsStyle, ".cjs": jsStyle,
+	".ts": jsStyle, ".tsx": jsStyle, ".jsx": jsStyle,
+	".c": cStyle, ".h": cStyle, ".cc": cStyle, ".cpp": cStyle, ".hpp": cStyle,
+	".java": cStyle, ".kt": cStyle, ".scala": cStyle, ".cs": cStyle,
+	".rs": cStyle, ".swift": cStyle, ".php": cStyle, ".proto": cStyle,
+	".py": hashStyle, ".rb": hashStyle, ".sh": hashStyle, ".bash": hashStyle,
+	".pl": hashStyle, ".r": hashStyle, ".toml": hashStyle,
+	".yaml": hashStyle, ".yml": hashStyle,
+	".sql": sqlStyle, ".lua": sqlStyle,
+}
+
+// indentSensitive lists extensions where leading whitespace is meaningful
+var indentSensitive = map[string]bool{
+	".py": true, ".yaml": true, ".yml": true, ".mk": true,
+}
+
+// importPrefixes start lines that only import other code
+var importPrefixes = []string{
+	"import ", "from ", "#include ", "#include<", "use ", "require ", "require(", "using ",
+}
+
+// NonFunctionalChange reports whether the changes between parent and c to
+// paths leave their functional content unchanged: only whitespace,
+// comments, or the order of imports differ. It returns a human-readable
+// reason such as "only whitespace and comments changed", or "" if any
+// file changed functionally. Indentation is treated as functional in
+// Python and YAML.
+func NonFunctionalChange(c, parent *object.Commit, paths []string) (string, error) {
+	if len(paths) == 0 {
+		return "", nil
+	}
+	cTree, err := c.Tree()
+	if err != nil {
+		return "", err
+	}
+	var pTree *object.Tree
+	if parent != nil {
+		if pTree, err = parent.Tree(); err != nil {
+			return "", err
+		}
+	}
+
+	kinds := map[string]bool{}
+	for _, p := range paths {
+		oldContent, oldOK := readFile(pTree, p)
+		newContent, newOK := readFile(cTree, p)
+		if !oldOK || !newOK {
+			// Added, deleted, or unreadable files count as functional
+			return "", nil
+		}
+		if fileMode(pTree, p) != fileMode(cTree, p) {
+			// So do mode changes, such as a script losing its executable bit
+			return "", nil
+		}
+		kind := nonFunctionalKind(p, oldContent, newContent)
+		if kind == "" {
+			return "", nil
+		}
+		kinds[kind] = true
+	}
+
+	var parts []string
+	for _, kind := range []string{"whitespace", "comments", "import order"} {
+		if kinds[kind] {
+			parts = append(parts, kind)
+		}
+	}
+	return fmt.Sprintf("only %s changed", strings.Join(parts, " and ")), nil
+}
+
+// nonFunctionalKind classifies the difference between two versions of a
+// file as "whitespace", "comments", or "import order", or "" if the
+// difference is functional
+func nonFunctionalKind(filePath, oldContent, newContent string) string {
+	ext := strings.ToLower(path.Ext(filePath))
+	indent := indentSensitive[ext] || path.Base(filePath) == "Makefile"
+	style, hasComments := commentStyles[ext]
+
+	if normalizeSpace(oldContent, indent, style) == normalizeSpace(newContent, indent, style) {
+		return "whitespace"
+	}
+	if !hasComments {
+		return ""
+	}
+	oldCode, newCode := stripComments(oldContent, style), stripComments(newContent, style)
+	if normalizeSpace(oldCode, indent, style) == normalizeSpace(newCode, indent, style) {
+		return "comments"
+	}
+	if normalizeSpace(sortImports(oldCode), indent, style) == normalizeSpace(sortImports(newCode), indent, style) {
+		return "import order"
+	}
+	return ""
+}
+
+// readFile returns the content of p in tree
+func readFile(tree *object.Tree, p string) (string, bool) {
+	if tree == nil {
+		return "", false
+	}
+	f, err := tree.File(p)
+	if err != nil {
+		return "", false
+	}
+	r, err := f.Reader()
+	if err != nil {
+		return "", false
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// isWordByte reports whether b can be part of an identifier or number
+func isWordByte(b byte) bool {
+	return b == '_' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= 0x80
+}
+
+// normalizeSpace removes formatting from code outside string literals. A
+// whitespace run becomes a single space between two words and disappears
+// elsewhere. When indent is set, line structure and leading indentation
+// are kept and only blank lines and trailing or inner whitespace runs are
+// normalized.
+func normalizeSpace(s string, indent bool, style commentStyle) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	var sb strings.Builder
+	var lineIndent strings.Builder
+	atLineStart := true
+	pendingSpace := false
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case ch == '\n':
+			pendingSpace = false
+			if indent && !atLineStart {
+				sb.WriteByte('\n')
+			}
+			atLineStart = true
+			lineIndent.Reset()
+		case ch == ' ' || ch == '\t' || ch == '\r' || ch == '\f' || ch == '\v':
+			if indent && atLineStart {
+				lineIndent.WriteByte(ch)
+				continue
+			}
+			pendingSpace = true
+		default:
+			if indent && atLineStart {
+				sb.WriteString(lineIndent.String())
+			} else if pendingSpace && sb.Len() > 0 && isWordByte(sb.String()[sb.Len()-1]) && isWordByte(ch) {
+				sb.WriteByte(' ')
+			}
+			pendingSpace = false
+			atLineStart = false
+			if end := stringEnd(s, i, style); end > i {
+				sb.WriteString(s[i:end])
+				i = end - 1
+				continue
+			}
+			sb.WriteByte(ch)
+		}
+	}
+	return strings.TrimRight(sb.String(), " \t\n")
+}
+
+// stringEnd returns the index just past the string literal starting at i,
+// or i if there is none
+func stringEnd(s string, i int, style commentStyle) int {
+	quote := s[i]
+	if quote != '"' && quote != '\'' && !(quote == '`' && style.backtick) {
+		return i
+	}
+	for j := i + 1; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			if quote != '`' {
+				j++
+			}
+		case quote:
+			return j + 1
+		case '\n':
+			if quote != '`' {
+				// Unterminated; treat the quote as ordinary text
+				return i
+			}
+		}
+	}
+	return i
+}
+
+// stripComments removes comments outside string literals, keeping
+// newlines so that line structure is preserved
+func stripComments(s string, style commentStyle) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if end := stringEnd(s, i, style); end > i {
+			sb.WriteString(s[i:end])
+			i = end - 1
+			continue
+		}
+		if style.blockStart != "" && strings.HasPrefix(s[i:], style.blockStart) {
+			end := strings.Index(s[i+len(style.blockStart):], style.blockEnd)
+			if end == -1 {
+				return sb.String()
+			}
+			block := s[i : i+len(style.blockStart)+end+len(style.blockEnd)]
+			sb.WriteString(strings.Repeat("\n", strings.Count(block, "\n")))
+			i += len(block) - 1
+			continue
+		}
+		isLineComment := false
+		for _, prefix := range style.line {
+			if strings.HasPrefix(s[i:], prefix) {
+				isLineComment = true
+				break
+			}
+		}
+		if isLineComment {
+			end := strings.IndexByte(s[i:], '\n')
+			if end == -1 {
+				return sb.String()
+			}
+			i += end - 1
+			continue
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+// sortImports sorts each contiguous run of import lines, including the
+// lines of Go's parenthesized import blocks
+func sortImports(s string) string {
+	lines := strings.Split(s, "\n")
+	inBlock := false
+	start := -1
+	flush := func(end int) {
+		if start >= 0 {
+			sort.Strings(lines[start:end])
+			start = -1
+		}
+	}
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		isImport := inBlock && trimmed != ")" && trimmed != ""
+		if !inBlock {
+			for _, prefix := range importPrefixes {
+				if strings.HasPrefix(trimmed, prefix) {
+					isImport = true
+					break
+				}
+			}
+		}
+		if trimmed == "import (" {
+			flush(i)
+			inBlock = true
+			continue
+		}
+		if inBlock && trimmed == ")" {
+			flush(i)
+			inBlock = false
+			continue
+		}
+		if isImport {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if trimmed == "" && inBlock {
+			// Blank lines separate import groups; sort across them
+			continue
+		}
+		flush(i)
+	}
+	flush(len(lines))
+	return strings.Join(lines, "\n")
+}
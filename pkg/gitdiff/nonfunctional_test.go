@@ -0,0 +1,70 @@
+package gitdiff
+
+import (
+	"testing"
+)
+
+func TestNonFunctionalKind(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		old, new string
+		expected string
+	}{
+		{"reindented go", "a.go", "func f() {\n\treturn 1\n}\n", "func f() {\n    return 1\n}\n", "whitespace"},
+		{"gofmt spacing", "a.go", "x:=a+b\n", "x := a + b\n", "whitespace"},
+		{"trailing blank lines", "README.md", "# Title\n", "# Title\n\n\n", "whitespace"},
+		{"comment edit", "a.go", "// old\nx := 1 // why\n", "// new\nx := 1 // because\n", "comments"},
+		{"block comment", "a.c", "/* a\n b */\nint x;\n", "/* changed */\nint x;\n", "comments"},
+		{"python comment", "a.py", "x = 1  # old\n", "x = 1  # new\n", "comments"},
+		{"go import order", "a.go", "import (\n\t\"os\"\n\t\"fmt\"\n)\n", "import (\n\t\"fmt\"\n\t\"os\"\n)\n", "import order"},
+		{"python import order", "a.py", "import sys\nimport os\n\nx = 1\n", "import os\nimport sys\n\nx = 1\n", "import order"},
+
+		{"code change", "a.go", "return 1\n", "return 2\n", ""},
+		{"string whitespace", "a.go", "s := \"a b\"\n", "s := \"a  b\"\n", ""},
+		{"comment marker in string", "a.go", "u := \"http://a\"\n", "u := \"http://b\"\n", ""},
+		{"words joined", "a.go", "return x\n", "returnx\n", ""},
+		{"python reindent", "a.py", "if x:\n    y()\nz()\n", "if x:\n    y()\n    z()\n", ""},
+		{"comment in unknown language", "a.txt", "# a\n", "# b\n", ""},
+		{"new import", "a.go", "import (\n\t\"os\"\n)\n", "import (\n\t\"fmt\"\n\t\"os\"\n)\n", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nonFunctionalKind(tt.path, tt.old, tt.new); got != tt.expected {
+				t.Errorf("nonFunctionalKind(%q): expected %q, got %q", tt.path, tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestNonFunctionalChange(t *testing.T) {
+	parent := commitFiles(t, map[string]string{"main.go": "package main\n\nfunc main() {}\n"})
+	reformatted := commitFiles(t, map[string]string{"main.go": "package main\n\n// main runs\nfunc main()  {}\n"})
+	changed := commitFiles(t, map[string]string{"main.go": "package main\n\nfunc main() { run() }\n"})
+
+	reason, err := NonFunctionalChange(reformatted, parent, []string{"main.go"})
+	if err != nil {
+		t.Fatalf("NonFunctionalChange failed: %v", err)
+	}
+	if reason != "only comments changed" {
+		t.Errorf("Expected %q, got %q", "only comments changed", reason)
+	}
+
+	reason, err = NonFunctionalChange(changed, parent, []string{"main.go"})
+	if err != nil {
+		t.Fatalf("NonFunctionalChange failed: %v", err)
+	}
+	if reason != "" {
+		t.Errorf("Expected functional change, got %q", reason)
+	}
+
+	// A file added by the commit is always functional
+	reason, err = NonFunctionalChange(parent, nil, []string{"main.go"})
+	if err != nil {
+		t.Fatalf("NonFunctionalChange failed: %v", err)
+	}
+	if reason != "" {
+		t.Errorf("Expected added file to be functional, got %q", reason)
+	}
+}
@@ -0,0 +1,174 @@
+package gitdiff
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// maxBlameOwnerFiles bounds the files blamed for each suggestion, since
+// blame walks history
+const maxBlameOwnerFiles = 3
+
+// Sources of an Owner
+const (
+	OwnerSourceCodeOwners = "codeowners"
+	OwnerSourceBlame      = "blame"
+)
+
+// codeOwnersPaths are where GitHub and GitLab look for CODEOWNERS, in
+// order; the first one found is used
+var codeOwnersPaths = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS", ".gitlab/CODEOWNERS"}
+
+// Owner is someone to ask about a suspect commit: a CODEOWNERS owner of
+// its files, or for files without one, the last author to touch them
+type Owner struct {
+	// Owner is a CODEOWNERS entry ("@org/team", "@user", or an email) or
+	// a blamed author's email
+	Owner string `json:"owner"`
+
+	// Files are the commit's files the owner is responsible for
+	Files []string `json:"files"`
+
+	// Source is OwnerSourceCodeOwners or OwnerSourceBlame
+	Source string `json:"source"`
+}
+
+// ownerRule is a CODEOWNERS line
+type ownerRule struct {
+	pattern gitignore.Pattern
+	owners  []string
+}
+
+// OwnerResolver suggests owners for files at HEAD. It is safe for
+// concurrent use and caches blame results, so create one per analysis.
+type OwnerResolver struct {
+	head  *object.Commit
+	rules []ownerRule
+
+	mu    sync.Mutex
+	blame map[string]string
+}
+
+// NewOwnerResolver reads the CODEOWNERS file of head, if any. A nil head
+// gives a resolver that suggests no owners.
+func NewOwnerResolver(head *object.Commit) *OwnerResolver {
+	r := &OwnerResolver{head: head, blame: map[string]string{}}
+	if head == nil {
+		return r
+	}
+	tree, err := head.Tree()
+	if err != nil {
+		return r
+	}
+	for _, p := range codeOwnersPaths {
+		f, err := tree.File(p)
+		if err != nil {
+			continue
+		}
+		if content, err := f.Contents(); err == nil {
+			r.rules = parseCodeOwners(content)
+		}
+		break
+	}
+	return r
+}
+
+// parseCodeOwners parses CODEOWNERS content: one gitignore-style pattern
+// per line followed by its owners. GitLab section headers are skipped.
+func parseCodeOwners(content string) []ownerRule {
+	var rules []ownerRule
+	for _, line := range strings.Split(content, "\n") {
+		line, _, _ = strings.Cut(line, " #")
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "[") || strings.HasPrefix(line, "^[") {
+			continue
+		}
+		fields := strings.Fields(line)
+		rules = append(rules, ownerRule{
+			pattern: gitignore.ParsePattern(fields[0], nil),
+			owners:  fields[1:],
+		})
+	}
+	return rules
+}
+
+// codeOwners returns the CODEOWNERS owners of path and whether any rule
+// matched it. The last matching rule wins; a rule without owners leaves
+// the path unowned.
+func (r *OwnerResolver) codeOwners(path string) ([]string, bool) {
+	parts := strings.Split(path, "/")
+	for i := len(r.rules) - 1; i >= 0; i-- {
+		if r.rules[i].pattern.Match(parts, false) == gitignore.Exclude {
+			return r.rules[i].owners, true
+		}
+	}
+	return nil, false
+}
+
+// blameOwner returns the email of the author who last touched path at
+// HEAD, or "" if the file cannot be blamed
+func (r *OwnerResolver) blameOwner(path string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if owner, ok := r.blame[path]; ok {
+		return owner
+	}
+	owner := ""
+	if result, err := git.Blame(r.head, path); err == nil {
+		var newest *git.Line
+		for _, l := range result.Lines {
+			if newest == nil || l.Date.After(newest.Date) {
+				newest = l
+			}
+		}
+		if newest != nil {
+			owner = newest.Author
+		}
+	}
+	r.blame[path] = owner
+	return owner
+}
+
+// Suggest groups paths by owner, in order of first appearance. Paths that
+// no CODEOWNERS rule matches are attributed by blame at HEAD, up to
+// maxBlameOwnerFiles of them; paths deleted since are skipped. A nil
+// resolver suggests no owners.
+func (r *OwnerResolver) Suggest(paths []string) []Owner {
+	if r == nil || r.head == nil {
+		return nil
+	}
+	var owners []Owner
+	index := map[[2]string]int{}
+	add := func(name, source, path string) {
+		key := [2]string{name, source}
+		i, ok := index[key]
+		if !ok {
+			i = len(owners)
+			index[key] = i
+			owners = append(owners, Owner{Owner: name, Source: source})
+		}
+		owners[i].Files = append(owners[i].Files, path)
+	}
+
+	blamed := 0
+	for _, path := range paths {
+		if names, ok := r.codeOwners(path); ok {
+			for _, name := range names {
+				add(name, OwnerSourceCodeOwners, path)
+			}
+			continue
+		}
+		if blamed == maxBlameOwnerFiles {
+			continue
+		}
+		blamed++
+		if name := r.blameOwner(path); name != "" {
+			add(name, OwnerSourceBlame, path)
+		}
+	}
+	return owners
+}
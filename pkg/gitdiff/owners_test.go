@@ -0,0 +1,69 @@
+package gitdiff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOwnerResolverCodeOwners(t *testing.T) {
+	head := commitFiles(t, map[string]string{
+		".github/CODEOWNERS":  "# Default owners\n*  @acme/platform\n\n[Backend]\n/pkg/auth/ @acme/identity dana@example.com # sessions\ndocs/ @acme/docs\n*.sql @acme/dba\n/pkg/auth/legacy.go\n",
+		"pkg/auth/session.go": "package auth\n",
+		"pkg/auth/legacy.go":  "package auth\n",
+		"pkg/db/schema.sql":   "CREATE TABLE t ();\n",
+		"docs/guide/intro.md": "# Intro\n",
+		"main.go":             "package main\n",
+	})
+	r := NewOwnerResolver(head)
+
+	tests := []struct {
+		path     string
+		expected []string
+		matched  bool
+	}{
+		{"pkg/auth/session.go", []string{"@acme/identity", "dana@example.com"}, true},
+		{"pkg/db/schema.sql", []string{"@acme/dba"}, true},
+		{"docs/guide/intro.md", []string{"@acme/docs"}, true},
+		{"main.go", []string{"@acme/platform"}, true},
+		{"pkg/auth/legacy.go", []string{}, true},
+	}
+	for _, tt := range tests {
+		owners, matched := r.codeOwners(tt.path)
+		if matched != tt.matched || len(owners) != len(tt.expected) || (len(owners) > 0 && !reflect.DeepEqual(owners, tt.expected)) {
+			t.Errorf("codeOwners(%q): expected %v (matched %v), got %v (matched %v)", tt.path, tt.expected, tt.matched, owners, matched)
+		}
+	}
+
+	expected := []Owner{
+		{Owner: "@acme/identity", Files: []string{"pkg/auth/session.go"}, Source: OwnerSourceCodeOwners},
+		{Owner: "dana@example.com", Files: []string{"pkg/auth/session.go"}, Source: OwnerSourceCodeOwners},
+		{Owner: "@acme/platform", Files: []string{"main.go", "go.mod"}, Source: OwnerSourceCodeOwners},
+	}
+	if got := r.Suggest([]string{"pkg/auth/session.go", "main.go", "go.mod"}); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+}
+
+func TestOwnerResolverBlameFallback(t *testing.T) {
+	commits := commitHistory(t,
+		map[string]string{"CODEOWNERS": "/api/ @acme/api\n", "api/handler.go": "package api\n", "worker.go": "package main\n"},
+		map[string]string{"worker.go": "package main\n\nfunc work() {}\n"},
+	)
+	r := NewOwnerResolver(commits[1])
+
+	expected := []Owner{
+		{Owner: "test@example.com", Files: []string{"worker.go"}, Source: OwnerSourceBlame},
+		{Owner: "@acme/api", Files: []string{"api/handler.go"}, Source: OwnerSourceCodeOwners},
+	}
+	if got := r.Suggest([]string{"worker.go", "api/handler.go", "deleted.go"}); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+
+	if got := NewOwnerResolver(nil).Suggest([]string{"worker.go"}); got != nil {
+		t.Errorf("Expected no owners without HEAD, got %+v", got)
+	}
+	var nilResolver *OwnerResolver
+	if got := nilResolver.Suggest([]string{"worker.go"}); got != nil {
+		t.Errorf("Expected no owners from a nil resolver, got %+v", got)
+	}
+}
@@ -0,0 +1,32 @@
+package gitdiff
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+)
+
+// PatchID identifies a diff by its content, like git patch-id: hunk
+// headers and whitespace are ignored, so a commit cherry-picked onto
+// shifted lines gets the same ID as the original. An empty diff has an
+// empty ID.
+func PatchID(diff string) string {
+	h := sha1.New()
+	empty := true
+	for _, line := range strings.Split(diff, "\n") {
+		if strings.HasPrefix(line, "@@") {
+			continue
+		}
+		line = strings.Join(strings.Fields(line), "")
+		if line == "" {
+			continue
+		}
+		h.Write([]byte(line))
+		h.Write([]byte{'\n'})
+		empty = false
+	}
+	if empty {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
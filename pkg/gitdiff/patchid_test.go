@@ -0,0 +1,31 @@
+package gitdiff
+
+import (
+	"testing"
+)
+
+func TestPatchID(t *testing.T) {
+	base := "--- auth.go\n@@ -10 +10 @@\n func Login() {\n-\treturn nil\n+\treturn err\n }\n"
+
+	tests := []struct {
+		name string
+		diff string
+		same bool
+	}{
+		{"identical", base, true},
+		{"shifted lines", "--- auth.go\n@@ -42 +40 @@\n func Login() {\n-\treturn nil\n+\treturn err\n }\n", true},
+		{"reindented", "--- auth.go\n@@ -10 +10 @@\n func Login()  {\n-    return nil\n+    return err\n }\n\n", true},
+		{"different change", "--- auth.go\n@@ -10 +10 @@\n func Login() {\n-\treturn nil\n+\treturn errBadToken\n }\n", false},
+		{"different file", "--- session.go\n@@ -10 +10 @@\n func Login() {\n-\treturn nil\n+\treturn err\n }\n", false},
+	}
+	id := PatchID(base)
+	for _, tt := range tests {
+		if got := PatchID(tt.diff) == id; got != tt.same {
+			t.Errorf("%s: expected same patch ID %v, got %v", tt.name, tt.same, got)
+		}
+	}
+
+	if got := PatchID("@@ -1 +1 @@\n\n"); got != "" {
+		t.Errorf("Expected an empty ID for an empty diff, got %q", got)
+	}
+}
@@ -0,0 +1,57 @@
+package gitdiff
+
+import (
+	"fmt"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// ChangedPaths lists the paths c changes relative to parent (nil: the
+// empty tree) by comparing tree entries. Unchanged subtrees are skipped by
+// hash and no file content is read, so it is much cheaper than a patch. A
+// renamed file is listed under its old and its new path.
+func ChangedPaths(c, parent *object.Commit) ([]string, error) {
+	cTree, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+	var pTree *object.Tree
+	if parent != nil {
+		if pTree, err = parent.Tree(); err != nil {
+			return nil, err
+		}
+	}
+
+	changes, err := object.DiffTree(pTree, cTree)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff trees: %w", err)
+	}
+	paths := make([]string, 0, len(changes))
+	for _, ch := range changes {
+		if ch.From.Name != "" {
+			paths = append(paths, ch.From.Name)
+		}
+		if ch.To.Name != "" && ch.To.Name != ch.From.Name {
+			paths = append(paths, ch.To.Name)
+		}
+	}
+	return paths, nil
+}
+
+// OnlyIgnoredChanges reports whether opts.Filter drops every path c
+// changes, such as commits touching only lock files, CI configuration, or
+// excluded docs, so the commit can be skipped before any patch is built.
+// Only path rules are applied: false does not mean the full diff keeps a
+// file, since .gitattributes and generated-content checks need contents.
+func OnlyIgnoredChanges(c, parent *object.Commit, opts Options) (bool, error) {
+	paths, err := ChangedPaths(c, parent)
+	if err != nil {
+		return false, err
+	}
+	for _, p := range paths {
+		if !opts.Filter.Ignore(p) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
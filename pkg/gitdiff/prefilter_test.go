@@ -0,0 +1,74 @@
+package gitdiff
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestChangedPaths(t *testing.T) {
+	commits := commitHistory(t,
+		map[string]string{"main.go": "package main\n", "docs/guide.md": "# Guide\n"},
+		map[string]string{"main.go": "package main\n\nfunc main() {}\n", "docs/guide.md": deleteFile, "go.sum": "sum\n"},
+	)
+
+	paths, err := ChangedPaths(commits[1], commits[0])
+	if err != nil {
+		t.Fatalf("ChangedPaths failed: %v", err)
+	}
+	sort.Strings(paths)
+	expected := []string{"docs/guide.md", "go.sum", "main.go"}
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("Expected %v, got %v", expected, paths)
+	}
+
+	paths, err = ChangedPaths(commits[0], nil)
+	if err != nil {
+		t.Fatalf("ChangedPaths failed: %v", err)
+	}
+	if len(paths) != 2 {
+		t.Errorf("Expected root commit to add 2 files, got %v", paths)
+	}
+}
+
+func TestOnlyIgnoredChanges(t *testing.T) {
+	commits := commitHistory(t,
+		map[string]string{"main.go": "package main\n"},
+		map[string]string{"go.sum": "sum\n", ".github/workflows/ci.yml": "on: push\n"},
+		map[string]string{"docs/guide.md": "# Guide\n"},
+	)
+	docs, err := NewFilter(nil, []string{"docs/**"})
+	if err != nil {
+		t.Fatalf("NewFilter failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		index    int
+		opts     Options
+		expected bool
+	}{
+		{"code", 0, Options{}, false},
+		{"lock file and CI", 1, Options{}, true},
+		{"docs without filter", 2, Options{}, false},
+		{"docs excluded", 2, Options{Filter: docs}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var parent *object.Commit
+			if tt.index > 0 {
+				parent = commits[tt.index-1]
+			}
+			got, err := OnlyIgnoredChanges(commits[tt.index], parent, tt.opts)
+			if err != nil {
+				t.Fatalf("OnlyIgnoredChanges failed: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
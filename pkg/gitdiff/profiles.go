@@ -0,0 +1,157 @@
+package gitdiff
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// ProfileAuto selects the filter profiles detected from the repository
+const ProfileAuto = "auto"
+
+// filterProfiles are exclude patterns for build output, tooling, and
+// generated files of each ecosystem, on top of the built-in rules
+var filterProfiles = map[string][]string{
+	"go": {
+		"*.pb.go", "*.pb.gw.go", "*_grpc.pb.go", "zz_generated*.go",
+		"mock_*.go", "*_mock.go", "**/mocks/**", "**/testdata/**", "go.work.sum",
+	},
+	"node": {
+		"*.min.js", "*.min.css", "*.map", "*.snap", "**/__snapshots__/**",
+		"**/coverage/**", "**/.next/**", "**/.nuxt/**", "**/.turbo/**",
+		"npm-shrinkwrap.json", "bun.lockb", ".eslintcache",
+	},
+	"python": {
+		"*.pyc", "*.pyo", "**/.venv/**", "**/venv/**", "**/*.egg-info/**",
+		"**/.mypy_cache/**", "**/.ruff_cache/**", "**/htmlcov/**", "uv.lock",
+		"*_pb2.py", "*_pb2_grpc.py",
+	},
+	"jvm": {
+		"**/target/**", "**/.gradle/**", "**/gradle/wrapper/**", "gradlew", "gradlew.bat",
+		"**/.mvn/wrapper/**", "mvnw", "mvnw.cmd", "*.class", "*.jar", "gradle.lockfile",
+	},
+	"monorepo": {
+		"**/docs/**", "*.md", "**/.changeset/**", "CODEOWNERS", "renovate.json",
+		".renovaterc*", "**/bazel-*/**", "**/.nx/**",
+	},
+}
+
+// profileManifests are the files that mark a project of each ecosystem
+var profileManifests = map[string][]string{
+	"go":     {"go.mod"},
+	"node":   {"package.json"},
+	"python": {"pyproject.toml", "setup.py", "setup.cfg", "requirements.txt", "Pipfile"},
+	"jvm":    {"pom.xml", "build.gradle", "build.gradle.kts", "settings.gradle", "settings.gradle.kts", "build.sbt"},
+}
+
+// monorepoMarkers are root files of multi-project workspaces
+var monorepoMarkers = []string{
+	"go.work", "pnpm-workspace.yaml", "lerna.json", "nx.json", "turbo.json", "rush.json",
+	"WORKSPACE", "WORKSPACE.bazel", "MODULE.bazel", "pants.toml",
+}
+
+// ProfileNames lists the filter profiles, sorted
+func ProfileNames() []string {
+	names := make([]string, 0, len(filterProfiles))
+	for name := range filterProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DetectProfiles picks filter profiles from the manifests at the root of
+// tree and in its top-level directories, such as "go" for go.mod. A
+// repository with workspace markers (go.work, pnpm-workspace.yaml,
+// Bazel's WORKSPACE, ...), several ecosystems, or projects in several
+// directories also gets "monorepo".
+func DetectProfiles(tree *object.Tree) []string {
+	if tree == nil {
+		return nil
+	}
+	found := map[string]bool{}
+	subprojects := map[string]bool{}
+	monorepo := false
+	for _, entry := range tree.Entries {
+		if entry.Mode.IsFile() {
+			if profile := manifestProfile(entry.Name); profile != "" {
+				found[profile] = true
+			}
+			for _, marker := range monorepoMarkers {
+				if entry.Name == marker {
+					monorepo = true
+				}
+			}
+			continue
+		}
+		sub, err := tree.Tree(entry.Name)
+		if err != nil {
+			continue
+		}
+		for _, subEntry := range sub.Entries {
+			if !subEntry.Mode.IsFile() {
+				continue
+			}
+			if profile := manifestProfile(subEntry.Name); profile != "" {
+				found[profile] = true
+				subprojects[entry.Name] = true
+			}
+		}
+	}
+
+	var profiles []string
+	for _, name := range ProfileNames() {
+		if found[name] {
+			profiles = append(profiles, name)
+		}
+	}
+	if monorepo || len(profiles) > 1 || len(subprojects) > 1 {
+		profiles = append(profiles, "monorepo")
+	}
+	return profiles
+}
+
+// manifestProfile returns the profile a manifest file name marks, or ""
+func manifestProfile(name string) string {
+	for profile, manifests := range profileManifests {
+		for _, m := range manifests {
+			if name == m {
+				return profile
+			}
+		}
+	}
+	return ""
+}
+
+// AddProfiles adds the exclude patterns of the named filter profiles to
+// f, with ProfileAuto standing for the profiles DetectProfiles finds in
+// tree (usually HEAD's). It returns the profiles applied, in order.
+func (f *Filter) AddProfiles(names []string, tree *object.Tree) ([]string, error) {
+	var applied []string
+	seen := map[string]bool{}
+	add := func(name string) {
+		if seen[name] {
+			return
+		}
+		seen[name] = true
+		applied = append(applied, name)
+		f.Exclude = append(f.Exclude, filterProfiles[name]...)
+	}
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch {
+		case name == "":
+		case name == ProfileAuto:
+			for _, detected := range DetectProfiles(tree) {
+				add(detected)
+			}
+		case filterProfiles[name] != nil:
+			add(name)
+		default:
+			return nil, fmt.Errorf("unknown filter profile %q (expected %s, or %s)", name, strings.Join(ProfileNames(), ", "), ProfileAuto)
+		}
+	}
+	return applied, nil
+}
@@ -0,0 +1,69 @@
+package gitdiff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDetectProfiles(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		expected []string
+	}{
+		{"go module", map[string]string{"go.mod": "module x\n", "main.go": "package main\n"}, []string{"go"}},
+		{"python in subdirectory", map[string]string{"service/pyproject.toml": "[project]\n"}, []string{"python"}},
+		{"nothing", map[string]string{"README": "hi\n"}, nil},
+		{"several ecosystems", map[string]string{"go.mod": "module x\n", "web/package.json": "{}\n"}, []string{"go", "node", "monorepo"}},
+		{"workspace marker", map[string]string{"pnpm-workspace.yaml": "packages: []\n", "package.json": "{}\n"}, []string{"node", "monorepo"}},
+		{"several projects", map[string]string{"a/pom.xml": "<project/>\n", "b/pom.xml": "<project/>\n"}, []string{"jvm", "monorepo"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree, err := commitFiles(t, tt.files).Tree()
+			if err != nil {
+				t.Fatalf("Failed to get tree: %v", err)
+			}
+			if got := DetectProfiles(tree); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestAddProfiles(t *testing.T) {
+	tree, err := commitFiles(t, map[string]string{"go.mod": "module x\n"}).Tree()
+	if err != nil {
+		t.Fatalf("Failed to get tree: %v", err)
+	}
+
+	f := &Filter{}
+	applied, err := f.AddProfiles([]string{"auto", " Node ", "go"}, tree)
+	if err != nil {
+		t.Fatalf("AddProfiles failed: %v", err)
+	}
+	if expected := []string{"go", "node"}; !reflect.DeepEqual(applied, expected) {
+		t.Errorf("Expected profiles %v, got %v", expected, applied)
+	}
+
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"api/v1/service.pb.go", true},
+		{"internal/mocks/store.go", true},
+		{"web/dist.min.js", true},
+		{"web/src/app.js", false},
+		{"server/server.go", false},
+	}
+	for _, tt := range tests {
+		if got := f.Ignore(tt.path); got != tt.expected {
+			t.Errorf("Ignore(%q): expected %v, got %v", tt.path, tt.expected, got)
+		}
+	}
+
+	if _, err := (&Filter{}).AddProfiles([]string{"cobol"}, tree); err == nil {
+		t.Error("Expected error for unknown profile")
+	}
+}
@@ -0,0 +1,97 @@
+package gitdiff
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+
+	"github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Diff backends accepted by NewProvider
+const (
+	BackendGoGit = "go-git" // pure Go, works on any repository
+	BackendGit   = "git"    // system git binary, faster on large repositories
+	BackendAuto  = "auto"   // system git when on PATH, else go-git
+)
+
+// DiffProvider computes the file patches between two commits. Patches
+// must carry every line of each file as Equal, Add, or Delete chunks, as
+// go-git does, since whole-file output and hunks are rendered from them.
+type DiffProvider interface {
+	// FilePatches returns the patches from one commit to another. A nil
+	// from compares against the empty tree. With detectRenames, a renamed
+	// file is one patch from its old path to its new one instead of a
+	// deletion and an addition.
+	FilePatches(from, to *object.Commit, detectRenames bool) ([]diff.FilePatch, error)
+}
+
+// GoGitProvider computes patches from go-git trees. It is the default.
+type GoGitProvider struct{}
+
+// FilePatches implements DiffProvider
+func (GoGitProvider) FilePatches(from, to *object.Commit, detectRenames bool) ([]diff.FilePatch, error) {
+	toTree, err := to.Tree()
+	if err != nil {
+		return nil, err
+	}
+	var fromTree *object.Tree
+	if from != nil {
+		if fromTree, err = from.Tree(); err != nil {
+			return nil, err
+		}
+	}
+
+	// DiffTree treats a nil tree as empty, covering root commits
+	var changes object.Changes
+	if detectRenames {
+		changes, err = object.DiffTreeWithOptions(context.Background(), fromTree, toTree, object.DefaultDiffTreeOptions)
+	} else {
+		changes, err = object.DiffTree(fromTree, toTree)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff trees: %w", err)
+	}
+	patch, err := changes.Patch()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate patch: %w", err)
+	}
+	return patch.FilePatches(), nil
+}
+
+// NewProvider returns the DiffProvider for a backend name (empty:
+// go-git). The system git backend runs in repoDir, the repository's
+// working directory or git directory.
+func NewProvider(backend, repoDir string) (DiffProvider, error) {
+	switch backend {
+	case "", BackendGoGit:
+		return GoGitProvider{}, nil
+	case BackendGit:
+		return NewGitCLIProvider(repoDir)
+	case BackendAuto:
+		if _, err := exec.LookPath("git"); err != nil {
+			return GoGitProvider{}, nil
+		}
+		return NewGitCLIProvider(repoDir)
+	default:
+		return nil, fmt.Errorf("unknown diff backend %q (expected %s, %s, or %s)", backend, BackendGoGit, BackendGit, BackendAuto)
+	}
+}
+
+// provider returns the configured DiffProvider, defaulting to go-git
+func (opts Options) provider() DiffProvider {
+	if opts.Provider == nil {
+		return GoGitProvider{}
+	}
+	return opts.Provider
+}
+
+// textChunk is a diff.Chunk built outside go-git
+type textChunk struct {
+	content string
+	op      diff.Operation
+}
+
+func (c *textChunk) Content() string      { return c.content }
+func (c *textChunk) Type() diff.Operation { return c.op }
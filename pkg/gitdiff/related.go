@@ -0,0 +1,291 @@
+package gitdiff
+
+import (
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Thresholds for two files to count as changing together: B is related
+// to A when they changed in the same commit at least minCoChanges times,
+// in at least minCoChangeShare of A's commits
+const (
+	minCoChanges     = 2
+	minCoChangeShare = 0.3
+)
+
+// maxCoChangeCommitFiles leaves out commits changing more files, such as
+// renames and reformatting, which pair files that do not interact
+const maxCoChangeCommitFiles = 20
+
+// maxGoSourceSize bounds the Go files whose imports are read
+const maxGoSourceSize = 1 << 20
+
+// moduleRe matches the module directive of a go.mod
+var moduleRe = regexp.MustCompile(`(?m)^module\s+"?([^\s"]+)"?`)
+
+// RelatedFile is a file a commit did not change that its changes may
+// interact with
+type RelatedFile struct {
+	Path string `json:"path"`
+
+	// Reason says how the file relates to the commit, such as "changed
+	// together with auth.go in 4 of 6 commits"
+	Reason string `json:"reason"`
+}
+
+// Related finds the files that interact with a commit's files: those
+// that historically change in the same commits, and, in Go modules, those
+// importing their packages. It is read-only once loaded and safe for
+// concurrent use.
+type Related struct {
+	goImportIndex
+	commits map[string]int            // commits changing each file
+	pairs   map[string]map[string]int // commits changing both files
+	max     int
+}
+
+// LoadRelated walks up to history commits of head's first-parent history
+// like LoadHotspots, counting the commits each pair of files changed in
+// together, and indexes the imports of the Go files in head's tree. Diff
+// shows at most maxFiles related files.
+func LoadRelated(head *object.Commit, history, maxFiles int) (*Related, error) {
+	r := &Related{
+		commits: map[string]int{},
+		pairs:   map[string]map[string]int{},
+		max:     maxFiles,
+	}
+	err := walkHistory(head, history, func(_ *object.Commit, paths []string) {
+		if len(paths) > maxCoChangeCommitFiles {
+			return
+		}
+		for _, a := range paths {
+			r.commits[a]++
+			for _, b := range paths {
+				if a == b {
+					continue
+				}
+				if r.pairs[a] == nil {
+					r.pairs[a] = map[string]int{}
+				}
+				r.pairs[a][b]++
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := head.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get HEAD tree: %w", err)
+	}
+	if r.goImportIndex, err = loadGoImportIndex(tree); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// goImportIndex maps the packages of the Go modules in a tree to the Go
+// files importing them
+type goImportIndex struct {
+	modules   map[string]string   // module path by module root directory
+	importers map[string][]string // Go files, tests included, by imported package
+}
+
+// loadGoImportIndex records the module roots of tree and the packages
+// each of its Go files imports, leaving out vendored files
+func loadGoImportIndex(tree *object.Tree) (goImportIndex, error) {
+	x := goImportIndex{modules: map[string]string{}, importers: map[string][]string{}}
+	var goFiles []goFile
+	walker := object.NewTreeWalker(tree, true, nil)
+	defer walker.Close()
+	for {
+		name, entry, err := walker.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return x, fmt.Errorf("walking tree: %w", err)
+		}
+		if !entry.Mode.IsFile() || ignoreRule(name, true) != "" {
+			continue
+		}
+		switch {
+		case path.Base(name) == "go.mod":
+			f, err := tree.TreeEntryFile(&entry)
+			if err != nil || f.Size > maxManifestSize {
+				continue
+			}
+			content, err := f.Contents()
+			if err != nil {
+				continue
+			}
+			if m := moduleRe.FindStringSubmatch(content); m != nil {
+				x.modules[path.Dir(name)] = m[1]
+			}
+		case path.Ext(name) == ".go":
+			if f, err := tree.TreeEntryFile(&entry); err == nil && f.Size <= maxGoSourceSize {
+				goFiles = append(goFiles, goFile{path: name, file: f})
+			}
+		}
+	}
+	if len(x.modules) == 0 {
+		return x, nil
+	}
+
+	fset := token.NewFileSet()
+	for _, f := range goFiles {
+		content, err := f.file.Contents()
+		if err != nil {
+			continue
+		}
+		file, err := parser.ParseFile(fset, f.path, content, parser.ImportsOnly)
+		if err != nil {
+			continue
+		}
+		for _, imp := range file.Imports {
+			if pkg, err := strconv.Unquote(imp.Path.Value); err == nil {
+				x.importers[pkg] = append(x.importers[pkg], f.path)
+			}
+		}
+	}
+	return x, nil
+}
+
+// goFile is a Go file of a tree and its path
+type goFile struct {
+	path string
+	file *object.File
+}
+
+// goPackage returns the import path of the package of the Go file p, or
+// "" if p is not in a module of the tree
+func (x goImportIndex) goPackage(p string) string {
+	if path.Ext(p) != ".go" {
+		return ""
+	}
+	dir := path.Dir(p)
+	for root := dir; ; root = path.Dir(root) {
+		if module, ok := x.modules[root]; ok {
+			if root == dir {
+				return module
+			}
+			return module + "/" + strings.TrimPrefix(dir, root+"/")
+		}
+		if root == "." || root == "/" {
+			return ""
+		}
+	}
+}
+
+// For returns the files related to a commit changing paths, leaving out
+// paths themselves and files filter ignores: first the files importing
+// the packages of changed Go files, then those changed together with them,
+// most often first. A nil Related gives nil.
+func (r *Related) For(paths []string, filter *Filter) []RelatedFile {
+	if r == nil {
+		return nil
+	}
+	skip := map[string]bool{}
+	for _, p := range paths {
+		skip[p] = true
+	}
+	var related []RelatedFile
+	add := func(p, reason string) {
+		if !skip[p] && !filter.Ignore(p) {
+			skip[p] = true
+			related = append(related, RelatedFile{Path: p, Reason: reason})
+		}
+	}
+
+	for _, p := range paths {
+		if pkg := r.goPackage(p); pkg != "" {
+			for _, importer := range r.importers[pkg] {
+				if IsTestFile(importer) {
+					continue
+				}
+				add(importer, fmt.Sprintf("imports %s, changed in %s", pkg, p))
+			}
+		}
+	}
+
+	type coChange struct {
+		path, with string
+		together   int
+		share      float64
+	}
+	var candidates []coChange
+	for _, p := range paths {
+		for other, n := range r.pairs[p] {
+			share := float64(n) / float64(r.commits[p])
+			if n >= minCoChanges && share >= minCoChangeShare {
+				candidates = append(candidates, coChange{path: other, with: p, together: n, share: share})
+			}
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.together != b.together {
+			return a.together > b.together
+		}
+		if a.share != b.share {
+			return a.share > b.share
+		}
+		return a.path < b.path
+	})
+	for _, c := range candidates {
+		add(c.path, fmt.Sprintf("changed together with %s in %d of its %d commits", c.with, c.together, r.commits[c.with]))
+	}
+	return related
+}
+
+// Diff returns the evolution from c to head of up to maxFiles files
+// related to c's changes to paths (see For) that changed since c, under a
+// header giving the reason each is included, and those files. It returns
+// "" if none changed, as does a nil Related. Like GetFullDiffWithOptions,
+// only the context and provider settings of opts apply; the result is
+// capped at half the full diff's size.
+func (r *Related) Diff(c, head *object.Commit, paths []string, opts Options) (string, []RelatedFile, error) {
+	related := r.For(paths, opts.Filter)
+	if len(related) == 0 || r.max <= 0 {
+		return "", nil, nil
+	}
+	changed, err := ChangedPaths(head, c)
+	if err != nil {
+		return "", nil, err
+	}
+	changedSet := make(map[string]bool, len(changed))
+	for _, p := range changed {
+		changedSet[p] = true
+	}
+
+	var sb strings.Builder
+	var shown []RelatedFile
+	var files []string
+	for _, rf := range related {
+		if changedSet[rf.Path] && len(shown) < r.max {
+			shown = append(shown, rf)
+			files = append(files, rf.Path)
+			fmt.Fprintf(&sb, "- %s: %s\n", rf.Path, rf.Reason)
+		}
+	}
+	if len(shown) == 0 {
+		return "", nil, nil
+	}
+	diff, err := GetFullDiffWithOptions(c, head, files, opts)
+	if err != nil {
+		return "", nil, err
+	}
+	header := "RELATED FILES (not changed by this commit, but they interact with its files) - evolution to HEAD:\n"
+	return TruncateDiff(header+sb.String()+"\n"+diff, opts.maxFullDiffSize()/2), shown, nil
+}
@@ -0,0 +1,86 @@
+package gitdiff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRelated(t *testing.T) {
+	commits := commitHistory(t,
+		map[string]string{
+			"go.mod":         "module example.com/app\n",
+			"store/store.go": "package store\n",
+			"api/api.go":     "package api\n\nimport \"example.com/app/store\"\n\nvar _ = store.X\n",
+			"schema.sql":     "a",
+			"README.md":      "a",
+		},
+		map[string]string{"store/store.go": "package store\n\nvar X = 1\n", "schema.sql": "b"},
+		map[string]string{"store/store.go": "package store\n\nvar X = 2\n", "schema.sql": "c"},
+		map[string]string{"store/store.go": "package store\n\nvar X = 3\n"},
+		map[string]string{"api/api.go": "package api\n\nimport \"example.com/app/store\"\n\nvar _ = store.X + 1\n", "schema.sql": "d"},
+	)
+	commit, head := commits[3], commits[4]
+
+	r, err := LoadRelated(head, DefaultHotspotHistory, 1)
+	if err != nil {
+		t.Fatalf("LoadRelated failed: %v", err)
+	}
+
+	related := r.For([]string{"store/store.go"}, nil)
+	expected := []RelatedFile{
+		{Path: "api/api.go", Reason: "imports example.com/app/store, changed in store/store.go"},
+		{Path: "schema.sql", Reason: "changed together with store/store.go in 3 of its 4 commits"},
+	}
+	if !reflect.DeepEqual(related, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, related)
+	}
+
+	filter := &Filter{Exclude: []string{"*.sql"}}
+	if got := r.For([]string{"store/store.go"}, filter); len(got) != 1 || got[0].Path != "api/api.go" {
+		t.Errorf("Expected the filter to leave out schema.sql, got %+v", got)
+	}
+
+	diff, shown, err := r.Diff(commit, head, []string{"store/store.go"}, Options{})
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	if len(shown) != 1 || shown[0].Path != "api/api.go" {
+		t.Errorf("Expected only the importer within the limit of 1, got %+v", shown)
+	}
+	if !strings.Contains(diff, "RELATED FILES") || !strings.Contains(diff, "store.X + 1") {
+		t.Errorf("Expected the importer's evolution, got:\n%s", diff)
+	}
+	if strings.Contains(diff, "schema.sql") {
+		t.Errorf("Expected schema.sql beyond the limit, got:\n%s", diff)
+	}
+
+	// Files unchanged since the commit have no evolution to show
+	if diff, shown, err := r.Diff(commits[4], head, []string{"store/store.go"}, Options{}); err != nil || diff != "" || shown != nil {
+		t.Errorf("Expected no related diff at HEAD, got %q, %+v, %v", diff, shown, err)
+	}
+
+	var none *Related
+	if got := none.For([]string{"store/store.go"}, nil); got != nil {
+		t.Errorf("Expected nil Related to give nil, got %+v", got)
+	}
+	if diff, _, err := none.Diff(commit, head, []string{"store/store.go"}, Options{}); err != nil || diff != "" {
+		t.Errorf("Expected nil Related to give no diff, got %q, %v", diff, err)
+	}
+}
+
+func TestGoPackage(t *testing.T) {
+	r := goImportIndex{modules: map[string]string{".": "example.com/app", "tools": "example.com/tools"}}
+	tests := map[string]string{
+		"main.go":            "example.com/app",
+		"pkg/store/store.go": "example.com/app/pkg/store",
+		"tools/gen.go":       "example.com/tools",
+		"tools/cmd/x/x.go":   "example.com/tools/cmd/x",
+		"schema.sql":         "",
+	}
+	for p, want := range tests {
+		if got := r.goPackage(p); got != want {
+			t.Errorf("goPackage(%q) = %q, want %q", p, got, want)
+		}
+	}
+}
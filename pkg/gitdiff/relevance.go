@@ -0,0 +1,273 @@
+package gitdiff
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// stackFrameBonus is the score given to a file named in a stack trace,
+// high enough to outrank any amount of keyword overlap
+const stackFrameBonus = 1000
+
+var (
+	// identRe matches identifiers and words worth searching for
+	identRe = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]{2,}`)
+	// fileRefRe matches "path/to/file.ext:123" as found in stack traces
+	fileRefRe = regexp.MustCompile(`([A-Za-z0-9_./\\-]+\.[A-Za-z0-9]+):\d+`)
+)
+
+// stopWords are common words in error messages that say nothing about
+// where the bug is
+var stopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "with": true, "from": true,
+	"this": true, "that": true, "not": true, "was": true, "are": true,
+	"when": true, "after": true, "before": true, "while": true, "into": true,
+	"error": true, "errors": true, "failed": true, "failure": true, "fails": true,
+	"cannot": true, "can": true, "could": true, "unable": true, "invalid": true,
+	"goroutine": true, "panic": true, "running": true, "exit": true, "status": true,
+}
+
+// relevance scores diff text against an error message
+type relevance struct {
+	terms []string
+	files []string
+}
+
+// newRelevance extracts search terms and stack-trace file references from
+// an error message
+func newRelevance(errorMessage string) *relevance {
+	r := &relevance{}
+	seen := map[string]bool{}
+	for _, m := range identRe.FindAllString(errorMessage, -1) {
+		term := strings.ToLower(m)
+		if stopWords[term] || seen[term] {
+			continue
+		}
+		seen[term] = true
+		r.terms = append(r.terms, term)
+	}
+	for _, m := range fileRefRe.FindAllStringSubmatch(errorMessage, -1) {
+		r.files = append(r.files, strings.ReplaceAll(m[1], "\\", "/"))
+	}
+	return r
+}
+
+// empty reports whether the error message gave nothing to score with
+func (r *relevance) empty() bool {
+	return len(r.terms) == 0 && len(r.files) == 0
+}
+
+// fileScore scores a file by whether a stack trace names it
+func (r *relevance) fileScore(filePath string) int {
+	for _, ref := range r.files {
+		// Stack traces may show absolute or module paths; compare the tail
+		if strings.HasSuffix(ref, "/"+filePath) || strings.HasSuffix(filePath, "/"+ref) ||
+			ref == filePath || path.Base(ref) == path.Base(filePath) {
+			return stackFrameBonus
+		}
+	}
+	return 0
+}
+
+// textScore counts the search terms found in text; each term counts once
+// so that one repeated word cannot dominate
+func (r *relevance) textScore(text string) int {
+	return len(r.matchedTerms(text))
+}
+
+// matchedTerms returns the search terms found in text
+func (r *relevance) matchedTerms(text string) []string {
+	lower := strings.ToLower(text)
+	var matched []string
+	for _, term := range r.terms {
+		if strings.Contains(lower, term) {
+			matched = append(matched, term)
+		}
+	}
+	return matched
+}
+
+// ErrorMatch relates a commit's files and diff to an error message
+type ErrorMatch struct {
+	// StackFiles are the files that a stack trace in the message names
+	StackFiles []string
+
+	// Keywords are the message's identifiers and words found in the diff
+	Keywords []string
+
+	// Terms counts the message's identifiers and words, stop words aside
+	Terms int
+}
+
+// MatchError finds which of files a stack trace in errorMessage names,
+// and which identifiers and words of errorMessage appear in diff, with
+// the rules used to keep relevant files when truncating
+func MatchError(errorMessage string, files []string, diff string) ErrorMatch {
+	rel := newRelevance(errorMessage)
+	m := ErrorMatch{Terms: len(rel.terms), Keywords: rel.matchedTerms(diff)}
+	for _, f := range files {
+		if rel.fileScore(f) > 0 {
+			m.StackFiles = append(m.StackFiles, f)
+		}
+	}
+	return m
+}
+
+// splitHunks splits a file section into its header line and its hunks.
+// Sections rendered without hunk headers form a single hunk.
+func splitHunks(text string) (header string, hunks []string) {
+	idx := strings.Index(text, "\n")
+	if idx == -1 {
+		return text, nil
+	}
+	header, body := text[:idx+1], text[idx+1:]
+	for body != "" {
+		next := strings.Index(body[1:], "\n@@ ")
+		if next == -1 {
+			hunks = append(hunks, body)
+			break
+		}
+		hunks = append(hunks, body[:next+2])
+		body = body[next+2:]
+	}
+	return header, hunks
+}
+
+// dropIrrelevantHunks removes from each file section the hunks that share
+// no search term with rel and notes their line numbers in a marker, and
+// returns the new sections with their token counts and total. Sections
+// without hunk headers, and files none of whose hunks are relevant, are
+// left whole for prioritizeSections to rank.
+func dropIrrelevantHunks(sections []fileSection, tok Tokenizer, rel *relevance) ([]fileSection, []int, int) {
+	out := make([]fileSection, len(sections))
+	counts := make([]int, len(sections))
+	total := 0
+	for i, s := range sections {
+		out[i] = s
+		header, hunks := splitHunks(s.text)
+		if len(hunks) > 1 && strings.HasPrefix(hunks[0], "@@ ") {
+			var sb strings.Builder
+			sb.WriteString(header)
+			var omitted []string
+			for _, h := range hunks {
+				if rel.textScore(h) > 0 {
+					sb.WriteString(h)
+					continue
+				}
+				first, _, _ := strings.Cut(h, "\n")
+				omitted = append(omitted, strings.TrimSuffix(strings.TrimPrefix(first, "@@ "), " @@"))
+			}
+			if len(omitted) > 0 && len(omitted) < len(hunks) {
+				sb.WriteString(fmt.Sprintf("... [omitted %d hunks unrelated to the error at lines %s] ...\n", len(omitted), strings.Join(omitted, ", ")))
+				out[i].text = sb.String()
+			}
+		}
+		counts[i] = tok.CountTokens(out[i].text)
+		total += counts[i]
+	}
+	return out, counts, total
+}
+
+// prioritizeSections fits sections into maxTokens by keeping the files and
+// hunks most relevant to rel and dropping the rest. Files are considered
+// from most to least relevant; a file that doesn't fit keeps its most
+// relevant hunks that do. Kept files stay in their original order, and a
+// final marker lists the files that were dropped.
+func prioritizeSections(sections []fileSection, counts []int, maxTokens int, tok Tokenizer, rel *relevance) string {
+	type scored struct {
+		index int
+		score int
+	}
+	order := make([]scored, len(sections))
+	for i, s := range sections {
+		order[i] = scored{i, rel.fileScore(s.path) + rel.textScore(s.text)}
+	}
+	sort.SliceStable(order, func(a, b int) bool { return order[a].score > order[b].score })
+
+	kept := make([]string, len(sections))
+	remaining := maxTokens
+	for _, o := range order {
+		s := sections[o.index]
+		if counts[o.index] <= remaining {
+			kept[o.index] = s.text
+			remaining -= counts[o.index]
+			continue
+		}
+		if o.score == 0 {
+			continue
+		}
+		if text := fitHunks(s.text, remaining, tok, rel); text != "" {
+			kept[o.index] = text
+			remaining -= tok.CountTokens(text)
+		}
+	}
+
+	var sb strings.Builder
+	sb.Grow(defaultDiffBufferSize)
+	var dropped []string
+	for i, text := range kept {
+		if text == "" {
+			dropped = append(dropped, sections[i].path)
+			continue
+		}
+		sb.WriteString(text)
+	}
+	if len(dropped) > 0 {
+		sb.WriteString(fmt.Sprintf("... [omitted %d less relevant files: %s] ...\n", len(dropped), strings.Join(dropped, ", ")))
+	}
+	return sb.String()
+}
+
+// fitHunks keeps the header of a file section and its most relevant hunks
+// that fit in maxTokens, in their original order. If no hunk fits whole,
+// the section is truncated instead. It returns "" if not even the header
+// fits.
+func fitHunks(text string, maxTokens int, tok Tokenizer, rel *relevance) string {
+	header, hunks := splitHunks(text)
+	remaining := maxTokens - tok.CountTokens(header)
+	if remaining <= 0 || len(hunks) == 0 {
+		return ""
+	}
+	if len(hunks) == 1 {
+		return truncateSection(text, maxTokens, tok)
+	}
+
+	order := make([]int, len(hunks))
+	scores := make([]int, len(hunks))
+	for i, h := range hunks {
+		order[i] = i
+		scores[i] = rel.textScore(h)
+	}
+	sort.SliceStable(order, func(a, b int) bool { return scores[order[a]] > scores[order[b]] })
+
+	keep := make([]bool, len(hunks))
+	found := false
+	for _, i := range order {
+		if n := tok.CountTokens(hunks[i]); n <= remaining {
+			keep[i] = true
+			remaining -= n
+			found = true
+		}
+	}
+	if !found {
+		return truncateSection(text, maxTokens, tok)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(header)
+	omitted := 0
+	for i, h := range hunks {
+		if keep[i] {
+			sb.WriteString(h)
+		} else {
+			omitted++
+		}
+	}
+	if omitted > 0 {
+		sb.WriteString(fmt.Sprintf("... [omitted %d less relevant hunks in this file] ...\n", omitted))
+	}
+	return sb.String()
+}
@@ -0,0 +1,149 @@
+package gitdiff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewRelevance(t *testing.T) {
+	rel := newRelevance("panic: runtime error: invalid memory address in ParseConfig\n\tgithub.com/acme/app/pkg/config/loader.go:42 +0x1d")
+
+	for _, term := range []string{"runtime", "memory", "address", "parseconfig", "loader"} {
+		found := false
+		for _, got := range rel.terms {
+			if got == term {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Expected term %q in %v", term, rel.terms)
+		}
+	}
+	for _, got := range rel.terms {
+		if stopWords[got] {
+			t.Errorf("Expected stop word %q to be dropped", got)
+		}
+	}
+	if len(rel.files) != 1 || rel.files[0] != "github.com/acme/app/pkg/config/loader.go" {
+		t.Errorf("Expected stack trace file reference, got %v", rel.files)
+	}
+
+	if !newRelevance("the error failed").empty() {
+		t.Error("Expected message of only stop words to be empty")
+	}
+}
+
+func TestFileScore(t *testing.T) {
+	rel := newRelevance("at pkg/config/loader.go:42")
+
+	tests := []struct {
+		path     string
+		expected int
+	}{
+		{"pkg/config/loader.go", stackFrameBonus},
+		{"internal/pkg/config/loader.go", stackFrameBonus},
+		{"pkg/config/other.go", 0},
+	}
+	for _, tt := range tests {
+		if got := rel.fileScore(tt.path); got != tt.expected {
+			t.Errorf("fileScore(%q): expected %d, got %d", tt.path, tt.expected, got)
+		}
+	}
+}
+
+func TestMatchError(t *testing.T) {
+	m := MatchError("nil pointer in ParseConfig\n\tgithub.com/acme/app/pkg/config/loader.go:42",
+		[]string{"pkg/config/loader.go", "pkg/server/server.go"},
+		"+func ParseConfig(path string) *Config {\n")
+
+	if len(m.StackFiles) != 1 || m.StackFiles[0] != "pkg/config/loader.go" {
+		t.Errorf("Expected loader.go to be named by the stack trace, got %v", m.StackFiles)
+	}
+	if strings.Join(m.Keywords, ",") != "parseconfig,config" {
+		t.Errorf("Expected keywords parseconfig and config, got %v", m.Keywords)
+	}
+	if m.Terms < 3 {
+		t.Errorf("Expected the message's terms to be counted, got %d", m.Terms)
+	}
+}
+
+func TestSplitHunks(t *testing.T) {
+	header, hunks := splitHunks("--- a.go\n@@ -1 +1 @@\n-a\n+b\n@@ -9 +9 @@\n+c\n")
+	if header != "--- a.go\n" {
+		t.Errorf("Expected header %q, got %q", "--- a.go\n", header)
+	}
+	expected := []string{"@@ -1 +1 @@\n-a\n+b\n", "@@ -9 +9 @@\n+c\n"}
+	if len(hunks) != len(expected) || hunks[0] != expected[0] || hunks[1] != expected[1] {
+		t.Errorf("Expected hunks %q, got %q", expected, hunks)
+	}
+}
+
+func TestBudgetSectionsPrioritizesRelevantFiles(t *testing.T) {
+	sections := []fileSection{
+		section("docs.go", 40),
+		{path: "auth/session.go", text: "--- auth/session.go\n+func RefreshToken() {\n+\treturn\n+}\n"},
+		section("util.go", 40),
+	}
+
+	result := budgetSections(sections, 45, lineTokenizer{}, "token refresh panics in RefreshToken", false)
+
+	if !strings.Contains(result, "RefreshToken") {
+		t.Errorf("Expected relevant file to be kept, got:\n%s", result)
+	}
+	if !strings.Contains(result, "--- docs.go\n") {
+		t.Errorf("Expected first irrelevant file to fill the remaining budget, got:\n%s", result)
+	}
+	if strings.Contains(result, "--- util.go\n") {
+		t.Errorf("Expected second irrelevant file to be dropped, got:\n%s", result)
+	}
+	if !strings.Contains(result, "[omitted 1 less relevant files: util.go]") {
+		t.Errorf("Expected omission marker, got:\n%s", result)
+	}
+	if strings.Index(result, "--- docs.go") > strings.Index(result, "--- auth/session.go") {
+		t.Errorf("Expected kept files in original order, got:\n%s", result)
+	}
+}
+
+func TestBudgetSectionsPrioritizesRelevantHunks(t *testing.T) {
+	text := "--- server.go\n" +
+		"@@ -1 +1 @@\n+a\n+b\n+c\n+d\n" +
+		"@@ -20 +20 @@\n+handleLogin()\n" +
+		"@@ -40 +40 @@\n+e\n+f\n+g\n+h\n"
+	sections := []fileSection{{path: "server.go", text: text}}
+
+	result := budgetSections(sections, 8, lineTokenizer{}, "crash in handleLogin", false)
+
+	if !strings.Contains(result, "+handleLogin()") {
+		t.Errorf("Expected relevant hunk to be kept, got:\n%s", result)
+	}
+	if strings.Contains(result, "+h\n") {
+		t.Errorf("Expected a less relevant hunk to be dropped, got:\n%s", result)
+	}
+	if !strings.Contains(result, "[omitted 1 less relevant hunks in this file]") {
+		t.Errorf("Expected hunk omission marker, got:\n%s", result)
+	}
+}
+
+func TestBudgetSectionsDropsIrrelevantHunks(t *testing.T) {
+	server := "--- server.go\n" +
+		"@@ -1 +1 @@\n+a\n" +
+		"@@ -20 +20 @@\n+handleLogin()\n" +
+		"@@ -40 +40 @@\n+b\n"
+	sections := []fileSection{
+		{path: "server.go", text: server},
+		{path: "util.go", text: "--- util.go\n@@ -1 +1 @@\n+c\n@@ -9 +9 @@\n+d\n"},
+	}
+
+	result := budgetSections(sections, 10, lineTokenizer{}, "crash in handleLogin", true)
+
+	expected := "--- server.go\n@@ -20 +20 @@\n+handleLogin()\n" +
+		"... [omitted 2 hunks unrelated to the error at lines -1 +1, -40 +40] ...\n" +
+		"--- util.go\n@@ -1 +1 @@\n+c\n@@ -9 +9 @@\n+d\n"
+	if result != expected {
+		t.Errorf("Expected irrelevant hunks of the relevant file to be dropped, got:\n%s", result)
+	}
+
+	if result := budgetSections(sections, 100, lineTokenizer{}, "crash in handleLogin", true); result != server+sections[1].text {
+		t.Errorf("Expected diff within budget to be unchanged, got:\n%s", result)
+	}
+}
@@ -0,0 +1,207 @@
+package gitdiff
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Symbol is a top-level declaration in a source file
+type Symbol struct {
+	Kind      string // func, method, type, var, const, class, ...
+	Name      string // methods are qualified by receiver type: "Server.Handle"
+	StartLine int
+	EndLine   int
+	Source    string
+}
+
+// SymbolParser extracts the declarations of a source file. Only Go has
+// one, built on go/parser; files in other languages get no semantic diff
+// and keep their line diff.
+type SymbolParser interface {
+	ParseSymbols(filename string, src []byte) ([]Symbol, error)
+}
+
+// symbolParsers are the parsers by file extension
+var symbolParsers = map[string]SymbolParser{
+	".go": goSymbolParser{},
+}
+
+// symbolParserFor returns the parser for a file, or nil
+func symbolParserFor(filePath string) SymbolParser {
+	return symbolParsers[strings.ToLower(path.Ext(filePath))]
+}
+
+// goSymbolParser parses Go source with the standard library
+type goSymbolParser struct{}
+
+// ParseSymbols implements SymbolParser
+func (goSymbolParser) ParseSymbols(filename string, src []byte) ([]Symbol, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+
+	symbol := func(kind, name string, node ast.Node) Symbol {
+		start, end := fset.Position(node.Pos()), fset.Position(node.End())
+		return Symbol{
+			Kind:      kind,
+			Name:      name,
+			StartLine: start.Line,
+			EndLine:   end.Line,
+			Source:    string(src[start.Offset:end.Offset]),
+		}
+	}
+
+	var symbols []Symbol
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				symbols = append(symbols, symbol("method", receiverName(d.Recv.List[0].Type)+"."+d.Name.Name, d))
+			} else {
+				symbols = append(symbols, symbol("func", d.Name.Name, d))
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					symbols = append(symbols, symbol("type", s.Name.Name, s))
+				case *ast.ValueSpec:
+					kind := "var"
+					if d.Tok == token.CONST {
+						kind = "const"
+					}
+					for _, name := range s.Names {
+						if name.Name != "_" {
+							symbols = append(symbols, symbol(kind, name.Name, s))
+						}
+					}
+				}
+			}
+		}
+	}
+	return symbols, nil
+}
+
+// receiverName returns the type name of a method receiver, without
+// pointers or type parameters
+func receiverName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(t.X)
+	case *ast.IndexExpr:
+		return receiverName(t.X)
+	case *ast.IndexListExpr:
+		return receiverName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return "?"
+}
+
+// SymbolChange is a declaration added, removed, or modified by a commit
+type SymbolChange struct {
+	Kind   string
+	Name   string
+	Change string // added, removed, or modified
+	Lines  int    // lines of the declaration after the change (before, if removed)
+	Delta  int    // change in line count
+}
+
+// DiffSymbols compares the declarations of two versions of a file. Either
+// version may be empty for added or deleted files. Changes that only
+// affect formatting or comments are not reported.
+func DiffSymbols(filePath string, oldSrc, newSrc []byte) ([]SymbolChange, error) {
+	p := symbolParserFor(filePath)
+	if p == nil {
+		return nil, fmt.Errorf("no symbol parser for %s", filePath)
+	}
+	parse := func(src []byte) (map[string]Symbol, error) {
+		symbols := map[string]Symbol{}
+		if len(src) == 0 {
+			return symbols, nil
+		}
+		list, err := p.ParseSymbols(filePath, src)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range list {
+			symbols[s.Kind+" "+s.Name] = s
+		}
+		return symbols, nil
+	}
+	before, err := parse(oldSrc)
+	if err != nil {
+		return nil, err
+	}
+	after, err := parse(newSrc)
+	if err != nil {
+		return nil, err
+	}
+
+	style := commentStyles[strings.ToLower(path.Ext(filePath))]
+	normalize := func(src string) string {
+		return normalizeSpace(stripComments(src, style), false, style)
+	}
+
+	var changes []SymbolChange
+	for key, s := range after {
+		lines := s.EndLine - s.StartLine + 1
+		old, ok := before[key]
+		switch {
+		case !ok:
+			changes = append(changes, SymbolChange{Kind: s.Kind, Name: s.Name, Change: "added", Lines: lines, Delta: lines})
+		case normalize(old.Source) != normalize(s.Source):
+			changes = append(changes, SymbolChange{Kind: s.Kind, Name: s.Name, Change: "modified", Lines: lines, Delta: lines - (old.EndLine - old.StartLine + 1)})
+		}
+	}
+	for key, s := range before {
+		if _, ok := after[key]; !ok {
+			lines := s.EndLine - s.StartLine + 1
+			changes = append(changes, SymbolChange{Kind: s.Kind, Name: s.Name, Change: "removed", Lines: lines, Delta: -lines})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Name != changes[j].Name {
+			return changes[i].Name < changes[j].Name
+		}
+		return changes[i].Kind < changes[j].Kind
+	})
+	return changes, nil
+}
+
+// changeMarkers prefix each change in a semantic diff
+var changeMarkers = map[string]string{"added": "+", "removed": "-", "modified": "~"}
+
+// renderSemanticDiff formats symbol changes as a file section
+func renderSemanticDiff(filePath string, changes []SymbolChange) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("--- %s (semantic)\n", filePath))
+	for _, ch := range changes {
+		detail := fmt.Sprintf("%d lines", ch.Lines)
+		if ch.Change == "modified" {
+			detail = fmt.Sprintf("%d lines, %+d", ch.Lines, ch.Delta)
+		}
+		sb.WriteString(fmt.Sprintf("%s %s %s %s (%s)\n", changeMarkers[ch.Change], ch.Kind, ch.Name, ch.Change, detail))
+	}
+	return sb.String()
+}
+
+// semanticSection renders the semantic diff of a file between two trees.
+// It reports false if the file has no parser, cannot be parsed, or has no
+// changed declarations.
+func semanticSection(pTree, cTree *object.Tree, filePath string) (string, bool) {
+	changes, ok := parsedSymbols(pTree, cTree, filePath)
+	if !ok || len(changes) == 0 {
+		return "", false
+	}
+	return renderSemanticDiff(filePath, changes), true
+}
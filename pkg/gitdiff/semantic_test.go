@@ -0,0 +1,117 @@
+package gitdiff
+
+import (
+	"strings"
+	"testing"
+)
+
+const semanticBefore = `package server
+
+import "fmt"
+
+// Version is the protocol version
+const Version = 1
+
+type Server struct {
+	name string
+}
+
+// Handle serves a request
+func (s *Server) Handle(req string) string {
+	return fmt.Sprintf("%s: %s", s.name, req)
+}
+
+func legacy() {}
+`
+
+func TestGoSymbolParser(t *testing.T) {
+	symbols, err := goSymbolParser{}.ParseSymbols("server.go", []byte(semanticBefore))
+	if err != nil {
+		t.Fatalf("ParseSymbols failed: %v", err)
+	}
+
+	expected := []struct {
+		kind, name string
+		start, end int
+	}{
+		{"const", "Version", 6, 6},
+		{"type", "Server", 8, 10},
+		{"method", "Server.Handle", 13, 15},
+		{"func", "legacy", 17, 17},
+	}
+	if len(symbols) != len(expected) {
+		t.Fatalf("Expected %d symbols, got %d: %+v", len(expected), len(symbols), symbols)
+	}
+	for i, want := range expected {
+		got := symbols[i]
+		if got.Kind != want.kind || got.Name != want.name || got.StartLine != want.start || got.EndLine != want.end {
+			t.Errorf("Expected %s %s (%d-%d), got %s %s (%d-%d)",
+				want.kind, want.name, want.start, want.end, got.Kind, got.Name, got.StartLine, got.EndLine)
+		}
+	}
+	if !strings.HasPrefix(symbols[2].Source, "func (s *Server) Handle") {
+		t.Errorf("Expected method source, got %q", symbols[2].Source)
+	}
+}
+
+func TestDiffSymbols(t *testing.T) {
+	after := strings.NewReplacer(
+		"// Handle serves a request\n", "// Handle serves a request and logs it\n",
+		"func legacy() {}\n", "func logRequest(req string) {\n\tfmt.Println(req)\n}\n",
+		"return fmt.Sprintf", "logRequest(req)\n\treturn fmt.Sprintf",
+		"name string", "name  string // display name",
+	).Replace(semanticBefore)
+
+	changes, err := DiffSymbols("server.go", []byte(semanticBefore), []byte(after))
+	if err != nil {
+		t.Fatalf("DiffSymbols failed: %v", err)
+	}
+
+	expected := []SymbolChange{
+		{Kind: "method", Name: "Server.Handle", Change: "modified", Lines: 4, Delta: 1},
+		{Kind: "func", Name: "legacy", Change: "removed", Lines: 1, Delta: -1},
+		{Kind: "func", Name: "logRequest", Change: "added", Lines: 3, Delta: 3},
+	}
+	if len(changes) != len(expected) {
+		t.Fatalf("Expected %d changes, got %d: %+v", len(expected), len(changes), changes)
+	}
+	for i := range expected {
+		if changes[i] != expected[i] {
+			t.Errorf("Expected %+v, got %+v", expected[i], changes[i])
+		}
+	}
+}
+
+func TestDiffSymbolsErrors(t *testing.T) {
+	if _, err := DiffSymbols("a.txt", nil, []byte("x")); err == nil {
+		t.Error("Expected error for file without a parser")
+	}
+	if _, err := DiffSymbols("a.go", nil, []byte("package a\nfunc {")); err == nil {
+		t.Error("Expected error for unparsable file")
+	}
+}
+
+func TestGetStandardDiffSemantic(t *testing.T) {
+	parent := commitFiles(t, map[string]string{
+		"server.go": semanticBefore,
+		"notes.txt": "old\n",
+	})
+	c := commitFiles(t, map[string]string{
+		"server.go": strings.Replace(semanticBefore, "func legacy() {}\n", "", 1),
+		"notes.txt": "new\n",
+	})
+
+	diff, _, err := GetStandardDiffWithOptions(c, parent, Options{SemanticDiff: true})
+	if err != nil {
+		t.Fatalf("GetStandardDiffWithOptions failed: %v", err)
+	}
+	if !strings.Contains(diff, "--- server.go (semantic)\n- func legacy removed (1 lines)\n") {
+		t.Errorf("Expected semantic section for server.go, got:\n%s", diff)
+	}
+	if strings.Contains(diff, "package server") {
+		t.Errorf("Expected no line diff for server.go, got:\n%s", diff)
+	}
+	if !strings.Contains(diff, "--- notes.txt\n") || !strings.Contains(diff, "+new") {
+		t.Errorf("Expected line diff for notes.txt, got:\n%s", diff)
+	}
+}
@@ -0,0 +1,111 @@
+package gitdiff
+
+import (
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// FileStat counts the changed lines of one file
+type FileStat struct {
+	Path       string `json:"path"`
+	Insertions int    `json:"insertions"`
+	Deletions  int    `json:"deletions"`
+	Binary     bool   `json:"binary,omitempty"`
+
+	// ModeChanged is set when the file mode changed, such as the
+	// executable bit or a switch between file and symlink
+	ModeChanged bool `json:"mode_changed,omitempty"`
+}
+
+// DiffStats summarizes a commit's changes, like git diff --numstat. It
+// covers every changed file, before any filtering.
+type DiffStats struct {
+	Files      []FileStat `json:"files"`
+	Insertions int        `json:"insertions"`
+	Deletions  int        `json:"deletions"`
+}
+
+// Stats computes per-file and total line counts for the changes between
+// parent and c. A nil parent compares against the empty tree. Binary files
+// are listed with zero counts.
+func Stats(c, parent *object.Commit) (*DiffStats, error) {
+	return StatsWithOptions(c, parent, Options{})
+}
+
+// StatsWithOptions is Stats computed with the diff provider of opts
+func StatsWithOptions(c, parent *object.Commit, opts Options) (*DiffStats, error) {
+	filePatches, err := opts.provider().FilePatches(parent, c, false)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &DiffStats{}
+	index := make(map[string]int, len(filePatches))
+	for _, fp := range filePatches {
+		fs := FileStat{Path: patchPath(fp), Binary: fp.IsBinary(), ModeChanged: modeChanged(fp)}
+		for _, chunk := range fp.Chunks() {
+			n := countLines(chunk.Content())
+			switch chunk.Type() {
+			case diff.Add:
+				fs.Insertions += n
+			case diff.Delete:
+				fs.Deletions += n
+			}
+		}
+		stats.Insertions += fs.Insertions
+		stats.Deletions += fs.Deletions
+
+		// A path diffed twice, as git does a type change, is one file
+		// whose mode changed
+		if i, ok := index[fs.Path]; ok {
+			prev := &stats.Files[i]
+			prev.Insertions += fs.Insertions
+			prev.Deletions += fs.Deletions
+			prev.Binary = prev.Binary || fs.Binary
+			prev.ModeChanged = true
+			continue
+		}
+		index[fs.Path] = len(stats.Files)
+		stats.Files = append(stats.Files, fs)
+	}
+	return stats, nil
+}
+
+// ChangedLines returns the insertions plus deletions of the given files,
+// or of all files if paths is nil. A mode change counts as one line, so
+// that a file losing its executable bit is never skipped as trivial.
+func (s *DiffStats) ChangedLines(paths []string) int {
+	var want map[string]bool
+	if paths != nil {
+		want = make(map[string]bool, len(paths))
+		for _, p := range paths {
+			want[p] = true
+		}
+	}
+	n := 0
+	for _, f := range s.Files {
+		if want != nil && !want[f.Path] {
+			continue
+		}
+		n += f.Insertions + f.Deletions
+		if f.ModeChanged {
+			n++
+		}
+	}
+	return n
+}
+
+// countLines counts the lines in chunk content, including a final line
+// without a trailing newline
+func countLines(content string) int {
+	if content == "" {
+		return 0
+	}
+	n := strings.Count(content, "\n")
+	if !strings.HasSuffix(content, "\n") {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,60 @@
+package gitdiff
+
+import (
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	c := commitFiles(t, map[string]string{
+		"main.go":   "package main\n\nfunc main() {}\n",
+		"logo.png":  "\x89PNG\r\n\x1a\n\x00\x00\x00binary",
+		"README.md": "# Title",
+	})
+
+	stats, err := Stats(c, nil)
+	if err != nil {
+		t.Fatalf("Stats failed: %v", err)
+	}
+
+	expected := map[string]FileStat{
+		"main.go":   {Path: "main.go", Insertions: 3},
+		"logo.png":  {Path: "logo.png", Binary: true},
+		"README.md": {Path: "README.md", Insertions: 1},
+	}
+	if len(stats.Files) != len(expected) {
+		t.Fatalf("Expected %d files, got %+v", len(expected), stats.Files)
+	}
+	for _, f := range stats.Files {
+		if f != expected[f.Path] {
+			t.Errorf("Expected %+v, got %+v", expected[f.Path], f)
+		}
+	}
+	if stats.Insertions != 4 || stats.Deletions != 0 {
+		t.Errorf("Expected totals 4/0, got %d/%d", stats.Insertions, stats.Deletions)
+	}
+	if got := stats.ChangedLines([]string{"main.go"}); got != 3 {
+		t.Errorf("Expected 3 changed lines in main.go, got %d", got)
+	}
+	if got := stats.ChangedLines(nil); got != 4 {
+		t.Errorf("Expected 4 changed lines in total, got %d", got)
+	}
+}
+
+func TestCountLines(t *testing.T) {
+	tests := []struct {
+		content  string
+		expected int
+	}{
+		{"", 0},
+		{"a", 1},
+		{"a\n", 1},
+		{"a\nb", 2},
+		{"a\nb\n", 2},
+	}
+
+	for _, tt := range tests {
+		if got := countLines(tt.content); got != tt.expected {
+			t.Errorf("countLines(%q): expected %d, got %d", tt.content, tt.expected, got)
+		}
+	}
+}
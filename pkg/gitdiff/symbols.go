@@ -0,0 +1,207 @@
+package gitdiff
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// FileSymbols lists the declarations a commit changed in one file
+type FileSymbols struct {
+	Path    string
+	Symbols []SymbolChange
+}
+
+// declPattern recognizes a declaration line; the first submatch is the name
+type declPattern struct {
+	kind string
+	re   *regexp.Regexp
+}
+
+// declPatterns are heuristics for languages without a SymbolParser,
+// covering the declaration syntax of common C-family, scripting, and JVM
+// languages
+var declPatterns = []declPattern{
+	{"func", regexp.MustCompile(`^func\s+(?:\([^)]*\)\s*)?(\w+)`)},
+	{"func", regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:async\s+)?function\s*\*?\s*(\w+)`)},
+	{"func", regexp.MustCompile(`^\s*(?:export\s+)?(?:const|let|var)\s+(\w+)\s*=\s*(?:async\s+)?(?:function\b|\([^)]*\)\s*=>|\w+\s*=>)`)},
+	{"func", regexp.MustCompile(`^\s*(?:async\s+)?def\s+(?:self\.)?(\w+[?!]?)`)},
+	{"func", regexp.MustCompile(`^\s*(?:pub(?:\([^)]*\))?\s+)?(?:const\s+)?(?:async\s+)?(?:unsafe\s+)?fn\s+(\w+)`)},
+	{"func", regexp.MustCompile(`^\s*(?:(?:public|private|protected|internal|static|final|override|virtual|abstract|async|synchronized|suspend|open)\s+)+(?:fun\s+)?(?:[\w<>\[\],.?]+\s+)?(\w+)\s*\(`)},
+	{"func", regexp.MustCompile(`^\s*fun\s+(?:<[^>]*>\s*)?(?:\w+\.)?(\w+)\s*\(`)},
+	{"func", regexp.MustCompile(`^[A-Za-z_][\w\s\*&:<>,]*?[\s\*&:](\w+)\s*\([^;]*$`)},
+	{"class", regexp.MustCompile(`^\s*(?:(?:export|default|public|private|protected|internal|abstract|final|sealed|static|data|open|pub)\s+)*(?:class|interface|trait|enum|struct|record|object|module)\s+(\w+)`)},
+	{"type", regexp.MustCompile(`^\s*(?:export\s+)?type\s+(\w+)`)},
+}
+
+// declKeywords are words that look like names to the declaration
+// heuristics but start statements, such as "if (x)"
+var declKeywords = map[string]bool{
+	"if": true, "for": true, "while": true, "switch": true, "return": true,
+	"catch": true, "sizeof": true, "else": true, "do": true, "case": true,
+}
+
+// matchDecl returns the kind and name declared by line, or "" if it
+// declares nothing
+func matchDecl(line string) (kind, name string) {
+	for _, p := range declPatterns {
+		if m := p.re.FindStringSubmatch(line); m != nil && !declKeywords[m[1]] {
+			return p.kind, m[1]
+		}
+	}
+	return "", ""
+}
+
+// ChangedSymbols lists the functions, methods, and types the changes
+// between parent and c touch in paths. Files with a SymbolParser are
+// compared declaration by declaration, ignoring formatting and comments;
+// other files, and files that fail to parse, fall back to attributing each
+// changed line to the nearest declaration above it. Files without changed
+// declarations are omitted.
+func ChangedSymbols(c, parent *object.Commit, paths []string) ([]FileSymbols, error) {
+	return ChangedSymbolsWithOptions(c, parent, paths, Options{})
+}
+
+// ChangedSymbolsWithOptions is ChangedSymbols computed with the diff
+// provider of opts
+func ChangedSymbolsWithOptions(c, parent *object.Commit, paths []string, opts Options) ([]FileSymbols, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	cTree, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+	var pTree *object.Tree
+	if parent != nil {
+		if pTree, err = parent.Tree(); err != nil {
+			return nil, err
+		}
+	}
+
+	filePatches, err := opts.provider().FilePatches(parent, c, false)
+	if err != nil {
+		return nil, err
+	}
+
+	want := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		want[p] = true
+	}
+
+	var result []FileSymbols
+	for _, fp := range filePatches {
+		path := patchPath(fp)
+		if !want[path] || fp.IsBinary() {
+			continue
+		}
+		symbols, ok := parsedSymbols(pTree, cTree, path)
+		if !ok {
+			symbols = heuristicSymbols(patchLines(fp.Chunks()))
+		}
+		if len(symbols) > 0 {
+			result = append(result, FileSymbols{Path: path, Symbols: symbols})
+		}
+	}
+	return result, nil
+}
+
+// parsedSymbols compares the declarations of a file between two trees
+// with its SymbolParser. It reports false if there is no parser or either
+// version fails to parse.
+func parsedSymbols(pTree, cTree *object.Tree, filePath string) ([]SymbolChange, bool) {
+	if symbolParserFor(filePath) == nil {
+		return nil, false
+	}
+	oldSrc, _ := readFile(pTree, filePath)
+	newSrc, _ := readFile(cTree, filePath)
+	changes, err := DiffSymbols(filePath, []byte(oldSrc), []byte(newSrc))
+	if err != nil {
+		return nil, false
+	}
+	return changes, true
+}
+
+// heuristicSymbols attributes each changed line to the nearest declaration
+// at or above it. A declaration whose own line was only added is reported
+// as added, one whose line was only removed as removed, and any other as
+// modified. Line counts are not known and left zero.
+func heuristicSymbols(lines []diffLine) []SymbolChange {
+	type state struct {
+		kind           string
+		added, removed bool // the declaration line itself
+	}
+	var order []string
+	seen := map[string]*state{}
+	current := ""
+	for _, l := range lines {
+		if kind, name := matchDecl(l.text); name != "" {
+			current = name
+			st, ok := seen[name]
+			if !ok {
+				st = &state{kind: kind}
+				seen[name] = st
+			}
+			switch l.op {
+			case '+':
+				st.added = true
+			case '-':
+				st.removed = true
+			}
+		}
+		if l.op == ' ' || current == "" {
+			continue
+		}
+		if !slices.Contains(order, current) {
+			order = append(order, current)
+		}
+	}
+
+	symbols := make([]SymbolChange, 0, len(order))
+	for _, name := range order {
+		st := seen[name]
+		change := "modified"
+		switch {
+		case st.added && !st.removed:
+			change = "added"
+		case st.removed && !st.added:
+			change = "removed"
+		}
+		symbols = append(symbols, SymbolChange{Kind: st.kind, Name: name, Change: change})
+	}
+	return symbols
+}
+
+// maxListedSymbols caps the symbols listed by FormatChangedSymbols so that
+// sweeping refactors do not crowd out the diffs
+const maxListedSymbols = 50
+
+// FormatChangedSymbols renders changed symbols one file per line, e.g.
+// "pkg/server.go: method Server.Handle (modified), func parse (added)".
+// It returns "" if there are none.
+func FormatChangedSymbols(files []FileSymbols) string {
+	var sb strings.Builder
+	listed, total := 0, 0
+	for _, f := range files {
+		total += len(f.Symbols)
+		if listed >= maxListedSymbols {
+			continue
+		}
+		var parts []string
+		for _, s := range f.Symbols {
+			if listed >= maxListedSymbols {
+				break
+			}
+			parts = append(parts, fmt.Sprintf("%s %s (%s)", s.Kind, s.Name, s.Change))
+			listed++
+		}
+		sb.WriteString(fmt.Sprintf("%s: %s\n", f.Path, strings.Join(parts, ", ")))
+	}
+	if listed < total {
+		sb.WriteString(fmt.Sprintf("... and %d more\n", total-listed))
+	}
+	return sb.String()
+}
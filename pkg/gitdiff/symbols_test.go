@@ -0,0 +1,116 @@
+package gitdiff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatchDecl(t *testing.T) {
+	tests := []struct {
+		line       string
+		kind, name string
+	}{
+		{"func (s *Server) Handle(req string) {", "func", "Handle"},
+		{"export async function loadUser(id) {", "func", "loadUser"},
+		{"const fetchAll = async (ids) => {", "func", "fetchAll"},
+		{"    def parse(self, text):", "func", "parse"},
+		{"  def self.build!", "func", "build!"},
+		{"pub(crate) async fn spawn_worker(", "func", "spawn_worker"},
+		{"    public static int count(List<String> items) {", "func", "count"},
+		{"    override fun onCreate(state: Bundle?) {", "func", "onCreate"},
+		{"static int read_block(struct dev *d, int n)", "func", "read_block"},
+		{"export default class Parser {", "class", "Parser"},
+		{"public sealed interface Shape {", "class", "Shape"},
+		{"export type Options = {", "type", "Options"},
+
+		{"    if (x) {", "", ""},
+		{"    return compute(x);", "", ""},
+		{"x = compute(y)", "", ""},
+		{"    count += 1", "", ""},
+	}
+
+	for _, tt := range tests {
+		kind, name := matchDecl(tt.line)
+		if kind != tt.kind || name != tt.name {
+			t.Errorf("matchDecl(%q): expected %q %q, got %q %q", tt.line, tt.kind, tt.name, kind, name)
+		}
+	}
+}
+
+func TestHeuristicSymbols(t *testing.T) {
+	lines := parseLines(strings.Join([]string{
+		" class Cache:",
+		"     def get(self, key):",
+		"-        return self.data[key]",
+		"+        return self.data.get(key)",
+		"-    def stale(self):",
+		"-        pass",
+		"+    def fresh(self):",
+		"+        pass",
+		"     def size(self):",
+		"         return len(self.data)",
+	}, "\n"))
+
+	got := heuristicSymbols(lines)
+	expected := []SymbolChange{
+		{Kind: "func", Name: "get", Change: "modified"},
+		{Kind: "func", Name: "stale", Change: "removed"},
+		{Kind: "func", Name: "fresh", Change: "added"},
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("Expected %d symbols, got %d: %+v", len(expected), len(got), got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("Expected %+v, got %+v", expected[i], got[i])
+		}
+	}
+}
+
+func TestChangedSymbols(t *testing.T) {
+	parent := commitFiles(t, map[string]string{
+		"server.go": semanticBefore,
+		"cache.py":  "class Cache:\n    def get(self, key):\n        return None\n",
+		"notes.txt": "old\n",
+	})
+	c := commitFiles(t, map[string]string{
+		"server.go": strings.Replace(semanticBefore, "func legacy() {}\n", "", 1),
+		"cache.py":  "class Cache:\n    def get(self, key):\n        return self.data.get(key)\n",
+		"notes.txt": "new\n",
+	})
+
+	files, err := ChangedSymbols(c, parent, []string{"cache.py", "notes.txt", "server.go"})
+	if err != nil {
+		t.Fatalf("ChangedSymbols failed: %v", err)
+	}
+
+	expected := "cache.py: func get (modified)\nserver.go: func legacy (removed)\n"
+	if got := FormatChangedSymbols(files); got != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, got)
+	}
+
+	files, err = ChangedSymbols(c, parent, []string{"notes.txt"})
+	if err != nil {
+		t.Fatalf("ChangedSymbols failed: %v", err)
+	}
+	if len(files) != 0 {
+		t.Errorf("Expected no symbols for unselected files, got %+v", files)
+	}
+}
+
+func TestFormatChangedSymbolsCap(t *testing.T) {
+	var symbols []SymbolChange
+	for i := 0; i < maxListedSymbols+5; i++ {
+		symbols = append(symbols, SymbolChange{Kind: "func", Name: "f", Change: "added"})
+	}
+	got := FormatChangedSymbols([]FileSymbols{{Path: "a.go", Symbols: symbols}, {Path: "b.go", Symbols: symbols[:1]}})
+	if !strings.HasSuffix(got, "... and 6 more\n") {
+		t.Errorf("Expected overflow marker, got:\n%s", got)
+	}
+	if strings.Contains(got, "b.go") {
+		t.Errorf("Expected files past the cap to be omitted, got:\n%s", got)
+	}
+	if FormatChangedSymbols(nil) != "" {
+		t.Error("Expected empty string for no symbols")
+	}
+}
@@ -0,0 +1,219 @@
+package gitdiff
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// Bounds of the languages listed in a TechStack
+const (
+	maxStackLanguages   = 4
+	minLanguageFraction = 0.05
+)
+
+// maxManifestSize bounds the manifest contents searched for frameworks
+const maxManifestSize = 256 << 10
+
+// languageExtensions maps file extensions to the language they are
+// written in. Markup, data, and documentation files are not counted.
+var languageExtensions = map[string]string{
+	".go": "Go", ".py": "Python", ".pyi": "Python",
+	".js": "JavaScript", ".jsx": "JavaScript", ".mjs": "JavaScript", ".cjs": "JavaScript",
+	".ts": "TypeScript", ".tsx": "TypeScript", ".mts": "TypeScript", ".cts": "TypeScript",
+	".java": "Java", ".kt": "Kotlin", ".kts": "Kotlin", ".scala": "Scala", ".groovy": "Groovy",
+	".c": "C", ".h": "C", ".cc": "C++", ".cpp": "C++", ".cxx": "C++", ".hpp": "C++", ".hh": "C++",
+	".cs": "C#", ".fs": "F#", ".rs": "Rust", ".swift": "Swift", ".m": "Objective-C", ".mm": "Objective-C",
+	".rb": "Ruby", ".php": "PHP", ".pl": "Perl", ".pm": "Perl", ".lua": "Lua",
+	".ex": "Elixir", ".exs": "Elixir", ".erl": "Erlang", ".hs": "Haskell", ".ml": "OCaml",
+	".clj": "Clojure", ".cljs": "Clojure", ".dart": "Dart", ".zig": "Zig", ".nim": "Nim",
+	".jl": "Julia", ".r": "R", ".sh": "Shell", ".bash": "Shell", ".sql": "SQL",
+	".vue": "Vue", ".svelte": "Svelte", ".sol": "Solidity", ".tf": "Terraform",
+	".proto": "Protocol Buffers",
+}
+
+// frameworkMarker names a framework whose manifest entry contains marker
+type frameworkMarker struct {
+	marker, name string
+}
+
+// frameworkManifests are the markers searched for in each manifest, in
+// the order frameworks are listed. Markers are matched case-insensitively.
+var frameworkManifests = map[string][]frameworkMarker{
+	"go.mod": {
+		{"github.com/gin-gonic/gin", "Gin"}, {"github.com/labstack/echo", "Echo"},
+		{"github.com/gofiber/fiber", "Fiber"}, {"github.com/go-chi/chi", "chi"},
+		{"google.golang.org/grpc", "gRPC"}, {"sigs.k8s.io/controller-runtime", "controller-runtime"},
+		{"k8s.io/client-go", "Kubernetes client-go"}, {"gorm.io/gorm", "GORM"},
+		{"github.com/spf13/cobra", "Cobra"},
+	},
+	"package.json": {
+		{`"next"`, "Next.js"}, {`"react-native"`, "React Native"}, {`"react"`, "React"},
+		{`"nuxt"`, "Nuxt"}, {`"vue"`, "Vue"}, {`"@angular/core"`, "Angular"},
+		{`"svelte"`, "Svelte"}, {`"@nestjs/core"`, "NestJS"}, {`"express"`, "Express"},
+		{`"fastify"`, "Fastify"}, {`"electron"`, "Electron"},
+	},
+	"pom.xml":          jvmFrameworks,
+	"build.gradle":     jvmFrameworks,
+	"build.gradle.kts": jvmFrameworks,
+	"pyproject.toml":   pythonFrameworks,
+	"requirements.txt": pythonFrameworks,
+	"setup.py":         pythonFrameworks,
+	"setup.cfg":        pythonFrameworks,
+	"Pipfile":          pythonFrameworks,
+	"Gemfile":          {{"rails", "Rails"}, {"sinatra", "Sinatra"}},
+	"Cargo.toml": {
+		{"tokio", "Tokio"}, {"actix-web", "Actix Web"}, {"axum", "Axum"}, {"bevy", "Bevy"},
+	},
+	"composer.json": {{"laravel/framework", "Laravel"}, {"symfony/", "Symfony"}},
+	"mix.exs":       {{":phoenix", "Phoenix"}},
+	"pubspec.yaml":  {{"flutter", "Flutter"}},
+}
+
+var jvmFrameworks = []frameworkMarker{
+	{"spring-boot", "Spring Boot"}, {"io.quarkus", "Quarkus"}, {"io.micronaut", "Micronaut"},
+	{"com.android", "Android"}, {"ktor", "Ktor"}, {"akka", "Akka"},
+}
+
+var pythonFrameworks = []frameworkMarker{
+	{"django", "Django"}, {"flask", "Flask"}, {"fastapi", "FastAPI"}, {"torch", "PyTorch"},
+	{"tensorflow", "TensorFlow"}, {"sqlalchemy", "SQLAlchemy"}, {"celery", "Celery"},
+	{"pandas", "pandas"},
+}
+
+// LanguageShare is a language's fraction of a repository's source files
+type LanguageShare struct {
+	Name     string  `json:"name"`
+	Fraction float64 `json:"fraction"`
+}
+
+// TechStack is a repository's dominant languages and frameworks, which
+// tell the LLM the idioms and failure modes of code it may rarely see
+type TechStack struct {
+	// Languages are the most common languages of the source files, most
+	// common first
+	Languages []LanguageShare `json:"languages,omitempty"`
+
+	// Frameworks are those the manifests at the root and in top-level
+	// directories depend on, such as Django in requirements.txt
+	Frameworks []string `json:"frameworks,omitempty"`
+}
+
+// DetectTechStack finds the dominant languages of tree from its file
+// extensions, leaving out vendored and lock files, and its frameworks from
+// its manifests. It returns nil if tree has neither.
+func DetectTechStack(tree *object.Tree) (*TechStack, error) {
+	if tree == nil {
+		return nil, nil
+	}
+	counts := map[string]int{}
+	total := 0
+	walker := object.NewTreeWalker(tree, true, nil)
+	defer walker.Close()
+	for {
+		name, entry, err := walker.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("walking tree: %w", err)
+		}
+		if !entry.Mode.IsFile() || ignoreRule(name, true) != "" {
+			continue
+		}
+		if lang := languageExtensions[strings.ToLower(path.Ext(name))]; lang != "" {
+			counts[lang]++
+			total++
+		}
+	}
+
+	stack := &TechStack{}
+	for lang, n := range counts {
+		if fraction := float64(n) / float64(total); fraction >= minLanguageFraction {
+			stack.Languages = append(stack.Languages, LanguageShare{Name: lang, Fraction: fraction})
+		}
+	}
+	sort.Slice(stack.Languages, func(i, j int) bool {
+		a, b := stack.Languages[i], stack.Languages[j]
+		if a.Fraction != b.Fraction {
+			return a.Fraction > b.Fraction
+		}
+		return a.Name < b.Name
+	})
+	if len(stack.Languages) > maxStackLanguages {
+		stack.Languages = stack.Languages[:maxStackLanguages]
+	}
+	stack.Frameworks = detectFrameworks(tree)
+
+	if len(stack.Languages) == 0 && len(stack.Frameworks) == 0 {
+		return nil, nil
+	}
+	return stack, nil
+}
+
+// detectFrameworks searches the manifests at the root of tree and in its
+// top-level directories for frameworkManifests' markers
+func detectFrameworks(tree *object.Tree) []string {
+	var frameworks []string
+	seen := map[string]bool{}
+	search := func(t *object.Tree) {
+		for _, entry := range t.Entries {
+			markers := frameworkManifests[entry.Name]
+			if markers == nil || !entry.Mode.IsFile() {
+				continue
+			}
+			f, err := t.TreeEntryFile(&entry)
+			if err != nil || f.Size > maxManifestSize {
+				continue
+			}
+			content, err := f.Contents()
+			if err != nil {
+				continue
+			}
+			content = strings.ToLower(content)
+			for _, m := range markers {
+				if !seen[m.name] && strings.Contains(content, m.marker) {
+					seen[m.name] = true
+					frameworks = append(frameworks, m.name)
+				}
+			}
+		}
+	}
+
+	search(tree)
+	for _, entry := range tree.Entries {
+		if entry.Mode.IsFile() || ignoreRule(entry.Name+"/", true) != "" {
+			continue
+		}
+		if sub, err := tree.Tree(entry.Name); err == nil {
+			search(sub)
+		}
+	}
+	return frameworks
+}
+
+// String formats s for the prompt, such as "Languages: Go (80%), Shell
+// (20%)" and "Frameworks: gRPC, Cobra" on separate lines. A nil TechStack
+// is "".
+func (s *TechStack) String() string {
+	if s == nil {
+		return ""
+	}
+	var b strings.Builder
+	if len(s.Languages) > 0 {
+		langs := make([]string, len(s.Languages))
+		for i, l := range s.Languages {
+			langs[i] = fmt.Sprintf("%s (%.0f%%)", l.Name, l.Fraction*100)
+		}
+		fmt.Fprintf(&b, "Languages: %s\n", strings.Join(langs, ", "))
+	}
+	if len(s.Frameworks) > 0 {
+		fmt.Fprintf(&b, "Frameworks: %s\n", strings.Join(s.Frameworks, ", "))
+	}
+	return b.String()
+}
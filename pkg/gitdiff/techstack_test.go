@@ -0,0 +1,61 @@
+package gitdiff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDetectTechStack(t *testing.T) {
+	files := map[string]string{
+		"go.mod":                  "module x\n\nrequire (\n\tgoogle.golang.org/grpc v1.60.0\n\tgithub.com/spf13/cobra v1.8.0\n)\n",
+		"web/package.json":        "{\"dependencies\": {\"react\": \"^18.0.0\"}}\n",
+		"web/node_modules/a/a.js": "x\n",
+		"web/node_modules/b/b.js": "x\n",
+		"web/node_modules/c/c.js": "x\n",
+		"web/app.tsx":             "export {}\n",
+		"README.md":               "# x\n",
+		"scripts/build.sh":        "#!/bin/sh\n",
+	}
+	for _, name := range []string{"a", "b", "c", "d", "e", "f"} {
+		files["pkg/"+name+".go"] = "package pkg\n"
+	}
+	files["pkg/a_test.go"] = "package pkg\n"
+	tree, err := commitFiles(t, files).Tree()
+	if err != nil {
+		t.Fatalf("Failed to get tree: %v", err)
+	}
+
+	stack, err := DetectTechStack(tree)
+	if err != nil {
+		t.Fatalf("DetectTechStack failed: %v", err)
+	}
+	// node_modules is vendored; README.md is not source
+	expected := &TechStack{
+		Languages: []LanguageShare{
+			{Name: "Go", Fraction: 7.0 / 9},
+			{Name: "Shell", Fraction: 1.0 / 9},
+			{Name: "TypeScript", Fraction: 1.0 / 9},
+		},
+		Frameworks: []string{"gRPC", "Cobra", "React"},
+	}
+	if !reflect.DeepEqual(stack, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, stack)
+	}
+	if got, want := stack.String(), "Languages: Go (78%), Shell (11%), TypeScript (11%)\nFrameworks: gRPC, Cobra, React\n"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestDetectTechStackNothing(t *testing.T) {
+	tree, err := commitFiles(t, map[string]string{"README.md": "# x\n", "go.sum": "x\n"}).Tree()
+	if err != nil {
+		t.Fatalf("Failed to get tree: %v", err)
+	}
+	stack, err := DetectTechStack(tree)
+	if err != nil || stack != nil {
+		t.Errorf("Expected no tech stack, got %+v (%v)", stack, err)
+	}
+	if s := stack.String(); s != "" {
+		t.Errorf("Expected a nil tech stack to format as empty, got %q", s)
+	}
+}
@@ -0,0 +1,173 @@
+package gitdiff
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// maxAffectedTests bounds the test files listed for a commit
+const maxAffectedTests = 20
+
+// testNameRe matches the test file naming conventions of common
+// languages; the subject submatch is the name of the file under test
+var testNameRe = []*regexp.Regexp{
+	regexp.MustCompile(`^(?P<subject>.+)_test\.(go|py)$`),
+	regexp.MustCompile(`^test_(?P<subject>.+)\.py$`),
+	regexp.MustCompile(`^(?P<subject>.+)\.(test|spec)\.[cm]?[jt]sx?$`),
+	regexp.MustCompile(`^(?P<subject>.+)_spec\.rb$`),
+	regexp.MustCompile(`^(?P<subject>[A-Z]\w*?)(Tests?|Spec|IT)\.(java|kt|scala|groovy|cs|php|swift)$`),
+}
+
+// languageFamily groups the languages whose tests may cover each other's
+// files, such as TypeScript tests of JavaScript modules
+var languageFamily = map[string]string{
+	"TypeScript": "JavaScript", "Vue": "JavaScript", "Svelte": "JavaScript",
+	"Kotlin": "Java", "Scala": "Java", "Groovy": "Java",
+}
+
+// testSubject returns the name, without extension, of the file the test
+// file p is named after, such as "token" for token_test.go or
+// "TokenService" for TokenServiceTest.java, or "" if p is not named like
+// a test
+func testSubject(p string) string {
+	base := path.Base(p)
+	for _, re := range testNameRe {
+		if m := re.FindStringSubmatch(base); m != nil {
+			return m[re.SubexpIndex("subject")]
+		}
+	}
+	return ""
+}
+
+// family returns the language family of p's extension ("" if unknown)
+func family(p string) string {
+	lang := languageExtensions[strings.ToLower(path.Ext(p))]
+	if f, ok := languageFamily[lang]; ok {
+		return f
+	}
+	return lang
+}
+
+// TestMap maps source files at HEAD to the test files likely to exercise
+// them. It is read-only once loaded and safe for concurrent use.
+type TestMap struct {
+	goImportIndex
+	bySubject map[string][]string // test files by family and subject, see subjectKey
+	byDir     map[string][]string // Go test files by directory
+}
+
+// subjectKey keys the test files of a language family named after subject
+func subjectKey(lang, subject string) string {
+	return lang + "\x00" + strings.ToLower(subject)
+}
+
+// LoadTestMap indexes the test files of head's tree by the file they are
+// named after and, for Go, by package and the packages they import
+func LoadTestMap(head *object.Commit) (*TestMap, error) {
+	tree, err := head.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get HEAD tree: %w", err)
+	}
+	m := &TestMap{bySubject: map[string][]string{}, byDir: map[string][]string{}}
+	walker := object.NewTreeWalker(tree, true, nil)
+	defer walker.Close()
+	for {
+		name, entry, err := walker.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("walking tree: %w", err)
+		}
+		if !entry.Mode.IsFile() || ignoreRule(name, true) != "" {
+			continue
+		}
+		subject := testSubject(name)
+		if subject == "" {
+			continue
+		}
+		key := subjectKey(family(name), subject)
+		m.bySubject[key] = append(m.bySubject[key], name)
+		if path.Ext(name) == ".go" {
+			m.byDir[path.Dir(name)] = append(m.byDir[path.Dir(name)], name)
+		}
+	}
+	if len(m.byDir) > 0 {
+		if m.goImportIndex, err = loadGoImportIndex(tree); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// For returns the test files at HEAD likely to exercise changes to paths,
+// at most twenty: changed test files themselves, then test files named
+// after a changed file (token_test.go, test_token.py, token.spec.ts,
+// TokenTest.java), nearest first, then for Go the other tests of changed
+// packages, then the tests of packages importing them. A nil TestMap gives
+// nil.
+func (m *TestMap) For(paths []string) []string {
+	if m == nil {
+		return nil
+	}
+	seen := map[string]bool{}
+	var tests []string
+	add := func(files ...string) {
+		for _, f := range files {
+			if !seen[f] && len(tests) < maxAffectedTests {
+				seen[f] = true
+				tests = append(tests, f)
+			}
+		}
+	}
+
+	for _, p := range paths {
+		if testSubject(p) != "" {
+			add(p)
+		}
+	}
+	for _, p := range paths {
+		if testSubject(p) != "" {
+			continue
+		}
+		subject := strings.TrimSuffix(path.Base(p), path.Ext(p))
+		named := append([]string(nil), m.bySubject[subjectKey(family(p), subject)]...)
+		sort.SliceStable(named, func(i, j int) bool {
+			return pathDistance(p, named[i]) < pathDistance(p, named[j])
+		})
+		add(named...)
+	}
+	for _, p := range paths {
+		if path.Ext(p) == ".go" {
+			add(m.byDir[path.Dir(p)]...)
+		}
+	}
+	for _, p := range paths {
+		if pkg := m.goPackage(p); pkg != "" {
+			for _, importer := range m.importers[pkg] {
+				if IsTestFile(importer) {
+					add(importer)
+				}
+			}
+		}
+	}
+	return tests
+}
+
+// pathDistance counts the directories between the files a and b: 0 for
+// files in the same directory
+func pathDistance(a, b string) int {
+	da, db := strings.Split(path.Dir(a), "/"), strings.Split(path.Dir(b), "/")
+	common := 0
+	for common < len(da) && common < len(db) && da[common] == db[common] {
+		common++
+	}
+	return len(da) + len(db) - 2*common
+}
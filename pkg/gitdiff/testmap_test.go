@@ -0,0 +1,72 @@
+package gitdiff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTestSubject(t *testing.T) {
+	tests := map[string]string{
+		"pkg/auth/token_test.go":          "token",
+		"tests/test_token.py":             "token",
+		"app/token_test.py":               "token",
+		"src/token.test.ts":               "token",
+		"src/Token.spec.jsx":              "Token",
+		"spec/models/user_spec.rb":        "user",
+		"src/test/java/TokenTest.java":    "Token",
+		"src/test/kotlin/TokenTests.kt":   "Token",
+		"Tests/TokenServiceTests.cs":      "TokenService",
+		"pkg/auth/token.go":               "",
+		"src/Test.java":                   "",
+		"docs/testing.md":                 "",
+		"src/main/java/TokenService.java": "",
+	}
+	for p, want := range tests {
+		if got := testSubject(p); got != want {
+			t.Errorf("testSubject(%q) = %q, want %q", p, got, want)
+		}
+	}
+}
+
+func TestLoadTestMap(t *testing.T) {
+	commits := commitHistory(t, map[string]string{
+		"go.mod":                 "module example.com/app\n",
+		"auth/token.go":          "package auth\n",
+		"auth/session.go":        "package auth\n",
+		"auth/token_test.go":     "package auth\n",
+		"auth/session_test.go":   "package auth\n",
+		"api/api.go":             "package api\n\nimport \"example.com/app/auth\"\n",
+		"api/api_test.go":        "package api\n\nimport \"example.com/app/auth\"\n",
+		"web/src/token.ts":       "export {}\n",
+		"web/src/token.test.ts":  "import './token'\n",
+		"web/e2e/token.spec.js":  "\n",
+		"vendor/x/token_test.go": "package x\n",
+		"scripts/token.py":       "\n",
+	})
+
+	m, err := LoadTestMap(commits[0])
+	if err != nil {
+		t.Fatalf("LoadTestMap failed: %v", err)
+	}
+
+	got := m.For([]string{"auth/token.go"})
+	expected := []string{"auth/token_test.go", "auth/session_test.go", "api/api_test.go"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+
+	got = m.For([]string{"web/src/token.ts"})
+	expected = []string{"web/src/token.test.ts", "web/e2e/token.spec.js"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected the nearest test first, got %v", got)
+	}
+
+	if got := m.For([]string{"scripts/token.py"}); got != nil {
+		t.Errorf("Expected no tests of another language, got %v", got)
+	}
+
+	var none *TestMap
+	if got := none.For([]string{"auth/token.go"}); got != nil {
+		t.Errorf("Expected nil TestMap to give nil, got %v", got)
+	}
+}
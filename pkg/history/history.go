@@ -0,0 +1,434 @@
+// Package history persists analysis runs in a local SQLite database.
+//
+// Every run records the repository, a fingerprint of the error message, and
+// one verdict per analyzed commit (probability, reasoning, model, and token
+// cost). Stored verdicts enable trend analysis across runs and let callers
+// skip re-analyzing a commit whose verdict for the same error is already known.
+package history
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite" // registers the "sqlite" driver
+)
+
+// ErrRunNotFound is returned when a run ID is unknown
+var ErrRunNotFound = errors.New("run not found")
+
+const schema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id            TEXT PRIMARY KEY,
+	repo          TEXT NOT NULL,
+	branch        TEXT NOT NULL DEFAULT '',
+	error_message TEXT NOT NULL,
+	fingerprint   TEXT NOT NULL,
+	model         TEXT NOT NULL,
+	total         INTEGER NOT NULL DEFAULT 0,
+	high          INTEGER NOT NULL DEFAULT 0,
+	medium        INTEGER NOT NULL DEFAULT 0,
+	low           INTEGER NOT NULL DEFAULT 0,
+	skipped       INTEGER NOT NULL DEFAULT 0,
+	errors        INTEGER NOT NULL DEFAULT 0,
+	duration      TEXT NOT NULL DEFAULT '',
+	created_at    TIMESTAMP NOT NULL
+);
+CREATE TABLE IF NOT EXISTS verdicts (
+	id            INTEGER PRIMARY KEY AUTOINCREMENT,
+	run_id        TEXT NOT NULL REFERENCES runs(id),
+	repo          TEXT NOT NULL,
+	fingerprint   TEXT NOT NULL,
+	commit_hash   TEXT NOT NULL,
+	message       TEXT NOT NULL DEFAULT '',
+	probability   TEXT NOT NULL,
+	reasoning     TEXT NOT NULL DEFAULT '',
+	model         TEXT NOT NULL,
+	prompt_tokens INTEGER NOT NULL DEFAULT 0,
+	output_tokens INTEGER NOT NULL DEFAULT 0,
+	created_at    TIMESTAMP NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_verdicts_lookup ON verdicts(repo, fingerprint, commit_hash);
+CREATE INDEX IF NOT EXISTS idx_runs_created ON runs(created_at);
+`
+
+// Run is a single analysis invocation
+type Run struct {
+	ID           string    `json:"id"`
+	Repo         string    `json:"repo"`
+	Branch       string    `json:"branch,omitempty"`
+	ErrorMessage string    `json:"error_message"`
+	Fingerprint  string    `json:"fingerprint"`
+	Model        string    `json:"model"`
+	Total        int       `json:"total"`
+	High         int       `json:"high"`
+	Medium       int       `json:"medium"`
+	Low          int       `json:"low"`
+	Skipped      int       `json:"skipped"`
+	Errors       int       `json:"errors"`
+	Duration     string    `json:"duration"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Verdict is the stored outcome for one commit within a run
+type Verdict struct {
+	RunID        string    `json:"run_id"`
+	Repo         string    `json:"repo"`
+	Fingerprint  string    `json:"fingerprint"`
+	Commit       string    `json:"commit"`
+	Message      string    `json:"message,omitempty"`
+	Probability  string    `json:"probability"`
+	Reasoning    string    `json:"reasoning"`
+	Model        string    `json:"model"`
+	PromptTokens int       `json:"prompt_tokens"`
+	OutputTokens int       `json:"output_tokens"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Filter restricts the verdicts returned by Query. Zero fields match anything.
+type Filter struct {
+	Repo        string
+	Fingerprint string
+	Commit      string // full hash or prefix
+	Probability string
+	Since       time.Time
+	Limit       int
+}
+
+// Store is a SQLite-backed history database
+type Store struct {
+	db *sql.DB
+}
+
+// Open opens (creating if necessary) the history database at path.
+// A leading "~/" is expanded to the user's home directory.
+func Open(path string) (*Store, error) {
+	if path == "" {
+		return nil, fmt.Errorf("history path cannot be empty")
+	}
+	if strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get home directory: %w", err)
+		}
+		path = filepath.Join(home, path[2:])
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	// Store timestamps in SQLite's native format so date functions work
+	db, err := sql.Open("sqlite", "file:"+path+"?_time_format=sqlite")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history database: %w", err)
+	}
+	// SQLite allows a single writer; serialize access through one connection
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize history schema: %w", err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the underlying database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// volatileRegex matches error message fragments that differ between
+// occurrences of the same bug (pointer addresses, goroutine IDs)
+var volatileRegex = regexp.MustCompile(`0x[0-9a-fA-F]+|goroutine \d+`)
+
+// Fingerprint returns a stable identifier for an error message so that
+// repeated reports of the same bug map to the same stored verdicts.
+// Case, whitespace, and pointer addresses are normalized away.
+func Fingerprint(errorMsg string) string {
+	normalized := volatileRegex.ReplaceAllString(errorMsg, "#")
+	normalized = strings.Join(strings.Fields(strings.ToLower(normalized)), " ")
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:8])
+}
+
+// NewRunID returns a random 16-character hex run identifier
+func NewRunID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().UTC().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(b)
+}
+
+// SaveRun stores a run and its verdicts in a single transaction.
+// Verdicts inherit the run's ID, repo, and fingerprint; their Model and
+// CreatedAt default to the run's values when unset.
+func (s *Store) SaveRun(run Run, verdicts []Verdict) error {
+	if run.CreatedAt.IsZero() {
+		run.CreatedAt = time.Now()
+	}
+	run.CreatedAt = run.CreatedAt.UTC()
+	if run.Fingerprint == "" {
+		run.Fingerprint = Fingerprint(run.ErrorMessage)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO runs (id, repo, branch, error_message, fingerprint, model,
+		total, high, medium, low, skipped, errors, duration, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		run.ID, run.Repo, run.Branch, run.ErrorMessage, run.Fingerprint, run.Model,
+		run.Total, run.High, run.Medium, run.Low, run.Skipped, run.Errors, run.Duration, run.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert run: %w", err)
+	}
+
+	stmt, err := tx.Prepare(`INSERT INTO verdicts (run_id, repo, fingerprint, commit_hash, message,
+		probability, reasoning, model, prompt_tokens, output_tokens, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare verdict insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, v := range verdicts {
+		if v.CreatedAt.IsZero() {
+			v.CreatedAt = run.CreatedAt
+		}
+		v.CreatedAt = v.CreatedAt.UTC()
+		if v.Model == "" {
+			v.Model = run.Model
+		}
+		if _, err := stmt.Exec(run.ID, run.Repo, run.Fingerprint, v.Commit, v.Message,
+			v.Probability, v.Reasoning, v.Model, v.PromptTokens, v.OutputTokens, v.CreatedAt); err != nil {
+			return fmt.Errorf("failed to insert verdict for %s: %w", v.Commit, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ListRuns returns the most recent runs, newest first
+func (s *Store) ListRuns(limit int) ([]Run, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	rows, err := s.db.Query(`SELECT id, repo, branch, error_message, fingerprint, model,
+		total, high, medium, low, skipped, errors, duration, created_at
+		FROM runs ORDER BY created_at DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []Run
+	for rows.Next() {
+		var r Run
+		if err := rows.Scan(&r.ID, &r.Repo, &r.Branch, &r.ErrorMessage, &r.Fingerprint, &r.Model,
+			&r.Total, &r.High, &r.Medium, &r.Low, &r.Skipped, &r.Errors, &r.Duration, &r.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan run: %w", err)
+		}
+		runs = append(runs, r)
+	}
+	return runs, rows.Err()
+}
+
+// GetRun returns a run and its verdicts
+func (s *Store) GetRun(id string) (*Run, []Verdict, error) {
+	var r Run
+	err := s.db.QueryRow(`SELECT id, repo, branch, error_message, fingerprint, model,
+		total, high, medium, low, skipped, errors, duration, created_at
+		FROM runs WHERE id = ?`, id).Scan(&r.ID, &r.Repo, &r.Branch, &r.ErrorMessage, &r.Fingerprint, &r.Model,
+		&r.Total, &r.High, &r.Medium, &r.Low, &r.Skipped, &r.Errors, &r.Duration, &r.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, ErrRunNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get run: %w", err)
+	}
+
+	verdicts, err := s.queryVerdicts(`WHERE run_id = ? ORDER BY id`, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &r, verdicts, nil
+}
+
+// Query returns verdicts matching the filter, newest first
+func (s *Store) Query(f Filter) ([]Verdict, error) {
+	var conds []string
+	var args []interface{}
+	if f.Repo != "" {
+		conds = append(conds, "repo = ?")
+		args = append(args, f.Repo)
+	}
+	if f.Fingerprint != "" {
+		conds = append(conds, "fingerprint = ?")
+		args = append(args, f.Fingerprint)
+	}
+	if f.Commit != "" {
+		conds = append(conds, "commit_hash LIKE ?")
+		args = append(args, f.Commit+"%")
+	}
+	if f.Probability != "" {
+		conds = append(conds, "probability = ?")
+		args = append(args, strings.ToUpper(f.Probability))
+	}
+	if !f.Since.IsZero() {
+		conds = append(conds, "created_at >= ?")
+		args = append(args, f.Since.UTC())
+	}
+
+	clause := ""
+	if len(conds) > 0 {
+		clause = "WHERE " + strings.Join(conds, " AND ")
+	}
+	clause += " ORDER BY created_at DESC, id DESC"
+	if f.Limit > 0 {
+		clause += " LIMIT ?"
+		args = append(args, f.Limit)
+	}
+	return s.queryVerdicts(clause, args...)
+}
+
+// Lookup returns the most recent verdict for a commit of a repository and
+// error fingerprint produced by the given model, if one is stored
+func (s *Store) Lookup(repo, fingerprint, commit, model string) (*Verdict, bool, error) {
+	verdicts, err := s.queryVerdicts(`WHERE repo = ? AND fingerprint = ? AND commit_hash = ? AND model = ?
+		ORDER BY created_at DESC, id DESC LIMIT 1`, repo, fingerprint, commit, model)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(verdicts) == 0 {
+		return nil, false, nil
+	}
+	return &verdicts[0], true, nil
+}
+
+func (s *Store) queryVerdicts(clause string, args ...interface{}) ([]Verdict, error) {
+	rows, err := s.db.Query(`SELECT run_id, repo, fingerprint, commit_hash, message, probability,
+		reasoning, model, prompt_tokens, output_tokens, created_at FROM verdicts `+clause, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query verdicts: %w", err)
+	}
+	defer rows.Close()
+
+	var verdicts []Verdict
+	for rows.Next() {
+		var v Verdict
+		if err := rows.Scan(&v.RunID, &v.Repo, &v.Fingerprint, &v.Commit, &v.Message, &v.Probability,
+			&v.Reasoning, &v.Model, &v.PromptTokens, &v.OutputTokens, &v.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan verdict: %w", err)
+		}
+		verdicts = append(verdicts, v)
+	}
+	return verdicts, rows.Err()
+}
+
+// DailyCost aggregates token usage for one calendar day (UTC)
+type DailyCost struct {
+	Day          string `json:"day"`
+	Runs         int    `json:"runs"`
+	Verdicts     int    `json:"verdicts"`
+	PromptTokens int    `json:"prompt_tokens"`
+	OutputTokens int    `json:"output_tokens"`
+}
+
+// CostByDay returns token usage per day for verdicts recorded since the
+// given time, oldest day first
+func (s *Store) CostByDay(since time.Time) ([]DailyCost, error) {
+	rows, err := s.db.Query(`SELECT strftime('%Y-%m-%d', created_at) AS day, COUNT(DISTINCT run_id), COUNT(*),
+		COALESCE(SUM(prompt_tokens), 0), COALESCE(SUM(output_tokens), 0)
+		FROM verdicts WHERE created_at >= ? GROUP BY day ORDER BY day`, since.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate cost: %w", err)
+	}
+	defer rows.Close()
+
+	var days []DailyCost
+	for rows.Next() {
+		var d DailyCost
+		if err := rows.Scan(&d.Day, &d.Runs, &d.Verdicts, &d.PromptTokens, &d.OutputTokens); err != nil {
+			return nil, fmt.Errorf("failed to scan cost: %w", err)
+		}
+		days = append(days, d)
+	}
+	return days, rows.Err()
+}
+
+// Suspect is a commit that received HIGH or MEDIUM verdicts
+type Suspect struct {
+	Repo        string    `json:"repo"`
+	Commit      string    `json:"commit"`
+	Message     string    `json:"message,omitempty"`
+	High        int       `json:"high"`
+	Medium      int       `json:"medium"`
+	LastSeen    time.Time `json:"last_seen"`
+	LastVerdict string    `json:"last_reasoning"`
+}
+
+// Suspects returns commits with HIGH or MEDIUM verdicts, most frequently
+// flagged first. An empty repo matches all repositories.
+func (s *Store) Suspects(repo string, limit int) ([]Suspect, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+	rows, err := s.db.Query(`SELECT repo, commit_hash, MAX(message),
+		SUM(CASE WHEN probability = 'HIGH' THEN 1 ELSE 0 END),
+		SUM(CASE WHEN probability = 'MEDIUM' THEN 1 ELSE 0 END),
+		MAX(created_at)
+		FROM verdicts
+		WHERE probability IN ('HIGH', 'MEDIUM') AND (? = '' OR repo = ?)
+		GROUP BY repo, commit_hash
+		ORDER BY 4 DESC, 5 DESC, 6 DESC
+		LIMIT ?`, repo, repo, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query suspects: %w", err)
+	}
+	defer rows.Close()
+
+	var suspects []Suspect
+	for rows.Next() {
+		var sp Suspect
+		var lastSeen string
+		if err := rows.Scan(&sp.Repo, &sp.Commit, &sp.Message, &sp.High, &sp.Medium, &lastSeen); err != nil {
+			return nil, fmt.Errorf("failed to scan suspect: %w", err)
+		}
+		sp.LastSeen, _ = parseTimestamp(lastSeen)
+		suspects = append(suspects, sp)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range suspects {
+		latest, err := s.Query(Filter{Repo: suspects[i].Repo, Commit: suspects[i].Commit, Limit: 1})
+		if err == nil && len(latest) > 0 {
+			suspects[i].LastVerdict = latest[0].Reasoning
+		}
+	}
+	return suspects, nil
+}
+
+// parseTimestamp parses timestamps returned by SQLite aggregate functions,
+// which lose the column's time type
+func parseTimestamp(s string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02 15:04:05.999999999-07:00", time.RFC3339Nano, "2006-01-02 15:04:05"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized timestamp %q", s)
+}
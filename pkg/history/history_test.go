@@ -0,0 +1,201 @@
+package history
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTestStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := Open(filepath.Join(t.TempDir(), "nested", "history.db"))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestFingerprint(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		same bool
+	}{
+		{"identical", "nil pointer dereference", "nil pointer dereference", true},
+		{"case and whitespace", "Nil  Pointer\tdereference", "nil pointer dereference", true},
+		{"pointer addresses", "invalid memory address 0xc000123456", "invalid memory address 0xc000999999", true},
+		{"goroutine IDs", "panic in goroutine 17", "panic in goroutine 42", true},
+		{"different values", "interval must be > 0, got -2", "interval must be > 0, got -3", false},
+		{"different errors", "timeout", "connection refused", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Fingerprint(tt.a) == Fingerprint(tt.b)
+			if got != tt.same {
+				t.Errorf("Fingerprint(%q) == Fingerprint(%q) = %v, expected %v", tt.a, tt.b, got, tt.same)
+			}
+		})
+	}
+
+	if len(Fingerprint("x")) != 16 {
+		t.Errorf("Expected 16-character fingerprint, got %q", Fingerprint("x"))
+	}
+}
+
+func TestSaveAndGetRun(t *testing.T) {
+	s := openTestStore(t)
+
+	run := Run{
+		ID:           "run1",
+		Repo:         "/src/app",
+		ErrorMessage: "nil pointer",
+		Model:        "gemini-flash-latest",
+		Total:        2,
+		High:         1,
+		Low:          1,
+	}
+	verdicts := []Verdict{
+		{Commit: "aaaa1111", Probability: "HIGH", Reasoning: "guard removed", PromptTokens: 1200, OutputTokens: 80},
+		{Commit: "bbbb2222", Probability: "LOW", Reasoning: "docs only"},
+	}
+	if err := s.SaveRun(run, verdicts); err != nil {
+		t.Fatalf("SaveRun failed: %v", err)
+	}
+
+	got, gotVerdicts, err := s.GetRun("run1")
+	if err != nil {
+		t.Fatalf("GetRun failed: %v", err)
+	}
+	if got.Fingerprint != Fingerprint("nil pointer") {
+		t.Errorf("Expected fingerprint to be derived from error message, got %q", got.Fingerprint)
+	}
+	if got.High != 1 || got.Total != 2 {
+		t.Errorf("Unexpected run counts: %+v", got)
+	}
+	if len(gotVerdicts) != 2 {
+		t.Fatalf("Expected 2 verdicts, got %d", len(gotVerdicts))
+	}
+	if gotVerdicts[0].Commit != "aaaa1111" || gotVerdicts[0].PromptTokens != 1200 || gotVerdicts[0].Model != run.Model {
+		t.Errorf("Unexpected verdict: %+v", gotVerdicts[0])
+	}
+
+	if _, _, err := s.GetRun("missing"); err != ErrRunNotFound {
+		t.Errorf("Expected ErrRunNotFound, got %v", err)
+	}
+}
+
+func TestListRunsNewestFirst(t *testing.T) {
+	s := openTestStore(t)
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i, id := range []string{"old", "new"} {
+		if err := s.SaveRun(Run{ID: id, Repo: "r", ErrorMessage: "e", Model: "m", CreatedAt: base.Add(time.Duration(i) * time.Hour)}, nil); err != nil {
+			t.Fatalf("SaveRun failed: %v", err)
+		}
+	}
+
+	runs, err := s.ListRuns(10)
+	if err != nil {
+		t.Fatalf("ListRuns failed: %v", err)
+	}
+	if len(runs) != 2 || runs[0].ID != "new" {
+		t.Errorf("Expected newest run first, got %+v", runs)
+	}
+}
+
+func TestQueryAndLookup(t *testing.T) {
+	s := openTestStore(t)
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	if err := s.SaveRun(Run{ID: "r1", Repo: "repoA", ErrorMessage: "boom", Model: "m1", CreatedAt: base},
+		[]Verdict{{Commit: "abc123", Probability: "LOW"}, {Commit: "def456", Probability: "HIGH"}}); err != nil {
+		t.Fatalf("SaveRun failed: %v", err)
+	}
+	if err := s.SaveRun(Run{ID: "r2", Repo: "repoA", ErrorMessage: "boom", Model: "m1", CreatedAt: base.Add(time.Hour)},
+		[]Verdict{{Commit: "abc123", Probability: "MEDIUM"}}); err != nil {
+		t.Fatalf("SaveRun failed: %v", err)
+	}
+	if err := s.SaveRun(Run{ID: "r3", Repo: "repoB", ErrorMessage: "other", Model: "m1", CreatedAt: base},
+		[]Verdict{{Commit: "abc123", Probability: "HIGH"}}); err != nil {
+		t.Fatalf("SaveRun failed: %v", err)
+	}
+
+	high, err := s.Query(Filter{Probability: "high"})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(high) != 2 {
+		t.Errorf("Expected 2 HIGH verdicts, got %d", len(high))
+	}
+
+	byCommit, err := s.Query(Filter{Repo: "repoA", Commit: "abc"})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(byCommit) != 2 || byCommit[0].RunID != "r2" {
+		t.Errorf("Expected 2 verdicts newest first, got %+v", byCommit)
+	}
+
+	v, ok, err := s.Lookup("repoA", Fingerprint("boom"), "abc123", "m1")
+	if err != nil || !ok {
+		t.Fatalf("Lookup failed: ok=%v err=%v", ok, err)
+	}
+	if v.Probability != "MEDIUM" {
+		t.Errorf("Expected latest verdict MEDIUM, got %s", v.Probability)
+	}
+
+	if _, ok, _ := s.Lookup("repoA", Fingerprint("boom"), "abc123", "other-model"); ok {
+		t.Error("Expected no verdict for a different model")
+	}
+}
+
+func TestCostByDayAndSuspects(t *testing.T) {
+	s := openTestStore(t)
+	day1 := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	day2 := day1.Add(24 * time.Hour)
+
+	if err := s.SaveRun(Run{ID: "r1", Repo: "repoA", ErrorMessage: "boom", Model: "m", CreatedAt: day1}, []Verdict{
+		{Commit: "abc123", Message: "Remove guard", Probability: "HIGH", Reasoning: "first", PromptTokens: 100, OutputTokens: 10},
+		{Commit: "def456", Probability: "LOW", PromptTokens: 50, OutputTokens: 5},
+	}); err != nil {
+		t.Fatalf("SaveRun failed: %v", err)
+	}
+	if err := s.SaveRun(Run{ID: "r2", Repo: "repoA", ErrorMessage: "boom", Model: "m", CreatedAt: day2}, []Verdict{
+		{Commit: "abc123", Message: "Remove guard", Probability: "HIGH", Reasoning: "second", PromptTokens: 200, OutputTokens: 20},
+		{Commit: "fed789", Probability: "MEDIUM", PromptTokens: 10, OutputTokens: 1},
+	}); err != nil {
+		t.Fatalf("SaveRun failed: %v", err)
+	}
+
+	costs, err := s.CostByDay(day1.Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("CostByDay failed: %v", err)
+	}
+	if len(costs) != 2 {
+		t.Fatalf("Expected 2 days, got %+v", costs)
+	}
+	if costs[0].Day != "2026-01-01" || costs[0].PromptTokens != 150 || costs[0].Verdicts != 2 || costs[0].Runs != 1 {
+		t.Errorf("Unexpected first day: %+v", costs[0])
+	}
+
+	suspects, err := s.Suspects("repoA", 10)
+	if err != nil {
+		t.Fatalf("Suspects failed: %v", err)
+	}
+	if len(suspects) != 2 {
+		t.Fatalf("Expected 2 suspects, got %+v", suspects)
+	}
+	if suspects[0].Commit != "abc123" || suspects[0].High != 2 || suspects[0].LastVerdict != "second" {
+		t.Errorf("Unexpected top suspect: %+v", suspects[0])
+	}
+	if !suspects[0].LastSeen.Equal(day2) {
+		t.Errorf("Expected last seen %v, got %v", day2, suspects[0].LastSeen)
+	}
+
+	none, err := s.Suspects("repoB", 10)
+	if err != nil || len(none) != 0 {
+		t.Errorf("Expected no suspects for repoB, got %+v (err %v)", none, err)
+	}
+}
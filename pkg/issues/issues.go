@@ -0,0 +1,253 @@
+// Package issues resolves the issue references in commit messages, such as
+// "Fixes #123" and "PROJ-42", to the titles of the issues on GitHub and
+// Jira. The title says what a change was meant to do, which a terse
+// commit message often does not.
+//
+// A tracker is only queried once its credentials are configured; without
+// any, a Resolver is nil and resolves nothing.
+package issues
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultGitHubAPI is the API GitHub references are resolved on
+const DefaultGitHubAPI = "https://api.github.com"
+
+// DefaultTimeout bounds each lookup
+const DefaultTimeout = 10 * time.Second
+
+// maxTitleLength bounds the titles returned, which end up in prompts
+const maxTitleLength = 120
+
+// maxResponseSize bounds the tracker responses read
+const maxResponseSize = 1 << 20
+
+var (
+	// githubRemoteRe matches the owner and name of GitHub remote URLs,
+	// such as git@github.com:owner/name.git and https://github.com/owner/name
+	githubRemoteRe = regexp.MustCompile(`github\.com[:/]([\w.-]+)/([\w.-]+?)(?:\.git)?/?$`)
+	// numberRefRe matches "#123" references
+	numberRefRe = regexp.MustCompile(`^#(\d+)$`)
+	// keyRefRe matches Jira keys such as "PROJ-42"
+	keyRefRe = regexp.MustCompile(`^[A-Z][A-Z0-9]+-\d+$`)
+)
+
+// Config names the trackers to query and their credentials
+type Config struct {
+	// GitHubRepo is the "owner/name" repository "#123" references are
+	// resolved in (empty: GitHub is not queried)
+	GitHubRepo string
+
+	// GitHubToken authenticates GitHub requests (empty: GitHub is not
+	// queried)
+	GitHubToken string
+
+	// GitHubAPI is the API URL, for GitHub Enterprise (empty:
+	// DefaultGitHubAPI)
+	GitHubAPI string
+
+	// JiraURL is the Jira site keys are resolved on, such as
+	// https://acme.atlassian.net (empty: Jira is not queried)
+	JiraURL string
+
+	// JiraUser is the Jira Cloud account the token belongs to; without
+	// it the token is sent as a Jira Data Center personal access token
+	JiraUser string
+
+	// JiraToken is the API token or personal access token (empty: Jira is
+	// not queried)
+	JiraToken string
+
+	// Timeout bounds each lookup (0: DefaultTimeout)
+	Timeout time.Duration
+
+	// Client makes the requests (nil: http.DefaultClient)
+	Client *http.Client
+}
+
+// github reports whether GitHub references are resolved
+func (c Config) github() bool {
+	return c.GitHubRepo != "" && c.GitHubToken != ""
+}
+
+// jira reports whether Jira keys are resolved
+func (c Config) jira() bool {
+	return c.JiraURL != "" && c.JiraToken != ""
+}
+
+// GitHubRepo returns the "owner/name" of a GitHub remote URL, or "" for
+// remotes elsewhere
+func GitHubRepo(remoteURL string) string {
+	m := githubRemoteRe.FindStringSubmatch(strings.TrimSpace(remoteURL))
+	if m == nil {
+		return ""
+	}
+	return m[1] + "/" + m[2]
+}
+
+// Resolver looks up issue titles, remembering each reference's title (or
+// failure) for the rest of the run. It is safe for concurrent use, and a
+// nil Resolver resolves nothing.
+type Resolver struct {
+	cfg    Config
+	mu     sync.Mutex
+	lookup map[string]lookup
+}
+
+// lookup is the outcome of resolving one reference
+type lookup struct {
+	title string
+	err   error
+}
+
+// New returns a Resolver for the trackers of cfg, or nil if cfg has the
+// credentials of none
+func New(cfg Config) *Resolver {
+	if !cfg.github() && !cfg.jira() {
+		return nil
+	}
+	if cfg.GitHubAPI == "" {
+		cfg.GitHubAPI = DefaultGitHubAPI
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	return &Resolver{cfg: cfg, lookup: map[string]lookup{}}
+}
+
+// Titles returns the titles of refs, as IssueReferences in the analyzer
+// gives them, keyed by reference. References no configured tracker knows
+// are left out. Failed lookups are left out too and returned together as
+// the error.
+func (r *Resolver) Titles(ctx context.Context, refs []string) (map[string]string, error) {
+	if r == nil {
+		return nil, nil
+	}
+	var titles map[string]string
+	var errs []error
+	for _, ref := range refs {
+		title, err := r.Title(ctx, ref)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if title != "" {
+			if titles == nil {
+				titles = map[string]string{}
+			}
+			titles[ref] = title
+		}
+	}
+	return titles, errors.Join(errs...)
+}
+
+// Title returns the title of the issue ref, such as "#123" or "PROJ-42",
+// or "" if no configured tracker has it
+func (r *Resolver) Title(ctx context.Context, ref string) (string, error) {
+	if r == nil {
+		return "", nil
+	}
+	r.mu.Lock()
+	l, ok := r.lookup[ref]
+	r.mu.Unlock()
+	if ok {
+		return l.title, l.err
+	}
+
+	l.title, l.err = r.fetch(ctx, ref)
+	if ctx.Err() == nil {
+		// A cancelled run's failures say nothing about the tracker
+		r.mu.Lock()
+		r.lookup[ref] = l
+		r.mu.Unlock()
+	}
+	return l.title, l.err
+}
+
+// fetch asks the tracker of ref for its title
+func (r *Resolver) fetch(ctx context.Context, ref string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.Timeout)
+	defer cancel()
+
+	switch {
+	case numberRefRe.MatchString(ref) && r.cfg.github():
+		endpoint := fmt.Sprintf("%s/repos/%s/issues/%s", strings.TrimRight(r.cfg.GitHubAPI, "/"), r.cfg.GitHubRepo, ref[1:])
+		var issue struct {
+			Title string `json:"title"`
+		}
+		err := r.get(ctx, endpoint, "Bearer "+r.cfg.GitHubToken, &issue)
+		if err != nil {
+			return "", fmt.Errorf("GitHub issue %s: %w", ref, err)
+		}
+		return cleanTitle(issue.Title), nil
+	case keyRefRe.MatchString(ref) && r.cfg.jira():
+		endpoint := fmt.Sprintf("%s/rest/api/2/issue/%s?fields=summary", strings.TrimRight(r.cfg.JiraURL, "/"), url.PathEscape(ref))
+		auth := "Bearer " + r.cfg.JiraToken
+		if r.cfg.JiraUser != "" {
+			auth = "Basic " + base64.StdEncoding.EncodeToString([]byte(r.cfg.JiraUser+":"+r.cfg.JiraToken))
+		}
+		var issue struct {
+			Fields struct {
+				Summary string `json:"summary"`
+			} `json:"fields"`
+		}
+		if err := r.get(ctx, endpoint, auth, &issue); err != nil {
+			return "", fmt.Errorf("Jira issue %s: %w", ref, err)
+		}
+		return cleanTitle(issue.Fields.Summary), nil
+	default:
+		return "", nil
+	}
+}
+
+// get decodes the JSON at endpoint into v. Unknown issues are not
+// failures, since "#12" may be an issue of another repository, and leave
+// v unset.
+func (r *Resolver) get(ctx context.Context, endpoint, auth string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", auth)
+	resp, err := r.cfg.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(v); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	return nil
+}
+
+// cleanTitle puts title on one line of at most maxTitleLength characters
+func cleanTitle(title string) string {
+	title = strings.Join(strings.Fields(title), " ")
+	if runes := []rune(title); len(runes) > maxTitleLength {
+		title = string(runes[:maxTitleLength-3]) + "..."
+	}
+	return title
+}
@@ -0,0 +1,95 @@
+package issues
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestGitHubRepo(t *testing.T) {
+	tests := []struct {
+		remote   string
+		expected string
+	}{
+		{"https://github.com/acme/api.git", "acme/api"},
+		{"https://github.com/acme/api", "acme/api"},
+		{"git@github.com:acme/web.app.git", "acme/web.app"},
+		{"ssh://git@github.com/acme/api/", "acme/api"},
+		{"https://gitlab.com/acme/api.git", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := GitHubRepo(tt.remote); got != tt.expected {
+			t.Errorf("GitHubRepo(%q) = %q, expected %q", tt.remote, got, tt.expected)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	if r := New(Config{GitHubRepo: "acme/api"}); r != nil {
+		t.Error("Expected no resolver without a token")
+	}
+	if r := New(Config{JiraToken: "t"}); r != nil {
+		t.Error("Expected no resolver without a Jira URL")
+	}
+	var r *Resolver
+	if titles, err := r.Titles(context.Background(), []string{"#1"}); titles != nil || err != nil {
+		t.Errorf("Expected a nil resolver to resolve nothing, got %v, %v", titles, err)
+	}
+}
+
+func TestResolverTitles(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		switch r.URL.Path {
+		case "/repos/acme/api/issues/12":
+			if r.Header.Get("Authorization") != "Bearer gh-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			io.WriteString(w, `{"number": 12, "title": "Sessions expire\n after 5 minutes"}`)
+		case "/rest/api/2/issue/PROJ-42":
+			if user, pass, ok := r.BasicAuth(); !ok || user != "dana@example.com" || pass != "jira-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			io.WriteString(w, `{"key": "PROJ-42", "fields": {"summary": "`+strings.Repeat("x", 200)+`"}}`)
+		case "/repos/acme/api/issues/13":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	r := New(Config{
+		GitHubRepo: "acme/api", GitHubToken: "gh-token", GitHubAPI: srv.URL,
+		JiraURL: srv.URL + "/", JiraUser: "dana@example.com", JiraToken: "jira-token",
+	})
+	refs := []string{"#12", "PROJ-42", "#99", "#13"}
+	titles, err := r.Titles(context.Background(), refs)
+	expected := map[string]string{
+		"#12":     "Sessions expire after 5 minutes",
+		"PROJ-42": strings.Repeat("x", 117) + "...",
+	}
+	if !reflect.DeepEqual(titles, expected) {
+		t.Errorf("Expected %v, got %v", expected, titles)
+	}
+	if err == nil || !strings.Contains(err.Error(), "GitHub issue #13") {
+		t.Errorf("Expected the failed lookup of #13 reported, got %v", err)
+	}
+
+	// Every outcome is remembered
+	if _, err := r.Titles(context.Background(), refs); err == nil {
+		t.Error("Expected the remembered failure reported again")
+	}
+	if n := calls.Load(); n != 4 {
+		t.Errorf("Expected 4 requests, got %d", n)
+	}
+}
@@ -0,0 +1,123 @@
+// Package network configures outbound HTTPS connections, to git remotes and
+// to the LLM API alike, for corporate networks that route traffic through
+// a proxy or inspect TLS with their own certificate authority.
+//
+// Proxies set in the standard HTTPS_PROXY, HTTP_PROXY, and NO_PROXY
+// environment variables are used without any configuration. Options add an
+// explicit proxy URL, which overrides them, and a CA bundle trusted in
+// addition to the system roots.
+package network
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// Options configures outbound connections; the zero value keeps Go's
+// defaults
+type Options struct {
+	// Proxy is the URL of the proxy for all HTTP(S) connections (default:
+	// from the environment)
+	Proxy string
+
+	// CABundle is a PEM file of certificate authorities to trust in
+	// addition to the system roots
+	CABundle string
+}
+
+// Transport returns a copy of base configured with o
+func Transport(base *http.Transport, o Options) (*http.Transport, error) {
+	t := base.Clone()
+	if o.Proxy != "" {
+		u, err := ParseProxy(o.Proxy)
+		if err != nil {
+			return nil, err
+		}
+		t.Proxy = http.ProxyURL(u)
+	}
+	if o.CABundle != "" {
+		pool, err := loadCABundle(o.CABundle)
+		if err != nil {
+			return nil, err
+		}
+		if t.TLSClientConfig == nil {
+			t.TLSClientConfig = &tls.Config{}
+		}
+		t.TLSClientConfig.RootCAs = pool
+	}
+	return t, nil
+}
+
+// Configure applies o to every HTTP(S) connection the process makes: it
+// replaces http.DefaultTransport, which the Gemini client copies, and the
+// transport go-git clones and fetches over. Call it once at startup,
+// before any connection is made.
+func Configure(o Options) error {
+	if o == (Options{}) {
+		return nil
+	}
+	base, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return fmt.Errorf("http.DefaultTransport is not an *http.Transport")
+	}
+	t, err := Transport(base, o)
+	if err != nil {
+		return err
+	}
+	http.DefaultTransport = t
+	git := githttp.NewClient(&http.Client{Transport: t})
+	client.InstallProtocol("https", git)
+	client.InstallProtocol("http", git)
+	return nil
+}
+
+// ParseProxy parses a proxy URL, which must name an http, https, or
+// socks5 proxy
+func ParseProxy(proxy string) (*url.URL, error) {
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("invalid proxy URL %q: scheme must be http, https, or socks5", proxy)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q: missing host", proxy)
+	}
+	return u, nil
+}
+
+// loadCABundle returns the system roots with the certificates of a PEM
+// file added
+func loadCABundle(path string) (*x509.CertPool, error) {
+	if strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get home directory: %w", err)
+		}
+		path = filepath.Join(home, path[2:])
+	}
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in CA bundle %s", path)
+	}
+	return pool, nil
+}
@@ -0,0 +1,126 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/kerneldump/git-dual-context/pkg/analyzer"
+)
+
+// markdownSink renders the run as a Markdown report when closed
+type markdownSink struct {
+	collector
+	w io.Writer
+}
+
+// NewMarkdown returns a sink writing a Markdown report of the run to w
+// when closed: the suspect commits, most suspicious first, the commits
+// rated LOW, failures, and the summary's counts
+func NewMarkdown(w io.Writer) Sink {
+	return &markdownSink{w: w}
+}
+
+func (s *markdownSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := io.WriteString(s.w, s.render())
+	return err
+}
+
+func (s *markdownSink) render() string {
+	var b strings.Builder
+	b.WriteString("# Root Cause Analysis\n\n")
+
+	if sum := s.summary; sum != nil {
+		if sum.Partial {
+			b.WriteString("> **Partial run:** interrupted before every commit was analyzed.\n\n")
+		}
+		fmt.Fprintf(&b, "| Total | High | Medium | Low | Skipped | Errors | Duration | Model |\n")
+		fmt.Fprintf(&b, "|---|---|---|---|---|---|---|---|\n")
+		fmt.Fprintf(&b, "| %d | %d | %d | %d | %d | %d | %s | %s |\n\n",
+			sum.Total, sum.High, sum.Medium, sum.Low, sum.Skipped, sum.Errors, sum.Duration, markdownCell(sum.Model))
+	}
+
+	suspects := s.suspects()
+	b.WriteString("## Suspects\n\n")
+	if len(suspects) == 0 {
+		b.WriteString("No commit was rated HIGH or MEDIUM.\n\n")
+	}
+	for i, r := range suspects {
+		fmt.Fprintf(&b, "### %d. `%s` — %s\n\n", i+1, rankID(r), r.Probability)
+		if r.Message != "" {
+			fmt.Fprintf(&b, "%s\n\n", markdownQuote(r.Message))
+		}
+		if r.CommitMetadata != nil && r.Author != "" {
+			fmt.Fprintf(&b, "- **Author:** %s\n", r.Author)
+		}
+		if r.Suspicion > 0 {
+			fmt.Fprintf(&b, "- **Suspicion:** %.2f\n", r.Suspicion)
+		}
+		if r.Stats != nil && len(r.Stats.Files) > 0 {
+			paths := make([]string, len(r.Stats.Files))
+			for i, f := range r.Stats.Files {
+				paths[i] = "`" + f.Path + "`"
+			}
+			fmt.Fprintf(&b, "- **Files:** %s\n", strings.Join(paths, ", "))
+		}
+		fmt.Fprintf(&b, "\n%s\n\n", r.Reasoning)
+	}
+
+	var low []analyzer.JSONResult
+	for _, r := range s.results {
+		if r.Probability == analyzer.ProbLow {
+			low = append(low, r)
+		}
+	}
+	if len(low) > 0 {
+		b.WriteString("## Other Commits\n\n| Commit | Message | Reasoning |\n|---|---|---|\n")
+		for _, r := range low {
+			fmt.Fprintf(&b, "| `%s` | %s | %s |\n", rankID(r), markdownCell(r.Message), markdownCell(r.Reasoning))
+		}
+		b.WriteString("\n")
+	}
+
+	if len(s.errors) > 0 {
+		b.WriteString("## Failures\n\n")
+		for _, e := range s.errors {
+			if e.ErrorKind != "" {
+				fmt.Fprintf(&b, "- `%s`: %s\n", e.ErrorKind, e.Msg)
+			} else {
+				fmt.Fprintf(&b, "- %s\n", e.Msg)
+			}
+		}
+		b.WriteString("\n")
+	}
+
+	if sum := s.summary; sum != nil && len(sum.ErrorKinds) > 0 {
+		kinds := make([]string, 0, len(sum.ErrorKinds))
+		for k := range sum.ErrorKinds {
+			kinds = append(kinds, k)
+		}
+		sort.Strings(kinds)
+		for i, k := range kinds {
+			kinds[i] = fmt.Sprintf("%s %d", k, sum.ErrorKinds[k])
+		}
+		fmt.Fprintf(&b, "Errors by kind: %s\n", strings.Join(kinds, ", "))
+	}
+
+	return b.String()
+}
+
+// markdownCell flattens s onto one line safe inside a table cell
+func markdownCell(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	return strings.ReplaceAll(s, "|", `\|`)
+}
+
+// markdownQuote renders s as a block quote
+func markdownQuote(s string) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimRight("> "+l, " ")
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,33 @@
+package output
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/kerneldump/git-dual-context/pkg/analyzer"
+)
+
+// ndjsonSink streams every record as one line of JSON
+type ndjsonSink struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewNDJSON returns a sink writing each result and the summary to w as
+// they arrive, one JSON object per line. Log entries are left to the
+// run's logger, keeping logs and results in separate streams.
+func NewNDJSON(w io.Writer) Sink {
+	return &ndjsonSink{enc: json.NewEncoder(w)}
+}
+
+func (s *ndjsonSink) encode(v any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(v)
+}
+
+func (s *ndjsonSink) Write(result analyzer.JSONResult) error  { return s.encode(result) }
+func (s *ndjsonSink) WriteLog(entry analyzer.LogEntry) error  { return nil }
+func (s *ndjsonSink) WriteSummary(sum analyzer.Summary) error { return s.encode(sum) }
+func (s *ndjsonSink) Close() error                            { return nil }
@@ -0,0 +1,172 @@
+// Package output delivers the records of an analysis run — per-commit
+// results, failures, and the final summary — to sinks.
+//
+// A Sink may stream each record as it arrives (NDJSON) or collect them and
+// render a report when closed (Markdown, SARIF, webhook). Multi fans one
+// run out to several sinks, and Open builds a sink from a command-line
+// spec such as "report.md" or "sarif:findings.json".
+package output
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kerneldump/git-dual-context/pkg/analyzer"
+)
+
+// Sink receives the records of one analysis run. Implementations are safe
+// for concurrent use.
+type Sink interface {
+	// Write records one commit's result; results arrive in commit order
+	Write(result analyzer.JSONResult) error
+
+	// WriteLog records the error log entry of a commit whose analysis
+	// failed, for reports listing failures
+	WriteLog(entry analyzer.LogEntry) error
+
+	// WriteSummary records the run's summary, after every result
+	WriteSummary(summary analyzer.Summary) error
+
+	// Close flushes any report the sink renders and releases its resources
+	Close() error
+}
+
+// Output formats
+const (
+	FormatNDJSON   = "ndjson"
+	FormatMarkdown = "markdown"
+	FormatSARIF    = "sarif"
+	FormatWebhook  = "webhook"
+)
+
+// Formats lists the formats Open accepts as a "format:" prefix
+var Formats = []string{FormatNDJSON, FormatMarkdown, FormatSARIF, FormatWebhook}
+
+// ParseSpec splits an output spec into its format and target. A spec is
+// "-" (stdout), a file path, or an http(s) URL, optionally prefixed with
+// "format:" to override the format implied by its extension: .md is
+// Markdown, .sarif is SARIF, URLs are webhooks, and anything else NDJSON.
+func ParseSpec(spec string) (format, target string, err error) {
+	if spec == "" {
+		return "", "", errors.New("empty output spec")
+	}
+	if prefix, rest, ok := strings.Cut(spec, ":"); ok && isFormat(prefix) {
+		if rest == "" {
+			return "", "", fmt.Errorf("output spec %q has no target", spec)
+		}
+		return prefix, rest, nil
+	}
+
+	lower := strings.ToLower(spec)
+	switch {
+	case strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://"):
+		return FormatWebhook, spec, nil
+	case strings.HasSuffix(lower, ".md") || strings.HasSuffix(lower, ".markdown"):
+		return FormatMarkdown, spec, nil
+	case strings.HasSuffix(lower, ".sarif") || strings.HasSuffix(lower, ".sarif.json"):
+		return FormatSARIF, spec, nil
+	default:
+		return FormatNDJSON, spec, nil
+	}
+}
+
+func isFormat(name string) bool {
+	for _, f := range Formats {
+		if name == f {
+			return true
+		}
+	}
+	return false
+}
+
+// Open returns the sink for spec (see ParseSpec). The target "-" writes to
+// stdout; files are created, replacing any existing file.
+func Open(spec string, stdout io.Writer) (Sink, error) {
+	format, target, err := ParseSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	if format == FormatWebhook {
+		lower := strings.ToLower(target)
+		if !strings.HasPrefix(lower, "http://") && !strings.HasPrefix(lower, "https://") {
+			return nil, fmt.Errorf("webhook target %q is not an http(s) URL", target)
+		}
+		return NewWebhook(target, nil), nil
+	}
+
+	var w io.Writer = stdout
+	var c io.Closer
+	if target != "-" {
+		f, err := os.Create(filepath.Clean(target))
+		if err != nil {
+			return nil, fmt.Errorf("creating output file: %w", err)
+		}
+		w, c = f, f
+	}
+
+	switch format {
+	case FormatMarkdown:
+		return withCloser(NewMarkdown(w), c), nil
+	case FormatSARIF:
+		return withCloser(NewSARIF(w), c), nil
+	default:
+		return withCloser(NewNDJSON(w), c), nil
+	}
+}
+
+// closingSink closes a file after the sink writing to it
+type closingSink struct {
+	Sink
+	c io.Closer
+}
+
+func (s *closingSink) Close() error {
+	return errors.Join(s.Sink.Close(), s.c.Close())
+}
+
+func withCloser(s Sink, c io.Closer) Sink {
+	if c == nil {
+		return s
+	}
+	return &closingSink{Sink: s, c: c}
+}
+
+// multiSink writes every record to each of its sinks
+type multiSink []Sink
+
+// Multi returns a sink writing every record to each of sinks. Errors are
+// joined; a failing sink does not keep the others from receiving records.
+func Multi(sinks ...Sink) Sink {
+	return multiSink(sinks)
+}
+
+func (m multiSink) Write(result analyzer.JSONResult) error {
+	return m.each(func(s Sink) error { return s.Write(result) })
+}
+
+func (m multiSink) WriteLog(entry analyzer.LogEntry) error {
+	return m.each(func(s Sink) error { return s.WriteLog(entry) })
+}
+
+func (m multiSink) WriteSummary(summary analyzer.Summary) error {
+	return m.each(func(s Sink) error { return s.WriteSummary(summary) })
+}
+
+func (m multiSink) Close() error {
+	return m.each(Sink.Close)
+}
+
+func (m multiSink) each(fn func(Sink) error) error {
+	var errs []error
+	for _, s := range m {
+		if err := fn(s); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
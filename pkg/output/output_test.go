@@ -0,0 +1,234 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kerneldump/git-dual-context/pkg/analyzer"
+	"github.com/kerneldump/git-dual-context/pkg/gitdiff"
+)
+
+func TestParseSpec(t *testing.T) {
+	tests := []struct {
+		spec    string
+		format  string
+		target  string
+		wantErr bool
+	}{
+		{spec: "-", format: FormatNDJSON, target: "-"},
+		{spec: "results.ndjson", format: FormatNDJSON, target: "results.ndjson"},
+		{spec: "results.json", format: FormatNDJSON, target: "results.json"},
+		{spec: "report.md", format: FormatMarkdown, target: "report.md"},
+		{spec: "findings.sarif", format: FormatSARIF, target: "findings.sarif"},
+		{spec: "findings.SARIF.json", format: FormatSARIF, target: "findings.SARIF.json"},
+		{spec: "https://hooks.example.com/x", format: FormatWebhook, target: "https://hooks.example.com/x"},
+		{spec: "sarif:findings.json", format: FormatSARIF, target: "findings.json"},
+		{spec: "markdown:-", format: FormatMarkdown, target: "-"},
+		{spec: "C:report.md", format: FormatMarkdown, target: "C:report.md"},
+		{spec: "", wantErr: true},
+		{spec: "sarif:", wantErr: true},
+	}
+	for _, tt := range tests {
+		format, target, err := ParseSpec(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSpec(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			continue
+		}
+		if format != tt.format || target != tt.target {
+			t.Errorf("ParseSpec(%q) = %q, %q; want %q, %q", tt.spec, format, target, tt.format, tt.target)
+		}
+	}
+}
+
+func TestOpenRejectsWebhookFile(t *testing.T) {
+	if _, err := Open("webhook:out.json", os.Stdout); err == nil {
+		t.Error("expected an error for a webhook without a URL")
+	}
+}
+
+// writeRun writes a small run to s: a LOW and a HIGH result, a failure,
+// and the summary
+func writeRun(t *testing.T, s Sink) {
+	t.Helper()
+	results := []analyzer.JSONResult{
+		{Type: "result", Hash: "aaaaaaaa", Message: "Tidy docs", Probability: analyzer.ProbLow, Reasoning: "Docs only"},
+		{Type: "result", Hash: "bbbbbbbb", Message: "Rework auth", Probability: analyzer.ProbHigh, Reasoning: "Drops the nil check",
+			Stats: &gitdiff.DiffStats{Files: []gitdiff.FileStat{{Path: "auth/login.go", Insertions: 3}}}},
+	}
+	for _, r := range results {
+		if err := s.Write(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.WriteLog(analyzer.NewLogEntry("INFO", "progress")); err != nil {
+		t.Fatal(err)
+	}
+	entry := analyzer.NewErrorEntry("Failed to analyze commit cccccccc: boom", "cccccccc", errors.New("boom"), "")
+	if err := s.WriteLog(entry); err != nil {
+		t.Fatal(err)
+	}
+	summary := analyzer.Summary{Type: "summary", Total: 3, High: 1, Low: 1, Errors: 1, Duration: "1s", Model: "m",
+		ErrorKinds: map[string]int{analyzer.ErrorKindOther: 1}, Ranking: []string{"bbbbbbbb"}}
+	if err := s.WriteSummary(summary); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNDJSON(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewNDJSON(&buf)
+	writeRun(t, s)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 2 results and the summary, got %d lines:\n%s", len(lines), buf.String())
+	}
+	var last struct{ Type string }
+	if err := json.Unmarshal([]byte(lines[2]), &last); err != nil || last.Type != "summary" {
+		t.Errorf("expected the summary last, got %s (%v)", lines[2], err)
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewMarkdown(&buf)
+	writeRun(t, s)
+	if buf.Len() != 0 {
+		t.Error("expected nothing written before Close")
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	report := buf.String()
+	for _, want := range []string{
+		"# Root Cause Analysis",
+		"### 1. `bbbbbbbb` — HIGH",
+		"> Rework auth",
+		"`auth/login.go`",
+		"| `aaaaaaaa` | Tidy docs | Docs only |",
+		"## Failures",
+		"boom",
+		"Errors by kind: other 1",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report missing %q:\n%s", want, report)
+		}
+	}
+	if strings.Contains(report, "progress") {
+		t.Error("expected progress logs left out of the report")
+	}
+}
+
+func TestSARIF(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewSARIF(&buf)
+	writeRun(t, s)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatalf("invalid SARIF: %v", err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("unexpected log: %+v", log)
+	}
+	results := log.Runs[0].Results
+	if len(results) != 1 {
+		t.Fatalf("expected only the HIGH commit, got %d results", len(results))
+	}
+	r := results[0]
+	if r.Level != "error" || r.PartialFingerprints["commit"] != "bbbbbbbb" {
+		t.Errorf("unexpected result: %+v", r)
+	}
+	if len(r.Locations) != 1 || r.Locations[0].PhysicalLocation.ArtifactLocation.URI != "auth/login.go" {
+		t.Errorf("unexpected locations: %+v", r.Locations)
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	var got webhookPayload
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decoding payload: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	s := NewWebhook(srv.URL, srv.Client())
+	writeRun(t, s)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Results) != 2 || len(got.Errors) != 1 || got.Summary == nil || got.Summary.Total != 3 {
+		t.Errorf("unexpected payload: %+v", got)
+	}
+}
+
+func TestWebhookErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	if err := NewWebhook(srv.URL, srv.Client()).Close(); err == nil {
+		t.Error("expected an error for a 502 response")
+	}
+}
+
+func TestMultiWritesEverySink(t *testing.T) {
+	dir := t.TempDir()
+	md, err := Open(filepath.Join(dir, "report.md"), os.Stdout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	s := Multi(md, NewNDJSON(&buf))
+	writeRun(t, s)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := os.ReadFile(filepath.Join(dir, "report.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(report), "bbbbbbbb") {
+		t.Errorf("expected the report file written:\n%s", report)
+	}
+	if strings.Count(buf.String(), "\n") != 3 {
+		t.Errorf("expected 3 NDJSON lines:\n%s", buf.String())
+	}
+}
+
+// failingSink fails every write
+type failingSink struct{ collector }
+
+func (*failingSink) Write(analyzer.JSONResult) error { return errors.New("write failed") }
+func (*failingSink) Close() error                    { return nil }
+
+func TestMultiContinuesPastFailure(t *testing.T) {
+	var buf bytes.Buffer
+	s := Multi(&failingSink{}, NewNDJSON(&buf))
+	if err := s.Write(analyzer.JSONResult{Hash: "aaaaaaaa"}); err == nil {
+		t.Error("expected the failing sink's error")
+	}
+	if !strings.Contains(buf.String(), "aaaaaaaa") {
+		t.Error("expected the other sink to receive the result")
+	}
+}
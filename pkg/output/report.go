@@ -0,0 +1,78 @@
+package output
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/kerneldump/git-dual-context/pkg/analyzer"
+)
+
+// collector gathers a run's records for sinks that render one report when
+// closed
+type collector struct {
+	mu      sync.Mutex
+	results []analyzer.JSONResult
+	errors  []analyzer.LogEntry
+	summary *analyzer.Summary
+}
+
+func (c *collector) Write(result analyzer.JSONResult) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.results = append(c.results, result)
+	return nil
+}
+
+// WriteLog keeps only errors; progress and skip messages stay out of
+// reports
+func (c *collector) WriteLog(entry analyzer.LogEntry) error {
+	if entry.Level != "ERROR" {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.errors = append(c.errors, entry)
+	return nil
+}
+
+func (c *collector) WriteSummary(summary analyzer.Summary) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.summary = &summary
+	return nil
+}
+
+// suspects returns the HIGH and MEDIUM results, most suspicious first, in
+// the summary's ranking order when there is one
+func (c *collector) suspects() []analyzer.JSONResult {
+	var out []analyzer.JSONResult
+	for _, r := range c.results {
+		if r.Probability == analyzer.ProbHigh || r.Probability == analyzer.ProbMedium {
+			out = append(out, r)
+		}
+	}
+	if c.summary == nil || len(c.summary.Ranking) == 0 {
+		return out
+	}
+
+	rank := make(map[string]int, len(c.summary.Ranking))
+	for i, id := range c.summary.Ranking {
+		rank[id] = i
+	}
+	position := func(r analyzer.JSONResult) int {
+		if i, ok := rank[rankID(r)]; ok {
+			return i
+		}
+		return len(rank)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return position(out[i]) < position(out[j]) })
+	return out
+}
+
+// rankID identifies a result as Summary.Ranking does
+func rankID(r analyzer.JSONResult) string {
+	if r.Repo != "" {
+		return r.Repo + "@" + r.Hash
+	}
+	return r.Hash
+}
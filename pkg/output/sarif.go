@@ -0,0 +1,135 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/kerneldump/git-dual-context/pkg/analyzer"
+)
+
+// SARIF identifiers of the report
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifRuleID  = "suspect-commit"
+)
+
+// sarifSink renders the run as a SARIF log when closed
+type sarifSink struct {
+	collector
+	w io.Writer
+}
+
+// NewSARIF returns a sink writing the HIGH and MEDIUM commits to w as a
+// SARIF 2.1.0 log when closed, for code scanning dashboards. HIGH commits
+// are errors and MEDIUM ones warnings; each result is located at the
+// files the commit changed.
+func NewSARIF(w io.Writer) Sink {
+	return &sarifSink{w: w}
+}
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations,omitempty"`
+	PartialFingerprints map[string]string `json:"partialFingerprints"`
+	Properties          map[string]any    `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+func (s *sarifSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "git-dual-context",
+			InformationURI: "https://github.com/kerneldump/git-dual-context",
+			Rules: []sarifRule{{
+				ID:               sarifRuleID,
+				ShortDescription: sarifMessage{Text: "Commit likely to have introduced the reported bug"},
+			}},
+		}},
+		Results: []sarifResult{},
+	}
+	for _, r := range s.suspects() {
+		run.Results = append(run.Results, newSARIFResult(r))
+	}
+
+	enc := json.NewEncoder(s.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{Version: sarifVersion, Schema: sarifSchema, Runs: []sarifRun{run}})
+}
+
+func newSARIFResult(r analyzer.JSONResult) sarifResult {
+	level := "warning"
+	if r.Probability == analyzer.ProbHigh {
+		level = "error"
+	}
+	text := fmt.Sprintf("Commit %s is a %s suspect: %s", rankID(r), r.Probability, r.Reasoning)
+	if r.Message != "" {
+		text = fmt.Sprintf("Commit %s (%s) is a %s suspect: %s", rankID(r), r.Message, r.Probability, r.Reasoning)
+	}
+
+	res := sarifResult{
+		RuleID:              sarifRuleID,
+		Level:               level,
+		Message:             sarifMessage{Text: text},
+		PartialFingerprints: map[string]string{"commit": rankID(r)},
+		Properties:          map[string]any{"probability": r.Probability},
+	}
+	if r.Suspicion > 0 {
+		res.Properties["suspicion"] = r.Suspicion
+	}
+	if r.Stats != nil {
+		for _, f := range r.Stats.Files {
+			res.Locations = append(res.Locations, sarifLocation{
+				PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: f.Path}},
+			})
+		}
+	}
+	return res
+}
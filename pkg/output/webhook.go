@@ -0,0 +1,72 @@
+package output
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/kerneldump/git-dual-context/pkg/analyzer"
+)
+
+// WebhookTimeout bounds the webhook POST
+const WebhookTimeout = 30 * time.Second
+
+// webhookSink posts the run to a URL when closed
+type webhookSink struct {
+	collector
+	url    string
+	client *http.Client
+}
+
+// webhookPayload is the JSON body a webhook receives
+type webhookPayload struct {
+	Results []analyzer.JSONResult `json:"results"`
+	Errors  []analyzer.LogEntry   `json:"errors,omitempty"`
+	Summary *analyzer.Summary     `json:"summary,omitempty"`
+}
+
+// NewWebhook returns a sink POSTing the run's results, errors, and summary
+// to url as one JSON object when closed. A nil client uses
+// http.DefaultClient.
+func NewWebhook(url string, client *http.Client) Sink {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &webhookSink{url: url, client: client}
+}
+
+func (s *webhookSink) Close() error {
+	s.mu.Lock()
+	payload := webhookPayload{Results: s.results, Errors: s.errors, Summary: s.summary}
+	if payload.Results == nil {
+		payload.Results = []analyzer.JSONResult{}
+	}
+	body, err := json.Marshal(payload)
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), WebhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook: %s returned %s", s.url, resp.Status)
+	}
+	return nil
+}
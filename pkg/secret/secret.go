@@ -0,0 +1,145 @@
+// Package secret resolves references to secrets such as API keys, so that
+// the keys themselves need not be written into config files or passed on
+// command lines, where other users can read them.
+//
+// A reference names where the secret is kept:
+//
+//	env:NAME                 the environment variable NAME
+//	keyring:SERVICE/ACCOUNT  the OS keyring (macOS Keychain, or the Secret
+//	                         Service through secret-tool on Linux and BSD)
+//	command:CMD              the output of the shell command CMD, like a git
+//	                         credential helper (e.g. "command:pass show gemini")
+package secret
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// CommandTimeout bounds keyring lookups and commands
+const CommandTimeout = 30 * time.Second
+
+// run executes a program and returns its standard output; tests replace it
+var run = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%w: %s", err, msg)
+		}
+		return nil, err
+	}
+	return out, nil
+}
+
+// Validate checks that ref is a well-formed reference, without resolving it
+func Validate(ref string) error {
+	scheme, value, ok := strings.Cut(ref, ":")
+	if !ok {
+		return fmt.Errorf("invalid secret reference %q: expected env:, keyring:, or command:", ref)
+	}
+	switch scheme {
+	case "env", "command":
+		if strings.TrimSpace(value) == "" {
+			return fmt.Errorf("invalid secret reference %q: empty %s", ref, scheme)
+		}
+	case "keyring":
+		service, account, _ := strings.Cut(value, "/")
+		if service == "" || account == "" {
+			return fmt.Errorf("invalid secret reference %q: expected keyring:SERVICE/ACCOUNT", ref)
+		}
+	default:
+		return fmt.Errorf("invalid secret reference %q: unknown scheme %q (expected env, keyring, or command)", ref, scheme)
+	}
+	return nil
+}
+
+// Resolve returns the secret ref points at, with surrounding whitespace
+// removed. Errors name the reference but never the secret.
+func Resolve(ctx context.Context, ref string) (string, error) {
+	if err := Validate(ref); err != nil {
+		return "", err
+	}
+	scheme, value, _ := strings.Cut(ref, ":")
+
+	var secret string
+	switch scheme {
+	case "env":
+		secret = os.Getenv(value)
+		if strings.TrimSpace(secret) == "" {
+			return "", fmt.Errorf("secret %s: environment variable %s is not set", ref, value)
+		}
+	case "keyring":
+		service, account, _ := strings.Cut(value, "/")
+		out, err := lookupKeyring(ctx, service, account)
+		if err != nil {
+			return "", fmt.Errorf("secret %s: %w", ref, err)
+		}
+		secret = out
+	case "command":
+		out, err := runCommand(ctx, value)
+		if err != nil {
+			return "", fmt.Errorf("secret %s: command failed: %w", ref, err)
+		}
+		secret = out
+	}
+
+	secret = strings.TrimSpace(secret)
+	if secret == "" {
+		return "", fmt.Errorf("secret %s is empty", ref)
+	}
+	return secret, nil
+}
+
+// lookupKeyring reads the password of account in service from the OS
+// keyring
+func lookupKeyring(ctx context.Context, service, account string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, CommandTimeout)
+	defer cancel()
+
+	var out []byte
+	var err error
+	switch runtime.GOOS {
+	case "darwin":
+		out, err = run(ctx, "security", "find-generic-password", "-s", service, "-a", account, "-w")
+	case "windows":
+		return "", errors.New("the keyring is not supported on Windows; use command: with a credential tool instead")
+	default:
+		out, err = run(ctx, "secret-tool", "lookup", "service", service, "account", account)
+	}
+	if err != nil {
+		var notFound *exec.Error
+		if errors.As(err, &notFound) {
+			return "", fmt.Errorf("no keyring tool available: %w", err)
+		}
+		return "", fmt.Errorf("keyring lookup failed: %w", err)
+	}
+	return string(out), nil
+}
+
+// runCommand runs command with the platform's shell and returns its output
+func runCommand(ctx context.Context, command string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, CommandTimeout)
+	defer cancel()
+
+	var out []byte
+	var err error
+	if runtime.GOOS == "windows" {
+		out, err = run(ctx, "cmd", "/C", command)
+	} else {
+		out, err = run(ctx, "sh", "-c", command)
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
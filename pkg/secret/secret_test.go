@@ -0,0 +1,95 @@
+package secret
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		ref     string
+		wantErr bool
+	}{
+		{"env:GEMINI_KEY", false},
+		{"keyring:git-dual-context/gemini", false},
+		{"command:pass show gemini", false},
+		{"GEMINI_KEY", true},
+		{"env:", true},
+		{"command:  ", true},
+		{"keyring:git-dual-context", true},
+		{"keyring:/gemini", true},
+		{"file:/etc/key", true},
+	}
+	for _, tt := range tests {
+		if err := Validate(tt.ref); (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%q) = %v, wantErr %v", tt.ref, err, tt.wantErr)
+		}
+	}
+}
+
+func TestResolveEnv(t *testing.T) {
+	t.Setenv("GDC_TEST_KEY", " key-from-env\n")
+	got, err := Resolve(context.Background(), "env:GDC_TEST_KEY")
+	if err != nil || got != "key-from-env" {
+		t.Errorf("Resolve() = %q, %v; want the trimmed variable", got, err)
+	}
+
+	if _, err := Resolve(context.Background(), "env:GDC_TEST_UNSET"); err == nil || !strings.Contains(err.Error(), "GDC_TEST_UNSET is not set") {
+		t.Errorf("expected an error naming the unset variable, got %v", err)
+	}
+}
+
+func TestResolveCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	got, err := Resolve(context.Background(), "command:printf 'key-from-command\\n'")
+	if err != nil || got != "key-from-command" {
+		t.Errorf("Resolve() = %q, %v; want the command's trimmed output", got, err)
+	}
+
+	_, err = Resolve(context.Background(), "command:echo locked >&2; exit 3")
+	if err == nil || !strings.Contains(err.Error(), "locked") {
+		t.Errorf("expected the command's stderr in the error, got %v", err)
+	}
+
+	if _, err := Resolve(context.Background(), "command:true"); err == nil || !strings.Contains(err.Error(), "is empty") {
+		t.Errorf("expected an error for empty output, got %v", err)
+	}
+}
+
+func TestResolveKeyring(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("no keyring support")
+	}
+	var gotArgs []string
+	orig := run
+	defer func() { run = orig }()
+	run = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		gotArgs = append([]string{name}, args...)
+		return []byte("key-from-keyring\n"), nil
+	}
+
+	got, err := Resolve(context.Background(), "keyring:git-dual-context/gemini")
+	if err != nil || got != "key-from-keyring" {
+		t.Fatalf("Resolve() = %q, %v; want the keyring's password", got, err)
+	}
+	want := []string{"secret-tool", "lookup", "service", "git-dual-context", "account", "gemini"}
+	if runtime.GOOS == "darwin" {
+		want = []string{"security", "find-generic-password", "-s", "git-dual-context", "-a", "gemini", "-w"}
+	}
+	if !reflect.DeepEqual(gotArgs, want) {
+		t.Errorf("ran %q, want %q", gotArgs, want)
+	}
+
+	run = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		return nil, errors.New("exit status 1")
+	}
+	if _, err := Resolve(context.Background(), "keyring:git-dual-context/gemini"); err == nil || !strings.Contains(err.Error(), "keyring:git-dual-context/gemini") {
+		t.Errorf("expected an error naming the reference, got %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	_ "embed"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kerneldump/git-dual-context/pkg/history"
+)
+
+//go:embed web/dashboard.html
+var dashboardHTML []byte
+
+// registerDashboard adds the web UI and the history-backed data endpoints:
+//
+//	GET /                            dashboard page
+//	GET /v1/history/runs             recent runs (?limit=)
+//	GET /v1/history/runs/{id}        one run with its verdicts
+//	GET /v1/history/suspects         most-flagged commits (?repo=&limit=)
+//	GET /v1/history/cost             token usage per day (?days=)
+func (s *Server) registerDashboard(mux *http.ServeMux) {
+	mux.HandleFunc("GET /{$}", s.handleDashboard)
+	mux.HandleFunc("GET /v1/history/runs", s.handleHistoryRuns)
+	mux.HandleFunc("GET /v1/history/runs/{id}", s.handleHistoryRun)
+	mux.HandleFunc("GET /v1/history/suspects", s.handleHistorySuspects)
+	mux.HandleFunc("GET /v1/history/cost", s.handleHistoryCost)
+}
+
+func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if _, err := w.Write(dashboardHTML); err != nil {
+		s.logger.Printf("Failed to write dashboard: %v", err)
+	}
+}
+
+func (s *Server) handleHistoryRuns(w http.ResponseWriter, r *http.Request) {
+	runs, err := s.history.ListRuns(queryInt(r, "limit", 50))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if runs == nil {
+		runs = []history.Run{}
+	}
+	writeJSON(w, http.StatusOK, runs)
+}
+
+func (s *Server) handleHistoryRun(w http.ResponseWriter, r *http.Request) {
+	run, verdicts, err := s.history.GetRun(r.PathValue("id"))
+	if errors.Is(err, history.ErrRunNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if verdicts == nil {
+		verdicts = []history.Verdict{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"run":      run,
+		"verdicts": verdicts,
+	})
+}
+
+func (s *Server) handleHistorySuspects(w http.ResponseWriter, r *http.Request) {
+	suspects, err := s.history.Suspects(r.URL.Query().Get("repo"), queryInt(r, "limit", 20))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if suspects == nil {
+		suspects = []history.Suspect{}
+	}
+	writeJSON(w, http.StatusOK, suspects)
+}
+
+func (s *Server) handleHistoryCost(w http.ResponseWriter, r *http.Request) {
+	days := queryInt(r, "days", 30)
+	since := time.Now().UTC().AddDate(0, 0, -days)
+	costs, err := s.history.CostByDay(since)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if costs == nil {
+		costs = []history.DailyCost{}
+	}
+	writeJSON(w, http.StatusOK, costs)
+}
+
+// queryInt parses a positive integer query parameter, falling back to def
+func queryInt(r *http.Request, name string, def int) int {
+	v, err := strconv.Atoi(r.URL.Query().Get(name))
+	if err != nil || v <= 0 {
+		return def
+	}
+	return v
+}
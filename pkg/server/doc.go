@@ -0,0 +1,7 @@
+// Package server provides a long-running HTTP daemon for git-dual-context.
+//
+// It exposes a small REST API so internal tooling can submit analysis jobs,
+// poll their status, stream results as Server-Sent Events, and fetch past
+// reports without shelling out to the CLI. Jobs run through the shared
+// analyzer orchestrator, so results match the CLI and MCP server exactly.
+package server
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"errors"
+
+	"github.com/kerneldump/git-dual-context/pkg/analyzer"
+	"github.com/kerneldump/git-dual-context/pkg/api/analysispb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// grpcService implements analysispb.AnalysisServiceServer on top of the
+// same job runner used by the REST API
+type grpcService struct {
+	analysispb.UnimplementedAnalysisServiceServer
+	s *Server
+}
+
+// RegisterGRPC registers the AnalysisService on a gRPC server. Jobs started
+// over gRPC are stored like REST jobs and can be fetched via /v1/reports.
+func (s *Server) RegisterGRPC(gs *grpc.Server) {
+	analysispb.RegisterAnalysisServiceServer(gs, &grpcService{s: s})
+}
+
+// Analyze submits a job and streams its records until it finishes. The
+// job serves only this call, so it is cancelled if the client cancels or
+// disconnects first. With
+// an AuthToken, the call's "authorization" metadata must carry it as a
+// bearer token.
+func (g *grpcService) Analyze(req *analysispb.AnalyzeRequest, stream grpc.ServerStreamingServer[analysispb.AnalyzeResult]) error {
+	var header string
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	if auth := md.Get("authorization"); len(auth) == 1 {
+		header = auth[0]
+	}
+	if !g.s.authorized(header) {
+		return status.Error(codes.Unauthenticated, "missing or wrong bearer token")
+	}
+	job, err := g.s.Submit(JobRequest{
+		RepoPath:     req.GetRepoPath(),
+		ErrorMessage: req.GetErrorMessage(),
+		NumCommits:   int(req.GetNumCommits()),
+		Branch:       req.GetBranch(),
+		Concurrency:  int(req.GetConcurrency()),
+	})
+	if errors.Is(err, ErrTooManyJobs) {
+		return status.Error(codes.ResourceExhausted, err.Error())
+	}
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	err = job.Stream(stream.Context(), func(ev Event) error {
+		msg := eventToProto(ev)
+		if msg == nil {
+			return nil
+		}
+		msg.JobId = job.id
+		return stream.Send(msg)
+	}, nil)
+	if err != nil {
+		// Nobody reads the rest of the results; stop spending on them
+		job.cancel()
+		return status.FromContextError(err).Err()
+	}
+
+	if v := job.View(); v.Status == StatusFailed {
+		return status.Error(codes.Internal, v.Error)
+	}
+	return nil
+}
+
+// eventToProto converts a job event into its protobuf record
+func eventToProto(ev Event) *analysispb.AnalyzeResult {
+	switch d := ev.Data.(type) {
+	case analyzer.JSONResult:
+		// CommitResult carries verdicts only; skipped and failed commits
+		// reach gRPC clients as log records
+		if d.Status != analyzer.StatusAnalyzed {
+			return nil
+		}
+		return &analysispb.AnalyzeResult{Record: &analysispb.AnalyzeResult_Result{Result: &analysispb.CommitResult{
+			Hash:        d.Hash,
+			Message:     d.Message,
+			Probability: string(d.Probability),
+			Reasoning:   d.Reasoning,
+		}}}
+	case analyzer.LogEntry:
+		return &analysispb.AnalyzeResult{Record: &analysispb.AnalyzeResult_Log{Log: &analysispb.LogEntry{
+			Level:     d.Level,
+			Msg:       d.Msg,
+			Timestamp: d.Timestamp,
+		}}}
+	case *analyzer.Summary:
+		return &analysispb.AnalyzeResult{Record: &analysispb.AnalyzeResult_Summary{Summary: &analysispb.Summary{
+			Total:    int32(d.Total),
+			High:     int32(d.High),
+			Medium:   int32(d.Medium),
+			Low:      int32(d.Low),
+			Skipped:  int32(d.Skipped),
+			Errors:   int32(d.Errors),
+			Duration: d.Duration,
+			Model:    d.Model,
+		}}}
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kerneldump/git-dual-context/pkg/api/analysispb"
+
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newTestGRPCClient(t *testing.T, s *Server) analysispb.AnalysisServiceClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1024 * 1024)
+	gs := grpc.NewServer()
+	s.RegisterGRPC(gs)
+	go gs.Serve(lis)
+	t.Cleanup(gs.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return analysispb.NewAnalysisServiceClient(conn)
+}
+
+func TestGRPCAnalyzeStream(t *testing.T) {
+	s, _ := newTestServer(t)
+	client := newTestGRPCClient(t, s)
+	repoPath := createTestRepo(t)
+
+	stream, err := client.Analyze(context.Background(), &analysispb.AnalyzeRequest{
+		RepoPath:     repoPath,
+		ErrorMessage: "panic in main",
+		NumCommits:   2,
+	})
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+
+	var results []*analysispb.CommitResult
+	var summary *analysispb.Summary
+	var jobID string
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Recv failed: %v", err)
+		}
+		jobID = msg.GetJobId()
+		if r := msg.GetResult(); r != nil {
+			results = append(results, r)
+		}
+		if sm := msg.GetSummary(); sm != nil {
+			summary = sm
+		}
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	if results[0].GetProbability() != "HIGH" {
+		t.Errorf("Expected HIGH probability, got %s", results[0].GetProbability())
+	}
+	if summary == nil || summary.GetTotal() != 2 || summary.GetModel() != "mock-model" {
+		t.Errorf("Unexpected summary: %v", summary)
+	}
+
+	// gRPC jobs share the REST report store
+	if _, err := s.store.Get(jobID); err != nil {
+		t.Errorf("Expected report for job %s: %v", jobID, err)
+	}
+}
+
+// blockingModel is an LLMModel whose calls wait until their context is
+// cancelled, counting the calls that returned
+type blockingModel struct {
+	started chan struct{}
+	once    sync.Once
+	stopped atomic.Int32
+}
+
+func (m *blockingModel) GenerateContent(ctx context.Context, parts ...genai.Part) (*genai.GenerateContentResponse, error) {
+	m.once.Do(func() { close(m.started) })
+	<-ctx.Done()
+	m.stopped.Add(1)
+	return nil, ctx.Err()
+}
+
+func TestGRPCAnalyzeClientCancel(t *testing.T) {
+	model := &blockingModel{started: make(chan struct{})}
+	s := New(Options{Model: model})
+	t.Cleanup(s.Close)
+	client := newTestGRPCClient(t, s)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := client.Analyze(ctx, &analysispb.AnalyzeRequest{
+		RepoPath:     createTestRepo(t),
+		ErrorMessage: "panic in main",
+		NumCommits:   1,
+	})
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	go stream.Recv()
+
+	select {
+	case <-model.started:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Expected the job to call the model")
+	}
+	cancel()
+
+	// The job stops without waiting for the server to close
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Expected the job to stop when the client cancelled")
+	}
+	if model.stopped.Load() == 0 {
+		t.Error("Expected the model call cancelled")
+	}
+}
+
+func TestGRPCAnalyzeAuthToken(t *testing.T) {
+	s := New(Options{
+		Model:     &mockModel{response: `{"probability": "HIGH", "reasoning": "mock"}`},
+		AuthToken: "s3cret",
+	})
+	t.Cleanup(s.Close)
+	client := newTestGRPCClient(t, s)
+	req := &analysispb.AnalyzeRequest{RepoPath: createTestRepo(t), ErrorMessage: "panic in main", NumCommits: 1}
+
+	stream, err := client.Analyze(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected Unauthenticated without a token, got %v", err)
+	}
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer s3cret")
+	stream, err = client.Analyze(ctx, req)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Errorf("Expected records with the token, got %v", err)
+	}
+}
+
+func TestGRPCAnalyzeInvalidRequest(t *testing.T) {
+	s, _ := newTestServer(t)
+	client := newTestGRPCClient(t, s)
+
+	stream, err := client.Analyze(context.Background(), &analysispb.AnalyzeRequest{RepoPath: "."})
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	_, err = stream.Recv()
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument, got %v", err)
+	}
+}
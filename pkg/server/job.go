@@ -0,0 +1,204 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/kerneldump/git-dual-context/pkg/analyzer"
+)
+
+// JobRequest is the body accepted by POST /v1/jobs
+type JobRequest struct {
+	RepoPath     string `json:"repo_path"`
+	ErrorMessage string `json:"error_message"`
+	NumCommits   int    `json:"num_commits,omitempty"`
+	Branch       string `json:"branch,omitempty"`
+	HeadRef      string `json:"head_ref,omitempty"`
+	Concurrency  int    `json:"concurrency,omitempty"`
+	IncludeTests bool   `json:"include_tests,omitempty"`
+}
+
+// JobStatus describes the lifecycle state of a job
+type JobStatus string
+
+const (
+	// StatusQueued means the job was accepted but has not started yet
+	StatusQueued JobStatus = "queued"
+	// StatusRunning means the job is currently being analyzed
+	StatusRunning JobStatus = "running"
+	// StatusCompleted means the job finished and its report is stored
+	StatusCompleted JobStatus = "completed"
+	// StatusFailed means the job aborted before producing a report
+	StatusFailed JobStatus = "failed"
+)
+
+// Event is a single streamed record of a job (result, log, or summary)
+type Event struct {
+	Name string
+	Data interface{}
+}
+
+// JobView is the JSON representation of a job returned by the API
+type JobView struct {
+	ID         string            `json:"id"`
+	Status     JobStatus         `json:"status"`
+	Request    JobRequest        `json:"request"`
+	Completed  int               `json:"completed"`
+	Summary    *analyzer.Summary `json:"summary,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+	FinishedAt *time.Time        `json:"finished_at,omitempty"`
+}
+
+// Job tracks a submitted analysis and the events it has produced so far.
+// Events are append-only so SSE subscribers can replay from any offset.
+type Job struct {
+	mu         sync.Mutex
+	id         string
+	request    JobRequest
+	status     JobStatus
+	events     []Event
+	completed  int
+	summary    *analyzer.Summary
+	err        string
+	createdAt  time.Time
+	finishedAt time.Time
+	changed    chan struct{} // closed and replaced on every update
+
+	cancel context.CancelFunc // stops the running job
+}
+
+func newJob(req JobRequest) *Job {
+	return &Job{
+		id:        newJobID(),
+		request:   req,
+		status:    StatusQueued,
+		createdAt: time.Now().UTC(),
+		changed:   make(chan struct{}),
+	}
+}
+
+// newJobID returns a random 16-character hex identifier
+func newJobID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().UTC().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(b)
+}
+
+// notifyLocked wakes up subscribers; the caller must hold j.mu
+func (j *Job) notifyLocked() {
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+func (j *Job) setStatus(s JobStatus) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.status = s
+	j.notifyLocked()
+}
+
+func (j *Job) appendEvent(name string, data interface{}, completed bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.events = append(j.events, Event{Name: name, Data: data})
+	if completed {
+		j.completed++
+	}
+	j.notifyLocked()
+}
+
+func (j *Job) finish(summary *analyzer.Summary, err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.finishedAt = time.Now().UTC()
+	if err != nil {
+		j.status = StatusFailed
+		j.err = err.Error()
+		j.events = append(j.events, Event{Name: "log", Data: analyzer.NewLogEntry("ERROR", err.Error())})
+	} else {
+		j.status = StatusCompleted
+		j.summary = summary
+		j.events = append(j.events, Event{Name: "summary", Data: summary})
+	}
+	j.notifyLocked()
+}
+
+// finishedBefore reports whether the job finished before t
+func (j *Job) finishedBefore(t time.Time) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return !j.finishedAt.IsZero() && j.finishedAt.Before(t)
+}
+
+// eventsSince returns events from offset, whether the job is finished, and
+// a channel that is closed on the next update
+func (j *Job) eventsSince(offset int) ([]Event, bool, <-chan struct{}) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var evs []Event
+	if offset < len(j.events) {
+		evs = append(evs, j.events[offset:]...)
+	}
+	done := j.status == StatusCompleted || j.status == StatusFailed
+	return evs, done, j.changed
+}
+
+// Stream calls fn for every event of the job, replaying past events first
+// and then following new ones until the job finishes or ctx is cancelled.
+// flush, if non-nil, is called after each batch of events.
+func (j *Job) Stream(ctx context.Context, fn func(Event) error, flush func()) error {
+	offset := 0
+	for {
+		events, done, changed := j.eventsSince(offset)
+		for _, ev := range events {
+			if err := fn(ev); err != nil {
+				return err
+			}
+		}
+		offset += len(events)
+		if flush != nil {
+			flush()
+		}
+
+		if done {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// ID returns the job identifier
+func (j *Job) ID() string {
+	return j.id
+}
+
+// View returns a snapshot of the job suitable for JSON encoding
+func (j *Job) View() JobView {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	v := JobView{
+		ID:        j.id,
+		Status:    j.status,
+		Request:   j.request,
+		Completed: j.completed,
+		Summary:   j.summary,
+		Error:     j.err,
+		CreatedAt: j.createdAt,
+	}
+	if !j.finishedAt.IsZero() {
+		finished := j.finishedAt
+		v.FinishedAt = &finished
+	}
+	return v
+}
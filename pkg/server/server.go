@@ -0,0 +1,581 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kerneldump/git-dual-context/pkg/analyzer"
+	"github.com/kerneldump/git-dual-context/pkg/ci"
+	"github.com/kerneldump/git-dual-context/pkg/config"
+	"github.com/kerneldump/git-dual-context/pkg/gitdiff"
+	"github.com/kerneldump/git-dual-context/pkg/history"
+	"github.com/kerneldump/git-dual-context/pkg/issues"
+	"github.com/kerneldump/git-dual-context/pkg/validator"
+)
+
+// MaxRequestBytes caps the body of a job submission
+const MaxRequestBytes = 1 << 20
+
+// ErrTooManyJobs is the error of a submission refused because
+// serve.max_concurrent_jobs jobs are running
+var ErrTooManyJobs = errors.New("too many jobs running")
+
+// Options configures a Server
+type Options struct {
+	// Model is the LLM used for every job (required)
+	Model analyzer.LLMModel
+
+	// ModelName is reported in job summaries
+	ModelName string
+
+	// ContextCache, if set, caches the prompt context each job's commits
+	// share; Model must route prompts through ContextCache.Model
+	ContextCache *analyzer.ContextCache
+
+	// Tokenizer, if set, counts tokens to fit diffs to their budget
+	// (default: gitdiff.EstimateTokenizer)
+	Tokenizer gitdiff.Tokenizer
+
+	// Config supplies defaults for commits, workers, and timeouts
+	Config *config.Config
+
+	// Store persists finished reports (default: NewMemoryStore())
+	Store Store
+
+	// History, if set, records every finished job and backs the dashboard
+	History *history.Store
+
+	// Logger receives operational messages (default: log.Default())
+	Logger *log.Logger
+
+	// AuthToken, if set, is the bearer token every REST request and gRPC
+	// call must carry
+	AuthToken string
+}
+
+// Server runs analysis jobs and serves the REST API
+type Server struct {
+	store   Store
+	history *history.Store
+	logger  *log.Logger
+	token   string
+
+	settingsMu sync.RWMutex
+	settings   settings
+
+	mu      sync.RWMutex
+	jobs    map[string]*Job
+	running int // jobs started and not finished
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New creates a Server from the given options
+func New(opts Options) *Server {
+	if opts.Config == nil {
+		opts.Config = config.DefaultConfig()
+	}
+	if opts.Store == nil {
+		store := NewMemoryStore()
+		store.MaxReports = opts.Config.Serve.MaxReports
+		opts.Store = store
+	}
+	if opts.Logger == nil {
+		opts.Logger = log.Default()
+	}
+	if opts.ModelName == "" {
+		opts.ModelName = opts.Config.LLM.Model
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Server{
+		store:   opts.Store,
+		history: opts.History,
+		logger:  opts.Logger,
+		token:   opts.AuthToken,
+		settings: settings{
+			model:     opts.Model,
+			modelName: opts.ModelName,
+			cache:     opts.ContextCache,
+			tokenizer: opts.Tokenizer,
+			cfg:       opts.Config,
+		},
+		jobs:   make(map[string]*Job),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// settings are the model and config a job runs with
+type settings struct {
+	model     analyzer.LLMModel
+	modelName string
+	cache     *analyzer.ContextCache
+	tokenizer gitdiff.Tokenizer
+	cfg       *config.Config
+}
+
+// Reload makes jobs submitted from now on use the Model, ModelName,
+// ContextCache, Tokenizer, and Config of opts, defaulted as by New; running jobs
+// finish with the settings they started with. The other options, and the
+// report cap of the default store, cannot change.
+func (s *Server) Reload(opts Options) {
+	if opts.Config == nil {
+		opts.Config = config.DefaultConfig()
+	}
+	if opts.ModelName == "" {
+		opts.ModelName = opts.Config.LLM.Model
+	}
+
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+	s.settings = settings{
+		model:     opts.Model,
+		modelName: opts.ModelName,
+		cache:     opts.ContextCache,
+		tokenizer: opts.Tokenizer,
+		cfg:       opts.Config,
+	}
+}
+
+// current returns the settings for a new job
+func (s *Server) current() settings {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return s.settings
+}
+
+// Handler returns the HTTP handler exposing the REST API:
+//
+//	POST /v1/jobs               submit an analysis job
+//	GET  /v1/jobs/{id}          poll job status
+//	GET  /v1/jobs/{id}/events   stream job records as Server-Sent Events
+//	GET  /v1/reports            list stored reports
+//	GET  /v1/reports/{id}       fetch a stored report
+//
+// When a history store is configured, the dashboard and its data
+// endpoints are also served (see dashboard.go). With an AuthToken, every
+// request must carry it as a bearer token.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/jobs", s.handleSubmit)
+	mux.HandleFunc("GET /v1/jobs/{id}", s.handleJob)
+	mux.HandleFunc("GET /v1/jobs/{id}/events", s.handleEvents)
+	mux.HandleFunc("GET /v1/reports", s.handleListReports)
+	mux.HandleFunc("GET /v1/reports/{id}", s.handleReport)
+	if s.history != nil {
+		s.registerDashboard(mux)
+	}
+	if s.token == "" {
+		return mux
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.authorized(r.Header.Get("Authorization")) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, errors.New("missing or wrong bearer token"))
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// authorized reports whether an Authorization header value carries the
+// server's bearer token, if it has one
+func (s *Server) authorized(header string) bool {
+	if s.token == "" {
+		return true
+	}
+	got, ok := strings.CutPrefix(header, "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(got), []byte(s.token)) == 1
+}
+
+// Close cancels running jobs and waits for them to stop
+func (s *Server) Close() {
+	s.cancel()
+	s.wg.Wait()
+}
+
+// Submit validates a request and starts it as a background job, or
+// returns ErrTooManyJobs when serve.max_concurrent_jobs are running. Jobs
+// finished longer than serve.job_retention ago are dropped.
+func (s *Server) Submit(req JobRequest) (*Job, error) {
+	set := s.current()
+	if req.NumCommits <= 0 {
+		req.NumCommits = set.cfg.Analysis.DefaultCommits
+	}
+	if req.Concurrency <= 0 {
+		req.Concurrency = set.cfg.Performance.Workers
+	}
+
+	if err := validator.ValidateErrorMessage(req.ErrorMessage); err != nil {
+		return nil, fmt.Errorf("invalid error message: %w", err)
+	}
+	if err := validator.ValidateNumCommits(req.NumCommits); err != nil {
+		return nil, fmt.Errorf("invalid number of commits: %w", err)
+	}
+	if err := validator.ValidateNumWorkers(req.Concurrency); err != nil {
+		return nil, fmt.Errorf("invalid concurrency value: %w", err)
+	}
+	if err := validator.ValidateRef(req.Branch); err != nil {
+		return nil, fmt.Errorf("invalid branch name: %w", err)
+	}
+	if err := validator.ValidateRef(req.HeadRef); err != nil {
+		return nil, fmt.Errorf("invalid head ref: %w", err)
+	}
+	if err := validator.ValidateRepoPath(req.RepoPath); err != nil {
+		return nil, fmt.Errorf("invalid repository path: %w", err)
+	}
+	if validator.IsRepoURL(req.RepoPath) {
+		return nil, fmt.Errorf("invalid repository path: only local repositories are accepted, got %s", req.RepoPath)
+	}
+	if err := validator.ValidateRepoRoot(req.RepoPath, set.cfg.Serve.AllowedRoots); err != nil {
+		return nil, fmt.Errorf("invalid repository path: %w", err)
+	}
+
+	s.mu.Lock()
+	if limit := set.cfg.Serve.MaxConcurrentJobs; limit > 0 && s.running >= limit {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("%w: serve.max_concurrent_jobs allows %d, retry later", ErrTooManyJobs, limit)
+	}
+	s.running++
+	ctx, cancel := context.WithCancel(s.ctx)
+	job := newJob(req)
+	job.cancel = cancel
+	expiry := time.Now().Add(-set.cfg.Serve.JobRetention)
+	for id, old := range s.jobs {
+		if old.finishedBefore(expiry) {
+			delete(s.jobs, id)
+		}
+	}
+	s.jobs[job.id] = job
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer func() {
+			s.mu.Lock()
+			s.running--
+			s.mu.Unlock()
+		}()
+		defer cancel()
+		s.run(ctx, job, set)
+	}()
+
+	return job, nil
+}
+
+// Job returns the job with the given ID, or nil if unknown
+func (s *Server) Job(id string) *Job {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.jobs[id]
+}
+
+// run executes a job through the shared orchestrator with the settings in
+// effect when it was submitted, until it finishes or ctx is cancelled
+func (s *Server) run(ctx context.Context, job *Job, set settings) {
+	job.setStatus(StatusRunning)
+	start := time.Now()
+	req := job.request
+	cfg := set.cfg
+
+	repo, err := analyzer.OpenRepository(req.RepoPath)
+	if err != nil {
+		job.finish(nil, fmt.Errorf("failed to open git repository at %s: %w", req.RepoPath, err))
+		return
+	}
+
+	filter, err := gitdiff.NewFilter(cfg.Analysis.IncludeFiles, cfg.Analysis.FileFilters)
+	if err != nil {
+		job.finish(nil, fmt.Errorf("invalid file filter: %w", err))
+		return
+	}
+	filter.IncludeTests = cfg.Analysis.IncludeTests || req.IncludeTests
+
+	provider, err := gitdiff.NewProvider(cfg.Analysis.DiffBackend, req.RepoPath)
+	if err != nil {
+		job.finish(nil, fmt.Errorf("invalid diff backend: %w", err))
+		return
+	}
+
+	offline := cfg.LLM.Provider == config.ProviderHeuristic
+	var trackers issues.Config
+	var forges ci.Config
+	if !offline {
+		// Issue titles and CI statuses only enrich the prompt; analysis
+		// goes on without them
+		if trackers, err = cfg.IssueTrackers(ctx); err != nil {
+			s.logger.Printf("Issue titles unavailable for job %s: %v", job.id, err)
+		}
+		if forges, err = cfg.CIForges(ctx); err != nil {
+			s.logger.Printf("CI statuses unavailable for job %s: %v", job.id, err)
+		}
+	}
+
+	var jsonResults []analyzer.JSONResult
+	var verdicts []history.Verdict
+	results, err := analyzer.RunAnalysis(ctx, repo, set.model, analyzer.AnalysisOptions{
+		NumCommits:   req.NumCommits,
+		Branch:       req.Branch,
+		HeadRef:      req.HeadRef,
+		ErrorMessage: req.ErrorMessage,
+		Workers:      req.Concurrency,
+		Timeout:      cfg.LLM.Timeout,
+		RunTimeout:   cfg.Performance.RunTimeout,
+		Retry:        analyzer.RetryConfig(cfg.Retry()),
+
+		FilterProfiles: cfg.Analysis.FilterProfiles,
+		SuggestOwners:  cfg.Analysis.SuggestOwners,
+		AffectedTests:  cfg.Analysis.AffectedTests,
+		HotspotHistory: cfg.Analysis.HotspotHistory,
+		TechStack:      cfg.Analysis.TechStack,
+		RelatedFiles:   cfg.Analysis.RelatedFiles,
+		GoCallers:      cfg.Analysis.GoCallers,
+		Offline:        offline,
+		ScoreWeights:   analyzer.ScoreWeights(cfg.Analysis.ScoreWeights),
+		Verbosity:      analyzer.Verbosity(cfg.Analysis.Reasoning),
+		Issues:         trackers,
+		CI:             forges,
+		DedupePatches:  cfg.Analysis.DedupePatches,
+		DeepenShallow:  cfg.Analysis.DeepenShallow,
+		ObjectCacheMB:  cfg.Performance.ObjectCacheMB,
+
+		ContextCache:       set.cache,
+		ContextCacheTokens: cfg.LLM.ContextCacheTokens,
+
+		Diff: gitdiff.Options{
+			Filter:          filter,
+			ContextLines:    cfg.Analysis.ContextLines,
+			FunctionContext: cfg.Analysis.FunctionContext,
+			MaxTokens:       analyzer.DiffTokenBudget(set.modelName, cfg.Analysis.MaxDiffTokens),
+			Tokenizer:       set.tokenizer,
+			MaxChunks:       cfg.Analysis.MaxChunks,
+			MinChangedLines: cfg.Analysis.MinChangedLines,
+
+			MaxDiffSize:          cfg.Analysis.MaxDiffSize,
+			MaxFullDiffSize:      cfg.Analysis.MaxFullDiffSize,
+			MaxPromptDiffSize:    cfg.Analysis.MaxPromptDiffSize,
+			AnalyzeNonFunctional: cfg.Analysis.AnalyzeNonFunctional,
+			SemanticDiff:         cfg.Analysis.SemanticDiff,
+			DropIrrelevantHunks:  cfg.Analysis.DropIrrelevantHunks,
+			FullFileMaxBytes:     cfg.Analysis.FullFileMaxBytes,
+			BlameEvolution:       cfg.Analysis.BlameEvolution,
+			Provider:             provider,
+		},
+		OnResult: func(r analyzer.CommitAnalysisResult) {
+			// Every commit gets a result record; those without a verdict
+			// are also logged
+			record := func(jr analyzer.JSONResult) {
+				jsonResults = append(jsonResults, jr)
+				job.appendEvent("result", jr, true)
+			}
+			switch {
+			case errors.Is(r.Error, analyzer.ErrBudgetExhausted):
+				job.appendEvent("log", analyzer.NewLogEntry("WARN", fmt.Sprintf("Commit: %s | [Skipped - Run deadline reached]", r.Hash[:8])), true)
+				skipped := &analyzer.AnalysisResult{Skipped: true, Skip: &analyzer.SkipInfo{Reason: analyzer.SkipDeadline}}
+				record(skipped.ToJSONResult(r.Hash[:8], r.Message))
+			case r.Error != nil:
+				job.appendEvent("log", analyzer.NewErrorEntry(fmt.Sprintf("Failed to analyze commit %s: %v", r.Hash, r.Error), r.Hash, r.Error, cfg.Output.DebugDir), true)
+				jr := analyzer.NewErrorResult(r.Hash[:8], r.Message, r.Error)
+				jr.Retries = r.Retries
+				record(jr)
+			case r.Result == nil:
+				job.appendEvent("log", analyzer.NewLogEntry("ERROR", fmt.Sprintf("No result for commit %s", r.Hash)), true)
+				record(analyzer.NewErrorResult(r.Hash[:8], r.Message, errors.New("no result")))
+			case r.Result.Skipped:
+				job.appendEvent("log", analyzer.NewSkipEntry(r.Hash[:8], r.Hash, r.Result.Skip), true)
+				record(r.Result.ToJSONResult(r.Hash[:8], r.Message))
+			default:
+				jr := r.Result.ToJSONResult(r.Hash[:8], r.Message)
+				verdicts = append(verdicts, history.Verdict{
+					Commit:       r.Hash,
+					Message:      jr.Message,
+					Probability:  string(r.Result.Probability),
+					Reasoning:    r.Result.Reasoning,
+					PromptTokens: int(r.Result.PromptTokens),
+					OutputTokens: int(r.Result.OutputTokens),
+				})
+				record(jr)
+			}
+		},
+	})
+	if err != nil {
+		job.finish(nil, err)
+		return
+	}
+
+	counts := analyzer.CalculateSummary(results)
+	summary := &analyzer.Summary{
+		Type:     analyzer.RecordSummary,
+		Total:    counts.Total,
+		High:     counts.High,
+		Medium:   counts.Medium,
+		Low:      counts.Low,
+		Skipped:  counts.Skipped,
+		Errors:   counts.Errors,
+		Duration: time.Since(start).String(),
+		Model:    set.modelName,
+		Ranking:  analyzer.Ranking(results),
+
+		OverBudget:    counts.OverBudget,
+		ErrorKinds:    counts.ErrorKinds,
+		SkipReasons:   counts.SkipReasons,
+		SchemaVersion: analyzer.SchemaVersion,
+	}
+
+	if jsonResults == nil {
+		jsonResults = []analyzer.JSONResult{}
+	}
+	report := &Report{
+		ID:        job.id,
+		Request:   req,
+		Results:   jsonResults,
+		Summary:   *summary,
+		CreatedAt: job.createdAt,
+	}
+	if err := s.store.Save(report); err != nil {
+		s.logger.Printf("Failed to store report %s: %v", job.id, err)
+	}
+	if s.history != nil {
+		s.recordHistory(job, summary, verdicts)
+	}
+
+	job.finish(summary, nil)
+}
+
+// recordHistory stores a finished job in the history database
+func (s *Server) recordHistory(job *Job, summary *analyzer.Summary, verdicts []history.Verdict) {
+	repo := job.request.RepoPath
+	if abs, err := filepath.Abs(repo); err == nil {
+		repo = abs
+	}
+	run := history.Run{
+		ID:           job.id,
+		Repo:         repo,
+		Branch:       job.request.Branch,
+		ErrorMessage: job.request.ErrorMessage,
+		Model:        summary.Model,
+		Total:        summary.Total,
+		High:         summary.High,
+		Medium:       summary.Medium,
+		Low:          summary.Low,
+		Skipped:      summary.Skipped,
+		Errors:       summary.Errors,
+		Duration:     summary.Duration,
+		CreatedAt:    job.createdAt,
+	}
+	if err := s.history.SaveRun(run, verdicts); err != nil {
+		s.logger.Printf("Failed to record job %s in history: %v", job.id, err)
+	}
+}
+
+func (s *Server) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	var req JobRequest
+	var tooLarge *http.MaxBytesError
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxRequestBytes)).Decode(&req)
+	switch {
+	case errors.As(err, &tooLarge):
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("invalid request body: larger than %d bytes", MaxRequestBytes))
+		return
+	case err != nil:
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+
+	job, err := s.Submit(req)
+	switch {
+	case errors.Is(err, ErrTooManyJobs):
+		writeError(w, http.StatusServiceUnavailable, err)
+		return
+	case err != nil:
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	w.Header().Set("Location", "/v1/jobs/"+job.id)
+	writeJSON(w, http.StatusAccepted, job.View())
+}
+
+func (s *Server) handleJob(w http.ResponseWriter, r *http.Request) {
+	job := s.Job(r.PathValue("id"))
+	if job == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("job not found"))
+		return
+	}
+	writeJSON(w, http.StatusOK, job.View())
+}
+
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	job := s.Job(r.PathValue("id"))
+	if job == nil {
+		writeError(w, http.StatusNotFound, fmt.Errorf("job not found"))
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("streaming not supported"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	_ = job.Stream(r.Context(), func(ev Event) error {
+		data, err := json.Marshal(ev.Data)
+		if err != nil {
+			s.logger.Printf("Failed to encode event for job %s: %v", job.id, err)
+			return nil
+		}
+		_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Name, data)
+		return err
+	}, flusher.Flush)
+}
+
+func (s *Server) handleListReports(w http.ResponseWriter, r *http.Request) {
+	reports, err := s.store.List()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, reports)
+}
+
+func (s *Server) handleReport(w http.ResponseWriter, r *http.Request) {
+	report, err := s.store.Get(r.PathValue("id"))
+	if errors.Is(err, ErrReportNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Failed to encode response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
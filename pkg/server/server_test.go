@@ -0,0 +1,512 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kerneldump/git-dual-context/pkg/config"
+	"github.com/kerneldump/git-dual-context/pkg/history"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/google/generative-ai-go/genai"
+)
+
+// mockModel is an LLMModel that always returns the same verdict
+type mockModel struct {
+	response string
+}
+
+func (m *mockModel) GenerateContent(ctx context.Context, parts ...genai.Part) (*genai.GenerateContentResponse, error) {
+	return &genai.GenerateContentResponse{
+		Candidates: []*genai.Candidate{
+			{Content: &genai.Content{Parts: []genai.Part{genai.Text(m.response)}}},
+		},
+	}, nil
+}
+
+func createTestRepo(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatalf("Failed to init repo: %v", err)
+	}
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("Failed to get worktree: %v", err)
+	}
+
+	for i, content := range []string{"package main\n", "package main\n\nfunc main() {}\n"} {
+		if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		if _, err := w.Add("main.go"); err != nil {
+			t.Fatalf("Failed to add file: %v", err)
+		}
+		if _, err := w.Commit("commit "+string(rune('a'+i)), &git.CommitOptions{
+			Author: &object.Signature{Name: "Test User", Email: "test@example.com", When: time.Now()},
+		}); err != nil {
+			t.Fatalf("Failed to commit: %v", err)
+		}
+	}
+
+	return dir
+}
+
+func newTestServer(t *testing.T) (*Server, *httptest.Server) {
+	t.Helper()
+	s := New(Options{
+		Model:     &mockModel{response: `{"probability": "HIGH", "reasoning": "mock"}`},
+		ModelName: "mock-model",
+	})
+	ts := httptest.NewServer(s.Handler())
+	t.Cleanup(func() {
+		ts.Close()
+		s.Close()
+	})
+	return s, ts
+}
+
+func submitJob(t *testing.T, ts *httptest.Server, body string) JobView {
+	t.Helper()
+	resp, err := http.Post(ts.URL+"/v1/jobs", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("POST /v1/jobs failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		t.Fatalf("Expected status 202, got %d", resp.StatusCode)
+	}
+	var view JobView
+	if err := json.NewDecoder(resp.Body).Decode(&view); err != nil {
+		t.Fatalf("Failed to decode job: %v", err)
+	}
+	return view
+}
+
+func TestSubmitAndStreamJob(t *testing.T) {
+	_, ts := newTestServer(t)
+	repoPath := createTestRepo(t)
+
+	body, _ := json.Marshal(JobRequest{RepoPath: repoPath, ErrorMessage: "panic in main", NumCommits: 2})
+	view := submitJob(t, ts, string(body))
+	if view.ID == "" {
+		t.Fatal("Expected job ID")
+	}
+
+	resp, err := http.Get(ts.URL + "/v1/jobs/" + view.ID + "/events")
+	if err != nil {
+		t.Fatalf("GET events failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Expected text/event-stream, got %s", ct)
+	}
+
+	var eventNames []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if name, ok := strings.CutPrefix(scanner.Text(), "event: "); ok {
+			eventNames = append(eventNames, name)
+		}
+	}
+
+	if len(eventNames) != 3 {
+		t.Fatalf("Expected 3 events, got %v", eventNames)
+	}
+	if eventNames[0] != "result" || eventNames[2] != "summary" {
+		t.Errorf("Unexpected event sequence: %v", eventNames)
+	}
+
+	// Poll status after completion
+	statusResp, err := http.Get(ts.URL + "/v1/jobs/" + view.ID)
+	if err != nil {
+		t.Fatalf("GET job failed: %v", err)
+	}
+	defer statusResp.Body.Close()
+	var status JobView
+	if err := json.NewDecoder(statusResp.Body).Decode(&status); err != nil {
+		t.Fatalf("Failed to decode status: %v", err)
+	}
+	if status.Status != StatusCompleted {
+		t.Errorf("Expected completed status, got %s", status.Status)
+	}
+	if status.Summary == nil || status.Summary.High != 2 {
+		t.Errorf("Expected summary with 2 high, got %+v", status.Summary)
+	}
+}
+
+func TestReportsEndpoints(t *testing.T) {
+	s, ts := newTestServer(t)
+	repoPath := createTestRepo(t)
+
+	job, err := s.Submit(JobRequest{RepoPath: repoPath, ErrorMessage: "panic in main", NumCommits: 1})
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	s.wg.Wait()
+
+	resp, err := http.Get(ts.URL + "/v1/reports")
+	if err != nil {
+		t.Fatalf("GET reports failed: %v", err)
+	}
+	defer resp.Body.Close()
+	var infos []ReportInfo
+	if err := json.NewDecoder(resp.Body).Decode(&infos); err != nil {
+		t.Fatalf("Failed to decode reports: %v", err)
+	}
+	if len(infos) != 1 || infos[0].ID != job.id {
+		t.Fatalf("Expected one report for job %s, got %+v", job.id, infos)
+	}
+
+	reportResp, err := http.Get(ts.URL + "/v1/reports/" + job.id)
+	if err != nil {
+		t.Fatalf("GET report failed: %v", err)
+	}
+	defer reportResp.Body.Close()
+	var report Report
+	if err := json.NewDecoder(reportResp.Body).Decode(&report); err != nil {
+		t.Fatalf("Failed to decode report: %v", err)
+	}
+	if len(report.Results) != 1 || report.Summary.Model != "mock-model" {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+}
+
+func TestSubmitInvalidRequest(t *testing.T) {
+	_, ts := newTestServer(t)
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"malformed JSON", `{`},
+		{"missing error message", `{"repo_path": "."}`},
+		{"empty repo path", `{"error_message": "boom"}`},
+		{"invalid branch", `{"repo_path": ".", "error_message": "boom", "branch": "-bad"}`},
+		{"remote repository", `{"repo_path": "https://github.com/user/repo.git", "error_message": "boom"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Post(ts.URL+"/v1/jobs", "application/json", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatalf("POST failed: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("Expected 400, got %d", resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestUnknownJobAndReport(t *testing.T) {
+	_, ts := newTestServer(t)
+
+	for _, path := range []string{"/v1/jobs/missing", "/v1/jobs/missing/events", "/v1/reports/missing"} {
+		resp, err := http.Get(ts.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v", path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("GET %s: expected 404, got %d", path, resp.StatusCode)
+		}
+	}
+}
+
+func TestJobFailsForMissingRepo(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	job, err := s.Submit(JobRequest{RepoPath: filepath.Join(t.TempDir(), "nope"), ErrorMessage: "boom"})
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	s.wg.Wait()
+
+	view := job.View()
+	if view.Status != StatusFailed || view.Error == "" {
+		t.Errorf("Expected failed job with error, got %+v", view)
+	}
+}
+
+func TestAuthToken(t *testing.T) {
+	s := New(Options{
+		Model:     &mockModel{response: `{"probability": "HIGH", "reasoning": "mock"}`},
+		AuthToken: "s3cret",
+	})
+	ts := httptest.NewServer(s.Handler())
+	t.Cleanup(func() {
+		ts.Close()
+		s.Close()
+	})
+
+	for header, want := range map[string]int{
+		"":              http.StatusUnauthorized,
+		"Bearer wrong":  http.StatusUnauthorized,
+		"s3cret":        http.StatusUnauthorized,
+		"Bearer s3cret": http.StatusOK,
+	} {
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/v1/reports", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET reports failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("Authorization %q: expected %d, got %d", header, want, resp.StatusCode)
+		}
+		if want == http.StatusUnauthorized && resp.Header.Get("WWW-Authenticate") != "Bearer" {
+			t.Errorf("Authorization %q: expected a WWW-Authenticate challenge", header)
+		}
+	}
+}
+
+func TestSubmitAllowedRoots(t *testing.T) {
+	s, _ := newTestServer(t)
+	repoPath := createTestRepo(t)
+
+	cfg := config.DefaultConfig()
+	cfg.Serve.AllowedRoots = []string{t.TempDir()}
+	s.Reload(Options{Model: &mockModel{response: `{"probability": "LOW", "reasoning": "mock"}`}, Config: cfg})
+	if _, err := s.Submit(JobRequest{RepoPath: repoPath, ErrorMessage: "boom"}); err == nil || !strings.Contains(err.Error(), "outside the allowed roots") {
+		t.Errorf("Expected a repository outside the allowed roots rejected, got %v", err)
+	}
+
+	cfg.Serve.AllowedRoots = []string{filepath.Dir(repoPath)}
+	if _, err := s.Submit(JobRequest{RepoPath: repoPath, ErrorMessage: "boom"}); err != nil {
+		t.Errorf("Expected a repository under an allowed root accepted, got %v", err)
+	}
+	s.wg.Wait()
+}
+
+func TestSubmitMaxConcurrentJobs(t *testing.T) {
+	model := &blockingModel{started: make(chan struct{})}
+	cfg := config.DefaultConfig()
+	cfg.Serve.MaxConcurrentJobs = 1
+	s := New(Options{Model: model, Config: cfg})
+	ts := httptest.NewServer(s.Handler())
+	t.Cleanup(func() {
+		ts.Close()
+		s.Close()
+	})
+	repoPath := createTestRepo(t)
+
+	body, _ := json.Marshal(JobRequest{RepoPath: repoPath, ErrorMessage: "panic in main", NumCommits: 1})
+	first := submitJob(t, ts, string(body))
+	<-model.started
+
+	// A second job is refused while the first runs
+	resp, err := http.Post(ts.URL+"/v1/jobs", "application/json", strings.NewReader(string(body)))
+	if err != nil {
+		t.Fatalf("POST failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 over serve.max_concurrent_jobs, got %d", resp.StatusCode)
+	}
+
+	s.Job(first.ID).cancel()
+	s.wg.Wait()
+	if _, err := s.Submit(JobRequest{RepoPath: repoPath, ErrorMessage: "panic in main", NumCommits: 1}); err != nil {
+		t.Errorf("Expected a job accepted once the first finished, got %v", err)
+	}
+	s.Close()
+}
+
+func TestSubmitRequestTooLarge(t *testing.T) {
+	_, ts := newTestServer(t)
+
+	body := `{"repo_path": ".", "error_message": "` + strings.Repeat("x", MaxRequestBytes) + `"}`
+	resp, err := http.Post(ts.URL+"/v1/jobs", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("POST failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413, got %d", resp.StatusCode)
+	}
+}
+
+func TestFinishedJobsExpire(t *testing.T) {
+	s, _ := newTestServer(t)
+	repoPath := createTestRepo(t)
+
+	cfg := config.DefaultConfig()
+	cfg.Serve.JobRetention = time.Millisecond
+	s.Reload(Options{Model: &mockModel{response: `{"probability": "LOW", "reasoning": "mock"}`}, Config: cfg})
+
+	first, err := s.Submit(JobRequest{RepoPath: repoPath, ErrorMessage: "boom", NumCommits: 1})
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	s.wg.Wait()
+	time.Sleep(10 * time.Millisecond)
+
+	second, err := s.Submit(JobRequest{RepoPath: repoPath, ErrorMessage: "boom", NumCommits: 1})
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	if s.Job(first.ID()) != nil {
+		t.Errorf("Expected the expired job %s dropped", first.ID())
+	}
+	if s.Job(second.ID()) == nil {
+		t.Errorf("Expected the new job %s kept", second.ID())
+	}
+	s.wg.Wait()
+
+	// The report outlives its job
+	if _, err := s.store.Get(first.ID()); err != nil {
+		t.Errorf("Expected the report of job %s kept: %v", first.ID(), err)
+	}
+}
+
+func TestMemoryStoreMaxReports(t *testing.T) {
+	store := NewMemoryStore()
+	store.MaxReports = 2
+
+	start := time.Now()
+	for i, id := range []string{"a", "b", "c"} {
+		if err := store.Save(&Report{ID: id, CreatedAt: start.Add(time.Duration(i) * time.Second)}); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+	if _, err := store.Get("a"); err != ErrReportNotFound {
+		t.Errorf("Expected the oldest report dropped, got %v", err)
+	}
+	infos, _ := store.List()
+	if len(infos) != 2 || infos[0].ID != "c" || infos[1].ID != "b" {
+		t.Errorf("Expected the 2 newest reports kept, got %+v", infos)
+	}
+}
+
+func TestReload(t *testing.T) {
+	s, _ := newTestServer(t)
+	repoPath := createTestRepo(t)
+
+	cfg := config.DefaultConfig()
+	cfg.Analysis.DefaultCommits = 1
+	s.Reload(Options{
+		Model:     &mockModel{response: `{"probability": "LOW", "reasoning": "reloaded"}`},
+		ModelName: "reloaded-model",
+		Config:    cfg,
+	})
+
+	job, err := s.Submit(JobRequest{RepoPath: repoPath, ErrorMessage: "panic in main"})
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	s.wg.Wait()
+
+	view := job.View()
+	if view.Request.NumCommits != 1 {
+		t.Errorf("Expected the reloaded default of 1 commit, got %d", view.Request.NumCommits)
+	}
+	if view.Summary == nil || view.Summary.Model != "reloaded-model" || view.Summary.Low != 1 {
+		t.Errorf("Expected a summary from the reloaded model, got %+v", view.Summary)
+	}
+}
+
+func TestDashboardWithHistory(t *testing.T) {
+	store, err := history.Open(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatalf("Failed to open history: %v", err)
+	}
+	defer store.Close()
+
+	s := New(Options{
+		Model:     &mockModel{response: `{"probability": "HIGH", "reasoning": "mock"}`},
+		ModelName: "mock-model",
+		History:   store,
+	})
+	ts := httptest.NewServer(s.Handler())
+	defer func() {
+		ts.Close()
+		s.Close()
+	}()
+
+	job, err := s.Submit(JobRequest{RepoPath: createTestRepo(t), ErrorMessage: "panic in main", NumCommits: 2})
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	s.wg.Wait()
+
+	resp, err := http.Get(ts.URL + "/")
+	if err != nil {
+		t.Fatalf("GET / failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		t.Errorf("Expected HTML dashboard, got %d %s", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	var runs []history.Run
+	getJSON(t, ts.URL+"/v1/history/runs", &runs)
+	if len(runs) != 1 || runs[0].ID != job.ID() || runs[0].High != 2 {
+		t.Fatalf("Expected the job recorded in history, got %+v", runs)
+	}
+
+	var suspects []history.Suspect
+	getJSON(t, ts.URL+"/v1/history/suspects?repo="+runs[0].Repo, &suspects)
+	if len(suspects) != 2 {
+		t.Errorf("Expected 2 suspects, got %+v", suspects)
+	}
+
+	var costs []history.DailyCost
+	getJSON(t, ts.URL+"/v1/history/cost?days=1", &costs)
+	if len(costs) != 1 || costs[0].Verdicts != 2 {
+		t.Errorf("Expected one day with 2 verdicts, got %+v", costs)
+	}
+
+	missing, err := http.Get(ts.URL + "/v1/history/runs/missing")
+	if err != nil {
+		t.Fatalf("GET run failed: %v", err)
+	}
+	missing.Body.Close()
+	if missing.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for unknown run, got %d", missing.StatusCode)
+	}
+}
+
+func TestDashboardDisabledWithoutHistory(t *testing.T) {
+	_, ts := newTestServer(t)
+
+	resp, err := http.Get(ts.URL + "/v1/history/runs")
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 without history store, got %d", resp.StatusCode)
+	}
+}
+
+func getJSON(t *testing.T, url string, v interface{}) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s: status %d", url, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatalf("Failed to decode %s: %v", url, err)
+	}
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kerneldump/git-dual-context/pkg/analyzer"
+)
+
+// ErrReportNotFound is returned when a report ID is unknown to the store
+var ErrReportNotFound = errors.New("report not found")
+
+// Report is the persisted outcome of a finished analysis job
+type Report struct {
+	ID        string                `json:"id"`
+	Request   JobRequest            `json:"request"`
+	Results   []analyzer.JSONResult `json:"results"`
+	Summary   analyzer.Summary      `json:"summary"`
+	CreatedAt time.Time             `json:"created_at"`
+}
+
+// ReportInfo is the lightweight listing entry for a stored report
+type ReportInfo struct {
+	ID           string    `json:"id"`
+	RepoPath     string    `json:"repo_path"`
+	ErrorMessage string    `json:"error_message"`
+	High         int       `json:"high"`
+	Total        int       `json:"total"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Store persists finished reports so they can be fetched after the job ends
+type Store interface {
+	Save(r *Report) error
+	Get(id string) (*Report, error)
+	List() ([]ReportInfo, error)
+}
+
+// MemoryStore is an in-process Store. Reports are lost on restart.
+type MemoryStore struct {
+	// MaxReports caps the reports kept, dropping the oldest when a new
+	// one is saved (0: no cap)
+	MaxReports int
+
+	mu      sync.RWMutex
+	reports map[string]*Report
+}
+
+// NewMemoryStore creates an empty in-memory report store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{reports: make(map[string]*Report)}
+}
+
+// Save stores or replaces a report, dropping the oldest ones past
+// MaxReports
+func (m *MemoryStore) Save(r *Report) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reports[r.ID] = r
+	for m.MaxReports > 0 && len(m.reports) > m.MaxReports {
+		var oldest *Report
+		for _, stored := range m.reports {
+			if oldest == nil || stored.CreatedAt.Before(oldest.CreatedAt) {
+				oldest = stored
+			}
+		}
+		delete(m.reports, oldest.ID)
+	}
+	return nil
+}
+
+// Get returns the report with the given ID
+func (m *MemoryStore) Get(id string) (*Report, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	r, ok := m.reports[id]
+	if !ok {
+		return nil, ErrReportNotFound
+	}
+	return r, nil
+}
+
+// List returns all reports, newest first
+func (m *MemoryStore) List() ([]ReportInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	infos := make([]ReportInfo, 0, len(m.reports))
+	for _, r := range m.reports {
+		infos = append(infos, r.Info())
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].CreatedAt.After(infos[j].CreatedAt)
+	})
+	return infos, nil
+}
+
+// Info returns the listing entry for the report
+func (r *Report) Info() ReportInfo {
+	return ReportInfo{
+		ID:           r.ID,
+		RepoPath:     r.Request.RepoPath,
+		ErrorMessage: r.Request.ErrorMessage,
+		High:         r.Summary.High,
+		Total:        r.Summary.Total,
+		CreatedAt:    r.CreatedAt,
+	}
+}
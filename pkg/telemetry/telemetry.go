@@ -0,0 +1,59 @@
+// Package telemetry configures OpenTelemetry tracing for long-running hosts
+// (the REST/gRPC daemon and the MCP server).
+//
+// Tracing is opt-in: spans are exported over OTLP/gRPC only when one of the
+// standard OTEL_EXPORTER_OTLP_ENDPOINT or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT
+// environment variables is set. Otherwise the global TracerProvider stays a
+// no-op and instrumentation in pkg/analyzer costs nothing.
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// ShutdownFunc flushes buffered spans and releases the exporter
+type ShutdownFunc func(context.Context) error
+
+// Enabled reports whether an OTLP endpoint is configured in the environment
+func Enabled() bool {
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" ||
+		os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// Setup installs a global TracerProvider that exports spans via OTLP/gRPC.
+// The exporter reads its endpoint, headers, and TLS settings from the
+// standard OTEL_EXPORTER_OTLP_* variables. When tracing is not enabled,
+// Setup does nothing and returns a no-op ShutdownFunc.
+func Setup(ctx context.Context, serviceName string) (ShutdownFunc, error) {
+	if !Enabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracegrpc.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("creating OTLP trace exporter: %w", err)
+	}
+
+	res := resource.NewSchemaless(attribute.String("service.name", serviceName))
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	return tp.Shutdown, nil
+}
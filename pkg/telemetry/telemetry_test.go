@@ -0,0 +1,32 @@
+package telemetry
+
+import (
+	"context"
+	"testing"
+)
+
+func TestEnabled(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	if Enabled() {
+		t.Error("Expected tracing to be disabled without an OTLP endpoint")
+	}
+
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "http://localhost:4317")
+	if !Enabled() {
+		t.Error("Expected tracing to be enabled with OTEL_EXPORTER_OTLP_TRACES_ENDPOINT set")
+	}
+}
+
+func TestSetupDisabledIsNoop(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+
+	shutdown, err := Setup(context.Background(), "test")
+	if err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("Expected no-op shutdown, got %v", err)
+	}
+}
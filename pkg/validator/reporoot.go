@@ -0,0 +1,78 @@
+package validator
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ValidateRepoRoot checks that a local repository path, or the path of a
+// file URL, lies under one of roots. Paths and roots are made absolute
+// and their symlinks resolved first, so neither a relative path nor a
+// link out of a root escapes it. A leading "~/" in a root is expanded to
+// the user's home directory; roots that do not exist match nothing. Empty
+// roots allow every path, and remote URLs other than file URLs are not
+// checked.
+func ValidateRepoRoot(repoPath string, roots []string) error {
+	if len(roots) == 0 {
+		return nil
+	}
+	local := repoPath
+	if IsRepoURL(repoPath) {
+		u, err := ParseRepoURL(repoPath)
+		if err != nil {
+			return err
+		}
+		if u.Scheme != "file" {
+			return nil
+		}
+		local = u.Path
+	}
+
+	// A path that does not resolve cannot be opened either; compare it as
+	// given so the error does not tell what exists outside the roots
+	resolved, err := resolvePath(local)
+	if err != nil {
+		if resolved, err = filepath.Abs(local); err != nil {
+			return fmt.Errorf("invalid repository path %s: %w", repoPath, err)
+		}
+	}
+	for _, root := range roots {
+		r, err := resolvePath(root)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(r, resolved)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("repository path %s is outside the allowed roots", repoPath)
+}
+
+// ValidateRoot checks that root can be passed to ValidateRepoRoot: an
+// absolute path, or one starting with "~/"
+func ValidateRoot(root string) error {
+	if !filepath.IsAbs(root) && !strings.HasPrefix(root, "~/") {
+		return fmt.Errorf("root %q must be an absolute path", root)
+	}
+	return nil
+}
+
+// resolvePath returns path absolute, with a leading "~/" expanded and
+// symlinks resolved
+func resolvePath(path string) (string, error) {
+	if strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get home directory: %w", err)
+		}
+		path = filepath.Join(home, path[2:])
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(abs)
+}
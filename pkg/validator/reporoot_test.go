@@ -0,0 +1,70 @@
+package validator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateRepoRoot(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "src")
+	for _, dir := range []string{filepath.Join(root, "app"), filepath.Join(base, "srcx"), filepath.Join(base, "secret")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(base, "secret"), filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "app"), filepath.Join(base, "applink")); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(root)
+
+	tests := []struct {
+		name    string
+		path    string
+		roots   []string
+		wantErr bool
+	}{
+		{"no roots", filepath.Join(base, "secret"), nil, false},
+		{"the root", root, []string{root}, false},
+		{"under the root", filepath.Join(root, "app"), []string{root}, false},
+		{"relative path", "app", []string{root}, false},
+		{"second root", filepath.Join(base, "srcx"), []string{root, filepath.Join(base, "srcx")}, false},
+		{"link into the root", filepath.Join(base, "applink"), []string{root}, false},
+		{"missing path under the root", filepath.Join(root, "missing"), []string{root}, false},
+		{"outside the root", filepath.Join(base, "secret"), []string{root}, true},
+		{"root name prefix", filepath.Join(base, "srcx"), []string{root}, true},
+		{"parent of the root", base, []string{root}, true},
+		{"link out of the root", filepath.Join(root, "link"), []string{root}, true},
+		{"missing path outside the root", filepath.Join(base, "missing"), []string{root}, true},
+		{"missing root", filepath.Join(root, "app"), []string{filepath.Join(base, "missing")}, true},
+		{"file URL under the root", "file://" + filepath.Join(root, "app"), []string{root}, false},
+		{"file URL outside the root", "file://" + filepath.Join(base, "secret"), []string{root}, true},
+		{"remote URL", "https://github.com/org/repo.git", []string{root}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRepoRoot(tt.path, tt.roots)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateRepoRoot(%q, %q) error = %v, wantErr %v", tt.path, tt.roots, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateRoot(t *testing.T) {
+	for _, root := range []string{"/src", "~/src"} {
+		if err := ValidateRoot(root); err != nil {
+			t.Errorf("ValidateRoot(%q) = %v, want nil", root, err)
+		}
+	}
+	for _, root := range []string{"", "src", "./src", "~src"} {
+		if err := ValidateRoot(root); err == nil {
+			t.Errorf("ValidateRoot(%q) = nil, want an error", root)
+		}
+	}
+}
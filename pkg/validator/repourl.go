@@ -0,0 +1,180 @@
+package validator
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// RepoURL is a remote repository location parsed by ParseRepoURL
+type RepoURL struct {
+	// Scheme is http, https, ssh (also for the scp-like form), git, or file
+	Scheme string
+
+	// User is the user name, if any; never a password
+	User string
+
+	// Host is the host name without brackets or port (empty for file URLs)
+	Host string
+
+	// Port is the port, if given
+	Port string
+
+	// Path is the repository's path on the host
+	Path string
+}
+
+// repoURLSchemes are the URL schemes git clones from
+var repoURLSchemes = map[string]bool{"http": true, "https": true, "ssh": true, "git": true, "file": true}
+
+// IsRepoURL reports whether path names a remote repository rather than a
+// local directory: a URL with a scheme, or git's scp-like [user@]host:path
+// form. As in git, a colon before the first slash makes a path scp-like,
+// unless it follows a Windows drive letter.
+func IsRepoURL(path string) bool {
+	return strings.Contains(path, "://") || scpColon(path) >= 0
+}
+
+// scpColon returns the index of the colon ending the host of the scp-like
+// path, skipping a bracketed IPv6 address, or -1 if path is not scp-like
+func scpColon(path string) int {
+	start := 0
+	if at := strings.Index(path, "@["); at >= 0 && !strings.ContainsAny(path[:at], ":/\\") {
+		start = at + 1
+	}
+	if strings.HasPrefix(path[start:], "[") {
+		if end := strings.Index(path[start:], "]"); end >= 0 {
+			start += end
+		}
+	}
+	colon := strings.Index(path[start:], ":")
+	if colon < 0 {
+		return -1
+	}
+	colon += start
+	if strings.ContainsAny(path[:colon], "/\\") || colon == 1 {
+		return -1
+	}
+	return colon
+}
+
+// ParseRepoURL parses a remote repository URL: scheme://[user@]host[:port]/path
+// for http, https, ssh, and git; file:///path; or scp-like
+// [user@]host:path. It rejects user and host names that an SSH client
+// could take for options, and file URLs to the paths ValidateRepoPath
+// rejects.
+func ParseRepoURL(s string) (*RepoURL, error) {
+	for _, r := range s {
+		if r <= ' ' || r == 0x7f {
+			return nil, fmt.Errorf("repository URL cannot contain spaces or control characters")
+		}
+	}
+
+	var r RepoURL
+	if strings.Contains(s, "://") {
+		u, err := url.Parse(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid repository URL: %w", err)
+		}
+		if !repoURLSchemes[u.Scheme] {
+			return nil, fmt.Errorf("unsupported repository URL scheme %q (use http, https, ssh, git, or file)", u.Scheme)
+		}
+		r = RepoURL{Scheme: u.Scheme, Host: u.Hostname(), Port: u.Port(), Path: u.Path}
+		if u.User != nil {
+			r.User = u.User.Username()
+		}
+		if r.Scheme == "file" {
+			if r.Host != "" && r.Host != "localhost" {
+				return nil, fmt.Errorf("file URLs cannot name a host: %s", s)
+			}
+			if r.User != "" || r.Port != "" {
+				return nil, fmt.Errorf("file URLs cannot have a user or port: %s", s)
+			}
+			r.Host = ""
+			if !strings.HasPrefix(r.Path, "/") {
+				return nil, fmt.Errorf("file URLs need an absolute path: %s", s)
+			}
+			if err := validateLocalPath(r.Path); err != nil {
+				return nil, err
+			}
+			return &r, nil
+		}
+	} else {
+		colon := scpColon(s)
+		if colon < 0 {
+			return nil, fmt.Errorf("not a repository URL: %s", s)
+		}
+		userHost := s[:colon]
+		r = RepoURL{Scheme: "ssh", Host: userHost, Path: s[colon+1:]}
+		if i := strings.LastIndex(userHost, "@"); i >= 0 {
+			r.User, r.Host = userHost[:i], userHost[i+1:]
+		}
+		r.Host = strings.TrimSuffix(strings.TrimPrefix(r.Host, "["), "]")
+	}
+
+	if r.Host == "" {
+		return nil, fmt.Errorf("repository URL has no host: %s", s)
+	}
+	if strings.HasPrefix(r.Host, "-") || strings.HasPrefix(r.User, "-") {
+		return nil, fmt.Errorf("repository URL user and host cannot start with '-': %s", s)
+	}
+	if strings.Trim(r.Path, "/") == "" {
+		return nil, fmt.Errorf("repository URL has no path: %s", s)
+	}
+	if strings.HasPrefix(r.Path, "-") {
+		return nil, fmt.Errorf("repository URL path cannot start with '-': %s", s)
+	}
+	return &r, nil
+}
+
+// ValidateRepoHost checks the host of a remote repository URL against
+// allow and deny lists of host patterns, matched case-insensitively with
+// path.Match syntax (git.example.com, *.example.com). A denied host is
+// rejected, and with a non-empty allow list so is every host not on it,
+// including file URLs, which have none. Local paths are not checked.
+func ValidateRepoHost(repoPath string, allow, deny []string) error {
+	if !IsRepoURL(repoPath) || (len(allow) == 0 && len(deny) == 0) {
+		return nil
+	}
+	u, err := ParseRepoURL(repoPath)
+	if err != nil {
+		return err
+	}
+	host := strings.ToLower(u.Host)
+	if host == "" {
+		if len(allow) > 0 {
+			return fmt.Errorf("file URLs are not allowed when repository hosts are restricted: %s", repoPath)
+		}
+		return nil
+	}
+	if matchHost(deny, host) {
+		return fmt.Errorf("repository host %s is denied", u.Host)
+	}
+	if len(allow) > 0 && !matchHost(allow, host) {
+		return fmt.Errorf("repository host %s is not in the allowed hosts", u.Host)
+	}
+	return nil
+}
+
+// ValidateHostPattern checks that pattern is a valid host pattern for
+// ValidateRepoHost
+func ValidateHostPattern(pattern string) error {
+	if pattern == "" {
+		return fmt.Errorf("host pattern cannot be empty")
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid host pattern %q: %w", pattern, err)
+	}
+	return nil
+}
+
+// matchHost reports whether host matches one of patterns
+func matchHost(patterns []string, host string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(strings.ToLower(p), host); ok {
+			return true
+		}
+	}
+	return false
+}
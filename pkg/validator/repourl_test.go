@@ -0,0 +1,115 @@
+package validator
+
+import (
+	"testing"
+)
+
+func TestIsRepoURL(t *testing.T) {
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{"https://github.com/user/repo.git", true},
+		{"ssh://git@github.com/user/repo.git", true},
+		{"file:///srv/git/repo.git", true},
+		{"git@github.com:user/repo.git", true},
+		{"github.com:user/repo.git", true},
+		{".", false},
+		{"/home/user/repo", false},
+		{"./dir:with/colon", false},
+		{`C:\src\repo`, false},
+		{"C:/src/repo", false},
+		{"http-server", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsRepoURL(tt.input); got != tt.want {
+			t.Errorf("IsRepoURL(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseRepoURL(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    RepoURL
+		wantErr bool
+	}{
+		{input: "https://token@github.com/user/repo.git", want: RepoURL{Scheme: "https", User: "token", Host: "github.com", Path: "/user/repo.git"}},
+		{input: "ssh://git@git.example.com:2222/team/repo.git", want: RepoURL{Scheme: "ssh", User: "git", Host: "git.example.com", Port: "2222", Path: "/team/repo.git"}},
+		{input: "git://git.example.com/repo.git", want: RepoURL{Scheme: "git", Host: "git.example.com", Path: "/repo.git"}},
+		{input: "git@github.com:user/repo.git", want: RepoURL{Scheme: "ssh", User: "git", Host: "github.com", Path: "user/repo.git"}},
+		{input: "[::1]:repo.git", want: RepoURL{Scheme: "ssh", Host: "::1", Path: "repo.git"}},
+		{input: "file:///srv/git/repo.git", want: RepoURL{Scheme: "file", Path: "/srv/git/repo.git"}},
+		{input: "file://localhost/srv/git/repo.git", want: RepoURL{Scheme: "file", Path: "/srv/git/repo.git"}},
+		{input: "ssh://git@example.com:port/repo.git", wantErr: true},
+		{input: "ssh://-oProxyCommand=evil/repo", wantErr: true},
+		{input: "-oProxyCommand=evil:repo", wantErr: true},
+		{input: "git@github.com:-upload-pack=evil", wantErr: true},
+		{input: "https:///repo.git", wantErr: true},
+		{input: "https://github.com/", wantErr: true},
+		{input: "git@github.com:", wantErr: true},
+		{input: "ftp://example.com/repo.git", wantErr: true},
+		{input: "file://server/share/repo.git", wantErr: true},
+		{input: "file:///srv/../etc/repo", wantErr: true},
+		{input: "https://github.com/user/repo .git", wantErr: true},
+		{input: "/home/user/repo", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseRepoURL(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseRepoURL(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if err == nil && *got != tt.want {
+			t.Errorf("ParseRepoURL(%q) = %+v, want %+v", tt.input, *got, tt.want)
+		}
+	}
+}
+
+func TestValidateRepoHost(t *testing.T) {
+	allow := []string{"github.com", "*.corp.example"}
+	deny := []string{"legacy.corp.example"}
+
+	tests := []struct {
+		name    string
+		input   string
+		allow   []string
+		deny    []string
+		wantErr bool
+	}{
+		{"no lists", "https://anywhere.example/repo.git", nil, nil, false},
+		{"allowed host", "https://github.com/user/repo.git", allow, deny, false},
+		{"allowed by pattern", "git@git.corp.example:team/repo.git", allow, deny, false},
+		{"case-insensitive", "https://GitHub.com/user/repo.git", allow, deny, false},
+		{"not allowed", "https://gitlab.com/user/repo.git", allow, deny, true},
+		{"denied despite pattern", "ssh://git@legacy.corp.example/repo.git", allow, deny, true},
+		{"denied without allow list", "https://legacy.corp.example/repo.git", nil, deny, true},
+		{"file url with allow list", "file:///srv/git/repo.git", allow, nil, true},
+		{"file url with deny list only", "file:///srv/git/repo.git", nil, deny, false},
+		{"local path", "/home/user/repo", allow, deny, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRepoHost(tt.input, tt.allow, tt.deny)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateRepoHost(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateHostPattern(t *testing.T) {
+	for _, p := range []string{"github.com", "*.example.com", "git?.example.com"} {
+		if err := ValidateHostPattern(p); err != nil {
+			t.Errorf("ValidateHostPattern(%q) = %v", p, err)
+		}
+	}
+	for _, p := range []string{"", "git[.example.com"} {
+		if err := ValidateHostPattern(p); err == nil {
+			t.Errorf("ValidateHostPattern(%q) should fail", p)
+		}
+	}
+}
@@ -4,7 +4,6 @@ package validator
 import (
 	"fmt"
 	"path/filepath"
-	"regexp"
 	"strings"
 )
 
@@ -13,12 +12,14 @@ const (
 	MaxCommits = 1000
 	// MaxWorkers is the maximum number of concurrent workers allowed
 	MaxWorkers = 50
-)
 
-var (
-	// branchNameRegex validates branch names according to git conventions
-	// Allows alphanumeric, hyphens, underscores, forward slashes (for feature branches), and dots (for versions)
-	branchNameRegex = regexp.MustCompile(`^[a-zA-Z0-9/_.-]+$`)
+	// MinHashLength is the shortest abbreviated commit hash accepted
+	MinHashLength = 7
+	// MaxHashLength is the length of a full SHA-1 commit hash
+	MaxHashLength = 40
+
+	// maxAncestryDigits bounds the number in a ~N or ^N suffix
+	maxAncestryDigits = 6
 )
 
 // ValidateNumCommits checks if the number of commits is within reasonable bounds
@@ -43,43 +44,158 @@ func ValidateNumWorkers(n int) error {
 	return nil
 }
 
-// ValidateBranchName checks if a branch name is valid and safe
+// ValidateBranchName checks if a branch name is valid and safe: a ref
+// name git accepts (see checkRefFormat) that does not start with '-'
 func ValidateBranchName(branch string) error {
 	if branch == "" {
 		return nil // Empty is allowed (means use HEAD)
 	}
+	return checkRefFormat("branch name", branch)
+}
 
-	// Check for suspicious patterns
-	if strings.Contains(branch, "..") {
-		return fmt.Errorf("branch name contains suspicious pattern '..'")
+// ValidateRef checks if a ref naming where analysis starts, or what it
+// compares against, is valid and safe. Besides branches it accepts tags,
+// remote-tracking refs (origin/main), full ref names (refs/tags/v1.2.0),
+// and full or abbreviated commit hashes. Rev-spec operators such as ~, ^,
+// and @{...} are not accepted.
+func ValidateRef(ref string) error {
+	if ref == "" {
+		return nil // Empty is allowed (means use HEAD)
 	}
-	if strings.HasPrefix(branch, "-") {
-		return fmt.Errorf("branch name cannot start with '-'")
+	return checkRefFormat("ref", ref)
+}
+
+// ValidateCommitHash checks that hash is a full or abbreviated commit
+// hash: MinHashLength to MaxHashLength hexadecimal digits
+func ValidateCommitHash(hash string) error {
+	if len(hash) < MinHashLength || len(hash) > MaxHashLength {
+		return fmt.Errorf("commit hash must be %d to %d hex digits, got %d characters", MinHashLength, MaxHashLength, len(hash))
 	}
-	if strings.HasPrefix(branch, "/") || strings.HasSuffix(branch, "/") {
-		return fmt.Errorf("branch name cannot start or end with '/'")
+	for _, r := range hash {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+			return fmt.Errorf("commit hash contains non-hex character %q", r)
+		}
 	}
+	return nil
+}
+
+// ValidateRevSpec checks that spec is a simple revision: a ref name or
+// commit hash as accepted by ValidateRef, or HEAD, followed by any number
+// of ~N and ^N ancestry suffixes (HEAD~3, v1.2.0^, main~2^2). Reflog
+// selectors (@{...}), peeling (^{...}), paths (:), and ranges are not
+// accepted.
+func ValidateRevSpec(spec string) error {
+	if spec == "" {
+		return fmt.Errorf("rev spec cannot be empty")
+	}
+	base, suffix := spec, ""
+	if i := strings.IndexAny(spec, "~^"); i >= 0 {
+		base, suffix = spec[:i], spec[i:]
+	}
+	if base == "" {
+		return fmt.Errorf("rev spec must start with a ref or commit: %s", spec)
+	}
+	if err := checkRefFormat("rev spec", base); err != nil {
+		return err
+	}
+	for suffix != "" {
+		if suffix[0] != '~' && suffix[0] != '^' {
+			return fmt.Errorf("rev spec has unsupported suffix %q: %s", suffix, spec)
+		}
+		digits := 0
+		for digits+1 < len(suffix) && suffix[digits+1] >= '0' && suffix[digits+1] <= '9' {
+			digits++
+		}
+		if digits > maxAncestryDigits {
+			return fmt.Errorf("rev spec ancestry number is too large: %s", spec)
+		}
+		suffix = suffix[1+digits:]
+	}
+	return nil
+}
 
-	// Validate against regex
-	if !branchNameRegex.MatchString(branch) {
-		return fmt.Errorf("branch name contains invalid characters: %s", branch)
+// ValidateRange checks that r is a commit range A..B of two simple
+// revisions (see ValidateRevSpec); B may be left out for HEAD, as in
+// "v1.2.0..". Symmetric differences (A...B) are not accepted.
+func ValidateRange(r string) error {
+	from, to, ok := strings.Cut(r, "..")
+	if !ok {
+		return fmt.Errorf("range must have the form A..B, got %q", r)
 	}
+	if strings.HasPrefix(to, ".") {
+		return fmt.Errorf("symmetric difference ranges (A...B) are not supported: %s", r)
+	}
+	if from == "" {
+		return fmt.Errorf("range must name its start commit: %s", r)
+	}
+	if err := ValidateRevSpec(from); err != nil {
+		return fmt.Errorf("range start: %w", err)
+	}
+	if to == "" {
+		return nil
+	}
+	if err := ValidateRevSpec(to); err != nil {
+		return fmt.Errorf("range end: %w", err)
+	}
+	return nil
+}
 
+// checkRefFormat applies the rules of git check-ref-format to name,
+// allowing one-level names such as main, and rejects a leading '-' that
+// git would take for an option. kind names what name is in errors.
+func checkRefFormat(kind, name string) error {
+	if strings.HasPrefix(name, "-") {
+		return fmt.Errorf("%s cannot start with '-'", kind)
+	}
+	if name == "@" {
+		return fmt.Errorf("%s cannot be '@'", kind)
+	}
+	if strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/") {
+		return fmt.Errorf("%s cannot start or end with '/'", kind)
+	}
+	if strings.HasSuffix(name, ".") {
+		return fmt.Errorf("%s cannot end with '.'", kind)
+	}
+	for _, pattern := range []string{"..", "@{", "//"} {
+		if strings.Contains(name, pattern) {
+			return fmt.Errorf("%s cannot contain '%s'", kind, pattern)
+		}
+	}
+	for _, r := range name {
+		if r < 0x20 || r == 0x7f {
+			return fmt.Errorf("%s cannot contain control characters", kind)
+		}
+		if strings.ContainsRune(" ~^:?*[\\", r) {
+			return fmt.Errorf("%s cannot contain %q", kind, r)
+		}
+	}
+	for _, component := range strings.Split(name, "/") {
+		if strings.HasPrefix(component, ".") {
+			return fmt.Errorf("%s components cannot start with '.': %s", kind, name)
+		}
+		if strings.HasSuffix(component, ".lock") {
+			return fmt.Errorf("%s components cannot end with '.lock': %s", kind, name)
+		}
+	}
 	return nil
 }
 
 // ValidateRepoPath validates that a repository path is safe to use
-// It checks for directory traversal attempts and other suspicious patterns
+// It checks remote URLs with ParseRepoURL, and local paths for directory
+// traversal attempts and other suspicious patterns
 func ValidateRepoPath(path string) error {
 	if path == "" {
 		return fmt.Errorf("repository path cannot be empty")
 	}
-
-	// Allow remote URLs
-	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") || strings.HasPrefix(path, "git@") {
-		return nil // Remote URLs are handled by git itself
+	if IsRepoURL(path) {
+		_, err := ParseRepoURL(path)
+		return err
 	}
+	return validateLocalPath(path)
+}
 
+// validateLocalPath checks a local repository path
+func validateLocalPath(path string) error {
 	// Clean the path to resolve any . or .. components
 	cleanPath := filepath.Clean(path)
 
@@ -1,6 +1,7 @@
 package validator
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -69,7 +70,11 @@ func TestValidateBranchName(t *testing.T) {
 		{"starts with dash", "-main", true},
 		{"starts with slash", "/main", true},
 		{"ends with slash", "main/", true},
-		{"special chars", "branch@name", true},
+		{"at sign", "branch@name", false},
+		{"remote-tracking branch", "origin/main", false},
+		{"reflog syntax", "main@{1}", true},
+		{"double dot", "feature..fix", true},
+		{"lock suffix", "main.lock", true},
 		{"spaces", "my branch", true},
 		{"null byte", "main\x00evil", true},
 	}
@@ -84,6 +89,42 @@ func TestValidateBranchName(t *testing.T) {
 	}
 }
 
+func TestValidateRef(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{"empty (valid - means HEAD)", "", false},
+		{"branch", "main", false},
+		{"tag", "v1.2.3", false},
+		{"remote-tracking ref", "origin/main", false},
+		{"full ref name", "refs/tags/v1.2.3", false},
+		{"full hash", "be938a8e8cb0ff3041d9850e3ba187f04f0d80fd", false},
+		{"abbreviated hash", "be938a8", false},
+		{"directory traversal", "../etc/passwd", true},
+		{"range", "main..feature", true},
+		{"starts with dash", "--upload-pack=evil", true},
+		{"ends with slash", "origin/", true},
+		{"ancestor operator", "main~2", true},
+		{"parent operator", "main^", true},
+		{"reflog", "main@{1}", true},
+		{"at sign", "release@2024", false},
+		{"lock suffix", "refs/heads/main.lock", true},
+		{"hidden component", "origin/.main", true},
+		{"spaces", "my tag", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRef(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateRef(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestValidateRepoPath(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -97,6 +138,12 @@ func TestValidateRepoPath(t *testing.T) {
 		{"http url", "https://github.com/user/repo.git", false},
 		{"https url", "https://github.com/user/repo.git", false},
 		{"git ssh", "git@github.com:user/repo.git", false},
+		{"ssh url with port", "ssh://git@git.example.com:2222/team/repo.git", false},
+		{"scp-like without user", "git.example.com:team/repo.git", false},
+		{"file url", "file:///srv/git/repo.git", false},
+		{"ssh option as host", "ssh://-oProxyCommand=evil/repo", true},
+		{"unsupported scheme", "ftp://example.com/repo.git", true},
+		{"file url to etc", "file:///etc/repo", true},
 		{"directory traversal", "../../../etc/passwd", true},
 		{"etc path", "/etc/config", true},
 		{"sys path", "/sys/devices", true},
@@ -139,8 +186,7 @@ func TestValidateErrorMessage(t *testing.T) {
 	}
 }
 
-func TestValidateBranchNameRegex(t *testing.T) {
-	// Additional regex-specific tests
+func TestCheckRefFormat(t *testing.T) {
 	validNames := []string{
 		"main",
 		"develop",
@@ -149,33 +195,210 @@ func TestValidateBranchNameRegex(t *testing.T) {
 		"release/v1.0.0",
 		"hotfix/prod-crash",
 		"user/john/experimental",
+		"origin/main",
+		"refs/remotes/origin/main",
 		"1234",
 		"v2.0",
+		"fix#123",
+		"a.lock.b",
+		"x@y",
 	}
 
 	for _, name := range validNames {
 		t.Run("valid:"+name, func(t *testing.T) {
-			if !branchNameRegex.MatchString(name) {
-				t.Errorf("branchNameRegex should match %q but didn't", name)
+			if err := checkRefFormat("ref", name); err != nil {
+				t.Errorf("checkRefFormat(%q) = %v, want nil", name, err)
 			}
 		})
 	}
 
 	invalidNames := []string{
 		"branch name with spaces",
-		"branch@with#special",
 		"branch:with:colons",
-		"branch;with;semicolons",
-		"branch&ampersand",
-		"branch|pipe",
-		"branch<>brackets",
+		"tab\tname",
+		"del\x7fname",
+		"main..dev",
+		"main@{upstream}",
+		"@",
+		"main.lock",
+		"feature/x.lock/y",
+		".hidden",
+		"feature/.hidden",
+		"ends-with-dot.",
+		"double//slash",
+		"star*",
+		"question?",
+		"bracket[1]",
+		"back\\slash",
+		"tilde~1",
+		"caret^",
+		"-option",
 	}
 
 	for _, name := range invalidNames {
 		t.Run("invalid:"+name, func(t *testing.T) {
-			if branchNameRegex.MatchString(name) {
-				t.Errorf("branchNameRegex should not match %q but did", name)
+			if err := checkRefFormat("ref", name); err == nil {
+				t.Errorf("checkRefFormat(%q) should fail but didn't", name)
+			}
+		})
+	}
+}
+
+func TestValidateCommitHash(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{"full hash", "be938a8e8cb0ff3041d9850e3ba187f04f0d80fd", false},
+		{"abbreviated hash", "be938a8", false},
+		{"upper case", "BE938A8E", false},
+		{"too short", "be938a", true},
+		{"too long", "be938a8e8cb0ff3041d9850e3ba187f04f0d80fd0", true},
+		{"not hex", "be938g8", true},
+		{"ref name", "main", true},
+		{"option", "--all", true},
+		{"empty", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateCommitHash(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCommitHash(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateRevSpec(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{"HEAD", "HEAD", false},
+		{"ancestor", "HEAD~3", false},
+		{"parent", "v1.2.0^", false},
+		{"second parent", "main~2^2", false},
+		{"bare operators", "HEAD~^~", false},
+		{"hash with ancestor", "be938a8~1", false},
+		{"remote-tracking ref", "origin/main~1", false},
+		{"empty", "", true},
+		{"operator only", "~3", true},
+		{"reflog", "main@{1}", true},
+		{"peel", "v1.2.0^{commit}", true},
+		{"path", "HEAD:main.go", true},
+		{"range", "main..dev", true},
+		{"option", "--output=/tmp/x", true},
+		{"huge ancestry number", "HEAD~99999999", true},
+		{"trailing text", "HEAD~3x", true},
+		{"space", "HEAD ~3", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRevSpec(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateRevSpec(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
 			}
 		})
 	}
 }
+
+func TestValidateRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{"tags", "v1.2.0..v1.3.0", false},
+		{"open end", "v1.2.0..", false},
+		{"rev specs", "HEAD~10..HEAD~2", false},
+		{"hashes", "be938a8..4f1c2d9", false},
+		{"no dots", "v1.2.0", true},
+		{"open start", "..HEAD", true},
+		{"symmetric difference", "main...dev", true},
+		{"two ranges", "a..b..c", true},
+		{"option", "--all..HEAD", true},
+		{"bad end", "v1.2.0..HEAD@{1}", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRange(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateRange(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// checkInert fails if s, accepted by a validator, could be read by git
+// plumbing as anything but a revision: an option, a path, a reflog or
+// peel selector, a range, or a pattern
+func checkInert(t *testing.T, s string) {
+	t.Helper()
+	if strings.HasPrefix(s, "-") {
+		t.Errorf("accepted %q, which starts like an option", s)
+	}
+	for _, bad := range []string{"..", "@{", "^{", ":", "?", "*", "[", "\\"} {
+		if strings.Contains(s, bad) {
+			t.Errorf("accepted %q, which contains %q", s, bad)
+		}
+	}
+	for _, r := range s {
+		if r <= ' ' || r == 0x7f {
+			t.Errorf("accepted %q, which contains %q", s, r)
+		}
+	}
+}
+
+func FuzzValidateCommitHash(f *testing.F) {
+	for _, seed := range []string{"be938a8", "be938a8e8cb0ff3041d9850e3ba187f04f0d80fd", "-be938a8", "be938a8\n", ""} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		if ValidateCommitHash(s) != nil {
+			return
+		}
+		checkInert(t, s)
+		if len(s) < MinHashLength || len(s) > MaxHashLength {
+			t.Errorf("accepted %q of length %d", s, len(s))
+		}
+	})
+}
+
+func FuzzValidateRevSpec(f *testing.F) {
+	for _, seed := range []string{"HEAD~3", "v1.2.0^", "main~2^2", "main@{1}", "HEAD:go.mod", "--all", "a\x00b", "é~1"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		if ValidateRevSpec(s) != nil {
+			return
+		}
+		checkInert(t, s)
+		base, _, _ := strings.Cut(s, "~")
+		base, _, _ = strings.Cut(base, "^")
+		if ValidateRef(base) != nil {
+			t.Errorf("accepted %q, whose base %q is not a valid ref", s, base)
+		}
+	})
+}
+
+func FuzzValidateRange(f *testing.F) {
+	for _, seed := range []string{"v1.2.0..v1.3.0", "v1.2.0..", "a...b", "..HEAD", "a..b..c", "HEAD~1..--all"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		if ValidateRange(s) != nil {
+			return
+		}
+		from, to, _ := strings.Cut(s, "..")
+		checkInert(t, from)
+		checkInert(t, to)
+		if ValidateRevSpec(from) != nil || (to != "" && ValidateRevSpec(to) != nil) {
+			t.Errorf("accepted %q with an invalid side", s)
+		}
+	})
+}
@@ -0,0 +1,260 @@
+// Package worktree checks out commits into temporary directories, for
+// running commands such as tests at a past commit without touching the
+// repository's own worktree. Checkouts are written from the repository's
+// objects, so in-memory and bare repositories work too, and none of git's
+// worktree bookkeeping is left behind.
+//
+// A Manager keeps the checkouts of a run under one directory: callers
+// asking for the same commit share its checkout, a released checkout is
+// kept for reuse until more than MaxIdle are idle, and Close removes them
+// all.
+package worktree
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// DefaultMaxIdle is how many released checkouts a Manager keeps for reuse
+const DefaultMaxIdle = 4
+
+// ErrClosed is returned by Checkout once the Manager is closed
+var ErrClosed = errors.New("worktree manager closed")
+
+// Manager checks out commits into directories under a temporary directory
+// of its own, created on first use. It is safe for concurrent use.
+type Manager struct {
+	// MaxIdle is how many released checkouts are kept for reuse, the most
+	// recently released first (0: DefaultMaxIdle, negative: none)
+	MaxIdle int
+
+	dir string // where the Manager's directory is created
+
+	mu     sync.Mutex
+	root   string                  // the Manager's directory ("" until first use)
+	trees  map[plumbing.Hash]*tree // checkouts by commit
+	clock  int64                   // orders releases, for eviction
+	closed bool
+}
+
+// tree is the checkout of one commit
+type tree struct {
+	dir   string
+	err   error
+	ready chan struct{} // closed once dir is written, or err is set
+
+	refs     int   // Worktrees holding it
+	released int64 // Manager.clock at its last release
+}
+
+// Worktree is a checkout of a commit, held until Release
+type Worktree struct {
+	// Dir is the root of the checkout
+	Dir string
+
+	// Hash is the commit checked out
+	Hash plumbing.Hash
+
+	m        *Manager
+	t        *tree
+	released atomic.Bool
+}
+
+// NewManager returns a Manager keeping its checkouts in a new temporary
+// directory under dir (empty: the system's temporary directory)
+func NewManager(dir string) *Manager {
+	return &Manager{dir: dir, trees: map[plumbing.Hash]*tree{}}
+}
+
+// Checkout returns a checkout of c, shared with the other holders of one,
+// which the caller releases with Worktree.Release. Files are written with
+// their executable bits and symbolic links as links; submodules are left
+// out. A checkout is shared and reused as its holders left it, so commands
+// writing to it, such as builds, see each other's files.
+func (m *Manager) Checkout(c *object.Commit) (*Worktree, error) {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil, ErrClosed
+	}
+	if m.root == "" {
+		root, err := os.MkdirTemp(m.dir, "gdc-worktrees-")
+		if err != nil {
+			m.mu.Unlock()
+			return nil, fmt.Errorf("failed to create worktree directory: %w", err)
+		}
+		m.root = root
+	}
+	t, ok := m.trees[c.Hash]
+	if !ok {
+		t = &tree{ready: make(chan struct{})}
+		m.trees[c.Hash] = t
+	}
+	t.refs++
+	root := m.root
+	m.mu.Unlock()
+
+	if ok {
+		<-t.ready
+	} else {
+		t.dir, t.err = write(c, root)
+		close(t.ready)
+	}
+	if t.err != nil {
+		m.mu.Lock()
+		t.refs--
+		if m.trees[c.Hash] == t {
+			delete(m.trees, c.Hash) // a later Checkout tries again
+		}
+		m.mu.Unlock()
+		return nil, t.err
+	}
+	return &Worktree{Dir: t.dir, Hash: c.Hash, m: m, t: t}, nil
+}
+
+// Release gives the checkout back to its Manager, which keeps it for
+// reuse or removes it. Releasing twice does nothing.
+func (w *Worktree) Release() {
+	if !w.released.CompareAndSwap(false, true) {
+		return
+	}
+	m := w.m
+	m.mu.Lock()
+	w.t.refs--
+	if w.t.refs == 0 {
+		m.clock++
+		w.t.released = m.clock
+	}
+	evicted := m.evict()
+	m.mu.Unlock()
+	for _, dir := range evicted {
+		os.RemoveAll(dir)
+	}
+}
+
+// evict forgets the least recently released checkouts while more than
+// MaxIdle are idle, returning their directories for removal; m.mu is held
+func (m *Manager) evict() []string {
+	maxIdle := m.MaxIdle
+	if maxIdle == 0 {
+		maxIdle = DefaultMaxIdle
+	}
+	var evicted []string
+	for {
+		var idle int
+		var oldest plumbing.Hash
+		for h, t := range m.trees {
+			if t.refs > 0 {
+				continue
+			}
+			idle++
+			if o, ok := m.trees[oldest]; !ok || t.released < o.released {
+				oldest = h
+			}
+		}
+		if idle <= max(maxIdle, 0) {
+			return evicted
+		}
+		evicted = append(evicted, m.trees[oldest].dir)
+		delete(m.trees, oldest)
+	}
+}
+
+// Close removes every checkout of the Manager, held or not, and its
+// directory. Checkouts must not be used once it is closed.
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	m.trees = map[plumbing.Hash]*tree{}
+	if m.root == "" {
+		return nil
+	}
+	return os.RemoveAll(m.root)
+}
+
+// write writes the files of c's tree to a new directory under root
+func write(c *object.Commit, root string) (dir string, err error) {
+	short := c.Hash.String()[:8]
+	tree, err := c.Tree()
+	if err != nil {
+		return "", fmt.Errorf("failed to get tree of %s: %w", short, err)
+	}
+	dir, err = os.MkdirTemp(root, short+"-")
+	if err != nil {
+		return "", fmt.Errorf("failed to check out %s: %w", short, err)
+	}
+	links := map[string]bool{}
+	err = tree.Files().ForEach(func(f *object.File) error {
+		if throughLink(f.Name, links) {
+			return nil
+		}
+		if f.Mode == filemode.Symlink {
+			links[f.Name] = true
+		}
+		return writeFile(f, dir)
+	})
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("failed to check out %s: %w", short, err)
+	}
+	return dir, nil
+}
+
+// throughLink reports whether a parent directory of the file name is one
+// of links, which a malformed tree could use to write outside the checkout
+func throughLink(name string, links map[string]bool) bool {
+	for p := path.Dir(name); p != "."; p = path.Dir(p) {
+		if links[p] {
+			return true
+		}
+	}
+	return false
+}
+
+// writeFile writes f under dir, skipping paths that would leave it
+func writeFile(f *object.File, dir string) error {
+	name := filepath.FromSlash(f.Name)
+	if !filepath.IsLocal(name) {
+		return nil
+	}
+	target := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	if f.Mode == filemode.Symlink {
+		link, err := f.Contents()
+		if err != nil {
+			return err
+		}
+		return os.Symlink(link, target)
+	}
+	perm := os.FileMode(0o644)
+	if f.Mode == filemode.Executable {
+		perm = 0o755
+	}
+	r, err := f.Reader()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	w, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
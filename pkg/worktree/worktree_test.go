@@ -0,0 +1,236 @@
+package worktree
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// commitHistory creates a repository with one commit per map of files to
+// write (an empty content removes the file) and returns its commits,
+// oldest first
+func commitHistory(t *testing.T, commits ...map[string]string) []*object.Commit {
+	t.Helper()
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatalf("Failed to init repo: %v", err)
+	}
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("Failed to get worktree: %v", err)
+	}
+	var result []*object.Commit
+	for i, files := range commits {
+		for name, content := range files {
+			full := filepath.Join(dir, name)
+			if content == "" {
+				if _, err := w.Remove(name); err != nil {
+					t.Fatalf("Failed to remove %s: %v", name, err)
+				}
+				continue
+			}
+			if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+				t.Fatalf("Failed to create dir: %v", err)
+			}
+			if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+				t.Fatalf("Failed to write file: %v", err)
+			}
+			if _, err := w.Add(name); err != nil {
+				t.Fatalf("Failed to add file: %v", err)
+			}
+		}
+		hash, err := w.Commit("commit", &git.CommitOptions{
+			Author: &object.Signature{Name: "Test User", Email: "test@example.com", When: time.Unix(int64(i), 0)},
+		})
+		if err != nil {
+			t.Fatalf("Failed to commit: %v", err)
+		}
+		c, err := repo.CommitObject(hash)
+		if err != nil {
+			t.Fatalf("Failed to read commit: %v", err)
+		}
+		result = append(result, c)
+	}
+	return result
+}
+
+func TestCheckout(t *testing.T) {
+	commits := commitHistory(t,
+		map[string]string{"main.go": "package main\n", "pkg/a/a.go": "package a\n"},
+		map[string]string{"main.go": "package main\n\nfunc main() {}\n", "pkg/a/a.go": ""},
+	)
+	m := NewManager(t.TempDir())
+	defer m.Close()
+
+	old, err := m.Checkout(commits[0])
+	if err != nil {
+		t.Fatalf("Checkout failed: %v", err)
+	}
+	if content, err := os.ReadFile(filepath.Join(old.Dir, "pkg/a/a.go")); err != nil || string(content) != "package a\n" {
+		t.Errorf("Expected pkg/a/a.go checked out, got %q, %v", content, err)
+	}
+	head, err := m.Checkout(commits[1])
+	if err != nil {
+		t.Fatalf("Checkout failed: %v", err)
+	}
+	if head.Dir == old.Dir || head.Hash != commits[1].Hash {
+		t.Errorf("Expected a checkout of its own for each commit, got %s and %s", old.Dir, head.Dir)
+	}
+	if _, err := os.Stat(filepath.Join(head.Dir, "pkg/a/a.go")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected the removed file left out, got %v", err)
+	}
+
+	// Holders of the same commit share its checkout, which outlives them
+	again, err := m.Checkout(commits[0])
+	if err != nil {
+		t.Fatalf("Checkout failed: %v", err)
+	}
+	if again.Dir != old.Dir {
+		t.Errorf("Expected the checkout reused, got %s and %s", old.Dir, again.Dir)
+	}
+	old.Release()
+	old.Release()
+	again.Release()
+	if reused, err := m.Checkout(commits[0]); err != nil || reused.Dir != old.Dir {
+		t.Errorf("Expected the released checkout reused, got %v", err)
+	} else {
+		reused.Release()
+	}
+
+	if err := m.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := os.Stat(head.Dir); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected Close to remove held checkouts, got %v", err)
+	}
+	if _, err := m.Checkout(commits[0]); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+}
+
+func TestCheckoutEviction(t *testing.T) {
+	commits := commitHistory(t,
+		map[string]string{"a.txt": "1"},
+		map[string]string{"a.txt": "2"},
+		map[string]string{"a.txt": "3"},
+	)
+	m := NewManager(t.TempDir())
+	m.MaxIdle = 1
+	defer m.Close()
+
+	var dirs []string
+	for _, c := range commits {
+		wt, err := m.Checkout(c)
+		if err != nil {
+			t.Fatalf("Checkout failed: %v", err)
+		}
+		dirs = append(dirs, wt.Dir)
+		wt.Release()
+	}
+	for i, dir := range dirs {
+		_, err := os.Stat(dir)
+		if kept := err == nil; kept != (i == len(dirs)-1) {
+			t.Errorf("Expected only the last released checkout kept, checkout %d: %v", i, err)
+		}
+	}
+}
+
+func TestCheckoutConcurrent(t *testing.T) {
+	commits := commitHistory(t, map[string]string{"a.txt": "1", "b/c.txt": "2"})
+	m := NewManager(t.TempDir())
+	defer m.Close()
+
+	var wg sync.WaitGroup
+	dirs := make([]string, 8)
+	for i := range dirs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			wt, err := m.Checkout(commits[0])
+			if err != nil {
+				t.Errorf("Checkout failed: %v", err)
+				return
+			}
+			dirs[i] = wt.Dir
+			if _, err := os.Stat(filepath.Join(wt.Dir, "b/c.txt")); err != nil {
+				t.Errorf("Expected a complete checkout, got %v", err)
+			}
+			wt.Release()
+		}()
+	}
+	wg.Wait()
+	for _, dir := range dirs[1:] {
+		if dir != dirs[0] {
+			t.Errorf("Expected one checkout shared by concurrent callers, got %v", dirs)
+			break
+		}
+	}
+}
+
+func TestThroughLink(t *testing.T) {
+	links := map[string]bool{"vendor": true, "a/b": true}
+	tests := map[string]bool{
+		"vendor/x.go": true,
+		"a/b/c/d.go":  true,
+		"a/bc.go":     false,
+		"vendor":      false,
+		"main.go":     false,
+	}
+	for name, want := range tests {
+		if got := throughLink(name, links); got != want {
+			t.Errorf("throughLink(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestCheckoutModes(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatalf("Failed to init repo: %v", err)
+	}
+	w, err := repo.Worktree()
+	if err != nil {
+		t.Fatalf("Failed to get worktree: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "run.sh"), []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := os.Symlink("run.sh", filepath.Join(dir, "latest")); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+	if err := w.AddGlob("*"); err != nil {
+		t.Fatalf("Failed to add files: %v", err)
+	}
+	hash, err := w.Commit("scripts", &git.CommitOptions{
+		Author: &object.Signature{Name: "Test User", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+	c, err := repo.CommitObject(hash)
+	if err != nil {
+		t.Fatalf("Failed to read commit: %v", err)
+	}
+
+	m := NewManager(t.TempDir())
+	defer m.Close()
+	wt, err := m.Checkout(c)
+	if err != nil {
+		t.Fatalf("Checkout failed: %v", err)
+	}
+	defer wt.Release()
+	if info, err := os.Stat(filepath.Join(wt.Dir, "run.sh")); err != nil || info.Mode().Perm()&0o100 == 0 {
+		t.Errorf("Expected run.sh executable, got %v, %v", info, err)
+	}
+	if target, err := os.Readlink(filepath.Join(wt.Dir, "latest")); err != nil || target != "run.sh" {
+		t.Errorf("Expected latest to link to run.sh, got %q, %v", target, err)
+	}
+}